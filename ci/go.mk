@@ -7,6 +7,7 @@ goget:
 
 gobuild:
 	$(GO) build $(GO_BUILD_FLAGS) $(V) ./...
+	$(GO) build $(GO_BUILD_FLAGS) -tags minimal $(V) ./...
 
 gotest:
 	$(GO) test $(GO_TEST_FLAGS) $(V) ./...
@@ -7,7 +7,7 @@ import (
 
 	"github.com/aws/ec2-macos-utils/internal/cmd"
 	"github.com/aws/ec2-macos-utils/internal/contextual"
-	"github.com/aws/ec2-macos-utils/internal/system"
+	"github.com/aws/ec2-macos-utils/pkg/system"
 )
 
 func main() {
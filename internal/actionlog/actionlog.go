@@ -0,0 +1,180 @@
+// Package actionlog provides the functionality necessary for recording the state-changing actions performed on an
+// instance to an append-only log, and querying it.
+package actionlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultPath is where actions are recorded.
+const DefaultPath = "/var/log/ec2-macos-utils/actions.log"
+
+// Result is the outcome of an action.
+type Result string
+
+const (
+	// Success means the action completed.
+	Success Result = "success"
+	// Failure means the action returned an error, which may have left it partially done.
+	Failure Result = "failure"
+)
+
+// Entry records an action: who performed it, what it was, when, and its result.
+type Entry struct {
+	// Time is when the action started.
+	Time time.Time `json:"time"`
+	// Duration is how long the action took.
+	Duration time.Duration `json:"duration_ns"`
+	// RunID correlates the action with the invocation's logs and artifacts.
+	RunID string `json:"run_id,omitempty"`
+	// User is the user the action ran as.
+	User string `json:"user"`
+	// SudoUser is the user who ran the action with sudo, if any.
+	SudoUser string `json:"sudo_user,omitempty"`
+	// Action is the path of the command that performed the action, e.g. "ssh configure".
+	Action string `json:"action"`
+	// Args are the command's positional arguments.
+	Args []string `json:"args,omitempty"`
+	// Flags are the values of the command's flags that were set on the command line or by the configuration.
+	Flags map[string]string `json:"flags,omitempty"`
+	// Detail describes what the action did when it isn't evident from the command, e.g. a watchdog's remediation.
+	Detail string `json:"detail,omitempty"`
+	// Result is the outcome of the action.
+	Result Result `json:"result"`
+	// Error is the error the action failed with.
+	Error string `json:"error,omitempty"`
+	// PrevHash is the hash of the previous entry, and the genesis marker for the first chained entry of the log.
+	PrevHash string `json:"prev_hash,omitempty"`
+	// Hash is the hash of the entry, see Entry.chain.
+	Hash string `json:"hash,omitempty"`
+}
+
+// NewEntry returns an entry for the action started at start, recording the current user as its performer and err as
+// its result.
+func NewEntry(action string, start time.Time, err error) Entry {
+	e := Entry{
+		Time:     start,
+		Duration: time.Since(start),
+		Action:   action,
+		Result:   Success,
+		SudoUser: os.Getenv("SUDO_USER"),
+	}
+	if u, err := user.Current(); err == nil {
+		e.User = u.Username
+	} else {
+		e.User = fmt.Sprint(os.Geteuid())
+	}
+	if err != nil {
+		e.Result, e.Error = Failure, err.Error()
+	}
+
+	return e
+}
+
+// Append records the entry at the end of the log at path, creating it if needed, chained to the log's last entry.
+// Each entry is a line of JSON, and the log is locked while it's appended so that concurrent invocations neither
+// interleave their entries nor chain to the same one.
+func Append(path string, e Entry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// Closing the log releases the lock.
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("lock %s: %w", path, err)
+	}
+
+	last, err := lastEntry(f)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	prevHash := last.Hash
+	if prevHash == "" {
+		prevHash = genesisHash
+	}
+	if e, err = e.chain(prevHash); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+
+	return err
+}
+
+// Query selects entries of the log. The zero value selects every entry.
+type Query struct {
+	// Action selects the entries of the action and, if it's a command group, its subcommands, e.g. "user" selects
+	// "user create".
+	Action string
+	// Since selects the entries of actions started at or after the time.
+	Since time.Time
+	// Failed selects the entries of failed actions.
+	Failed bool
+	// Limit selects the most recent entries, at most this many. Zero means no limit.
+	Limit int
+}
+
+// Match reports whether the query selects the entry, disregarding the limit.
+func (q Query) Match(e Entry) bool {
+	if q.Action != "" && e.Action != q.Action && !strings.HasPrefix(e.Action, q.Action+" ") {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if q.Failed && e.Result != Failure {
+		return false
+	}
+
+	return true
+}
+
+// Read returns the entries of the log at path that the query selects, oldest first. Lines that aren't entries, e.g.
+// one truncated by a full disk, are skipped. A missing log has no entries.
+func Read(path string, q Query) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if !parseEntry(scanner.Bytes(), &e) {
+			continue
+		}
+		if q.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,67 @@
+package actionlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ec2-macos-utils", "actions.log")
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	entries, err := Read(path, Query{})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	grow := NewEntry("grow", start, nil)
+	create := NewEntry("user create", start.Add(time.Hour), errors.New("user exists"))
+	create.Args = []string{"ci"}
+	create.Flags = map[string]string{"admin": "true"}
+	ssh := NewEntry("ssh configure", start.Add(2*time.Hour), nil)
+	for _, e := range []Entry{grow, create, ssh} {
+		assert.NoError(t, Append(path, e))
+	}
+
+	// Truncated lines are skipped.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	assert.NoError(t, err)
+	_, _ = f.WriteString(`{"time":"2024-05-01T15:00:00Z","act`)
+	assert.NoError(t, f.Close())
+
+	entries, err = Read(path, Query{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, create.Args, entries[1].Args)
+	assert.Equal(t, create.Flags, entries[1].Flags)
+	assert.Equal(t, Failure, entries[1].Result)
+	assert.Equal(t, "user exists", entries[1].Error)
+	assert.NotEmpty(t, entries[1].User)
+
+	entries, err = Read(path, Query{Action: "user"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	entries, err = Read(path, Query{Failed: true})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	entries, err = Read(path, Query{Since: start.Add(30 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = Read(path, Query{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "ssh configure", entries[0].Action)
+}
+
+func TestQuery_Match(t *testing.T) {
+	assert.True(t, Query{Action: "user"}.Match(Entry{Action: "user"}))
+	assert.True(t, Query{Action: "user"}.Match(Entry{Action: "user create"}))
+	assert.False(t, Query{Action: "user"}.Match(Entry{Action: "users"}))
+}
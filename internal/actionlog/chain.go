@@ -0,0 +1,199 @@
+package actionlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Entries are chained by hashes to detect changes to the log: each entry records the hash of the previous one, and the
+// first chained entry records the genesis marker, so modifying or removing an entry breaks the chain at the next
+// entry, removing entries from the start leaves a first entry that isn't the start of the chain, and an entry without
+// a hash after the genesis isn't chained. Removing entries from the end can only be detected against a previously
+// recorded head of the chain, i.e. the hash of the last entry at the time.
+//
+// The hashes aren't keyed, so whoever can write the log, i.e. root, can also rewrite the whole chain consistently.
+// That can only be detected against a head recorded off the host, which the rewritten chain doesn't contain.
+
+// genesisHash is recorded as the previous hash of the first chained entry of the log.
+const genesisHash = "genesis"
+
+// chain returns the entry chained to the entry whose hash is prevHash.
+func (e Entry) chain(prevHash string) (Entry, error) {
+	e.PrevHash = prevHash
+	// Entries are hashed as they're decoded from the log so that the hash can be verified, e.g. times lose their
+	// monotonic clock reading and location name.
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, err
+	}
+	var decoded Entry
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return Entry{}, err
+	}
+	e.Hash, err = decoded.hash()
+
+	return e, err
+}
+
+// hash returns the SHA-256 hash of the entry's JSON without its hash, as a hex string.
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// parseEntry decodes a line of the log into e and reports whether it's an entry.
+func parseEntry(line []byte, e *Entry) bool {
+	return json.Unmarshal(line, e) == nil && e.Action != ""
+}
+
+// tailSize is the size of the end of the log that's read to find its last entry, which is doubled until one is
+// found.
+const tailSize = 64 * 1024
+
+// lastEntry returns the last entry of the log, or the zero entry if there's none.
+func lastEntry(f *os.File) (Entry, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return Entry{}, err
+	}
+	size := info.Size()
+	for n := int64(tailSize); ; n *= 2 {
+		offset := size - n
+		if offset < 0 {
+			offset = 0
+		}
+		data := make([]byte, size-offset)
+		if _, err := f.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+			return Entry{}, err
+		}
+		lines := bytes.Split(data, []byte("\n"))
+		// The first line is partial unless the start of the log was read.
+		first := 0
+		if offset > 0 {
+			first = 1
+		}
+		for i := len(lines) - 1; i >= first; i-- {
+			var e Entry
+			if parseEntry(lines[i], &e) {
+				return e, nil
+			}
+		}
+		if offset == 0 {
+			return Entry{}, nil
+		}
+	}
+}
+
+// Problem is a break in the log's chain of entries.
+type Problem struct {
+	// Line is the line of the log at which the chain breaks, or the line after the last one when entries were removed
+	// from the end.
+	Line int `json:"line"`
+	// Message describes the break.
+	Message string `json:"message"`
+}
+
+// Verification is the result of verifying the log's chain of entries.
+type Verification struct {
+	// Entries is the number of chained entries.
+	Entries int `json:"entries"`
+	// Unchained is the number of entries at the start of the log recorded before entries were chained.
+	Unchained int `json:"unchained"`
+	// Head is the hash of the last entry, which can be recorded elsewhere to later detect the removal of entries from
+	// the end of the log.
+	Head string `json:"head,omitempty"`
+	// Problems are the breaks in the chain. The log is intact when there are none.
+	Problems []Problem `json:"problems"`
+}
+
+// Intact reports whether the chain has no breaks.
+func (v Verification) Intact() bool {
+	return len(v.Problems) == 0
+}
+
+// Verify verifies the chain of entries of the log at path. With a head, the hash of the log's last entry recorded
+// earlier, it also verifies that the entry is still in the log, detecting the removal of entries after it or the
+// rewriting of the chain up to it.
+func Verify(path, head string) (Verification, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Verification{}, err
+	}
+	defer f.Close()
+
+	return verify(f, head)
+}
+
+// verify verifies the chain of entries read from r.
+func verify(r io.Reader, head string) (Verification, error) {
+	v := Verification{Problems: []Problem{}}
+	problem := func(line int, format string, args ...interface{}) {
+		v.Problems = append(v.Problems, Problem{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	prev := genesisHash
+	headFound := false
+	line := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line++
+		var e Entry
+		if !parseEntry(scanner.Bytes(), &e) {
+			problem(line, "not an entry")
+			continue
+		}
+		if e.Hash == "" {
+			// Entries recorded before chaining can only precede the genesis.
+			if v.Entries > 0 {
+				problem(line, "entry isn't chained")
+			} else {
+				v.Unchained++
+			}
+			continue
+		}
+
+		hash, err := e.hash()
+		if err != nil {
+			return Verification{}, err
+		}
+		switch {
+		case hash != e.Hash:
+			problem(line, "entry was modified")
+		case e.PrevHash != prev && v.Entries == 0:
+			problem(line, "entries before this one were removed")
+		case e.PrevHash != prev:
+			problem(line, "previous entry was modified or removed")
+		}
+		prev = e.Hash
+		v.Entries++
+		if e.Hash == head {
+			headFound = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Verification{}, err
+	}
+
+	if v.Entries > 0 {
+		v.Head = prev
+	}
+	if head != "" && !headFound {
+		problem(line+1, "head %s isn't in the log, entries after it were removed or the chain was rewritten", head)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,113 @@
+package actionlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeChain appends n entries to a new log and returns its path and lines.
+func writeChain(t *testing.T, n int) (string, []string) {
+	path := filepath.Join(t.TempDir(), "actions.log")
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		e := NewEntry("grow", start.Add(time.Duration(i)*time.Minute), nil)
+		e.Flags = map[string]string{"id": strings.Repeat("x", i)}
+		assert.NoError(t, Append(path, e))
+	}
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	return path, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func mustMarshal(t *testing.T, e Entry) string {
+	data, err := json.Marshal(e)
+	assert.NoError(t, err)
+
+	return string(data)
+}
+
+func verifyLines(t *testing.T, lines []string, head string) Verification {
+	v, err := verify(strings.NewReader(strings.Join(lines, "\n")+"\n"), head)
+	assert.NoError(t, err)
+
+	return v
+}
+
+func TestAppend_Chain(t *testing.T) {
+	path, _ := writeChain(t, 3)
+
+	entries, err := Read(path, Query{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, genesisHash, entries[0].PrevHash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Equal(t, entries[1].Hash, entries[2].PrevHash)
+
+	v, err := Verify(path, entries[1].Hash)
+	assert.NoError(t, err)
+	assert.True(t, v.Intact(), v.Problems)
+	assert.Equal(t, 3, v.Entries)
+	assert.Equal(t, entries[2].Hash, v.Head)
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	_, lines := writeChain(t, 4)
+
+	modified := append([]string{}, lines...)
+	modified[1] = strings.Replace(modified[1], `"result":"success"`, `"result":"failure"`, 1)
+	assert.Equal(t, []Problem{{Line: 2, Message: "entry was modified"}}, verifyLines(t, modified, "").Problems)
+
+	removed := append(append([]string{}, lines[:1]...), lines[2:]...)
+	assert.Equal(t, []Problem{{Line: 2, Message: "previous entry was modified or removed"}}, verifyLines(t, removed, "").Problems)
+
+	assert.Equal(t, []Problem{{Line: 1, Message: "entries before this one were removed"}}, verifyLines(t, lines[1:], "").Problems)
+
+	head := verifyLines(t, lines, "").Head
+	assert.Equal(t, []Problem{{Line: 4, Message: "head " + head + " isn't in the log, entries after it were removed or the chain was rewritten"}}, verifyLines(t, lines[:3], head).Problems)
+
+	// Stripping the hashes of the first entries to pass them off as recorded before chaining breaks the chain too.
+	var e Entry
+	assert.True(t, parseEntry([]byte(lines[0]), &e))
+	e.PrevHash, e.Hash = "", ""
+	stripped := append([]string{}, lines...)
+	stripped[0] = mustMarshal(t, e)
+	assert.Equal(t, []Problem{{Line: 2, Message: "entries before this one were removed"}}, verifyLines(t, stripped, "").Problems)
+
+	// Entries without a hash can't follow the genesis.
+	unchained := append(append([]string{}, lines[:2]...), stripped[0])
+	assert.Equal(t, []Problem{{Line: 3, Message: "entry isn't chained"}}, verifyLines(t, unchained, "").Problems)
+
+	torn := append(append([]string{}, lines...), `{"time":"2024-05-01T`)
+	assert.Equal(t, []Problem{{Line: 5, Message: "not an entry"}}, verifyLines(t, torn, "").Problems)
+}
+
+func TestVerify_Unchained(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.log")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"time":"2024-05-01T12:00:00Z","duration_ns":0,"user":"root","action":"grow","result":"success"}`+"\n"), 0644))
+	assert.NoError(t, Append(path, NewEntry("ssh configure", time.Now(), nil)))
+
+	v, err := Verify(path, "")
+	assert.NoError(t, err)
+	assert.True(t, v.Intact(), v.Problems)
+	assert.Equal(t, 1, v.Unchained)
+	assert.Equal(t, 1, v.Entries)
+}
+
+func TestLastEntry_LargeLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.log")
+	e := NewEntry("grow", time.Now(), nil)
+	e.Detail = strings.Repeat("x", tailSize)
+	assert.NoError(t, Append(path, e))
+	assert.NoError(t, Append(path, NewEntry("grow", time.Now(), nil)))
+
+	v, err := Verify(path, "")
+	assert.NoError(t, err)
+	assert.True(t, v.Intact(), v.Problems)
+}
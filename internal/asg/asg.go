@@ -0,0 +1,104 @@
+// Package asg provides the functionality necessary for reporting on-host health to EC2 Auto Scaling.
+package asg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotInGroup is returned when the instance doesn't belong to an Auto Scaling group.
+var ErrNotInGroup = errors.New("instance is not in an Auto Scaling group")
+
+// API is the subset of the Auto Scaling client used by the Reporter.
+type API interface {
+	DescribeAutoScalingInstances(ctx context.Context, params *autoscaling.DescribeAutoScalingInstancesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DescribeAutoScalingInstancesOutput, error)
+	SetInstanceHealth(ctx context.Context, params *autoscaling.SetInstanceHealthInput, optFns ...func(*autoscaling.Options)) (*autoscaling.SetInstanceHealthOutput, error)
+	CompleteLifecycleAction(ctx context.Context, params *autoscaling.CompleteLifecycleActionInput, optFns ...func(*autoscaling.Options)) (*autoscaling.CompleteLifecycleActionOutput, error)
+}
+
+// Reporter reports the instance's health to its Auto Scaling group.
+type Reporter struct {
+	// Client is the Auto Scaling API client.
+	Client API
+	// InstanceID is the ID of this instance.
+	InstanceID string
+	// GroupName is the instance's Auto Scaling group. It's looked up when empty.
+	GroupName string
+	// LifecycleHook, when set, completes the named lifecycle hook's pending action instead of setting the
+	// instance's health: CONTINUE when healthy and ABANDON otherwise.
+	LifecycleHook string
+}
+
+// Report sends the result of the on-host checks. An unhealthy instance is marked Unhealthy so that Auto Scaling
+// replaces it, respecting the group's health check grace period. Healthy results aren't reported since Auto
+// Scaling's own health checks are authoritative for healthy instances.
+func (r *Reporter) Report(ctx context.Context, healthy bool) error {
+	if r.LifecycleHook == "" && healthy {
+		return nil
+	}
+
+	group, err := r.groupName(ctx)
+	if err != nil {
+		return err
+	}
+	fields := logrus.Fields{
+		"group":    group,
+		"instance": r.InstanceID,
+		"healthy":  healthy,
+	}
+
+	if r.LifecycleHook != "" {
+		result := "CONTINUE"
+		if !healthy {
+			result = "ABANDON"
+		}
+		_, err := r.Client.CompleteLifecycleAction(ctx, &autoscaling.CompleteLifecycleActionInput{
+			AutoScalingGroupName:  aws.String(group),
+			LifecycleHookName:     aws.String(r.LifecycleHook),
+			InstanceId:            aws.String(r.InstanceID),
+			LifecycleActionResult: aws.String(result),
+		})
+		if err != nil {
+			return fmt.Errorf("complete lifecycle action: %w", err)
+		}
+		logrus.WithFields(fields).WithField("hook", r.LifecycleHook).WithField("result", result).Info("Completed lifecycle action")
+		return nil
+	}
+
+	_, err = r.Client.SetInstanceHealth(ctx, &autoscaling.SetInstanceHealthInput{
+		InstanceId:               aws.String(r.InstanceID),
+		HealthStatus:             aws.String("Unhealthy"),
+		ShouldRespectGracePeriod: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("set instance health: %w", err)
+	}
+	logrus.WithFields(fields).Warn("Reported instance as unhealthy to Auto Scaling")
+
+	return nil
+}
+
+// groupName returns the configured group name or looks up the group the instance belongs to.
+func (r *Reporter) groupName(ctx context.Context) (string, error) {
+	if r.GroupName != "" {
+		return r.GroupName, nil
+	}
+
+	out, err := r.Client.DescribeAutoScalingInstances(ctx, &autoscaling.DescribeAutoScalingInstancesInput{
+		InstanceIds: []string{r.InstanceID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("describe Auto Scaling instance: %w", err)
+	}
+	if len(out.AutoScalingInstances) == 0 {
+		return "", ErrNotInGroup
+	}
+	r.GroupName = aws.ToString(out.AutoScalingInstances[0].AutoScalingGroupName)
+
+	return r.GroupName, nil
+}
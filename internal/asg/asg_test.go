@@ -0,0 +1,68 @@
+package asg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAPI struct {
+	group      string
+	health     []*autoscaling.SetInstanceHealthInput
+	lifecycles []*autoscaling.CompleteLifecycleActionInput
+}
+
+func (f *fakeAPI) DescribeAutoScalingInstances(context.Context, *autoscaling.DescribeAutoScalingInstancesInput, ...func(*autoscaling.Options)) (*autoscaling.DescribeAutoScalingInstancesOutput, error) {
+	out := &autoscaling.DescribeAutoScalingInstancesOutput{}
+	if f.group != "" {
+		out.AutoScalingInstances = []types.AutoScalingInstanceDetails{{AutoScalingGroupName: aws.String(f.group)}}
+	}
+
+	return out, nil
+}
+
+func (f *fakeAPI) SetInstanceHealth(_ context.Context, in *autoscaling.SetInstanceHealthInput, _ ...func(*autoscaling.Options)) (*autoscaling.SetInstanceHealthOutput, error) {
+	f.health = append(f.health, in)
+	return &autoscaling.SetInstanceHealthOutput{}, nil
+}
+
+func (f *fakeAPI) CompleteLifecycleAction(_ context.Context, in *autoscaling.CompleteLifecycleActionInput, _ ...func(*autoscaling.Options)) (*autoscaling.CompleteLifecycleActionOutput, error) {
+	f.lifecycles = append(f.lifecycles, in)
+	return &autoscaling.CompleteLifecycleActionOutput{}, nil
+}
+
+func TestReporter_SetInstanceHealth(t *testing.T) {
+	api := &fakeAPI{group: "macs"}
+	r := &Reporter{Client: api, InstanceID: "i-123"}
+
+	assert.NoError(t, r.Report(context.Background(), true))
+	assert.Empty(t, api.health, "healthy results shouldn't be reported")
+
+	assert.NoError(t, r.Report(context.Background(), false))
+	assert.Len(t, api.health, 1)
+	assert.Equal(t, "Unhealthy", aws.ToString(api.health[0].HealthStatus))
+	assert.True(t, aws.ToBool(api.health[0].ShouldRespectGracePeriod))
+}
+
+func TestReporter_LifecycleHook(t *testing.T) {
+	api := &fakeAPI{group: "macs"}
+	r := &Reporter{Client: api, InstanceID: "i-123", LifecycleHook: "launch"}
+
+	assert.NoError(t, r.Report(context.Background(), true))
+	assert.NoError(t, r.Report(context.Background(), false))
+	assert.Len(t, api.lifecycles, 2)
+	assert.Equal(t, "CONTINUE", aws.ToString(api.lifecycles[0].LifecycleActionResult))
+	assert.Equal(t, "ABANDON", aws.ToString(api.lifecycles[1].LifecycleActionResult))
+	assert.Equal(t, "macs", aws.ToString(api.lifecycles[1].AutoScalingGroupName))
+	assert.Empty(t, api.health)
+}
+
+func TestReporter_NotInGroup(t *testing.T) {
+	r := &Reporter{Client: &fakeAPI{}, InstanceID: "i-123"}
+
+	assert.ErrorIs(t, r.Report(context.Background(), false), ErrNotInGroup)
+}
@@ -0,0 +1,293 @@
+// Package audit provides the functionality necessary for configuring the macOS (OpenBSM) audit subsystem: the
+// audit_control settings and the audit daemon.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+const (
+	// ControlPath is the audit_control(5) configuration file.
+	ControlPath = "/etc/security/audit_control"
+
+	// auditdLabel and auditdPlist identify the audit daemon's launchd job.
+	auditdLabel = "com.apple.auditd"
+	auditdPlist = "/System/Library/LaunchDaemons/com.apple.auditd.plist"
+)
+
+// Keys of the audit_control settings that can be configured.
+const (
+	// KeyFlags are the event classes audited for every user.
+	KeyFlags = "flags"
+	// KeyNAFlags are the event classes audited for events that can't be attributed to a user.
+	KeyNAFlags = "naflags"
+	// KeyPolicy are the audit policy flags, e.g. argv to record command arguments.
+	KeyPolicy = "policy"
+	// KeyFileSize is the size a trail file is rotated at.
+	KeyFileSize = "filesz"
+	// KeyExpireAfter is when rotated trail files are removed, by size and/or age.
+	KeyExpireAfter = "expire-after"
+	// KeyMinFree is the percentage of free space under which the oldest trail files are removed.
+	KeyMinFree = "minfree"
+	// KeyDir is the directory trail files are written to.
+	KeyDir = "dir"
+)
+
+// classes are the event classes of audit_class(5).
+var classes = map[string]bool{
+	"fr": true, "fw": true, "fa": true, "fm": true, "fc": true, "fd": true, "cl": true, "pc": true, "nt": true,
+	"ip": true, "na": true, "ad": true, "lo": true, "aa": true, "ap": true, "io": true, "ex": true, "ot": true,
+	"res": true, "all": true, "no": true,
+}
+
+// policies are the policy flags of audit_control(5).
+var policies = map[string]bool{
+	"cnt": true, "ahlt": true, "argv": true, "arge": true, "seq": true, "group": true, "trail": true, "path": true,
+	"zonename": true, "perzone": true,
+}
+
+var (
+	// classExp matches an event class with an optional success (+), failure (-), or negated (^) prefix.
+	classExp = regexp.MustCompile(`^\^?[+-]?([a-z]+)$`)
+	// sizeExp matches sizes such as 2M.
+	sizeExp = regexp.MustCompile(`^\d+[BKMG]?$`)
+	// expireExp matches expire-after values such as "10M", "7d", or "10M AND 30d".
+	expireExp = regexp.MustCompile(`^\d+[BKMGsmhdy]?(?: (?:AND|OR) \d+[BKMGsmhdy]?)?$`)
+)
+
+// Config holds the audit_control settings to change; empty fields are left as they are.
+type Config struct {
+	Flags       []string
+	NAFlags     []string
+	Policy      []string
+	FileSize    string
+	ExpireAfter string
+	// MinFree is set when it's not negative.
+	MinFree int
+}
+
+// Validate checks the settings before they're written.
+func (c Config) Validate() error {
+	for _, list := range [][]string{c.Flags, c.NAFlags} {
+		for _, class := range list {
+			m := classExp.FindStringSubmatch(class)
+			if m == nil || !classes[m[1]] {
+				return fmt.Errorf("unknown audit class %q", class)
+			}
+		}
+	}
+	for _, policy := range c.Policy {
+		if !policies[policy] {
+			return fmt.Errorf("unknown audit policy %q", policy)
+		}
+	}
+	if c.FileSize != "" && !sizeExp.MatchString(c.FileSize) {
+		return fmt.Errorf("invalid file size %q, expected a size such as 2M", c.FileSize)
+	}
+	if c.ExpireAfter != "" && !expireExp.MatchString(c.ExpireAfter) {
+		return fmt.Errorf("invalid expire-after %q, expected a size or age such as 1G or 30d", c.ExpireAfter)
+	}
+	if c.MinFree > 100 {
+		return fmt.Errorf("invalid minfree %d, must be a percentage", c.MinFree)
+	}
+
+	return nil
+}
+
+// settings returns the audit_control lines of the settings to change by key.
+func (c Config) settings() map[string]string {
+	settings := map[string]string{}
+	if len(c.Flags) > 0 {
+		settings[KeyFlags] = strings.Join(c.Flags, ",")
+	}
+	if len(c.NAFlags) > 0 {
+		settings[KeyNAFlags] = strings.Join(c.NAFlags, ",")
+	}
+	if len(c.Policy) > 0 {
+		settings[KeyPolicy] = strings.Join(c.Policy, ",")
+	}
+	if c.FileSize != "" {
+		settings[KeyFileSize] = c.FileSize
+	}
+	if c.ExpireAfter != "" {
+		settings[KeyExpireAfter] = c.ExpireAfter
+	}
+	if c.MinFree >= 0 {
+		settings[KeyMinFree] = strconv.Itoa(c.MinFree)
+	}
+
+	return settings
+}
+
+// ParseControl returns the settings of audit_control by key. Comments and blank lines are skipped.
+func ParseControl(data string) map[string]string {
+	settings := map[string]string{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			settings[key] = value
+		}
+	}
+
+	return settings
+}
+
+// updateControl returns audit_control with the settings changed in place, keeping comments, the order of lines, and
+// other settings. Settings that aren't set yet are appended in key order.
+func updateControl(data string, settings map[string]string) string {
+	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
+	if data == "" {
+		lines = nil
+	}
+	done := map[string]bool{}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key, _, ok := strings.Cut(trimmed, ":")
+		value, change := settings[key]
+		if !ok || !change {
+			continue
+		}
+		lines[i] = key + ":" + value
+		done[key] = true
+	}
+
+	var missing []string
+	for key := range settings {
+		if !done[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		lines = append(lines, key+":"+settings[key])
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Configure changes the audit_control settings and has the audit daemon reload them if it's running. It reports
+// whether the file changed.
+func Configure(ctx context.Context, c Config) (bool, error) {
+	if err := c.Validate(); err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(ControlPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("read %s: %w", ControlPath, err)
+	}
+	updated := updateControl(string(data), c.settings())
+	if updated == string(data) {
+		return false, nil
+	}
+
+	// The file is replaced atomically so that auditd never reads a partial configuration.
+	tmp := ControlPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(updated), 0400); err != nil {
+		return false, fmt.Errorf("write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, ControlPath); err != nil {
+		_ = os.Remove(tmp)
+		return false, fmt.Errorf("replace %s: %w", ControlPath, err)
+	}
+
+	if Enabled(ctx) {
+		if err := run(ctx, "audit", "-s"); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+// Enabled reports whether the audit daemon is loaded.
+func Enabled(ctx context.Context) bool {
+	_, err := util.ExecuteCommand(ctx, []string{"launchctl", "print", "system/" + auditdLabel}, "", nil, nil)
+
+	return err == nil
+}
+
+// Enable enables and loads the audit daemon so that it also runs after reboots.
+func Enable(ctx context.Context) error {
+	if err := run(ctx, "launchctl", "enable", "system/"+auditdLabel); err != nil {
+		return err
+	}
+	if Enabled(ctx) {
+		return nil
+	}
+
+	return run(ctx, "launchctl", "bootstrap", "system", auditdPlist)
+}
+
+// Status is the state of the audit subsystem.
+type Status struct {
+	// Enabled reports whether the audit daemon is loaded.
+	Enabled bool `json:"enabled"`
+	// Settings are the audit_control settings by key.
+	Settings map[string]string `json:"settings"`
+	// CurrentTrail is the trail file being written, if any.
+	CurrentTrail string `json:"current_trail,omitempty"`
+	// Trails is the number of trail files and TrailBytes their total size.
+	Trails     int   `json:"trails"`
+	TrailBytes int64 `json:"trail_bytes"`
+}
+
+// GetStatus returns the state of the audit subsystem.
+func GetStatus(ctx context.Context) (Status, error) {
+	data, err := os.ReadFile(ControlPath)
+	if err != nil {
+		return Status{}, fmt.Errorf("read %s: %w", ControlPath, err)
+	}
+	status := Status{
+		Enabled:  Enabled(ctx),
+		Settings: ParseControl(string(data)),
+	}
+
+	dir := status.Settings[KeyDir]
+	if dir == "" {
+		dir = "/var/audit"
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "current")); err == nil {
+		status.CurrentTrail = target
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return status, nil
+	}
+	for _, entry := range entries {
+		// Trail files are named by their start and end times, e.g. 20240102030405.not_terminated.
+		if entry.Name() == "current" || !entry.Type().IsRegular() {
+			continue
+		}
+		status.Trails++
+		if info, err := entry.Info(); err == nil {
+			status.TrailBytes += info.Size()
+		}
+	}
+
+	return status, nil
+}
+
+// run runs the command and wraps its error with its output.
+func run(ctx context.Context, args ...string) error {
+	out, err := util.ExecuteCommand(ctx, args, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", strings.Join(args, " "), strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testControl = `#
+# $P4: //depot/projects/trustedbsd/openbsm/etc/audit_control#8 $
+#
+dir:/var/audit
+flags:lo,aa
+minfree:5
+naflags:lo,aa
+policy:cnt,argv
+filesz:2M
+expire-after:10M
+superuser-set-sflags-mask:has_authenticated,has_console_access
+`
+
+func TestParseControl(t *testing.T) {
+	settings := ParseControl(testControl)
+	assert.Equal(t, "/var/audit", settings[KeyDir])
+	assert.Equal(t, "lo,aa", settings[KeyFlags])
+	assert.Equal(t, "cnt,argv", settings[KeyPolicy])
+	assert.Equal(t, "10M", settings[KeyExpireAfter])
+	assert.Equal(t, "has_authenticated,has_console_access", settings["superuser-set-sflags-mask"])
+	assert.Len(t, settings, 8)
+}
+
+func TestUpdateControl(t *testing.T) {
+	c := Config{Flags: []string{"lo", "aa", "ex"}, FileSize: "10M", MinFree: -1}
+	assert.Equal(t, `#
+# $P4: //depot/projects/trustedbsd/openbsm/etc/audit_control#8 $
+#
+dir:/var/audit
+flags:lo,aa,ex
+minfree:5
+naflags:lo,aa
+policy:cnt,argv
+filesz:10M
+expire-after:10M
+superuser-set-sflags-mask:has_authenticated,has_console_access
+`, updateControl(testControl, c.settings()))
+
+	c = Config{ExpireAfter: "1G AND 30d", MinFree: 10}
+	assert.Equal(t, "flags:lo\nexpire-after:1G AND 30d\nminfree:10\n", updateControl("flags:lo\n", c.settings()))
+	assert.Equal(t, "minfree:10\n", updateControl("", Config{MinFree: 10}.settings()))
+
+	unchanged := Config{Flags: []string{"lo", "aa"}, MinFree: -1}
+	assert.Equal(t, testControl, updateControl(testControl, unchanged.settings()))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{
+		Flags:       []string{"lo", "aa", "+ex", "-fw", "^-fr"},
+		NAFlags:     []string{"lo"},
+		Policy:      []string{"cnt", "argv"},
+		FileSize:    "2M",
+		ExpireAfter: "1G OR 30d",
+		MinFree:     5,
+	}
+	assert.NoError(t, valid.Validate())
+
+	for name, modify := range map[string]func(*Config){
+		"class":        func(c *Config) { c.Flags = []string{"xx"} },
+		"naflags":      func(c *Config) { c.NAFlags = []string{"lo,aa"} },
+		"policy":       func(c *Config) { c.Policy = []string{"everything"} },
+		"file size":    func(c *Config) { c.FileSize = "2 MB" },
+		"expire-after": func(c *Config) { c.ExpireAfter = "forever" },
+		"minfree":      func(c *Config) { c.MinFree = 101 },
+	} {
+		c := valid
+		modify(&c)
+		assert.Error(t, c.Validate(), name)
+	}
+}
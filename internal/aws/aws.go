@@ -0,0 +1,171 @@
+// Package aws provides the functionality necessary for resolving the AWS configuration shared by every AWS-facing
+// feature of EC2 macOS Utils.
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+// Options customizes how the AWS configuration is resolved. The zero value uses the standard credential chain
+// (environment, shared config, then the instance role) and the region reported by IMDS.
+type Options struct {
+	// Region overrides the region from the environment, shared config, and IMDS.
+	Region string
+	// Profile selects a named profile from the shared config and credentials files.
+	Profile string
+	// EndpointURL overrides the endpoint of every AWS service client (e.g. for testing against a local emulator).
+	EndpointURL string
+	// ServiceEndpoints overrides the endpoint of individual services (e.g. with VPC interface endpoints), keyed by
+	// service ID as used in AWS_ENDPOINT_URL_<SERVICE> environment variables such as CLOUDWATCH_LOGS.
+	ServiceEndpoints map[string]string
+	// UseFIPSEndpoint selects FIPS endpoints, as required in some partitions.
+	UseFIPSEndpoint bool
+	// MaxAttempts is the maximum number of attempts of each AWS API call. Zero uses the SDK's default of 3.
+	MaxAttempts int
+	// MaxBackoff is the maximum delay between attempts. Zero uses the SDK's default of 20 seconds.
+	MaxBackoff time.Duration
+	// Timeout limits each attempt of an AWS API call, including IMDS requests. Zero is unlimited.
+	Timeout time.Duration
+	// IMDSTokenSocket is the socket of the IMDS session token shared by the daemon, see the imdstoken package. IMDS
+	// requests use it when it's served, and request their own token otherwise. Empty always requests a token.
+	IMDSTokenSocket string
+}
+
+// SetEndpoint parses an endpoint override, either a URL for every service or service=URL for a single service, and
+// adds it to the options.
+func (o *Options) SetEndpoint(value string) error {
+	service, endpoint, scoped := strings.Cut(value, "=")
+	if !scoped {
+		service, endpoint = "", value
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid endpoint URL %q", endpoint)
+	}
+
+	if !scoped {
+		o.EndpointURL = endpoint
+		return nil
+	}
+	if o.ServiceEndpoints == nil {
+		o.ServiceEndpoints = map[string]string{}
+	}
+	o.ServiceEndpoints[serviceKey(service)] = endpoint
+
+	return nil
+}
+
+// serviceKey normalizes a service ID the same way the SDK does for AWS_ENDPOINT_URL_<SERVICE>.
+func serviceKey(id string) string {
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToUpper(strings.TrimSpace(id)))
+}
+
+// serviceEndpoints is an SDK configuration source that provides per-service endpoint overrides.
+type serviceEndpoints map[string]string
+
+// GetServiceBaseEndpoint returns the override for the service with the SDK ID, e.g. "CloudWatch Logs".
+func (e serviceEndpoints) GetServiceBaseEndpoint(_ context.Context, sdkID string) (string, bool, error) {
+	endpoint, ok := e[serviceKey(sdkID)]
+	return endpoint, ok, nil
+}
+
+// LoadConfig resolves the AWS configuration for the options. Credentials are resolved lazily, when the first request
+// is made, so an error here only reflects the configuration itself.
+func LoadConfig(ctx context.Context, opts Options) (awssdk.Config, error) {
+	loadOpts := []func(*config.LoadOptions) error{
+		config.WithEC2IMDSRegion(),
+	}
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(opts.Region))
+	}
+	if opts.Profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(opts.Profile))
+	}
+	if opts.UseFIPSEndpoint {
+		loadOpts = append(loadOpts, config.WithUseFIPSEndpoint(awssdk.FIPSEndpointStateEnabled))
+	}
+	loadOpts = append(loadOpts, retryOptions(opts)...)
+	if opts.IMDSTokenSocket != "" {
+		loadOpts = append(loadOpts, config.WithAPIOptions([]func(*middleware.Stack) error{withSharedIMDSToken(opts.IMDSTokenSocket)}))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return awssdk.Config{}, fmt.Errorf("load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		return awssdk.Config{}, errors.New("no AWS region configured, set --region or AWS_REGION when IMDS is unavailable")
+	}
+	if cfg.Credentials != nil {
+		cfg.Credentials = &breakerProvider{provider: cfg.Credentials, breaker: credentialsBreaker}
+	}
+	if opts.EndpointURL != "" {
+		cfg.BaseEndpoint = awssdk.String(opts.EndpointURL)
+	}
+	if len(opts.ServiceEndpoints) > 0 {
+		// Service clients use the first source that provides an endpoint for them, so these take precedence over
+		// endpoints from the shared config.
+		cfg.ConfigSources = append([]interface{}{serviceEndpoints(opts.ServiceEndpoints)}, cfg.ConfigSources...)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"region":            cfg.Region,
+		"partition":         Partition(cfg.Region),
+		"profile":           opts.Profile,
+		"endpoint":          opts.EndpointURL,
+		"fips":              opts.UseFIPSEndpoint,
+		"attempts":          opts.MaxAttempts,
+		"timeout":           opts.Timeout,
+		"imds_token_socket": opts.IMDSTokenSocket,
+	}).Debug("Resolved AWS configuration")
+
+	return cfg, nil
+}
+
+// partitionPrefixes maps region name prefixes to their partition, most specific first. Regions without a matching
+// prefix are in the standard "aws" partition.
+var partitionPrefixes = []struct {
+	prefix, partition string
+}{
+	{"us-gov-", "aws-us-gov"},
+	{"cn-", "aws-cn"},
+	{"us-isob-", "aws-iso-b"},
+	{"us-isof-", "aws-iso-f"},
+	{"eu-isoe-", "aws-iso-e"},
+	{"us-iso-", "aws-iso"},
+}
+
+// Partition returns the partition of the region (e.g. "aws-us-gov" for us-gov-west-1), as used in ARNs.
+func Partition(region string) string {
+	for _, p := range partitionPrefixes {
+		if strings.HasPrefix(region, p.prefix) {
+			return p.partition
+		}
+	}
+
+	return "aws"
+}
+
+// InstanceID returns the instance's ID from its identity document, which is only fetched from IMDS once per process.
+func InstanceID(ctx context.Context, cfg awssdk.Config) (string, error) {
+	identity, err := instance.CachedIdentity(ctx, imds.NewFromConfig(cfg))
+	if err != nil {
+		return "", fmt.Errorf("fetch instance ID: %w", err)
+	}
+
+	return identity.InstanceID, nil
+}
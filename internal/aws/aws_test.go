@@ -0,0 +1,89 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+// isolate keeps the developer's own AWS configuration and IMDS out of the test.
+func isolate(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+}
+
+func TestLoadConfig(t *testing.T) {
+	isolate(t)
+
+	cfg, err := LoadConfig(context.Background(), Options{Region: "us-west-2", EndpointURL: "https://example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west-2", cfg.Region)
+	assert.Equal(t, "https://example.com", awssdk.ToString(cfg.BaseEndpoint))
+}
+
+func TestLoadConfig_EnvironmentRegion(t *testing.T) {
+	isolate(t)
+	t.Setenv("AWS_REGION", "eu-central-1")
+
+	cfg, err := LoadConfig(context.Background(), Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-central-1", cfg.Region)
+	assert.Nil(t, cfg.BaseEndpoint)
+}
+
+func TestLoadConfig_NoRegion(t *testing.T) {
+	isolate(t)
+
+	_, err := LoadConfig(context.Background(), Options{})
+	assert.Error(t, err)
+}
+
+func TestOptions_SetEndpoint(t *testing.T) {
+	var opts Options
+	assert.NoError(t, opts.SetEndpoint("https://vpce-1.logs.us-east-1.vpce.amazonaws.com"))
+	assert.NoError(t, opts.SetEndpoint("cloudwatch-logs=https://vpce-2.logs.us-east-1.vpce.amazonaws.com"))
+	assert.Error(t, opts.SetEndpoint("logs=not a url"))
+	assert.Error(t, opts.SetEndpoint("example.com"))
+
+	assert.Equal(t, "https://vpce-1.logs.us-east-1.vpce.amazonaws.com", opts.EndpointURL)
+	assert.Equal(t, map[string]string{"CLOUDWATCH_LOGS": "https://vpce-2.logs.us-east-1.vpce.amazonaws.com"}, opts.ServiceEndpoints)
+}
+
+func TestLoadConfig_ServiceEndpoints(t *testing.T) {
+	isolate(t)
+
+	opts := Options{Region: "us-gov-west-1"}
+	assert.NoError(t, opts.SetEndpoint("CLOUDWATCH_LOGS=https://logs.example.com"))
+	cfg, err := LoadConfig(context.Background(), opts)
+	assert.NoError(t, err)
+
+	endpoints := cfg.ConfigSources[0].(serviceEndpoints)
+	endpoint, ok, err := endpoints.GetServiceBaseEndpoint(context.Background(), "CloudWatch Logs")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "https://logs.example.com", endpoint)
+
+	_, ok, _ = endpoints.GetServiceBaseEndpoint(context.Background(), "S3")
+	assert.False(t, ok)
+}
+
+func TestPartition(t *testing.T) {
+	for region, partition := range map[string]string{
+		"us-east-1":       "aws",
+		"us-gov-west-1":   "aws-us-gov",
+		"cn-north-1":      "aws-cn",
+		"us-iso-east-1":   "aws-iso",
+		"us-isob-east-1":  "aws-iso-b",
+		"eu-isoe-west-1":  "aws-iso-e",
+		"us-isof-south-1": "aws-iso-f",
+	} {
+		assert.Equal(t, partition, Partition(region), region)
+	}
+}
@@ -0,0 +1,136 @@
+package aws
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/sirupsen/logrus"
+)
+
+// connectivityTimeout is the time allowed for an endpoint connectivity check when the context has no deadline.
+const connectivityTimeout = 10 * time.Second
+
+// Categories of endpoint connectivity failures.
+const (
+	// ConnectivityDNS is a failure to resolve the endpoint's host, e.g. without private DNS for a VPC endpoint.
+	ConnectivityDNS = "dns"
+	// ConnectivityRefused is a connection refused by the endpoint's host.
+	ConnectivityRefused = "refused"
+	// ConnectivityTimedOut is a connection that didn't complete in time, e.g. blocked by a security group.
+	ConnectivityTimedOut = "timeout"
+	// ConnectivityTLS is a failed TLS handshake, e.g. with an untrusted certificate or a host name mismatch.
+	ConnectivityTLS = "tls"
+	// ConnectivityNetwork is any other failure to reach the endpoint.
+	ConnectivityNetwork = "network"
+)
+
+// ConnectivityError is a failure to reach an endpoint.
+type ConnectivityError struct {
+	// Endpoint is the URL of the endpoint.
+	Endpoint string
+	// Category classifies the failure, e.g. ConnectivityDNS.
+	Category string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ConnectivityError) Error() string {
+	return fmt.Sprintf("cannot reach %s (%s): %v", e.Endpoint, e.Category, e.Err)
+}
+
+func (e *ConnectivityError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPClient is the client used to check connectivity, usually the SDK's HTTP client so that the check honors the
+// same proxy, CA bundle, and timeout settings as the API calls.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// CheckConnectivity verifies that endpoint is reachable by sending it a HEAD request. Any HTTP response, including
+// errors such as "403 Forbidden", means that the endpoint is reachable. Failures are returned as *ConnectivityError.
+func CheckConnectivity(ctx context.Context, client HTTPClient, endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid endpoint URL %q", endpoint)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, connectivityTimeout)
+		defer cancel()
+	}
+
+	// The host name is resolved separately to tell missing DNS records apart from other failures, unless requests
+	// go through a proxy which resolves it instead.
+	if proxy, _ := http.ProxyFromEnvironment(&http.Request{URL: u}); proxy == nil {
+		if _, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+			return &ConnectivityError{Endpoint: endpoint, Category: ConnectivityDNS, Err: err}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint URL %q: %w", endpoint, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return &ConnectivityError{Endpoint: endpoint, Category: connectivityCategory(err), Err: err}
+	}
+	_ = resp.Body.Close()
+
+	logrus.WithFields(logrus.Fields{
+		"endpoint": endpoint,
+		"status":   resp.StatusCode,
+	}).Debug("Endpoint is reachable")
+
+	return nil
+}
+
+// CheckConfigConnectivity checks each endpoint with the configuration's HTTP client.
+func CheckConfigConnectivity(ctx context.Context, cfg awssdk.Config, endpoints ...string) error {
+	var client HTTPClient = http.DefaultClient
+	if cfg.HTTPClient != nil {
+		client = cfg.HTTPClient
+	}
+	for _, endpoint := range endpoints {
+		if err := CheckConnectivity(ctx, client, endpoint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connectivityCategory classifies a failed request.
+func connectivityCategory(err error) string {
+	var (
+		dnsErr      *net.DNSError
+		certErr     *tls.CertificateVerificationError
+		unknownErr  x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		recordErr   tls.RecordHeaderError
+		netErr      net.Error
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return ConnectivityDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ConnectivityRefused
+	case errors.As(err, &certErr), errors.As(err, &unknownErr), errors.As(err, &hostnameErr), errors.As(err, &recordErr):
+		return ConnectivityTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ConnectivityTimedOut
+	default:
+		return ConnectivityNetwork
+	}
+}
@@ -0,0 +1,52 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConnectivity(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, CheckConnectivity(context.Background(), server.Client(), server.URL), "any response means the endpoint is reachable")
+
+	var connErr *ConnectivityError
+	err := CheckConnectivity(context.Background(), http.DefaultClient, server.URL)
+	assert.True(t, errors.As(err, &connErr))
+	assert.Equal(t, ConnectivityTLS, connErr.Category, "the test server's certificate isn't trusted")
+}
+
+func TestCheckConnectivity_Refused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	endpoint := "http://" + l.Addr().String()
+	_ = l.Close()
+
+	var connErr *ConnectivityError
+	err = CheckConnectivity(context.Background(), http.DefaultClient, endpoint)
+	assert.True(t, errors.As(err, &connErr))
+	assert.Equal(t, ConnectivityRefused, connErr.Category)
+	assert.Equal(t, endpoint, connErr.Endpoint)
+}
+
+func TestCheckConnectivity_InvalidEndpoint(t *testing.T) {
+	var connErr *ConnectivityError
+	err := CheckConnectivity(context.Background(), http.DefaultClient, "not a url")
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &connErr))
+}
+
+func TestConnectivityCategory(t *testing.T) {
+	assert.Equal(t, ConnectivityDNS, connectivityCategory(&net.DNSError{Err: "no such host", IsNotFound: true}))
+	assert.Equal(t, ConnectivityTimedOut, connectivityCategory(context.DeadlineExceeded))
+	assert.Equal(t, ConnectivityNetwork, connectivityCategory(errors.New("connection reset")))
+}
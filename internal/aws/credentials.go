@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// imdsCredentialsPath is the IMDS path that lists the instance role and serves its credentials.
+const imdsCredentialsPath = "iam/security-credentials/"
+
+// IMDSAPI is the subset of the IMDS client used to inspect the instance role.
+type IMDSAPI interface {
+	GetMetadata(ctx context.Context, params *imds.GetMetadataInput, optFns ...func(*imds.Options)) (*imds.GetMetadataOutput, error)
+}
+
+// STSAPI is the subset of the STS client used to verify credentials.
+type STSAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// ErrNoInstanceRole is returned when no IAM role is attached to the instance.
+var ErrNoInstanceRole = errors.New("no IAM role is attached to the instance, attach an instance profile with the required permissions")
+
+// InstanceRole returns the name of the IAM role attached to the instance, verifying that IMDS serves valid
+// credentials for it.
+func InstanceRole(ctx context.Context, client IMDSAPI, now time.Time) (string, error) {
+	role, err := getMetadata(ctx, client, imdsCredentialsPath)
+	if err != nil {
+		if isNotFound(err) {
+			return "", ErrNoInstanceRole
+		}
+		return "", fmt.Errorf("cannot read the instance role from IMDS: %w", err)
+	}
+	role = strings.TrimSpace(strings.SplitN(role, "\n", 2)[0])
+	if role == "" {
+		return "", ErrNoInstanceRole
+	}
+
+	data, err := getMetadata(ctx, client, imdsCredentialsPath+role)
+	if err != nil {
+		return role, fmt.Errorf("cannot retrieve credentials for role %s from IMDS: %w", role, err)
+	}
+	var creds struct {
+		Code       string
+		Message    string
+		Expiration time.Time
+	}
+	if err := json.Unmarshal([]byte(data), &creds); err != nil {
+		return role, fmt.Errorf("invalid credentials for role %s from IMDS: %w", role, err)
+	}
+	if creds.Code != "Success" {
+		return role, fmt.Errorf("IMDS cannot provide credentials for role %s (%s: %s), check the role's trust policy allows ec2.amazonaws.com", role, creds.Code, creds.Message)
+	}
+	if !creds.Expiration.After(now) {
+		return role, fmt.Errorf("IMDS credentials for role %s expired at %s, check the system clock", role, creds.Expiration.Format(time.RFC3339))
+	}
+
+	return role, nil
+}
+
+// CheckCredentials retrieves credentials from the provider and verifies they haven't expired.
+func CheckCredentials(ctx context.Context, provider awssdk.CredentialsProvider, now time.Time) (awssdk.Credentials, error) {
+	if provider == nil {
+		return awssdk.Credentials{}, errors.New("no credentials configured, attach an instance profile or set --aws-profile")
+	}
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		return creds, fmt.Errorf("cannot retrieve credentials: %w", err)
+	}
+	if creds.CanExpire && !creds.Expires.After(now) {
+		return creds, fmt.Errorf("credentials from %s expired at %s, check the system clock", creds.Source, creds.Expires.Format(time.RFC3339))
+	}
+
+	return creds, nil
+}
+
+// CallerIdentity returns the ARN of the identity the credentials belong to.
+func CallerIdentity(ctx context.Context, client STSAPI) (string, error) {
+	out, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("cannot verify credentials with sts:GetCallerIdentity: %w", err)
+	}
+
+	return awssdk.ToString(out.Arn), nil
+}
+
+// Preflight verifies the configuration has working credentials before a long-running or expensive operation (e.g. an
+// upload) relies on them, returning the ARN of their identity. The instance role is only inspected when the
+// credentials come from it, so credentials from the environment or a profile aren't rejected on instances without a
+// role.
+func Preflight(ctx context.Context, cfg awssdk.Config) (string, error) {
+	now := time.Now()
+	creds, err := CheckCredentials(ctx, cfg.Credentials, now)
+	if creds.Source == ec2rolecreds.ProviderName || (err != nil && creds.Source == "") {
+		if _, roleErr := InstanceRole(ctx, imds.NewFromConfig(cfg), now); roleErr != nil {
+			return "", roleErr
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return CallerIdentity(ctx, sts.NewFromConfig(cfg))
+}
+
+// getMetadata reads an IMDS path.
+func getMetadata(ctx context.Context, client IMDSAPI, path string) (string, error) {
+	out, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: path})
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = out.Content.Close() }()
+
+	data, err := io.ReadAll(out.Content)
+
+	return string(data), err
+}
+
+// isNotFound reports whether err is an HTTP 404 response.
+func isNotFound(err error) bool {
+	var re *awshttp.ResponseError
+	return errors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotFound
+}
@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIMDS serves metadata paths from a map and responds "404 Not Found" for everything else.
+type fakeIMDS map[string]string
+
+func (f fakeIMDS) GetMetadata(_ context.Context, in *imds.GetMetadataInput, _ ...func(*imds.Options)) (*imds.GetMetadataOutput, error) {
+	v, ok := f[in.Path]
+	if !ok {
+		return nil, &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotFound}},
+			Err:      errors.New("not found"),
+		}}
+	}
+
+	return &imds.GetMetadataOutput{Content: io.NopCloser(strings.NewReader(v))}, nil
+}
+
+func TestInstanceRole(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	credentials := func(code, expiration string) fakeIMDS {
+		return fakeIMDS{
+			"iam/security-credentials/":            "mac-builder\n",
+			"iam/security-credentials/mac-builder": `{"Code": "` + code + `", "Expiration": "` + expiration + `"}`,
+		}
+	}
+
+	role, err := InstanceRole(context.Background(), credentials("Success", "2024-05-01T18:00:00Z"), now)
+	assert.NoError(t, err)
+	assert.Equal(t, "mac-builder", role)
+
+	_, err = InstanceRole(context.Background(), credentials("Success", "2024-05-01T11:00:00Z"), now)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "expired")
+
+	_, err = InstanceRole(context.Background(), credentials("AssumeRoleUnauthorizedAccess", "2024-05-01T18:00:00Z"), now)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "trust policy")
+
+	_, err = InstanceRole(context.Background(), fakeIMDS{}, now)
+	assert.ErrorIs(t, err, ErrNoInstanceRole)
+}
+
+func TestCheckCredentials(t *testing.T) {
+	now := time.Now()
+	provider := func(expires time.Time) awssdk.CredentialsProvider {
+		return awssdk.CredentialsProviderFunc(func(context.Context) (awssdk.Credentials, error) {
+			return awssdk.Credentials{AccessKeyID: "AKID", Source: "test", CanExpire: true, Expires: expires}, nil
+		})
+	}
+
+	_, err := CheckCredentials(context.Background(), provider(now.Add(time.Hour)), now)
+	assert.NoError(t, err)
+
+	_, err = CheckCredentials(context.Background(), provider(now.Add(-time.Minute)), now)
+	assert.Error(t, err)
+
+	_, err = CheckCredentials(context.Background(), nil, now)
+	assert.Error(t, err)
+}
+
+type fakeSTS struct{}
+
+func (fakeSTS) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Arn: awssdk.String("arn:aws:sts::123456789012:assumed-role/mac-builder/i-0123")}, nil
+}
+
+func TestCallerIdentity(t *testing.T) {
+	arn, err := CallerIdentity(context.Background(), fakeSTS{})
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:sts::123456789012:assumed-role/mac-builder/i-0123", arn)
+}
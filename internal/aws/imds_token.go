@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/imdstoken"
+)
+
+// imdsTokenProviderID is the ID of the IMDS client's middleware that requests a session token and adds it to requests.
+const imdsTokenProviderID = "APITokenProvider"
+
+// sharedIMDSTokenClients are the clients of the shared token sockets, one per socket, so that every configuration in the
+// process reuses the token it got.
+var sharedIMDSTokenClients sync.Map
+
+// withSharedIMDSToken returns an API option that makes IMDS clients use the token shared on the socket, instead of
+// requesting their own. Requests fall back to the IMDS client's own token when the shared one can't be had, e.g. when
+// the daemon isn't running or the process isn't root. Clients of other services are left alone.
+func withSharedIMDSToken(socketPath string) func(*middleware.Stack) error {
+	client, _ := sharedIMDSTokenClients.LoadOrStore(socketPath, imdstoken.NewClient(socketPath))
+
+	return func(stack *middleware.Stack) error {
+		provider, ok := stack.Finalize.Get(imdsTokenProviderID)
+		if !ok {
+			return nil
+		}
+		_, err := stack.Finalize.Swap(imdsTokenProviderID, &sharedIMDSToken{
+			client:   client.(*imdstoken.Client),
+			fallback: provider,
+		})
+
+		return err
+	}
+}
+
+// sharedIMDSToken is a finalize middleware adding the shared token to IMDS requests.
+type sharedIMDSToken struct {
+	client   *imdstoken.Client
+	fallback middleware.FinalizeMiddleware
+}
+
+// ID returns the ID of the middleware it replaces, so that the IMDS client's other middleware keeps its position.
+func (*sharedIMDSToken) ID() string { return imdsTokenProviderID }
+
+// HandleFinalize adds the shared token to the request, or lets the IMDS client add its own when there's none.
+func (m *sharedIMDSToken) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (
+	middleware.FinalizeOutput, middleware.Metadata, error,
+) {
+	req, ok := in.Request.(*smithyhttp.Request)
+	if !ok {
+		return m.fallback.HandleFinalize(ctx, in, next)
+	}
+	token, err := m.client.Token(ctx)
+	if err != nil {
+		logrus.WithError(err).Trace("Requesting an IMDS token instead of using the shared one")
+		return m.fallback.HandleFinalize(ctx, in, next)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token.Value)
+
+	return next.HandleFinalize(ctx, in)
+}
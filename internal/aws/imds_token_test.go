@@ -0,0 +1,69 @@
+package aws
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/ec2-macos-utils/internal/imdstoken"
+)
+
+func TestWithSharedIMDSToken(t *testing.T) {
+	var tokenRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			tokenRequests.Add(1)
+			w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+			_, _ = io.WriteString(w, "own-token")
+			return
+		}
+		_, _ = io.WriteString(w, r.Header.Get("X-aws-ec2-metadata-token"))
+	}))
+	defer server.Close()
+
+	socket := filepath.Join(t.TempDir(), "imds-token.sock")
+	read := func() string {
+		client := imds.New(imds.Options{
+			Endpoint:   server.URL,
+			APIOptions: []func(*middleware.Stack) error{withSharedIMDSToken(socket)},
+		})
+		out, err := client.GetMetadata(context.Background(), &imds.GetMetadataInput{Path: "instance-id"})
+		require.NoError(t, err)
+		defer out.Content.Close()
+		token, err := io.ReadAll(out.Content)
+		require.NoError(t, err)
+		return string(token)
+	}
+
+	assert.Equal(t, "own-token", read(), "the client's own token should be used while none is shared")
+	assert.EqualValues(t, 1, tokenRequests.Load())
+
+	// The daemon shares a token, which is used by the IMDS clients of a process that starts afterwards.
+	daemonIMDS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "shared-token")
+	}))
+	defer daemonIMDS.Close()
+	cache := &imdstoken.Cache{Endpoint: daemonIMDS.URL}
+	_, err := cache.Refresh(context.Background())
+	require.NoError(t, err)
+	l, err := imdstoken.Listen(socket)
+	require.NoError(t, err)
+	shared := &http.Server{Handler: cache.Handler()}
+	go func() { _ = shared.Serve(l) }()
+	defer shared.Close()
+	sharedIMDSTokenClients.Delete(socket)
+
+	tokenRequests.Store(0)
+	assert.Equal(t, "shared-token", read())
+	assert.Equal(t, "shared-token", read())
+	assert.Zero(t, tokenRequests.Load(), "no token should be requested while one is shared")
+}
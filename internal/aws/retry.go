@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// credentialsBreakerBase is how long credential retrieval fails fast after the first failure.
+	credentialsBreakerBase = 5 * time.Second
+	// credentialsBreakerMax caps how long credential retrieval fails fast after repeated failures.
+	credentialsBreakerMax = 5 * time.Minute
+)
+
+// ErrCredentialsUnavailable is returned without contacting the credential sources while they're failing, e.g. when
+// IMDS is unreachable during a network partition.
+var ErrCredentialsUnavailable = errors.New("AWS credentials unavailable")
+
+// retryOptions returns the configuration options that apply the retry and timeout settings of opts.
+func retryOptions(opts Options) []func(*config.LoadOptions) error {
+	var loadOpts []func(*config.LoadOptions) error
+	if opts.MaxAttempts > 0 || opts.MaxBackoff > 0 {
+		loadOpts = append(loadOpts, config.WithRetryer(func() awssdk.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				if opts.MaxAttempts > 0 {
+					o.MaxAttempts = opts.MaxAttempts
+				}
+				if opts.MaxBackoff > 0 {
+					o.MaxBackoff = opts.MaxBackoff
+				}
+			})
+		}))
+	}
+	if opts.Timeout > 0 {
+		loadOpts = append(loadOpts, config.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(opts.Timeout)))
+	}
+
+	return loadOpts
+}
+
+// credentialsBreaker is a circuit breaker for credential retrieval. After a failure, retrieval fails fast for a period
+// that doubles with each consecutive failure, so callers that retry in a loop (e.g. watchdogs) don't flood an
+// unavailable credential source. It's shared by every configuration in the process.
+var credentialsBreaker = &breaker{now: time.Now}
+
+// breaker tracks consecutive failures and when calls are allowed again.
+type breaker struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	lastErr   error
+}
+
+// allow returns an error wrapping ErrCredentialsUnavailable while the breaker is open.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.now().Before(b.openUntil) {
+		return fmt.Errorf("%w until %s after %d failures: %v", ErrCredentialsUnavailable, b.openUntil.Format(time.RFC3339), b.failures, b.lastErr)
+	}
+
+	return nil
+}
+
+// record closes the breaker on success and opens it on failure.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures, b.openUntil, b.lastErr = 0, time.Time{}, nil
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// The caller gave up, which says nothing about the credential source.
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	wait := credentialsBreakerBase << min(b.failures-1, 16)
+	if wait > credentialsBreakerMax {
+		wait = credentialsBreakerMax
+	}
+	b.openUntil = b.now().Add(wait)
+	logrus.WithError(err).WithFields(logrus.Fields{
+		"failures": b.failures,
+		"wait":     wait,
+	}).Warn("AWS credentials unavailable, pausing retrieval")
+}
+
+// breakerProvider guards a credentials provider with a breaker.
+type breakerProvider struct {
+	provider awssdk.CredentialsProvider
+	breaker  *breaker
+}
+
+// Retrieve retrieves credentials from the provider unless the breaker is open.
+func (p *breakerProvider) Retrieve(ctx context.Context) (awssdk.Credentials, error) {
+	if err := p.breaker.allow(); err != nil {
+		return awssdk.Credentials{}, err
+	}
+	creds, err := p.provider.Retrieve(ctx)
+	p.breaker.record(err)
+
+	return creds, err
+}
@@ -0,0 +1,59 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig_Retry(t *testing.T) {
+	isolate(t)
+
+	cfg, err := LoadConfig(context.Background(), Options{Region: "us-west-2", MaxAttempts: 7, MaxBackoff: time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, cfg.Retryer().MaxAttempts())
+
+	cfg, err = LoadConfig(context.Background(), Options{Region: "us-west-2"})
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.Retryer, "service clients should use their default retryer")
+}
+
+func TestBreakerProvider(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	b := &breaker{now: func() time.Time { return now }}
+
+	calls := 0
+	fail := true
+	p := &breakerProvider{breaker: b, provider: awssdk.CredentialsProviderFunc(func(context.Context) (awssdk.Credentials, error) {
+		calls++
+		if fail {
+			return awssdk.Credentials{}, errors.New("no EC2 IMDS role found")
+		}
+		return awssdk.Credentials{AccessKeyID: "AKID"}, nil
+	})}
+
+	_, err := p.Retrieve(context.Background())
+	assert.Error(t, err)
+	_, err = p.Retrieve(context.Background())
+	assert.ErrorIs(t, err, ErrCredentialsUnavailable)
+	assert.Equal(t, 1, calls, "retrieval should fail fast while the breaker is open")
+
+	now = now.Add(credentialsBreakerBase)
+	_, err = p.Retrieve(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls)
+
+	now = now.Add(credentialsBreakerBase)
+	_, err = p.Retrieve(context.Background())
+	assert.ErrorIs(t, err, ErrCredentialsUnavailable, "the open period should double after consecutive failures")
+
+	now = now.Add(credentialsBreakerMax)
+	fail = false
+	_, err = p.Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, b.failures)
+}
@@ -11,4 +11,12 @@ var (
 
 	// Version is the latest version of the utility. This variable gets set at build-time.
 	Version string
+
+	// UpdateManifestURL is the default location of the signed release manifest used for self-updates. This variable
+	// gets set at build-time.
+	UpdateManifestURL string
+
+	// UpdatePublicKey is the base64 encoded ed25519 key used to verify release manifests. This variable gets set at
+	// build-time.
+	UpdatePublicKey string
 )
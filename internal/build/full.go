@@ -0,0 +1,6 @@
+//go:build !minimal
+
+package build
+
+// Minimal reports whether the utility was built with the minimal build tag.
+const Minimal = false
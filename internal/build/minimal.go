@@ -0,0 +1,7 @@
+//go:build minimal
+
+package build
+
+// Minimal reports whether the utility was built with the minimal build tag, which excludes the features that use S3,
+// CloudWatch, CloudWatch Logs, and SSM Parameter Store to keep the executable small.
+const Minimal = true
@@ -0,0 +1,40 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// minimalExcluded lists the SDK clients of the features the minimal build excludes.
+var minimalExcluded = []string{
+	"github.com/aws/aws-sdk-go-v2/service/s3",
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch",
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs",
+	"github.com/aws/aws-sdk-go-v2/service/ssm",
+	"github.com/aws/aws-sdk-go-v2/service/kms",
+}
+
+func TestMinimalDeps(t *testing.T) {
+	if testing.Short() {
+		t.Skip("lists the dependencies of the minimal build with go list")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go isn't installed")
+	}
+
+	cmd := exec.Command(goTool, "list", "-tags", "minimal", "-deps", "../../cmd/ec2-macos-utils")
+	cmd.Env = append(os.Environ(), "GOOS=darwin", "CGO_ENABLED=1")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	deps := strings.Fields(string(out))
+	for _, excluded := range minimalExcluded {
+		assert.NotContains(t, deps, excluded, "the minimal build shouldn't depend on %s", excluded)
+	}
+}
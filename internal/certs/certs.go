@@ -0,0 +1,215 @@
+// Package certs provides the functionality necessary for installing certificates in macOS keychains as trusted
+// roots, e.g. for TLS interception proxies and private certificate authorities.
+package certs
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// SystemKeychain is the keychain whose certificates are trusted by every user.
+const SystemKeychain = "/Library/Keychains/System.keychain"
+
+// maxCertificateSize limits the size of certificate files downloaded from URLs.
+const maxCertificateSize = 1 << 20
+
+// Certificate is a certificate to install.
+type Certificate struct {
+	*x509.Certificate
+	// Source is the path or URL the certificate was loaded from.
+	Source string
+}
+
+// SHA1 returns the certificate's SHA-1 fingerprint, which security(1) identifies certificates by.
+func (c Certificate) SHA1() string {
+	sum := sha1.Sum(c.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// SHA256 returns the certificate's SHA-256 fingerprint.
+func (c Certificate) SHA256() string {
+	sum := sha256.Sum256(c.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// PEM returns the certificate PEM encoded.
+func (c Certificate) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+}
+
+// Load loads the certificates from the source, a file or an https:// URL, which can be PEM encoded with any number
+// of certificates or a single DER encoded certificate.
+func Load(ctx context.Context, client *http.Client, source string) ([]Certificate, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case strings.HasPrefix(source, "https://"):
+		data, err = download(ctx, client, source)
+	case strings.Contains(source, "://"):
+		return nil, fmt.Errorf("cannot load %s: only https:// URLs are supported", source)
+	default:
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load %s: %w", source, err)
+	}
+
+	parsed, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load %s: %w", source, err)
+	}
+	certs := make([]Certificate, len(parsed))
+	for i, c := range parsed {
+		certs[i] = Certificate{Certificate: c, Source: source}
+	}
+
+	return certs, nil
+}
+
+// download reads the URL.
+func download(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// One byte more than the limit is read so that a larger body fails rather than being truncated, which could
+	// silently drop the certificates at the end of a bundle.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCertificateSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCertificateSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxCertificateSize)
+	}
+
+	return data, nil
+}
+
+// parse parses PEM encoded certificates, or a DER encoded certificate.
+func parse(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	c, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, errors.New("no PEM or DER encoded certificate found")
+	}
+
+	return []*x509.Certificate{c}, nil
+}
+
+// Install adds the certificate to the keychain and trusts it for every user, as a root when it's a CA certificate.
+// The installation is then verified by finding the certificate in the keychain.
+func Install(ctx context.Context, keychain string, cert Certificate) error {
+	f, err := os.CreateTemp("", "ec2-macos-utils-cert-*.pem")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(cert.PEM()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	out, err := util.ExecuteCommand(ctx, installCommand(keychain, cert, f.Name()), "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("install %s: %s: %w", cert.Subject, strings.TrimSpace(out.Stderr), err)
+	}
+
+	installed, err := Installed(ctx, keychain, cert.SHA1())
+	if err != nil {
+		return err
+	}
+	if !installed {
+		return fmt.Errorf("certificate %s was not found in %s after installation", cert.Subject, keychain)
+	}
+
+	return nil
+}
+
+// installCommand returns the command that installs the certificate file with admin trust settings.
+func installCommand(keychain string, cert Certificate, path string) []string {
+	result := "trustAsRoot"
+	if cert.IsCA {
+		result = "trustRoot"
+	}
+
+	return []string{"security", "add-trusted-cert", "-d", "-r", result, "-k", keychain, path}
+}
+
+// Installed returns whether the certificate with the SHA-1 fingerprint is in the keychain.
+func Installed(ctx context.Context, keychain, sha1 string) (bool, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"security", "find-certificate", "-a", "-Z", keychain}, "", nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("list certificates in %s: %s: %w", keychain, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return hasFingerprint(out.Stdout, sha1), nil
+}
+
+// hasFingerprint returns whether security find-certificate -Z's output lists the SHA-1 fingerprint, as
+// "SHA-1 hash: <fingerprint>".
+func hasFingerprint(output, sha1 string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "SHA-1 hash" &&
+			strings.EqualFold(strings.TrimSpace(value), sha1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Remove deletes the certificate with the SHA-1 fingerprint and its trust settings from the keychain.
+func Remove(ctx context.Context, keychain, sha1 string) error {
+	out, err := util.ExecuteCommand(ctx, []string{"security", "delete-certificate", "-Z", sha1, "-t", keychain}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("remove %s: %s: %w", sha1, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newCertificate creates a self-signed certificate.
+func newCertificate(t *testing.T, name string, ca bool) Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  ca,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	c, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return Certificate{Certificate: c}
+}
+
+func TestLoad(t *testing.T) {
+	root, leaf := newCertificate(t, "root", true), newCertificate(t, "leaf", false)
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "bundle.pem")
+	assert.NoError(t, os.WriteFile(bundle, append(root.PEM(), leaf.PEM()...), 0600))
+	der := filepath.Join(dir, "leaf.cer")
+	assert.NoError(t, os.WriteFile(der, leaf.Raw, 0600))
+
+	certs, err := Load(context.Background(), nil, bundle)
+	assert.NoError(t, err)
+	assert.Len(t, certs, 2)
+	assert.Equal(t, root.SHA1(), certs[0].SHA1())
+	assert.Equal(t, leaf.SHA1(), certs[1].SHA1())
+	assert.Equal(t, bundle, certs[0].Source)
+
+	certs, err = Load(context.Background(), nil, der)
+	assert.NoError(t, err)
+	assert.Len(t, certs, 1)
+	assert.Equal(t, leaf.SHA256(), certs[0].SHA256())
+
+	_, err = Load(context.Background(), nil, filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+	_, err = Load(context.Background(), nil, "http://example.com/root.pem")
+	assert.Error(t, err)
+}
+
+func TestLoad_URL(t *testing.T) {
+	root := newCertificate(t, "root", true)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/root.pem":
+			_, _ = w.Write(root.PEM())
+		case "/large.pem":
+			_, _ = w.Write(root.PEM())
+			_, _ = w.Write(bytes.Repeat([]byte("\n"), maxCertificateSize))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	certs, err := Load(context.Background(), server.Client(), server.URL+"/root.pem")
+	assert.NoError(t, err)
+	assert.Len(t, certs, 1)
+	assert.Equal(t, root.SHA1(), certs[0].SHA1())
+
+	_, err = Load(context.Background(), server.Client(), server.URL+"/missing.pem")
+	assert.Error(t, err)
+	_, err = Load(context.Background(), server.Client(), server.URL+"/large.pem")
+	assert.ErrorContains(t, err, "larger than")
+}
+
+func TestInstallCommand(t *testing.T) {
+	assert.Equal(t,
+		[]string{"security", "add-trusted-cert", "-d", "-r", "trustRoot", "-k", SystemKeychain, "/tmp/root.pem"},
+		installCommand(SystemKeychain, newCertificate(t, "root", true), "/tmp/root.pem"))
+	assert.Equal(t,
+		[]string{"security", "add-trusted-cert", "-d", "-r", "trustAsRoot", "-k", SystemKeychain, "/tmp/leaf.pem"},
+		installCommand(SystemKeychain, newCertificate(t, "leaf", false), "/tmp/leaf.pem"))
+}
+
+func TestHasFingerprint(t *testing.T) {
+	output := `SHA-256 hash: 0A1B
+SHA-1 hash: 2C3D4E
+keychain: "/Library/Keychains/System.keychain"
+`
+	assert.True(t, hasFingerprint(output, "2c3d4e"))
+	assert.False(t, hasFingerprint(output, "0A1B"))
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// archiveDefaultMode is the mode of saved archives and their manifests: read-only since diagnostic data shouldn't
+	// be modified, and only by their owner since it's sensitive.
+	archiveDefaultMode fs.FileMode = 0400
+	// archiveDefaultDirMode is the mode of the directories created for archives.
+	archiveDefaultDirMode fs.FileMode = 0700
+)
+
+// archivePermissionArgs is a struct for holding the flags that set the ownership and mode of saved archives and of
+// their directory, e.g. so that an agent fetching them doesn't need to run as root.
+type archivePermissionArgs struct {
+	owner   string
+	group   string
+	mode    string
+	dirMode string
+}
+
+// addFlags registers the archive permission flags.
+func (a *archivePermissionArgs) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&a.owner, "archive-owner", "", "user owning the saved archives and their directory, by name or ID (default root)")
+	flags.StringVar(&a.group, "archive-group", "", "group owning the saved archives and their directory, by name or ID, e.g. a group a fetch agent runs as")
+	flags.StringVar(&a.mode, "archive-mode", fmt.Sprintf("%04o", archiveDefaultMode), "permissions of the saved archives and their manifests, in octal, e.g. 0440 to let --archive-group read them")
+	flags.StringVar(&a.dirMode, "archive-dir-mode", fmt.Sprintf("%04o", archiveDefaultDirMode), "permissions of the archives' directory, in octal, e.g. 0750 to let --archive-group list it")
+}
+
+// resolve parses the modes and looks up the owner and group.
+func (a archivePermissionArgs) resolve() (archivePermissions, error) {
+	p := archivePermissions{uid: -1, gid: -1}
+
+	var err error
+	if p.mode, err = parseArchiveMode(a.mode, archiveDefaultMode); err != nil {
+		return p, fmt.Errorf("invalid archive mode: %w", err)
+	}
+	if p.dirMode, err = parseArchiveMode(a.dirMode, archiveDefaultDirMode); err != nil {
+		return p, fmt.Errorf("invalid archive directory mode: %w", err)
+	}
+	p.chown = a.owner != "" || a.group != ""
+	if a.owner != "" {
+		if p.uid, err = lookupArchiveID(a.owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		}); err != nil {
+			return p, fmt.Errorf("invalid archive owner: %w", err)
+		}
+	}
+	if a.group != "" {
+		if p.gid, err = lookupArchiveID(a.group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		}); err != nil {
+			return p, fmt.Errorf("invalid archive group: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+// parseArchiveMode parses an octal mode, which can't let others write.
+func parseArchiveMode(s string, defaultMode fs.FileMode) (fs.FileMode, error) {
+	if s == "" {
+		return defaultMode, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("%q isn't an octal mode such as 0440", s)
+	}
+	if mode&0002 != 0 {
+		return 0, fmt.Errorf("%s would let anyone write", s)
+	}
+
+	return fs.FileMode(mode), nil
+}
+
+// lookupArchiveID returns the numeric ID of a user or group given by name or ID.
+func lookupArchiveID(s string, lookup func(name string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil && id >= 0 {
+		return id, nil
+	}
+	id, err := lookup(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(id)
+}
+
+// archivePermissions are the ownership and mode of saved archives and of their directory. The zero value, as well as
+// the default flags, keeps them owned by the collecting user (root) and readable only by it.
+type archivePermissions struct {
+	// uid and gid own the archives and their directory when chown is set, unless they're -1.
+	uid, gid int
+	chown    bool
+	// mode and dirMode are the modes of the archives and of their directory. Zero uses the defaults.
+	mode, dirMode fs.FileMode
+}
+
+// fileMode returns the mode of archives.
+func (p archivePermissions) fileMode() fs.FileMode {
+	if p.mode == 0 {
+		return archiveDefaultMode
+	}
+
+	return p.mode
+}
+
+// create creates an archive or manifest at path for writing, which must not exist, with the mode of archives. A
+// symlink at path isn't followed, so the file can't be redirected elsewhere.
+func (p archivePermissions) create(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, p.fileMode())
+}
+
+// apply sets the mode and ownership of an archive or manifest through its open descriptor once it's written. Going
+// through the descriptor rather than the path ensures they only apply to the file that was created, even if the path
+// was replaced meanwhile, e.g. by the archive owner in a directory it owns.
+func (p archivePermissions) apply(f *os.File) error {
+	// The mode is set again in case the umask restricted it.
+	if err := f.Chmod(p.fileMode()); err != nil {
+		return err
+	}
+	if p.chown {
+		return f.Chown(p.uid, p.gid)
+	}
+
+	return nil
+}
+
+// writeFile saves a file that accompanies an archive, such as its manifest, with the mode and ownership of archives.
+// The file is written by write and is removed if it can't be saved.
+func (p archivePermissions) writeFile(path string, write func(w io.Writer) error) (err error) {
+	f, err := p.create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+	if err := write(f); err != nil {
+		return err
+	}
+
+	return p.apply(f)
+}
+
+// mkdir creates the archive directory and its missing parents, and sets the mode and ownership of the directories it
+// created. Existing directories are never changed, since they may be shared with other data, e.g. /var/db.
+func (p archivePermissions) mkdir(dir string) error {
+	dirMode := p.dirMode
+	if dirMode == 0 {
+		dirMode = archiveDefaultDirMode
+	}
+
+	var created []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		created = append(created, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return err
+	}
+
+	if len(created) == 0 && ((p.dirMode != 0 && p.dirMode != archiveDefaultDirMode) || p.chown) {
+		logrus.WithField("dir", dir).Warn("Not changing the permissions of an existing archive directory")
+	}
+	// The deepest directories are changed first, so their parents are still only writable by root when they are.
+	for _, d := range created {
+		if err := p.applyDir(d, dirMode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyDir sets the mode and ownership of a directory created for archives through its descriptor, refusing to follow
+// a symlink that replaced it.
+func (p archivePermissions) applyDir(dir string, mode fs.FileMode) error {
+	f, err := os.OpenFile(dir, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+	if p.chown {
+		return f.Chown(p.uid, p.gid)
+	}
+
+	return nil
+}
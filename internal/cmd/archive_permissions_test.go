@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchivePermissionArgs_Resolve(t *testing.T) {
+	uid, gid := os.Getuid(), os.Getgid()
+
+	perms, err := archivePermissionArgs{mode: "0400", dirMode: "0700"}.resolve()
+	require.NoError(t, err)
+	assert.Equal(t, archivePermissions{uid: -1, gid: -1, mode: 0400, dirMode: 0700}, perms)
+
+	perms, err = archivePermissionArgs{group: strconv.Itoa(gid), mode: "0440", dirMode: "0750"}.resolve()
+	require.NoError(t, err)
+	assert.Equal(t, archivePermissions{uid: -1, gid: gid, chown: true, mode: 0440, dirMode: 0750}, perms)
+
+	perms, err = archivePermissionArgs{owner: strconv.Itoa(uid)}.resolve()
+	require.NoError(t, err)
+	assert.Equal(t, archivePermissions{uid: uid, gid: -1, chown: true, mode: archiveDefaultMode, dirMode: archiveDefaultDirMode}, perms)
+
+	perms, err = archivePermissionArgs{mode: "640"}.resolve()
+	require.NoError(t, err, "modes don't need a leading zero")
+	assert.Equal(t, fs.FileMode(0640), perms.mode)
+
+	for _, args := range []archivePermissionArgs{
+		{mode: "0666"},
+		{mode: "rw-r-----"},
+		{mode: "01755"},
+		{dirMode: "0777"},
+		{owner: "no-such-user-ec2-macos-utils"},
+		{group: "no-such-group-ec2-macos-utils"},
+	} {
+		_, err := args.resolve()
+		assert.Error(t, err, "%+v", args)
+	}
+}
+
+func TestArchivePermissions_Mkdir(t *testing.T) {
+	base := t.TempDir()
+	baseInfo, err := os.Stat(base)
+	require.NoError(t, err)
+	perms := archivePermissions{uid: -1, gid: os.Getgid(), chown: true, mode: 0440, dirMode: 0750}
+
+	dir := filepath.Join(base, "diag", "uuid")
+	require.NoError(t, perms.mkdir(dir))
+	for _, d := range []string{filepath.Join(base, "diag"), dir} {
+		fi, err := os.Stat(d)
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0750), fi.Mode().Perm(), d)
+	}
+	fi, err := os.Stat(base)
+	require.NoError(t, err)
+	assert.Equal(t, baseInfo.Mode(), fi.Mode(), "existing parents should be left alone")
+
+	// Existing directories are never changed, whatever the permissions.
+	existing := filepath.Join(base, "existing")
+	require.NoError(t, os.Mkdir(existing, 0700))
+	require.NoError(t, perms.mkdir(existing))
+	fi, err = os.Stat(existing)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0700), fi.Mode().Perm())
+}
+
+func TestArchivePermissions_WriteFile(t *testing.T) {
+	dir := t.TempDir()
+	perms := archivePermissions{uid: -1, gid: os.Getgid(), chown: true, mode: 0440, dirMode: 0750}
+
+	path := filepath.Join(dir, "sysdiagnose.manifest.json")
+	require.NoError(t, perms.writeFile(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "{}")
+		return err
+	}))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0440), fi.Mode().Perm())
+	assert.Error(t, perms.writeFile(path, func(io.Writer) error { return nil }), "files shouldn't be overwritten")
+
+	// A symlink planted at the path isn't followed.
+	target := filepath.Join(dir, "target")
+	require.NoError(t, os.WriteFile(target, []byte("target"), 0600))
+	link := filepath.Join(dir, "sysdiagnose.tar.gz")
+	require.NoError(t, os.Symlink(target, link))
+	_, err = perms.create(link)
+	assert.Error(t, err)
+	fi, err = os.Stat(target)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0600), fi.Mode().Perm())
+
+	// A file that can't be written is removed.
+	failed := filepath.Join(dir, "failed.json")
+	assert.Error(t, perms.writeFile(failed, func(io.Writer) error { return errors.New("encode failed") }))
+	assert.NoFileExists(t, failed)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/spf13/pflag"
+
+	"github.com/aws/ec2-macos-utils/internal/asg"
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+)
+
+// asgHealthArgs is a struct for holding the flags that report check results to an Auto Scaling group.
+type asgHealthArgs struct {
+	report        bool
+	group         string
+	lifecycleHook string
+}
+
+// addFlags registers the Auto Scaling health reporting flags.
+func (a *asgHealthArgs) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&a.report, "report-asg-health", false, "mark the instance Unhealthy in its Auto Scaling group when checks fail")
+	flags.StringVar(&a.group, "asg-name", "", "Auto Scaling group name (default looked up for the instance)")
+	flags.StringVar(&a.lifecycleHook, "complete-lifecycle-hook", "", "complete this lifecycle hook with CONTINUE when checks pass and ABANDON when they fail")
+}
+
+// enabled reports whether health should be reported to Auto Scaling.
+func (a asgHealthArgs) enabled() bool {
+	return a.report || a.lifecycleHook != ""
+}
+
+// reportHealth reports the check results to the instance's Auto Scaling group when enabled.
+func (a asgHealthArgs) reportHealth(ctx context.Context, healthy bool) error {
+	if !a.enabled() {
+		return nil
+	}
+
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return err
+	}
+	id, err := aws.InstanceID(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	reporter := &asg.Reporter{
+		Client:        autoscaling.NewFromConfig(cfg),
+		InstanceID:    id,
+		GroupName:     a.group,
+		LifecycleHook: a.lifecycleHook,
+	}
+
+	return reporter.Report(ctx, healthy)
+}
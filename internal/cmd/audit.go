@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/audit"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+// auditCommand creates a new command which groups security auditing utilities.
+func auditCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "security auditing utilities",
+		Long:  "utilities for configuring and inspecting the macOS (OpenBSM) audit subsystem",
+	}
+
+	cmd.AddCommand(
+		auditConfigureCommand(),
+		auditStatusCommand(),
+	)
+
+	return cmd
+}
+
+// auditConfigureCommand creates a new command which enables and tunes auditing.
+func auditConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "enable and tune security auditing",
+		Long: strings.TrimSpace(`
+configure enables the audit daemon, so that it also runs after reboots,
+and changes the settings given with flags in ` + audit.ControlPath + `.
+Other settings and comments are kept, and the daemon reloads the
+settings if they changed.
+
+--flags and --naflags are the event classes audited for users and for
+events that can't be attributed to one (see audit_class(5)), e.g.
+lo,aa,ad,ex. Classes can be prefixed with + or - to audit only
+successful or failed events. --policy sets the audit policy, e.g.
+cnt,argv. Trail files are rotated at --filesz and removed after
+--expire-after, a size, an age, or both such as '1G AND 30d', or when
+free space drops under --minfree percent.
+
+With --exec, command-level auditing is turned on: the ex (exec) class is
+added to the audited classes and the argv policy, which records
+command arguments, to the policy, as needed to prove which commands ran
+on build hosts.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var config audit.Config
+	var execAuditing bool
+	cmd.Flags().StringSliceVar(&config.Flags, "flags", nil, "event classes audited for users")
+	cmd.Flags().StringSliceVar(&config.NAFlags, "naflags", nil, "event classes audited for events not attributable to a user")
+	cmd.Flags().StringSliceVar(&config.Policy, "policy", nil, "audit policy flags")
+	cmd.Flags().StringVar(&config.FileSize, "filesz", "", "size trail files are rotated at (e.g. 2M, 100M)")
+	cmd.Flags().StringVar(&config.ExpireAfter, "expire-after", "", "size and/or age rotated trail files are removed after (e.g. 1G, 30d, '1G AND 30d')")
+	cmd.Flags().IntVar(&config.MinFree, "minfree", 0, "percentage of free space under which the oldest trail files are removed")
+	cmd.Flags().BoolVar(&execAuditing, "exec", false, "audit the commands run, with their arguments")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		if !cmd.Flags().Changed("minfree") {
+			config.MinFree = -1
+		}
+		if execAuditing {
+			if err := addExecAuditing(&config); err != nil {
+				return err
+			}
+		}
+
+		changed, err := audit.Configure(ctx, config)
+		if err != nil {
+			return err
+		}
+		if changed {
+			logrus.WithField("path", audit.ControlPath).Info("Updated audit settings")
+		} else {
+			logrus.Info("Audit settings are already up to date")
+		}
+
+		if err := audit.Enable(ctx); err != nil {
+			return err
+		}
+		logrus.Info("Auditing is enabled")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// addExecAuditing adds the ex class and argv policy to the config, starting from the current settings when the
+// config leaves them as they are.
+func addExecAuditing(config *audit.Config) error {
+	data, err := os.ReadFile(audit.ControlPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read %s: %w", audit.ControlPath, err)
+	}
+	current := audit.ParseControl(string(data))
+
+	if len(config.Flags) == 0 && current[audit.KeyFlags] != "" {
+		config.Flags = strings.Split(current[audit.KeyFlags], ",")
+	}
+	if !containsString(config.Flags, "ex") && !containsString(config.Flags, "all") {
+		config.Flags = append(config.Flags, "ex")
+	}
+	if len(config.Policy) == 0 && current[audit.KeyPolicy] != "" {
+		config.Policy = strings.Split(current[audit.KeyPolicy], ",")
+	}
+	if !containsString(config.Policy, "argv") {
+		config.Policy = append(config.Policy, "argv")
+	}
+
+	return nil
+}
+
+// auditStatusCommand creates a new command which prints the state of auditing.
+func auditStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "print the state of security auditing",
+		Long: strings.TrimSpace(`
+status prints whether the audit daemon is enabled, the audit_control
+settings, the trail file being written, and the number and total size
+of trail files, as a table or, with --json, a JSON object.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var asJSON bool
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the status as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		status, err := audit.GetStatus(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(cmd, status)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		enabled := styler.Bad("false")
+		if status.Enabled {
+			enabled = styler.Good("true")
+		}
+		table := output.NewTable(styler, "setting", "value")
+		table.AddRow("enabled", enabled)
+		for _, key := range []string{audit.KeyFlags, audit.KeyNAFlags, audit.KeyPolicy, audit.KeyFileSize, audit.KeyExpireAfter, audit.KeyMinFree, audit.KeyDir} {
+			table.AddRow(key, orDash(status.Settings[key]))
+		}
+		table.AddRow("current trail", orDash(status.CurrentTrail))
+		table.AddRow("trails", fmt.Sprintf("%d (%s)", status.Trails, units.HumanSize(float64(status.TrailBytes))))
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/naming"
+	"github.com/aws/ec2-macos-utils/internal/util"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+const batchDefaultTimeout = 30 * time.Minute
+
+// batchOperation is an operation requested in the batch input.
+type batchOperation struct {
+	// Op is the kind of operation: check, info, or sysdiagnose.
+	Op string `json:"op"`
+	// Name selects the check or the information to query.
+	Name string `json:"name,omitempty"`
+	// OutputDir is where a sysdiagnose is saved.
+	OutputDir string `json:"output_dir,omitempty"`
+	// Upload is the S3 location a sysdiagnose is uploaded to.
+	Upload string `json:"upload,omitempty"`
+	// Reason is why a sysdiagnose is collected, recorded in its manifest and object metadata.
+	Reason string `json:"reason,omitempty"`
+}
+
+// batchOperationResult is the outcome of a batch operation.
+type batchOperationResult struct {
+	Op         string                  `json:"op"`
+	Name       string                  `json:"name,omitempty"`
+	OK         bool                    `json:"ok"`
+	Error      string                  `json:"error,omitempty"`
+	ToolError  *util.ExternalToolError `json:"tool_error,omitempty"`
+	Output     interface{}             `json:"output,omitempty"`
+	DurationMS int64                   `json:"duration_ms"`
+}
+
+// batchResult is the consolidated result of a batch.
+type batchResult struct {
+	RunID      string                 `json:"run_id"`
+	Version    string                 `json:"version"`
+	StartedAt  time.Time              `json:"started_at"`
+	DurationMS int64                  `json:"duration_ms"`
+	OK         bool                   `json:"ok"`
+	Results    []batchOperationResult `json:"results"`
+}
+
+// batchHandler runs a batch operation and returns its output.
+type batchHandler func(ctx context.Context, op batchOperation) (interface{}, error)
+
+// batchHandlers are the handlers of each kind of operation.
+var batchHandlers = map[string]batchHandler{
+	"check":       batchCheck,
+	"info":        batchInfo,
+	"sysdiagnose": batchSysdiagnose,
+}
+
+// batchCommand creates a new command which runs a list of operations and reports their results as one document.
+func batchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "batch",
+		Short: "run a batch of operations from JSON",
+		Long: strings.TrimSpace(`
+batch reads a JSON list of operations from stdin (or --input), runs them
+one after another, and prints a single JSON document with the result of
+each. It's designed to be run by SSM Run Command documents across a
+fleet, where one parseable result per instance is easier to aggregate
+than the output of several commands.
+
+Supported operations:
+  {"op": "check", "name": "imds"}          run a check (see 'check all')
+  {"op": "info", "name": "instance-id"}    query instance-id, identity,
+                                           tags, scheduled-events, or version
+  {"op": "sysdiagnose", "output_dir": "/var/tmp", "upload": "s3://bucket/prefix"}
+                                           collect (and upload) a sysdiagnose,
+                                           with an optional "reason"
+
+Operations that don't start before --timeout are reported as skipped.
+The command fails when any operation fails, after printing the results.
+        `),
+		SilenceUsage: true,
+	}
+
+	var (
+		input   string
+		timeout time.Duration
+	)
+	cmd.Flags().StringVar(&input, "input", "-", "file with the JSON list of operations, - for stdin")
+	cmd.Flags().DurationVar(&timeout, "timeout", batchDefaultTimeout, "time limit for the whole batch")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		var r io.Reader = cmd.InOrStdin()
+		if input != "-" {
+			f, err := os.Open(input)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		var ops []batchOperation
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&ops); err != nil {
+			return fmt.Errorf("invalid batch input: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+		defer cancel()
+		result := runBatch(ctx, ops, batchHandlers)
+
+		if err := printJSON(cmd, result); err != nil {
+			return err
+		}
+		if !result.OK {
+			return errors.New("one or more batch operations failed")
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// runBatch runs the operations in order with the handlers. Operations are skipped once ctx is done.
+func runBatch(ctx context.Context, ops []batchOperation, handlers map[string]batchHandler) batchResult {
+	result := batchResult{
+		RunID:     contextual.RunID(ctx),
+		Version:   build.Version,
+		StartedAt: time.Now().UTC(),
+		OK:        true,
+		Results:   make([]batchOperationResult, 0, len(ops)),
+	}
+
+	for _, op := range ops {
+		start := time.Now()
+		opResult := batchOperationResult{Op: op.Op, Name: op.Name}
+
+		var err error
+		if handler, ok := handlers[op.Op]; !ok {
+			err = fmt.Errorf("unknown operation %q", op.Op)
+		} else if ctx.Err() != nil {
+			err = fmt.Errorf("skipped: %w", ctx.Err())
+		} else {
+			logrus.WithFields(logrus.Fields{"op": op.Op, "name": op.Name}).Info("Running batch operation")
+			opResult.Output, err = handler(ctx, op)
+		}
+
+		opResult.OK = err == nil
+		if err != nil {
+			opResult.Error = err.Error()
+			opResult.ToolError = util.AsExternalToolError(err)
+			result.OK = false
+		}
+		opResult.DurationMS = time.Since(start).Milliseconds()
+		result.Results = append(result.Results, opResult)
+	}
+	result.DurationMS = time.Since(result.StartedAt).Milliseconds()
+
+	return result
+}
+
+// batchCheck runs the named system check.
+func batchCheck(ctx context.Context, op batchOperation) (interface{}, error) {
+	check, ok := systemChecks[op.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown check %q, expected one of %s", op.Name, strings.Join(systemCheckNames(), ", "))
+	}
+
+	return nil, check(ctx)
+}
+
+// batchInfo queries the named information about the instance.
+func batchInfo(ctx context.Context, op batchOperation) (interface{}, error) {
+	if op.Name == "version" {
+		return build.Version, nil
+	}
+
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return nil, err
+	}
+	switch op.Name {
+	case "instance-id":
+		return aws.InstanceID(ctx, cfg)
+	case "identity":
+		return instance.CachedIdentity(ctx, imds.NewFromConfig(cfg))
+	case "tags":
+		return instance.NewTagReader(cfg).Tags(ctx)
+	case "scheduled-events":
+		return instance.ScheduledEvents(ctx, imds.NewFromConfig(cfg))
+	default:
+		return nil, fmt.Errorf("unknown info %q", op.Name)
+	}
+}
+
+// batchSysdiagnose collects a sysdiagnose and uploads it when requested, returning the archive's path.
+func batchSysdiagnose(ctx context.Context, op batchOperation) (interface{}, error) {
+	if !hasRootPrivileges() {
+		return nil, errors.New("root privileges required - run with sudo")
+	}
+
+	args := sysdiagnoseArgs{
+		outputDir: op.OutputDir,
+		timeout:   sysdiagnoseDefaultTimeout,
+		reason:    op.Reason,
+		trigger:   map[string]string{"command": "batch"},
+	}
+	if args.outputDir == "" {
+		args.outputDir = os.TempDir()
+	}
+	args.upload.destination = op.Upload
+	args.upload.key = "{" + naming.Filename + "}"
+	args.upload.metadata = args.metadata()
+	if err := args.upload.validate(); err != nil {
+		return nil, err
+	}
+	if err := args.upload.preflight(ctx); err != nil {
+		return nil, err
+	}
+
+	outputPath, err := runSysdiagnose(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := namingVars(ctx, time.Now(), args.upload.key)
+	if err != nil {
+		return outputPath, err
+	}
+
+	return outputPath, uploadSysdiagnose(ctx, args.upload, vars, outputPath)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+)
+
+func TestRunBatch(t *testing.T) {
+	handlers := map[string]batchHandler{
+		"echo": func(_ context.Context, op batchOperation) (interface{}, error) {
+			return op.Name, nil
+		},
+		"fail": func(context.Context, batchOperation) (interface{}, error) {
+			return nil, errors.New("failed")
+		},
+	}
+	ctx := contextual.WithRunID(context.Background(), "run-1")
+
+	result := runBatch(ctx, []batchOperation{
+		{Op: "echo", Name: "a"},
+		{Op: "fail"},
+		{Op: "unknown"},
+		{Op: "echo", Name: "b"},
+	}, handlers)
+
+	assert.Equal(t, "run-1", result.RunID)
+	assert.False(t, result.OK)
+	assert.Len(t, result.Results, 4)
+	assert.True(t, result.Results[0].OK)
+	assert.Equal(t, "a", result.Results[0].Output)
+	assert.Equal(t, "failed", result.Results[1].Error)
+	assert.Contains(t, result.Results[2].Error, "unknown operation")
+	assert.True(t, result.Results[3].OK, "later operations should run after a failure")
+}
+
+func TestRunBatch_Timeout(t *testing.T) {
+	handlers := map[string]batchHandler{
+		"sleep": func(ctx context.Context, _ batchOperation) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	ctx, cancel := context.WithTimeout(contextual.WithRunID(context.Background(), "run-1"), 10*time.Millisecond)
+	defer cancel()
+
+	result := runBatch(ctx, []batchOperation{{Op: "sleep"}, {Op: "sleep"}}, handlers)
+	assert.False(t, result.OK)
+	assert.Contains(t, result.Results[1].Error, "skipped")
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/certs"
+)
+
+// certsDefaultTimeout is the default timeout of certificate downloads.
+const certsDefaultTimeout = 30 * time.Second
+
+// sha1Fingerprint matches SHA-1 certificate fingerprints once colons are removed.
+var sha1Fingerprint = regexp.MustCompile(`^[0-9A-Fa-f]{40}$`)
+
+// certsCommand creates a new command which groups certificate utilities.
+func certsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "certificate trust utilities",
+		Long:  "utilities for installing and removing trusted certificates in the system keychain",
+	}
+
+	cmd.AddCommand(
+		certsInstallCommand(),
+		certsRemoveCommand(),
+	)
+
+	return cmd
+}
+
+// certsInstallCommand creates a new command which installs trusted certificates.
+func certsInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install <path|url>...",
+		Short: "install trusted certificates",
+		Long: strings.TrimSpace(`
+install imports the certificates from the files or https:// URLs into
+the keychain and trusts them for every user, e.g. the CA of a TLS
+interception proxy or of a private PKI. Files can be PEM encoded with
+any number of certificates or a single DER encoded certificate.
+
+CA certificates are trusted as roots and other certificates are trusted
+as themselves. Every certificate is verified to be in the keychain once
+imported. Certificates that already are have their trust settings set
+again, in case they were changed.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.MinimumNArgs(1),
+		PreRunE: assertRootPrivileges,
+	}
+
+	var (
+		keychain string
+		timeout  time.Duration
+	)
+	cmd.Flags().StringVar(&keychain, "keychain", "system", "keychain to install in, system or a keychain file")
+	cmd.Flags().DurationVar(&timeout, "timeout", certsDefaultTimeout, "timeout of each certificate download")
+
+	cmd.RunE = func(cmd *cobra.Command, sources []string) error {
+		ctx := cmd.Context()
+		path := keychainPath(keychain)
+		client := &http.Client{Timeout: timeout}
+
+		// Every source is loaded first so that a bad one doesn't leave the certificates partially installed.
+		var all []certs.Certificate
+		for _, source := range sources {
+			loaded, err := certs.Load(ctx, client, source)
+			if err != nil {
+				return err
+			}
+			all = append(all, loaded...)
+		}
+
+		for _, cert := range all {
+			fields := logrus.Fields{
+				"subject": cert.Subject.String(),
+				"sha256":  cert.SHA256(),
+				"source":  cert.Source,
+			}
+			installed, err := certs.Installed(ctx, path, cert.SHA1())
+			if err != nil {
+				return err
+			}
+			// Certificates already in the keychain are installed again since their trust settings may have been
+			// changed or removed, which installing them sets.
+			if err := certs.Install(ctx, path, cert); err != nil {
+				return err
+			}
+			if installed {
+				logrus.WithFields(fields).Info("Certificate is already installed, set its trust settings")
+			} else {
+				logrus.WithFields(fields).Info("Installed trusted certificate")
+			}
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// certsRemoveCommand creates a new command which removes trusted certificates.
+func certsRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <path|url|sha1>...",
+		Short: "remove trusted certificates",
+		Long: strings.TrimSpace(`
+remove deletes the certificates, and their trust settings, from the
+keychain. Certificates are given as the files or URLs they were
+installed from, or by their SHA-1 fingerprint. Certificates that aren't
+in the keychain are skipped.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.MinimumNArgs(1),
+		PreRunE: assertRootPrivileges,
+	}
+
+	var (
+		keychain string
+		timeout  time.Duration
+	)
+	cmd.Flags().StringVar(&keychain, "keychain", "system", "keychain to remove from, system or a keychain file")
+	cmd.Flags().DurationVar(&timeout, "timeout", certsDefaultTimeout, "timeout of each certificate download")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		path := keychainPath(keychain)
+
+		fingerprints, err := certFingerprints(ctx, &http.Client{Timeout: timeout}, args)
+		if err != nil {
+			return err
+		}
+		for _, sha1 := range fingerprints {
+			installed, err := certs.Installed(ctx, path, sha1)
+			if err != nil {
+				return err
+			}
+			if !installed {
+				logrus.WithField("sha1", sha1).Info("Certificate is not installed")
+				continue
+			}
+			if err := certs.Remove(ctx, path, sha1); err != nil {
+				return err
+			}
+			logrus.WithField("sha1", sha1).Info("Removed certificate")
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// certFingerprints returns the SHA-1 fingerprints of the arguments, which are fingerprints or certificate sources.
+func certFingerprints(ctx context.Context, client *http.Client, args []string) ([]string, error) {
+	var fingerprints []string
+	for _, arg := range args {
+		if sha1 := strings.ReplaceAll(arg, ":", ""); sha1Fingerprint.MatchString(sha1) {
+			fingerprints = append(fingerprints, strings.ToUpper(sha1))
+			continue
+		}
+		loaded, err := certs.Load(ctx, client, arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range loaded {
+			fingerprints = append(fingerprints, cert.SHA1())
+		}
+	}
+
+	return fingerprints, nil
+}
+
+// keychainPath returns the path of the named keychain.
+func keychainPath(keychain string) string {
+	if keychain == "system" {
+		return certs.SystemKeychain
+	}
+
+	return keychain
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/notify"
+)
+
+// checkAllCommand creates a new command which runs every system check.
+func checkAllCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "all",
+		Short: "run all system checks",
+		Long: strings.TrimSpace(`
+run every system check and print the result of each. The command fails
+if any check fails. Checks of the workload's resources rather than of
+the instance, such as disk-space, and diagnostics that sample over time,
+such as neighbors, only run when they're given to --include, so that
+they don't fail the command, and the instance's Auto Scaling health, on
+hosts that are otherwise healthy.
+
+With --report-asg-health, a failure marks the instance Unhealthy in its
+Auto Scaling group so it is replaced. With --complete-lifecycle-hook,
+the named lifecycle hook is completed with CONTINUE when every check
+passes and ABANDON otherwise, e.g. to gate instance launch on on-host
+checks.
+
+With --notify eventbridge, the result of each check is published to
+EventBridge with the source "ec2-macos-utils" and the detail-type
+"<check> check" (e.g. "imds check"). The instance role must allow
+events:PutEvents. With --notify notification-center, failed checks are
+posted as notifications to the user logged in on the console, e.g. over
+Screen Sharing.
+        `),
+		SilenceUsage: true,
+	}
+
+	var (
+		include    []string
+		asgArgs    asgHealthArgs
+		notifyArgs notifyArgs
+	)
+	cmd.Flags().StringSliceVar(&include, "include", nil, "also run these opt-in checks (available: "+strings.Join(optInCheckNames(), ", ")+")")
+	asgArgs.addFlags(cmd.Flags())
+	notifyArgs.addFlags(cmd.Flags())
+
+	cmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
+		for _, name := range include {
+			if !optInChecks[name] {
+				return fmt.Errorf("unknown opt-in check %q, expected one of %s", name, strings.Join(optInCheckNames(), ", "))
+			}
+		}
+		return notifyArgs.validate()
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		var (
+			failed []string
+			events []notify.Event
+		)
+		names := append(defaultCheckNames(), include...)
+		sort.Strings(names)
+		for _, name := range names {
+			err := systemChecks[name](cmd.Context())
+			printCheckResult(cmd, name, err)
+			if err != nil {
+				failed = append(failed, name)
+			}
+			events = append(events, notify.NewEvent(name, err))
+		}
+
+		// Notifications are best effort so they don't change the outcome of the checks.
+		if err := notifyArgs.notify(cmd.Context(), events...); err != nil {
+			logrus.WithError(err).Error("Failed to publish check results")
+		}
+
+		if err := asgArgs.reportHealth(cmd.Context(), len(failed) == 0); err != nil {
+			logrus.WithError(err).Error("Failed to report health to Auto Scaling")
+			return fmt.Errorf("cannot report health to Auto Scaling: %w", err)
+		}
+
+		if len(failed) > 0 {
+			return fmt.Errorf("%d of %d checks failed: %s", len(failed), len(names), strings.Join(failed, ", "))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// optInCheckNames returns the sorted names of the checks that check all only runs with --include.
+func optInCheckNames() []string {
+	names := make([]string, 0, len(optInChecks))
+	for name := range optInChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
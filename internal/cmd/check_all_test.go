@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultCheckNames(t *testing.T) {
+	names := defaultCheckNames()
+	assert.Contains(t, names, "imds")
+	for name := range optInChecks {
+		assert.NotContains(t, names, name, "opt-in checks shouldn't run by default")
+		assert.Contains(t, systemCheckNames(), name)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+)
+
+// checkCredentialsCommand creates a new command which verifies the instance's AWS credentials.
+func checkCredentialsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "credentials",
+		Short: "check AWS credentials",
+		Long: strings.TrimSpace(`
+verifies that AWS credentials are available and valid: when they come
+from the instance role, that a role is attached and IMDS serves
+unexpired credentials for it; then that the credentials are accepted
+by sts:GetCallerIdentity. The ARN of the identity is printed.
+        `),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			arn, err := runCheckCredentials(cmd.Context())
+			countCheck("credentials", err)
+			printCheckResult(cmd, "credentials", err)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), arn)
+
+			return nil
+		},
+	}
+}
+
+// runCheckCredentials verifies the AWS credentials and returns the ARN of their identity.
+func runCheckCredentials(ctx context.Context) (string, error) {
+	logrus.Info("Starting AWS credentials check")
+
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return "", err
+	}
+	arn, err := aws.Preflight(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	logrus.WithField("arn", arn).Info("AWS credentials check passed")
+	return arn, nil
+}
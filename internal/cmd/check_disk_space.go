@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/diskspace"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+const (
+	// checkDiskSpaceDefaultVolume is the volume checked when --volume isn't set.
+	checkDiskSpaceDefaultVolume = "/"
+	// checkDiskSpaceDefaultMinFree is the least free space that passes the check by default.
+	checkDiskSpaceDefaultMinFree = "10%"
+)
+
+// checkDiskSpaceCommand creates a new command which checks the free space of volumes.
+func checkDiskSpaceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disk-space",
+		Short: "check the free space of volumes",
+		Long: strings.TrimSpace(`
+verifies that each --volume, given by the path of its mount point or of
+any file on it, has at least --min-free free space, as a percentage of
+its capacity (e.g. 10%) or a size (e.g. 20GB). A volume can have its own
+threshold after an equal sign, e.g.:
+
+  ec2-macos-utils check disk-space --volume / --volume /Volumes/Data=50GB --min-free 10%
+
+The free space is the space available to processes that aren't root,
+and the check fails if any volume has less than its threshold or can't
+be read. The free space of each volume is printed, as a JSON object
+with --json. check all only checks the root volume with
+--include disk-space.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+	}
+
+	var (
+		volumes []string
+		minFree string
+		asJSON  bool
+	)
+	cmd.Flags().StringArrayVar(&volumes, "volume", []string{checkDiskSpaceDefaultVolume}, "volume to check, as a path with an optional =<min-free>, can be repeated")
+	cmd.Flags().StringVar(&minFree, "min-free", checkDiskSpaceDefaultMinFree, "least free space of the volumes without their own threshold, as a percentage or a size")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the result as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		specs, err := parseDiskSpaceSpecs(volumes, minFree)
+		if err != nil {
+			return err
+		}
+
+		result := checkDiskSpace(specs)
+		checkErr := countCheck("disk-space", result.Err())
+		if asJSON {
+			if err := printJSON(cmd, result); err != nil {
+				return err
+			}
+			return checkErr
+		}
+		if err := printDiskSpace(cmd, result); err != nil {
+			return err
+		}
+		printCheckResult(cmd, "disk-space", checkErr)
+
+		return checkErr
+	}
+
+	return cmd
+}
+
+// runCheckDiskSpace checks that the root volume has the default free space.
+func runCheckDiskSpace(context.Context) error {
+	specs, err := parseDiskSpaceSpecs([]string{checkDiskSpaceDefaultVolume}, checkDiskSpaceDefaultMinFree)
+	if err != nil {
+		return err
+	}
+
+	return checkDiskSpace(specs).Err()
+}
+
+// parseDiskSpaceSpecs parses the volumes to check, with minFree as the threshold of those without their own.
+func parseDiskSpaceSpecs(volumes []string, minFree string) ([]diskspace.Spec, error) {
+	defaultMinFree, err := diskspace.ParseThreshold(minFree)
+	if err != nil {
+		return nil, err
+	}
+	specs := make([]diskspace.Spec, 0, len(volumes))
+	for _, v := range volumes {
+		spec, err := diskspace.ParseSpec(v, defaultMinFree)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// checkDiskSpace checks the volumes, logging the outcome.
+func checkDiskSpace(specs []diskspace.Spec) diskspace.Result {
+	logrus.Info("Starting disk space check")
+	result := diskspace.Check(specs)
+	if err := result.Err(); err != nil {
+		logrus.WithError(err).Warn("Disk space check failed")
+	} else {
+		logrus.WithField("volumes", len(result.Volumes)).Info("Disk space check passed")
+	}
+
+	return result
+}
+
+// printDiskSpace prints a table of the free space of each volume.
+func printDiskSpace(cmd *cobra.Command, result diskspace.Result) error {
+	styler := contextual.Styler(cmd.Context())
+	table := output.NewTable(styler, "volume", "free", "capacity", "free %", "min free", "status")
+	for _, v := range result.Volumes {
+		if v.Error != "" {
+			table.AddRow(v.Path, "-", "-", "-", v.MinFree, styler.Status(false))
+			continue
+		}
+		table.AddRow(v.Path, units.HumanSize(float64(v.FreeBytes)), units.HumanSize(float64(v.CapacityBytes)),
+			fmt.Sprintf("%.1f%%", v.FreePercent), v.MinFree, styler.Status(v.OK))
+	}
+
+	return table.Render(cmd.OutOrStdout())
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+// checkHistoryCommand creates a new command which shows the recorded results of checks.
+func checkHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history [check]",
+		Short: "show the results of previous checks",
+		Long: strings.TrimSpace(`
+history shows the results of previous runs of the check, or of every
+check, oldest first. Results of checks run as root, including by the
+network health monitor, are recorded in the state database, which keeps
+the most recent results of each check.
+
+--limit shows only the most recent results. With --json, the results are
+printed as JSON.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: assertRootPrivileges,
+	}
+
+	var (
+		limit  int
+		asJSON bool
+	)
+	cmd.Flags().IntVar(&limit, "limit", 50, "maximum number of most recent results to show, or 0 for all")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the results as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if limit < 0 {
+			return errors.New("limit cannot be negative")
+		}
+		var check string
+		if len(args) > 0 {
+			check = args[0]
+		}
+
+		var results []state.CheckResult
+		err := withState(func(s *state.Store) error {
+			var err error
+			results, err = s.CheckHistory(check, limit)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			if results == nil {
+				results = []state.CheckResult{}
+			}
+			return printJSON(cmd, results)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		table := output.NewTable(styler, "time", "check", "result", "error")
+		for _, r := range results {
+			result := styler.Good("ok")
+			if !r.OK {
+				result = styler.Bad("failed")
+			}
+			table.AddRow(r.Time.Local().Format(time.RFC3339), r.Check, result, orDash(r.Error))
+		}
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
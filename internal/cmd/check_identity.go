@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+// checkIdentityCommand creates a new command which verifies the instance identity document.
+func checkIdentityCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "identity",
+		Short: "check the instance identity document",
+		Long: strings.TrimSpace(`
+verifies that the instance identity document can be read from IMDS and
+that it matches its signature. The signature is verified with the AWS
+public certificate for the instance's region, installed as
+<region>.pem (or default.pem) in ` + instance.DefaultCertificateDir + `.
+The certificates aren't installed with the utility; they're listed in
+the EC2 documentation on instance identity documents. The check fails
+when no certificate is installed, since the document can't be verified.
+        `),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := countCheck("identity", runCheckIdentity(cmd.Context()))
+			printCheckResult(cmd, "identity", err)
+			return err
+		},
+	}
+}
+
+// runCheckIdentity reads and verifies the instance identity document, bypassing the cache.
+func runCheckIdentity(ctx context.Context) error {
+	logrus.Info("Starting instance identity check")
+
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return err
+	}
+	identity, err := (&instance.IdentityReader{IMDS: imds.NewFromConfig(cfg), Require: true}).Identity(ctx)
+	if err != nil {
+		logrus.WithError(err).Warn("Instance identity check failed")
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{"instance_id": identity.InstanceID, "region": identity.Region}).
+		Info("Instance identity check passed")
+	return nil
+}
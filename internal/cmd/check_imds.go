@@ -9,12 +9,61 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/selfmetrics"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
 )
 
 const (
 	imdsTokenURL = "http://169.254.169.254/latest/api/token"
 )
 
+// systemChecks are the checks that can be run by name outside of their own command (e.g. to publish as metrics).
+var systemChecks = map[string]func(ctx context.Context) error{
+	"imds":       countedCheck("imds", runCheckIMDS),
+	"imds-token": countedCheck("imds-token", runCheckIMDSToken),
+	"credentials": countedCheck("credentials", func(ctx context.Context) error {
+		_, err := runCheckCredentials(ctx)
+		return err
+	}),
+	"identity":   countedCheck("identity", runCheckIdentity),
+	"time":       countedCheck("time", runCheckTime),
+	"spotlight":  countedCheck("spotlight", runCheckSpotlight),
+	"disk-space": countedCheck("disk-space", runCheckDiskSpace),
+	"neighbors":  countedCheck("neighbors", runCheckNeighbors),
+}
+
+// optInChecks are the system checks that check all only runs when they're given to --include, since they fail on
+// hosts that are healthy for their workload, e.g. with a full data volume, which shouldn't get them replaced, or take
+// long enough to slow every run, like sampling the neighbor entries.
+var optInChecks = map[string]bool{
+	"disk-space": true,
+	"neighbors":  true,
+}
+
+// countedCheck returns the check, counting and tracing its runs and failures.
+func countedCheck(name string, check func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx, span := tracing.Start(ctx, "check "+name, nil)
+		err := countCheck(name, check(ctx))
+		span.Finish(err)
+
+		return err
+	}
+}
+
+// countCheck counts a run of the named check that returned err and records its result, and returns err.
+func countCheck(name string, err error) error {
+	selfmetrics.ChecksRun.Inc(name)
+	if err != nil {
+		selfmetrics.CheckFailures.Inc(name)
+	}
+	recordCheck(name, err)
+
+	return err
+}
+
 func checkCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "check",
@@ -24,6 +73,15 @@ func checkCommand() *cobra.Command {
 
 	cmd.AddCommand(
 		checkImdsCommand(),
+		checkIMDSTokenCommand(),
+		checkCredentialsCommand(),
+		checkIdentityCommand(),
+		checkTimeCommand(),
+		checkSpotlightCommand(),
+		checkDiskSpaceCommand(),
+		checkNeighborsCommand(),
+		checkAllCommand(),
+		checkHistoryCommand(),
 	)
 
 	return cmd
@@ -36,11 +94,23 @@ func checkImdsCommand() *cobra.Command {
 		Long:         "verifies connectivity to the EC2 Instance Metadata Service",
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCheckIMDS(cmd.Context())
+			err := countCheck("imds", runCheckIMDS(cmd.Context()))
+			printCheckResult(cmd, "imds", err)
+			return err
 		},
 	}
 }
 
+// printCheckResult writes the PASS or FAIL verdict of the named check to the command's output.
+func printCheckResult(cmd *cobra.Command, name string, err error) {
+	styler := contextual.Styler(cmd.Context())
+	if err != nil {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s  %s: %v\n", styler.Status(false), name, err)
+		return
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%s  %s\n", styler.Status(true), name)
+}
+
 func runCheckIMDS(ctx context.Context) error {
 	const dialerTimeout = 5 * time.Second // timeout for the dialed network connection to start
 	const imdsTokenLifetime = "941"       // arbitrary short-lived token lifetime
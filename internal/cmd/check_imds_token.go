@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/imdstoken"
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+)
+
+// imdsTokenRefreshInterval is how often the daemon checks whether its shared IMDS token needs replacing, which is also
+// how soon it requests one again after a failure.
+const imdsTokenRefreshInterval = time.Minute
+
+// checkIMDSTokenCommand creates a new command which checks that IMDSv2 session tokens can be acquired.
+func checkIMDSTokenCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "imds-token",
+		Short: "check IMDS token acquisition",
+		Long: strings.TrimSpace(`
+verifies that an IMDSv2 session token can be acquired, separately from
+reading metadata with it. When the daemon shares its token (see the
+--imds-token-socket flag), the check reports whether the daemon holds a
+token, and why its last requests failed otherwise. Without the daemon, a
+token is requested from IMDS.
+        `),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := countCheck("imds-token", runCheckIMDSToken(cmd.Context()))
+			printCheckResult(cmd, "imds-token", err)
+			return err
+		},
+	}
+}
+
+// runCheckIMDSToken checks the health of the token shared by the daemon, or requests a token when it isn't shared.
+func runCheckIMDSToken(ctx context.Context) error {
+	logrus.Info("Starting IMDS token check")
+
+	if socket := contextual.AWSOptions(ctx).IMDSTokenSocket; socket != "" {
+		health, err := imdstoken.NewClient(socket).Health(ctx)
+		if err == nil {
+			if err := health.Err(); err != nil {
+				return err
+			}
+			logrus.WithField("expires", health.Expires).Info("IMDS token check passed, the daemon holds a token")
+			return nil
+		}
+		logrus.WithError(err).Debug("IMDS token isn't shared, requesting one")
+	}
+
+	if _, err := (&imdstoken.Cache{}).Refresh(ctx); err != nil {
+		return err
+	}
+	logrus.Info("IMDS token check passed")
+
+	return nil
+}
+
+// serveIMDSToken keeps an IMDS token fresh and shares it on the socket until ctx is done, and returns the task that
+// refreshes it. Token requests are recorded as results of the imds-token check, so that failing to get a token shows
+// up on its own rather than as failed metadata reads.
+func serveIMDSToken(ctx context.Context, socketPath string) (scheduler.Task, error) {
+	if socketPath == "" {
+		return scheduler.Task{}, errors.New("no IMDS token socket, set --imds-token-socket")
+	}
+	cache := &imdstoken.Cache{}
+	l, err := imdstoken.Listen(socketPath)
+	if err != nil {
+		return scheduler.Task{}, err
+	}
+	server := &http.Server{Handler: cache.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Error("Stopped sharing IMDS token")
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	logrus.WithField("socket", socketPath).Debug("Sharing IMDS token")
+
+	return scheduler.Task{
+		Name:     "imds-token",
+		Interval: imdsTokenRefreshInterval,
+		Run: func(ctx context.Context) error {
+			requested, err := cache.Refresh(ctx)
+			if !requested {
+				return nil
+			}
+			if countCheck("imds-token", err) != nil {
+				logrus.WithError(err).Warn("Failed to acquire IMDS token")
+			} else {
+				logrus.WithField("expires", cache.Health().Expires).Debug("Acquired IMDS token")
+			}
+			return nil
+		},
+	}, nil
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/neighbors"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+const (
+	// checkNeighborsDefaultSamples is how many times each gateway's entry is read by default.
+	checkNeighborsDefaultSamples = 3
+	// checkNeighborsDefaultInterval is the default time between samples.
+	checkNeighborsDefaultInterval = time.Second
+)
+
+// checkNeighborsCommand creates a new command which checks the neighbor entries of the default gateways.
+func checkNeighborsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "neighbors",
+		Short: "check the ARP and NDP entries of the default gateways",
+		Long: strings.TrimSpace(`
+verifies that the ARP entry of the IPv4 default gateway, and the NDP
+entry of the IPv6 one if there's an IPv6 default route, resolve to a
+link-layer (MAC) address, and that the address is stable. The entries
+are read --samples times, --interval apart, after pinging the gateway
+so that they're resolved if they can be, whether or not it answers.
+
+The check fails if an entry is incomplete or missing in any sample, or
+resolves to more than one address (flaps). IMDS is reached through the
+gateway, so a stale or flapping entry makes it unreachable, which the
+imds check can't tell apart from other failures. The entries are
+printed, as a JSON object with --json. check all only runs this check
+with --include neighbors.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+	}
+
+	var (
+		opts   neighbors.Options
+		asJSON bool
+	)
+	cmd.Flags().IntVar(&opts.Samples, "samples", checkNeighborsDefaultSamples, "times to read each entry")
+	cmd.Flags().DurationVar(&opts.Interval, "interval", checkNeighborsDefaultInterval, "time between samples")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the result as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if opts.Samples <= 0 || opts.Interval < 0 {
+			return errors.New("samples must be positive and interval cannot be negative")
+		}
+
+		result, err := checkNeighbors(cmd.Context(), opts)
+		if err != nil {
+			countCheck("neighbors", err)
+			printCheckResult(cmd, "neighbors", err)
+			return err
+		}
+		checkErr := countCheck("neighbors", result.Err())
+		if asJSON {
+			if err := printJSON(cmd, result); err != nil {
+				return err
+			}
+			return checkErr
+		}
+		if err := printNeighbors(cmd, result); err != nil {
+			return err
+		}
+		printCheckResult(cmd, "neighbors", checkErr)
+
+		return checkErr
+	}
+
+	return cmd
+}
+
+// runCheckNeighbors checks the neighbor entries of the default gateways with the default samples.
+func runCheckNeighbors(ctx context.Context) error {
+	result, err := checkNeighbors(ctx, neighbors.Options{
+		Samples:  checkNeighborsDefaultSamples,
+		Interval: checkNeighborsDefaultInterval,
+	})
+	if err != nil {
+		return err
+	}
+
+	return result.Err()
+}
+
+// checkNeighbors checks the neighbor entries of the default gateways, logging the outcome.
+func checkNeighbors(ctx context.Context, opts neighbors.Options) (neighbors.Result, error) {
+	logrus.Info("Starting neighbor check")
+	result, err := neighbors.Check(ctx, opts)
+	if err != nil {
+		logrus.WithError(err).Warn("Neighbor check failed")
+		return result, err
+	}
+	if err := result.Err(); err != nil {
+		logrus.WithError(err).Warn("Neighbor check failed")
+	} else {
+		logrus.WithField("gateways", len(result.Neighbors)).Info("Neighbor check passed")
+	}
+
+	return result, nil
+}
+
+// printNeighbors prints a table of the entry of each gateway.
+func printNeighbors(cmd *cobra.Command, result neighbors.Result) error {
+	styler := contextual.Styler(cmd.Context())
+	table := output.NewTable(styler, "family", "gateway", "device", "mac", "samples", "status")
+	for _, nb := range result.Neighbors {
+		mac := strings.Join(nb.MACs, ", ")
+		if mac == "" {
+			mac = "-"
+		}
+		var resolved int
+		for _, s := range nb.Samples {
+			if s.State == neighbors.StateResolved {
+				resolved++
+			}
+		}
+		table.AddRow(nb.Family, nb.Gateway, nb.Device, mac, fmt.Sprintf("%d/%d resolved", resolved, len(nb.Samples)),
+			styler.Status(nb.OK))
+	}
+
+	return table.Render(cmd.OutOrStdout())
+}
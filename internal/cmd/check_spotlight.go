@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/spotlight"
+)
+
+// checkSpotlightCommand creates a new command which detects drift of Spotlight indexing.
+func checkSpotlightCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "spotlight",
+		Short: "check Spotlight indexing drift",
+		Long: strings.TrimSpace(`
+verifies that Spotlight indexing of every volume set with 'spotlight
+disable' or 'spotlight enable' is still in that state. The check passes
+when no state was set.
+        `),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := countCheck("spotlight", runCheckSpotlight(cmd.Context()))
+			printCheckResult(cmd, "spotlight", err)
+			return err
+		},
+	}
+}
+
+// runCheckSpotlight compares the indexing of volumes to the saved desired state.
+func runCheckSpotlight(ctx context.Context) error {
+	logrus.Info("Starting Spotlight indexing check")
+
+	state, err := spotlight.LoadState(spotlight.DefaultStatePath)
+	if err != nil {
+		return err
+	}
+	drifted, err := state.Drift(ctx)
+	if err != nil {
+		return err
+	}
+	if len(drifted) > 0 {
+		return fmt.Errorf("indexing has drifted from the desired state on %s", strings.Join(drifted, ", "))
+	}
+
+	logrus.WithField("volumes", len(state)).Info("Spotlight indexing check passed")
+	return nil
+}
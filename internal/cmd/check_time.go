@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/timesync"
+)
+
+// checkTimeDefaultMaxOffset is the largest clock offset from the time server that passes the time check by default.
+const checkTimeDefaultMaxOffset = time.Second
+
+// checkTimeCommand creates a new command which checks the system clock's drift.
+func checkTimeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "time",
+		Short: "check the system clock's drift",
+		Long: strings.TrimSpace(`
+verifies that network time is enabled and that the system clock's
+offset from the time server, the Amazon Time Sync Service by default,
+is within --max-offset. The offset is printed. Whether network time is
+enabled can only be read as root, so only the offset is checked when
+not running as root.
+        `),
+		SilenceUsage: true,
+	}
+
+	var (
+		server    string
+		maxOffset time.Duration
+	)
+	cmd.Flags().StringVar(&server, "server", timesync.AmazonTimeSyncServer, "time server to measure the offset from")
+	cmd.Flags().DurationVar(&maxOffset, "max-offset", checkTimeDefaultMaxOffset, "largest offset that passes the check")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if maxOffset <= 0 {
+			return errors.New("max offset must be positive")
+		}
+
+		offset, err := runCheckTimeWith(cmd.Context(), server, maxOffset)
+		countCheck("time", err)
+		printCheckResult(cmd, "time", err)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), offset)
+
+		return nil
+	}
+
+	return cmd
+}
+
+// runCheckTime checks the clock's drift from the Amazon Time Sync Service.
+func runCheckTime(ctx context.Context) error {
+	_, err := runCheckTimeWith(ctx, timesync.AmazonTimeSyncServer, checkTimeDefaultMaxOffset)
+	return err
+}
+
+// runCheckTimeWith checks that network time is enabled and that the clock's offset from the server is within
+// maxOffset, and returns the offset.
+func runCheckTimeWith(ctx context.Context, server string, maxOffset time.Duration) (time.Duration, error) {
+	logrus.Info("Starting time check")
+
+	// The offset is checked even when whether network time is enabled can't be, e.g. by users other than root.
+	enabled, err := timesync.NetworkTimeEnabled(ctx)
+	switch {
+	case errors.Is(err, timesync.ErrNetworkTimeUnknown):
+		logrus.WithError(err).Debug("Skipping network time setting check")
+	case err != nil:
+		return 0, err
+	case !enabled:
+		return 0, errors.New("network time is disabled")
+	}
+
+	offset, err := checkTimeOffset(ctx, server, maxOffset)
+	if err != nil {
+		return offset, err
+	}
+
+	logrus.WithField("offset", offset).Info("Time check passed")
+	return offset, nil
+}
+
+// checkTimeOffset returns the clock's offset from the server, which fails when it exceeds maxOffset.
+func checkTimeOffset(ctx context.Context, server string, maxOffset time.Duration) (time.Duration, error) {
+	offset, err := timesync.Offset(ctx, server)
+	if err != nil {
+		return 0, err
+	}
+	if offset > maxOffset || offset < -maxOffset {
+		return offset, fmt.Errorf("clock is off by %v from %s, more than %v", offset, server, maxOffset)
+	}
+
+	return offset, nil
+}
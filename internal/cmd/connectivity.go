@@ -0,0 +1,45 @@
+//go:build !minimal
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Endpoint resolves the endpoint that requests for objects in the bucket are sent to, including --endpoint-url
+// overrides.
+func s3Endpoint(ctx context.Context, cfg awssdk.Config, bucket string) (string, error) {
+	opts := s3.NewFromConfig(cfg).Options()
+	endpoint, err := opts.EndpointResolverV2.ResolveEndpoint(ctx, s3.EndpointParameters{
+		Bucket:         awssdk.String(bucket),
+		Region:         awssdk.String(opts.Region),
+		Endpoint:       opts.BaseEndpoint,
+		UseFIPS:        awssdk.Bool(opts.EndpointOptions.UseFIPSEndpoint == awssdk.FIPSEndpointStateEnabled),
+		ForcePathStyle: awssdk.Bool(opts.UsePathStyle),
+	})
+	if err != nil {
+		return "", fmt.Errorf("resolve S3 endpoint: %w", err)
+	}
+
+	return endpoint.URI.String(), nil
+}
+
+// cloudWatchLogsEndpoint resolves the endpoint of CloudWatch Logs, including --endpoint-url overrides.
+func cloudWatchLogsEndpoint(ctx context.Context, cfg awssdk.Config) (string, error) {
+	opts := cloudwatchlogs.NewFromConfig(cfg).Options()
+	endpoint, err := opts.EndpointResolverV2.ResolveEndpoint(ctx, cloudwatchlogs.EndpointParameters{
+		Region:   awssdk.String(opts.Region),
+		Endpoint: opts.BaseEndpoint,
+		UseFIPS:  awssdk.Bool(opts.EndpointOptions.UseFIPSEndpoint == awssdk.FIPSEndpointStateEnabled),
+	})
+	if err != nil {
+		return "", fmt.Errorf("resolve CloudWatch Logs endpoint: %w", err)
+	}
+
+	return endpoint.URI.String(), nil
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+)
+
+// daemonDefaultMinCaptureInterval is the default shortest time between the sysdiagnose captures of the daemon's tasks.
+const daemonDefaultMinCaptureInterval = time.Hour
+
+// daemonArgs is a struct for holding all information passed into the daemon command.
+type daemonArgs struct {
+	networkHealth      networkHealthMonitorArgs
+	scheduledEvents    scheduledEventsMonitorArgs
+	heartbeatInterval  time.Duration
+	shipLogs           bool
+	logs               logsShipArgs
+	minCaptureInterval time.Duration
+	privileges         privilegeArgs
+	archive            archivePermissionArgs
+	shareIMDSToken     bool
+}
+
+// daemonCommand creates a new command which runs the periodic tasks of the utility in a single process.
+func daemonCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "run the watchdogs, heartbeat, and log shipping in one process",
+		Long: strings.TrimSpace(`
+daemon runs the utility's periodic tasks in a single long-running process,
+instead of a launchd job for each of them:
+
+  network-health-monitor  checks network health, as "watchdog network-health-monitor" does
+  scheduled-events        checks for scheduled events, as "watchdog scheduled-events" does
+  metrics                 publishes a heartbeat, as "metrics heartbeat" does
+  logs-ship               ships logs to CloudWatch Logs, as "logs ship" does
+  imds-token              keeps an IMDS session token fresh for the utility's other invocations
+
+Each task runs at its own interval, and an interval of 0 disables it. The
+heartbeat and log shipping are disabled unless --heartbeat-interval or
+--ship-logs is set. A task that fails or panics is logged and run again at
+its next interval without affecting the others. The sysdiagnose captures
+of all tasks are limited to one every --min-capture-interval, so that
+simultaneous failures don't collect several at once.
+
+Unless --share-imds-token=false, the daemon shares an IMDSv2 session
+token on the root-only --imds-token-socket, which the utility's other
+invocations use instead of each requesting a token. Token requests are
+recorded as results of the imds-token check, so that failing to get a
+token is reported apart from failing to read metadata.
+
+With --run-as, the tasks run as the given service user once the daemon
+has started, and root privileges are only regained for privileged actions
+such as sysdiagnose collection. Only the effective user is switched and
+root stays the real user, so this keeps the tasks from acting as root by
+accident but doesn't contain a compromised daemon, which could regain
+root. It can't be combined with --ship-logs, which reads logs that only
+root can read.
+
+This command runs until interrupted and requires root privileges.
+        `),
+	}
+
+	var args daemonArgs
+	cmd.Flags().DurationVar(&args.networkHealth.interval, "network-health-interval", networkMonitorDefaultInterval, "interval between network checks, 0 to disable")
+	cmd.Flags().DurationVar(&args.networkHealth.startupDelay, "network-health-startup-delay", networkMonitorDefaultStartupDelay, "delay before starting network checks")
+	cmd.Flags().StringVar(&args.networkHealth.outputDir, "output-base-dir", networkMonitorDefaultOutputBaseDir, "base directory for the network health monitor's sysdiagnose output, can be a naming template (e.g. /var/db/diag/{instance-id})")
+	cmd.Flags().DurationVar(&args.scheduledEvents.interval, "scheduled-events-interval", scheduledEventsDefaultInterval, "interval between checks for scheduled events, 0 to disable")
+	cmd.Flags().BoolVar(&args.scheduledEvents.preCapture, "pre-capture", false, "collect a sysdiagnose when an event is scheduled")
+	cmd.Flags().StringVar(&args.scheduledEvents.outputDir, "scheduled-events-output-base-dir", scheduledEventsDefaultOutputBaseDir, "base directory for scheduled events sysdiagnose output")
+	cmd.Flags().DurationVar(&args.networkHealth.sysdiagnoseTimeout, "sysdiagnose-timeout", sysdiagnoseDefaultTimeout, "timeout for sysdiagnose collection")
+	cmd.Flags().DurationVar(&args.minCaptureInterval, "min-capture-interval", daemonDefaultMinCaptureInterval, "shortest time between the sysdiagnose captures of all tasks, 0 for no limit")
+	cmd.Flags().DurationVar(&args.heartbeatInterval, "heartbeat-interval", 0, "interval between heartbeats published to CloudWatch (default disabled)")
+	cmd.Flags().BoolVar(&args.shipLogs, "ship-logs", false, "ship the default log files to CloudWatch Logs")
+	cmd.Flags().BoolVar(&args.shareIMDSToken, "share-imds-token", true, "keep an IMDS session token fresh and share it with the utility's other invocations on --imds-token-socket")
+	cmd.Flags().StringVar(&args.logs.logGroup, "log-group", logsShipDefaultLogGroup, "destination log group of --ship-logs, created if it doesn't exist")
+	args.networkHealth.asgHealth.addFlags(cmd.Flags())
+	args.networkHealth.notify.addFlags(cmd.Flags())
+	args.scheduledEvents.upload.addFlags(cmd.Flags())
+	args.privileges.addFlags(cmd.Flags())
+	args.archive.addFlags(cmd.Flags())
+
+	cmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+
+		return args.validate()
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return runDaemon(ctx, args)
+	}
+
+	return cmd
+}
+
+// validate checks the daemon's flags, filling in those shared by its tasks.
+func (a *daemonArgs) validate() error {
+	if a.networkHealth.interval < 0 || a.scheduledEvents.interval < 0 || a.heartbeatInterval < 0 {
+		return errors.New("intervals cannot be negative")
+	}
+	if a.networkHealth.startupDelay < 0 {
+		return errors.New("startup delay cannot be negative")
+	}
+	if a.minCaptureInterval < 0 {
+		return errors.New("minimum capture interval cannot be negative")
+	}
+	if a.networkHealth.sysdiagnoseTimeout < sysdiagnoseMinTimeout {
+		return fmt.Errorf("timeout must be at least %v to ensure creation can complete", sysdiagnoseMinTimeout)
+	}
+	if a.shipLogs && a.privileges.user != "" {
+		return errors.New("--ship-logs can't be combined with --run-as")
+	}
+	if a.networkHealth.interval == 0 && a.scheduledEvents.interval == 0 && a.heartbeatInterval == 0 && !a.shipLogs && !a.shareIMDSToken {
+		return errors.New("every task is disabled")
+	}
+
+	a.scheduledEvents.sysdiagnoseTimeout = a.networkHealth.sysdiagnoseTimeout
+	a.networkHealth.upload = a.scheduledEvents.upload
+	a.logs.files = logsShipDefaultFiles
+	a.logs.flushInterval = logsShipDefaultFlushInterval
+	a.logs.stateDir = logsShipDefaultStateDir
+	perms, err := a.archive.resolve()
+	if err != nil {
+		return err
+	}
+	a.networkHealth.perms, a.scheduledEvents.perms = perms, perms
+	if err := a.networkHealth.notify.validate(); err != nil {
+		return err
+	}
+
+	return a.scheduledEvents.upload.validate()
+}
+
+// runDaemon sets up the enabled tasks as root, drops privileges when requested, and runs the tasks until ctx is done.
+func runDaemon(ctx context.Context, args daemonArgs) error {
+	captureLimiter := scheduler.NewLimiter(args.minCaptureInterval, 1)
+
+	var tasks []scheduler.Task
+	// The token is shared, and requested, first so that the other tasks can use it as they're set up.
+	if args.shareIMDSToken {
+		task, err := serveIMDSToken(ctx, contextual.AWSOptions(ctx).IMDSTokenSocket)
+		if err != nil {
+			return err
+		}
+		_ = task.Run(ctx)
+		tasks = append(tasks, task)
+	}
+	if args.networkHealth.interval > 0 {
+		args.networkHealth.captureLimiter = captureLimiter
+		done, err := prepareNetworkHealthMonitor(ctx, &args.networkHealth)
+		if err != nil {
+			return err
+		}
+		if !done {
+			tasks = append(tasks, networkHealthTask(args.networkHealth))
+		}
+	}
+	if args.scheduledEvents.interval > 0 {
+		cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+		if err != nil {
+			return err
+		}
+		args.scheduledEvents.captureLimiter = captureLimiter
+		tasks = append(tasks, scheduledEventsTask(args.scheduledEvents, imds.NewFromConfig(cfg)))
+	}
+	if args.heartbeatInterval > 0 {
+		task, err := heartbeatTask(ctx, metricsDefaultNamespace, args.heartbeatInterval)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, task)
+	}
+	if args.shipLogs {
+		task, err := logsShipTask(args.logs)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		logrus.Info("No tasks left to run, stopping daemon")
+		return nil
+	}
+
+	if err := args.privileges.drop(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		names = append(names, t.Name)
+	}
+	logrus.WithField("tasks", strings.Join(names, ", ")).Info("Starting daemon")
+
+	err := scheduler.Run(ctx, tasks...)
+	if ctx.Err() == nil {
+		return err
+	}
+	logrus.Info("Stopped daemon")
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemonArgsValidate(t *testing.T) {
+	valid := func() daemonArgs {
+		var a daemonArgs
+		a.networkHealth.interval = networkMonitorDefaultInterval
+		a.networkHealth.sysdiagnoseTimeout = sysdiagnoseDefaultTimeout
+		a.scheduledEvents.interval = scheduledEventsDefaultInterval
+		return a
+	}
+
+	a := valid()
+	if assert.NoError(t, a.validate()) {
+		assert.Equal(t, sysdiagnoseDefaultTimeout, a.scheduledEvents.sysdiagnoseTimeout, "the tasks should share the sysdiagnose timeout")
+		assert.Equal(t, logsShipDefaultFiles, a.logs.files)
+	}
+
+	for name, modify := range map[string]func(a *daemonArgs){
+		"negative interval":   func(a *daemonArgs) { a.heartbeatInterval = -time.Minute },
+		"short timeout":       func(a *daemonArgs) { a.networkHealth.sysdiagnoseTimeout = time.Second },
+		"every task disabled": func(a *daemonArgs) { a.networkHealth.interval, a.scheduledEvents.interval = 0, 0 },
+		"ship logs as user": func(a *daemonArgs) {
+			a.shipLogs = true
+			a.privileges.user = "_ec2macosutils"
+		},
+	} {
+		a := valid()
+		modify(&a)
+		assert.Error(t, a.validate(), name)
+	}
+}
@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -14,7 +18,14 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
-	"github.com/aws/ec2-macos-utils/internal/sysdiagnose"
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/naming"
+	"github.com/aws/ec2-macos-utils/internal/progress"
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+	"github.com/aws/ec2-macos-utils/internal/selfmetrics"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
+	"github.com/aws/ec2-macos-utils/pkg/sysdiagnose"
 )
 
 const (
@@ -24,11 +35,32 @@ const (
 
 	// Timestamp format
 	sysdiagnoseTimestampFormat = "20060102_150405" // YYYYMMDD_HHMMSS
+
+	// archiveCopyBufferSize is the size of the buffer archives are copied with, which is larger than io.Copy's default
+	// to make fewer system calls for archives of hundreds of megabytes.
+	archiveCopyBufferSize = 1024 * 1024
 )
 
 type sysdiagnoseArgs struct {
 	outputDir string
 	timeout   time.Duration
+	printPath bool
+	resume    bool
+	upload    uploadArgs
+	// reason is why the sysdiagnose is collected, and trigger what triggered the collection, e.g. the watchdog. They're
+	// recorded in the manifest and the metadata of the uploaded objects.
+	reason  string
+	trigger map[string]string
+	// attachments are the file names of what was saved in outputDir for the same collection, which are recorded in the
+	// manifest.
+	attachments []string
+	// perms are the ownership and mode of the archive, its manifest, and the output directory.
+	perms archivePermissions
+	// stream, when set, is given the archive as it's written to outputPath, e.g. to upload it at the same time
+	// rather than reading it again afterwards. The archive is saved even when stream fails.
+	stream func(ctx context.Context, outputPath string, r io.Reader) error
+	// limiter, when set, limits how often a sysdiagnose is collected, e.g. by the tasks of a daemon together.
+	limiter *scheduler.Limiter
 }
 
 func debugCommand() *cobra.Command {
@@ -38,7 +70,14 @@ func debugCommand() *cobra.Command {
 		Long:  "utilities and tools for debugging EC2 macOS instances",
 	}
 
-	cmd.AddCommand(createSysdiagnoseCommand())
+	cmd.AddCommand(
+		createSysdiagnoseCommand(),
+		serialConsoleCommand(),
+		exportLogsCommand(),
+		purgeMemoryCommand(),
+		imdsLatencyCommand(),
+		mtuProbeCommand(),
+	)
 
 	return cmd
 }
@@ -52,13 +91,76 @@ creates a sysdiagnose archive including logs, system stats,
 and other debug data. The resulting archive will be saved in the specified
 output directory.
 
+With --upload, the archive and its manifest are also uploaded to S3.
+Large archives are uploaded in parts, and an interrupted upload resumes
+from the parts that were already uploaded when the command is rerun.
+Unless --upload-max-rate limits the upload, the archive is uploaded
+while it's written instead of being read again afterwards, and it's
+uploaded after collection if that fails.
+The instance role must allow s3:PutObject (and s3:PutObjectTagging
+with --upload-tag). Before collection, the bucket's S3 endpoint
+(including --endpoint-url overrides such as VPC endpoints) is checked
+for connectivity, and the command fails with the category of the
+failure (dns, refused, timeout, tls, or network) if it's unreachable.
+
+The output directory and the uploaded object keys (--upload-key) can be
+naming templates with placeholders filled from the instance identity
+and the time of collection: {instance-id}, {az}, {region}, {account-id},
+{instance-type}, {image-id}, {hostname}, {run-id}, {timestamp}, {date},
+and, for keys, {filename}. For example,
+--upload-key '{instance-id}/{az}/{timestamp}-sysdiagnose.tar.gz'.
+The manifest is uploaded next to the archive. Besides the archive's
+checksum, it records the utility's own counters at the time, such as
+the checks run and the external commands executed, to help diagnose
+the utility itself.
+
+The collection and the upload are recorded in a journal as they
+complete. When a run is interrupted, e.g. by a crash or the timeout
+while uploading, --resume continues it with the archive it collected
+instead of collecting another, and uploads it under the same key.
+
+--reason records why the archive is collected, and --trigger what
+triggered the collection as key=value pairs, e.g. --trigger alarm=cpu,
+in the manifest and as the metadata of the uploaded archive and
+manifest (x-amz-meta-reason and x-amz-meta-trigger-<key>), so that the
+archives in a bucket can be triaged by cause without opening them. The
+watchdogs record their own reason and trigger. Uploaded objects also
+carry the run ID of the invocation (x-amz-meta-run-id), which its logs
+and the manifest record too.
+
+The archive and its manifest are only readable by root, and the output
+directory is created only accessible by root. --archive-owner,
+--archive-group, --archive-mode, and --archive-dir-mode change that,
+e.g. so that an agent running as a diagnostics group can fetch archives
+without root:
+
+  sudo ec2-macos-utils debug create-sysdiagnose --output-dir /var/db/diag \
+    --archive-group diagnostics --archive-mode 0440 --archive-dir-mode 0750
+
+Only the owner, group, and mode are set; ACLs aren't. The directories
+are only changed if the command creates them, so an existing output
+directory keeps its permissions.
+
+Logs are written to stderr. With --print-path, the location of the
+archive is the only output written to stdout: its s3:// URI when it's
+uploaded with --upload, or otherwise the path it was saved to.
+
 This command requires root privileges. Run with sudo if not running as root.
         `),
 	}
 
-	var args sysdiagnoseArgs
-	cmd.Flags().StringVar(&args.outputDir, "output-dir", os.TempDir(), "directory where the sysdiagnose archive will be saved")
+	var (
+		args  sysdiagnoseArgs
+		perms archivePermissionArgs
+	)
+	cmd.Flags().StringVar(&args.outputDir, "output-dir", os.TempDir(), "directory where the sysdiagnose archive will be saved, can be a naming template")
 	cmd.Flags().DurationVar(&args.timeout, "timeout", sysdiagnoseDefaultTimeout, "set the timeout for creation (e.g. 10m, 30m, 1.5h)")
+	cmd.Flags().BoolVar(&args.printPath, "print-path", false, "print only the location of the archive on stdout, its S3 URI with --upload or else its path")
+	cmd.Flags().StringVar(&args.reason, "reason", "", "why the sysdiagnose is collected, recorded in the manifest and object metadata")
+	cmd.Flags().StringToStringVar(&args.trigger, "trigger", nil, "what triggered the collection as key=value, recorded in the manifest and object metadata, can be repeated")
+	perms.addFlags(cmd.Flags())
+	addResumeFlag(cmd.Flags(), &args.resume)
+	args.upload.addFlags(cmd.Flags())
 
 	cmd.RunE = func(cmd *cobra.Command, cmdArgs []string) error {
 		if os.Geteuid() != 0 {
@@ -70,29 +172,144 @@ This command requires root privileges. Run with sudo if not running as root.
 		if args.timeout < sysdiagnoseMinTimeout {
 			return fmt.Errorf("timeout must be at least %v to ensure creation can complete", sysdiagnoseMinTimeout)
 		}
+		var err error
+		if args.perms, err = perms.resolve(); err != nil {
+			return err
+		}
 
-		timeoutCtx, cancel := context.WithTimeout(ctx, args.timeout)
-		defer cancel()
-		ctx = timeoutCtx
+		if err := args.validateTrigger(); err != nil {
+			return err
+		}
+		args.upload.metadata = args.metadata()
+		if err := args.upload.validate(); err != nil {
+			return err
+		}
+		if _, err := naming.Expand(args.outputDir, placeholderVars()); err != nil {
+			return err
+		}
+		if err := args.upload.preflight(ctx); err != nil {
+			return err
+		}
 
-		logrus.WithField("args", args).Debug("Running sysdiagnose")
-		if err := runSysdiagnose(ctx, args); err != nil {
-			if ctx.Err() == context.DeadlineExceeded {
-				return errors.New("creation timeout exceeded")
-			}
+		// Runs with the same output directory template resume each other.
+		journal, err := beginJournal(cmd, args.outputDir, args.resume)
+		if err != nil {
 			return err
 		}
 
+		outputPath, archiveKey := journal.Value("archive"), journal.Value("archive_key")
+		if _, statErr := os.Stat(outputPath); journal.Completed("collect") && statErr == nil {
+			logrus.WithField("output_path", outputPath).Info("Resuming with the sysdiagnose collected by the interrupted run")
+			// The interrupted run may not have been uploading.
+			if archiveKey == "" && args.upload.enabled() {
+				vars, err := namingVars(ctx, time.Now(), args.upload.key)
+				if err != nil {
+					return fmt.Errorf("cannot resolve naming template placeholders: %w", err)
+				}
+				if archiveKey, err = args.upload.objectKey(vars, outputPath); err != nil {
+					return err
+				}
+			}
+		} else {
+			var streamed bool
+			if outputPath, archiveKey, streamed, err = collectSysdiagnose(ctx, args); err != nil {
+				return err
+			}
+			journal.Set("archive", outputPath)
+			journal.Set("archive_key", archiveKey)
+			journal.Complete("collect")
+			// The archive was uploaded while it was written, which leaves its manifest.
+			if streamed {
+				if err := args.upload.upload(cmd.Context(), sysdiagnoseSidecars(outputPath, archiveKey)...); err != nil {
+					return err
+				}
+				journal.Complete("upload")
+			}
+		}
+
+		// The upload isn't bound by the creation timeout since large archives can take a while on slow links.
+		if !journal.Completed("upload") {
+			if err := uploadSysdiagnoseAs(cmd.Context(), args.upload, archiveKey, outputPath); err != nil {
+				return err
+			}
+			journal.Complete("upload")
+		}
+		journal.Finish()
+
+		if args.printPath {
+			location, err := sysdiagnoseLocation(args.upload, outputPath, archiveKey)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), location)
+		}
+
 		return nil
 	}
 
 	return cmd
 }
 
-func runSysdiagnose(ctx context.Context, args sysdiagnoseArgs) error {
-	// Create output directory with owner-only permissions (rwx------) since it will contain sensitive diagnostic data
-	if err := os.MkdirAll(args.outputDir, 0700); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+// collectSysdiagnose expands the naming templates of the output directory and upload key, and collects a sysdiagnose
+// archive within the timeout. It returns the archive's path and, with uploads enabled, its object key. Unless the
+// upload rate is limited, which would slow down writing the archive, the archive is uploaded while it's written, and
+// streamed reports whether that succeeded.
+func collectSysdiagnose(ctx context.Context, args sysdiagnoseArgs) (outputPath, archiveKey string, streamed bool, err error) {
+	vars, err := namingVars(ctx, time.Now(), args.outputDir, args.upload.key)
+	if err != nil {
+		return "", "", false, fmt.Errorf("cannot resolve naming template placeholders: %w", err)
+	}
+	if args.outputDir, err = naming.Expand(args.outputDir, vars); err != nil {
+		return "", "", false, err
+	}
+	if args.upload.enabled() && args.upload.maxRate == "" {
+		args.stream = func(ctx context.Context, outputPath string, r io.Reader) error {
+			key, err := args.upload.objectKey(vars, outputPath)
+			if err == nil {
+				err = args.upload.uploadStream(ctx, r, key)
+			}
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to upload sysdiagnose while writing it, uploading it after collection")
+				return err
+			}
+			streamed = true
+			return nil
+		}
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, args.timeout)
+	defer cancel()
+
+	logrus.WithField("args", args).Debug("Running sysdiagnose")
+	outputPath, err = runSysdiagnose(timeoutCtx, args)
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			return "", "", false, errors.New("creation timeout exceeded")
+		}
+		return "", "", false, err
+	}
+	if args.upload.enabled() {
+		if archiveKey, err = args.upload.objectKey(vars, outputPath); err != nil {
+			return "", "", false, err
+		}
+	}
+
+	return outputPath, archiveKey, streamed, nil
+}
+
+// runSysdiagnose collects a sysdiagnose archive into the output directory and returns the path it was saved to.
+func runSysdiagnose(ctx context.Context, args sysdiagnoseArgs) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "sysdiagnose", nil)
+	defer func() { span.Finish(err) }()
+
+	if !args.limiter.Allow() {
+		return "", errors.New("a sysdiagnose was collected too recently, try again later")
+	}
+
+	// Create output directory with owner-only permissions (rwx------) by default since it will contain sensitive
+	// diagnostic data
+	if err := args.perms.mkdir(args.outputDir); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	timestamp := time.Now().UTC().Format(sysdiagnoseTimestampFormat)
@@ -105,24 +322,63 @@ func runSysdiagnose(ctx context.Context, args sysdiagnoseArgs) error {
 
 	outputReader, err := sysdiagnose.Collect(ctx, archiveName)
 	if err != nil {
-		return fmt.Errorf("failed to create sysdiagnose: %w", err)
+		return "", fmt.Errorf("failed to create sysdiagnose: %w", err)
 	}
 	defer func() { _ = outputReader.Close() }()
 
-	// Create output file with read-only permissions (r--------) since diagnostic data should not be modified
-	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	// Create output file with read-only permissions (r-------- by default) since diagnostic data should not be modified
+	output, err := args.perms.create(outputPath)
 	if err != nil {
-		return fmt.Errorf("failed to create output file %s: %w", outputPath, err)
+		return "", fmt.Errorf("failed to create output file %s: %w", outputPath, err)
 	}
 	defer func() { _ = output.Close() }()
 
-	written, err := io.Copy(output, outputReader)
+	var total int64
+	if f, ok := outputReader.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil {
+			total = fi.Size()
+		}
+	}
+	reporter := contextual.Progress(ctx)
+	counter := progress.NewWriter(reporter, "write", total, 5)
+
+	hash := sha256.New()
+	written, err := copyArchive(ctx, io.MultiWriter(output, counter, hash), outputReader, outputPath, args.stream)
 	if err != nil {
 		// Ignore error from Remove() since:
 		// 1. We're already in an error state from io.Copy
 		// 2. If Remove() fails, the incomplete/corrupt file remaining is not critical
 		_ = os.Remove(outputPath)
-		return fmt.Errorf("failed to write sysdiagnose data: %w", err)
+		return "", fmt.Errorf("failed to write sysdiagnose data: %w", err)
+	}
+	if err := args.perms.apply(output); err != nil {
+		_ = os.Remove(outputPath)
+		return "", fmt.Errorf("failed to set the permissions of %s: %w", outputPath, err)
+	}
+
+	reporter.Report("write", 100, outputPath)
+	selfmetrics.SysdiagnosesCollected.Inc("")
+
+	manifestPath := sysdiagnose.ManifestPath(outputPath)
+	manifest := sysdiagnose.Manifest{
+		RunID:       contextual.RunID(ctx),
+		Archive:     filepath.Base(outputPath),
+		CreatedAt:   time.Now().UTC(),
+		Bytes:       written,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		ToolVersion: build.Version,
+		Reason:      args.reason,
+		Trigger:     args.trigger,
+		Counters:    selfmetrics.Snapshot(),
+		Attachments: args.attachments,
+	}
+	if err := args.perms.writeFile(manifestPath, func(w io.Writer) error {
+		return sysdiagnose.EncodeManifest(w, manifest)
+	}); err != nil {
+		// The archive is intact without its manifest, so don't fail the collection.
+		logrus.WithError(err).Warn("Failed to write sysdiagnose manifest")
+	} else {
+		logrus.WithField("manifest_path", manifestPath).Debug("Wrote sysdiagnose manifest")
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -130,5 +386,149 @@ func runSysdiagnose(ctx context.Context, args sysdiagnoseArgs) error {
 		"bytes":       written,
 	}).Infof("Sysdiagnose creation completed (%s)", units.HumanSize(float64(written)))
 
+	return outputPath, nil
+}
+
+// sysdiagnoseTriggerKey matches the keys of the trigger, which become part of object metadata names.
+var sysdiagnoseTriggerKey = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// validateTrigger checks that the keys of the trigger can be used in object metadata names.
+func (a sysdiagnoseArgs) validateTrigger() error {
+	for key := range a.trigger {
+		if !sysdiagnoseTriggerKey.MatchString(key) {
+			return fmt.Errorf("invalid trigger key %q, use lowercase letters, digits, hyphens, and underscores", key)
+		}
+	}
+
 	return nil
 }
+
+// metadata returns the metadata of the uploaded archive and manifest, which records why the archive was collected.
+func (a sysdiagnoseArgs) metadata() map[string]string {
+	if a.reason == "" && len(a.trigger) == 0 {
+		return nil
+	}
+	metadata := map[string]string{}
+	if a.reason != "" {
+		metadata["reason"] = a.reason
+	}
+	for key, value := range a.trigger {
+		metadata["trigger-"+key] = value
+	}
+
+	return metadata
+}
+
+// copyArchive copies the archive from r to w, and to stream as well when it's set, in a single pass so that the
+// archive is only read once. A stream that fails is detached from the copy without failing it.
+func copyArchive(ctx context.Context, w io.Writer, r io.Reader, outputPath string, stream func(context.Context, string, io.Reader) error) (int64, error) {
+	buf := make([]byte, archiveCopyBufferSize)
+	// r is wrapped so that io.CopyBuffer uses buf rather than r's own io.WriterTo, which copies in small chunks to
+	// writers other than sockets.
+	r = struct{ io.Reader }{r}
+	if stream == nil {
+		return io.CopyBuffer(w, r, buf)
+	}
+
+	pr, pw := io.Pipe()
+	streamed := make(chan error, 1)
+	go func() {
+		err := stream(ctx, outputPath, pr)
+		// A stream that returned stops reading, so the copy must stop writing to it.
+		_ = pr.CloseWithError(errStreamClosed)
+		streamed <- err
+	}()
+
+	written, err := io.CopyBuffer(io.MultiWriter(w, &detachableWriter{w: pw}), r, buf)
+	_ = pw.CloseWithError(err)
+	// Failures of the stream are handled by it and don't fail the copy.
+	<-streamed
+
+	return written, err
+}
+
+// errStreamClosed is the error of writes to a stream that stopped reading.
+var errStreamClosed = errors.New("stream closed")
+
+// detachableWriter writes to w until a write fails, after which it discards what's written to it, so that a
+// failing destination of an io.MultiWriter doesn't fail the writes to the others.
+type detachableWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (d *detachableWriter) Write(p []byte) (int, error) {
+	if d.err == nil {
+		_, d.err = d.w.Write(p)
+	}
+
+	return len(p), nil
+}
+
+// uploadSysdiagnose uploads the archive at outputPath and its manifest when uploads are enabled. The manifest's key
+// is derived from the archive's so that they stay side by side whatever the key template.
+func uploadSysdiagnose(ctx context.Context, args uploadArgs, vars naming.Vars, outputPath string) error {
+	if !args.enabled() {
+		return nil
+	}
+	archiveKey, err := args.objectKey(vars, outputPath)
+	if err != nil {
+		return err
+	}
+
+	return uploadSysdiagnoseAs(ctx, args, archiveKey, outputPath)
+}
+
+// sysdiagnoseLocation returns where the archive at outputPath ended up: the s3:// URI of archiveKey when uploads are
+// enabled, otherwise outputPath.
+func sysdiagnoseLocation(args uploadArgs, outputPath, archiveKey string) (string, error) {
+	if !args.enabled() {
+		return outputPath, nil
+	}
+
+	return args.objectURI(archiveKey)
+}
+
+// uploadSysdiagnoseAs uploads the archive at outputPath under archiveKey, and its manifest and attachments next to it,
+// when uploads are enabled.
+func uploadSysdiagnoseAs(ctx context.Context, args uploadArgs, archiveKey, outputPath string) error {
+	if !args.enabled() {
+		return nil
+	}
+
+	return args.upload(ctx, append([]uploadObject{{path: outputPath, key: archiveKey}},
+		sysdiagnoseSidecars(outputPath, archiveKey)...)...)
+}
+
+// sysdiagnoseSidecars returns what's uploaded next to the archive at outputPath: its manifest, and the attachments the
+// manifest lists, such as the network snapshot taken before the archive was collected.
+func sysdiagnoseSidecars(outputPath, archiveKey string) []uploadObject {
+	objects := []uploadObject{{path: sysdiagnose.ManifestPath(outputPath), key: sysdiagnose.ManifestPath(archiveKey)}}
+	// A missing manifest fails its own upload.
+	manifest, err := sysdiagnose.ReadManifest(outputPath)
+	if err != nil {
+		return objects
+	}
+	for _, name := range manifest.Attachments {
+		// Attachments are saved in the archive's directory.
+		if name != filepath.Base(name) {
+			continue
+		}
+		objects = append(objects, uploadObject{
+			path: filepath.Join(filepath.Dir(outputPath), name),
+			key:  path.Join(path.Dir(archiveKey), name),
+		})
+	}
+
+	return objects
+}
+
+// sysdiagnoseCaptures lists the sysdiagnose archives previously saved in dir.
+func sysdiagnoseCaptures(dir string) ([]string, error) {
+	existing, err := filepath.Glob(filepath.Join(dir, "sysdiagnose_*.tar.gz"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern: %w", err)
+	}
+
+	return existing, nil
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/ec2-macos-utils/pkg/sysdiagnose"
+)
+
+func TestCopyArchive(t *testing.T) {
+	data := bytes.Repeat([]byte("sysdiagnose"), archiveCopyBufferSize/4)
+
+	var out, streamed bytes.Buffer
+	written, err := copyArchive(context.Background(), &out, bytes.NewReader(data), "/tmp/sysdiagnose_1.tar.gz",
+		func(_ context.Context, outputPath string, r io.Reader) error {
+			assert.Equal(t, "/tmp/sysdiagnose_1.tar.gz", outputPath)
+			_, err := io.Copy(&streamed, r)
+			return err
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), written)
+	assert.Equal(t, data, out.Bytes())
+	assert.Equal(t, data, streamed.Bytes(), "the archive should be streamed as it's written")
+
+	out.Reset()
+	written, err = copyArchive(context.Background(), &out, bytes.NewReader(data), "",
+		func(_ context.Context, _ string, r io.Reader) error {
+			_, _ = r.Read(make([]byte, 10))
+			return errors.New("upload failed")
+		})
+	assert.NoError(t, err, "a failed stream shouldn't fail the copy")
+	assert.Equal(t, int64(len(data)), written)
+	assert.Equal(t, data, out.Bytes())
+}
+
+func TestSysdiagnoseArgs_Metadata(t *testing.T) {
+	assert.Nil(t, sysdiagnoseArgs{}.metadata())
+
+	args := sysdiagnoseArgs{
+		reason:  "scheduled event system-reboot",
+		trigger: map[string]string{"watchdog": "scheduled-events", "event-id": "instance-event-1"},
+	}
+	assert.NoError(t, args.validateTrigger())
+	assert.Equal(t, map[string]string{
+		"reason":           "scheduled event system-reboot",
+		"trigger-watchdog": "scheduled-events",
+		"trigger-event-id": "instance-event-1",
+	}, args.metadata())
+
+	args.trigger = map[string]string{"Event ID": "instance-event-1"}
+	assert.Error(t, args.validateTrigger(), "keys that aren't valid in metadata names should be rejected")
+}
+
+func TestSysdiagnoseSidecars(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "sysdiagnose_20261016_120000.tar.gz")
+	manifest := uploadObject{
+		path: filepath.Join(dir, "sysdiagnose_20261016_120000.manifest.json"),
+		key:  "host/sysdiagnose_20261016_120000.manifest.json",
+	}
+	assert.Equal(t, []uploadObject{manifest}, sysdiagnoseSidecars(archive, "host/sysdiagnose_20261016_120000.tar.gz"))
+
+	_, err := sysdiagnose.WriteManifest(archive, sysdiagnose.Manifest{
+		Attachments: []string{"network-snapshot_20261016_115959.json", "../elsewhere.json"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uploadObject{
+		manifest,
+		{path: filepath.Join(dir, "network-snapshot_20261016_115959.json"), key: "host/network-snapshot_20261016_115959.json"},
+	}, sysdiagnoseSidecars(archive, "host/sysdiagnose_20261016_120000.tar.gz"))
+}
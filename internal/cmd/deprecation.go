@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/build"
+)
+
+// deprecation describes a command that has been replaced and when it will stop working.
+type deprecation struct {
+	// replacement is what users should migrate to (e.g. "ec2-macos-utils check imds").
+	replacement string
+	// sunset is the release in which the deprecated name stops working.
+	sunset string
+}
+
+// notice builds the migration message for the deprecated name.
+func (d deprecation) notice(name string) string {
+	return fmt.Sprintf("%s is deprecated and will be removed in %s, use %s instead", name, d.sunset, d.replacement)
+}
+
+// expired reports whether the running version has reached the sunset release. Development builds without a
+// parseable version are never expired so that they keep the compatibility behavior.
+func (d deprecation) expired(current string) bool {
+	cur, err := semver.NewVersion(current)
+	if err != nil {
+		return false
+	}
+	sunset, err := semver.NewVersion(d.sunset)
+	if err != nil {
+		return false
+	}
+
+	return !cur.LessThan(sunset)
+}
+
+// check warns about the deprecated name, or fails once its sunset release has been reached.
+func (d deprecation) check(name string) error {
+	if d.expired(build.Version) {
+		return fmt.Errorf("%s was removed in %s, use %s instead", name, d.sunset, d.replacement)
+	}
+	logrus.Warn(d.notice(name))
+
+	return nil
+}
+
+// deprecatedAlias creates a hidden command named use that runs the command built by newCmd after warning about
+// the deprecation. This keeps scripts working after a command moves in the command tree.
+func deprecatedAlias(use string, newCmd func() *cobra.Command, dep deprecation) *cobra.Command {
+	cmd := newCmd()
+	cmd.Use = strings.Replace(cmd.Use, strings.Fields(cmd.Use)[0], use, 1)
+	cmd.Aliases = nil
+	cmd.Hidden = true
+	cmd.Short = "deprecated: " + cmd.Short
+
+	preRunE, preRun := cmd.PreRunE, cmd.PreRun
+	cmd.PreRun = nil
+	cmd.PreRunE = func(c *cobra.Command, args []string) error {
+		if err := dep.check(fmt.Sprintf("%q", c.CommandPath())); err != nil {
+			return err
+		}
+		if preRunE != nil {
+			return preRunE(c, args)
+		}
+		if preRun != nil {
+			preRun(c, args)
+		}
+
+		return nil
+	}
+
+	return cmd
+}
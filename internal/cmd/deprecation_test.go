@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/build"
+)
+
+func withVersion(t *testing.T, version string) {
+	prev := build.Version
+	build.Version = version
+	t.Cleanup(func() { build.Version = prev })
+}
+
+func TestDeprecation_Expired(t *testing.T) {
+	dep := deprecation{replacement: "new", sunset: "2.0.0"}
+
+	assert.False(t, dep.expired("1.9.9"))
+	assert.True(t, dep.expired("2.0.0"))
+	assert.True(t, dep.expired("v2.1.0"))
+	assert.False(t, dep.expired(""), "should not expire development builds")
+	assert.False(t, deprecation{sunset: "later"}.expired("9.9.9"), "should not expire without a valid sunset")
+}
+
+func testTree(child *cobra.Command) *cobra.Command {
+	root := &cobra.Command{Use: "root", SilenceUsage: true, SilenceErrors: true}
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+	root.AddCommand(child)
+	return root
+}
+
+func TestDeprecatedAlias(t *testing.T) {
+	ran := 0
+	newCmd := func() *cobra.Command {
+		return &cobra.Command{
+			Use:   "imds [flags]",
+			Short: "check IMDS",
+			RunE:  func(*cobra.Command, []string) error { ran++; return nil },
+		}
+	}
+	dep := deprecation{replacement: "root check imds", sunset: "2.0.0"}
+
+	withVersion(t, "1.0.0")
+	alias := deprecatedAlias("check-imds", newCmd, dep)
+	assert.Equal(t, "check-imds [flags]", alias.Use)
+	assert.True(t, alias.Hidden)
+
+	root := testTree(alias)
+	root.SetArgs([]string{"check-imds"})
+	assert.NoError(t, root.Execute(), "should run alias before sunset")
+	assert.Equal(t, 1, ran)
+
+	withVersion(t, "2.0.0")
+	root = testTree(deprecatedAlias("check-imds", newCmd, dep))
+	root.SetArgs([]string{"check-imds"})
+	assert.Error(t, root.Execute(), "should reject alias after sunset")
+	assert.Equal(t, 1, ran)
+}
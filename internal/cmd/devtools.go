@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/devtools"
+)
+
+// devtoolsDefaultTimeout is the default timeout of installing the Command Line Tools.
+const devtoolsDefaultTimeout = time.Hour
+
+// devtoolsCommand creates a new command which groups developer tools utilities.
+func devtoolsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "devtools",
+		Short: "developer tools utilities",
+		Long:  "utilities for installing developer tools on EC2 macOS instances",
+	}
+
+	cmd.AddCommand(
+		devtoolsBootstrapCommand(),
+		devtoolsInstallCLTCommand(),
+	)
+
+	return cmd
+}
+
+// devtoolsInstallCLTCommand creates a new command which installs the Xcode Command Line Tools.
+func devtoolsInstallCLTCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install-clt",
+		Short: "install the Xcode Command Line Tools",
+		Long: strings.TrimSpace(`
+install-clt installs the latest Xcode Command Line Tools headlessly,
+without the dialog that xcode-select --install opens, and verifies that
+they're installed in ` + devtools.CLTPath + `.
+
+softwareupdate is made to offer the Command Line Tools by creating the
+trigger file the interactive installer uses, and the latest non-beta
+version it lists is installed. Nothing is done when the Command Line
+Tools are already installed.
+
+With --progress-json, the progress of the download and installation is
+reported on stderr.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var timeout time.Duration
+	cmd.Flags().DurationVar(&timeout, "timeout", devtoolsDefaultTimeout, "timeout of the installation")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		if version, ok := devtools.CLTVersion(cmd.Context()); ok {
+			logrus.WithField("version", version).Info("Command Line Tools are already installed")
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+		defer cancel()
+
+		update, err := devtools.InstallCLT(ctx)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("installation timeout of %v exceeded", timeout)
+			}
+			return err
+		}
+		version, _ := devtools.CLTVersion(ctx)
+		logrus.WithFields(logrus.Fields{
+			"label":   update.Label,
+			"version": version,
+		}).Info("Installed Command Line Tools")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// devtoolsBootstrapCommand creates a new command which puts a declarative developer tools setup in place.
+func devtoolsBootstrapCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bootstrap",
+		Short: "bootstrap developer tools declaratively",
+		Long: strings.TrimSpace(`
+bootstrap puts the selected developer tools in place: the Xcode Command
+Line Tools (--clt), Rosetta (--rosetta, accepting its license), the
+license of the selected Xcode (--accept-xcode-license, which also
+installs the packages Xcode needs at first launch), and simulator
+runtimes (--simulator-runtime, e.g. "iOS 17.5", repeatable).
+
+Every step is idempotent: what's already in place is left alone, so
+bootstrapping again only changes what's missing. All steps run even
+when one fails, and a JSON report of each step's status (unchanged,
+changed, skipped, or failed) is printed on stdout.
+
+The steps are usually declared as a profile in the configuration file
+and selected with --profile, e.g.
+
+  {
+    "profiles": {
+      "devtools bootstrap": {
+        "ios-ci": {
+          "clt": true,
+          "rosetta": true,
+          "accept-xcode-license": true,
+          "simulator-runtime": ["iOS 17.5", "watchOS 10.5"]
+        }
+      }
+    }
+  }
+
+Flags given on the command line take precedence over the profile.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var (
+		bootstrap devtools.Bootstrap
+		runtimes  []string
+		timeout   time.Duration
+	)
+	addConfigProfileFlag(cmd)
+	cmd.Flags().BoolVar(&bootstrap.CLT, "clt", false, "install the Command Line Tools")
+	cmd.Flags().BoolVar(&bootstrap.Rosetta, "rosetta", false, "install Rosetta on Apple silicon, accepting its license")
+	cmd.Flags().BoolVar(&bootstrap.AcceptXcodeLicense, "accept-xcode-license", false, "accept the license of the selected Xcode")
+	cmd.Flags().StringArrayVar(&runtimes, "simulator-runtime", nil, "simulator runtime to install, e.g. \"iOS 17.5\" (repeatable)")
+	cmd.Flags().DurationVar(&timeout, "timeout", devtoolsDefaultTimeout, "timeout of the bootstrap")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		var err error
+		if bootstrap.SimulatorRuntimes, err = devtools.ParseSimulatorRuntimes(runtimes); err != nil {
+			return err
+		}
+		if !bootstrap.CLT && !bootstrap.Rosetta && !bootstrap.AcceptXcodeLicense && len(bootstrap.SimulatorRuntimes) == 0 {
+			return errors.New("nothing to bootstrap, select steps with flags or --profile")
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+		defer cancel()
+
+		report := bootstrap.Run(ctx)
+		if err := printJSON(cmd, report); err != nil {
+			return err
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("bootstrap timeout of %v exceeded", timeout)
+		}
+		if failed := report.Failed(); failed > 0 {
+			return fmt.Errorf("%d bootstrap step(s) failed", failed)
+		}
+
+		return nil
+	}
+
+	return cmd
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// disksCommand creates a new command which groups disk inspection utilities.
+func disksCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disks",
+		Short: "inspect disks and volumes",
+		Long:  "utilities for inspecting the disks, partitions, and volumes of EC2 macOS instances",
+	}
+
+	cmd.AddCommand(disksListCommand())
+
+	return cmd
+}
+
+// disksListCommand creates a new command which lists disks, partitions, and APFS volumes as a table.
+func disksListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list disks and volumes",
+		Long:  "list all disks with their partitions and APFS volumes using 'diskutil'",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			d, err := diskutil.ForProduct(product)
+			if err != nil {
+				return err
+			}
+
+			partitions, err := d.List(ctx, nil)
+			if err != nil {
+				return err
+			}
+
+			return disksTable(contextual.Styler(ctx), partitions).Render(cmd.OutOrStdout())
+		},
+	}
+}
+
+// disksTable renders each disk followed by its partitions and APFS volumes, which are indented beneath it.
+func disksTable(styler *output.Styler, partitions *types.SystemPartitions) *output.Table {
+	const indent = "  "
+
+	table := output.NewTable(styler, "device", "size", "content", "name", "mount point")
+	for _, disk := range partitions.AllDisksAndPartitions {
+		table.AddRow(styler.Bold(disk.DeviceIdentifier), humanize.Bytes(disk.Size), disk.Content)
+		for _, p := range disk.Partitions {
+			table.AddRow(indent+p.DeviceIdentifier, humanize.Bytes(p.Size), p.Content, p.VolumeName)
+		}
+		for _, v := range disk.APFSVolumes {
+			table.AddRow(indent+v.DeviceIdentifier, humanize.Bytes(v.Size), "APFS Volume", v.VolumeName, v.MountPoint)
+		}
+	}
+
+	return table
+}
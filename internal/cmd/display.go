@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/display"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+)
+
+// displayAgentLabel is the label of the agent that sets the resolution at login.
+const displayAgentLabel = "com.amazon.ec2.macos-utils.display"
+
+// displayCommand creates a new command which groups display utilities.
+func displayCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "display",
+		Short: "display utilities",
+		Long:  "utilities for configuring the displays of headless instances",
+	}
+
+	cmd.AddCommand(
+		displayConfigureCommand(),
+	)
+
+	return cmd
+}
+
+// displayConfigureCommand creates a new command which sets the display resolution.
+func displayConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "set the display resolution",
+		Long: strings.TrimSpace(`
+configure sets the resolution of the main display, or of the display
+with the persistent screen ID given with --display, so that UI tests and
+screen recordings render at a deterministic size on headless instances.
+Modes without HiDPI scaling are preferred so that screenshots have the
+requested size. The command fails, listing the supported resolutions,
+if the display has no mode with the requested one.
+
+Displays are configured with displayplacer, which must be installed
+(e.g. 'brew install displayplacer'), in the window server session of the
+user logged in on the console. When run as root, e.g. over SSH, the
+resolution is set in that user's session, running displayplacer as that
+user from /opt/homebrew/bin or /usr/local/bin. It must be owned by root
+or by that user and not writable by others. Enable automatic login for
+headless instances to have a session at boot.
+
+The window server normally keeps the resolution across reboots, but the
+virtual displays of some headless instances reset at login. With
+--persist, a launchd agent is also installed that sets the resolution
+whenever a user logs in, which requires root privileges.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		resolution string
+		screenID   string
+		persist    bool
+	)
+	cmd.Flags().StringVar(&resolution, "resolution", "", "resolution to set, as <width>x<height> (e.g. 1920x1080)")
+	cmd.Flags().StringVar(&screenID, "display", "", "persistent screen ID of the display to configure (default main display)")
+	cmd.Flags().BoolVar(&persist, "persist", false, "set the resolution whenever a user logs in")
+	_ = cmd.MarkFlagRequired("resolution")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		res, err := display.ParseResolution(resolution)
+		if err != nil {
+			return err
+		}
+		if persist {
+			if err := assertRootPrivileges(cmd, nil); err != nil {
+				return err
+			}
+		}
+
+		ctx := cmd.Context()
+		screens, err := display.Screens(ctx)
+		if err != nil {
+			if errors.Is(err, display.ErrNoDisplayplacer) {
+				return fmt.Errorf("%w, install it with 'brew install displayplacer'", err)
+			}
+			return err
+		}
+		screen, err := selectScreen(screens, screenID)
+		if err != nil {
+			return err
+		}
+		mode, ok := screen.FindMode(res)
+		if !ok {
+			return fmt.Errorf("display %s doesn't support %s, supported resolutions are %s", screen.ID, res, joinResolutions(screen.Resolutions()))
+		}
+
+		if mode.Current {
+			logrus.WithFields(logrus.Fields{
+				"display":    screen.ID,
+				"resolution": res,
+			}).Info("Display already has the resolution")
+		} else {
+			if err := display.SetMode(ctx, screen, mode); err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{
+				"display":    screen.ID,
+				"resolution": res,
+				"previous":   screen.Resolution,
+			}).Info("Set display resolution")
+		}
+
+		if !persist {
+			return nil
+		}
+		path, err := installDisplayAgent(cmd, res, screenID)
+		if err != nil {
+			return err
+		}
+		logrus.WithField("path", path).Info("Installed agent to set the resolution at login")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// selectScreen returns the screen with the persistent ID, or the main screen when id is empty.
+func selectScreen(screens []display.Screen, id string) (display.Screen, error) {
+	if id == "" {
+		screen, ok := display.Main(screens)
+		if !ok {
+			return display.Screen{}, errors.New("no displays found")
+		}
+		return screen, nil
+	}
+
+	ids := make([]string, 0, len(screens))
+	for _, screen := range screens {
+		if screen.ID == id {
+			return screen, nil
+		}
+		ids = append(ids, screen.ID)
+	}
+
+	return display.Screen{}, fmt.Errorf("no display %q, found %s", id, strings.Join(ids, ", "))
+}
+
+// joinResolutions formats the resolutions as a comma-separated list.
+func joinResolutions(resolutions []display.Resolution) string {
+	names := make([]string, len(resolutions))
+	for i, res := range resolutions {
+		names[i] = res.String()
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// installDisplayAgent installs an agent which sets the resolution in the GUI session of every user that logs in.
+func installDisplayAgent(cmd *cobra.Command, res display.Resolution, screenID string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot find executable: %w", err)
+	}
+	args := []string{exe, "display", "configure", "--resolution", res.String()}
+	if screenID != "" {
+		args = append(args, "--display", screenID)
+	}
+
+	return launchd.Install(cmd.Context(), launchd.Spec{
+		Kind:             launchd.Agent,
+		Label:            displayAgentLabel,
+		ProgramArguments: args,
+		RunAtLoad:        true,
+		SessionType:      launchd.AquaSession,
+	})
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/logexport"
+)
+
+const (
+	exportLogsDefaultLast    = 2 * time.Hour
+	exportLogsDefaultTimeout = 15 * time.Minute
+)
+
+// exportLogsArgs is a struct for holding all information passed into the export-logs command.
+type exportLogsArgs struct {
+	last      time.Duration
+	predicate string
+	format    string
+	outputDir string
+	timeout   time.Duration
+	printPath bool
+	// collectorTimeout bounds each collector of a bundle.
+	collectorTimeout time.Duration
+}
+
+// exportLogsCommand creates a new command which exports recent unified log entries.
+func exportLogsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export-logs",
+		Short: "export recent unified log entries",
+		Long: strings.TrimSpace(`
+export-logs exports the unified log entries from the last period (--last)
+as a much lighter alternative to create-sysdiagnose.
+
+With --format text, the default, the entries matching --predicate are
+written as gzip-compressed syslog-style text. The default predicate
+selects the entries most relevant to EC2 instances: the EC2 agents and
+tools, SSH, disk management, software updates, and launchd errors. Pass
+--predicate '' to export every entry.
+
+With --format logarchive, the whole unified log from the period is
+collected into a .logarchive bundle and saved as a gzip-compressed
+tarball which can be opened with Console or filtered later with
+'log show --archive <bundle> --predicate ...'. Predicates can't be
+applied when collecting, so --predicate isn't allowed with this format,
+and collecting requires root privileges.
+
+With --format bundle, the entries matching --predicate are saved as text
+in a gzip-compressed tarball along with the output of quick diagnostic
+tools: the macOS version, hardware, disks, free space, network
+interfaces, routes, DNS configuration, launchd jobs, processes, and
+memory statistics. The tools run concurrently, each for at most
+--collector-timeout. One that fails or hangs doesn't hold up the others,
+and its outcome is recorded in the summary.json at the end of the
+tarball. The bundle is a lighter fallback when create-sysdiagnose fails
+or takes too long.
+
+Logs are written to stderr. With --print-path, the path of the saved
+export is the only output written to stdout.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var args exportLogsArgs
+	cmd.Flags().DurationVar(&args.last, "last", exportLogsDefaultLast, "period to export, ending now (e.g. 30m, 2h, 24h)")
+	cmd.Flags().StringVar(&args.predicate, "predicate", logexport.DefaultPredicate, "unified log predicate selecting the entries to export (text format only)")
+	cmd.Flags().StringVar(&args.format, "format", string(logexport.FormatText), "export format, text, logarchive, or bundle")
+	cmd.Flags().StringVar(&args.outputDir, "output-dir", os.TempDir(), "directory where the export will be saved")
+	cmd.Flags().DurationVar(&args.timeout, "timeout", exportLogsDefaultTimeout, "set the timeout for the export")
+	cmd.Flags().BoolVar(&args.printPath, "print-path", false, "print only the path of the saved export on stdout")
+	cmd.Flags().DurationVar(&args.collectorTimeout, "collector-timeout", logexport.DefaultCollectorTimeout, "timeout of each diagnostic tool of a bundle (bundle format only)")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		format := logexport.Format(args.format)
+		switch format {
+		case logexport.FormatText:
+		case logexport.FormatArchive:
+			if cmd.Flags().Changed("predicate") {
+				return errors.New("--predicate can't be used with --format logarchive, filter the archive with 'log show --archive' instead")
+			}
+			if err := assertRootPrivileges(cmd, nil); err != nil {
+				return err
+			}
+		case logexport.FormatBundle:
+		default:
+			return fmt.Errorf("unknown format %q, expected text, logarchive, or bundle", args.format)
+		}
+		if args.collectorTimeout <= 0 {
+			return fmt.Errorf("invalid --collector-timeout %v: must be positive", args.collectorTimeout)
+		}
+		if args.last <= 0 {
+			return fmt.Errorf("invalid --last %v: must be positive", args.last)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), args.timeout)
+		defer cancel()
+
+		outputPath, err := runExportLogs(ctx, format, args)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New("export timeout exceeded")
+			}
+			return err
+		}
+
+		if args.printPath {
+			fmt.Fprintln(cmd.OutOrStdout(), outputPath)
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// runExportLogs saves an export in the format into the output directory and returns its path.
+func runExportLogs(ctx context.Context, format logexport.Format, args exportLogsArgs) (string, error) {
+	// Exports can contain sensitive data, so keep them private to the owner like sysdiagnose archives.
+	if err := os.MkdirAll(args.outputDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	name := "logs_" + time.Now().UTC().Format(sysdiagnoseTimestampFormat)
+	outputPath := filepath.Join(args.outputDir, name+format.Extension())
+	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output file %s: %w", outputPath, err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"output_path": outputPath,
+		"last":        args.last,
+		"format":      format,
+	}).Info("Exporting logs")
+
+	switch format {
+	case logexport.FormatArchive:
+		err = logexport.Collect(ctx, output, args.last, name)
+	case logexport.FormatBundle:
+		collectors := logexport.BundleCollectors(args.last, args.predicate)
+		for i := range collectors {
+			collectors[i].Timeout = args.collectorTimeout
+		}
+		// Failed collectors are logged and recorded in the bundle, they don't fail the export.
+		_, err = logexport.WriteBundle(ctx, output, collectors)
+	default:
+		err = logexport.Show(ctx, output, args.last, args.predicate)
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(outputPath)
+		return "", fmt.Errorf("failed to export logs: %w", err)
+	}
+
+	var size int64
+	if fi, err := os.Stat(outputPath); err == nil {
+		size = fi.Size()
+	}
+	logrus.WithFields(logrus.Fields{
+		"output_path": outputPath,
+		"bytes":       size,
+	}).Infof("Log export completed (%s)", units.HumanSize(float64(size)))
+
+	return outputPath, nil
+}
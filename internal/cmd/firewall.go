@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/firewall"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+// firewallDaemonLabel is the label of the launchd daemon that reapplies the pf rules at boot.
+const firewallDaemonLabel = "com.amazon.ec2.macos-utils.firewall"
+
+// firewallCommand creates a new command which groups host firewall utilities.
+func firewallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "firewall",
+		Short: "host firewall management",
+		Long:  "utilities for configuring the macOS application firewall and packet filter (pf) rules",
+	}
+
+	cmd.AddCommand(
+		firewallAppCommand(),
+		firewallPFCommand(),
+		firewallStatusCommand(),
+	)
+
+	return cmd
+}
+
+// firewallAppCommand creates a new command which groups application firewall utilities.
+func firewallAppCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "app",
+		Short: "application firewall",
+		Long:  "utilities for enabling and disabling the macOS application firewall",
+	}
+
+	enable := &cobra.Command{
+		Use:   "enable",
+		Short: "enable the application firewall",
+		Long: strings.TrimSpace(`
+enable turns on the macOS application firewall, which blocks incoming
+connections to applications that aren't allowed to receive them. With
+--stealth, probes such as pings are ignored; with --block-all, every
+incoming connection is blocked except those required by basic services
+(note that this includes SSH).
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+	var opts firewall.AppOptions
+	enable.Flags().BoolVar(&opts.Stealth, "stealth", false, "ignore probes such as pings")
+	enable.Flags().BoolVar(&opts.BlockAll, "block-all", false, "block every incoming connection except those of basic services")
+	enable.RunE = func(cmd *cobra.Command, _ []string) error {
+		if err := firewall.EnableApp(cmd.Context(), opts); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"stealth":   opts.Stealth,
+			"block_all": opts.BlockAll,
+		}).Info("Enabled application firewall")
+
+		return nil
+	}
+
+	disable := &cobra.Command{
+		Use:   "disable",
+		Short: "disable the application firewall",
+		Long: strings.TrimSpace(`
+disable turns off the macOS application firewall.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := firewall.DisableApp(cmd.Context()); err != nil {
+				return err
+			}
+			logrus.Info("Disabled application firewall")
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(enable, disable)
+
+	return cmd
+}
+
+// firewallPFCommand creates a new command which groups pf rule utilities.
+func firewallPFCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pf",
+		Short: "packet filter rules",
+		Long:  "utilities for managing packet filter (pf) rules from a declarative configuration",
+	}
+
+	cmd.AddCommand(firewallPFApplyCommand(), firewallPFClearCommand())
+
+	return cmd
+}
+
+// firewallPFApplyCommand creates a new command which loads pf rules from a configuration.
+func firewallPFApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "apply pf rules from a configuration",
+		Long: strings.TrimSpace(`
+apply restricts incoming connections to ports to the sources allowed by
+a JSON configuration, replacing the rules previously applied, and
+enables pf. For example, to only allow SSH and Screen Sharing from the
+VPC and a bastion host:
+
+  {
+    "rules": [
+      {"service": "ssh", "allow": ["10.0.0.0/16", "192.0.2.10"]},
+      {"service": "vnc", "allow": ["10.0.0.0/16"]},
+      {"port": 8080, "protocol": "tcp", "allow": ["10.0.0.0/16"]}
+    ]
+  }
+
+Services are ssh (22), vnc (Screen Sharing, 5900), and ard (Apple Remote
+Desktop, 3283). Every rule needs at least one allowed source so that a
+mistake can't block a port entirely. Ports without a rule aren't
+affected, nor are connections over the loopback interface (lo0), e.g.
+to a local service from an SSH tunnel.
+
+The rules are loaded in the ` + firewall.Anchor + ` pf anchor,
+which the default /etc/pf.conf evaluates, and don't survive a restart
+unless --persist is set, which installs a launchd daemon that applies
+them at boot. With --dry-run, the rules are printed instead of applied.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		configPath      string
+		dryRun, persist bool
+	)
+	cmd.Flags().StringVar(&configPath, "config-file", "", "JSON configuration of the rules")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the rules instead of applying them")
+	cmd.Flags().BoolVar(&persist, "persist", false, "apply the rules at every boot")
+	_ = cmd.MarkFlagRequired("config-file")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		c, err := firewall.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			fmt.Fprint(cmd.OutOrStdout(), c.Render())
+			return nil
+		}
+
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+		if err := firewall.Apply(cmd.Context(), c); err != nil {
+			return err
+		}
+		logrus.WithField("rules", len(c.Rules)).Info("Applied pf rules")
+
+		if !persist {
+			return nil
+		}
+		path, err := installFirewallDaemon(cmd, configPath)
+		if err != nil {
+			return err
+		}
+		logrus.WithField("path", path).Info("Installed daemon to apply pf rules at boot")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// installFirewallDaemon installs the launchd daemon that applies the configuration at boot.
+func installFirewallDaemon(cmd *cobra.Command, configPath string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot find executable: %w", err)
+	}
+	absConfig, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve configuration path: %w", err)
+	}
+
+	return launchd.Install(cmd.Context(), launchd.Spec{
+		Kind:             launchd.Daemon,
+		Label:            firewallDaemonLabel,
+		ProgramArguments: []string{exe, "firewall", "pf", "apply", "--config-file", absConfig},
+		RunAtLoad:        true,
+	})
+}
+
+// firewallPFClearCommand creates a new command which removes the pf rules.
+func firewallPFClearCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "remove the applied pf rules",
+		Long: strings.TrimSpace(`
+clear removes the rules applied by 'firewall pf apply' and the daemon
+that applies them at boot, if installed. pf stays enabled for the rules
+of other anchors.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := firewall.Clear(cmd.Context()); err != nil {
+				return err
+			}
+			if _, err := os.Stat(launchd.Path(launchd.Daemon, firewallDaemonLabel)); err == nil {
+				if err := launchd.Remove(cmd.Context(), launchd.Daemon, "", firewallDaemonLabel); err != nil {
+					return err
+				}
+			}
+			logrus.Info("Cleared pf rules")
+
+			return nil
+		},
+	}
+}
+
+// firewallStatus is the effective state of the host firewall.
+type firewallStatus struct {
+	App firewall.AppState `json:"app"`
+	PF  firewall.PFState  `json:"pf"`
+}
+
+// firewallStatusCommand creates a new command which prints the state of the host firewall.
+func firewallStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "print the state of the host firewall",
+		Long: strings.TrimSpace(`
+status prints the state of the application firewall, whether pf is
+enabled, and the pf rules applied by 'firewall pf apply', as text or,
+with --json, a JSON object.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var asJSON bool
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the state as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		var status firewallStatus
+		var err error
+		if status.App, err = firewall.GetAppState(cmd.Context()); err != nil {
+			return err
+		}
+		if status.PF, err = firewall.GetPFState(cmd.Context()); err != nil {
+			return err
+		}
+
+		if asJSON {
+			return printJSON(cmd, status)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		onOff := func(on bool) string {
+			if on {
+				return styler.Good("on")
+			}
+			return styler.Caution("off")
+		}
+		table := output.NewTable(styler, "component", "state")
+		table.AddRow("application firewall", onOff(status.App.Enabled))
+		table.AddRow("stealth mode", onOff(status.App.Stealth))
+		table.AddRow("block all", onOff(status.App.BlockAll))
+		table.AddRow("pf", onOff(status.PF.Enabled))
+		if err := table.Render(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "\npf rules (%s):\n", firewall.Anchor)
+		if len(status.PF.Rules) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "  none")
+		}
+		for _, rule := range status.PF.Rules {
+			fmt.Fprintln(cmd.OutOrStdout(), "  "+rule)
+		}
+
+		return nil
+	}
+
+	return cmd
+}
@@ -0,0 +1,417 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/runid"
+	"github.com/aws/ec2-macos-utils/internal/state"
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// firstbootDefaultModuleTimeout is the default time limit of each first-boot module.
+const firstbootDefaultModuleTimeout = 15 * time.Minute
+
+// Statuses of first-boot modules in the report.
+const (
+	// firstbootSucceeded and firstbootFailed are the outcomes of modules that ran.
+	firstbootSucceeded = "succeeded"
+	firstbootFailed    = "failed"
+	// firstbootDone is the status of modules that already succeeded on the instance, which aren't run again.
+	firstbootDone = "done"
+	// firstbootPending is the status of modules that would run, in a dry run.
+	firstbootPending = "pending"
+)
+
+// firstbootModule is a step of the first boot, performed by running commands of the utility.
+type firstbootModule struct {
+	name        string
+	description string
+	// commands returns the arguments of each command that performs the module.
+	commands func(args firstbootArgs) [][]string
+}
+
+// firstbootModules are the first-boot modules, in the order they run. The disk is grown first so that the other
+// modules have space, and the daemons are installed last so that they start on a configured host.
+var firstbootModules = []firstbootModule{
+	{
+		name:        "grow-disk",
+		description: "grow the root container to the size of the volume",
+		commands: func(firstbootArgs) [][]string {
+			return [][]string{{"grow", "--id", "root"}}
+		},
+	},
+	{
+		name:        "hostname",
+		description: "set the host names from the instance",
+		commands: func(firstbootArgs) [][]string {
+			return [][]string{{"hostname", "set"}}
+		},
+	},
+	{
+		name:        "ssh-keys",
+		description: "sync authorized_keys with the instance's public keys",
+		commands: func(firstbootArgs) [][]string {
+			return [][]string{{"ssh", "sync-keys"}}
+		},
+	},
+	{
+		name:        "time",
+		description: "set the system time from the Amazon Time Sync Service",
+		commands: func(firstbootArgs) [][]string {
+			return [][]string{{"time", "configure"}}
+		},
+	},
+	{
+		name:        "services",
+		description: "install the launchd jobs of the --service-spec specs",
+		commands: func(args firstbootArgs) [][]string {
+			var commands [][]string
+			for _, spec := range args.serviceSpecs {
+				commands = append(commands, []string{"service", "install", "--spec", spec})
+			}
+			return commands
+		},
+	},
+}
+
+// firstbootDefaultModules are the modules run when --module isn't set.
+var firstbootDefaultModules = []string{"grow-disk", "hostname", "ssh-keys", "time"}
+
+// firstbootArgs is a struct for holding the arguments of the firstboot run command.
+type firstbootArgs struct {
+	modules       []string
+	serviceSpecs  []string
+	moduleTimeout time.Duration
+	force         bool
+	dryRun        bool
+}
+
+// firstbootModuleResult is the status of a first-boot module in the report.
+type firstbootModuleResult struct {
+	Module string `json:"module"`
+	Status string `json:"status"`
+	// Time, DurationMS, Attempts, and Error are those of the module's last run on the instance, if it ran.
+	Time       *time.Time `json:"time,omitempty"`
+	DurationMS int64      `json:"duration_ms"`
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// firstbootReport is the outcome of the firstboot run command.
+type firstbootReport struct {
+	Instance string                  `json:"instance"`
+	DryRun   bool                    `json:"dry_run"`
+	Modules  []firstbootModuleResult `json:"modules"`
+}
+
+// firstbootRunner runs a command of the utility with the arguments.
+type firstbootRunner func(ctx context.Context, args []string) error
+
+// firstbootCommand creates a new command with subcommands for the first boot of instances.
+func firstbootCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "firstboot",
+		Short: "configure the host on the first boot of an instance",
+	}
+
+	cmd.AddCommand(firstbootRunCommand())
+
+	return cmd
+}
+
+// firstbootRunCommand creates a new command which runs the first-boot modules once per instance.
+func firstbootRunCommand() *cobra.Command {
+	var modules strings.Builder
+	for _, m := range firstbootModules {
+		fmt.Fprintf(&modules, "  %-10s%s\n", m.name, m.description)
+	}
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "run the first-boot modules once per instance",
+		Long: strings.TrimSpace(`
+run configures the host on the first boot of an instance in one pass,
+replacing user data scripts that call the utility's commands one by one.
+The --module modules run in this order, whatever their order on the
+command line:
+
+` + modules.String() + `
+Each module runs a command of the utility: 'grow --id root',
+'hostname set', 'ssh sync-keys', 'time configure', and 'service install'
+for each --service-spec, with the flags configured for that command in
+the configuration file, e.g. the --from of "hostname set". A module that
+fails doesn't stop the modules after it.
+
+The status of each module is recorded in the utility's state for the
+instance, identified by its instance ID and the host's platform UUID.
+Modules that succeeded on the instance don't run again, so run can be
+run at every boot, e.g. by a launchd daemon, and only runs modules that
+didn't succeed yet: on the first boot of an instance, including one
+launched from an image of this host, and after a failure. With --force,
+every module runs again.
+
+A report of the status of each module is printed, as a JSON object with
+--json. With --dry-run, the modules that would run are reported without
+running them.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:         cobra.NoArgs,
+		PreRunE:      assertRootPrivileges,
+		SilenceUsage: true,
+	}
+
+	var (
+		args   firstbootArgs
+		asJSON bool
+	)
+	cmd.Flags().StringSliceVar(&args.modules, "module", firstbootDefaultModules, "modules to run")
+	cmd.Flags().StringArrayVar(&args.serviceSpecs, "service-spec", nil, "JSON spec of a launchd job the services module installs (repeatable)")
+	cmd.Flags().DurationVar(&args.moduleTimeout, "module-timeout", firstbootDefaultModuleTimeout, "time limit of each module")
+	cmd.Flags().BoolVar(&args.force, "force", false, "run the modules that already succeeded on the instance again")
+	cmd.Flags().BoolVar(&args.dryRun, "dry-run", false, "report the modules that would run without running them")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		modules, err := selectFirstbootModules(args)
+		if err != nil {
+			return err
+		}
+		if args.moduleTimeout <= 0 {
+			return errors.New("module timeout must be positive")
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		instance, err := firstbootInstance(ctx)
+		if err != nil {
+			return err
+		}
+		run, err := firstbootExec(cmd)
+		if err != nil {
+			return err
+		}
+		report, err := runFirstboot(ctx, instance, modules, args, run)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			if err := printJSON(cmd, report); err != nil {
+				return err
+			}
+		} else {
+			printFirstbootReport(cmd, report)
+		}
+
+		var failed int
+		for _, m := range report.Modules {
+			if m.Status == firstbootFailed {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d first-boot modules failed", failed, len(report.Modules))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// selectFirstbootModules returns the modules named by args, in the order they run.
+func selectFirstbootModules(args firstbootArgs) ([]firstbootModule, error) {
+	known := map[string]bool{}
+	for _, m := range firstbootModules {
+		known[m.name] = true
+	}
+	selected := map[string]bool{}
+	for _, name := range args.modules {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown module %q", name)
+		}
+		selected[name] = true
+	}
+
+	var modules []firstbootModule
+	for _, m := range firstbootModules {
+		if !selected[m.name] {
+			continue
+		}
+		if len(m.commands(args)) == 0 {
+			return nil, fmt.Errorf("module %s has nothing to do, see its description", m.name)
+		}
+		modules = append(modules, m)
+	}
+
+	return modules, nil
+}
+
+// firstbootInstance returns what identifies the instance the host runs as: its instance ID and the host's platform
+// UUID, which also changes when an instance's image is launched on another host.
+func firstbootInstance(ctx context.Context) (string, error) {
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return "", err
+	}
+	id, err := aws.InstanceID(ctx, cfg)
+	if err != nil {
+		return "", fmt.Errorf("cannot get instance ID: %w", err)
+	}
+	uuid, err := getCollectionPrefix()
+	if err != nil {
+		return "", fmt.Errorf("cannot get platform UUID: %w", err)
+	}
+
+	return id + "/" + uuid, nil
+}
+
+// firstbootExec returns a runner that runs commands of the running executable, with the configuration file of cmd
+// and its run ID so that their logs are correlated with it.
+func firstbootExec(cmd *cobra.Command) (firstbootRunner, error) {
+	exe, err := executablePath()
+	if err != nil {
+		return nil, err
+	}
+	prefix := []string{exe}
+	if f := cmd.Flags().Lookup("config"); f != nil && f.Changed {
+		prefix = append(prefix, "--config", f.Value.String())
+	}
+	env := []string{runid.EnvVar + "=" + contextual.RunID(cmd.Context())}
+
+	return func(ctx context.Context, args []string) error {
+		out, err := util.ExecuteCommand(ctx, append(append([]string(nil), prefix...), args...), "", env, nil)
+		if out.Stdout != "" {
+			logrus.WithField("command", strings.Join(args, " ")).Debug(strings.TrimSpace(out.Stdout))
+		}
+		return err
+	}, nil
+}
+
+// runFirstboot runs the modules that didn't succeed on the instance yet, or every module with args.force, recording
+// the status of each as it completes, and returns the report.
+func runFirstboot(ctx context.Context, instance string, modules []firstbootModule, args firstbootArgs, run firstbootRunner) (firstbootReport, error) {
+	report := firstbootReport{Instance: instance, DryRun: args.dryRun}
+
+	recorded := map[string]state.FirstbootModule{}
+	err := withState(func(s *state.Store) error {
+		statuses, err := s.FirstbootModules(instance)
+		for _, status := range statuses {
+			recorded[status.Module] = status
+		}
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, m := range modules {
+		status, ran := recorded[m.name]
+		switch {
+		case ran && status.OK && !args.force:
+			report.Modules = append(report.Modules, firstbootResult(firstbootDone, status))
+			logrus.WithField("module", m.name).Debug("First-boot module already succeeded")
+			continue
+		case args.dryRun:
+			result := firstbootResult(firstbootPending, status)
+			result.Module = m.name
+			report.Modules = append(report.Modules, result)
+			continue
+		}
+
+		log := logrus.WithField("module", m.name)
+		log.Info("Running first-boot module")
+		start := time.Now()
+		err := runFirstbootModule(ctx, m, args, run)
+
+		status = state.FirstbootModule{
+			Instance:   instance,
+			Module:     m.name,
+			OK:         err == nil,
+			Time:       start,
+			DurationMS: time.Since(start).Milliseconds(),
+			Attempts:   status.Attempts + 1,
+		}
+		outcome := firstbootSucceeded
+		if err != nil {
+			outcome, status.Error = firstbootFailed, err.Error()
+			log.WithError(err).Error("First-boot module failed")
+		} else {
+			log.WithField("duration", time.Since(start)).Info("First-boot module succeeded")
+		}
+		if err := withState(func(s *state.Store) error { return s.RecordFirstbootModule(status) }); err != nil {
+			return report, fmt.Errorf("cannot record status of module %s: %w", m.name, err)
+		}
+		report.Modules = append(report.Modules, firstbootResult(outcome, status))
+
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+	}
+
+	return report, nil
+}
+
+// runFirstbootModule runs the commands of the module in order, until one fails, within the module timeout.
+func runFirstbootModule(ctx context.Context, m firstbootModule, args firstbootArgs, run firstbootRunner) error {
+	ctx, cancel := context.WithTimeout(ctx, args.moduleTimeout)
+	defer cancel()
+
+	for _, command := range m.commands(args) {
+		if err := run(ctx, command); err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(command, " "), err)
+		}
+	}
+
+	return nil
+}
+
+// firstbootResult returns the report of a module with its recorded status.
+func firstbootResult(status string, recorded state.FirstbootModule) firstbootModuleResult {
+	result := firstbootModuleResult{
+		Module:     recorded.Module,
+		Status:     status,
+		DurationMS: recorded.DurationMS,
+		Attempts:   recorded.Attempts,
+		Error:      recorded.Error,
+	}
+	if !recorded.Time.IsZero() {
+		t := recorded.Time
+		result.Time = &t
+	}
+
+	return result
+}
+
+// printFirstbootReport prints a line for each module of the report.
+func printFirstbootReport(cmd *cobra.Command, report firstbootReport) {
+	w := cmd.OutOrStdout()
+	fmt.Fprintf(w, "Instance %s\n", report.Instance)
+	styler := contextual.Styler(cmd.Context())
+	for _, m := range report.Modules {
+		switch m.Status {
+		case firstbootFailed:
+			fmt.Fprintf(w, "%s  %s: %s\n", styler.Status(false), m.Module, m.Error)
+		case firstbootSucceeded:
+			fmt.Fprintf(w, "%s  %s (%s)\n", styler.Status(true), m.Module, time.Duration(m.DurationMS)*time.Millisecond)
+		case firstbootDone:
+			fmt.Fprintf(w, "%s  %s already succeeded at %s\n", styler.Status(true), m.Module, m.Time.Local().Format(time.RFC3339))
+		default:
+			fmt.Fprintf(w, "would run  %s\n", m.Module)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectFirstbootModules(t *testing.T) {
+	modules, err := selectFirstbootModules(firstbootArgs{modules: []string{"time", "grow-disk"}})
+	require.NoError(t, err)
+	require.Len(t, modules, 2)
+	assert.Equal(t, "grow-disk", modules[0].name, "modules should run in their order, not the flag's")
+	assert.Equal(t, "time", modules[1].name)
+
+	_, err = selectFirstbootModules(firstbootArgs{modules: []string{"power"}})
+	assert.EqualError(t, err, `unknown module "power"`)
+	_, err = selectFirstbootModules(firstbootArgs{modules: []string{"services"}})
+	assert.Error(t, err, "services without specs should be rejected")
+}
+
+func TestRunFirstboot(t *testing.T) {
+	withTestState(t)
+	args := firstbootArgs{
+		modules:       []string{"grow-disk", "hostname", "services"},
+		serviceSpecs:  []string{"/a.json", "/b.json"},
+		moduleTimeout: time.Minute,
+	}
+	modules, err := selectFirstbootModules(args)
+	require.NoError(t, err)
+
+	var ran []string
+	failHostname := true
+	run := func(_ context.Context, args []string) error {
+		command := strings.Join(args, " ")
+		ran = append(ran, command)
+		if command == "hostname set" && failHostname {
+			return errors.New("no network")
+		}
+		return nil
+	}
+	statuses := func(report firstbootReport) map[string]string {
+		s := map[string]string{}
+		for _, m := range report.Modules {
+			s[m.Module] = m.Status
+		}
+		return s
+	}
+
+	report, err := runFirstboot(context.Background(), "i-1/uuid", modules, args, run)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"grow --id root", "hostname set", "service install --spec /a.json", "service install --spec /b.json"}, ran,
+		"a failed module shouldn't stop the modules after it")
+	assert.Equal(t, map[string]string{"grow-disk": "succeeded", "hostname": "failed", "services": "succeeded"}, statuses(report))
+	assert.Equal(t, "hostname set: no network", report.Modules[1].Error)
+
+	ran, failHostname = nil, false
+	report, err = runFirstboot(context.Background(), "i-1/uuid", modules, args, run)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hostname set"}, ran, "only the failed module should run again")
+	assert.Equal(t, map[string]string{"grow-disk": "done", "hostname": "succeeded", "services": "done"}, statuses(report))
+	assert.Equal(t, 2, report.Modules[1].Attempts)
+
+	ran = nil
+	dryRun := args
+	dryRun.dryRun = true
+	report, err = runFirstboot(context.Background(), "i-2/uuid", modules, dryRun, run)
+	require.NoError(t, err)
+	assert.Empty(t, ran, "a dry run shouldn't run modules")
+	assert.Equal(t, map[string]string{"grow-disk": "pending", "hostname": "pending", "services": "pending"}, statuses(report),
+		"modules should run again on another instance")
+
+	force := args
+	force.force = true
+	_, err = runFirstboot(context.Background(), "i-1/uuid", modules, force, run)
+	require.NoError(t, err)
+	assert.Len(t, ran, 4, "every module should run again with force")
+}
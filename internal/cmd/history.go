@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/aws/ec2-macos-utils/internal/actionlog"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/privsep"
+	"github.com/aws/ec2-macos-utils/internal/redact"
+)
+
+// recordedActions are the paths of the commands that change the instance's state, whose invocations are recorded to
+// the action log.
+var recordedActions = []string{
+	"audit configure",
+	"certs install",
+	"certs remove",
+	"debug create-sysdiagnose",
+	"debug purge-memory",
+	"devtools bootstrap",
+	"devtools install-clt",
+	"display configure",
+	"firewall app disable",
+	"firewall app enable",
+	"firewall pf apply",
+	"firewall pf clear",
+	"firstboot run",
+	"grow",
+	"hostname set",
+	"network configure-eni",
+	"network dhcp renew",
+	"network set-dns",
+	"network set-mtu",
+	"network set-proxy",
+	"remote-desktop disable",
+	"remote-desktop enable",
+	"rosetta install",
+	"security harden",
+	"security set-gatekeeper",
+	"self-update",
+	"service install",
+	"service remove",
+	"spotlight disable",
+	"spotlight enable",
+	"ssh configure",
+	"ssh sync-keys",
+	"system set-locale",
+	"system set-timezone",
+	"time configure",
+	"timemachine configure",
+	"ui configure",
+	"updates configure",
+	"updates defer",
+	"updates install",
+	"user create",
+	"user delete",
+	"user grant-sudo",
+	"user modify",
+	"user revoke-sudo",
+	"user set-password",
+	"watchdog pause",
+	"watchdog resume",
+}
+
+// recordActions makes the recorded actions under root record their invocations to the action log at path.
+func recordActions(root *cobra.Command, path string) {
+	for _, action := range recordedActions {
+		cmd, _, err := root.Find(strings.Fields(action))
+		if err != nil || cmd.RunE == nil {
+			continue
+		}
+		runE := cmd.RunE
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			start := time.Now()
+			err := runE(cmd, args)
+
+			e := actionlog.NewEntry(action, start, err)
+			e.RunID = contextual.RunID(cmd.Context())
+			e.Args = args
+			e.Flags = changedFlags(cmd.Flags())
+			appendAction(path, e)
+
+			return err
+		}
+	}
+}
+
+// changedFlags returns the values of the flags that were set on the command line or by the configuration.
+func changedFlags(flags *pflag.FlagSet) map[string]string {
+	values := map[string]string{}
+	flags.Visit(func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			values[f.Name] = strings.Join(sv.GetSlice(), ",")
+			return
+		}
+		values[f.Name] = f.Value.String()
+	})
+	if len(values) == 0 {
+		return nil
+	}
+
+	return values
+}
+
+// recordAction records an action performed on the command's behalf, such as a watchdog's remediation, to the action
+// log.
+func recordAction(ctx context.Context, action, detail string, start time.Time, err error) {
+	e := actionlog.NewEntry(action, start, err)
+	e.RunID = contextual.RunID(ctx)
+	e.Detail = detail
+	appendAction(actionlog.DefaultPath, e)
+}
+
+// appendAction appends the entry to the action log at path. Failing to record an action doesn't fail it.
+func appendAction(path string, e actionlog.Entry) {
+	e = redactEntry(e)
+	err := privsep.Privileged(func() error { return actionlog.Append(path, e) })
+	if err != nil {
+		logrus.WithError(err).WithField("action", e.Action).Warn("Failed to record action")
+	}
+}
+
+// historyCommand creates a new command which shows the actions recorded to the action log.
+func historyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "show the actions performed on this instance",
+		Long: strings.TrimSpace(`
+history shows the state-changing actions performed by ec2-macos-utils on
+this instance, oldest first, for auditing changes: resizes, configuration
+changes, installations, user management, sysdiagnose collection, and
+watchdog remediations.
+
+Every action is appended to ` + actionlog.DefaultPath + ` as a line of
+JSON recording when it started and how long it took, who performed it
+(the user and, with sudo, the invoking user), the command with its
+arguments and flags, the run ID of its logs, and whether it succeeded or
+the error it failed with. Actions run by users without write access to
+the log aren't recorded.
+
+Each entry records the hash of the previous one, so that modifying or
+removing entries can be detected with 'history verify'.
+
+Entries can be selected by --action, which includes subcommands (e.g.
+"user" selects "user create"), by --since, as a duration before now or
+an RFC 3339 time, and with --failed. --limit shows only the most recent
+entries. With --json, the entries are printed as JSON.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		historyVerifyCommand(),
+	)
+
+	var (
+		q       actionlog.Query
+		since   string
+		logFile string
+		asJSON  bool
+	)
+	cmd.Flags().StringVar(&q.Action, "action", "", "only show the action and its subcommands, e.g. \"ssh configure\"")
+	cmd.Flags().StringVar(&since, "since", "", "only show actions since a duration before now (e.g. 24h) or an RFC 3339 time")
+	cmd.Flags().BoolVar(&q.Failed, "failed", false, "only show failed actions")
+	cmd.Flags().IntVar(&q.Limit, "limit", 50, "maximum number of most recent actions to show, or 0 for all")
+	cmd.Flags().StringVar(&logFile, "log-file", actionlog.DefaultPath, "action log to read")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the actions as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if q.Limit < 0 {
+			return errors.New("limit cannot be negative")
+		}
+		var err error
+		if q.Since, err = parseSince(since, time.Now()); err != nil {
+			return err
+		}
+
+		entries, err := actionlog.Read(logFile, q)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			if entries == nil {
+				entries = []actionlog.Entry{}
+			}
+			return printJSON(cmd, entries)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		table := output.NewTable(styler, "time", "user", "action", "result", "duration")
+		for _, e := range entries {
+			who := e.User
+			if e.SudoUser != "" {
+				who = e.SudoUser + " (" + e.User + ")"
+			}
+			action := strings.Join(append([]string{e.Action}, e.Args...), " ")
+			result := styler.Good(string(e.Result))
+			if e.Result == actionlog.Failure {
+				result = styler.Bad(string(e.Result))
+			}
+			table.AddRow(e.Time.Local().Format(time.RFC3339), who, action, result, e.Duration.Round(time.Second).String())
+		}
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
+
+// historyVerifyCommand creates a new command which verifies the chain of entries of the action log.
+func historyVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "verify the hash chain of the action log",
+		Long: strings.TrimSpace(`
+verify checks the hash chain of the action log to detect changes to it:
+each entry records the hash of the previous one, so modifying or
+removing an entry breaks the chain at the next entry, and removing
+entries from the start leaves a first entry that doesn't start the
+chain. Entries recorded before entries were chained may only precede
+the first chained entry, which marks the genesis of the chain.
+
+The hashes aren't keyed, so root can rewrite the whole chain, and
+removing entries from the end can't be detected from the log alone.
+verify prints the head of the chain, the hash of the last entry, which
+can be recorded off the host (e.g. by fleet tooling) and passed back
+with --head to check that the log still contains it, i.e. that the
+entries up to it weren't rewritten and none were removed after it.
+
+The breaks found are printed with their line in the log, as a table or,
+with --json, a JSON object, and the command fails if there are any.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		logFile string
+		head    string
+		asJSON  bool
+	)
+	cmd.Flags().StringVar(&logFile, "log-file", actionlog.DefaultPath, "action log to verify")
+	cmd.Flags().StringVar(&head, "head", "", "head of the chain recorded earlier, which must still be in the log")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the verification as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		v, err := actionlog.Verify(logFile, head)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			if err := printJSON(cmd, v); err != nil {
+				return err
+			}
+		} else {
+			styler := contextual.Styler(cmd.Context())
+			intact := styler.Good("true")
+			if !v.Intact() {
+				intact = styler.Bad("false")
+			}
+			table := output.NewTable(styler, "check", "result")
+			table.AddRow("intact", intact)
+			table.AddRow("entries", strconv.Itoa(v.Entries))
+			table.AddRow("unchained entries", strconv.Itoa(v.Unchained))
+			table.AddRow("head", orDash(v.Head))
+			for _, p := range v.Problems {
+				table.AddRow("line "+strconv.Itoa(p.Line), styler.Bad(p.Message))
+			}
+			if err := table.Render(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+		}
+
+		if !v.Intact() {
+			return fmt.Errorf("action log %s was changed: %d break(s) in its chain", logFile, len(v.Problems))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// parseSince parses --since as a duration before now or an RFC 3339 time. Empty means no limit.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		if d < 0 {
+			return time.Time{}, errors.New("since cannot be a negative duration")
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, errors.New("since must be a duration such as 24h or an RFC 3339 time")
+	}
+
+	return t, nil
+}
+
+// redactEntry returns the entry with secrets redacted from its arguments, flags, detail, and error, since the action
+// log is kept for longer than the logs.
+func redactEntry(e actionlog.Entry) actionlog.Entry {
+	e.Args = redact.Strings(e.Args)
+	if e.Flags != nil {
+		flags := make(map[string]string, len(e.Flags))
+		for name, value := range e.Flags {
+			flags[name] = redact.String(value)
+		}
+		e.Flags = flags
+	}
+	e.Detail = redact.String(e.Detail)
+	e.Error = redact.String(e.Error)
+
+	return e
+}
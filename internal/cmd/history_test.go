@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/actionlog"
+)
+
+func TestRecordedActions(t *testing.T) {
+	root := MainCommand()
+	for _, action := range recordedActions {
+		cmd, _, err := root.Find(strings.Fields(action))
+		if assert.NoError(t, err, action) {
+			assert.Equal(t, action, strings.TrimPrefix(cmd.CommandPath(), root.Name()+" "))
+			assert.NotNil(t, cmd.RunE, action)
+		}
+	}
+}
+
+func TestRecordActions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.log")
+	var admin bool
+	child := &cobra.Command{Use: "create", RunE: func(*cobra.Command, []string) error { return errors.New("user exists") }}
+	child.Flags().BoolVar(&admin, "admin", false, "")
+	parent := &cobra.Command{Use: "user"}
+	parent.AddCommand(child)
+	root := testTree(parent)
+	recordActions(root, path)
+
+	root.SetArgs([]string{"user", "create", "ci", "--admin"})
+	assert.Error(t, root.Execute())
+
+	entries, err := actionlog.Read(path, actionlog.Query{})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "user create", entries[0].Action)
+		assert.Equal(t, []string{"ci"}, entries[0].Args)
+		assert.Equal(t, map[string]string{"admin": "true"}, entries[0].Flags)
+		assert.Equal(t, actionlog.Failure, entries[0].Result)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseSince("", now)
+	assert.NoError(t, err)
+	assert.True(t, since.IsZero())
+
+	since, err = parseSince("24h", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-24*time.Hour), since)
+
+	since, err = parseSince("2024-04-30T08:00:00Z", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC), since)
+
+	for _, invalid := range []string{"-1h", "yesterday"} {
+		_, err := parseSince(invalid, now)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/hostname"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+const (
+	// hostnameDaemonLabel is the label of the launchd daemon that sets the host name at boot.
+	hostnameDaemonLabel = "com.amazon.ec2.macos-utils.hostname"
+	// hostnameDaemonWait is how long the daemon waits for the name's source, e.g. IMDS, to become available at boot.
+	hostnameDaemonWait = 5 * time.Minute
+	// hostnameRetryInterval is the interval between attempts to resolve the name while waiting.
+	hostnameRetryInterval = 5 * time.Second
+)
+
+// hostnameCommand creates a new command which groups host name utilities.
+func hostnameCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hostname",
+		Short: "host name management",
+		Long:  "utilities for setting the macOS computer name, host name, and local host name",
+	}
+
+	cmd.AddCommand(
+		hostnameSetCommand(),
+	)
+
+	return cmd
+}
+
+// hostnameSetCommand creates a new command which sets the host names from the instance.
+func hostnameSetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "set the computer name, host name, and local host name",
+		Long: strings.TrimSpace(`
+set sets the computer name, host name, and local (Bonjour) host name
+consistently with scutil. The name comes from --from, one of:
+
+  instance-id   the instance ID (default)
+  tag:<key>     the value of an instance tag, e.g. tag:Name
+  value:<name>  the given name
+
+The computer name is the name as is. The host name is the name
+lowercased with characters that aren't valid in DNS names replaced by
+hyphens, and the local host name is the same as a single DNS label.
+Names that already have the desired value aren't changed.
+
+Tags are read from instance metadata when tags in instance metadata are
+enabled, and with ec2:DescribeTags otherwise.
+
+With --wait, the name's source is retried for up to that long, e.g.
+while the network comes up at boot. With --persist, a launchd daemon is
+also installed that sets the names at every boot.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var (
+		from    string
+		wait    time.Duration
+		persist bool
+	)
+	cmd.Flags().StringVar(&from, "from", "instance-id", "source of the name: instance-id, tag:<key>, or value:<name>")
+	cmd.Flags().DurationVar(&wait, "wait", 0, "retry resolving the name for up to this long")
+	cmd.Flags().BoolVar(&persist, "persist", false, "set the names at every boot")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if wait < 0 {
+			return errors.New("wait cannot be negative")
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		name, err := waitHostname(ctx, from, wait)
+		if err != nil {
+			return err
+		}
+		names, err := hostname.FromName(name)
+		if err != nil {
+			return err
+		}
+		changed, err := hostname.Set(ctx, names)
+		if err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"computer_name":   names.ComputerName,
+			"host_name":       names.HostName,
+			"local_host_name": names.LocalHostName,
+			"changed":         changed,
+		}).Info("Set host names")
+
+		if !persist {
+			return nil
+		}
+		path, err := installHostnameDaemon(cmd, from)
+		if err != nil {
+			return err
+		}
+		logrus.WithField("path", path).Info("Installed daemon to set host names at boot")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// waitHostname resolves the name from the source, retrying for up to wait.
+func waitHostname(ctx context.Context, from string, wait time.Duration) (string, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		name, err := resolveHostname(ctx, from)
+		if err == nil || errors.Is(err, errInvalidHostnameSource) || !time.Now().Before(deadline) {
+			return name, err
+		}
+		logrus.WithError(err).Warn("Failed to resolve host name, will retry")
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(hostnameRetryInterval):
+		}
+	}
+}
+
+// errInvalidHostnameSource is returned for --from values that can't be resolved however often they're retried.
+var errInvalidHostnameSource = errors.New("invalid host name source")
+
+// resolveHostname returns the name from the source.
+func resolveHostname(ctx context.Context, from string) (string, error) {
+	switch {
+	case from == "instance-id":
+		cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+		if err != nil {
+			return "", err
+		}
+		return aws.InstanceID(ctx, cfg)
+	case strings.HasPrefix(from, "tag:"):
+		key := strings.TrimPrefix(from, "tag:")
+		if key == "" {
+			return "", fmt.Errorf("%w: missing tag key", errInvalidHostnameSource)
+		}
+		cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+		if err != nil {
+			return "", err
+		}
+		tags, err := instance.NewTagReader(cfg).Tags(ctx)
+		if err != nil {
+			return "", err
+		}
+		value, ok := tags[key]
+		if !ok || strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("instance has no %q tag", key)
+		}
+		return value, nil
+	case strings.HasPrefix(from, "value:"):
+		if value := strings.TrimPrefix(from, "value:"); value != "" {
+			return value, nil
+		}
+		return "", fmt.Errorf("%w: missing value", errInvalidHostnameSource)
+	default:
+		return "", fmt.Errorf("%w %q, expected instance-id, tag:<key>, or value:<name>", errInvalidHostnameSource, from)
+	}
+}
+
+// installHostnameDaemon installs the launchd daemon that sets the names from the source at boot.
+func installHostnameDaemon(cmd *cobra.Command, from string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot find executable: %w", err)
+	}
+
+	return launchd.Install(cmd.Context(), launchd.Spec{
+		Kind:             launchd.Daemon,
+		Label:            hostnameDaemonLabel,
+		ProgramArguments: []string{exe, "hostname", "set", "--from", from, "--wait", hostnameDaemonWait.String()},
+		RunAtLoad:        true,
+	})
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveHostname(t *testing.T) {
+	name, err := resolveHostname(context.Background(), "value:Build Mac")
+	assert.NoError(t, err)
+	assert.Equal(t, "Build Mac", name)
+
+	for _, from := range []string{"value:", "tag:", "name", ""} {
+		_, err := resolveHostname(context.Background(), from)
+		assert.True(t, errors.Is(err, errInvalidHostnameSource), from)
+	}
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/imdslatency"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+const (
+	// imdsLatencyDefaultSamplesPath is where the samples are appended by default.
+	imdsLatencyDefaultSamplesPath = "/private/var/db/ec2-macos-utils/imds-latency.jsonl"
+	// imdsLatencyDefaultDuration is how long samples are taken for by default.
+	imdsLatencyDefaultDuration = time.Hour
+	// imdsLatencyDefaultInterval is the default time between samples.
+	imdsLatencyDefaultInterval = 30 * time.Second
+	// imdsLatencyDefaultTimeout is the default time limit of each request.
+	imdsLatencyDefaultTimeout = 5 * time.Second
+)
+
+// imdsLatencyArgs is a struct for holding the arguments of the imds-latency command.
+type imdsLatencyArgs struct {
+	duration    time.Duration
+	interval    time.Duration
+	timeout     time.Duration
+	samplesPath string
+}
+
+// imdsLatencyCommand creates a new command which records the latency of IMDS over time.
+func imdsLatencyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "imds-latency",
+		Short: "record the latency of IMDS over time",
+		Long: strings.TrimSpace(`
+imds-latency samples the round-trip latency of the Instance Metadata
+Service every --interval for --duration, or until interrupted, and then
+prints the 50th, 90th, and 99th percentiles and the maximum of:
+
+  token     requesting an IMDSv2 session token
+  metadata  reading the instance ID with the token
+  total     both requests
+
+Failed samples are counted separately and don't affect the percentiles.
+It gives evidence for reports of intermittent metadata slowness, e.g.:
+
+  ec2-macos-utils debug imds-latency --duration 24h --interval 30s
+
+Every sample is appended to --samples as a line of JSON as it's taken, so
+that samples survive the process and several runs can be summarized
+together with --summarize, which summarizes the samples in the file
+without taking more. The summary is printed as JSON with --json.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+	}
+
+	var (
+		args      imdsLatencyArgs
+		summarize bool
+		asJSON    bool
+	)
+	cmd.Flags().DurationVar(&args.duration, "duration", imdsLatencyDefaultDuration, "how long to take samples for")
+	cmd.Flags().DurationVar(&args.interval, "interval", imdsLatencyDefaultInterval, "time between samples")
+	cmd.Flags().DurationVar(&args.timeout, "timeout", imdsLatencyDefaultTimeout, "time limit of each request, after which the sample fails")
+	cmd.Flags().StringVar(&args.samplesPath, "samples", imdsLatencyDefaultSamplesPath, "file the samples are appended to")
+	cmd.Flags().BoolVar(&summarize, "summarize", false, "summarize the samples in --samples without taking more")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the summary as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		var (
+			samples []imdslatency.Sample
+			err     error
+		)
+		if summarize {
+			samples, err = imdslatency.ReadSamples(args.samplesPath)
+		} else {
+			if args.duration <= 0 || args.interval <= 0 || args.timeout <= 0 {
+				return errors.New("duration, interval, and timeout must be positive")
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			prober := &imdslatency.Prober{Client: &http.Client{Timeout: args.timeout}}
+			samples, err = recordIMDSLatency(ctx, prober, args)
+		}
+		if err != nil {
+			return err
+		}
+
+		summary, err := imdslatency.Summarize(samples)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(cmd, summary)
+		}
+
+		return printIMDSLatencySummary(cmd, summary)
+	}
+
+	return cmd
+}
+
+// recordIMDSLatency takes a sample at once and then every interval until the duration has passed or ctx is done,
+// appending each to the samples file, and returns the samples taken.
+func recordIMDSLatency(ctx context.Context, prober *imdslatency.Prober, args imdsLatencyArgs) ([]imdslatency.Sample, error) {
+	ctx, cancel := context.WithTimeout(ctx, args.duration)
+	defer cancel()
+
+	logrus.WithFields(logrus.Fields{
+		"duration": args.duration,
+		"interval": args.interval,
+		"samples":  args.samplesPath,
+	}).Info("Recording IMDS latency")
+
+	ticker := time.NewTicker(args.interval)
+	defer ticker.Stop()
+	var samples []imdslatency.Sample
+	for {
+		sample := prober.Probe(ctx)
+		if ctx.Err() != nil {
+			// The sample was cut short by the end of the recording rather than by IMDS.
+			return samples, nil
+		}
+		if err := imdslatency.AppendSample(args.samplesPath, sample); err != nil {
+			return samples, fmt.Errorf("cannot save sample: %w", err)
+		}
+		samples = append(samples, sample)
+
+		log := logrus.WithFields(logrus.Fields{
+			"token":    sample.Token,
+			"metadata": sample.Metadata,
+		})
+		if sample.Error != "" {
+			log.WithField("error", sample.Error).Warn("IMDS sample failed")
+		} else {
+			log.Debug("Sampled IMDS latency")
+		}
+
+		select {
+		case <-ctx.Done():
+			return samples, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// printIMDSLatencySummary prints the summary as a table of percentiles, after the period and counts of the samples.
+func printIMDSLatencySummary(cmd *cobra.Command, summary imdslatency.Summary) error {
+	w := cmd.OutOrStdout()
+	fmt.Fprintf(w, "%d samples from %s to %s, %d failed\n", summary.Samples,
+		summary.From.Local().Format(time.RFC3339), summary.To.Local().Format(time.RFC3339), summary.Failures)
+	if summary.Slowest != nil {
+		fmt.Fprintf(w, "Slowest at %s: %s\n", summary.Slowest.Time.Local().Format(time.RFC3339), summary.Slowest.Total())
+	}
+	fmt.Fprintln(w)
+
+	ms := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) + "ms" }
+	table := output.NewTable(contextual.Styler(cmd.Context()), "round trip", "p50", "p90", "p99", "max")
+	for _, row := range []struct {
+		name string
+		p    imdslatency.Percentiles
+	}{
+		{"token", summary.Token},
+		{"metadata", summary.Metadata},
+		{"total", summary.Total},
+	} {
+		table.AddRow(row.name, ms(row.p.P50), ms(row.p.P90), ms(row.p.P99), ms(row.p.Max))
+	}
+
+	return table.Render(w)
+}
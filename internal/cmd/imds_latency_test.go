@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/ec2-macos-utils/internal/imdslatency"
+)
+
+func TestRecordIMDSLatency(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("token"))
+	}))
+	defer server.Close()
+
+	args := imdsLatencyArgs{
+		duration:    200 * time.Millisecond,
+		interval:    20 * time.Millisecond,
+		samplesPath: filepath.Join(t.TempDir(), "imds-latency.jsonl"),
+	}
+	prober := &imdslatency.Prober{Endpoint: server.URL, Client: server.Client()}
+	samples, err := recordIMDSLatency(context.Background(), prober, args)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, len(samples), 2, "samples should be taken at every interval")
+	for _, s := range samples {
+		assert.Empty(t, s.Error)
+	}
+
+	saved, err := imdslatency.ReadSamples(args.samplesPath)
+	assert.NoError(t, err)
+	assert.Len(t, saved, len(samples), "every sample should be saved")
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/journal"
+)
+
+// addResumeFlag registers the flag that resumes an interrupted run of a journaled operation.
+func addResumeFlag(flags *pflag.FlagSet, resume *bool) {
+	flags.BoolVar(resume, "resume", false, "resume an interrupted run, skipping the steps it completed")
+}
+
+// operationJournal is the journal of a command's operation. Failing to record a step only loses the ability to resume
+// the operation, so it's logged rather than failing the operation.
+type operationJournal struct {
+	*journal.Journal
+}
+
+// beginJournal starts the journal of the command's operation on key, continuing the journal of an interrupted run
+// with resume. An interrupted run that isn't resumed is reported since it may have left the operation half done.
+func beginJournal(cmd *cobra.Command, key string, resume bool) (operationJournal, error) {
+	operation := commandPath(cmd)
+	j, interrupted, err := journal.Begin(statePath, operation, key, contextual.RunID(cmd.Context()), resume)
+	if err != nil {
+		return operationJournal{}, err
+	}
+
+	if interrupted != nil {
+		logrus.WithFields(logrus.Fields{
+			"operation":      operation,
+			"started":        interrupted.Started,
+			"completed":      interrupted.Completed,
+			"interrupted_id": interrupted.RunID,
+		}).Warn("A previous run was interrupted, starting over (use --resume to continue it instead)")
+	}
+	if j.Resumed() {
+		logrus.WithFields(logrus.Fields{
+			"operation": operation,
+			"started":   j.Record().Started,
+			"completed": j.Record().Completed,
+		}).Info("Resuming interrupted run")
+	} else if resume {
+		logrus.WithField("operation", operation).Info("No interrupted run to resume, starting")
+	}
+
+	return operationJournal{j}, nil
+}
+
+// Complete records that the step completed.
+func (j operationJournal) Complete(step string) {
+	if err := j.Journal.Complete(step); err != nil {
+		logrus.WithError(err).WithField("step", step).Warn("Failed to record completed step, the operation can't be resumed from it")
+	}
+}
+
+// Finish removes the journal once the operation completed.
+func (j operationJournal) Finish() {
+	if err := j.Journal.Finish(); err != nil {
+		logrus.WithError(err).Warn("Failed to remove the journal of the completed operation")
+	}
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	logsShipDefaultLogGroup      = "/ec2-macos-utils/macos"
+	logsShipDefaultFlushInterval = 5 * time.Second
+	logsShipDefaultStateDir      = "/private/var/db/ec2-macos-utils/logs-ship"
+
+	// logsShipSourceRestartDelay is the delay before restarting a source that stopped with an error.
+	logsShipSourceRestartDelay = 10 * time.Second
+)
+
+// logsShipDefaultFiles are the log files shipped when none are specified. Glob patterns are expanded when the
+// command starts.
+var logsShipDefaultFiles = []string{
+	"/var/log/system.log",
+	"/var/log/install.log",
+	"/var/log/ec2-macos-utils*.log",
+}
+
+// logsShipArgs is a struct for holding all information passed into the logs ship command.
+type logsShipArgs struct {
+	logGroup      string
+	streamPrefix  string
+	files         []string
+	predicates    []string
+	flushInterval time.Duration
+	stateDir      string
+}
+
+// logsCommand creates a new command which groups log utilities.
+func logsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "log utilities",
+		Long:  "utilities for collecting and shipping EC2 macOS instance logs",
+	}
+
+	cmd.AddCommand(logsShipCommand())
+
+	return cmd
+}
+
+// logsShipCommand creates a new command which continuously ships logs to CloudWatch Logs.
+func logsShipCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ship",
+		Short: "ship logs to CloudWatch Logs",
+		Long: strings.TrimSpace(`
+ship tails log files and unified log predicates and delivers their
+entries to CloudWatch Logs using the instance role's credentials.
+
+Each file and predicate is delivered to its own log stream named
+<stream-prefix>/<source>, where the stream prefix defaults to the
+instance ID. Predicates may be labeled as label=predicate to name
+their stream. File read positions are saved in the state directory
+so restarts resume where they left off. The command fails at start if
+the CloudWatch Logs endpoint, including --endpoint-url overrides, is
+unreachable.
+
+This command runs until interrupted and requires root privileges.
+        `),
+	}
+
+	var args logsShipArgs
+	cmd.Flags().StringVar(&args.logGroup, "log-group", logsShipDefaultLogGroup, "destination log group, created if it doesn't exist")
+	cmd.Flags().StringVar(&args.streamPrefix, "stream-prefix", "", "log stream name prefix (default instance ID)")
+	cmd.Flags().StringArrayVar(&args.files, "file", logsShipDefaultFiles, "log file or glob pattern to ship (repeatable)")
+	cmd.Flags().StringArrayVar(&args.predicates, "predicate", nil, "unified log predicate to ship, optionally as label=predicate (repeatable)")
+	cmd.Flags().DurationVar(&args.flushInterval, "flush-interval", logsShipDefaultFlushInterval, "longest time events are buffered before delivery")
+	cmd.Flags().StringVar(&args.stateDir, "state-dir", logsShipDefaultStateDir, "directory where file read positions are saved")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return runLogsShip(ctx, args)
+	}
+
+	return cmd
+}
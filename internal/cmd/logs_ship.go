@@ -0,0 +1,165 @@
+//go:build !minimal
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/cwlogs"
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+)
+
+// predicateLabelExp matches the optional "label=" prefix of a --predicate value.
+var predicateLabelExp = regexp.MustCompile(`^([A-Za-z0-9._-]+)=([^=].*)$`)
+
+// logsShipTask returns the task that ships logs continuously, restarting the shipping after it fails.
+func logsShipTask(args logsShipArgs) (scheduler.Task, error) {
+	return scheduler.Task{
+		Name:       "logs-ship",
+		Interval:   logsShipSourceRestartDelay,
+		Continuous: true,
+		Run: func(ctx context.Context) error {
+			return runLogsShip(ctx, args)
+		},
+	}, nil
+}
+
+func runLogsShip(ctx context.Context, args logsShipArgs) error {
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := cloudWatchLogsEndpoint(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := aws.CheckConfigConnectivity(ctx, cfg, endpoint); err != nil {
+		return fmt.Errorf("cannot ship logs: %w", err)
+	}
+
+	if args.streamPrefix == "" {
+		args.streamPrefix, err = aws.InstanceID(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("cannot determine stream prefix: %w", err)
+		}
+	}
+
+	offsets, err := cwlogs.LoadOffsets(filepath.Join(args.stateDir, "offsets.json"))
+	if err != nil {
+		return err
+	}
+
+	sources, err := logsShipSources(args, offsets)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("no log sources to ship")
+	}
+
+	shipper := &cwlogs.Shipper{
+		Client:        cloudwatchlogs.NewFromConfig(cfg),
+		LogGroup:      args.logGroup,
+		StreamPrefix:  args.streamPrefix,
+		FlushInterval: args.flushInterval,
+		OnFlush: func(delivered []cwlogs.Position) {
+			for _, pos := range delivered {
+				offsets.Set(pos.File, pos.Inode, pos.Offset)
+			}
+			if err := offsets.Save(); err != nil {
+				logrus.WithError(err).Warn("Failed to save log file offsets")
+			}
+		},
+	}
+
+	events := make(chan cwlogs.Event, 1024)
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src cwlogs.Source) {
+			defer wg.Done()
+			tailSource(ctx, src, events)
+		}(src)
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"log_group":     args.logGroup,
+		"stream_prefix": args.streamPrefix,
+		"sources":       len(sources),
+	}).Info("Shipping logs to CloudWatch Logs")
+
+	err = shipper.Run(ctx, events)
+	if ctx.Err() != nil {
+		logrus.Info("Stopped shipping logs")
+		return nil
+	}
+
+	return err
+}
+
+// logsShipSources builds the sources for the requested files and predicates, expanding file glob patterns.
+func logsShipSources(args logsShipArgs, offsets *cwlogs.Offsets) ([]cwlogs.Source, error) {
+	var sources []cwlogs.Source
+
+	seen := map[string]bool{}
+	for _, pattern := range args.files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			// Ship explicitly named files that don't exist yet once they're created.
+			matches = []string{pattern}
+		}
+		for _, path := range matches {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			sources = append(sources, &cwlogs.FileSource{Path: path, Offsets: offsets})
+		}
+	}
+
+	for i, p := range args.predicates {
+		label, predicate := fmt.Sprintf("unified-log-%d", i+1), p
+		if m := predicateLabelExp.FindStringSubmatch(p); m != nil {
+			label, predicate = m[1], m[2]
+		}
+		sources = append(sources, &cwlogs.UnifiedLogSource{Label: label, Predicate: predicate})
+	}
+
+	return sources, nil
+}
+
+// tailSource runs the source until ctx is done, restarting it after errors.
+func tailSource(ctx context.Context, src cwlogs.Source, out chan<- cwlogs.Event) {
+	for {
+		err := src.Tail(ctx, out)
+		if ctx.Err() != nil {
+			return
+		}
+		logrus.WithError(err).WithField("source", src.Name()).Warn("Log source stopped, restarting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(logsShipSourceRestartDelay):
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	metricsDefaultNamespace         = "EC2MacOSUtils"
+	metricsDefaultDiskPath          = "/"
+	metricsDefaultHeartbeatInterval = time.Minute
+)
+
+// metricsPublishArgs is a struct for holding all information passed into the metrics publish command.
+type metricsPublishArgs struct {
+	namespace string
+	interval  time.Duration
+	diskPaths []string
+	checks    []string
+}
+
+// metricsCommand creates a new command which groups metric utilities.
+func metricsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "metric utilities",
+		Long:  "utilities for publishing EC2 macOS instance metrics",
+	}
+
+	cmd.AddCommand(
+		metricsPublishCommand(),
+		metricsHeartbeatCommand(),
+	)
+
+	return cmd
+}
+
+// metricsPublishCommand creates a new command which publishes host vitals to CloudWatch.
+func metricsPublishCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "publish",
+		Short: "publish host metrics to CloudWatch",
+		Long: strings.TrimSpace(`
+publish sends CPU utilization, memory pressure, disk free space, and
+check results to CloudWatch using the instance role's credentials.
+
+Every metric has InstanceId and PlatformUUID dimensions. Disk metrics
+add a Path dimension and check results (CheckPassed, 1 or 0) add a
+Check dimension.
+
+Metrics are published once unless --interval is set, in which case
+they're published at that interval until interrupted.
+        `),
+	}
+
+	var args metricsPublishArgs
+	cmd.Flags().StringVar(&args.namespace, "namespace", metricsDefaultNamespace, "CloudWatch namespace to publish metrics under")
+	cmd.Flags().DurationVar(&args.interval, "interval", 0, "publish repeatedly at this interval instead of once")
+	cmd.Flags().StringSliceVar(&args.diskPaths, "disk", []string{metricsDefaultDiskPath}, "path on each volume to report free space for")
+	cmd.Flags().StringSliceVar(&args.checks, "check", []string{"imds"}, fmt.Sprintf("checks to report results for (available: %s)", strings.Join(systemCheckNames(), ", ")))
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return runMetricsPublish(ctx, args)
+	}
+
+	return cmd
+}
+
+// metricsHeartbeatCommand creates a new command which publishes a heartbeat metric to CloudWatch.
+func metricsHeartbeatCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "heartbeat",
+		Short: "publish a heartbeat metric to CloudWatch",
+		Long: strings.TrimSpace(`
+heartbeat publishes a Heartbeat metric with a value of 1, dimensioned by
+InstanceId, at every interval until interrupted. It's meant to run as a
+daemon on hosts without the CloudWatch agent so that an alarm that
+treats missing data as breaching detects an instance that silently
+stopped, e.g.:
+
+  aws cloudwatch put-metric-alarm --alarm-name mac-heartbeat-i-0123 \
+    --namespace EC2MacOSUtils --metric-name Heartbeat \
+    --dimensions Name=InstanceId,Value=i-0123 --statistic SampleCount \
+    --period 60 --evaluation-periods 5 --threshold 1 \
+    --comparison-operator LessThanThreshold --treat-missing-data breaching
+
+With --run-as, it runs as the given service user once it has started,
+since publishing the heartbeat needs no root privileges.
+        `),
+	}
+
+	var (
+		namespace  string
+		interval   time.Duration
+		privileges privilegeArgs
+	)
+	cmd.Flags().StringVar(&namespace, "namespace", metricsDefaultNamespace, "CloudWatch namespace to publish the heartbeat under")
+	cmd.Flags().DurationVar(&interval, "interval", metricsDefaultHeartbeatInterval, "interval between heartbeats")
+	privileges.addFlags(cmd.Flags())
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if interval <= 0 {
+			return errors.New("interval must be positive")
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return runMetricsHeartbeat(ctx, namespace, interval, privileges)
+	}
+
+	return cmd
+}
+
+// defaultCheckNames returns the sorted names of the system checks check all runs by default.
+func defaultCheckNames() []string {
+	var names []string
+	for _, name := range systemCheckNames() {
+		if !optInChecks[name] {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// systemCheckNames returns the sorted names of the available system checks.
+func systemCheckNames() []string {
+	names := make([]string, 0, len(systemChecks))
+	for name := range systemChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
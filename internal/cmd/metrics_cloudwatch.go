@@ -0,0 +1,145 @@
+//go:build !minimal
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/metrics"
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+func runMetricsPublish(ctx context.Context, args metricsPublishArgs) error {
+	collectors := map[string]metrics.Collector{
+		"cpu":    metrics.CPU,
+		"memory": metrics.Memory,
+	}
+	for _, path := range args.diskPaths {
+		collectors["disk "+path] = metrics.DiskFree(path)
+	}
+	for _, name := range args.checks {
+		check, ok := systemChecks[name]
+		if !ok {
+			return fmt.Errorf("unknown check %q", name)
+		}
+		collectors["check "+name] = metrics.Check(name, check)
+	}
+
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return err
+	}
+
+	id, err := aws.InstanceID(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	platformUUID, err := system.GetHostIOPlatformUUID()
+	if err != nil {
+		return fmt.Errorf("cannot determine platform UUID: %w", err)
+	}
+
+	publisher := &metrics.Publisher{
+		Client:     cloudwatch.NewFromConfig(cfg),
+		Namespace:  args.namespace,
+		Dimensions: map[string]string{"InstanceId": id, "PlatformUUID": platformUUID},
+		Collectors: collectors,
+	}
+
+	if args.interval <= 0 {
+		return publishMetrics(ctx, publisher)
+	}
+
+	return publishMetricsEvery(ctx, publisher, args.interval)
+}
+
+// runMetricsHeartbeat publishes the heartbeat metric under the namespace at the interval until ctx is done, dropping
+// privileges once the configuration is loaded.
+func runMetricsHeartbeat(ctx context.Context, namespace string, interval time.Duration, privileges privilegeArgs) error {
+	publisher, err := heartbeatPublisher(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	// The heartbeat needs no privileges once the configuration is loaded.
+	if err := privileges.drop(); err != nil {
+		return err
+	}
+
+	return publishMetricsEvery(ctx, publisher, interval)
+}
+
+// heartbeatTask returns the task that publishes the heartbeat metric under the namespace at the interval.
+func heartbeatTask(ctx context.Context, namespace string, interval time.Duration) (scheduler.Task, error) {
+	publisher, err := heartbeatPublisher(ctx, namespace)
+	if err != nil {
+		return scheduler.Task{}, err
+	}
+
+	return metricsTask(publisher, interval), nil
+}
+
+// heartbeatPublisher creates a publisher of the heartbeat metric under the namespace, dimensioned by the instance ID.
+func heartbeatPublisher(ctx context.Context, namespace string) (*metrics.Publisher, error) {
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return nil, err
+	}
+	id, err := aws.InstanceID(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metrics.Publisher{
+		Client:     cloudwatch.NewFromConfig(cfg),
+		Namespace:  namespace,
+		Dimensions: map[string]string{"InstanceId": id},
+		Collectors: map[string]metrics.Collector{"heartbeat": metrics.Heartbeat},
+	}, nil
+}
+
+// publishMetricsEvery publishes metrics at the interval until ctx is done. Failures are logged and retried at the next
+// interval.
+func publishMetricsEvery(ctx context.Context, publisher *metrics.Publisher, interval time.Duration) error {
+	logrus.WithFields(logrus.Fields{
+		"namespace": publisher.Namespace,
+		"interval":  interval,
+	}).Info("Publishing metrics to CloudWatch")
+
+	err := scheduler.Run(ctx, metricsTask(publisher, interval))
+	if ctx.Err() == nil {
+		return err
+	}
+	logrus.Info("Stopped publishing metrics")
+
+	return nil
+}
+
+// metricsTask returns the task that publishes a round of metrics at the interval.
+func metricsTask(publisher *metrics.Publisher, interval time.Duration) scheduler.Task {
+	return scheduler.Task{
+		Name:     "metrics",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			return publishMetrics(ctx, publisher)
+		},
+	}
+}
+
+// publishMetrics publishes a single round of metrics.
+func publishMetrics(ctx context.Context, publisher *metrics.Publisher) error {
+	n, err := publisher.Publish(ctx)
+	if err != nil {
+		return err
+	}
+	logrus.WithField("metrics", n).Info("Published metrics")
+
+	return nil
+}
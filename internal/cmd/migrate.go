@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/state"
+	"github.com/aws/ec2-macos-utils/pkg/sysdiagnose"
+)
+
+// Migrations of the on-disk layout.
+const (
+	// migrationHostPrefix moves the network health monitor's captures saved directly in its output base directory,
+	// before they were saved in a directory named after the host's platform UUID, into that directory.
+	migrationHostPrefix = "host-prefix"
+	// migrationCaptures records the captures that earlier versions only kept track of through their output
+	// directories in the state database.
+	migrationCaptures = "captures"
+)
+
+// Actions of migration changes.
+const (
+	migrationMove   = "move"
+	migrationRecord = "record"
+)
+
+// migrationLayout is where the watchdogs saved their captures.
+type migrationLayout struct {
+	// prefix names the directory of the network health monitor's captures for the host.
+	prefix              string
+	outputBaseDir       string
+	eventsOutputBaseDir string
+}
+
+// migrationChange is a change that upgrades the on-disk layout.
+type migrationChange struct {
+	Migration string `json:"migration"`
+	Action    string `json:"action"`
+	// Path is the file that's moved, or the capture that's recorded.
+	Path string `json:"path"`
+	// Target is where the file is moved.
+	Target string `json:"target,omitempty"`
+	// Watchdog and ID identify the capture that's recorded.
+	Watchdog string `json:"watchdog,omitempty"`
+	ID       string `json:"id,omitempty"`
+	// Applied reports whether the change was made, and is false in a dry run.
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// migrationReport is the outcome of the migrate command.
+type migrationReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Changes []migrationChange `json:"changes"`
+}
+
+// migrateCommand creates a new command which upgrades the on-disk layout of earlier versions.
+func migrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "upgrade the on-disk layout of earlier versions",
+		Long: strings.TrimSpace(`
+migrate upgrades what earlier versions of the utility saved on disk to the
+current layout, so that watchdogs upgraded in place don't misread or
+duplicate their earlier captures:
+
+  host-prefix  moves the network health monitor's sysdiagnose archives
+               saved directly in --output-base-dir into the directory
+               named after the host's platform UUID, where they're saved
+               now
+  captures     records the captures found in the output directories in
+               the state database, which earlier versions didn't use
+
+Migrations that are already done make no changes, so the command can be
+run on every upgrade. With --dry-run, the changes are printed without
+making them. The command prints a JSON object with --json.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:         cobra.NoArgs,
+		PreRunE:      assertRootPrivileges,
+		SilenceUsage: true,
+	}
+
+	var (
+		layout migrationLayout
+		dryRun bool
+		asJSON bool
+	)
+	cmd.Flags().StringVar(&layout.outputBaseDir, "output-base-dir", networkMonitorDefaultOutputBaseDir, "base directory for sysdiagnose output")
+	cmd.Flags().StringVar(&layout.eventsOutputBaseDir, "scheduled-events-output-base-dir", scheduledEventsDefaultOutputBaseDir, "base directory for scheduled events sysdiagnose output")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the changes without making them")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the results as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		var err error
+		// Moving the captures into a directory named after a made-up prefix would lose them.
+		if layout.prefix, err = getCollectionPrefix(); err != nil {
+			return fmt.Errorf("cannot get host prefix: %w", err)
+		}
+
+		report := migrationReport{DryRun: dryRun}
+		err = withState(func(s *state.Store) error {
+			var err error
+			if report.Changes, err = planMigration(layout, s); err != nil {
+				return err
+			}
+			if !dryRun {
+				applyMigration(report.Changes, s)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			if err := printJSON(cmd, report); err != nil {
+				return err
+			}
+		} else {
+			printMigrationReport(cmd, report)
+		}
+
+		var failed int
+		for _, change := range report.Changes {
+			if change.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d changes failed", failed, len(report.Changes))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// printMigrationReport prints a line for each change of the report.
+func printMigrationReport(cmd *cobra.Command, report migrationReport) {
+	if len(report.Changes) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "Nothing to migrate")
+		return
+	}
+	styler := contextual.Styler(cmd.Context())
+	for _, change := range report.Changes {
+		what := fmt.Sprintf("%s %s", change.Action, change.Path)
+		if change.Action == migrationMove {
+			what += " to " + change.Target
+		} else {
+			what += fmt.Sprintf(" as the %s capture of %s", change.Watchdog, change.ID)
+		}
+		switch {
+		case change.Error != "":
+			fmt.Fprintf(cmd.OutOrStdout(), "%s  %s: %s\n", styler.Status(false), what, change.Error)
+		case report.DryRun:
+			fmt.Fprintf(cmd.OutOrStdout(), "would %s\n", what)
+		default:
+			fmt.Fprintf(cmd.OutOrStdout(), "%s  %s\n", styler.Status(true), what)
+		}
+	}
+}
+
+// planMigration lists the changes that upgrade the layout, in the order they're made. Captures are recorded once
+// they're where the watchdogs look for them, so moves come first.
+func planMigration(layout migrationLayout, s *state.Store) ([]migrationChange, error) {
+	var changes []migrationChange
+
+	// Captures saved before the host prefix, and their manifests.
+	prefixDir := filepath.Join(layout.outputBaseDir, layout.prefix)
+	prefixed, err := sysdiagnoseCaptures(prefixDir)
+	if err != nil {
+		return nil, err
+	}
+	unprefixed, err := sysdiagnoseCaptures(layout.outputBaseDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, archive := range unprefixed {
+		target := filepath.Join(prefixDir, filepath.Base(archive))
+		changes = append(changes, migrationChange{Migration: migrationHostPrefix, Action: migrationMove, Path: archive, Target: target})
+		prefixed = append(prefixed, target)
+
+		manifest := sysdiagnose.ManifestPath(archive)
+		if _, err := os.Stat(manifest); err == nil {
+			changes = append(changes, migrationChange{
+				Migration: migrationHostPrefix,
+				Action:    migrationMove,
+				Path:      manifest,
+				Target:    sysdiagnose.ManifestPath(target),
+			})
+		}
+	}
+
+	// Captures that aren't recorded, of which the latest is recorded as captured() does.
+	change, err := planCaptureRecord(s, networkMonitorWatchdog, layout.prefix, prefixed)
+	if err != nil {
+		return nil, err
+	}
+	if change != nil {
+		changes = append(changes, *change)
+	}
+	eventDirs, err := filepath.Glob(filepath.Join(layout.eventsOutputBaseDir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern: %w", err)
+	}
+	for _, dir := range eventDirs {
+		archives, err := sysdiagnoseCaptures(dir)
+		if err != nil {
+			return nil, err
+		}
+		change, err := planCaptureRecord(s, scheduledEventsWatchdog, filepath.Base(dir), archives)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	return changes, nil
+}
+
+// planCaptureRecord returns the change that records the latest of the archives as the capture of the watchdog with
+// the ID, or nil when there are none or a capture is already recorded.
+func planCaptureRecord(s *state.Store, watchdog, id string, archives []string) (*migrationChange, error) {
+	if len(archives) == 0 {
+		return nil, nil
+	}
+	if _, ok, err := s.Captured(watchdog, id); err != nil || ok {
+		return nil, err
+	}
+	sorted := append([]string(nil), archives...)
+	sort.Strings(sorted)
+
+	return &migrationChange{
+		Migration: migrationCaptures,
+		Action:    migrationRecord,
+		Path:      sorted[len(sorted)-1],
+		Watchdog:  watchdog,
+		ID:        id,
+	}, nil
+}
+
+// applyMigration makes the changes in order, recording whether each was made. A failure doesn't stop the changes
+// after it, but a move never replaces an existing file.
+func applyMigration(changes []migrationChange, s *state.Store) {
+	for i := range changes {
+		change := &changes[i]
+
+		var err error
+		switch change.Action {
+		case migrationMove:
+			err = migrateMove(change.Path, change.Target)
+		case migrationRecord:
+			err = migrateRecord(s, change)
+		default:
+			err = fmt.Errorf("unknown action %q", change.Action)
+		}
+		if err != nil {
+			change.Error = err.Error()
+			logrus.WithError(err).WithField("path", change.Path).Warnf("Failed to %s", change.Action)
+			continue
+		}
+		change.Applied = true
+		logrus.WithFields(logrus.Fields{
+			"migration": change.Migration,
+			"path":      change.Path,
+		}).Debugf("Applied %s", change.Action)
+	}
+}
+
+// migrateMove moves the file at path to target, creating target's directory as the watchdogs do.
+func migrateMove(path, target string) error {
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+
+	return os.Rename(path, target)
+}
+
+// migrateRecord records the capture of the change, captured when its archive was last modified.
+func migrateRecord(s *state.Store, change *migrationChange) error {
+	fi, err := os.Stat(change.Path)
+	if err != nil {
+		return err
+	}
+
+	return s.RecordCapture(state.Capture{Watchdog: change.Watchdog, ID: change.ID, Path: change.Path, Time: fi.ModTime()})
+}
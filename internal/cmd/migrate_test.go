@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+// testMigrationLayout creates the layout of an earlier version in a temporary directory: a network health monitor
+// capture and its manifest saved before the host prefix, and a scheduled event capture that isn't recorded.
+func testMigrationLayout(t *testing.T) migrationLayout {
+	root := t.TempDir()
+	layout := migrationLayout{
+		prefix:              "uuid",
+		outputBaseDir:       filepath.Join(root, "sysdiagnose"),
+		eventsOutputBaseDir: filepath.Join(root, "scheduled-events"),
+	}
+	for _, path := range []string{
+		filepath.Join(layout.outputBaseDir, "sysdiagnose_20240101_000000.tar.gz"),
+		filepath.Join(layout.outputBaseDir, "sysdiagnose_20240101_000000.manifest.json"),
+		filepath.Join(layout.eventsOutputBaseDir, "instance-event-1", "sysdiagnose_20240102_000000.tar.gz"),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, nil, 0400))
+	}
+
+	return layout
+}
+
+func TestMigration(t *testing.T) {
+	withTestState(t)
+	layout := testMigrationLayout(t)
+	prefixDir := filepath.Join(layout.outputBaseDir, layout.prefix)
+
+	var changes []migrationChange
+	require.NoError(t, withState(func(s *state.Store) error {
+		var err error
+		changes, err = planMigration(layout, s)
+		return err
+	}))
+	assert.Equal(t, []migrationChange{
+		{
+			Migration: migrationHostPrefix,
+			Action:    migrationMove,
+			Path:      filepath.Join(layout.outputBaseDir, "sysdiagnose_20240101_000000.tar.gz"),
+			Target:    filepath.Join(prefixDir, "sysdiagnose_20240101_000000.tar.gz"),
+		},
+		{
+			Migration: migrationHostPrefix,
+			Action:    migrationMove,
+			Path:      filepath.Join(layout.outputBaseDir, "sysdiagnose_20240101_000000.manifest.json"),
+			Target:    filepath.Join(prefixDir, "sysdiagnose_20240101_000000.manifest.json"),
+		},
+		{
+			Migration: migrationCaptures,
+			Action:    migrationRecord,
+			Path:      filepath.Join(prefixDir, "sysdiagnose_20240101_000000.tar.gz"),
+			Watchdog:  networkMonitorWatchdog,
+			ID:        layout.prefix,
+		},
+		{
+			Migration: migrationCaptures,
+			Action:    migrationRecord,
+			Path:      filepath.Join(layout.eventsOutputBaseDir, "instance-event-1", "sysdiagnose_20240102_000000.tar.gz"),
+			Watchdog:  scheduledEventsWatchdog,
+			ID:        "instance-event-1",
+		},
+	}, changes, "captures should be recorded where they're moved to")
+
+	require.NoError(t, withState(func(s *state.Store) error {
+		applyMigration(changes, s)
+		return nil
+	}))
+	for _, change := range changes {
+		assert.True(t, change.Applied, change.Path)
+		assert.Empty(t, change.Error, change.Path)
+	}
+	assert.FileExists(t, filepath.Join(prefixDir, "sysdiagnose_20240101_000000.manifest.json"))
+	done, err := captured(networkMonitorWatchdog, layout.prefix, prefixDir)
+	assert.NoError(t, err)
+	assert.True(t, done)
+
+	require.NoError(t, withState(func(s *state.Store) error {
+		changes, err = planMigration(layout, s)
+		return err
+	}))
+	assert.Empty(t, changes, "a migrated layout shouldn't be changed again")
+}
+
+func TestApplyMigration_ExistingTarget(t *testing.T) {
+	withTestState(t)
+	layout := testMigrationLayout(t)
+	existing := filepath.Join(layout.outputBaseDir, layout.prefix, "sysdiagnose_20240101_000000.tar.gz")
+	require.NoError(t, os.MkdirAll(filepath.Dir(existing), 0700))
+	require.NoError(t, os.WriteFile(existing, []byte("newer"), 0400))
+
+	var changes []migrationChange
+	require.NoError(t, withState(func(s *state.Store) error {
+		var err error
+		if changes, err = planMigration(layout, s); err != nil {
+			return err
+		}
+		applyMigration(changes, s)
+		return nil
+	}))
+
+	assert.Contains(t, changes[0].Error, "already exists")
+	assert.False(t, changes[0].Applied)
+	assert.FileExists(t, filepath.Join(layout.outputBaseDir, "sysdiagnose_20240101_000000.tar.gz"), "a file shouldn't be moved over another")
+	data, err := os.ReadFile(existing)
+	assert.NoError(t, err)
+	assert.Equal(t, "newer", string(data))
+}
@@ -0,0 +1,100 @@
+//go:build minimal
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+)
+
+// The minimal build tag excludes the features that use S3, CloudWatch, CloudWatch Logs, SSM Parameter Store, and
+// KMS, whose SDK clients make up much of the executable, for hosts that only need the local commands. The commands
+// remain so that scripts and help stay the same, but invoking one of those features fails with errMinimalBuild.
+
+// errMinimalBuild returns the error of invoking a feature excluded from the minimal build.
+func errMinimalBuild(feature string) error {
+	return fmt.Errorf("%s isn't available in the minimal build of ec2-macos-utils, use the full build instead", feature)
+}
+
+// validate fails when an upload is requested, since uploads to S3 aren't available.
+func (a uploadArgs) validate() error {
+	if !a.enabled() {
+		return nil
+	}
+
+	return errMinimalBuild("uploading to S3")
+}
+
+// preflight fails when an upload is requested, since uploads to S3 aren't available.
+func (a uploadArgs) preflight(context.Context) error {
+	return a.validate()
+}
+
+// upload fails when an upload is requested, since uploads to S3 aren't available.
+func (a uploadArgs) upload(context.Context, ...uploadObject) error {
+	return a.validate()
+}
+
+// uploadStream fails, since uploads to S3 aren't available.
+func (a uploadArgs) uploadStream(context.Context, io.Reader, string) error {
+	return errMinimalBuild("uploading to S3")
+}
+
+// objectURI fails, since uploads to S3 aren't available.
+func (a uploadArgs) objectURI(string) (string, error) {
+	return "", errMinimalBuild("uploading to S3")
+}
+
+// runLogsShip fails, since shipping to CloudWatch Logs isn't available.
+func runLogsShip(context.Context, logsShipArgs) error {
+	return errMinimalBuild("shipping logs to CloudWatch Logs")
+}
+
+// logsShipTask fails, since shipping to CloudWatch Logs isn't available.
+func logsShipTask(logsShipArgs) (scheduler.Task, error) {
+	return scheduler.Task{}, errMinimalBuild("shipping logs to CloudWatch Logs")
+}
+
+// runMetricsPublish fails, since publishing to CloudWatch isn't available.
+func runMetricsPublish(context.Context, metricsPublishArgs) error {
+	return errMinimalBuild("publishing metrics to CloudWatch")
+}
+
+// runMetricsHeartbeat fails, since publishing to CloudWatch isn't available.
+func runMetricsHeartbeat(context.Context, string, time.Duration, privilegeArgs) error {
+	return errMinimalBuild("publishing metrics to CloudWatch")
+}
+
+// heartbeatTask fails, since publishing to CloudWatch isn't available.
+func heartbeatTask(context.Context, string, time.Duration) (scheduler.Task, error) {
+	return scheduler.Task{}, errMinimalBuild("publishing metrics to CloudWatch")
+}
+
+// configLoader returns the loader of local configuration files. Loading ssm:// sources or encrypted values fails,
+// since SSM Parameter Store and KMS aren't available.
+func configLoader(aws.Options) *config.Loader {
+	return &config.Loader{
+		SSM: func(context.Context) (config.ParameterStore, error) {
+			return nil, errMinimalBuild("loading configuration from SSM Parameter Store")
+		},
+		KMS: func(context.Context) (config.Decrypter, error) {
+			return nil, errMinimalBuild("decrypting configuration values")
+		},
+	}
+}
+
+// readSecureParameter fails, since SSM Parameter Store isn't available.
+func readSecureParameter(context.Context, string) (string, error) {
+	return "", errMinimalBuild("reading SSM parameters")
+}
+
+// storeSecureParameter fails, since SSM Parameter Store isn't available.
+func storeSecureParameter(context.Context, string, string, string, string) error {
+	return errMinimalBuild("storing SSM parameters")
+}
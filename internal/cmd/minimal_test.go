@@ -0,0 +1,24 @@
+//go:build minimal
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+)
+
+func TestMinimalBuild(t *testing.T) {
+	ctx := context.Background()
+
+	assert.NoError(t, uploadArgs{}.validate(), "commands shouldn't fail without --upload")
+	assert.ErrorContains(t, uploadArgs{destination: "s3://bucket"}.validate(), "use the full build")
+	assert.ErrorContains(t, runMetricsPublish(ctx, metricsPublishArgs{}), "use the full build")
+	assert.ErrorContains(t, runLogsShip(ctx, logsShipArgs{}), "use the full build")
+
+	_, err := configLoader(aws.Options{}).Load(ctx, "ssm:///ec2-macos-utils/fleet-config")
+	assert.ErrorContains(t, err, "use the full build")
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/mtuprobe"
+	"github.com/aws/ec2-macos-utils/internal/netconfig"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+const (
+	// mtuProbeDefaultTimeout is how long each probe waits for its answer by default.
+	mtuProbeDefaultTimeout = 2 * time.Second
+	// mtuProbeDefaultAttempts is how many times an unanswered size is sent by default.
+	mtuProbeDefaultAttempts = 2
+)
+
+// mtuProbeCommand creates a new command which discovers the path MTU to a host.
+func mtuProbeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mtu-probe",
+		Short: "discover the path MTU to a host",
+		Long: strings.TrimSpace(`
+mtu-probe discovers the largest packet that reaches --target unfragmented
+(the path MTU) by sending pings with the don't-fragment bit set, in sizes
+from --min-mtu to --max-mtu found by binary search, and reports what stops
+larger packets:
+
+  none         the path carries --max-mtu
+  local        the MTU of the local network device
+  frag-needed  a router that rejects them and reports its MTU, as path
+               MTU discovery needs
+  blackhole    a router or firewall that silently drops them, which makes
+               connections hang once they send full-sized packets
+
+A black hole is located by tracing the path with the smallest dropped size;
+it's after the last hop that answered. This helps when jumbo frames work
+within the VPC but not through a VPN or peering connection, e.g.:
+
+  ec2-macos-utils debug mtu-probe --target 10.1.0.10
+
+--max-mtu is the MTU of the network device traffic to the target is routed
+through by default. A size is considered dropped when none of --attempts
+probes is answered within --timeout. Only IPv4 targets are supported.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+	}
+
+	var (
+		target  string
+		opts    mtuprobe.Options
+		timeout time.Duration
+		asJSON  bool
+	)
+	cmd.Flags().StringVar(&target, "target", "", "IPv4 address of the host to probe")
+	cmd.Flags().IntVar(&opts.MinMTU, "min-mtu", mtuprobe.MinIPv4MTU, "smallest size to probe")
+	cmd.Flags().IntVar(&opts.MaxMTU, "max-mtu", 0, "largest size to probe (default the MTU of the device routing to the target)")
+	cmd.Flags().DurationVar(&timeout, "timeout", mtuProbeDefaultTimeout, "time to wait for the answer to each probe")
+	cmd.Flags().IntVar(&opts.Attempts, "attempts", mtuProbeDefaultAttempts, "probes of a size to send before it's considered dropped")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the result as JSON")
+	_ = cmd.MarkFlagRequired("target")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ip := net.ParseIP(target)
+		if ip == nil {
+			return fmt.Errorf("invalid --target %q, expected an IPv4 address", target)
+		}
+		if ip.To4() == nil {
+			return fmt.Errorf("--target %s is IPv6, which isn't supported", target)
+		}
+		if timeout <= 0 || opts.Attempts <= 0 {
+			return errors.New("timeout and attempts must be positive")
+		}
+
+		ctx := cmd.Context()
+		if opts.MaxMTU == 0 {
+			device, err := netconfig.RouteDevice(ctx, target)
+			if err != nil {
+				return err
+			}
+			mtu, err := netconfig.GetMTU(ctx, device)
+			if err != nil {
+				return err
+			}
+			opts.MaxMTU = mtu.Active
+			logrus.WithFields(logrus.Fields{
+				"device": device,
+				"mtu":    mtu.Active,
+			}).Debug("Probing up to the MTU of the device")
+		}
+		trace := mtuprobe.Traceroute(timeout)
+		opts.Trace = func(ctx context.Context, target string, mtu int) ([]string, error) {
+			// The path MTU is still worth reporting when the black hole can't be located.
+			hops, err := trace(ctx, target, mtu)
+			if err != nil {
+				logrus.WithError(err).Warn("Cannot trace the path to locate the black hole")
+			}
+			return hops, nil
+		}
+
+		result, err := mtuprobe.Discover(ctx, ip.String(), opts, mtuprobe.Ping(timeout))
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(cmd, result)
+		}
+
+		return printMTUProbeResult(cmd, result)
+	}
+
+	return cmd
+}
+
+// printMTUProbeResult prints the probes as a table, followed by the path MTU and what limits it.
+func printMTUProbeResult(cmd *cobra.Command, result mtuprobe.Result) error {
+	w := cmd.OutOrStdout()
+	styler := contextual.Styler(cmd.Context())
+
+	table := output.NewTable(styler, "size", "outcome", "router")
+	for _, p := range result.Probes {
+		outcome := p.Outcome
+		if p.Outcome == mtuprobe.OutcomeReply {
+			outcome = styler.Good(outcome)
+		}
+		router := p.Router
+		if p.RouterMTU > 0 {
+			router += " (MTU " + strconv.Itoa(p.RouterMTU) + ")"
+		}
+		table.AddRow(strconv.Itoa(p.MTU), outcome, router)
+	}
+	if err := table.Render(w); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "Path MTU to %s: %d\n", result.Target, result.PathMTU)
+	switch result.Limit {
+	case mtuprobe.LimitNone:
+		fmt.Fprintf(w, "The path carries the largest size probed, %d\n", result.MaxMTU)
+	case mtuprobe.LimitLocal:
+		fmt.Fprintln(w, "Larger packets exceed the MTU of the local network device")
+	case mtuprobe.LimitFragNeeded:
+		fmt.Fprintf(w, "Larger packets are rejected by %s, which reports an MTU of %d\n", result.Router, result.RouterMTU)
+	case mtuprobe.LimitBlackhole:
+		where := "on the path"
+		if result.LastHop != "" {
+			where = "after " + result.LastHop
+		}
+		fmt.Fprintln(w, styler.Caution(fmt.Sprintf(
+			"Larger packets are silently dropped %s, which breaks path MTU discovery", where)))
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/naming"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+// namingVars returns the values of the naming template placeholders at now. The instance identity is only fetched
+// from IMDS when one of the templates uses it, so plain paths keep working without IMDS.
+func namingVars(ctx context.Context, now time.Time, templates ...string) (naming.Vars, error) {
+	vars := naming.Merge(naming.TimeVars(now), naming.Vars{naming.RunID: contextual.RunID(ctx)})
+	if hostname, err := os.Hostname(); err == nil {
+		vars[naming.Hostname] = hostname
+	}
+
+	if naming.NeedsIdentity(templates...) {
+		cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+		if err != nil {
+			return nil, err
+		}
+		identity, err := instance.CachedIdentity(ctx, imds.NewFromConfig(cfg))
+		if err != nil {
+			return nil, err
+		}
+		vars = naming.Merge(vars, naming.IdentityVars(identity.InstanceIdentityDocument))
+	}
+
+	return vars, nil
+}
+
+// placeholderVars returns stand-in values for every placeholder, used to validate templates before the real values
+// are known.
+func placeholderVars() naming.Vars {
+	vars := naming.Vars{}
+	for _, name := range []string{
+		naming.InstanceID, naming.AZ, naming.Region, naming.AccountID, naming.InstanceType, naming.ImageID,
+		naming.Timestamp, naming.Date, naming.Hostname, naming.RunID, naming.Filename,
+	} {
+		vars[name] = name
+	}
+
+	return vars
+}
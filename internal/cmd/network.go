@@ -0,0 +1,669 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/netconfig"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+const (
+	// networkMTUDaemonLabel is the label of the launchd daemon that reapplies the MTU and tunables at boot.
+	networkMTUDaemonLabel = "com.amazon.ec2.macos-utils.network-mtu"
+	// networkENIDaemonLabel is the label of the launchd daemon that configures the network interfaces.
+	networkENIDaemonLabel = "com.amazon.ec2.macos-utils.network-eni"
+	// networkENIDaemonInterval is how often, in seconds, the daemon configures newly attached interfaces and addresses.
+	networkENIDaemonInterval = 300
+)
+
+// networkSetDNSArgs is a struct for holding all information passed into the network set-dns command.
+type networkSetDNSArgs struct {
+	services  []string
+	dns       netconfig.DNS
+	resolvers []string
+	check     bool
+	interval  time.Duration
+}
+
+// networkCommand creates a new command which groups network configuration utilities.
+func networkCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "network configuration utilities",
+		Long:  "utilities for configuring the network services of EC2 macOS instances",
+	}
+
+	cmd.AddCommand(
+		networkSetDNSCommand(),
+		networkSetMTUCommand(),
+		networkConfigureENICommand(),
+		networkSetProxyCommand(),
+		networkDHCPCommand(),
+	)
+
+	return cmd
+}
+
+// networkSetDNSCommand creates a new command which configures DNS servers, search domains, and per-domain resolvers.
+func networkSetDNSCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-dns",
+		Short: "configure DNS servers and search domains",
+		Long: strings.TrimSpace(`
+set-dns sets the DNS servers and search domains of the --service network
+services, every enabled service by default. Without --server or
+--search-domain, the service's setting is cleared so that the value
+from DHCP, i.e. the VPC resolver, is used.
+
+With --resolver domain=server[,server...], queries for the domain and
+its subdomains are forwarded to the servers instead, e.g. to resolve an
+on-premises domain through conditional forwarders while other queries
+go to the VPC resolver. Resolvers are written to ` + netconfig.ResolverDir + `;
+those previously written by set-dns that aren't given again are removed
+and files written by others are never changed.
+
+Only settings that differ from the desired configuration (drift) are
+changed, and each one is logged. With --check, drift is reported and
+the command fails without changing anything. The configuration is set
+once unless --interval is set, in which case drift is corrected at that
+interval until interrupted.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var args networkSetDNSArgs
+	cmd.Flags().StringSliceVar(&args.services, "service", nil, "network service to configure (default every enabled service)")
+	cmd.Flags().StringSliceVar(&args.dns.Servers, "server", nil, "DNS server address, in order of preference")
+	cmd.Flags().StringSliceVar(&args.dns.SearchDomains, "search-domain", nil, "search domain, in order of preference")
+	cmd.Flags().StringArrayVar(&args.resolvers, "resolver", nil, "forward a domain to servers, as domain=server[,server...] (repeatable)")
+	cmd.Flags().BoolVar(&args.check, "check", false, "report drift from the configuration without changing it")
+	cmd.Flags().DurationVar(&args.interval, "interval", 0, "correct drift repeatedly at this interval instead of once")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if args.interval < 0 {
+			return errors.New("interval cannot be negative")
+		}
+		if args.check && args.interval > 0 {
+			return errors.New("--check and --interval cannot be used together")
+		}
+		if err := args.dns.Validate(); err != nil {
+			return err
+		}
+		resolvers := make([]netconfig.Resolver, 0, len(args.resolvers))
+		for _, s := range args.resolvers {
+			r, err := netconfig.ParseResolver(s)
+			if err != nil {
+				return err
+			}
+			resolvers = append(resolvers, r)
+		}
+
+		if args.check {
+			drift, err := dnsDrift(cmd.Context(), args.services, args.dns, resolvers)
+			if err != nil {
+				return err
+			}
+			for _, d := range drift {
+				fmt.Fprintln(cmd.OutOrStdout(), d)
+			}
+			if len(drift) > 0 {
+				return fmt.Errorf("DNS configuration has drifted in %d settings", len(drift))
+			}
+			return nil
+		}
+
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return runNetworkSetDNS(ctx, args, resolvers)
+	}
+
+	return cmd
+}
+
+func runNetworkSetDNS(ctx context.Context, args networkSetDNSArgs, resolvers []netconfig.Resolver) error {
+	set := func() error {
+		return setDNS(ctx, args.services, args.dns, resolvers)
+	}
+	if args.interval == 0 {
+		return set()
+	}
+
+	logrus.WithField("interval", args.interval).Info("Correcting DNS configuration drift")
+
+	ticker := time.NewTicker(args.interval)
+	defer ticker.Stop()
+	for {
+		if err := set(); err != nil && ctx.Err() == nil {
+			logrus.WithError(err).Warn("Failed to set DNS configuration, will retry")
+		}
+
+		select {
+		case <-ctx.Done():
+			logrus.Info("Stopped correcting DNS configuration drift")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// dnsDriftItem is a DNS setting that differs from the desired configuration.
+type dnsDriftItem struct {
+	// service is the drifted network service, or empty for the resolvers.
+	service string
+	current interface{}
+	desired interface{}
+}
+
+// String describes the drift.
+func (d dnsDriftItem) String() string {
+	if d.service == "" {
+		return fmt.Sprintf("resolvers: %v, want %v", d.current, d.desired)
+	}
+	return fmt.Sprintf("%s: %+v, want %+v", d.service, d.current, d.desired)
+}
+
+// dnsDrift returns the settings of the services and the resolvers that differ from the desired configuration.
+func dnsDrift(ctx context.Context, services []string, dns netconfig.DNS, resolvers []netconfig.Resolver) ([]dnsDriftItem, error) {
+	if len(services) == 0 {
+		var err error
+		if services, err = netconfig.Services(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var drift []dnsDriftItem
+	for _, service := range services {
+		current, err := netconfig.GetDNS(ctx, service)
+		if err != nil {
+			return nil, err
+		}
+		if !current.Equal(dns) {
+			drift = append(drift, dnsDriftItem{service: service, current: current, desired: dns})
+		}
+	}
+
+	current, err := netconfig.Resolvers(netconfig.ResolverDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read resolvers: %w", err)
+	}
+	if !equalResolvers(current, resolvers) {
+		drift = append(drift, dnsDriftItem{current: current, desired: resolvers})
+	}
+
+	return drift, nil
+}
+
+// setDNS corrects the drifted settings of the services and the resolvers.
+func setDNS(ctx context.Context, services []string, dns netconfig.DNS, resolvers []netconfig.Resolver) error {
+	drift, err := dnsDrift(ctx, services, dns, resolvers)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range drift {
+		if d.service == "" {
+			err = netconfig.SetResolvers(netconfig.ResolverDir, resolvers)
+		} else {
+			err = netconfig.SetDNS(ctx, d.service, dns)
+		}
+		if err != nil {
+			return err
+		}
+		logrus.WithField("drift", d.String()).Info("Corrected DNS configuration")
+	}
+	if len(drift) == 0 {
+		logrus.Debug("DNS configuration has not drifted")
+	}
+
+	return nil
+}
+
+// equalResolvers returns whether the resolvers have the same domains and servers, ignoring the order of domains.
+func equalResolvers(a, b []netconfig.Resolver) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	servers := make(map[string]string, len(a))
+	for _, r := range a {
+		servers[r.Domain] = strings.Join(r.Servers, ",")
+	}
+	for _, r := range b {
+		if s, ok := servers[r.Domain]; !ok || s != strings.Join(r.Servers, ",") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// networkSetMTUCommand creates a new command which sets the MTU and related tunables of the primary interface.
+func networkSetMTUCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-mtu",
+		Short: "set the MTU and related tunables of the primary interface",
+		Long: strings.TrimSpace(`
+set-mtu sets the MTU of the --device network device, the device of the
+default route by default. The MTU must be supported by both the VPC
+(` + fmt.Sprint(netconfig.MinMTU) + `-` + fmt.Sprint(netconfig.VPCMaxMTU) + `) and the device.
+
+Jumbo frames (MTUs above ` + fmt.Sprint(netconfig.InternetMTU) + `) are only carried within the VPC
+and peered VPCs in the same region. Traffic through an internet
+gateway, VPN connection, or inter-region peering connection relies on
+path MTU discovery, and connections hang when the ICMP messages it needs
+are blocked. Turning --pmtud-blackhole-detection on works around such
+paths by lowering the segment size of connections that stall.
+
+Related tunables are turned on or off with their flags:
+
+  --pmtud-blackhole-detection  lower the TCP segment size when large
+                               segments are silently dropped
+  --tso                        offload TCP segmentation to the device
+
+Without an MTU or tunable, the current settings are printed. The MTU is
+kept in the network preferences but tunables are reset at restart, so
+--persist installs a launchd daemon that applies both at boot.
+
+Changing settings requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		device  string
+		mtu     int
+		persist bool
+	)
+	cmd.Flags().StringVar(&device, "device", "", "network device to configure, e.g. en0 (default the device of the default route)")
+	cmd.Flags().IntVar(&mtu, "mtu", 0, "MTU to set")
+	cmd.Flags().BoolVar(&persist, "persist", false, "apply the settings at every boot")
+	tunables := map[string]*string{}
+	for _, t := range netconfig.Tunables {
+		tunables[t.Name] = cmd.Flags().String(t.Name, "", t.Description+" (on or off)")
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		changes := map[string]bool{}
+		for name, value := range tunables {
+			if !cmd.Flags().Changed(name) {
+				continue
+			}
+			switch *value {
+			case "on":
+				changes[name] = true
+			case "off":
+				changes[name] = false
+			default:
+				return fmt.Errorf("invalid --%s %q, expected on or off", name, *value)
+			}
+		}
+
+		ctx := cmd.Context()
+		if device == "" {
+			var err error
+			if device, err = netconfig.DefaultDevice(ctx); err != nil {
+				return err
+			}
+		}
+		current, err := netconfig.GetMTU(ctx, device)
+		if err != nil {
+			return err
+		}
+
+		if mtu == 0 && len(changes) == 0 {
+			if persist {
+				return errors.New("--persist requires --mtu or a tunable")
+			}
+			return printNetworkMTU(cmd, device, current)
+		}
+		if mtu != 0 {
+			if err := current.Validate(mtu); err != nil {
+				return err
+			}
+		}
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+
+		if mtu != 0 && mtu != current.Active {
+			if err := netconfig.SetMTU(ctx, device, mtu); err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{
+				"device":   device,
+				"mtu":      mtu,
+				"previous": current.Active,
+			}).Info("Set MTU")
+			if mtu > netconfig.InternetMTU {
+				logrus.Warnf("Traffic leaving the VPC is limited to an MTU of %d and relies on path MTU discovery", netconfig.InternetMTU)
+			}
+		}
+		for _, t := range netconfig.Tunables {
+			enabled, ok := changes[t.Name]
+			if !ok {
+				continue
+			}
+			if err := t.Write(ctx, enabled); err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{
+				"tunable": t.Name,
+				"enabled": enabled,
+			}).Info("Set network tunable")
+		}
+
+		if !persist {
+			return nil
+		}
+		path, err := installNetworkMTUDaemon(cmd, device, mtu, changes)
+		if err != nil {
+			return err
+		}
+		logrus.WithField("path", path).Info("Installed daemon to apply network settings at boot")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// printNetworkMTU writes the device's MTU and the tunables as a table.
+func printNetworkMTU(cmd *cobra.Command, device string, mtu netconfig.MTU) error {
+	styler := contextual.Styler(cmd.Context())
+	table := output.NewTable(styler, "setting", "value")
+	table.AddRow("device", device)
+	table.AddRow("mtu", fmt.Sprint(mtu.Active))
+	table.AddRow("supported mtu", fmt.Sprintf("%d-%d", mtu.Min, mtu.Max))
+	for _, t := range netconfig.Tunables {
+		enabled, err := t.Read(cmd.Context())
+		if err != nil {
+			return err
+		}
+		state := styler.Caution("off")
+		if enabled {
+			state = styler.Good("on")
+		}
+		table.AddRow(t.Name, state)
+	}
+
+	return table.Render(cmd.OutOrStdout())
+}
+
+// installNetworkMTUDaemon installs the launchd daemon that applies the settings at boot.
+func installNetworkMTUDaemon(cmd *cobra.Command, device string, mtu int, tunables map[string]bool) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot find executable: %w", err)
+	}
+
+	args := []string{exe, "network", "set-mtu", "--device", device}
+	if mtu != 0 {
+		args = append(args, "--mtu", fmt.Sprint(mtu))
+	}
+	for _, t := range netconfig.Tunables {
+		if enabled, ok := tunables[t.Name]; ok {
+			value := "off"
+			if enabled {
+				value = "on"
+			}
+			args = append(args, "--"+t.Name, value)
+		}
+	}
+
+	return launchd.Install(cmd.Context(), launchd.Spec{
+		Kind:             launchd.Daemon,
+		Label:            networkMTUDaemonLabel,
+		ProgramArguments: args,
+		RunAtLoad:        true,
+	})
+}
+
+// networkConfigureENICommand creates a new command which configures the addresses of the attached network interfaces.
+func networkConfigureENICommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure-eni",
+		Short: "configure secondary network interfaces and private IP addresses",
+		Long: strings.TrimSpace(`
+configure-eni reads the network interfaces (ENIs) attached to the
+instance and their private IPv4 addresses from IMDS and adds the
+addresses missing from the matching macOS network devices, found by MAC
+address, as aliases.
+
+The first address of a device in its subnet gets the subnet's netmask,
+which routes the subnet through the device, and the other addresses a
+host netmask. The default route stays on the primary interface.
+Addresses that are already configured, e.g. by DHCP, aren't changed.
+
+Aliases are removed at restart. With --persist, a launchd daemon is
+installed that configures the interfaces at boot and every 5 minutes, so
+that interfaces and addresses attached later are configured too. With
+--dry-run, the ifconfig commands are printed instead of run.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var dryRun, persist bool
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the ifconfig commands instead of running them")
+	cmd.Flags().BoolVar(&persist, "persist", false, "configure the interfaces at boot and periodically")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if dryRun && persist {
+			return errors.New("--dry-run and --persist cannot be used together")
+		}
+		if !dryRun {
+			if err := assertRootPrivileges(cmd, nil); err != nil {
+				return err
+			}
+		}
+
+		aliases, err := planENIAliases(cmd.Context())
+		if err != nil {
+			return err
+		}
+		for _, alias := range aliases {
+			if dryRun {
+				fmt.Fprintln(cmd.OutOrStdout(), strings.Join(alias.Args(), " "))
+				continue
+			}
+			if err := netconfig.AddAlias(cmd.Context(), alias); err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{
+				"device":  alias.Device,
+				"address": alias.Address,
+				"netmask": alias.Netmask,
+			}).Info("Added address")
+		}
+		if len(aliases) == 0 {
+			logrus.Info("Network interfaces are already configured")
+		}
+
+		if !persist {
+			return nil
+		}
+		path, err := installNetworkENIDaemon(cmd)
+		if err != nil {
+			return err
+		}
+		logrus.WithField("path", path).Info("Installed daemon to configure network interfaces")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// planENIAliases returns the aliases that add the attached interfaces' missing addresses to their devices.
+func planENIAliases(ctx context.Context) ([]netconfig.Alias, error) {
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return nil, err
+	}
+	interfaces, err := instance.NetworkInterfaces(ctx, imds.NewFromConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+	devices, err := netconfig.HardwareDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases []netconfig.Alias
+	for _, iface := range interfaces {
+		device, ok := devices[strings.ToLower(iface.MAC)]
+		if !ok {
+			// A newly attached interface can take a moment to appear.
+			logrus.WithFields(logrus.Fields{
+				"interface": iface.ID,
+				"mac":       iface.MAC,
+			}).Warn("No network device found for interface, skipping")
+			continue
+		}
+		existing, err := netconfig.Addresses(ctx, device)
+		if err != nil {
+			return nil, err
+		}
+		planned, err := netconfig.PlanAliases(device, iface.SubnetCIDR, iface.PrivateIPv4s, existing)
+		if err != nil {
+			return nil, fmt.Errorf("cannot configure %s: %w", iface.ID, err)
+		}
+		aliases = append(aliases, planned...)
+	}
+
+	return aliases, nil
+}
+
+// installNetworkENIDaemon installs the launchd daemon that configures the interfaces at boot and periodically.
+func installNetworkENIDaemon(cmd *cobra.Command) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot find executable: %w", err)
+	}
+
+	return launchd.Install(cmd.Context(), launchd.Spec{
+		Kind:             launchd.Daemon,
+		Label:            networkENIDaemonLabel,
+		ProgramArguments: []string{exe, "network", "configure-eni"},
+		RunAtLoad:        true,
+		StartInterval:    networkENIDaemonInterval,
+	})
+}
+
+// networkSetProxyCommand creates a new command which configures the system-wide proxies.
+func networkSetProxyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-proxy",
+		Short: "configure HTTP, HTTPS, and SOCKS proxies",
+		Long: strings.TrimSpace(`
+set-proxy sets the HTTP, HTTPS, and SOCKS proxies and the proxy bypass
+list of the --service network services, every enabled service by
+default. Proxies that aren't given are turned off, so running set-proxy
+without any clears the configuration.
+
+The bypass list defaults to the macOS default, which keeps IMDS and the
+Amazon Time Sync Service (in 169.254/16) reachable directly.
+
+Command line tools don't read the system proxy settings. With
+--env-file, a shell snippet exporting http_proxy, https_proxy,
+all_proxy, and no_proxy (and their uppercase forms) is also written to
+the file, e.g. to be sourced from /etc/zshrc. With --print-env, the
+snippet is printed and nothing is changed.
+
+Changing settings requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		services                          []string
+		httpProxy, httpsProxy, socksProxy string
+		bypass                            []string
+		envFile                           string
+		printEnv                          bool
+	)
+	cmd.Flags().StringSliceVar(&services, "service", nil, "network service to configure (default every enabled service)")
+	cmd.Flags().StringVar(&httpProxy, "http", "", "HTTP proxy, as host:port")
+	cmd.Flags().StringVar(&httpsProxy, "https", "", "HTTPS proxy, as host:port")
+	cmd.Flags().StringVar(&socksProxy, "socks", "", "SOCKS proxy, as host:port")
+	cmd.Flags().StringSliceVar(&bypass, "bypass", []string{"*.local", "169.254/16"}, "hosts and domains to connect to directly")
+	cmd.Flags().StringVar(&envFile, "env-file", "", "also write a shell snippet exporting the proxy environment variables to this file")
+	cmd.Flags().BoolVar(&printEnv, "print-env", false, "print the shell snippet instead of configuring the proxies")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		proxies := netconfig.Proxies{Bypass: bypass}
+		for _, p := range []struct {
+			value string
+			proxy **netconfig.Proxy
+		}{
+			{httpProxy, &proxies.HTTP},
+			{httpsProxy, &proxies.HTTPS},
+			{socksProxy, &proxies.SOCKS},
+		} {
+			if p.value == "" {
+				continue
+			}
+			proxy, err := netconfig.ParseProxy(p.value)
+			if err != nil {
+				return err
+			}
+			*p.proxy = &proxy
+		}
+
+		if printEnv {
+			fmt.Fprint(cmd.OutOrStdout(), netconfig.EnvSnippet(proxies))
+			return nil
+		}
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		if len(services) == 0 {
+			var err error
+			if services, err = netconfig.Services(ctx); err != nil {
+				return err
+			}
+		}
+		for _, service := range services {
+			if err := netconfig.SetProxies(ctx, service, proxies); err != nil {
+				return err
+			}
+			logrus.WithField("service", service).Info("Configured proxies")
+		}
+
+		if envFile == "" {
+			return nil
+		}
+		if err := os.WriteFile(envFile, []byte(netconfig.EnvSnippet(proxies)), 0644); err != nil {
+			return fmt.Errorf("cannot write proxy environment: %w", err)
+		}
+		logrus.WithField("path", envFile).Info("Wrote proxy environment")
+
+		return nil
+	}
+
+	return cmd
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/netconfig"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+const (
+	// networkDHCPDefaultRenewTimeout is how long renew waits for the renewed lease by default.
+	networkDHCPDefaultRenewTimeout = 30 * time.Second
+	// networkDHCPRenewPollInterval is how often renew checks whether the lease was renewed.
+	networkDHCPRenewPollInterval = time.Second
+)
+
+// networkDHCPCommand creates a new command which groups DHCP lease utilities.
+func networkDHCPCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dhcp",
+		Short: "inspect and renew DHCP leases",
+		Long:  "utilities for inspecting and renewing the DHCP lease of a network device",
+	}
+
+	cmd.AddCommand(
+		networkDHCPStatusCommand(),
+		networkDHCPRenewCommand(),
+	)
+
+	return cmd
+}
+
+// networkDHCPStatusCommand creates a new command which shows the DHCP lease of a network device.
+func networkDHCPStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show the DHCP lease",
+		Long: strings.TrimSpace(`
+status shows the DHCP lease of --device, the device of the default route
+by default: the leased address, the DHCP server that granted it, when it
+was granted and expires, and the options it carries, such as the router,
+DNS servers, and MTU. The lease is printed as a JSON object with --json.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+	}
+
+	var (
+		device string
+		asJSON bool
+	)
+	cmd.Flags().StringVar(&device, "device", "", "network device, e.g. en0 (default the device of the default route)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the lease as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		if device == "" {
+			var err error
+			if device, err = netconfig.DefaultDevice(ctx); err != nil {
+				return err
+			}
+		}
+
+		lease, err := netconfig.GetDHCPLease(ctx, device)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(cmd, lease)
+		}
+
+		return printDHCPLease(cmd, lease)
+	}
+
+	return cmd
+}
+
+// networkDHCPRenewCommand creates a new command which renews the DHCP lease of a network device.
+func networkDHCPRenewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "renew",
+		Short: "renew the DHCP lease",
+		Long: strings.TrimSpace(`
+renew forces --device, the device of the default route by default, to
+request its DHCP lease again, waits up to --timeout for the lease to be
+granted, and shows it as status does. The device's address may be
+briefly unavailable while it's renewed, so connections through it can
+be interrupted. The command fails if no new lease, granted after the
+renewal was requested, is seen within --timeout.
+
+It's a remediation for a lease whose options went stale, or for losing
+the route to IMDS and the rest of the VPC, e.g. in a runbook run after
+watchdog network-health-monitor reports a failure.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PreRunE:      assertRootPrivileges,
+	}
+
+	var (
+		device  string
+		timeout time.Duration
+		asJSON  bool
+	)
+	cmd.Flags().StringVar(&device, "device", "", "network device, e.g. en0 (default the device of the default route)")
+	cmd.Flags().DurationVar(&timeout, "timeout", networkDHCPDefaultRenewTimeout, "time to wait for the renewed lease")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the renewed lease as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		ctx := cmd.Context()
+		if device == "" {
+			var err error
+			if device, err = netconfig.DefaultDevice(ctx); err != nil {
+				return err
+			}
+		}
+
+		previous, err := netconfig.GetDHCPLease(ctx, device)
+		if err != nil {
+			logrus.WithError(err).Warn("Cannot read the lease before renewing it")
+		}
+		requested := time.Now()
+		if err := netconfig.RenewDHCPLease(ctx, device); err != nil {
+			return err
+		}
+		lease, err := waitForDHCPLease(ctx, device, previous, requested, timeout)
+		if err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"device":   device,
+			"address":  lease.Address,
+			"previous": previous.Address,
+			"server":   lease.Server,
+		}).Info("Renewed DHCP lease")
+
+		if asJSON {
+			return printJSON(cmd, lease)
+		}
+
+		return printDHCPLease(cmd, lease)
+	}
+
+	return cmd
+}
+
+// waitForDHCPLease waits up to timeout for the device to have a lease granted since requested, which replaced the
+// previous lease. It fails rather than returning a lease that can't be told apart from the previous one.
+func waitForDHCPLease(ctx context.Context, device string, previous netconfig.DHCPLease, requested time.Time, timeout time.Duration) (netconfig.DHCPLease, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(networkDHCPRenewPollInterval)
+	defer ticker.Stop()
+	for {
+		lease, err := netconfig.GetDHCPLease(ctx, device)
+		if err == nil && dhcpLeaseRenewed(lease, previous, requested) {
+			return lease, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return lease, fmt.Errorf("no lease was granted to %s within %v", device, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// dhcpLeaseRenewed reports whether the lease was granted since requested, when the DHCP client saved when it was
+// granted, or otherwise whether it was granted by a different exchange than the previous lease.
+func dhcpLeaseRenewed(lease, previous netconfig.DHCPLease, requested time.Time) bool {
+	if lease.Start != nil {
+		// Lease start times are saved to the second.
+		return !lease.Start.Before(requested.Truncate(time.Second))
+	}
+
+	return lease.TransactionID != "" && previous.TransactionID != "" && lease.TransactionID != previous.TransactionID
+}
+
+// printDHCPLease prints the lease as a table, followed by a table of its options.
+func printDHCPLease(cmd *cobra.Command, lease netconfig.DHCPLease) error {
+	w := cmd.OutOrStdout()
+	styler := contextual.Styler(cmd.Context())
+
+	length := "infinite"
+	if lease.LeaseSeconds > 0 {
+		length = (time.Duration(lease.LeaseSeconds) * time.Second).String()
+	}
+	granted, expires := "unknown", "unknown"
+	if lease.Start != nil {
+		granted = lease.Start.Local().Format(time.RFC3339)
+	}
+	if lease.Expires != nil {
+		expires = lease.Expires.Local().Format(time.RFC3339)
+		if remaining := time.Until(*lease.Expires); remaining > 0 {
+			expires += styler.Good(fmt.Sprintf(" (in %s)", remaining.Round(time.Second)))
+		} else {
+			expires += styler.Caution(" (expired)")
+		}
+	} else if lease.LeaseSeconds == 0 {
+		expires = "never"
+	}
+
+	table := output.NewTable(styler, "lease", "value")
+	table.AddRow("device", lease.Device)
+	table.AddRow("address", lease.Address)
+	table.AddRow("server", lease.Server)
+	table.AddRow("length", length)
+	table.AddRow("granted", granted)
+	table.AddRow("expires", expires)
+	if err := table.Render(w); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	options := output.NewTable(styler, "option", "value")
+	for _, o := range lease.Options {
+		options.AddRow(o.Name, o.Value)
+	}
+
+	return options.Render(w)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/netconfig"
+)
+
+func TestDHCPLeaseRenewed(t *testing.T) {
+	requested := time.Date(2026, 10, 16, 12, 0, 0, 500, time.UTC)
+	before, after := requested.Add(-time.Minute), requested.Truncate(time.Second)
+	previous := netconfig.DHCPLease{Address: "10.0.0.5", TransactionID: "0x1"}
+
+	assert.True(t, dhcpLeaseRenewed(netconfig.DHCPLease{Start: &after}, previous, requested))
+	assert.False(t, dhcpLeaseRenewed(netconfig.DHCPLease{Start: &before, TransactionID: "0x2"}, previous, requested),
+		"a lease granted before the renewal was requested is stale")
+	assert.True(t, dhcpLeaseRenewed(netconfig.DHCPLease{TransactionID: "0x2"}, previous, requested))
+	assert.False(t, dhcpLeaseRenewed(netconfig.DHCPLease{TransactionID: "0x1"}, previous, requested),
+		"the previous packet isn't a renewed lease")
+	assert.False(t, dhcpLeaseRenewed(netconfig.DHCPLease{TransactionID: "0x2"}, netconfig.DHCPLease{}, requested),
+		"a lease can't be told apart from an unknown previous lease")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,13 +13,22 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
-	"github.com/aws/ec2-macos-utils/internal/system"
+	"github.com/aws/ec2-macos-utils/internal/naming"
+	"github.com/aws/ec2-macos-utils/internal/netsnapshot"
+	"github.com/aws/ec2-macos-utils/internal/notify"
+	"github.com/aws/ec2-macos-utils/internal/privsep"
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
+	"github.com/aws/ec2-macos-utils/pkg/system"
 )
 
 const (
 	networkMonitorDefaultInterval      = 5 * time.Minute
 	networkMonitorDefaultStartupDelay  = 5 * time.Minute
 	networkMonitorDefaultOutputBaseDir = "/private/var/db/ec2-macos-utils/sysdiagnose"
+
+	// networkMonitorWatchdog names the monitor's captures in the state.
+	networkMonitorWatchdog = "network-health-monitor"
 )
 
 type networkHealthMonitorArgs struct {
@@ -26,6 +36,17 @@ type networkHealthMonitorArgs struct {
 	startupDelay       time.Duration
 	outputDir          string
 	sysdiagnoseTimeout time.Duration
+	// captureID identifies the monitor's capture in the state, so it's captured once per host.
+	captureID  string
+	upload     uploadArgs
+	asgHealth  asgHealthArgs
+	notify     notifyArgs
+	privileges privilegeArgs
+	archive    archivePermissionArgs
+	// perms are the ownership and mode of the captures, resolved from archive.
+	perms archivePermissions
+	// captureLimiter limits how often a sysdiagnose is collected, along with the other tasks of a daemon.
+	captureLimiter *scheduler.Limiter
 }
 
 func newNetworkHealthMonitorCommand() *cobra.Command {
@@ -35,6 +56,16 @@ func newNetworkHealthMonitorCommand() *cobra.Command {
 		Long: strings.TrimSpace(`
 monitor network health with periodic checks.
 A sysdiagnose will be collected on first failure, after which the monitor will exit.
+Before it, a snapshot of the TCP connections, their socket buffers, and the error counters of the
+network devices is logged and saved next to the archive, since that state is often gone by the
+time the sysdiagnose is collected.
+With --upload, the sysdiagnose is also uploaded to S3, with its manifest and the snapshot.
+With --report-asg-health, the failure is also reported to the instance's Auto Scaling group.
+With --notify eventbridge, the failure is published to EventBridge as an "imds check" event.
+With --notify notification-center, it's posted as a notification to the user logged in on the console.
+With --run-as, the checks run as the given service user once the monitor has started, and root
+privileges are regained to collect the sysdiagnose and report the failure. The process keeps root
+as its real user, so --run-as isn't a security boundary.
 
 This command requires root privileges. Run with sudo if not running as root.
         `),
@@ -43,8 +74,13 @@ This command requires root privileges. Run with sudo if not running as root.
 	var args networkHealthMonitorArgs
 	cmd.Flags().DurationVar(&args.interval, "interval", networkMonitorDefaultInterval, "interval between network checks")
 	cmd.Flags().DurationVar(&args.startupDelay, "startup-delay", networkMonitorDefaultStartupDelay, "delay before starting checks")
-	cmd.Flags().StringVar(&args.outputDir, "output-base-dir", networkMonitorDefaultOutputBaseDir, "base directory for sysdiagnose output")
+	cmd.Flags().StringVar(&args.outputDir, "output-base-dir", networkMonitorDefaultOutputBaseDir, "base directory for sysdiagnose output, can be a naming template (e.g. /var/db/diag/{instance-id})")
 	cmd.Flags().DurationVar(&args.sysdiagnoseTimeout, "sysdiagnose-timeout", sysdiagnoseDefaultTimeout, "timeout for sysdiagnose collection")
+	args.upload.addFlags(cmd.Flags())
+	args.asgHealth.addFlags(cmd.Flags())
+	args.notify.addFlags(cmd.Flags())
+	args.privileges.addFlags(cmd.Flags())
+	args.archive.addFlags(cmd.Flags())
 
 	cmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
 		if os.Geteuid() != 0 {
@@ -63,99 +99,252 @@ This command requires root privileges. Run with sudo if not running as root.
 			return fmt.Errorf("timeout must be at least %v to ensure creation can complete", sysdiagnoseMinTimeout)
 		}
 
-		return nil
-	}
-
-	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
-		// Get collection prefix for potential use later
-		prefix, err := getCollectionPrefix()
-		if err != nil {
-			logrus.WithError(err).Warn("Failed to get prefix, using 'unknown'")
-			prefix = "unknown"
+		if _, err := naming.Expand(args.outputDir, placeholderVars()); err != nil {
+			return err
 		}
 
-		// Create only the base output directory
-		if err := os.MkdirAll(args.outputDir, 0700); err != nil {
-			return fmt.Errorf("base output directory creation: %w", err)
+		var err error
+		if args.perms, err = args.archive.resolve(); err != nil {
+			return err
+		}
+		if err := args.upload.validate(); err != nil {
+			return err
 		}
 
-		// Check if sysdiagnose already exists in the prefix directory
-		prefixDir := filepath.Join(args.outputDir, prefix)
-		existing, err := filepath.Glob(filepath.Join(prefixDir, "sysdiagnose_*.tar.gz"))
-		if err != nil {
-			return fmt.Errorf("invalid glob pattern: %w", err)
+		return args.notify.validate()
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		done, err := prepareNetworkHealthMonitor(cmd.Context(), &args)
+		if err != nil || done {
+			return err
 		}
-		if len(existing) > 0 {
-			logrus.Warn("Monitor already captured sysdiagnose for failure, stopping watchdog")
-			return nil
+		if err := args.privileges.drop(); err != nil {
+			return err
 		}
 
-		// Set the final output directory
-		args.outputDir = prefixDir
-
 		return runNetworkHealthMonitor(cmd.Context(), args)
 	}
 
 	return cmd
 }
 
-func runNetworkHealthMonitor(ctx context.Context, args networkHealthMonitorArgs) error {
-	logrus.WithField("delay", args.startupDelay).Info("Waiting before starting network checks")
+// prepareNetworkHealthMonitor resolves the monitor's output directory and capture ID for the host, and reports whether
+// it already captured a sysdiagnose, in which case it shouldn't run.
+func prepareNetworkHealthMonitor(ctx context.Context, args *networkHealthMonitorArgs) (bool, error) {
+	// Get collection prefix for potential use later
+	prefix, err := getCollectionPrefix()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get prefix, using 'unknown'")
+		prefix = "unknown"
+	}
 
-	// Handle startup delay
-	select {
-	case <-time.After(args.startupDelay):
-	case <-ctx.Done():
-		return ctx.Err()
+	vars, err := namingVars(ctx, time.Now(), args.outputDir)
+	if err != nil {
+		return false, fmt.Errorf("cannot resolve naming template placeholders: %w", err)
+	}
+	if args.outputDir, err = naming.Expand(args.outputDir, vars); err != nil {
+		return false, err
 	}
 
-	timer := time.NewTimer(args.interval)
-	defer timer.Stop()
+	// Create only the base output directory
+	if err := args.perms.mkdir(args.outputDir); err != nil {
+		return false, fmt.Errorf("base output directory creation: %w", err)
+	}
+
+	// Check if sysdiagnose was already captured for the host
+	prefixDir := filepath.Join(args.outputDir, prefix)
+	done, err := captured(networkMonitorWatchdog, prefix, prefixDir)
+	if err != nil {
+		return false, err
+	}
+	if done {
+		logrus.Warn("Monitor already captured sysdiagnose for failure, stopping watchdog")
+		return true, nil
+	}
+
+	// Set the final output directory
+	args.outputDir = prefixDir
+	args.captureID = prefix
 
-	logrus.WithField("interval", args.interval).Info("Starting network health monitoring")
+	return false, nil
+}
 
+func runNetworkHealthMonitor(ctx context.Context, args networkHealthMonitorArgs) error {
+	logrus.WithFields(logrus.Fields{
+		"delay":    args.startupDelay,
+		"interval": args.interval,
+	}).Info("Starting network health monitoring")
+
+	return scheduler.Run(ctx, networkHealthTask(args))
+}
+
+// networkHealthTask returns the task that checks network health at the interval, once the startup delay and a first
+// interval have passed, and stops once it collected a sysdiagnose for a failure. It skips its checks while the watchdogs
+// are paused.
+func networkHealthTask(args networkHealthMonitorArgs) scheduler.Task {
 	sysdiagnoseCollectionArgs := sysdiagnoseArgs{
 		outputDir: args.outputDir,
 		timeout:   args.sysdiagnoseTimeout,
+		upload:    args.upload,
+		limiter:   args.captureLimiter,
+		perms:     args.perms,
+		reason:    "IMDS connectivity check failed",
+		trigger:   map[string]string{"watchdog": networkMonitorWatchdog, "check": "imds"},
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-timer.C:
-			sysdiagnoseCollected, err := checkNetworkAndCollect(ctx, sysdiagnoseCollectionArgs)
-			timer.Reset(args.interval)
+	return pausable(scheduler.Task{
+		Name:     networkMonitorWatchdog,
+		Delay:    args.startupDelay + args.interval,
+		Interval: args.interval,
+		Run: func(ctx context.Context) error {
+			cycleCtx, span := tracing.Start(ctx, "network health check cycle", nil)
+			outputPath, err := checkNetworkAndCollect(cycleCtx, sysdiagnoseCollectionArgs, args.captureID)
+			span.Finish(err)
+			flushSpans(ctx)
+			sysdiagnoseCollected := outputPath != ""
+
+			// The failure is reported whether or not the diagnostics could be collected.
+			if sysdiagnoseCollected || err != nil {
+				var capture *notify.Capture
+				if sysdiagnoseCollected {
+					capture = &notify.Capture{
+						Path:    outputPath,
+						Reason:  sysdiagnoseCollectionArgs.reason,
+						Trigger: sysdiagnoseCollectionArgs.trigger,
+					}
+				}
+				report := func() error { reportNetworkFailure(ctx, args, capture); return nil }
+				if err := privsep.Privileged(report); err != nil {
+					logrus.WithError(err).Error("Failed to report check failure")
+				}
+			}
 
 			if err != nil {
-				logrus.WithError(err).Error("Sysdiagnose collection failed")
-				continue
+				return fmt.Errorf("sysdiagnose collection failed: %w", err)
 			}
 			if sysdiagnoseCollected {
 				logrus.Info("Sysdiagnose collected, stopping watchdog")
-				return nil
+				return scheduler.ErrStop
 			}
-		}
+
+			return nil
+		},
+	})
+}
+
+// reportNetworkFailure reports the failed check to Auto Scaling and the notification backends, with the sysdiagnose
+// collected for it if any, logging their failures.
+func reportNetworkFailure(ctx context.Context, args networkHealthMonitorArgs, capture *notify.Capture) {
+	if err := args.asgHealth.reportHealth(ctx, false); err != nil {
+		logrus.WithError(err).Error("Failed to report health to Auto Scaling")
+	}
+	event := notify.NewEvent("imds", errors.New("IMDS connectivity check failed"))
+	event.Capture = capture
+	if err := args.notify.notify(ctx, event); err != nil {
+		logrus.WithError(err).Error("Failed to publish check failure")
 	}
 }
 
-func checkNetworkAndCollect(ctx context.Context, sysArgs sysdiagnoseArgs) (bool, error) {
-	if err := runCheckIMDS(ctx); err != nil {
+// checkNetworkAndCollect checks IMDS connectivity and collects a sysdiagnose when it fails, uploading it with uploads
+// enabled, and returns the archive's path, which is empty when none was collected.
+func checkNetworkAndCollect(ctx context.Context, sysArgs sysdiagnoseArgs, captureID string) (string, error) {
+	if err := countCheck("imds", runCheckIMDS(ctx)); err != nil {
 		logrus.WithError(err).Warn("IMDS check failed, collecting sysdiagnose")
+		snapshot := captureNetworkSnapshot(ctx)
 
-		// Create the directory before collecting sysdiagnose
-		if err := os.MkdirAll(sysArgs.outputDir, 0700); err != nil {
-			return false, fmt.Errorf("sysdiagnose output directory creation: %w", err)
+		var outputPath string
+		err := privsep.Privileged(func() error {
+			// Create the directory before collecting sysdiagnose
+			if err := sysArgs.perms.mkdir(sysArgs.outputDir); err != nil {
+				return fmt.Errorf("sysdiagnose output directory creation: %w", err)
+			}
+			if name, err := saveNetworkSnapshot(snapshot, sysArgs); err != nil {
+				logrus.WithError(err).Warn("Failed to save network snapshot")
+			} else {
+				sysArgs.attachments = append(sysArgs.attachments, name)
+			}
+
+			start := time.Now()
+			var err error
+			outputPath, err = runSysdiagnose(ctx, sysArgs)
+			recordAction(ctx, "watchdog network-health-monitor", "sysdiagnose collection after IMDS check failure", start, err)
+			if err != nil {
+				return fmt.Errorf("sysdiagnose collection: %w", err)
+			}
+			recordCapture(networkMonitorWatchdog, captureID, outputPath)
+
+			// The sysdiagnose was collected whether or not it's uploaded, so failing to upload it is only logged.
+			if err := uploadNetworkCapture(ctx, sysArgs, outputPath); err != nil {
+				logrus.WithError(err).Error("Failed to upload sysdiagnose")
+			}
+
+			return nil
+		})
+		if err != nil {
+			return "", err
 		}
 
-		if err := runSysdiagnose(ctx, sysArgs); err != nil {
-			return false, fmt.Errorf("sysdiagnose collection: %w", err)
+		return outputPath, nil
+	}
+
+	return "", nil
+}
+
+// uploadNetworkCapture uploads the sysdiagnose at outputPath, with its manifest and the network snapshot it lists,
+// when uploads are enabled.
+func uploadNetworkCapture(ctx context.Context, sysArgs sysdiagnoseArgs, outputPath string) error {
+	if !sysArgs.upload.enabled() {
+		return nil
+	}
+	vars, err := namingVars(ctx, time.Now(), sysArgs.upload.key)
+	if err != nil {
+		return fmt.Errorf("cannot resolve naming template placeholders: %w", err)
+	}
+	upload := sysArgs.upload
+	upload.metadata = sysArgs.metadata()
+
+	return uploadSysdiagnose(ctx, upload, vars, outputPath)
+}
+
+// captureNetworkSnapshot takes a snapshot of the network stack and logs what stands out in it, so that the connections
+// and error counters at the time of a failure are kept even if the sysdiagnose collected afterwards isn't.
+func captureNetworkSnapshot(ctx context.Context) netsnapshot.Snapshot {
+	snapshot := netsnapshot.Capture(ctx)
+	summary := snapshot.Summarize()
+	log := logrus.WithFields(logrus.Fields{
+		"connections":      len(snapshot.Connections),
+		"states":           summary.States,
+		"half_open":        summary.HalfOpen,
+		"queued":           summary.Queued,
+		"interface_errors": summary.InterfaceErrors,
+	})
+	for _, i := range snapshot.Interfaces {
+		if i.InErrors+i.OutErrors+i.Drops > 0 {
+			log = log.WithField("errors_"+i.Name, fmt.Sprintf("in=%d out=%d drops=%d", i.InErrors, i.OutErrors, i.Drops))
 		}
+	}
+	if len(snapshot.Errors) > 0 {
+		log = log.WithField("snapshot_errors", strings.Join(snapshot.Errors, "; "))
+	}
+	log.Warn("Captured network snapshot")
 
-		return true, nil
+	return snapshot
+}
+
+// saveNetworkSnapshot saves the snapshot in the output directory of the sysdiagnose it precedes, with the ownership
+// and mode of the archive, and returns its file name.
+func saveNetworkSnapshot(snapshot netsnapshot.Snapshot, sysArgs sysdiagnoseArgs) (string, error) {
+	name := fmt.Sprintf("network-snapshot_%s.json", snapshot.Time.Format(sysdiagnoseTimestampFormat))
+	path := filepath.Join(sysArgs.outputDir, name)
+	if err := sysArgs.perms.writeFile(path, func(w io.Writer) error {
+		return netsnapshot.Encode(w, snapshot)
+	}); err != nil {
+		return "", fmt.Errorf("failed to save network snapshot %s: %w", path, err)
 	}
+	logrus.WithField("path", path).Info("Saved network snapshot")
 
-	return false, nil
+	return name, nil
 }
 
 func getCollectionPrefix() (string, error) {
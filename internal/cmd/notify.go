@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/notify"
+)
+
+// --notify backends.
+const (
+	// notifyBackendEventBridge publishes events to EventBridge.
+	notifyBackendEventBridge = "eventbridge"
+	// notifyBackendNotificationCenter posts failed checks as user notifications to the console user.
+	notifyBackendNotificationCenter = "notification-center"
+)
+
+// notifyArgs is a struct for holding the flags that publish check results to notification backends.
+type notifyArgs struct {
+	backends []string
+	eventBus string
+}
+
+// addFlags registers the notification flags.
+func (a *notifyArgs) addFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&a.backends, "notify", nil, "publish check results to a backend (eventbridge, notification-center), can be repeated")
+	flags.StringVar(&a.eventBus, "event-bus", notify.DefaultEventBus, "name or ARN of the EventBridge event bus for --notify eventbridge")
+}
+
+// validate checks that every backend is known.
+func (a notifyArgs) validate() error {
+	for _, backend := range a.backends {
+		switch backend {
+		case notifyBackendEventBridge, notifyBackendNotificationCenter:
+		default:
+			return fmt.Errorf("unknown notification backend %q", backend)
+		}
+	}
+
+	return nil
+}
+
+// notify publishes the events to the configured backends, adding the instance and invocation they came from.
+func (a notifyArgs) notify(ctx context.Context, events ...notify.Event) error {
+	if len(a.backends) == 0 || len(events) == 0 {
+		return nil
+	}
+	if err := a.validate(); err != nil {
+		return err
+	}
+
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return err
+	}
+	instanceID, err := aws.InstanceID(ctx, cfg)
+	if err != nil {
+		// The events are still useful without the instance, e.g. when IMDS is what failed.
+		logrus.WithError(err).Warn("Failed to get instance ID for notifications")
+	}
+	for i := range events {
+		events[i].InstanceID = instanceID
+		events[i].RunID = contextual.RunID(ctx)
+		events[i].Version = build.Version
+	}
+
+	var notifiers notify.Multi
+	for _, backend := range a.backends {
+		switch backend {
+		case notifyBackendEventBridge:
+			notifiers = append(notifiers, &notify.EventBridge{
+				Client:   eventbridge.NewFromConfig(cfg),
+				EventBus: a.eventBus,
+			})
+		case notifyBackendNotificationCenter:
+			notifiers = append(notifiers, &notify.UserNotification{})
+		}
+	}
+
+	return notifiers.Notify(ctx, events...)
+}
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/actionlog"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+)
+
+// prepareImageHistoryFiles are the files and directories in home directories holding the history of shells and
+// interactive tools.
+var prepareImageHistoryFiles = []string{
+	".bash_history",
+	".bash_sessions",
+	".zsh_history",
+	".zsh_sessions",
+	".sh_history",
+	".lesshst",
+	".python_history",
+	".node_repl_history",
+	".viminfo",
+}
+
+// Categories of what prepare-image cleans.
+const (
+	prepareImageHistory  = "history"
+	prepareImageCache    = "cache"
+	prepareImageState    = "state"
+	prepareImageCaptures = "captures"
+	prepareImageLog      = "log"
+)
+
+// Actions of prepare-image.
+const (
+	// prepareImageRemove removes a file or directory.
+	prepareImageRemove = "remove"
+	// prepareImageEmpty removes the contents of a directory, keeping the directory.
+	prepareImageEmpty = "empty"
+	// prepareImageTruncate truncates a file, keeping the file.
+	prepareImageTruncate = "truncate"
+)
+
+// prepareImageLayout is where what prepare-image cleans is found.
+type prepareImageLayout struct {
+	// homePatterns match the home directories whose histories and caches are cleared.
+	homePatterns []string
+	// stateFiles are the utility's host-specific state, such as the state database with the watchdogs' capture
+	// markers, and cacheDirs its caches.
+	stateFiles []string
+	cacheDirs  []string
+	// captureDirs are the output base directories of the watchdogs, whose captures are removed.
+	captureDirs []string
+	// logPatterns match the logs that are truncated, and rotatedLogPatterns the rotated logs that are removed.
+	logPatterns        []string
+	rotatedLogPatterns []string
+}
+
+// defaultPrepareImageLayout returns where what prepare-image cleans is found by default.
+func defaultPrepareImageLayout() prepareImageLayout {
+	logDir := filepath.Dir(actionlog.DefaultPath)
+
+	return prepareImageLayout{
+		homePatterns:       []string{"/Users/*", "/var/root"},
+		stateFiles:         []string{statePath, imdsLatencyDefaultSamplesPath},
+		cacheDirs:          []string{uploadDefaultStateDir, logsShipDefaultStateDir, uninstallRunDir},
+		captureDirs:        []string{networkMonitorDefaultOutputBaseDir, scheduledEventsDefaultOutputBaseDir},
+		logPatterns:        []string{filepath.Join(logDir, "*.log"), "/var/log/ec2-macos-utils*.log"},
+		rotatedLogPatterns: []string{filepath.Join(logDir, "*.log.*"), "/var/log/ec2-macos-utils*.log.*"},
+	}
+}
+
+// prepareImageItem is something prepare-image cleans.
+type prepareImageItem struct {
+	Category string `json:"category"`
+	Action   string `json:"action"`
+	Path     string `json:"path"`
+	// Cleaned reports whether the item was cleaned, and is false in a dry run.
+	Cleaned bool   `json:"cleaned"`
+	Error   string `json:"error,omitempty"`
+
+	// home is the home directory the item is in, if any, below which every path component must be a directory and
+	// not a symlink, since the user could otherwise redirect the cleaning elsewhere.
+	home string
+}
+
+// prepareImageReport is the outcome of the prepare-image command.
+type prepareImageReport struct {
+	DryRun bool               `json:"dry_run"`
+	Items  []prepareImageItem `json:"items"`
+}
+
+// prepareImageCommand creates a new command which cleans the host before an AMI is created from it.
+func prepareImageCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prepare-image",
+		Short: "clean the host before creating an AMI",
+		Long: strings.TrimSpace(`
+prepare-image performs the standard cleanup of a Mac host before an AMI is
+created from it, so that instances launched from the AMI don't inherit its
+history or its identity:
+
+  history   the shell and interactive tool histories of every user,
+            e.g. ~/.zsh_history and ~/.zsh_sessions, including root's
+  cache     the contents of every user's ~/Library/Caches, and the
+            utility's upload and log shipping state and runtime files
+  state     the utility's state database, which holds the check history
+            and the watchdogs' capture markers, so that the watchdogs are
+            armed again on the new instances, and the IMDS latency samples
+  captures  the sysdiagnose archives captured by the watchdogs
+  log       the utility's logs, which are truncated, and its rotated logs,
+            which are removed
+
+The utility's launchd jobs, configuration, and policies are kept, unlike
+with uninstall. A report of what was cleaned is printed, as a JSON object
+with --json. With --dry-run, what would be cleaned is printed without
+cleaning it.
+
+Run it last, right before stopping the instance to create the AMI, since
+anything the host does afterwards is captured in the image.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:         cobra.NoArgs,
+		PreRunE:      assertRootPrivileges,
+		SilenceUsage: true,
+	}
+
+	var (
+		dryRun bool
+		asJSON bool
+	)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print what would be cleaned without cleaning it")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		items, err := planPrepareImage(defaultPrepareImageLayout())
+		if err != nil {
+			return err
+		}
+		report := prepareImageReport{DryRun: dryRun, Items: items}
+		if !dryRun {
+			runPrepareImage(report.Items)
+		}
+
+		if asJSON {
+			if err := printJSON(cmd, report); err != nil {
+				return err
+			}
+		} else {
+			printPrepareImageReport(cmd, report)
+		}
+
+		var failed int
+		for _, item := range report.Items {
+			if item.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to clean %d of %d items", failed, len(report.Items))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// printPrepareImageReport prints a line for each item of the report.
+func printPrepareImageReport(cmd *cobra.Command, report prepareImageReport) {
+	if len(report.Items) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "Nothing to clean")
+		return
+	}
+	styler := contextual.Styler(cmd.Context())
+	for _, item := range report.Items {
+		switch {
+		case item.Error != "":
+			fmt.Fprintf(cmd.OutOrStdout(), "%s  %s %s %s: %s\n", styler.Status(false), item.Action, item.Category, item.Path, item.Error)
+		case report.DryRun:
+			fmt.Fprintf(cmd.OutOrStdout(), "would %s  %s %s\n", item.Action, item.Category, item.Path)
+		default:
+			fmt.Fprintf(cmd.OutOrStdout(), "%s  %s %s %s\n", styler.Status(true), item.Action, item.Category, item.Path)
+		}
+	}
+}
+
+// planPrepareImage lists what's found in the layout to clean. Files that don't exist aren't listed.
+func planPrepareImage(layout prepareImageLayout) ([]prepareImageItem, error) {
+	var items []prepareImageItem
+	add := func(category, action string, paths ...string) {
+		for _, path := range paths {
+			if _, err := os.Lstat(path); err == nil {
+				items = append(items, prepareImageItem{Category: category, Action: action, Path: path})
+			}
+		}
+	}
+	glob := func(patterns []string) ([]string, error) {
+		var paths []string
+		for _, pattern := range patterns {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob pattern: %w", err)
+			}
+			paths = append(paths, matches...)
+		}
+		return paths, nil
+	}
+
+	homes, err := glob(layout.homePatterns)
+	if err != nil {
+		return nil, err
+	}
+	for _, home := range homes {
+		if fi, err := os.Lstat(home); err != nil || !fi.IsDir() || filepath.Base(home) == "Shared" {
+			continue
+		}
+		for _, name := range prepareImageHistoryFiles {
+			add(prepareImageHistory, prepareImageRemove, filepath.Join(home, name))
+		}
+		if caches := filepath.Join(home, "Library", "Caches"); isDirUnder(home, caches) {
+			items = append(items, prepareImageItem{
+				Category: prepareImageCache,
+				Action:   prepareImageEmpty,
+				Path:     caches,
+				home:     home,
+			})
+		}
+	}
+
+	add(prepareImageCache, prepareImageRemove, layout.cacheDirs...)
+	add(prepareImageState, prepareImageRemove, layout.stateFiles...)
+	add(prepareImageCaptures, prepareImageEmpty, layout.captureDirs...)
+
+	logs, err := glob(layout.logPatterns)
+	if err != nil {
+		return nil, err
+	}
+	add(prepareImageLog, prepareImageTruncate, logs...)
+	rotated, err := glob(layout.rotatedLogPatterns)
+	if err != nil {
+		return nil, err
+	}
+	add(prepareImageLog, prepareImageRemove, rotated...)
+
+	return items, nil
+}
+
+// runPrepareImage cleans the items in order, recording whether each was cleaned. A failure doesn't stop the cleaning
+// of the items after it.
+func runPrepareImage(items []prepareImageItem) {
+	for i := range items {
+		item := &items[i]
+
+		var err error
+		switch item.Action {
+		case prepareImageRemove:
+			err = os.RemoveAll(item.Path)
+		case prepareImageEmpty:
+			// The home directory may have changed since the plan.
+			if item.home != "" && !isDirUnder(item.home, item.Path) {
+				err = fmt.Errorf("%s is no longer a directory, or is under a symlink", item.Path)
+				break
+			}
+			err = emptyDir(item.Path)
+		case prepareImageTruncate:
+			err = os.Truncate(item.Path, 0)
+		default:
+			err = fmt.Errorf("unknown action %q", item.Action)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			item.Error = err.Error()
+			logrus.WithError(err).WithField("path", item.Path).Warnf("Failed to %s %s", item.Action, item.Category)
+			continue
+		}
+		item.Cleaned = true
+		logrus.WithField("path", item.Path).Debugf("Cleaned %s", item.Category)
+	}
+}
+
+// emptyDir removes the contents of the directory, keeping the directory itself and its permissions. Contents that
+// aren't permitted to be removed, e.g. caches protected by System Integrity Protection, are skipped.
+func emptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		err := os.RemoveAll(path)
+		if errors.Is(err, os.ErrPermission) {
+			logrus.WithError(err).WithField("path", path).Debug("Skipped protected file")
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isDirUnder reports whether path and every path component between base and it are directories and not symlinks.
+func isDirUnder(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return false
+	}
+	dir := base
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, name)
+		if fi, err := os.Lstat(dir); err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareImage(t *testing.T) {
+	root := t.TempDir()
+	layout := prepareImageLayout{
+		homePatterns:       []string{filepath.Join(root, "Users", "*")},
+		stateFiles:         []string{filepath.Join(root, "db", "state.db")},
+		cacheDirs:          []string{filepath.Join(root, "db", "uploads")},
+		captureDirs:        []string{filepath.Join(root, "db", "sysdiagnose")},
+		logPatterns:        []string{filepath.Join(root, "log", "*.log")},
+		rotatedLogPatterns: []string{filepath.Join(root, "log", "*.log.*")},
+	}
+	home := filepath.Join(root, "Users", "ec2-user")
+	for path, content := range map[string]string{
+		filepath.Join(home, ".zsh_history"):                          "sudo ec2-macos-utils prepare-image",
+		filepath.Join(home, ".zsh_sessions", "session.history"):      "ls",
+		filepath.Join(home, ".zshrc"):                                "export PATH",
+		filepath.Join(home, "Library", "Caches", "com.example", "x"): "cached",
+		filepath.Join(root, "Users", "Shared", ".zsh_history"):       "shared",
+		filepath.Join(root, "db", "state.db"):                        "state",
+		filepath.Join(root, "db", "uploads", "upload.json"):          "{}",
+		filepath.Join(root, "db", "spotlight.json"):                  "{}",
+		filepath.Join(root, "db", "sysdiagnose", "uuid", "a.tar.gz"): "archive",
+		filepath.Join(root, "log", "actions.log"):                    "action",
+		filepath.Join(root, "log", "actions.log.0.gz"):               "rotated",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	items, err := planPrepareImage(layout)
+	require.NoError(t, err)
+	actions := map[string]string{}
+	for _, item := range items {
+		actions[item.Path] = item.Category + " " + item.Action
+	}
+	assert.Equal(t, map[string]string{
+		filepath.Join(home, ".zsh_history"):            "history remove",
+		filepath.Join(home, ".zsh_sessions"):           "history remove",
+		filepath.Join(home, "Library", "Caches"):       "cache empty",
+		filepath.Join(root, "db", "uploads"):           "cache remove",
+		filepath.Join(root, "db", "state.db"):          "state remove",
+		filepath.Join(root, "db", "sysdiagnose"):       "captures empty",
+		filepath.Join(root, "log", "actions.log"):      "log truncate",
+		filepath.Join(root, "log", "actions.log.0.gz"): "log remove",
+	}, actions)
+
+	runPrepareImage(items)
+	for _, item := range items {
+		assert.True(t, item.Cleaned, item.Path)
+		assert.Empty(t, item.Error, item.Path)
+	}
+	assert.NoFileExists(t, filepath.Join(home, ".zsh_history"))
+	assert.NoDirExists(t, filepath.Join(home, ".zsh_sessions"))
+	assert.FileExists(t, filepath.Join(home, ".zshrc"), "shell configuration should be kept")
+	assert.DirExists(t, filepath.Join(home, "Library", "Caches"))
+	assert.NoDirExists(t, filepath.Join(home, "Library", "Caches", "com.example"))
+	assert.FileExists(t, filepath.Join(root, "Users", "Shared", ".zsh_history"), "the shared directory isn't a home")
+	assert.NoFileExists(t, filepath.Join(root, "db", "state.db"))
+	assert.FileExists(t, filepath.Join(root, "db", "spotlight.json"), "policies should be kept")
+	assert.DirExists(t, filepath.Join(root, "db", "sysdiagnose"))
+	assert.NoDirExists(t, filepath.Join(root, "db", "sysdiagnose", "uuid"))
+	assert.NoFileExists(t, filepath.Join(root, "log", "actions.log.0.gz"))
+	if fi, err := os.Stat(filepath.Join(root, "log", "actions.log")); assert.NoError(t, err, "logs should be kept") {
+		assert.Zero(t, fi.Size(), "logs should be truncated")
+	}
+}
+
+func TestPrepareImage_SymlinkedCaches(t *testing.T) {
+	root := t.TempDir()
+	layout := prepareImageLayout{homePatterns: []string{filepath.Join(root, "Users", "*")}}
+	target := filepath.Join(root, "target")
+	require.NoError(t, os.MkdirAll(filepath.Join(target, "Caches"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(target, "Caches", "keep"), nil, 0644))
+
+	// Neither a symlinked Caches nor a symlinked Library is emptied.
+	library := filepath.Join(root, "Users", "caches", "Library")
+	require.NoError(t, os.MkdirAll(library, 0755))
+	require.NoError(t, os.Symlink(filepath.Join(target, "Caches"), filepath.Join(library, "Caches")))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "Users", "library"), 0755))
+	require.NoError(t, os.Symlink(target, filepath.Join(root, "Users", "library", "Library")))
+
+	items, err := planPrepareImage(layout)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	// Nor is one that was replaced by a symlink after the plan.
+	home := filepath.Join(root, "Users", "swapped")
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "Library", "Caches"), 0755))
+	items, err = planPrepareImage(layout)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.NoError(t, os.RemoveAll(filepath.Join(home, "Library")))
+	require.NoError(t, os.Symlink(target, filepath.Join(home, "Library")))
+	runPrepareImage(items)
+	assert.False(t, items[0].Cleaned)
+	assert.NotEmpty(t, items[0].Error)
+	assert.FileExists(t, filepath.Join(target, "Caches", "keep"))
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/pflag"
+
+	"github.com/aws/ec2-macos-utils/internal/privsep"
+)
+
+// privilegeArgs is a struct for holding the flag that drops the root privileges of a long-running command.
+type privilegeArgs struct {
+	user string
+}
+
+// addFlags registers the privilege dropping flag.
+func (a *privilegeArgs) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&a.user, "run-as", "", "drop root privileges to this service user once started, e.g. _ec2macosutils, regaining them for privileged actions such as sysdiagnose collection; root stays the real user, so this isn't a security boundary (default keep running as root)")
+}
+
+// drop drops root privileges to the service user when one was given. It's called once the command's privileged setup,
+// e.g. creating its directories and loading the AWS configuration, is done.
+func (a privilegeArgs) drop() error {
+	if a.user == "" {
+		return nil
+	}
+
+	return privsep.Drop(a.user)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/memory"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+// purgeMemoryCommand creates a new command which empties the disk cache to relieve memory pressure.
+func purgeMemoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "purge-memory",
+		Short: "empty the disk cache to relieve memory pressure",
+		Long: strings.TrimSpace(`
+purge-memory flushes file system buffers to disk with sync and empties
+the disk cache with purge, freeing the memory it held, then reports the
+memory pressure and usage before and after.
+
+This relieves pressure caused by the disk cache, e.g. before collecting
+diagnostics so that they reflect the memory used by processes, but
+doesn't free memory that processes are using. The cache fills again as
+files are read, so file access is slower for a while afterwards.
+
+With --json, the memory usage before and after is printed as JSON, with
+sizes in bytes.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var asJSON bool
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the memory usage before and after as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		result, err := memory.Purge(cmd.Context())
+		if err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"pressure_before": result.Before.Pressure,
+			"pressure_after":  result.After.Pressure,
+		}).Info("Purged disk cache")
+
+		if asJSON {
+			return printJSON(cmd, result)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		table := output.NewTable(styler, "memory", "before", "after")
+		table.AddRow("pressure", fmt.Sprintf("%.0f%%", result.Before.Pressure), fmt.Sprintf("%.0f%%", result.After.Pressure))
+		for _, row := range []struct {
+			name          string
+			before, after uint64
+		}{
+			{"free", result.Before.Free, result.After.Free},
+			{"inactive", result.Before.Inactive, result.After.Inactive},
+			{"speculative", result.Before.Speculative, result.After.Speculative},
+			{"purgeable", result.Before.Purgeable, result.After.Purgeable},
+		} {
+			table.AddRow(row.name, units.HumanSize(float64(row.before)), units.HumanSize(float64(row.after)))
+		}
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
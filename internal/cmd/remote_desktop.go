@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/localuser"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/remotedesktop"
+)
+
+// remoteDesktopCommand creates a new command which groups Screen Sharing and ARD utilities.
+func remoteDesktopCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remote-desktop",
+		Short: "Screen Sharing and Apple Remote Desktop utilities",
+		Long:  "utilities for enabling and disabling Screen Sharing (VNC) and Apple Remote Desktop access",
+	}
+
+	cmd.AddCommand(
+		remoteDesktopEnableCommand(),
+		remoteDesktopDisableCommand(),
+		remoteDesktopStatusCommand(),
+	)
+
+	return cmd
+}
+
+// remoteDesktopEnableCommand creates a new command which enables Screen Sharing and ARD.
+func remoteDesktopEnableCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "enable",
+		Short: "enable Screen Sharing and Apple Remote Desktop",
+		Long: strings.TrimSpace(`
+enable turns on Screen Sharing and Apple Remote Desktop with kickstart,
+allowing only the --user users to connect, with every privilege. The
+users log in with their own password, which can be set with
+'user set-password'.
+
+Clients that only support legacy VNC authentication need a separate VNC
+password of at most 8 characters, read from the first line of stdin
+with --vnc-password-stdin or from an SSM Parameter Store parameter with
+--vnc-password-ssm-parameter.
+
+Screen Sharing listens on port 5900; connect through an SSH tunnel
+(ssh -L 5900:localhost:5900 ec2-user@instance) rather than opening the
+port in the instance's security group.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		users        []string
+		vncStdin     bool
+		vncParameter string
+	)
+	cmd.Flags().StringSliceVar(&users, "user", []string{"ec2-user"}, "local user allowed to connect (repeatable)")
+	cmd.Flags().BoolVar(&vncStdin, "vnc-password-stdin", false, "read a VNC password from standard input")
+	cmd.Flags().StringVar(&vncParameter, "vnc-password-ssm-parameter", "", "read a VNC password from this SSM Parameter Store parameter")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if vncStdin && vncParameter != "" {
+			return errors.New("only one of --vnc-password-stdin and --vnc-password-ssm-parameter can be set")
+		}
+		for _, user := range users {
+			if err := localuser.ValidateName(user); err != nil {
+				return err
+			}
+		}
+
+		opts := remotedesktop.Options{Users: users}
+		if vncStdin || vncParameter != "" {
+			password, err := readSecret(cmd, vncStdin, vncParameter)
+			if err != nil {
+				return err
+			}
+			if password == "" {
+				return errors.New("VNC password cannot be empty")
+			}
+			opts.VNCPassword = password
+		}
+
+		if err := remotedesktop.Enable(cmd.Context(), opts); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"users": users,
+			"vnc":   opts.VNCPassword != "",
+		}).Info("Enabled remote desktop access")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// remoteDesktopDisableCommand creates a new command which disables Screen Sharing and ARD.
+func remoteDesktopDisableCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "disable Screen Sharing and Apple Remote Desktop",
+		Long: strings.TrimSpace(`
+disable turns off Screen Sharing, Apple Remote Desktop, and legacy VNC
+access.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if err := remotedesktop.Disable(cmd.Context()); err != nil {
+			return err
+		}
+		logrus.Info("Disabled remote desktop access")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// remoteDesktopStatusCommand creates a new command which prints the state of Screen Sharing and ARD.
+func remoteDesktopStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "print the state of Screen Sharing and Apple Remote Desktop",
+		Long: strings.TrimSpace(`
+status prints whether the Screen Sharing service is loaded, whether the
+ARD agent is running, and whether connections are accepted on port
+5900, as a table or, with --json, a JSON object.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var asJSON bool
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the status as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		status := remotedesktop.GetStatus(cmd.Context())
+		if asJSON {
+			return printJSON(cmd, status)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		state := func(ok bool, yes, no string) string {
+			if ok {
+				return styler.Good(yes)
+			}
+			return styler.Caution(no)
+		}
+		table := output.NewTable(styler, "component", "state")
+		table.AddRow("screen sharing", state(status.ScreenSharing, "loaded", "not loaded"))
+		table.AddRow("ard agent", state(status.Agent, "running", "not running"))
+		table.AddRow("port 5900", state(status.Listening, "listening", "not listening"))
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
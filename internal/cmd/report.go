@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/actionlog"
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/state"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+// report is a snapshot of the instance's state for support. Sections that couldn't be gathered are left empty and
+// their errors are recorded in Errors, so that one failure doesn't cost the rest of the report.
+type report struct {
+	Time     time.Time          `json:"time"`
+	Version  string             `json:"version"`
+	Hostname string             `json:"hostname,omitempty"`
+	Product  string             `json:"product,omitempty"`
+	Identity *instance.Identity `json:"identity,omitempty"`
+	// Checks are the most recent result of each check.
+	Checks    []state.CheckResult     `json:"checks"`
+	Watchdogs []reportWatchdog        `json:"watchdogs"`
+	Disks     *types.SystemPartitions `json:"disks,omitempty"`
+	Actions   []actionlog.Entry       `json:"actions"`
+	Errors    map[string]string       `json:"errors,omitempty"`
+}
+
+// reportWatchdog is the diagnostic data captured by a watchdog.
+type reportWatchdog struct {
+	Watchdog string          `json:"watchdog"`
+	Captures []state.Capture `json:"captures"`
+}
+
+// reportCommand creates a new command which reports the instance's state for support.
+func reportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "report the instance's state for support",
+		Long: strings.TrimSpace(`
+report assembles a snapshot of the instance's state for support: the
+utility's version, the instance identity and macOS version, the most
+recent result of each check, the diagnostic data captured by watchdogs,
+the disks and volumes, and the most recent actions performed on the
+instance. It takes seconds, unlike a sysdiagnose, so it's the first
+thing to collect when asking for help.
+
+Sections that can't be gathered, e.g. the instance identity when IMDS is
+unreachable, are reported as errors rather than failing the report.
+
+--actions sets how many of the most recent actions are included. With
+--json, the report is printed as JSON. With --upload, the report is also
+saved to a file and uploaded to S3 (see 'debug create-sysdiagnose' for
+the upload flags and naming templates).
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var (
+		actions int
+		asJSON  bool
+		upload  uploadArgs
+	)
+	cmd.Flags().IntVar(&actions, "actions", 20, "maximum number of most recent actions to include, or 0 for all")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON")
+	upload.addFlags(cmd.Flags())
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		if actions < 0 {
+			return errors.New("actions cannot be negative")
+		}
+		if err := upload.validate(); err != nil {
+			return err
+		}
+
+		r := gatherReport(ctx, actions)
+
+		write := func(w io.Writer) error { return writeReport(w, contextual.Styler(ctx), r) }
+		ext := ".txt"
+		if asJSON {
+			write, ext = func(w io.Writer) error { return writeReportJSON(w, r) }, ".json"
+		}
+		if err := write(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+
+		if !upload.enabled() {
+			return nil
+		}
+		// The uploaded copy isn't styled since it's read elsewhere.
+		if !asJSON {
+			write = func(w io.Writer) error { return writeReport(w, output.Plain, r) }
+		}
+		dir, err := os.MkdirTemp("", "ec2-macos-utils-report")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "report_"+r.Time.UTC().Format("20060102_150405")+ext)
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := write(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		vars, err := namingVars(ctx, r.Time, upload.key)
+		if err != nil {
+			return fmt.Errorf("cannot resolve naming template placeholders: %w", err)
+		}
+		key, err := upload.objectKey(vars, path)
+		if err != nil {
+			return err
+		}
+
+		return upload.upload(ctx, uploadObject{path: path, key: key})
+	}
+
+	return cmd
+}
+
+// gatherReport gathers each section of the report, including at most actions of the most recent actions.
+func gatherReport(ctx context.Context, actions int) report {
+	r := report{Time: time.Now(), Version: build.Version, Errors: map[string]string{}}
+	fail := func(section string, err error) {
+		r.Errors[section] = err.Error()
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		r.Hostname = hostname
+	} else {
+		fail("hostname", err)
+	}
+	if product := contextual.Product(ctx); product != nil {
+		r.Product = product.String()
+	}
+
+	if cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx)); err != nil {
+		fail("identity", err)
+	} else if r.Identity, err = instance.CachedIdentity(ctx, imds.NewFromConfig(cfg)); err != nil {
+		fail("identity", err)
+	}
+
+	err := withState(func(s *state.Store) error {
+		history, err := s.CheckHistory("", 0)
+		if err != nil {
+			return err
+		}
+		r.Checks = latestCheckResults(history)
+
+		for _, watchdog := range []string{networkMonitorWatchdog, scheduledEventsWatchdog} {
+			captures, err := s.Captures(watchdog)
+			if err != nil {
+				return err
+			}
+			r.Watchdogs = append(r.Watchdogs, reportWatchdog{Watchdog: watchdog, Captures: captures})
+		}
+		return nil
+	})
+	if err != nil {
+		fail("state", err)
+	}
+
+	if product := contextual.Product(ctx); product == nil {
+		fail("disks", errors.New("product required in context"))
+	} else if d, err := diskutil.ForProduct(product); err != nil {
+		fail("disks", err)
+	} else if r.Disks, err = d.List(ctx, nil); err != nil {
+		fail("disks", err)
+	}
+
+	if r.Actions, err = actionlog.Read(actionlog.DefaultPath, actionlog.Query{Limit: actions}); err != nil {
+		fail("actions", err)
+	}
+
+	return r
+}
+
+// latestCheckResults returns the most recent of the results of each check, ordered by check.
+func latestCheckResults(history []state.CheckResult) []state.CheckResult {
+	latest := map[string]state.CheckResult{}
+	var checks []string
+	for _, result := range history {
+		previous, ok := latest[result.Check]
+		if !ok {
+			checks = append(checks, result.Check)
+		}
+		if !ok || !result.Time.Before(previous.Time) {
+			latest[result.Check] = result
+		}
+	}
+	sort.Strings(checks)
+
+	results := make([]state.CheckResult, 0, len(checks))
+	for _, check := range checks {
+		results = append(results, latest[check])
+	}
+
+	return results
+}
+
+// writeReportJSON writes the report as JSON, with empty sections as empty lists rather than null.
+func writeReportJSON(w io.Writer, r report) error {
+	if r.Checks == nil {
+		r.Checks = []state.CheckResult{}
+	}
+	if r.Watchdogs == nil {
+		r.Watchdogs = []reportWatchdog{}
+	}
+	if r.Actions == nil {
+		r.Actions = []actionlog.Entry{}
+	}
+
+	return encodeJSON(w, "report", r)
+}
+
+// writeReport writes the report as a heading and a table for each section.
+func writeReport(w io.Writer, styler *output.Styler, r report) error {
+	section := func(title string) {
+		fmt.Fprintf(w, "%s\n\n", styler.Bold(title))
+	}
+
+	section("System")
+	system := output.NewTable(styler, "property", "value")
+	system.AddRow("time", r.Time.Local().Format(time.RFC3339))
+	system.AddRow("version", r.Version)
+	system.AddRow("hostname", orDash(r.Hostname))
+	system.AddRow("product", orDash(r.Product))
+	if r.Identity != nil {
+		system.AddRow("instance id", r.Identity.InstanceID)
+		system.AddRow("instance type", r.Identity.InstanceType)
+		system.AddRow("image id", r.Identity.ImageID)
+		system.AddRow("availability zone", r.Identity.AvailabilityZone)
+		system.AddRow("account id", r.Identity.AccountID)
+	}
+	if err := system.Render(w); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	section("Checks")
+	checks := output.NewTable(styler, "check", "result", "time", "error")
+	for _, c := range r.Checks {
+		result := styler.Good("ok")
+		if !c.OK {
+			result = styler.Bad("failed")
+		}
+		checks.AddRow(c.Check, result, c.Time.Local().Format(time.RFC3339), orDash(c.Error))
+	}
+	if err := checks.Render(w); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	section("Watchdogs")
+	watchdogs := output.NewTable(styler, "watchdog", "captures", "last capture", "time")
+	for _, wd := range r.Watchdogs {
+		last, at := "-", "-"
+		if n := len(wd.Captures); n > 0 {
+			last, at = wd.Captures[n-1].Path, wd.Captures[n-1].Time.Local().Format(time.RFC3339)
+		}
+		watchdogs.AddRow(wd.Watchdog, strconv.Itoa(len(wd.Captures)), last, at)
+	}
+	if err := watchdogs.Render(w); err != nil {
+		return err
+	}
+
+	if r.Disks != nil {
+		fmt.Fprintln(w)
+		section("Disks")
+		if err := disksTable(styler, r.Disks).Render(w); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w)
+	section("Recent actions")
+	actions := output.NewTable(styler, "time", "user", "action", "result")
+	for _, e := range r.Actions {
+		result := styler.Good(string(e.Result))
+		if e.Result == actionlog.Failure {
+			result = styler.Bad(string(e.Result))
+		}
+		actions.AddRow(e.Time.Local().Format(time.RFC3339), e.User, strings.Join(append([]string{e.Action}, e.Args...), " "), result)
+	}
+	if err := actions.Render(w); err != nil {
+		return err
+	}
+
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(w)
+		section("Errors")
+		sections := make([]string, 0, len(r.Errors))
+		for s := range r.Errors {
+			sections = append(sections, s)
+		}
+		sort.Strings(sections)
+		errs := output.NewTable(styler, "section", "error")
+		for _, s := range sections {
+			errs.AddRow(s, styler.Bad(r.Errors[s]))
+		}
+		if err := errs.Render(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/actionlog"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+func TestLatestCheckResults(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []state.CheckResult{
+		{Check: "imds", Time: start, OK: false, Error: "timeout"},
+		{Check: "time", Time: start.Add(time.Minute), OK: true},
+		{Check: "imds", Time: start.Add(2 * time.Minute), OK: true},
+	}
+
+	latest := latestCheckResults(history)
+	if assert.Len(t, latest, 2) {
+		assert.Equal(t, history[2], latest[0], "the most recent result of each check should be kept")
+		assert.Equal(t, history[1], latest[1])
+	}
+	assert.Empty(t, latestCheckResults(nil))
+}
+
+func TestWriteReport(t *testing.T) {
+	r := report{
+		Time:    time.Now(),
+		Version: "1.0.0",
+		Checks:  []state.CheckResult{{Check: "imds", Time: time.Now(), Error: "timeout"}},
+		Watchdogs: []reportWatchdog{
+			{Watchdog: scheduledEventsWatchdog, Captures: []state.Capture{{Path: "/tmp/sysdiagnose_1.tar.gz", Time: time.Now()}}},
+		},
+		Actions: []actionlog.Entry{{Action: "ssh configure", User: "root", Result: actionlog.Success}},
+		Errors:  map[string]string{"disks": "diskutil exited with status 1"},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeReport(&buf, output.Plain, r))
+	for _, want := range []string{"System", "1.0.0", "timeout", "/tmp/sysdiagnose_1.tar.gz", "ssh configure", "diskutil exited with status 1"} {
+		assert.Contains(t, buf.String(), want)
+	}
+	assert.NotContains(t, buf.String(), "Disks", "sections that couldn't be gathered should only be reported as errors")
+}
+
+func TestWriteReportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, writeReportJSON(&buf, report{Version: "1.0.0"}))
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, []interface{}{}, decoded["checks"], "empty sections should be empty lists")
+	assert.Equal(t, []interface{}{}, decoded["actions"])
+	assert.NotContains(t, decoded, "errors")
+}
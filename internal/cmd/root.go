@@ -4,6 +4,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -11,11 +12,45 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/aws/ec2-macos-utils/internal/actionlog"
+	"github.com/aws/ec2-macos-utils/internal/aws"
 	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/imdstoken"
+	"github.com/aws/ec2-macos-utils/internal/logdedup"
+	"github.com/aws/ec2-macos-utils/internal/oslog"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/privsep"
+	"github.com/aws/ec2-macos-utils/internal/progress"
+	"github.com/aws/ec2-macos-utils/internal/redact"
+	"github.com/aws/ec2-macos-utils/internal/runid"
+	"github.com/aws/ec2-macos-utils/internal/selfmetrics"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
+	"github.com/aws/ec2-macos-utils/internal/util"
+	"github.com/aws/ec2-macos-utils/pkg/command"
 )
 
 const shortLicenseText = "Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved."
 
+const (
+	// execDefaultConcurrency is the default most external commands run at the same time by the utility's processes.
+	execDefaultConcurrency = 4
+	// execDefaultPerMinute is the default most external commands started within a minute by the utility's processes.
+	execDefaultPerMinute = 120
+)
+
+// progressLogInterval is how often the progress of long operations is logged when stderr isn't a terminal.
+const progressLogInterval = 30 * time.Second
+
+// queryCache caches the output of slow queries of the host, so that the checks and the cycles of long-running
+// commands such as watchdogs don't run the same tools again while their output is unlikely to have changed. Commands
+// that change what's queried invalidate it.
+var queryCache = command.NewCache(command.Default).
+	SetTTL(time.Hour, "ioreg", "-d1", "-c", "IOPlatformExpertDevice").
+	SetTTL(30*time.Second, "diskutil", "list").
+	SetTTL(15*time.Minute, "softwareupdate", "--list")
+
 // MainCommand provides the main program entrypoint that dispatches to utility subcommands.
 func MainCommand() *cobra.Command {
 	cmd := rootCommand()
@@ -24,11 +59,48 @@ func MainCommand() *cobra.Command {
 		growContainerCommand(),
 		checkCommand(),
 		debugCommand(),
+		disksCommand(),
 		watchdogCommand(),
+		daemonCommand(),
+		selfUpdateCommand(),
+		logsCommand(),
+		metricsCommand(),
+		systemCommand(),
+		batchCommand(),
+		sshCommand(),
+		userCommand(),
+		remoteDesktopCommand(),
+		updatesCommand(),
+		serviceCommand(),
+		firewallCommand(),
+		hostnameCommand(),
+		timeCommand(),
+		networkCommand(),
+		certsCommand(),
+		timeMachineCommand(),
+		spotlightCommand(),
+		uiCommand(),
+		securityCommand(),
+		rosettaCommand(),
+		devtoolsCommand(),
+		displayCommand(),
+		auditCommand(),
+		historyCommand(),
+		schemaCommand(),
+		selftestCommand(),
+		uninstallCommand(),
+		migrateCommand(),
+		prepareImageCommand(),
+		firstbootCommand(),
+		reportCommand(),
 	}
 	for i := range cmds {
 		cmd.AddCommand(cmds[i])
 	}
+	recordActions(cmd, actionlog.DefaultPath)
+	logToolErrors(cmd)
+	traceCommands(cmd)
+	flushLogSummaries(cmd)
 
 	return cmd
 }
@@ -50,17 +122,103 @@ help text and usages that accompany them.
 	}
 
 	versionTemplate := "{{.Name}} {{.Version}} [%s]\n\n%s\n"
+	if build.Minimal {
+		versionTemplate = "{{.Name}} {{.Version}} (minimal) [%s]\n\n%s\n"
+	}
 	cmd.SetVersionTemplate(fmt.Sprintf(versionTemplate, build.CommitDate, shortLicenseText))
 
-	var verbose bool
+	var verbose, progressJSON, noProgress, noColor, traceExec bool
+	var configSource, selfMetricsAddr, statusAddr, otlpEndpoint string
+	var logDedupWindow time.Duration
+	var execConcurrency, execPerMinute int
+	var awsOpts aws.Options
+	var endpointURLs []string
 	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging output")
+	cmd.PersistentFlags().BoolVar(&progressJSON, "progress-json", false, "Emit newline-delimited JSON progress events on stderr during long operations")
+	cmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "Disable the progress bars drawn on stderr during long operations when it's a terminal, or the progress logged periodically otherwise")
+	cmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output (also disabled when output is not a terminal)")
+	cmd.PersistentFlags().BoolVar(&traceExec, "trace-exec", false, "Log every external command run, with its arguments, duration, exit code, and truncated output (which may contain secrets that aren't known to be sensitive)")
+	cmd.PersistentFlags().IntVar(&execConcurrency, "exec-concurrency", execDefaultConcurrency, "Maximum external commands, such as diskutil and log, run at the same time by all of the utility's processes, 0 for no limit")
+	cmd.PersistentFlags().IntVar(&execPerMinute, "exec-per-minute", execDefaultPerMinute, "Maximum external commands started within a minute by all of the utility's processes, 0 for no limit")
+	cmd.PersistentFlags().DurationVar(&logDedupWindow, "log-dedup-window", 0, "Collapse identical log entries repeated within this window (e.g. 1h) into a summary with their count, to keep the logs of long-running commands small (default no deduplication)")
+	cmd.PersistentFlags().StringVar(&awsOpts.Region, "region", "", "AWS region for AWS API calls (default from environment, shared config, or IMDS)")
+	cmd.PersistentFlags().StringVar(&awsOpts.Profile, "aws-profile", "", "Shared config profile for AWS API calls (default instance role)")
+	cmd.PersistentFlags().StringArrayVar(&endpointURLs, "endpoint-url", nil, "Endpoint URL for AWS API calls, or service=URL for one service, e.g. cloudwatch_logs=https://vpce-... (repeatable)")
+	cmd.PersistentFlags().BoolVar(&awsOpts.UseFIPSEndpoint, "use-fips-endpoint", false, "Use FIPS endpoints for AWS API calls")
+	cmd.PersistentFlags().IntVar(&awsOpts.MaxAttempts, "aws-max-attempts", 0, "Maximum attempts of each AWS API call (default 3)")
+	cmd.PersistentFlags().DurationVar(&awsOpts.MaxBackoff, "aws-max-backoff", 0, "Maximum delay between attempts of AWS API calls (default 20s)")
+	cmd.PersistentFlags().DurationVar(&awsOpts.Timeout, "aws-timeout", 0, "Timeout of each attempt of an AWS API call, including IMDS requests (default no timeout)")
+	cmd.PersistentFlags().StringVar(&awsOpts.IMDSTokenSocket, "imds-token-socket", imdstoken.DefaultSocketPath, "Root-only socket on which the daemon shares its IMDS session token, used by IMDS requests instead of requesting their own token when it's served, empty to always request one")
+	cmd.PersistentFlags().StringVar(&configSource, "config", config.DefaultPath, "Configuration file, or ssm:///parameter-name to load it from SSM Parameter Store")
+	cmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-traces-endpoint", tracing.EndpointFromEnv(), "Export trace spans of the command, its external commands, uploads, and checks to this OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces (default from OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT)")
+	cmd.PersistentFlags().StringVar(&selfMetricsAddr, "self-metrics-listen", "", "Serve the utility's own counters in the Prometheus text format at /metrics on this loopback address while it runs, e.g. 127.0.0.1:9464")
+	cmd.PersistentFlags().StringVar(&statusAddr, "status-listen", "", "Serve the command's liveness at /healthz and its status, with watchdog states and the latest check results, as JSON at /status on this loopback address while it runs, e.g. 127.0.0.1:9465")
 
 	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// Configuration is applied first so that it can provide any flag, including the global ones read below.
+		// Endpoints are resolved before the configuration is loaded so that it can be fetched through them, and
+		// again afterwards in case the configuration provides them.
+		if err := setEndpoints(&awsOpts, endpointURLs); err != nil {
+			return err
+		}
+		explicit := cmd.Flags().Changed("config")
+		if err := applyConfig(cmd, configSource, explicit, awsOpts); err != nil {
+			return err
+		}
+		if err := setEndpoints(&awsOpts, endpointURLs); err != nil {
+			return err
+		}
+		if logDedupWindow < 0 {
+			return errors.New("log deduplication window cannot be negative")
+		}
+		if awsOpts.MaxAttempts < 0 || awsOpts.MaxBackoff < 0 || awsOpts.Timeout < 0 {
+			return errors.New("AWS retry and timeout settings cannot be negative")
+		}
+		if execConcurrency < 0 || execPerMinute < 0 {
+			return errors.New("external command limits cannot be negative")
+		}
+
 		level := logrus.InfoLevel
 		if verbose {
 			level = logrus.DebugLevel
 		}
-		setupLogging(level)
+		runID := runid.FromEnvOrNew()
+		setupLogging(level, runID, commandPath(cmd), logDedupWindow)
+		util.SetExecTracing(traceExec)
+		util.SetExecLimiter(nil)
+		if execConcurrency > 0 || execPerMinute > 0 {
+			util.SetExecLimiter(util.NewExecLimiter(execConcurrency, execPerMinute, util.DefaultExecLimitDir))
+		}
+		command.Default = queryCache
+
+		ctx := contextual.WithRunID(cmd.Context(), runID)
+		ctx = contextual.WithStyler(ctx, output.NewStyler(cmd.OutOrStdout(), noColor))
+		ctx = contextual.WithAWSOptions(ctx, awsOpts)
+		ctx = withTracer(ctx, otlpEndpoint)
+		cmd.SetContext(ctx)
+
+		switch {
+		case progressJSON:
+			cmd.SetContext(contextual.WithProgress(cmd.Context(), progress.NewJSON(os.Stderr)))
+		case noProgress:
+		case output.IsTerminal(os.Stderr) && os.Getenv("TERM") != "dumb":
+			// Logs are written around the bar so that they don't garble it.
+			bar := progress.NewBar(os.Stderr)
+			logrus.SetOutput(bar.Writer(os.Stderr))
+			cmd.SetContext(contextual.WithProgress(cmd.Context(), bar))
+		default:
+			cmd.SetContext(contextual.WithProgress(cmd.Context(), progress.NewLog(progressLogInterval)))
+		}
+		if selfMetricsAddr != "" {
+			if err := serveSelfMetrics(selfMetricsAddr); err != nil {
+				return err
+			}
+		}
+		if statusAddr != "" {
+			if err := serveStatus(statusAddr, commandPath(cmd), runID); err != nil {
+				return err
+			}
+		}
 
 		return nil
 	}
@@ -68,8 +226,89 @@ help text and usages that accompany them.
 	return cmd
 }
 
-// setupLogging configures logrus to use the desired timestamp format and log level.
-func setupLogging(level logrus.Level) {
+// setEndpoints replaces the endpoint overrides of opts with the --endpoint-url values.
+func setEndpoints(opts *aws.Options, values []string) error {
+	opts.EndpointURL, opts.ServiceEndpoints = "", nil
+	for _, v := range values {
+		if err := opts.SetEndpoint(v); err != nil {
+			return fmt.Errorf("invalid --endpoint-url: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// applyConfig loads the configuration from source and applies it to the flags of cmd that weren't set on the
+// command line. A missing configuration file is only an error when it was explicitly requested.
+func applyConfig(cmd *cobra.Command, source string, explicit bool, awsOpts aws.Options) error {
+	profile := configProfile(cmd)
+	c, err := configLoader(awsOpts).Load(cmd.Context(), source)
+	if errors.Is(err, os.ErrNotExist) && !explicit && profile == "" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot load configuration: %w", err)
+	}
+
+	path := commandPath(cmd)
+	if profile != "" {
+		if err := c.ApplyProfile(path, profile, cmd.Flags()); err != nil {
+			return err
+		}
+	}
+	return c.Apply(path, cmd.Flags())
+}
+
+// commandPath returns the path of cmd below the root command, e.g. "ssh configure".
+func commandPath(cmd *cobra.Command) string {
+	return strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+}
+
+// configProfileAnnotation marks the --profile flag of commands whose profiles are read from the configuration.
+const configProfileAnnotation = "ec2-macos-utils/config-profile"
+
+// addConfigProfileFlag adds a --profile flag which selects a profile of flag values from the configuration.
+func addConfigProfileFlag(cmd *cobra.Command) {
+	cmd.Flags().String("profile", "", "profile of flag values to apply from the configuration's \"profiles\"")
+	_ = cmd.Flags().SetAnnotation("profile", configProfileAnnotation, []string{"true"})
+}
+
+// configProfile returns the profile selected with a --profile flag added by addConfigProfileFlag, if any.
+func configProfile(cmd *cobra.Command) string {
+	flag := cmd.Flags().Lookup("profile")
+	if flag == nil || !flag.Changed || flag.Annotations[configProfileAnnotation] == nil {
+		return ""
+	}
+
+	return flag.Value.String()
+}
+
+// serveSelfMetrics serves the utility's own counters for Prometheus to scrape at /metrics on addr until the program
+// exits, which is mostly useful for long-running commands such as watchdogs. Like the status, it only listens on
+// loopback addresses since it isn't authenticated, so scrapers on other hosts go through an agent on the instance.
+func serveSelfMetrics(addr string) error {
+	l, err := listenLoopback(addr)
+	if err != nil {
+		return fmt.Errorf("cannot serve self metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", selfmetrics.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(l); err != nil {
+			logrus.WithError(err).Error("Stopped serving self metrics")
+		}
+	}()
+	logrus.WithField("address", l.Addr().String()).Debug("Serving self metrics")
+
+	return nil
+}
+
+// setupLogging configures logrus to use the desired timestamp format and log level. Every entry is annotated with
+// the invocation's run ID and, where supported, mirrored into the unified log with the command as its category. With
+// a dedupWindow, identical entries repeated within it are collapsed into summaries on stderr, but not in the unified
+// log, which keeps every entry.
+func setupLogging(level logrus.Level, runID, command string, dedupWindow time.Duration) {
 	Formatter := &logrus.TextFormatter{}
 
 	// Configure the formatter
@@ -79,11 +318,51 @@ func setupLogging(level logrus.Level) {
 	// Set the desired log level
 	logrus.SetLevel(level)
 
-	logrus.SetFormatter(Formatter)
+	if dedupWindow > 0 {
+		logrus.SetFormatter(logdedup.New(Formatter, dedupWindow))
+	} else {
+		logrus.SetFormatter(Formatter)
+	}
+
+	hooks := logrus.LevelHooks{}
+	// Secrets are redacted first so that no other hook or sink sees them, whatever the level.
+	hooks.Add(redact.Hook{})
+	hooks.Add(runid.Hook(runID))
+	// The unified log isn't available in every build, e.g. on other platforms for tests and docs.
+	if command == "" {
+		command = "main"
+	}
+	if h, err := oslog.NewHook(command); err == nil {
+		hooks.Add(h)
+	}
+	logrus.StandardLogger().ReplaceHooks(hooks)
+}
+
+// flushLogSummaries makes every command under root write the summaries of the log entries collapsed with
+// --log-dedup-window when it returns, since their windows may not have ended.
+func flushLogSummaries(root *cobra.Command) {
+	for _, cmd := range root.Commands() {
+		flushLogSummaries(cmd)
+	}
+	if root.RunE == nil {
+		return
+	}
+
+	runE := root.RunE
+	root.RunE = func(cmd *cobra.Command, args []string) error {
+		err := runE(cmd, args)
+		logger := logrus.StandardLogger()
+		if f, ok := logger.Formatter.(*logdedup.Formatter); ok {
+			_ = f.Flush(logger.Out)
+		}
+
+		return err
+	}
 }
 
+// hasRootPrivileges reports whether the command runs as root, or dropped root privileges that it can regain.
 func hasRootPrivileges() bool {
-	return os.Geteuid() == 0
+	return os.Geteuid() == 0 || privsep.Dropped()
 }
 
 // assertRootPrivileges checks if the command is running with root permissions.
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+)
+
+func TestApplyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"commands": {"parent child": {"name": "configured"}}}`), 0600))
+
+	var name string
+	child := &cobra.Command{Use: "child", RunE: func(*cobra.Command, []string) error { return nil }}
+	child.Flags().StringVar(&name, "name", "default", "")
+	parent := &cobra.Command{Use: "parent"}
+	parent.AddCommand(child)
+	root := testTree(parent)
+	root.SetArgs([]string{"parent", "child"})
+	assert.NoError(t, root.Execute())
+
+	assert.NoError(t, applyConfig(child, path, true, aws.Options{}))
+	assert.Equal(t, "configured", name)
+}
+
+func TestApplyConfig_Missing(t *testing.T) {
+	cmd := &cobra.Command{Use: "child"}
+	missing := filepath.Join(t.TempDir(), "config.json")
+
+	assert.NoError(t, applyConfig(cmd, missing, false, aws.Options{}), "the default configuration is optional")
+	assert.Error(t, applyConfig(cmd, missing, true, aws.Options{}), "an explicit configuration is required")
+}
+
+func TestMainCommand_NoShadowedFlags(t *testing.T) {
+	root := MainCommand()
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		// The configuration's global values are applied to any flag with their name, so a command's own flag named
+		// like a global one would get the global's value.
+		cmd.LocalNonPersistentFlags().VisitAll(func(flag *pflag.Flag) {
+			assert.Nil(t, root.PersistentFlags().Lookup(flag.Name), "%s --%s shadows a global flag", commandPath(cmd), flag.Name)
+		})
+		for _, child := range cmd.Commands() {
+			walk(child)
+		}
+	}
+	walk(root)
+}
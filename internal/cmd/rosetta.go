@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/rosetta"
+)
+
+// rosettaCommand creates a new command which groups Rosetta utilities.
+func rosettaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rosetta",
+		Short: "Rosetta 2 utilities",
+		Long:  "utilities for running x86_64 code on Apple silicon instances",
+	}
+
+	cmd.AddCommand(
+		rosettaInstallCommand(),
+	)
+
+	return cmd
+}
+
+// rosettaInstallCommand creates a new command which installs Rosetta 2.
+func rosettaInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "install Rosetta 2",
+		Long: strings.TrimSpace(`
+install installs Rosetta 2 non-interactively on Apple silicon instances
+and verifies that x86_64 code can run, e.g. from user data on instances
+of a mixed-architecture CI fleet. Installing requires accepting the
+Rosetta license with --agree-to-license.
+
+Nothing is done on Intel instances, which run x86_64 code natively, or
+when Rosetta is already installed.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var agreeToLicense bool
+	cmd.Flags().BoolVar(&agreeToLicense, "agree-to-license", false, "accept the Rosetta license")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		appleSilicon, err := rosetta.AppleSilicon(ctx)
+		if err != nil {
+			return err
+		}
+		if !appleSilicon {
+			logrus.Info("Rosetta is not needed on Intel instances")
+			return nil
+		}
+		if rosetta.Installed(ctx) {
+			logrus.Info("Rosetta is already installed")
+			return nil
+		}
+
+		logrus.Info("Installing Rosetta")
+		if err := rosetta.Install(ctx, agreeToLicense); err != nil {
+			if errors.Is(err, rosetta.ErrLicenseNotAccepted) {
+				return fmt.Errorf("%w, re-run with --agree-to-license", err)
+			}
+			return err
+		}
+		logrus.Info("Installed Rosetta")
+
+		return nil
+	}
+
+	return cmd
+}
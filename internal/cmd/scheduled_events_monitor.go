@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/privsep"
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+const (
+	scheduledEventsDefaultInterval      = time.Minute
+	scheduledEventsDefaultOutputBaseDir = "/private/var/db/ec2-macos-utils/scheduled-events"
+
+	// scheduledEventsWatchdog names the monitor's captures in the state.
+	scheduledEventsWatchdog = "scheduled-events"
+)
+
+type scheduledEventsMonitorArgs struct {
+	interval           time.Duration
+	preCapture         bool
+	outputDir          string
+	sysdiagnoseTimeout time.Duration
+	upload             uploadArgs
+	privileges         privilegeArgs
+	archive            archivePermissionArgs
+	// perms are the ownership and mode of the captures, resolved from archive.
+	perms archivePermissions
+	// captureLimiter limits how often a sysdiagnose is collected, along with the other tasks of a daemon.
+	captureLimiter *scheduler.Limiter
+}
+
+// newScheduledEventsMonitorCommand creates a new command which watches for scheduled maintenance events.
+func newScheduledEventsMonitorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scheduled-events",
+		Short: "monitor scheduled maintenance events",
+		Long: strings.TrimSpace(`
+monitor the instance's scheduled events (host maintenance, reboots, stops,
+and retirement) from instance metadata and log each new active event.
+
+With --pre-capture, a sysdiagnose is collected as soon as an event is
+detected, before the instance is impacted, preserving state that would
+otherwise be lost when the instance is stopped or moved to a new host.
+Each event is captured once, into a directory named after the event ID.
+With --upload, the capture is also uploaded to S3.
+With --run-as, events are checked as the given service user once the
+monitor has started, and root privileges are regained to capture and
+upload the sysdiagnose. The process keeps root as its real user, so
+--run-as isn't a security boundary.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+	}
+
+	var args scheduledEventsMonitorArgs
+	cmd.Flags().DurationVar(&args.interval, "interval", scheduledEventsDefaultInterval, "interval between checks for scheduled events")
+	cmd.Flags().BoolVar(&args.preCapture, "pre-capture", false, "collect a sysdiagnose when an event is scheduled")
+	cmd.Flags().StringVar(&args.outputDir, "output-base-dir", scheduledEventsDefaultOutputBaseDir, "base directory for sysdiagnose output")
+	cmd.Flags().DurationVar(&args.sysdiagnoseTimeout, "sysdiagnose-timeout", sysdiagnoseDefaultTimeout, "timeout for sysdiagnose collection")
+	args.upload.addFlags(cmd.Flags())
+	args.privileges.addFlags(cmd.Flags())
+	args.archive.addFlags(cmd.Flags())
+
+	cmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
+		if os.Geteuid() != 0 {
+			return errors.New("root privileges required - run with sudo")
+		}
+
+		if args.interval <= 0 {
+			return errors.New("interval must be positive")
+		}
+
+		if args.sysdiagnoseTimeout < sysdiagnoseMinTimeout {
+			return fmt.Errorf("timeout must be at least %v to ensure creation can complete", sysdiagnoseMinTimeout)
+		}
+
+		var err error
+		if args.perms, err = args.archive.resolve(); err != nil {
+			return err
+		}
+
+		return args.upload.validate()
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return runScheduledEventsMonitor(cmd.Context(), args)
+	}
+
+	return cmd
+}
+
+func runScheduledEventsMonitor(ctx context.Context, args scheduledEventsMonitorArgs) error {
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return err
+	}
+	client := imds.NewFromConfig(cfg)
+	if err := args.privileges.drop(); err != nil {
+		return err
+	}
+
+	logrus.WithField("interval", args.interval).Info("Starting scheduled events monitoring")
+
+	return scheduler.Run(ctx, scheduledEventsTask(args, client))
+}
+
+// scheduledEventsTask returns the task that checks for scheduled events at the interval, logging each new active
+// event and capturing it with --pre-capture. It skips its checks while the watchdogs are paused.
+func scheduledEventsTask(args scheduledEventsMonitorArgs, client instance.IMDSAPI) scheduler.Task {
+	seen := map[string]bool{}
+
+	return pausable(scheduler.Task{
+		Name:     scheduledEventsWatchdog,
+		Interval: args.interval,
+		Run: func(ctx context.Context) error {
+			cycleCtx, span := tracing.Start(ctx, "scheduled events check cycle", nil)
+			events, err := instance.ScheduledEvents(cycleCtx, client)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to read scheduled events")
+			}
+			for _, e := range events {
+				if !e.Active() || seen[e.ID] {
+					continue
+				}
+				seen[e.ID] = true
+
+				logrus.WithFields(logrus.Fields{
+					"event_id":   e.ID,
+					"code":       e.Code,
+					"not_before": e.NotBefore,
+				}).Warnf("Scheduled event detected: %s", e.Description)
+
+				if args.preCapture {
+					err := privsep.Privileged(func() error { return captureScheduledEvent(cycleCtx, args, e) })
+					if err != nil {
+						logrus.WithError(err).WithField("event_id", e.ID).Error("Failed to capture sysdiagnose for scheduled event")
+						// Try again on the next check.
+						delete(seen, e.ID)
+					}
+				}
+			}
+
+			span.Finish(err)
+			flushSpans(ctx)
+
+			return nil
+		},
+	})
+}
+
+// captureScheduledEvent collects and uploads a sysdiagnose for the event, unless one was already collected.
+func captureScheduledEvent(ctx context.Context, args scheduledEventsMonitorArgs, e instance.ScheduledEvent) error {
+	dir := filepath.Join(args.outputDir, e.ID)
+	done, err := captured(scheduledEventsWatchdog, e.ID, dir)
+	if err != nil {
+		return err
+	}
+	if done {
+		logrus.WithField("event_id", e.ID).Info("Sysdiagnose already captured for scheduled event")
+		return nil
+	}
+
+	collectCtx, cancel := context.WithTimeout(ctx, args.sysdiagnoseTimeout)
+	defer cancel()
+	start := time.Now()
+	sysArgs := sysdiagnoseArgs{
+		outputDir: dir,
+		timeout:   args.sysdiagnoseTimeout,
+		limiter:   args.captureLimiter,
+		perms:     args.perms,
+		reason:    "scheduled event " + e.Code,
+		trigger:   map[string]string{"watchdog": scheduledEventsWatchdog, "event-id": e.ID, "event-code": e.Code},
+	}
+	outputPath, err := runSysdiagnose(collectCtx, sysArgs)
+	recordAction(ctx, "watchdog scheduled-events", "sysdiagnose collection for scheduled event "+e.ID, start, err)
+	if err != nil {
+		return fmt.Errorf("sysdiagnose collection: %w", err)
+	}
+	recordCapture(scheduledEventsWatchdog, e.ID, outputPath)
+
+	vars, err := namingVars(ctx, time.Now(), args.upload.key)
+	if err != nil {
+		return fmt.Errorf("cannot resolve naming template placeholders: %w", err)
+	}
+
+	upload := args.upload
+	upload.metadata = sysArgs.metadata()
+
+	return uploadSysdiagnose(ctx, upload, vars, outputPath)
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/actionlog"
+	"github.com/aws/ec2-macos-utils/internal/audit"
+	"github.com/aws/ec2-macos-utils/internal/devtools"
+	"github.com/aws/ec2-macos-utils/internal/diskspace"
+	"github.com/aws/ec2-macos-utils/internal/hardening"
+	"github.com/aws/ec2-macos-utils/internal/imdslatency"
+	"github.com/aws/ec2-macos-utils/internal/jsonschema"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/memory"
+	"github.com/aws/ec2-macos-utils/internal/mtuprobe"
+	"github.com/aws/ec2-macos-utils/internal/neighbors"
+	"github.com/aws/ec2-macos-utils/internal/netconfig"
+	"github.com/aws/ec2-macos-utils/internal/remotedesktop"
+	"github.com/aws/ec2-macos-utils/internal/state"
+	"github.com/aws/ec2-macos-utils/internal/timemachine"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+// schemaVersionField is the field of JSON objects printed by commands that holds the version of their schema.
+const schemaVersionField = "schema_version"
+
+// outputSchema is the versioned schema of the JSON output of a command.
+type outputSchema struct {
+	// version is the schema's version, as major.minor. Within a major version, output only gains fields, so that
+	// consumers of an earlier minor version keep working; removing, renaming, or retyping a field starts a new major
+	// version.
+	version string
+	// value is a value of the type printed, whose encoding the schema describes.
+	value any
+}
+
+// outputSchemas are the schemas of the JSON output of commands, by command path. Every command that prints JSON
+// has one, and its output is printed with printJSON or encodeJSON.
+var outputSchemas = map[string]outputSchema{
+	"audit status":               {version: "1.0", value: audit.Status{}},
+	"batch":                      {version: "1.0", value: batchResult{}},
+	"check disk-space":           {version: "1.0", value: diskspace.Result{}},
+	"check history":              {version: "1.0", value: []state.CheckResult{}},
+	"check neighbors":            {version: "1.0", value: neighbors.Result{}},
+	"debug imds-latency":         {version: "1.0", value: imdslatency.Summary{}},
+	"debug mtu-probe":            {version: "1.0", value: mtuprobe.Result{}},
+	"debug purge-memory":         {version: "1.0", value: memory.PurgeResult{}},
+	"devtools bootstrap":         {version: "1.1", value: devtools.BootstrapReport{}},
+	"firewall status":            {version: "1.0", value: firewallStatus{}},
+	"firstboot run":              {version: "1.0", value: firstbootReport{}},
+	"history":                    {version: "1.0", value: []actionlog.Entry{}},
+	"history verify":             {version: "1.0", value: actionlog.Verification{}},
+	"migrate":                    {version: "1.0", value: migrationReport{}},
+	"network dhcp renew":         {version: "1.0", value: netconfig.DHCPLease{}},
+	"network dhcp status":        {version: "1.0", value: netconfig.DHCPLease{}},
+	"prepare-image":              {version: "1.0", value: prepareImageReport{}},
+	"remote-desktop status":      {version: "1.0", value: remotedesktop.Status{}},
+	"report":                     {version: "1.0", value: report{}},
+	"security gatekeeper-status": {version: "1.0", value: gatekeeperAudit{}},
+	"security harden":            {version: "1.0", value: hardening.Report{}},
+	"selftest":                   {version: "1.0", value: selftestReport{}},
+	"service status":             {version: "1.0", value: launchd.Status{}},
+	"spotlight status":           {version: "1.0", value: []spotlightVolumeStatus{}},
+	"system identity":            {version: "1.0", value: instance.Identity{}},
+	"system tags":                {version: "1.0", value: map[string]string{}},
+	"timemachine status":         {version: "1.0", value: timemachine.Status{}},
+	"uninstall":                  {version: "1.0", value: uninstallReport{}},
+	"updates install":            {version: "1.0", value: updatesResult{}},
+	"updates list":               {version: "1.0", value: updatesResult{}},
+}
+
+// major returns the major version of the schema.
+func (s outputSchema) major() string {
+	major, _, _ := strings.Cut(s.version, ".")
+	return major
+}
+
+// versioned reports whether the output has the schema_version field. Only objects printed from structs do: arrays and
+// maps, e.g. of tags, can't have it without changing the shape they had before schemas were versioned.
+func (s outputSchema) versioned() bool {
+	return reflect.TypeOf(s.value).Kind() == reflect.Struct
+}
+
+// document returns the JSON Schema document of the command's output, whose schema_version field matches every version
+// with the same major version.
+func (s outputSchema) document(command string) *jsonschema.Schema {
+	doc := jsonschema.For(s.value)
+	doc.Schema = jsonschema.Draft
+	doc.ID = fmt.Sprintf("urn:ec2-macos-utils:output:%s:v%s", strings.ReplaceAll(command, " ", "-"), s.major())
+	doc.Title = command
+	doc.Description = fmt.Sprintf("JSON output of %q, schema version %s.", command, s.version)
+	if s.versioned() {
+		doc.Properties[schemaVersionField] = &jsonschema.Schema{
+			Type:    jsonschema.Types{"string"},
+			Pattern: "^" + regexp.QuoteMeta(s.major()+"."),
+		}
+		doc.Required = append(doc.Required, schemaVersionField)
+		sort.Strings(doc.Required)
+	}
+
+	return doc
+}
+
+// printJSON prints v as the command's JSON output.
+func printJSON(cmd *cobra.Command, v any) error {
+	return encodeJSON(cmd.OutOrStdout(), commandPath(cmd), v)
+}
+
+// encodeJSON writes v as the indented JSON output of the command. Objects start with the schema_version field of the
+// command's schema.
+func encodeJSON(w io.Writer, command string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s, ok := outputSchemas[command]; ok && s.versioned() && bytes.HasPrefix(data, []byte("{")) {
+		field, err := json.Marshal(map[string]string{schemaVersionField: s.version})
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data, []byte("{}")) {
+			field[len(field)-1] = ','
+			data = append(field, data[1:]...)
+		} else {
+			data = field
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+
+	return err
+}
+
+// schemaCommand creates a new command which prints the JSON Schema documents of the commands' JSON output.
+func schemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema [command]",
+		Short: "print the JSON Schema documents of JSON output",
+		Long: strings.TrimSpace(`
+schema prints the JSON Schema document of the JSON output of the given
+command, e.g. "schema check history", or, without a command, an object of
+every command's document by command.
+
+JSON objects printed by commands start with a "schema_version" field, such
+as "1.0", which is also in each document. Within a major version, output
+only gains fields, so that integrations written against an earlier minor
+version keep working; removing, renaming, or changing the type of a field
+starts a new major version. Commands that print a JSON array or tags, whose
+shape predates versioning, are versioned by their documents only.
+        `),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if len(args) == 0 {
+			docs := map[string]*jsonschema.Schema{}
+			for command, s := range outputSchemas {
+				docs[command] = s.document(command)
+			}
+			return encoder.Encode(docs)
+		}
+
+		command := strings.Join(args, " ")
+		s, ok := outputSchemas[command]
+		if !ok {
+			return fmt.Errorf("%q doesn't print JSON, commands that do: %s", command, strings.Join(schemaCommands(), ", "))
+		}
+
+		return encoder.Encode(s.document(command))
+	}
+
+	return cmd
+}
+
+// schemaCommands returns the sorted paths of the commands with a JSON output schema.
+func schemaCommands() []string {
+	commands := make([]string, 0, len(outputSchemas))
+	for command := range outputSchemas {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	return commands
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/ec2-macos-utils/internal/jsonschema"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+var updateSchemas = flag.Bool("update-schemas", false, "update the snapshots of the JSON output schemas in testdata")
+
+// TestOutputSchemas_Compatible checks each schema against its snapshot for its major version, so that output stays
+// compatible within a major version and changes come with a new minor version. Snapshots are written with
+// -update-schemas.
+func TestOutputSchemas_Compatible(t *testing.T) {
+	for command, s := range outputSchemas {
+		doc := s.document(command)
+		path := filepath.Join("testdata", "schemas", strings.ReplaceAll(command, " ", "-")+".v"+s.major()+".json")
+		data, err := json.MarshalIndent(doc, "", "  ")
+		require.NoError(t, err)
+
+		if *updateSchemas {
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, append(data, '\n'), 0644))
+			continue
+		}
+
+		snapshot, err := os.ReadFile(path)
+		if !assert.NoError(t, err, "%s: no snapshot of its schema, run the tests with -update-schemas", command) {
+			continue
+		}
+		var older jsonschema.Schema
+		require.NoError(t, json.Unmarshal(snapshot, &older), path)
+		assert.NoError(t, jsonschema.Compatible(&older, doc), "%s: incompatible changes need a new major version", command)
+		if older.Description == doc.Description {
+			assert.JSONEq(t, string(snapshot), string(data), "%s: changes need a new minor version, then run the tests with -update-schemas", command)
+		}
+	}
+}
+
+func TestOutputSchemas_Commands(t *testing.T) {
+	root := MainCommand()
+	for command := range outputSchemas {
+		found, _, err := root.Find(strings.Fields(command))
+		if assert.NoError(t, err, command) {
+			assert.Equal(t, command, commandPath(found))
+		}
+	}
+
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if cmd.Flags().Lookup("json") != nil {
+			assert.Contains(t, outputSchemas, commandPath(cmd), "commands that print JSON need a schema")
+		}
+		for _, c := range cmd.Commands() {
+			walk(c)
+		}
+	}
+	walk(root)
+}
+
+func TestEncodeJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, encodeJSON(&buf, "history verify", struct {
+		OK bool `json:"ok"`
+	}{OK: true}))
+	assert.Equal(t, "{\n  \"schema_version\": \"1.0\",\n  \"ok\": true\n}\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, encodeJSON(&buf, "history verify", struct{}{}))
+	assert.Equal(t, "{\n  \"schema_version\": \"1.0\"\n}\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, encodeJSON(&buf, "system tags", map[string]string{"Name": "mac"}))
+	assert.Equal(t, "{\n  \"Name\": \"mac\"\n}\n", buf.String(), "maps shouldn't be versioned")
+
+	buf.Reset()
+	require.NoError(t, encodeJSON(&buf, "check history", []state.CheckResult(nil)))
+	assert.Equal(t, "null\n", buf.String())
+}
@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/gatekeeper"
+	"github.com/aws/ec2-macos-utils/internal/hardening"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+// securityCommand creates a new command which groups security policy utilities.
+func securityCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "security",
+		Short: "security policy utilities",
+		Long:  "utilities for managing the Gatekeeper execution policy and hardening baselines",
+	}
+
+	cmd.AddCommand(
+		securitySetGatekeeperCommand(),
+		securityGatekeeperStatusCommand(),
+		securityHardenCommand(),
+	)
+
+	return cmd
+}
+
+// securitySetGatekeeperCommand creates a new command which sets the Gatekeeper policy and app exemptions.
+func securitySetGatekeeperCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-gatekeeper {" + strings.Join(gatekeeper.Policies, ",") + "}",
+		Short: "set the Gatekeeper execution policy",
+		Long: strings.TrimSpace(`
+set-gatekeeper sets the policy apps are assessed with before they run:
+
+  app-store         only apps from the App Store are allowed
+  allow-identified  apps from the App Store and from identified
+                    developers (signed with a Developer ID) are
+                    allowed, the macOS default
+  disable           assessments are turned off and any app is allowed
+
+With --exempt, the apps at the paths, e.g. internally signed tools, are
+allowed to run whatever the policy. The exemptions replace those set
+before, and --clear-exemptions removes them. Since flags can be set in
+the configuration file, a fleet can manage the policy and exemptions
+centrally, e.g.:
+
+  {"commands": {"security set-gatekeeper": {"exempt": ["/opt/tools/Runner.app"]}}}
+
+Recent macOS releases ask for confirmation in System Settings before
+assessments are turned off.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: gatekeeper.Policies,
+		PreRunE:   assertRootPrivileges,
+	}
+
+	var (
+		exempt          []string
+		clearExemptions bool
+	)
+	cmd.Flags().StringSliceVar(&exempt, "exempt", nil, "path of an app allowed to run whatever the policy (repeatable)")
+	cmd.Flags().BoolVar(&clearExemptions, "clear-exemptions", false, "remove the app exemptions")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if clearExemptions && len(exempt) > 0 {
+			return errors.New("--exempt and --clear-exemptions cannot be used together")
+		}
+
+		policy := args[0]
+		if err := gatekeeper.SetPolicy(cmd.Context(), policy); err != nil {
+			return err
+		}
+		logrus.WithField("policy", policy).Info("Set Gatekeeper policy")
+
+		if len(exempt) == 0 && !clearExemptions {
+			return nil
+		}
+		if err := gatekeeper.SetExemptions(cmd.Context(), exempt); err != nil {
+			return err
+		}
+		logrus.WithField("apps", exempt).Info("Set Gatekeeper exemptions")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// gatekeeperAudit is the assessment policy and the verdicts of assessed apps.
+type gatekeeperAudit struct {
+	gatekeeper.Status
+	Assessments []gatekeeper.Assessment `json:"assessments,omitempty"`
+}
+
+// securityGatekeeperStatusCommand creates a new command which audits the Gatekeeper policy.
+func securityGatekeeperStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gatekeeper-status [path]...",
+		Short: "audit the Gatekeeper execution policy",
+		Long: strings.TrimSpace(`
+gatekeeper-status prints the current assessment policy: whether
+assessments are enabled, whether apps from identified developers are
+allowed, the matching set-gatekeeper policy, and the number of app
+exemptions. Apps given as arguments are assessed and whether they're
+allowed to run is printed along with the kind of signature the verdict
+is based on. The audit is printed as tables or, with --json, a JSON
+object.
+        `),
+	}
+
+	var asJSON bool
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the audit as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, paths []string) error {
+		status, err := gatekeeper.GetStatus(cmd.Context())
+		if err != nil {
+			return err
+		}
+		audit := gatekeeperAudit{Status: status}
+		for _, path := range paths {
+			a, err := gatekeeper.Assess(cmd.Context(), path)
+			if err != nil {
+				return err
+			}
+			audit.Assessments = append(audit.Assessments, a)
+		}
+
+		if asJSON {
+			return printJSON(cmd, audit)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		onOff := func(on bool) string {
+			if on {
+				return "on"
+			}
+			return "off"
+		}
+		table := output.NewTable(styler, "setting", "state")
+		table.AddRow("assessments", onOff(status.Assessments))
+		table.AddRow("identified developers", onOff(status.DeveloperID))
+		table.AddRow("policy", status.Policy)
+		table.AddRow("exemptions", fmt.Sprint(status.Exemptions))
+		if err := table.Render(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+		if len(audit.Assessments) == 0 {
+			return nil
+		}
+
+		fmt.Fprintln(cmd.OutOrStdout())
+		table = output.NewTable(styler, "path", "verdict", "source")
+		for _, a := range audit.Assessments {
+			verdict := styler.Bad("rejected")
+			if a.Accepted {
+				verdict = styler.Good("accepted")
+			}
+			table.AddRow(a.Path, verdict, a.Source)
+		}
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
+
+// securityHardenCommand creates a new command which applies a hardening baseline.
+func securityHardenCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "harden",
+		Short: "apply a hardening baseline",
+		Long: strings.TrimSpace(`
+harden applies the curated hardening settings of --profile that aren't
+already in place and prints a report of every setting, so that a fleet
+gets an auditable baseline from one command. Settings already in place
+are left alone, so the command can be run repeatedly, e.g. at every boot.
+
+The cis-level1 profile follows the CIS Apple macOS Benchmark Level 1
+recommendations that don't get in the way of managing instances:
+
+  screen-lock-idle      screen saver starts after 20 minutes or less
+  screen-lock-password  password is required 5 seconds or less after
+                        the screen saver starts
+  firewall              application firewall is enabled
+  firewall-stealth      application firewall stealth mode is on
+  auditing              security auditing (auditd) is enabled
+  guest-account         guest account is disabled
+  gatekeeper            Gatekeeper assesses apps before they run,
+                        allowing apps from identified developers
+  remote-apple-events   remote Apple events are disabled
+
+SSH, Screen Sharing, and automatic login are left as they are since
+instances are managed through them.
+
+The screen lock settings are checked as they're in effect: as enforced
+by a configuration profile, or otherwise as the user logged in on the
+console set them, so they can't be checked when nobody is logged in.
+The idle time is applied to the console user; requiring the password
+can only be done with a configuration profile, which harden reports
+rather than applies.
+
+With --report-only, nothing is changed and the report scores the drift
+from the profile; the command fails if any setting has drifted. The
+report is printed as a table or, with --json, a JSON object.
+
+The settings made compliant are recorded in a journal as they're
+applied. When a run is interrupted, e.g. by a crash or a reboot, --resume
+continues it without checking those settings again, and reports them as
+the interrupted run left them.
+
+This command requires root privileges, except with --report-only. Run
+with sudo if not running as root.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+	}
+
+	var (
+		profile    string
+		reportOnly bool
+		asJSON     bool
+		resume     bool
+	)
+	cmd.Flags().StringVar(&profile, "profile", "", "hardening profile: "+strings.Join(hardening.Profiles(), ", "))
+	cmd.Flags().BoolVar(&reportOnly, "report-only", false, "report the drift from the profile without changing anything")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON")
+	addResumeFlag(cmd.Flags(), &resume)
+	_ = cmd.MarkFlagRequired("profile")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if _, err := hardening.Controls(profile); err != nil {
+			return err
+		}
+		if reportOnly && resume {
+			return errors.New("--resume can't be used with --report-only")
+		}
+		// Only runs that apply settings are journaled since reports don't change anything.
+		var journal hardening.Journal
+		if !reportOnly {
+			if err := assertRootPrivileges(cmd, nil); err != nil {
+				return err
+			}
+			j, err := beginJournal(cmd, profile, resume)
+			if err != nil {
+				return err
+			}
+			defer j.Finish()
+			journal = j
+		}
+
+		report, err := hardening.Run(cmd.Context(), profile, !reportOnly, journal)
+		if err != nil {
+			return err
+		}
+		if err := printHardeningReport(cmd, report, asJSON); err != nil {
+			return err
+		}
+
+		drifted := report.Drifted()
+		if len(drifted) == 0 {
+			return nil
+		}
+		if reportOnly {
+			return fmt.Errorf("%d of %d settings have drifted from %s: %s", len(drifted), len(report.Results), profile, strings.Join(drifted, ", "))
+		}
+
+		return fmt.Errorf("%d of %d settings couldn't be applied: %s", len(drifted), len(report.Results), strings.Join(drifted, ", "))
+	}
+
+	return cmd
+}
+
+// printHardeningReport prints the report as a table followed by its score, or as JSON.
+func printHardeningReport(cmd *cobra.Command, report hardening.Report, asJSON bool) error {
+	if asJSON {
+		return printJSON(cmd, report)
+	}
+
+	styler := contextual.Styler(cmd.Context())
+	table := output.NewTable(styler, "setting", "status", "description")
+	for _, result := range report.Results {
+		var status string
+		switch {
+		case result.Error != "":
+			status = styler.Bad("error: " + result.Error)
+		case !result.Compliant:
+			status = styler.Bad("drifted")
+		case result.Applied:
+			status = styler.Caution("applied")
+		default:
+			status = styler.Good("compliant")
+		}
+		table.AddRow(result.ID, status, result.Description)
+	}
+	if err := table.Render(cmd.OutOrStdout()); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "\nScore: %d%% (%d of %d settings compliant with %s)\n", report.Score, report.Compliant, len(report.Results), report.Profile)
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/selfupdate"
+)
+
+// selfUpdateDefaultTimeout bounds the manifest fetch and binary download.
+const selfUpdateDefaultTimeout = 5 * time.Minute
+
+// selfUpdateArgs is a struct for holding all information passed into the self-update command.
+type selfUpdateArgs struct {
+	manifestURL string
+	checkOnly   bool
+	force       bool
+	timeout     time.Duration
+}
+
+// selfUpdateCommand creates a new command which replaces the running executable with the latest verified release.
+func selfUpdateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "update to the latest release",
+		Long: strings.TrimSpace(`
+self-update fetches the signed release manifest, verifies its
+signature and the checksum of the release binary for this platform,
+and atomically replaces the running executable.
+
+Use --check-only to report whether an update is available without
+installing it. Replacing the executable typically requires root
+privileges.
+        `),
+	}
+
+	var args selfUpdateArgs
+	cmd.Flags().StringVar(&args.manifestURL, "manifest-url", build.UpdateManifestURL, "HTTPS URL of the signed release manifest (S3 or GitHub)")
+	cmd.Flags().BoolVar(&args.checkOnly, "check-only", false, "only report whether an update is available")
+	cmd.Flags().BoolVar(&args.force, "force", false, "install the release even if it is not newer than the running version")
+	cmd.Flags().DurationVar(&args.timeout, "timeout", selfUpdateDefaultTimeout, "set the timeout for the update (e.g. 30s, 5m)")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if args.manifestURL == "" {
+			return errors.New("no release manifest URL configured, provide --manifest-url")
+		}
+		key, err := selfupdate.ParsePublicKey(build.UpdatePublicKey)
+		if err != nil {
+			return fmt.Errorf("cannot verify releases: %w", err)
+		}
+
+		updater := &selfupdate.Updater{
+			Client:    &http.Client{Timeout: args.timeout},
+			PublicKey: key,
+		}
+
+		logrus.WithField("manifest_url", args.manifestURL).Info("Checking for updates...")
+		manifest, err := updater.FetchManifest(cmd.Context(), args.manifestURL)
+		if err != nil {
+			return err
+		}
+
+		newer, compareErr := manifest.NewerThan(build.Version)
+		if compareErr != nil {
+			logrus.WithError(compareErr).Warn("Unable to compare release versions")
+		}
+		logrus.WithFields(logrus.Fields{
+			"current": build.Version,
+			"latest":  manifest.Version,
+		}).Info("Fetched release manifest")
+
+		if args.checkOnly {
+			switch {
+			case compareErr != nil:
+				fmt.Fprintf(cmd.OutOrStdout(), "cannot compare versions: %s, latest %s\n", build.Version, manifest.Version)
+			case newer:
+				fmt.Fprintf(cmd.OutOrStdout(), "update available: %s -> %s\n", build.Version, manifest.Version)
+			default:
+				fmt.Fprintf(cmd.OutOrStdout(), "up to date: %s\n", build.Version)
+			}
+			return nil
+		}
+
+		if compareErr != nil && !args.force {
+			return fmt.Errorf("cannot compare versions, use --force to install %s anyway: %w", manifest.Version, compareErr)
+		}
+		if !newer && !args.force {
+			logrus.Info("Already running the latest release, nothing to do")
+			return nil
+		}
+
+		artifact, err := manifest.Artifact(selfupdate.Platform())
+		if err != nil {
+			return err
+		}
+
+		target, err := executablePath()
+		if err != nil {
+			return err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"version": manifest.Version,
+			"target":  target,
+		}).Info("Installing update...")
+		if err := updater.Install(cmd.Context(), artifact, target); err != nil {
+			return fmt.Errorf("cannot install update: %w", err)
+		}
+		logrus.WithField("version", manifest.Version).Info("Successfully updated")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// executablePath resolves the path of the running executable through any symlinks (e.g. Homebrew links).
+func executablePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate executable: %w", err)
+	}
+	resolved, err := filepath.EvalSymlinks(exe)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve executable path: %w", err)
+	}
+
+	return resolved, nil
+}
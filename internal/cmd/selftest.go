@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/logexport"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+// selftestDefaultTimeout is the default time limit of each subsystem's test.
+const selftestDefaultTimeout = 30 * time.Second
+
+// selftestSubsystem is a subsystem exercised by the selftest command.
+type selftestSubsystem struct {
+	name string
+	// run exercises the subsystem without changing the host and returns a short description of what it found.
+	run func(ctx context.Context, cmd *cobra.Command) (string, error)
+}
+
+// selftestSubsystems returns the subsystems exercised by the selftest command, in the order they're tested.
+func selftestSubsystems() []selftestSubsystem {
+	return []selftestSubsystem{
+		{name: "disk", run: selftestDisk},
+		{name: "imds", run: selftestIMDS},
+		{name: "archive", run: selftestArchive},
+		{name: "config", run: selftestConfig},
+	}
+}
+
+// selftestReport is the outcome of the selftest command.
+type selftestReport struct {
+	Passed     bool             `json:"passed"`
+	Subsystems []selftestResult `json:"subsystems"`
+}
+
+// selftestResult is the outcome of the test of a subsystem.
+type selftestResult struct {
+	Name       string `json:"name"`
+	Passed     bool   `json:"passed"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// selftestCommand creates a new command which exercises the utility's subsystems on the host.
+func selftestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "selftest",
+		Short: "exercise the utility's subsystems on this host",
+		Long: strings.TrimSpace(`
+selftest exercises the main subsystems of the utility against the host in
+a way that doesn't change it, and prints whether each passed:
+
+  disk     lists the disks and volumes with diskutil, read-only
+  imds     reads the instance identity document from IMDS
+  archive  writes a small archive to a temporary directory and reads it back
+  config   loads the --config source and applies it to every command's flags
+
+It's meant to validate that a newly built AMI installed the utility
+correctly. The command fails if any subsystem fails, and prints a JSON
+object with --json.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+	}
+
+	var (
+		asJSON  bool
+		timeout time.Duration
+	)
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the results as JSON")
+	cmd.Flags().DurationVar(&timeout, "timeout", selftestDefaultTimeout, "time limit of each subsystem's test")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+
+		report := runSelftest(cmd, selftestSubsystems(), timeout)
+		if asJSON {
+			if err := printJSON(cmd, report); err != nil {
+				return err
+			}
+		} else {
+			styler := contextual.Styler(cmd.Context())
+			for _, r := range report.Subsystems {
+				if r.Passed {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s  %s: %s\n", styler.Status(true), r.Name, r.Detail)
+				} else {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s  %s: %s\n", styler.Status(false), r.Name, r.Error)
+				}
+			}
+		}
+
+		var failed []string
+		for _, r := range report.Subsystems {
+			if !r.Passed {
+				failed = append(failed, r.Name)
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("%d of %d subsystems failed: %s", len(failed), len(report.Subsystems), strings.Join(failed, ", "))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// runSelftest tests each subsystem in turn, limiting each test to the timeout. A failure doesn't stop the tests of
+// the subsystems after it.
+func runSelftest(cmd *cobra.Command, subsystems []selftestSubsystem, timeout time.Duration) selftestReport {
+	report := selftestReport{Passed: true}
+	for _, s := range subsystems {
+		ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+		start := time.Now()
+		detail, err := s.run(ctx, cmd)
+		cancel()
+
+		result := selftestResult{
+			Name:       s.name,
+			Passed:     err == nil,
+			Detail:     detail,
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			result.Error = err.Error()
+			report.Passed = false
+		}
+		report.Subsystems = append(report.Subsystems, result)
+	}
+
+	return report
+}
+
+// selftestDisk lists the disks and volumes with diskutil, which can't change them in dry-run mode.
+func selftestDisk(ctx context.Context, _ *cobra.Command) (string, error) {
+	product := contextual.Product(ctx)
+	if product == nil {
+		return "", errors.New("product required in context")
+	}
+	d, err := diskutil.ForProduct(product)
+	if err != nil {
+		return "", err
+	}
+
+	partitions, err := diskutil.Dryrun(d).List(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(partitions.AllDisksAndPartitions) == 0 {
+		return "", errors.New("diskutil listed no disks")
+	}
+
+	volumes := 0
+	for _, disk := range partitions.AllDisksAndPartitions {
+		volumes += len(disk.APFSVolumes)
+	}
+
+	return fmt.Sprintf("%d disks, %d APFS volumes on %s", len(partitions.AllDisksAndPartitions), volumes, product), nil
+}
+
+// selftestIMDS reads the instance identity document from IMDS.
+func selftestIMDS(ctx context.Context, _ *cobra.Command) (string, error) {
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return "", err
+	}
+	identity, err := (&instance.IdentityReader{IMDS: imds.NewFromConfig(cfg)}).Identity(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s (%s) in %s", identity.InstanceID, identity.InstanceType, identity.AvailabilityZone), nil
+}
+
+// selftestArchive writes a bundle with a single small file to a temporary directory, and reads it back.
+func selftestArchive(ctx context.Context, _ *cobra.Command) (string, error) {
+	dir, err := os.MkdirTemp("", "ec2-macos-utils-selftest-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	const name, content = "selftest.txt", "ec2-macos-utils selftest\n"
+	path := filepath.Join(dir, "selftest.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	results, err := logexport.WriteBundle(ctx, f, []logexport.Collector{{
+		Name: name,
+		Collect: func(_ context.Context, w io.Writer) error {
+			_, err := io.WriteString(w, content)
+			return err
+		},
+	}})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			return "", fmt.Errorf("collect %s: %s", r.Name, r.Error)
+		}
+	}
+
+	files, err := readTarball(path)
+	if err != nil {
+		return "", fmt.Errorf("read archive: %w", err)
+	}
+	if !bytes.Equal(files[name], []byte(content)) {
+		return "", fmt.Errorf("archive doesn't hold %s as written", name)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("wrote and read back %d files in %d bytes", len(files), info.Size()), nil
+}
+
+// readTarball returns the contents of the regular files in the gzip-compressed tarball at path, by name.
+func readTarball(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if files[header.Name], err = io.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// selftestConfig loads the configuration from the --config source and applies it, and each of its profiles, to the
+// flags of every command of a separate command tree, so that invalid values are found without changing how this
+// command runs. A missing default configuration file passes, since it's optional.
+func selftestConfig(ctx context.Context, cmd *cobra.Command) (string, error) {
+	source := config.DefaultPath
+	explicit := false
+	if flag := cmd.Root().PersistentFlags().Lookup("config"); flag != nil {
+		source, explicit = flag.Value.String(), flag.Changed
+	}
+
+	c, err := configLoader(contextual.AWSOptions(ctx)).Load(ctx, source)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return fmt.Sprintf("no configuration at %s, the defaults apply", source), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	commands := 0
+	var walk func(cmd *cobra.Command) error
+	walk = func(cmd *cobra.Command) error {
+		// Inherited flags are merged into the command's flags, as they are when it's run.
+		cmd.InheritedFlags()
+		if err := c.Apply(commandPath(cmd), cmd.Flags()); err != nil {
+			return fmt.Errorf("configuration of %q: %w", commandPath(cmd), err)
+		}
+		commands++
+		for _, child := range cmd.Commands() {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(MainCommand()); err != nil {
+		return "", err
+	}
+
+	profiles := 0
+	for path, named := range c.Profiles {
+		for profile := range named {
+			target, _, err := MainCommand().Find(strings.Fields(path))
+			if err != nil || commandPath(target) != path {
+				return "", fmt.Errorf("profile %q is for unknown command %q", profile, path)
+			}
+			target.InheritedFlags()
+			if err := c.ApplyProfile(path, profile, target.Flags()); err != nil {
+				return "", err
+			}
+			if err := c.Apply(path, target.Flags()); err != nil {
+				return "", fmt.Errorf("profile %q of %s: %w", profile, path, err)
+			}
+			profiles++
+		}
+	}
+
+	return fmt.Sprintf("applied %s to %d commands and %d profiles", source, commands, profiles), nil
+}
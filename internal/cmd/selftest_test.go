@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSelftest(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	var ran []string
+	report := runSelftest(cmd, []selftestSubsystem{
+		{name: "fails", run: func(context.Context, *cobra.Command) (string, error) {
+			ran = append(ran, "fails")
+			return "", errors.New("broken")
+		}},
+		{name: "passes", run: func(ctx context.Context, _ *cobra.Command) (string, error) {
+			ran = append(ran, "passes")
+			_, ok := ctx.Deadline()
+			assert.True(t, ok, "tests should be limited by the timeout")
+			return "fine", nil
+		}},
+	}, time.Minute)
+
+	assert.Equal(t, []string{"fails", "passes"}, ran, "a failure shouldn't stop later tests")
+	assert.False(t, report.Passed)
+	require.Len(t, report.Subsystems, 2)
+	for i := range report.Subsystems {
+		report.Subsystems[i].DurationMS = 0
+	}
+	assert.Equal(t, selftestResult{Name: "fails", Error: "broken"}, report.Subsystems[0])
+	assert.Equal(t, selftestResult{Name: "passes", Passed: true, Detail: "fine"}, report.Subsystems[1])
+}
+
+func TestSelftestArchive(t *testing.T) {
+	detail, err := selftestArchive(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Contains(t, detail, "wrote and read back 2 files")
+}
+
+func TestSelftestConfig(t *testing.T) {
+	root := MainCommand()
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, root.PersistentFlags().Set("config", path))
+
+	_, err := selftestConfig(context.Background(), root)
+	assert.Error(t, err, "an explicit configuration is required")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"global": {"verbose": true}, "commands": {"selftest": {"timeout": "1m"}}}`), 0600))
+	detail, err := selftestConfig(context.Background(), root)
+	assert.NoError(t, err)
+	assert.Contains(t, detail, "0 profiles")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"commands": {"selftest": {"timeout": "soon"}}}`), 0600))
+	_, err = selftestConfig(context.Background(), root)
+	assert.ErrorContains(t, err, `configuration of "selftest"`)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"profiles": {"no such command": {"ci": {}}}}`), 0600))
+	_, err = selftestConfig(context.Background(), root)
+	assert.ErrorContains(t, err, "unknown command")
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/serialconsole"
+)
+
+// serialConsoleArgs is a struct for holding the arguments for the serial-console command.
+type serialConsoleArgs struct {
+	user                string
+	configure           bool
+	enableAccountAccess bool
+}
+
+// serialConsoleCommand creates a new command which prepares the instance for the EC2 serial console.
+func serialConsoleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serial-console",
+		Short: "verify and configure EC2 serial console access",
+		Long: strings.TrimSpace(`
+serial-console verifies the prerequisites for connecting to the instance
+through the EC2 serial console, which works when the instance's network
+doesn't, and prints the connection instructions.
+
+The prerequisites are:
+  - serial console access is enabled for the account in the region
+  - the console is directed to the serial port (boot-args serial=3)
+  - the login user has a password, since SSH keys aren't used on the console
+
+With --configure, the console is directed to the serial port, which takes
+effect on the next boot. Serial console access is account-wide, so it's
+only enabled with --enable-account-access. Passwords aren't set by this
+command; set one with 'sudo passwd <user>'.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		SilenceUsage: true,
+	}
+
+	var args serialConsoleArgs
+	cmd.Flags().StringVar(&args.user, "user", "ec2-user", "local user that logs in on the serial console")
+	cmd.Flags().BoolVar(&args.configure, "configure", false, "direct the console to the serial port from the next boot")
+	cmd.Flags().BoolVar(&args.enableAccountAccess, "enable-account-access", false, "enable serial console access for the account in the region")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		var failed []string
+		result := func(name string, err error) {
+			printCheckResult(cmd, name, err)
+			if err != nil {
+				failed = append(failed, name)
+			}
+		}
+
+		cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+		if err != nil {
+			return err
+		}
+		client := ec2.NewFromConfig(cfg)
+
+		enabled, err := serialconsole.AccountAccessEnabled(ctx, client)
+		if err == nil && !enabled && args.enableAccountAccess {
+			logrus.WithField("region", cfg.Region).Info("Enabling serial console access for the account")
+			if err = serialconsole.EnableAccountAccess(ctx, client); err == nil {
+				enabled = true
+			}
+		}
+		if err == nil && !enabled {
+			err = fmt.Errorf("disabled in %s, enable with --enable-account-access", cfg.Region)
+		}
+		result("account access", err)
+
+		if args.configure {
+			if err := serialconsole.EnableSerial(ctx); err != nil {
+				return err
+			}
+		}
+		bootArgs, err := serialconsole.BootArgs(ctx)
+		if err == nil && !serialconsole.SerialEnabled(bootArgs) {
+			err = fmt.Errorf("boot-args %q don't include serial=3, configure with --configure", bootArgs)
+		}
+		result("serial boot-args", err)
+
+		hasPassword, err := serialconsole.HasPassword(ctx, args.user)
+		if err == nil && !hasPassword {
+			err = fmt.Errorf("%s has no password, set one with 'sudo passwd %s'", args.user, args.user)
+		}
+		result("user password", err)
+
+		instanceID, err := aws.InstanceID(ctx, cfg)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to get instance ID")
+			instanceID = "<instance-id>"
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "\n%s\n", serialconsole.Instructions(instanceID, cfg.Region))
+
+		if len(failed) > 0 {
+			return fmt.Errorf("serial console prerequisites not met: %s", strings.Join(failed, ", "))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/output"
+)
+
+// serviceCommand creates a new command which groups launchd job utilities.
+func serviceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "launchd daemon and agent management",
+		Long:  "utilities for installing, removing, and inspecting launchd daemons and agents",
+	}
+
+	cmd.AddCommand(
+		serviceInstallCommand(),
+		serviceRemoveCommand(),
+		serviceStatusCommand(),
+	)
+
+	return cmd
+}
+
+// serviceInstallCommand creates a new command which installs a launchd job from a spec.
+func serviceInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "install a launchd daemon or agent",
+		Long: strings.TrimSpace(`
+install renders a launchd property list from a JSON spec, installs it
+in /Library/LaunchDaemons or /Library/LaunchAgents, and loads it. An
+installed job with the same label is replaced. For example:
+
+  {
+    "kind": "daemon",
+    "label": "com.example.ci-runner",
+    "program_arguments": ["/usr/local/bin/ci-runner", "--serve"],
+    "run_at_load": true,
+    "keep_alive": true,
+    "user_name": "ci",
+    "working_directory": "/Users/ci",
+    "environment": {"RUNNER_POOL": "mac"},
+    "stdout_path": "/var/log/ci-runner.log",
+    "stderr_path": "/var/log/ci-runner.log"
+  }
+
+"kind" is daemon (runs at boot, as root unless "user_name" is set) or
+agent (runs in each user's login session). "start_interval",
+"throttle_interval", and, for agents, "session_type" (Aqua, Background,
+or LoginWindow) are also supported. Agents are loaded for the user
+logged in on the console, if any, and for others when they log in.
+
+With --scope user, the job is installed as an agent of the --user user
+only, in their ~/Library/LaunchAgents, and loaded if they're logged in.
+Unless the spec sets "session_type", it runs in the user's Aqua (GUI)
+session, as needed by agents that interact with the desktop, and not in
+their SSH sessions. "kind" can be omitted or set to agent.
+
+The spec is read from --spec, or stdin with --spec -. With --dry-run,
+the property list is printed instead of installed.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		specPath string
+		dryRun   bool
+		scope    serviceScope
+	)
+	cmd.Flags().StringVar(&specPath, "spec", "", "JSON spec of the job, or - to read it from stdin")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the property list instead of installing it")
+	scope.addFlags(cmd)
+	_ = cmd.MarkFlagRequired("spec")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		spec, err := readServiceSpec(cmd, specPath)
+		if err != nil {
+			return err
+		}
+		user, err := scope.userName()
+		if err != nil {
+			return err
+		}
+		if user != "" {
+			if spec.Kind == "" {
+				spec.Kind = launchd.Agent
+			}
+			if spec.Kind != launchd.Agent {
+				return fmt.Errorf("only agents can be installed with --scope user, got kind %q", spec.Kind)
+			}
+			if spec.SessionType == "" {
+				spec.SessionType = launchd.AquaSession
+			}
+			spec.User = user
+		}
+		if err := spec.Validate(); err != nil {
+			return err
+		}
+
+		if dryRun {
+			data, err := launchd.Render(spec)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return nil
+		}
+
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+		path, err := launchd.Install(cmd.Context(), spec)
+		if err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"label": spec.Label,
+			"path":  path,
+			"scope": scope.scope,
+		}).Infof("Installed %s", spec.Kind)
+
+		return nil
+	}
+
+	return cmd
+}
+
+// readServiceSpec decodes the job spec from the file at path, or stdin when path is "-".
+func readServiceSpec(cmd *cobra.Command, path string) (launchd.Spec, error) {
+	var r io.Reader = cmd.InOrStdin()
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return launchd.Spec{}, fmt.Errorf("cannot read spec: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	var spec launchd.Spec
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return launchd.Spec{}, fmt.Errorf("invalid spec: %w", err)
+	}
+
+	return spec, nil
+}
+
+// serviceKind returns the job kind selected by the --agent flag.
+func serviceKind(agent bool) string {
+	if agent {
+		return launchd.Agent
+	}
+
+	return launchd.Daemon
+}
+
+// Scopes of launchd jobs.
+const (
+	serviceSystemScope = "system"
+	serviceUserScope   = "user"
+)
+
+// serviceScope holds the --scope and --user flags which select between jobs for every user and agents of a single
+// user.
+type serviceScope struct {
+	scope string
+	user  string
+}
+
+func (s *serviceScope) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.scope, "scope", serviceSystemScope, "system for jobs of every user, or user for an agent of --user only")
+	cmd.Flags().StringVar(&s.user, "user", "", "user whose agent it is, with --scope user")
+}
+
+// userName returns the user whose agent is selected, or "" for jobs of every user.
+func (s *serviceScope) userName() (string, error) {
+	switch s.scope {
+	case serviceSystemScope:
+		if s.user != "" {
+			return "", errors.New("--user can only be used with --scope user")
+		}
+		return "", nil
+	case serviceUserScope:
+		if s.user == "" {
+			return "", errors.New("--user is required with --scope user")
+		}
+		return s.user, nil
+	default:
+		return "", fmt.Errorf("unknown scope %q, expected %s or %s", s.scope, serviceSystemScope, serviceUserScope)
+	}
+}
+
+// kind returns the job kind selected by the --agent flag, which is always agent with --scope user.
+func (s *serviceScope) kind(agent bool) string {
+	if s.scope == serviceUserScope {
+		return launchd.Agent
+	}
+
+	return serviceKind(agent)
+}
+
+// serviceRemoveCommand creates a new command which removes a launchd job.
+func serviceRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <label>",
+		Short: "remove a launchd daemon or agent",
+		Long: strings.TrimSpace(`
+remove unloads a daemon, or an agent with --agent, and deletes its
+property list. With --scope user, the agent installed for the --user
+user only is removed.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var (
+		agent bool
+		scope serviceScope
+	)
+	cmd.Flags().BoolVar(&agent, "agent", false, "remove an agent instead of a daemon")
+	scope.addFlags(cmd)
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		user, err := scope.userName()
+		if err != nil {
+			return err
+		}
+		kind := scope.kind(agent)
+		if err := launchd.Remove(cmd.Context(), kind, user, args[0]); err != nil {
+			return err
+		}
+		logrus.WithField("label", args[0]).Infof("Removed %s", kind)
+
+		return nil
+	}
+
+	return cmd
+}
+
+// serviceStatusCommand creates a new command which prints the state of a launchd job.
+func serviceStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status <label>",
+		Short: "print the state of a launchd daemon or agent",
+		Long: strings.TrimSpace(`
+status prints whether a daemon, or an agent with --agent, is installed
+and loaded, its state, process ID, and last exit code, as a table or,
+with --json, a JSON object. Agents are looked up for the user logged in
+on the console or, with --scope user, among the agents installed for
+the --user user only.
+        `),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var (
+		agent, asJSON bool
+		scope         serviceScope
+	)
+	cmd.Flags().BoolVar(&agent, "agent", false, "look up an agent instead of a daemon")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the status as JSON")
+	scope.addFlags(cmd)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		user, err := scope.userName()
+		if err != nil {
+			return err
+		}
+		status, err := launchd.GetStatus(cmd.Context(), scope.kind(agent), user, args[0])
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(cmd, status)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		table := output.NewTable(styler, "label", "installed", "loaded", "state", "pid", "last exit code")
+		pid := "-"
+		if status.PID != 0 {
+			pid = strconv.Itoa(status.PID)
+		}
+		loaded := styler.Caution("false")
+		if status.Loaded {
+			loaded = styler.Good("true")
+		}
+		table.AddRow(status.Label, strconv.FormatBool(status.Installed), loaded, orDash(status.State), pid, orDash(status.LastExitCode))
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
+
+// orDash returns s, or "-" when it's empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
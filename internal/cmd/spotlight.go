@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/spotlight"
+)
+
+// spotlightCommand creates a new command which groups Spotlight indexing utilities.
+func spotlightCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "spotlight",
+		Short: "Spotlight indexing utilities",
+		Long:  "utilities for controlling Spotlight indexing of volumes",
+	}
+
+	var statePath string
+	cmd.PersistentFlags().StringVar(&statePath, "state-file", spotlight.DefaultStatePath, "file where the desired indexing state is saved")
+
+	cmd.AddCommand(
+		spotlightSetCommand(&statePath, false),
+		spotlightSetCommand(&statePath, true),
+		spotlightStatusCommand(&statePath),
+	)
+
+	return cmd
+}
+
+// spotlightSetCommand creates a new command which turns indexing of volumes on (enable) or off (disable).
+func spotlightSetCommand(statePath *string, enabled bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "turn Spotlight indexing off",
+		Long: strings.TrimSpace(`
+disable turns Spotlight indexing of the --volume volumes off and erases
+their index, so that mds doesn't spend CPU and IO indexing ephemeral
+build directories.
+
+The volumes' indexing state is saved as the desired state, and 'check
+spotlight' (and 'check all') fails when it has drifted, e.g. because a
+macOS update turned indexing back on.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+	if enabled {
+		cmd.Use = "enable"
+		cmd.Short = "turn Spotlight indexing on"
+		cmd.Long = strings.TrimSpace(`
+enable turns Spotlight indexing of the --volume volumes on, after which
+mds rebuilds their index.
+
+The volumes' indexing state is saved as the desired state, and 'check
+spotlight' (and 'check all') fails when it has drifted.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `)
+	}
+
+	var volumes []string
+	cmd.Flags().StringSliceVar(&volumes, "volume", []string{"/"}, "mount point of the volume (repeatable)")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		state, err := spotlight.LoadState(*statePath)
+		if err != nil {
+			return err
+		}
+		for _, volume := range volumes {
+			if err := spotlight.SetIndexing(cmd.Context(), volume, enabled); err != nil {
+				return err
+			}
+			state[volume] = enabled
+			logrus.WithFields(logrus.Fields{
+				"volume":  volume,
+				"enabled": enabled,
+			}).Info("Set Spotlight indexing")
+		}
+
+		return state.Save(*statePath)
+	}
+
+	return cmd
+}
+
+// spotlightVolumeStatus is the indexing state of a volume.
+type spotlightVolumeStatus struct {
+	Volume  string `json:"volume"`
+	Enabled bool   `json:"enabled"`
+	// Desired is the saved desired state, nil when there's none.
+	Desired *bool `json:"desired,omitempty"`
+}
+
+// spotlightStatusCommand creates a new command which prints the indexing state of volumes.
+func spotlightStatusCommand(statePath *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "print the Spotlight indexing state",
+		Long: strings.TrimSpace(`
+status prints whether Spotlight indexing is enabled for the --volume
+volumes, and those with a saved desired state, along with the desired
+state, as a table or, with --json, a JSON array.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		volumes []string
+		asJSON  bool
+	)
+	cmd.Flags().StringSliceVar(&volumes, "volume", []string{"/"}, "mount point of the volume (repeatable)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the status as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		state, err := spotlight.LoadState(*statePath)
+		if err != nil {
+			return err
+		}
+		for _, volume := range state.Volumes() {
+			if !containsString(volumes, volume) {
+				volumes = append(volumes, volume)
+			}
+		}
+
+		statuses := make([]spotlightVolumeStatus, 0, len(volumes))
+		for _, volume := range volumes {
+			enabled, err := spotlight.Indexing(cmd.Context(), volume)
+			if err != nil {
+				return err
+			}
+			status := spotlightVolumeStatus{Volume: volume, Enabled: enabled}
+			if desired, ok := state[volume]; ok {
+				status.Desired = &desired
+			}
+			statuses = append(statuses, status)
+		}
+
+		if asJSON {
+			return printJSON(cmd, statuses)
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		onOff := func(on bool) string {
+			if on {
+				return "on"
+			}
+			return "off"
+		}
+		table := output.NewTable(styler, "volume", "indexing", "desired")
+		for _, s := range statuses {
+			indexing, desired := onOff(s.Enabled), "-"
+			if s.Desired != nil {
+				desired = onOff(*s.Desired)
+				if *s.Desired != s.Enabled {
+					indexing = styler.Bad(indexing)
+				}
+			}
+			table.AddRow(s.Volume, indexing, desired)
+		}
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
+
+// containsString returns whether the slice contains s.
+func containsString(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
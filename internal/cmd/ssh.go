@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/sshd"
+	"github.com/aws/ec2-macos-utils/internal/sshkeys"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+// sshSyncKeysArgs is a struct for holding the arguments for the ssh sync-keys command.
+type sshSyncKeysArgs struct {
+	user     string
+	interval time.Duration
+}
+
+// sshCommand creates a new command which groups SSH utilities.
+func sshCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ssh",
+		Short: "SSH utilities",
+		Long:  "utilities for managing SSH access to EC2 macOS instances",
+	}
+
+	cmd.AddCommand(
+		sshSyncKeysCommand(),
+		sshConfigureCommand(),
+	)
+
+	return cmd
+}
+
+// sshSyncKeysCommand creates a new command which syncs the user's authorized_keys with the instance's public keys.
+func sshSyncKeysCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync-keys",
+		Short: "sync authorized_keys with the instance's public keys",
+		Long: strings.TrimSpace(`
+sync-keys fetches the instance's public keys from IMDS and writes them
+to the user's ~/.ssh/authorized_keys, which is created if needed and
+owned by the user with the permissions sshd requires.
+
+The keys are kept in a section of the file delimited by
+"# BEGIN ec2-macos-utils managed keys" and
+"# END ec2-macos-utils managed keys" lines. Keys in the section that the
+instance no longer provides are removed; keys outside of it are left
+alone.
+
+The keys are synced once unless --interval is set, in which case
+they're synced at that interval until interrupted.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+	}
+
+	var args sshSyncKeysArgs
+	cmd.Flags().StringVar(&args.user, "user", "ec2-user", "local user whose authorized_keys is maintained")
+	cmd.Flags().DurationVar(&args.interval, "interval", 0, "sync repeatedly at this interval instead of once")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if args.interval < 0 {
+			return errors.New("interval cannot be negative")
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return runSSHSyncKeys(ctx, args)
+	}
+
+	return cmd
+}
+
+func runSSHSyncKeys(ctx context.Context, args sshSyncKeysArgs) error {
+	u, err := user.Lookup(args.user)
+	if err != nil {
+		return fmt.Errorf("cannot find user %q: %w", args.user, err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid %q for user %q: %w", u.Uid, args.user, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid %q for user %q: %w", u.Gid, args.user, err)
+	}
+	path := filepath.Join(u.HomeDir, ".ssh", "authorized_keys")
+	owner := sshkeys.Owner{UID: uid, GID: gid}
+
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return err
+	}
+	client := imds.NewFromConfig(cfg)
+
+	sync := func() error {
+		keys, err := instance.PublicKeys(ctx, client)
+		if err != nil {
+			return fmt.Errorf("cannot read the instance's public keys: %w", err)
+		}
+		changed, err := sshkeys.Sync(path, keys, owner)
+		if err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"path":    path,
+			"keys":    len(keys),
+			"changed": changed,
+		}).Info("Synced authorized keys")
+
+		return nil
+	}
+
+	if args.interval == 0 {
+		return sync()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user":     args.user,
+		"interval": args.interval,
+	}).Info("Syncing authorized keys")
+
+	ticker := time.NewTicker(args.interval)
+	defer ticker.Stop()
+	for {
+		if err := sync(); err != nil && ctx.Err() == nil {
+			logrus.WithError(err).Warn("Failed to sync authorized keys, will retry")
+		}
+
+		select {
+		case <-ctx.Done():
+			logrus.Info("Stopped syncing authorized keys")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sshKeepAliveCountMax is the default number of unanswered keep-alive probes before a client is disconnected.
+const sshKeepAliveCountMax = 3
+
+// sshConfigureCommand creates a new command which configures the SSH server with a drop-in file.
+func sshConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "harden and tune the SSH server",
+		Long: strings.TrimSpace(`
+configure writes the SSH server settings given with flags to the drop-in
+file ` + sshd.DropInPath + `, replacing the settings written
+before, so that the file always matches the latest invocation:
+
+  --disable-password-auth  only allow public key authentication
+  --allow-users            only allow logins of these users, which can
+                           be patterns such as ci-* or ec2-user@10.0.0.0/8
+  --ciphers                only allow these ciphers
+  --keep-alive             probe idle clients at this interval, e.g. 60s,
+                           so that NAT gateways and load balancers, which
+                           drop idle connections after 350s, keep them
+
+The complete server configuration is verified with 'sshd -t' after the
+drop-in is installed, and the previous drop-in is restored if it fails,
+so that the server keeps accepting connections. sshd is started for each
+connection, so new connections use the settings right away and
+established sessions are left alone. Keep a session open until a new
+login succeeds. With --reset, the drop-in is removed; with --dry-run,
+it's printed instead of installed. A file of the same name that wasn't
+written by ec2-macos-utils is never replaced or removed.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		config sshd.Config
+		reset  bool
+		dryRun bool
+	)
+	cmd.Flags().BoolVar(&config.DisablePasswordAuth, "disable-password-auth", false, "only allow public key authentication")
+	cmd.Flags().StringSliceVar(&config.AllowUsers, "allow-users", nil, "only allow logins of users matching these patterns")
+	cmd.Flags().StringSliceVar(&config.Ciphers, "ciphers", nil, "only allow these ciphers: "+strings.Join(sshd.Ciphers, ", "))
+	cmd.Flags().DurationVar(&config.KeepAliveInterval, "keep-alive", 0, "interval idle clients are probed at (e.g. 60s)")
+	cmd.Flags().IntVar(&config.KeepAliveCountMax, "keep-alive-count", sshKeepAliveCountMax, "unanswered probes after which a client is disconnected")
+	cmd.Flags().BoolVar(&reset, "reset", false, "remove the drop-in file")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the drop-in file instead of installing it")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if reset && dryRun {
+			return errors.New("--reset and --dry-run cannot be used together")
+		}
+		if err := config.Validate(); err != nil {
+			return err
+		}
+		if dryRun {
+			fmt.Fprint(cmd.OutOrStdout(), config.Render())
+			return nil
+		}
+
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+		if reset {
+			if err := sshd.Reset(); err != nil {
+				return err
+			}
+			logrus.WithField("path", sshd.DropInPath).Info("Removed sshd drop-in")
+			return nil
+		}
+
+		if sudoUser := os.Getenv("SUDO_USER"); len(config.AllowUsers) > 0 && sudoUser != "" && !allowsSSHUser(config.AllowUsers, sudoUser) {
+			logrus.WithField("user", sudoUser).Warn("Allowed users don't include the user running this command, who may be locked out")
+		}
+		if err := sshd.Apply(cmd.Context(), config); err != nil {
+			return err
+		}
+		logrus.WithField("path", sshd.DropInPath).Info("Configured sshd")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// allowsSSHUser reports whether any of the AllowUsers patterns matches the user, from any host.
+func allowsSSHUser(patterns []string, user string) bool {
+	for _, pattern := range patterns {
+		userPattern, _, _ := strings.Cut(pattern, "@")
+		if ok, _ := path.Match(userPattern, user); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,126 @@
+//go:build !minimal
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+)
+
+// configLoader returns the loader of configuration sources, which reads ssm:// sources from Parameter Store and
+// decrypts encrypted values with KMS using the AWS configuration of awsOpts.
+func configLoader(awsOpts aws.Options) *config.Loader {
+	return &config.Loader{
+		SSM: func(ctx context.Context) (config.ParameterStore, error) {
+			cfg, err := aws.LoadConfig(ctx, awsOpts)
+			if err != nil {
+				return nil, err
+			}
+			return configParameterStore{client: ssm.NewFromConfig(cfg)}, nil
+		},
+		KMS: func(ctx context.Context) (config.Decrypter, error) {
+			cfg, err := aws.LoadConfig(ctx, awsOpts)
+			if err != nil {
+				return nil, err
+			}
+			return configDecrypter{client: kms.NewFromConfig(cfg)}, nil
+		},
+	}
+}
+
+// configParameterStore reads configuration sources from SSM Parameter Store.
+type configParameterStore struct {
+	client *ssm.Client
+}
+
+// Parameter returns the decrypted value of the named parameter.
+func (s configParameterStore) Parameter(ctx context.Context, name string) (string, error) {
+	return getSecureParameter(ctx, s.client, name)
+}
+
+// configDecrypter decrypts encrypted configuration values with KMS.
+type configDecrypter struct {
+	client *kms.Client
+}
+
+// Decrypt returns the plaintext of the KMS ciphertext.
+func (d configDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	out, err := d.client.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: ciphertext})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Plaintext, nil
+}
+
+// readSecureParameter returns the decrypted value of the named SSM Parameter Store parameter.
+func readSecureParameter(ctx context.Context, name string) (string, error) {
+	client, err := ssmClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	value, err := getSecureParameter(ctx, client, name)
+	if err != nil {
+		return "", fmt.Errorf("cannot read parameter %s: %w", name, err)
+	}
+
+	return value, nil
+}
+
+// getSecureParameter returns the value of the named parameter, decrypting SecureString parameters.
+func getSecureParameter(ctx context.Context, client *ssm.Client, name string) (string, error) {
+	out, err := client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           awssdk.String(name),
+		WithDecryption: awssdk.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if out.Parameter == nil {
+		return "", errors.New("parameter has no value")
+	}
+
+	return awssdk.ToString(out.Parameter.Value), nil
+}
+
+// storeSecureParameter stores the value in the named SecureString parameter, overwriting it, encrypted with the KMS
+// key or the AWS managed key when kmsKeyID is empty.
+func storeSecureParameter(ctx context.Context, name, value, description, kmsKeyID string) error {
+	client, err := ssmClient(ctx)
+	if err != nil {
+		return err
+	}
+	in := &ssm.PutParameterInput{
+		Name:        awssdk.String(name),
+		Value:       awssdk.String(value),
+		Type:        ssmtypes.ParameterTypeSecureString,
+		Overwrite:   awssdk.Bool(true),
+		Description: awssdk.String(description),
+	}
+	if kmsKeyID != "" {
+		in.KeyId = awssdk.String(kmsKeyID)
+	}
+	_, err = client.PutParameter(ctx, in)
+
+	return err
+}
+
+// ssmClient creates an SSM client with the command's AWS configuration.
+func ssmClient(ctx context.Context) (*ssm.Client, error) {
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return ssm.NewFromConfig(cfg), nil
+}
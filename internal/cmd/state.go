@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/privsep"
+	"github.com/aws/ec2-macos-utils/internal/redact"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+// statePath is where the utility's state is stored.
+var statePath = state.DefaultPath
+
+// withState opens the state, calls fn with it, and closes it. The state is only kept open while it's used since other
+// invocations, such as watchdogs, wait for it while it's open.
+func withState(fn func(s *state.Store) error) error {
+	// Only root can open the state, so the privileges dropped by a watchdog are regained while it is.
+	return privsep.Privileged(func() error {
+		s, err := state.Open(statePath)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		return fn(s)
+	})
+}
+
+// withStateReadOnly is like withState, but opens the state for reading, which doesn't wait for other readers.
+func withStateReadOnly(fn func(s *state.Store) error) error {
+	return privsep.Privileged(func() error {
+		s, err := state.OpenReadOnly(statePath)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		return fn(s)
+	})
+}
+
+// recordCheck records the result of a run of the named check in the state. Only root can write the state, so results
+// of checks run by other users aren't recorded, and failing to record a result doesn't fail the check.
+func recordCheck(name string, err error) {
+	if !hasRootPrivileges() {
+		return
+	}
+	result := state.CheckResult{Check: name, Time: time.Now(), OK: err == nil}
+	if err != nil {
+		result.Error = redact.String(err.Error())
+	}
+	if err := withState(func(s *state.Store) error { return s.RecordCheck(result) }); err != nil {
+		logrus.WithError(err).WithField("check", name).Warn("Failed to record check result")
+	}
+}
+
+// recordCapture records the diagnostic data the watchdog captured at path for the ID in the state.
+func recordCapture(watchdog, id, path string) {
+	capture := state.Capture{Watchdog: watchdog, ID: id, Path: path, Time: time.Now()}
+	if err := withState(func(s *state.Store) error { return s.RecordCapture(capture) }); err != nil {
+		logrus.WithError(err).WithField("watchdog", watchdog).Warn("Failed to record capture")
+	}
+}
+
+// captured reports whether the watchdog captured diagnostic data for the ID. Data captured before captures were
+// recorded in the state is found in legacyDir instead, and recorded when it's found.
+func captured(watchdog, id, legacyDir string) (bool, error) {
+	var found bool
+	err := withState(func(s *state.Store) error {
+		_, ok, err := s.Captured(watchdog, id)
+		if err != nil || ok {
+			found = ok
+			return err
+		}
+
+		legacy, err := sysdiagnoseCaptures(legacyDir)
+		if err != nil || len(legacy) == 0 {
+			return err
+		}
+		sort.Strings(legacy)
+		capture := state.Capture{Watchdog: watchdog, ID: id, Path: legacy[len(legacy)-1], Time: time.Now()}
+		if fi, err := os.Stat(capture.Path); err == nil {
+			capture.Time = fi.ModTime()
+		}
+		found = true
+		return s.RecordCapture(capture)
+	})
+
+	return found, err
+}
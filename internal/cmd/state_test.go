@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+func withTestState(t *testing.T) {
+	previous := statePath
+	statePath = filepath.Join(t.TempDir(), "state.db")
+	t.Cleanup(func() { statePath = previous })
+}
+
+func TestCaptured(t *testing.T) {
+	withTestState(t)
+	dir := t.TempDir()
+
+	done, err := captured(scheduledEventsWatchdog, "instance-event-1", dir)
+	assert.NoError(t, err)
+	assert.False(t, done)
+
+	recordCapture(scheduledEventsWatchdog, "instance-event-1", filepath.Join(dir, "sysdiagnose_1.tar.gz"))
+	done, err = captured(scheduledEventsWatchdog, "instance-event-1", dir)
+	assert.NoError(t, err)
+	assert.True(t, done)
+}
+
+func TestCaptured_Legacy(t *testing.T) {
+	withTestState(t)
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "sysdiagnose_20240101_000000.tar.gz")
+	assert.NoError(t, os.WriteFile(legacy, nil, 0400))
+
+	done, err := captured(networkMonitorWatchdog, "uuid", dir)
+	assert.NoError(t, err)
+	assert.True(t, done, "captures saved before they were recorded should be found")
+
+	var captures []state.Capture
+	assert.NoError(t, withState(func(s *state.Store) error {
+		captures, err = s.Captures(networkMonitorWatchdog)
+		return err
+	}))
+	if assert.Len(t, captures, 1, "legacy capture should be recorded") {
+		assert.Equal(t, legacy, captures[0].Path)
+		assert.Equal(t, "uuid", captures[0].ID)
+	}
+}
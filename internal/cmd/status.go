@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+// statusRefreshInterval is how often the status endpoint reads the state database at most. Requests in between are
+// served the last status read, so that requests can't keep the database locked from the commands recording to it.
+const statusRefreshInterval = 10 * time.Second
+
+// statusReport is what the status endpoint serves at /status.
+type statusReport struct {
+	Version string `json:"version"`
+	// Command is the path of the command serving the status, e.g. "watchdog network-health-monitor".
+	Command string    `json:"command"`
+	RunID   string    `json:"run_id"`
+	Started time.Time `json:"started"`
+	// Watchdogs are the states of the watchdogs recorded in the state database.
+	Watchdogs []watchdogStatus `json:"watchdogs"`
+	// Checks are the most recent result of each check.
+	Checks []state.CheckResult `json:"checks"`
+	// Error is why the state database couldn't be read, e.g. when not running as root.
+	Error string `json:"error,omitempty"`
+}
+
+// statusServer serves the status of the running command.
+type statusServer struct {
+	command string
+	runID   string
+	started time.Time
+
+	// mu guards the state last read from the database, and when it was read.
+	mu        sync.Mutex
+	watchdogs []watchdogStatus
+	checks    []state.CheckResult
+	stateErr  string
+	read      time.Time
+}
+
+// serveStatus serves the status of the running command at /status and its liveness at /healthz on addr until the
+// program exits, so that health probes and orchestrators on the host can query it without running the utility. It
+// only listens on loopback addresses since the status describes the instance's problems.
+func serveStatus(addr, command, runID string) error {
+	l, err := listenLoopback(addr)
+	if err != nil {
+		return fmt.Errorf("cannot serve status: %w", err)
+	}
+
+	s := &statusServer{command: command, runID: runID, started: time.Now()}
+	server := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(l); err != nil {
+			logrus.WithError(err).Error("Stopped serving status")
+		}
+	}()
+	logrus.WithField("address", l.Addr().String()).Debug("Serving status")
+
+	return nil
+}
+
+// listenLoopback listens on addr, which must be a loopback address since the endpoints the utility serves aren't
+// authenticated.
+func listenLoopback(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("%s isn't a loopback address, only loopback addresses are allowed", addr)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+// handler returns the read-only handler of the status endpoint.
+func (s *statusServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(s.status()); err != nil {
+			logrus.WithError(err).Debug("Failed to write status")
+		}
+	})
+
+	return readOnly(mux)
+}
+
+// status returns the status of the running command, reading the state database if it wasn't read in the last
+// statusRefreshInterval. A state database that can't be read is reported in the status rather than failing the
+// request, since the command is still running.
+func (s *statusServer) status() statusReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.read.IsZero() || time.Since(s.read) >= statusRefreshInterval {
+		s.readState()
+	}
+
+	return statusReport{
+		Version:   build.Version,
+		Command:   s.command,
+		RunID:     s.runID,
+		Started:   s.started,
+		Watchdogs: s.watchdogs,
+		Checks:    s.checks,
+		Error:     s.stateErr,
+	}
+}
+
+// readState reads the watchdogs and the latest check results from the state database.
+func (s *statusServer) readState() {
+	s.watchdogs, s.checks, s.stateErr, s.read = []watchdogStatus{}, []state.CheckResult{}, "", time.Now()
+	err := withStateReadOnly(func(st *state.Store) error {
+		watchdogs, err := watchdogStatuses(st)
+		if err != nil {
+			return err
+		}
+		history, err := st.CheckHistory("", 0)
+		if err != nil {
+			return err
+		}
+		s.watchdogs, s.checks = watchdogs, latestCheckResults(history)
+		return nil
+	})
+	if err != nil {
+		s.stateErr = err.Error()
+	}
+}
+
+// readOnly rejects requests to h other than GET and HEAD.
+func readOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusServer(t *testing.T) {
+	withTestState(t)
+	recordCheck("imds", errors.New("timeout"))
+	recordCheck("imds", nil)
+	recordCapture(scheduledEventsWatchdog, "instance-event-1", "/tmp/sysdiagnose_1.tar.gz")
+
+	s := &statusServer{command: "watchdog network-health-monitor", runID: "run-1"}
+	handler := s.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status statusReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, "watchdog network-health-monitor", status.Command)
+	assert.Empty(t, status.Error)
+	if assert.Len(t, status.Checks, 1) {
+		assert.True(t, status.Checks[0].OK, "the latest result of the check should be served")
+	}
+	if assert.Len(t, status.Watchdogs, 2) {
+		assert.Equal(t, watchdogArmed, status.Watchdogs[1].State)
+		assert.Equal(t, 1, status.Watchdogs[1].Captures)
+	}
+
+	recordCheck("imds", errors.New("timeout"))
+	assert.True(t, s.status().Checks[0].OK, "the state shouldn't be read again until the status is refreshed")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestServeStatus_Loopback(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:0", ":0", "10.0.0.1:0", "example.com:80", "127.0.0.1"} {
+		assert.Error(t, serveStatus(addr, "", ""), addr)
+	}
+	assert.NoError(t, serveStatus("127.0.0.1:0", "", ""))
+}
+
+func TestServeSelfMetrics_Loopback(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:9464", ":0", "10.0.0.1:0"} {
+		assert.Error(t, serveSelfMetrics(addr), addr)
+	}
+	assert.NoError(t, serveSelfMetrics("localhost:0"))
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/locale"
+	"github.com/aws/ec2-macos-utils/pkg/instance"
+)
+
+// systemCommand creates a new command which groups instance and system information utilities.
+func systemCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "system",
+		Short: "instance and system information and settings",
+		Long:  "utilities for reading information about the EC2 instance and its system, and for system settings",
+	}
+
+	cmd.AddCommand(
+		systemTagsCommand(),
+		systemIdentityCommand(),
+		systemSetTimezoneCommand(),
+		systemSetLocaleCommand(),
+	)
+
+	return cmd
+}
+
+// systemTagsCommand creates a new command which prints the instance's tags.
+func systemTagsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tags [key]",
+		Short: "print the instance's tags",
+		Long: strings.TrimSpace(`
+tags prints the instance's tags as a JSON object. When a key is
+given, only that tag's value is printed and the command fails if the
+tag doesn't exist.
+
+Tags are read from instance metadata when tags in instance metadata
+are enabled for the instance. Otherwise they're read from the EC2 API,
+which requires the instance role to allow ec2:DescribeTags.
+        `),
+		Args: cobra.MaximumNArgs(1),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := aws.LoadConfig(cmd.Context(), contextual.AWSOptions(cmd.Context()))
+		if err != nil {
+			return err
+		}
+		reader := instance.NewTagReader(cfg)
+
+		if len(args) == 1 {
+			value, ok, err := reader.Tag(cmd.Context(), args[0])
+			if err != nil {
+				return fmt.Errorf("cannot read instance tags: %w", err)
+			}
+			if !ok {
+				return fmt.Errorf("instance has no tag %q", args[0])
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		}
+
+		tags, err := reader.Tags(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("cannot read instance tags: %w", err)
+		}
+		return printJSON(cmd, tags)
+	}
+
+	return cmd
+}
+
+// systemIdentityCommand creates a new command which prints the instance identity document.
+func systemIdentityCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "identity",
+		Short: "print the instance identity document",
+		Long: strings.TrimSpace(`
+identity prints the instance identity document read from IMDS as a
+JSON object, with "verified" reporting whether its signature was
+verified with the AWS public certificate for the instance's region.
+
+Certificates are read from --certificate-dir as <region>.pem, or
+default.pem for every region. They aren't installed with the utility;
+they're listed in the EC2 documentation on instance identity documents.
+Without a certificate, the command fails unless --verify=false is set,
+which prints the document unverified. A document that doesn't match its
+signature is always an error.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		verify  bool
+		certDir string
+	)
+	cmd.Flags().BoolVar(&verify, "verify", true, "fail when the signature can't be verified")
+	cmd.Flags().StringVar(&certDir, "certificate-dir", instance.DefaultCertificateDir, "directory of the AWS public certificates used to verify the document")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		cfg, err := aws.LoadConfig(cmd.Context(), contextual.AWSOptions(cmd.Context()))
+		if err != nil {
+			return err
+		}
+		reader := &instance.IdentityReader{IMDS: imds.NewFromConfig(cfg), CertificateDir: certDir, Require: verify}
+
+		identity, err := reader.Identity(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("cannot read instance identity document: %w", err)
+		}
+		return printJSON(cmd, identity)
+	}
+
+	return cmd
+}
+
+// systemSetTimezoneCommand creates a new command which sets the system time zone.
+func systemSetTimezoneCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-timezone [zone]",
+		Short: "set the system time zone",
+		Long: strings.TrimSpace(`
+set-timezone sets the system time zone to the zone of the tz database
+given as argument, e.g. Europe/Paris or UTC, or with --from-region, to
+the zone of the location of the instance's region, e.g. Europe/Paris in
+eu-west-3. The time zone is read back to verify it's in effect.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: assertRootPrivileges,
+	}
+
+	var fromRegion bool
+	cmd.Flags().BoolVar(&fromRegion, "from-region", false, "use the time zone of the instance's region")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if fromRegion == (len(args) == 1) {
+			return errors.New("either a time zone or --from-region is required")
+		}
+
+		ctx := cmd.Context()
+		var tz string
+		if fromRegion {
+			var err error
+			if tz, err = regionTimeZone(ctx); err != nil {
+				return err
+			}
+		} else {
+			tz = args[0]
+		}
+
+		if err := locale.SetTimeZone(ctx, tz); err != nil {
+			return err
+		}
+		logrus.WithField("time_zone", tz).Info("Set time zone")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// regionTimeZone returns the time zone of the instance's region.
+func regionTimeZone(ctx context.Context) (string, error) {
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return "", err
+	}
+	identity, err := instance.CachedIdentity(ctx, imds.NewFromConfig(cfg))
+	if err != nil {
+		return "", fmt.Errorf("cannot read instance region: %w", err)
+	}
+
+	return locale.RegionTimeZone(identity.Region)
+}
+
+// systemSetLocaleCommand creates a new command which sets the system or a user's locale.
+func systemSetLocaleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-locale <locale>",
+		Short: "set the system locale",
+		Long: strings.TrimSpace(`
+set-locale sets the locale, e.g. en_US or fr_CA, and the matching
+preferred language, which decide how dates, numbers, and currencies are
+formatted and the language of apps. The system locale applies to users
+that haven't chosen their own; with --user, the user's locale is set
+instead. The locale is read back to verify it. Users get the new locale
+at their next login.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.ExactArgs(1),
+		PreRunE: assertRootPrivileges,
+	}
+
+	var username string
+	cmd.Flags().StringVar(&username, "user", "", "set the locale of this user instead of the system's")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := locale.SetLocale(cmd.Context(), username, args[0]); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"locale": args[0],
+			"user":   username,
+		}).Info("Set locale")
+
+		return nil
+	}
+
+	return cmd
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/timesync"
+)
+
+// timeCommand creates a new command which groups time synchronization utilities.
+func timeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "time",
+		Short: "time synchronization utilities",
+		Long:  "utilities for configuring network time with the Amazon Time Sync Service",
+	}
+
+	cmd.AddCommand(
+		timeConfigureCommand(),
+	)
+
+	return cmd
+}
+
+// timeConfigureCommand creates a new command which configures network time.
+func timeConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "set the system time from the Amazon Time Sync Service",
+		Long: strings.TrimSpace(`
+configure points the system time service at the Amazon Time Sync
+Service (` + timesync.AmazonTimeSyncServer + `), with any --fallback servers
+after it, and enables network time. The configuration is then verified
+by querying the server and checking that the clock's offset from it is
+within --max-offset, which 'check time' also checks.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var (
+		server    string
+		fallbacks []string
+		maxOffset time.Duration
+		noVerify  bool
+	)
+	cmd.Flags().StringVar(&server, "server", timesync.AmazonTimeSyncServer, "preferred time server")
+	cmd.Flags().StringSliceVar(&fallbacks, "fallback", nil, "time servers to use when the preferred server is unavailable")
+	cmd.Flags().DurationVar(&maxOffset, "max-offset", checkTimeDefaultMaxOffset, "largest clock offset from the server that verifies the configuration")
+	cmd.Flags().BoolVar(&noVerify, "no-verify", false, "don't verify the clock's offset from the server")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if maxOffset <= 0 {
+			return errors.New("max offset must be positive")
+		}
+
+		servers := append([]string{server}, fallbacks...)
+		if err := timesync.Configure(cmd.Context(), servers); err != nil {
+			return err
+		}
+		logrus.WithField("servers", servers).Info("Configured network time")
+
+		if noVerify {
+			return nil
+		}
+		offset, err := checkTimeOffset(cmd.Context(), server, maxOffset)
+		if err != nil {
+			return fmt.Errorf("cannot verify time synchronization: %w", err)
+		}
+		logrus.WithField("offset", offset).Info("Verified time synchronization")
+
+		return nil
+	}
+
+	return cmd
+}
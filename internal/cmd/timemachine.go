@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/timemachine"
+)
+
+// timeMachineCommand creates a new command which groups Time Machine utilities.
+func timeMachineCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "timemachine",
+		Short: "Time Machine utilities",
+		Long:  "utilities for configuring Time Machine backups and local snapshots",
+	}
+
+	cmd.AddCommand(
+		timeMachineConfigureCommand(),
+		timeMachineStatusCommand(),
+	)
+
+	return cmd
+}
+
+// timeMachineConfigureCommand creates a new command which configures automatic backups and local snapshots.
+func timeMachineConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "configure automatic backups and local snapshots",
+		Long: strings.TrimSpace(`
+configure turns Time Machine's automatic backups and local snapshots on
+or off. On build hosts, local snapshots hold on to the space of deleted
+files, wasting disk, and keep APFS containers from being resized with
+'grow', so they're usually disabled:
+
+  ec2-macos-utils timemachine configure --disable-auto --disable-local-snapshots
+
+Disabling local snapshots also deletes the existing snapshots of
+--volume. Current macOS releases take local snapshots for automatic
+backups, so disable both to stop them entirely.
+
+Without any flag, the status is printed.
+
+Changing settings requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		volume                                      string
+		disableAuto, enableAuto                     bool
+		disableLocalSnapshots, enableLocalSnapshots bool
+	)
+	cmd.Flags().StringVar(&volume, "volume", "/", "mount point of the volume whose local snapshots are deleted")
+	cmd.Flags().BoolVar(&disableAuto, "disable-auto", false, "turn automatic backups off")
+	cmd.Flags().BoolVar(&enableAuto, "enable-auto", false, "turn automatic backups on")
+	cmd.Flags().BoolVar(&disableLocalSnapshots, "disable-local-snapshots", false, "stop keeping local snapshots and delete the existing ones")
+	cmd.Flags().BoolVar(&enableLocalSnapshots, "enable-local-snapshots", false, "keep local snapshots")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if disableAuto && enableAuto {
+			return errors.New("only one of --disable-auto and --enable-auto can be set")
+		}
+		if disableLocalSnapshots && enableLocalSnapshots {
+			return errors.New("only one of --disable-local-snapshots and --enable-local-snapshots can be set")
+		}
+		if !disableAuto && !enableAuto && !disableLocalSnapshots && !enableLocalSnapshots {
+			return printTimeMachineStatus(cmd, volume, false)
+		}
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		if disableAuto || enableAuto {
+			if err := timemachine.SetAutoBackup(ctx, enableAuto); err != nil {
+				return err
+			}
+			logrus.WithField("enabled", enableAuto).Info("Configured automatic backups")
+		}
+		switch {
+		case disableLocalSnapshots:
+			if err := timemachine.DisableLocalSnapshots(ctx, volume); err != nil {
+				return err
+			}
+			logrus.WithField("volume", volume).Info("Disabled local snapshots")
+		case enableLocalSnapshots:
+			if err := timemachine.EnableLocalSnapshots(ctx); err != nil {
+				return err
+			}
+			logrus.Info("Enabled local snapshots")
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// timeMachineStatusCommand creates a new command which prints the state of Time Machine.
+func timeMachineStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "print the state of Time Machine",
+		Long: strings.TrimSpace(`
+status prints whether automatic backups and local snapshots are enabled
+and the local snapshots of --volume, as a table or, with --json, a JSON
+object.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var (
+		volume string
+		asJSON bool
+	)
+	cmd.Flags().StringVar(&volume, "volume", "/", "mount point of the volume whose local snapshots are listed")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the status as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return printTimeMachineStatus(cmd, volume, asJSON)
+	}
+
+	return cmd
+}
+
+// printTimeMachineStatus writes the state of Time Machine as a table or JSON.
+func printTimeMachineStatus(cmd *cobra.Command, volume string, asJSON bool) error {
+	status, err := timemachine.GetStatus(cmd.Context(), volume)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(cmd, status)
+	}
+
+	onOff := func(on bool) string {
+		if on {
+			return "on"
+		}
+		return "off"
+	}
+	table := output.NewTable(contextual.Styler(cmd.Context()), "setting", "state")
+	table.AddRow("automatic backups", onOff(status.AutoBackup))
+	table.AddRow("local snapshots", onOff(status.LocalSnapshots))
+	table.AddRow("snapshots of "+volume, fmt.Sprint(len(status.Snapshots)))
+
+	return table.Render(cmd.OutOrStdout())
+}
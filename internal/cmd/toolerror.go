@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// logToolErrors makes every command under root log the details of an external tool it failed on, such as the tool's
+// arguments and the end of its stderr, which its error message may not have room for.
+func logToolErrors(root *cobra.Command) {
+	for _, cmd := range root.Commands() {
+		logToolErrors(cmd)
+	}
+	if root.RunE == nil {
+		return
+	}
+
+	runE := root.RunE
+	root.RunE = func(cmd *cobra.Command, args []string) error {
+		err := runE(cmd, args)
+		logToolError(err)
+
+		return err
+	}
+}
+
+// logToolError logs the details of the external tool err failed on, if any.
+func logToolError(err error) {
+	toolErr := util.AsExternalToolError(err)
+	if toolErr == nil {
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"tool":      toolErr.Tool,
+		"args":      toolErr.Args,
+		"exit_code": toolErr.ExitCode,
+		"stderr":    toolErr.Stderr,
+	}).Error("External tool failed")
+}
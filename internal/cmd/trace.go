@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
+)
+
+// withTracer extends the context with a tracer exporting to the OTLP traces endpoint, or returns it unchanged without
+// an endpoint.
+func withTracer(ctx context.Context, endpoint string) context.Context {
+	if endpoint == "" {
+		return ctx
+	}
+
+	return tracing.WithTracer(ctx, tracing.NewTracer(&tracing.OTLPExporter{
+		Endpoint: endpoint,
+		Resource: map[string]string{
+			"service.name":    "ec2-macos-utils",
+			"service.version": build.Version,
+		},
+	}))
+}
+
+// traceCommands makes every command under root run in a root span named after its path, which is exported when the
+// command returns.
+func traceCommands(root *cobra.Command) {
+	for _, cmd := range root.Commands() {
+		traceCommands(cmd)
+	}
+	if root.RunE == nil {
+		return
+	}
+
+	runE := root.RunE
+	root.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx, span := tracing.Start(cmd.Context(), commandPath(cmd), map[string]string{
+			"ec2_macos_utils.run_id": contextual.RunID(cmd.Context()),
+		})
+		cmd.SetContext(ctx)
+		err := runE(cmd, args)
+		span.Finish(err)
+		flushSpans(ctx)
+
+		return err
+	}
+}
+
+// flushSpans exports the spans that ended. Export failures are only logged so that tracing never fails a command.
+func flushSpans(ctx context.Context) {
+	if err := tracing.Flush(ctx); err != nil {
+		logrus.WithError(err).Warn("Failed to export trace spans")
+	}
+}
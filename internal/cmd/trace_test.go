@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/tracing"
+)
+
+func TestTraceCommands(t *testing.T) {
+	var names []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						Name string `json:"name"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		for _, span := range req.ResourceSpans[0].ScopeSpans[0].Spans {
+			names = append(names, span.Name)
+		}
+	}))
+	defer server.Close()
+
+	child := &cobra.Command{Use: "child", RunE: func(cmd *cobra.Command, _ []string) error {
+		_, span := tracing.Start(cmd.Context(), "step", nil)
+		span.Finish(nil)
+		return nil
+	}}
+	parent := &cobra.Command{Use: "parent"}
+	parent.AddCommand(child)
+	root := testTree(parent)
+	traceCommands(root)
+
+	root.SetArgs([]string{"parent", "child"})
+	assert.NoError(t, root.ExecuteContext(withTracer(context.Background(), server.URL)))
+	assert.Equal(t, []string{"step", "parent child"}, names)
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/localuser"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/uiprefs"
+)
+
+// uiCommand creates a new command which groups user interface utilities.
+func uiCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Short: "user interface utilities",
+		Long:  "utilities for configuring the user interface for unattended sessions",
+	}
+
+	cmd.AddCommand(
+		uiConfigureCommand(),
+	)
+
+	return cmd
+}
+
+// uiConfigureCommand creates a new command which configures dialog suppression.
+func uiConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "suppress dialogs that block unattended UI sessions",
+		Long: strings.TrimSpace(`
+configure sets the --user user's preferences that keep modal dialogs
+from blocking unattended sessions, e.g. UI tests:
+
+  --suppress-crash-dialogs  report crashes with a notification instead
+                            of the modal crash reporter dialog
+  --suppress-setup-assistant
+                            skip the Setup Assistant panes (Siri,
+                            analytics, Screen Time, appearance, ...)
+                            shown at first login
+  --skip-icloud             skip the iCloud and Apple Account sign-in
+                            prompts shown at first login and after
+                            macOS updates
+
+Setup Assistant records the panes it showed for the current macOS
+version, so run configure for new users before their first login, e.g.
+right after 'user create' on automated hosts, and again after updating
+macOS.
+
+Setting a flag to false, e.g. --suppress-crash-dialogs=false, deletes
+the preferences so that macOS uses its defaults again. Settings that
+aren't given are left unchanged, and without any, the current settings
+are printed. Applications that are running may need to be relaunched to
+pick up the changes.
+
+Changing settings requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var username string
+	cmd.Flags().StringVar(&username, "user", "ec2-user", "local user whose preferences are configured")
+	values := map[string]*bool{}
+	for _, s := range uiprefs.Settings {
+		values[s.Name] = cmd.Flags().Bool(s.Name, false, s.Description)
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if err := localuser.ValidateName(username); err != nil {
+			return err
+		}
+
+		var changed bool
+		for _, s := range uiprefs.Settings {
+			changed = changed || cmd.Flags().Changed(s.Name)
+		}
+		if !changed {
+			return printUISettings(cmd, username)
+		}
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+
+		for _, s := range uiprefs.Settings {
+			if !cmd.Flags().Changed(s.Name) {
+				continue
+			}
+			suppress := *values[s.Name]
+			if err := s.Write(cmd.Context(), username, suppress); err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{
+				"user":    username,
+				"setting": s.Name,
+				"enabled": suppress,
+			}).Info("Configured dialog suppression")
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// printUISettings writes the user's dialog suppression settings as a table.
+func printUISettings(cmd *cobra.Command, username string) error {
+	styler := contextual.Styler(cmd.Context())
+	table := output.NewTable(styler, "setting", "state")
+	for _, s := range uiprefs.Settings {
+		enabled, err := s.Read(cmd.Context(), username)
+		if err != nil {
+			return err
+		}
+		state := "off"
+		if enabled {
+			state = "on"
+		}
+		table.AddRow(s.Name, state)
+	}
+
+	return table.Render(cmd.OutOrStdout())
+}
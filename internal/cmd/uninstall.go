@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/actionlog"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+const (
+	// uninstallJobPrefix prefixes the labels of the launchd jobs installed by the utility.
+	uninstallJobPrefix = "com.amazon.ec2.macos-utils."
+	// uninstallRunDir holds the utility's runtime files, such as the locks of the external command limits.
+	uninstallRunDir = "/private/var/run/ec2-macos-utils"
+)
+
+// Kinds of what uninstall removes.
+const (
+	uninstallJobKind         = "launchd job"
+	uninstallStateKind       = "state"
+	uninstallCacheKind       = "cache"
+	uninstallDiagnosticsKind = "diagnostics"
+	uninstallLogKind         = "log"
+)
+
+// uninstallLayout is where the utility installs jobs and keeps its files on the host.
+type uninstallLayout struct {
+	// jobDirs are the directories of the launchd jobs' property lists, by job kind.
+	jobDirs map[string]string
+	// stateDir holds the state, caches, and diagnostics, which are removed with it.
+	stateDir string
+	// cacheDirs and diagnosticDirs are the directories in stateDir with caches and captured diagnostics.
+	cacheDirs      []string
+	diagnosticDirs []string
+	// logPatterns match the utility's logs, including rotated ones.
+	logPatterns []string
+	// runDirs hold runtime files that are recreated as needed.
+	runDirs []string
+}
+
+// defaultUninstallLayout returns where the utility installs jobs and keeps its files by default.
+func defaultUninstallLayout() uninstallLayout {
+	return uninstallLayout{
+		jobDirs: map[string]string{
+			launchd.Daemon: launchd.DaemonDir,
+			launchd.Agent:  launchd.AgentDir,
+		},
+		stateDir:       filepath.Dir(state.DefaultPath),
+		cacheDirs:      []string{uploadDefaultStateDir, logsShipDefaultStateDir},
+		diagnosticDirs: []string{networkMonitorDefaultOutputBaseDir, scheduledEventsDefaultOutputBaseDir},
+		logPatterns: []string{
+			filepath.Dir(actionlog.DefaultPath),
+			"/var/log/ec2-macos-utils*.log*",
+		},
+		runDirs: []string{uninstallRunDir},
+	}
+}
+
+// uninstallItem is something uninstall removes, or keeps.
+type uninstallItem struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	// Label identifies a launchd job, and jobKind is whether it's a daemon or an agent.
+	Label   string `json:"label,omitempty"`
+	jobKind string
+	// emptyDir is set for a directory that's only removed once its contents were.
+	emptyDir bool
+	// Kept reports whether the item is kept, e.g. diagnostics with --keep-diagnostics.
+	Kept bool `json:"kept,omitempty"`
+	// Removed reports whether the item was removed, and is false in a dry run.
+	Removed bool   `json:"removed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// uninstallReport is the outcome of the uninstall command.
+type uninstallReport struct {
+	DryRun bool            `json:"dry_run"`
+	Items  []uninstallItem `json:"items"`
+}
+
+// uninstallCommand creates a new command which removes the utility's jobs and files from the host.
+func uninstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "remove the utility's launchd jobs, state, logs, and caches",
+		Long: strings.TrimSpace(`
+uninstall removes what the utility installed and accumulated on the host,
+so that it can be cleanly reset, e.g. before creating an AMI:
+
+  launchd jobs  the daemons and agents labeled com.amazon.ec2.macos-utils.*,
+                which are unloaded first
+  state         /private/var/db/ec2-macos-utils, including the state
+                database and policies
+  caches        the upload and log shipping state, and runtime files in
+                /private/var/run/ec2-macos-utils
+  diagnostics   the sysdiagnose archives captured by the watchdogs
+  logs          /var/log/ec2-macos-utils and /var/log/ec2-macos-utils*.log,
+                including rotated logs
+
+With --keep-diagnostics, the captured sysdiagnose archives are kept. With
+--dry-run, what would be removed is printed without removing it. The
+configuration in /etc/ec2-macos-utils and the utility itself aren't
+removed. The command prints a JSON object with --json.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+	}
+
+	var (
+		keepDiagnostics bool
+		dryRun          bool
+		asJSON          bool
+	)
+	cmd.Flags().BoolVar(&keepDiagnostics, "keep-diagnostics", false, "keep the sysdiagnose archives captured by the watchdogs")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print what would be removed without removing it")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the results as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if !dryRun {
+			if err := assertRootPrivileges(cmd, nil); err != nil {
+				return err
+			}
+		}
+
+		items, err := planUninstall(defaultUninstallLayout(), keepDiagnostics)
+		if err != nil {
+			return err
+		}
+		report := uninstallReport{DryRun: dryRun, Items: items}
+		if !dryRun {
+			removeJob := func(ctx context.Context, kind, label string) error {
+				return launchd.Remove(ctx, kind, "", label)
+			}
+			runUninstall(cmd.Context(), report.Items, removeJob)
+		}
+
+		if asJSON {
+			if err := printJSON(cmd, report); err != nil {
+				return err
+			}
+		} else {
+			printUninstallReport(cmd, report)
+		}
+
+		var failed int
+		for _, item := range report.Items {
+			if item.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to remove %d of %d items", failed, len(report.Items))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// printUninstallReport prints a line for each item of the report.
+func printUninstallReport(cmd *cobra.Command, report uninstallReport) {
+	if len(report.Items) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "Nothing to remove")
+		return
+	}
+	styler := contextual.Styler(cmd.Context())
+	for _, item := range report.Items {
+		name := item.Path
+		if item.Label != "" {
+			name = item.Label
+		}
+		switch {
+		case item.Error != "":
+			fmt.Fprintf(cmd.OutOrStdout(), "%s  %s %s: %s\n", styler.Status(false), item.Kind, name, item.Error)
+		case item.Kept:
+			fmt.Fprintf(cmd.OutOrStdout(), "kept  %s %s\n", item.Kind, name)
+		case report.DryRun:
+			fmt.Fprintf(cmd.OutOrStdout(), "would remove  %s %s\n", item.Kind, name)
+		default:
+			fmt.Fprintf(cmd.OutOrStdout(), "%s  removed %s %s\n", styler.Status(true), item.Kind, name)
+		}
+	}
+}
+
+// planUninstall lists what's installed in the layout, in the order it's removed: the jobs first, so that they don't
+// recreate the files removed after them. Files that don't exist aren't listed.
+func planUninstall(layout uninstallLayout, keepDiagnostics bool) ([]uninstallItem, error) {
+	var items []uninstallItem
+
+	kinds := make([]string, 0, len(layout.jobDirs))
+	for kind := range layout.jobDirs {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		paths, err := filepath.Glob(filepath.Join(layout.jobDirs[kind], uninstallJobPrefix+"*.plist"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			items = append(items, uninstallItem{
+				Kind:    uninstallJobKind,
+				Path:    path,
+				Label:   strings.TrimSuffix(filepath.Base(path), ".plist"),
+				jobKind: kind,
+			})
+		}
+	}
+
+	entries, err := os.ReadDir(layout.stateDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read %s: %w", layout.stateDir, err)
+	}
+	keptAny := false
+	for _, entry := range entries {
+		item := uninstallItem{Kind: uninstallStateKind, Path: filepath.Join(layout.stateDir, entry.Name())}
+		switch {
+		case containsPath(layout.diagnosticDirs, item.Path):
+			item.Kind = uninstallDiagnosticsKind
+			item.Kept = keepDiagnostics
+			keptAny = keptAny || keepDiagnostics
+		case containsPath(layout.cacheDirs, item.Path):
+			item.Kind = uninstallCacheKind
+		}
+		items = append(items, item)
+	}
+	if len(entries) > 0 && !keptAny {
+		// The emptied directory is removed too.
+		items = append(items, uninstallItem{Kind: uninstallStateKind, Path: layout.stateDir, emptyDir: true})
+	}
+
+	for _, dir := range layout.runDirs {
+		if _, err := os.Lstat(dir); err == nil {
+			items = append(items, uninstallItem{Kind: uninstallCacheKind, Path: dir})
+		}
+	}
+
+	for _, pattern := range layout.logPatterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			items = append(items, uninstallItem{Kind: uninstallLogKind, Path: path})
+		}
+	}
+
+	return items, nil
+}
+
+// runUninstall removes the items that aren't kept, in order, recording whether each was removed. A failure doesn't
+// stop the removal of the items after it.
+func runUninstall(ctx context.Context, items []uninstallItem, removeJob func(ctx context.Context, kind, label string) error) {
+	for i := range items {
+		item := &items[i]
+		if item.Kept {
+			continue
+		}
+
+		var err error
+		switch {
+		case item.Label != "":
+			err = removeJob(ctx, item.jobKind, item.Label)
+		case item.emptyDir:
+			err = os.Remove(item.Path)
+		default:
+			err = os.RemoveAll(item.Path)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			item.Error = err.Error()
+			logrus.WithError(err).WithField("path", item.Path).Warnf("Failed to remove %s", item.Kind)
+			continue
+		}
+		item.Removed = true
+		logrus.WithField("path", item.Path).Debugf("Removed %s", item.Kind)
+	}
+}
+
+// containsPath reports whether paths contains path, once both are cleaned.
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+)
+
+// testUninstallLayout creates a layout in a temporary directory with a job, state, caches, diagnostics, and logs
+// installed.
+func testUninstallLayout(t *testing.T) uninstallLayout {
+	root := t.TempDir()
+	layout := uninstallLayout{
+		jobDirs: map[string]string{
+			launchd.Daemon: filepath.Join(root, "LaunchDaemons"),
+			launchd.Agent:  filepath.Join(root, "LaunchAgents"),
+		},
+		stateDir:       filepath.Join(root, "db"),
+		cacheDirs:      []string{filepath.Join(root, "db", "uploads")},
+		diagnosticDirs: []string{filepath.Join(root, "db", "sysdiagnose")},
+		logPatterns:    []string{filepath.Join(root, "log", "ec2-macos-utils*.log*")},
+		runDirs:        []string{filepath.Join(root, "run")},
+	}
+
+	for _, path := range []string{
+		filepath.Join(layout.jobDirs[launchd.Daemon], uninstallJobPrefix+"hostname.plist"),
+		filepath.Join(layout.jobDirs[launchd.Daemon], "com.example.other.plist"),
+		filepath.Join(layout.stateDir, "state.db"),
+		filepath.Join(layout.stateDir, "uploads", "upload.json"),
+		filepath.Join(layout.stateDir, "sysdiagnose", "sysdiagnose.tar.gz"),
+		filepath.Join(root, "log", "ec2-macos-utils.log"),
+		filepath.Join(root, "log", "ec2-macos-utils.log.0.gz"),
+		filepath.Join(root, "log", "system.log"),
+		filepath.Join(root, "run", "exec", "slot-0"),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	return layout
+}
+
+func TestPlanUninstall(t *testing.T) {
+	layout := testUninstallLayout(t)
+
+	items, err := planUninstall(layout, false)
+	require.NoError(t, err)
+
+	kinds := map[string]string{}
+	for _, item := range items {
+		kinds[item.Path] = item.Kind
+	}
+	logDir := filepath.Dir(layout.logPatterns[0])
+	assert.Equal(t, map[string]string{
+		filepath.Join(layout.jobDirs[launchd.Daemon], uninstallJobPrefix+"hostname.plist"): uninstallJobKind,
+		filepath.Join(layout.stateDir, "state.db"):                                         uninstallStateKind,
+		filepath.Join(layout.stateDir, "uploads"):                                          uninstallCacheKind,
+		filepath.Join(layout.stateDir, "sysdiagnose"):                                      uninstallDiagnosticsKind,
+		filepath.Join(logDir, "ec2-macos-utils.log"):                                       uninstallLogKind,
+		filepath.Join(logDir, "ec2-macos-utils.log.0.gz"):                                  uninstallLogKind,
+		layout.stateDir:   uninstallStateKind,
+		layout.runDirs[0]: uninstallCacheKind,
+	}, kinds, "only the utility's jobs and logs should be removed")
+	assert.Equal(t, uninstallJobKind, items[0].Kind, "jobs should be removed first")
+	assert.Equal(t, uninstallJobPrefix+"hostname", items[0].Label)
+}
+
+func TestRunUninstall(t *testing.T) {
+	layout := testUninstallLayout(t)
+	items, err := planUninstall(layout, false)
+	require.NoError(t, err)
+
+	var removedJobs []string
+	runUninstall(context.Background(), items, func(_ context.Context, kind, label string) error {
+		removedJobs = append(removedJobs, kind+" "+label)
+		return nil
+	})
+
+	assert.Equal(t, []string{launchd.Daemon + " " + uninstallJobPrefix + "hostname"}, removedJobs)
+	for _, item := range items {
+		assert.True(t, item.Removed, item.Path)
+		assert.Empty(t, item.Error, item.Path)
+	}
+	assert.NoDirExists(t, layout.stateDir)
+	assert.NoDirExists(t, layout.runDirs[0])
+	assert.FileExists(t, filepath.Join(filepath.Dir(layout.logPatterns[0]), "system.log"), "other logs should be kept")
+}
+
+func TestRunUninstall_KeepDiagnostics(t *testing.T) {
+	layout := testUninstallLayout(t)
+	items, err := planUninstall(layout, true)
+	require.NoError(t, err)
+
+	runUninstall(context.Background(), items, func(context.Context, string, string) error {
+		return errors.New("not loaded")
+	})
+
+	assert.FileExists(t, filepath.Join(layout.stateDir, "sysdiagnose", "sysdiagnose.tar.gz"))
+	assert.NoFileExists(t, filepath.Join(layout.stateDir, "state.db"))
+	assert.NoDirExists(t, filepath.Join(layout.stateDir, "uploads"))
+	for _, item := range items {
+		switch item.Kind {
+		case uninstallDiagnosticsKind:
+			assert.True(t, item.Kept)
+			assert.False(t, item.Removed)
+		case uninstallJobKind:
+			assert.Equal(t, "not loaded", item.Error, "a failure should be reported")
+		default:
+			assert.True(t, item.Removed, "a failure shouldn't stop later removals: %s", item.Path)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+)
+
+const updatesDefaultInstallTimeout = 2 * time.Hour
+
+// updatesResult is the outcome of listing or installing updates.
+type updatesResult struct {
+	Available       []softwareupdate.Update   `json:"available"`
+	Deferred        []softwareupdate.Deferred `json:"deferred"`
+	Installed       []softwareupdate.Update   `json:"installed,omitempty"`
+	RestartRequired bool                      `json:"restart_required,omitempty"`
+}
+
+// updatesCommand creates a new command which groups software update utilities.
+func updatesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "updates",
+		Short: "software update management",
+		Long: strings.TrimSpace(`
+utilities for listing and installing macOS software updates.
+
+Updates can be held back by a local policy set with 'updates defer',
+which pins macOS to a major version and/or defers updates until they've
+been available for a number of days. macOS only honors deferrals from
+MDM profiles, so the policy applies to updates listed and installed by
+these commands, not to updates installed by other means.
+        `),
+	}
+
+	var policyPath string
+	cmd.PersistentFlags().StringVar(&policyPath, "policy-file", softwareupdate.DefaultPolicyPath, "file where the update deferral policy is saved")
+
+	cmd.AddCommand(
+		updatesListCommand(&policyPath),
+		updatesInstallCommand(&policyPath),
+		updatesDeferCommand(&policyPath),
+		updatesConfigureCommand(),
+	)
+
+	return cmd
+}
+
+// updatesListCommand creates a new command which lists the available software updates.
+func updatesListCommand(policyPath *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list available software updates",
+		Long: strings.TrimSpace(`
+list prints the available software updates, separating those held back
+by the deferral policy, as a table or, with --json, a JSON object.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var asJSON bool
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the updates as JSON")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		result, err := availableUpdates(cmd.Context(), *policyPath)
+		if err != nil {
+			return err
+		}
+
+		return printUpdates(cmd, result, asJSON)
+	}
+
+	return cmd
+}
+
+// updatesInstallCommand creates a new command which installs software updates.
+func updatesInstallCommand(policyPath *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install [label...]",
+		Short: "install software updates",
+		Long: strings.TrimSpace(`
+install installs the updates with the given labels (as printed by
+'updates list'), every available update with --all, or the updates
+Apple recommends with --recommended. Updates held back by the deferral
+policy are never installed, even when named.
+
+Licenses are accepted and nothing waits for input. The installation is
+abandoned when --timeout expires. The instance isn't restarted unless
+--restart is set, even when an update requires it; the result reports
+whether a restart is required. On Apple silicon, macOS updates may
+require the credentials of a volume owner and fail to install
+non-interactively.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+	}
+
+	var (
+		all, recommended, restart, noRestart, asJSON bool
+		timeout                                      time.Duration
+	)
+	cmd.Flags().BoolVar(&all, "all", false, "install every available update")
+	cmd.Flags().BoolVar(&recommended, "recommended", false, "install the recommended updates")
+	cmd.Flags().BoolVar(&restart, "restart", false, "restart after installing updates that require it")
+	cmd.Flags().BoolVar(&noRestart, "no-restart", false, "don't restart after installing updates (default)")
+	cmd.Flags().DurationVar(&timeout, "timeout", updatesDefaultInstallTimeout, "longest time the installation may take")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the result as JSON")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, labels []string) error {
+		selections := 0
+		for _, selected := range []bool{len(labels) > 0, all, recommended} {
+			if selected {
+				selections++
+			}
+		}
+		if selections != 1 {
+			return errors.New("exactly one of update labels, --all, or --recommended is required")
+		}
+		if restart && noRestart {
+			return errors.New("only one of --restart and --no-restart can be set")
+		}
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+
+		result, err := availableUpdates(cmd.Context(), *policyPath)
+		if err != nil {
+			return err
+		}
+		install, err := selectUpdates(result, labels, recommended)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+		defer cancel()
+		installLabels := make([]string, 0, len(install))
+		for _, u := range install {
+			installLabels = append(installLabels, u.Label)
+			result.RestartRequired = result.RestartRequired || u.Restart
+		}
+		if err := softwareupdate.Install(ctx, installLabels, softwareupdate.InstallOptions{Restart: restart}); err != nil {
+			return err
+		}
+		result.Installed = install
+		if result.RestartRequired && !restart {
+			logrus.Warn("Installed updates require a restart")
+		}
+
+		return printUpdates(cmd, result, asJSON)
+	}
+
+	return cmd
+}
+
+// selectUpdates returns the available updates to install: those with the labels, the recommended ones, or all of
+// them. Naming a deferred or unknown update is an error.
+func selectUpdates(result updatesResult, labels []string, recommended bool) ([]softwareupdate.Update, error) {
+	if len(labels) == 0 {
+		var selected []softwareupdate.Update
+		for _, u := range result.Available {
+			if !recommended || u.Recommended {
+				selected = append(selected, u)
+			}
+		}
+		return selected, nil
+	}
+
+	available := map[string]softwareupdate.Update{}
+	for _, u := range result.Available {
+		available[u.Label] = u
+	}
+	deferred := map[string]string{}
+	for _, d := range result.Deferred {
+		deferred[d.Label] = d.Reason
+	}
+
+	selected := make([]softwareupdate.Update, 0, len(labels))
+	for _, label := range labels {
+		if reason, ok := deferred[label]; ok {
+			return nil, fmt.Errorf("update %q is held back by the deferral policy: %s", label, reason)
+		}
+		u, ok := available[label]
+		if !ok {
+			return nil, fmt.Errorf("update %q isn't available", label)
+		}
+		selected = append(selected, u)
+	}
+
+	return selected, nil
+}
+
+// updatesDeferCommand creates a new command which sets the update deferral policy.
+func updatesDeferCommand(policyPath *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "defer",
+		Short: "hold back software updates",
+		Long: strings.TrimSpace(`
+defer sets the deferral policy applied by 'updates list' and 'updates
+install'. --pin-major holds back macOS updates to a later major version
+(e.g. --pin-major 14 keeps Sonoma and skips Sequoia) and --days holds
+back every update until it's been available for that many days, counted
+from when it was first listed on this instance. Setting either to 0, or
+--clear, removes it.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	var pinMajor, days int
+	var clearPolicy bool
+	cmd.Flags().IntVar(&pinMajor, "pin-major", 0, "hold back macOS updates to a later major version than this")
+	cmd.Flags().IntVar(&days, "days", 0, "hold back updates until they've been available for this many days")
+	cmd.Flags().BoolVar(&clearPolicy, "clear", false, "remove the deferral policy")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if pinMajor < 0 || days < 0 {
+			return errors.New("--pin-major and --days cannot be negative")
+		}
+		if !clearPolicy && !cmd.Flags().Changed("pin-major") && !cmd.Flags().Changed("days") {
+			return errors.New("one of --pin-major, --days, or --clear is required")
+		}
+
+		policy, err := softwareupdate.LoadPolicy(*policyPath)
+		if err != nil {
+			return err
+		}
+		if clearPolicy {
+			policy.PinMajor, policy.DeferDays = 0, 0
+		}
+		if cmd.Flags().Changed("pin-major") {
+			policy.PinMajor = pinMajor
+		}
+		if cmd.Flags().Changed("days") {
+			policy.DeferDays = days
+		}
+		if err := policy.Save(*policyPath); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"pin_major":  policy.PinMajor,
+			"defer_days": policy.DeferDays,
+		}).Info("Saved update deferral policy")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// updatesConfigureCommand creates a new command which configures automatic updates.
+func updatesConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "configure automatic software updates",
+		Long: strings.TrimSpace(`
+configure turns automatic update behaviors on or off by writing the
+com.apple.SoftwareUpdate and com.apple.commerce preferences, e.g. so
+that CI hosts don't download multi-gigabyte upgrades or show blocking
+prompts during jobs:
+
+  ec2-macos-utils updates configure --auto-check=off --auto-download=off \
+    --auto-install-macos=off --auto-install-apps=off
+
+Settings that aren't given are left unchanged. Without any, the current
+settings are printed, "default" meaning that macOS uses its default.
+Settings installed by MDM profiles take precedence over these.
+
+Changing settings requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.NoArgs,
+	}
+
+	values := map[string]*string{}
+	for _, s := range softwareupdate.Settings {
+		values[s.Name] = cmd.Flags().String(s.Name, "", s.Description+" (on or off)")
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		changes := map[string]bool{}
+		for name, value := range values {
+			if !cmd.Flags().Changed(name) {
+				continue
+			}
+			switch *value {
+			case "on":
+				changes[name] = true
+			case "off":
+				changes[name] = false
+			default:
+				return fmt.Errorf("invalid --%s %q, expected on or off", name, *value)
+			}
+		}
+
+		if len(changes) == 0 {
+			return printUpdateSettings(cmd)
+		}
+		if err := assertRootPrivileges(cmd, nil); err != nil {
+			return err
+		}
+		for _, s := range softwareupdate.Settings {
+			enabled, ok := changes[s.Name]
+			if !ok {
+				continue
+			}
+			if err := s.Write(cmd.Context(), enabled); err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{
+				"setting": s.Name,
+				"enabled": enabled,
+			}).Info("Configured automatic updates")
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// printUpdateSettings writes the current automatic update settings as a table.
+func printUpdateSettings(cmd *cobra.Command) error {
+	styler := contextual.Styler(cmd.Context())
+	table := output.NewTable(styler, "setting", "state")
+	for _, s := range softwareupdate.Settings {
+		enabled, ok, err := s.Read(cmd.Context())
+		if err != nil {
+			return err
+		}
+		state := "default"
+		switch {
+		case ok && enabled:
+			state = "on"
+		case ok:
+			state = "off"
+		}
+		table.AddRow(s.Name, state)
+	}
+
+	return table.Render(cmd.OutOrStdout())
+}
+
+// availableUpdates lists the updates and applies the deferral policy. The policy's record of when updates were first
+// seen is saved when possible; without root privileges, deferrals are measured from now for new updates.
+func availableUpdates(ctx context.Context, policyPath string) (updatesResult, error) {
+	policy, err := softwareupdate.LoadPolicy(policyPath)
+	if err != nil {
+		return updatesResult{}, err
+	}
+	updates, err := softwareupdate.List(ctx)
+	if err != nil {
+		return updatesResult{}, err
+	}
+
+	var result updatesResult
+	result.Available, result.Deferred = policy.Apply(updates, time.Now())
+	if policy.DeferDays > 0 {
+		if err := policy.Save(policyPath); err != nil {
+			logrus.WithError(err).Debug("Failed to save when updates were first seen")
+		}
+	}
+
+	return result, nil
+}
+
+// printUpdates writes the result as JSON or as a table.
+func printUpdates(cmd *cobra.Command, result updatesResult, asJSON bool) error {
+	if asJSON {
+		return printJSON(cmd, result)
+	}
+
+	return updatesTable(cmd.Context(), cmd.OutOrStdout(), result)
+}
+
+// updatesTable renders the result as a table.
+func updatesTable(ctx context.Context, w io.Writer, result updatesResult) error {
+	styler := contextual.Styler(ctx)
+	table := output.NewTable(styler, "label", "version", "restart", "state")
+
+	installed := map[string]bool{}
+	for _, u := range result.Installed {
+		installed[u.Label] = true
+	}
+	for _, u := range result.Available {
+		state := styler.Good("available")
+		if installed[u.Label] {
+			state = styler.Good("installed")
+		}
+		table.AddRow(u.Label, u.Version, strconv.FormatBool(u.Restart), state)
+	}
+	for _, d := range result.Deferred {
+		table.AddRow(d.Label, d.Version, strconv.FormatBool(d.Restart), styler.Caution(d.Reason))
+	}
+
+	return table.Render(w)
+}
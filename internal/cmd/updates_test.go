@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+)
+
+func TestSelectUpdates(t *testing.T) {
+	safari := softwareupdate.Update{Label: "Safari", Recommended: true}
+	clt := softwareupdate.Update{Label: "CLT"}
+	result := updatesResult{
+		Available: []softwareupdate.Update{safari, clt},
+		Deferred: []softwareupdate.Deferred{
+			{Update: softwareupdate.Update{Label: "macOS 15"}, Reason: "pinned to macOS 14"},
+		},
+	}
+
+	selected, err := selectUpdates(result, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []softwareupdate.Update{safari, clt}, selected)
+
+	selected, err = selectUpdates(result, nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []softwareupdate.Update{safari}, selected)
+
+	selected, err = selectUpdates(result, []string{"CLT"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []softwareupdate.Update{clt}, selected)
+
+	_, err = selectUpdates(result, []string{"macOS 15"}, false)
+	assert.Error(t, err, "deferred updates shouldn't be installed")
+	_, err = selectUpdates(result, []string{"Xcode"}, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/pflag"
+
+	"github.com/aws/ec2-macos-utils/internal/naming"
+)
+
+// uploadDefaultStateDir is where the progress of interrupted uploads is saved so they can be resumed.
+const uploadDefaultStateDir = "/private/var/db/ec2-macos-utils/uploads"
+
+// uploadArgs is a struct for holding the flags that upload artifacts to S3.
+type uploadArgs struct {
+	destination  string
+	storageClass string
+	tags         map[string]string
+	kmsKeyID     string
+	maxRate      string
+	key          string
+	// metadata is the user-defined metadata of uploaded objects, which is set by commands rather than flags, e.g. why
+	// a sysdiagnose was collected.
+	metadata map[string]string
+}
+
+// uploadObject is a file to upload and its key under the destination prefix.
+type uploadObject struct {
+	path string
+	key  string
+}
+
+// addFlags registers the S3 upload flags.
+func (a *uploadArgs) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&a.destination, "upload", "", "upload the artifacts to an S3 location (e.g. s3://bucket/prefix)")
+	flags.StringVar(&a.storageClass, "upload-storage-class", "", "S3 storage class of uploaded objects (e.g. STANDARD_IA)")
+	flags.StringToStringVar(&a.tags, "upload-tag", nil, "tag uploaded objects with key=value, can be repeated")
+	flags.StringVar(&a.kmsKeyID, "upload-kms-key-id", "", "encrypt uploaded objects with this KMS key, or aws/s3 for the AWS managed key")
+	flags.StringVar(&a.maxRate, "upload-max-rate", "", "limit upload bandwidth per second (e.g. 5MB)")
+	flags.StringVar(&a.key, "upload-key", "{"+naming.Filename+"}", "naming template for the key of uploaded objects under the --upload prefix (e.g. {instance-id}/{az}/{timestamp}-sysdiagnose.tar.gz)")
+}
+
+// enabled reports whether artifacts should be uploaded.
+func (a uploadArgs) enabled() bool {
+	return a.destination != ""
+}
+
+// objectKey returns the key of the file at path under the destination prefix, expanding the key template with the
+// vars and the file's name.
+func (a uploadArgs) objectKey(vars naming.Vars, path string) (string, error) {
+	return naming.Expand(a.key, naming.Merge(vars, naming.Vars{naming.Filename: filepath.Base(path)}))
+}
+
+// bytesPerSecond parses the bandwidth limit, zero being unlimited.
+func (a uploadArgs) bytesPerSecond() (int64, error) {
+	if a.maxRate == "" {
+		return 0, nil
+	}
+	rate, err := units.FromHumanSize(a.maxRate)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("invalid upload rate %q", a.maxRate)
+	}
+
+	return rate, nil
+}
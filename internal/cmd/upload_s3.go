@@ -0,0 +1,147 @@
+//go:build !minimal
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/upload"
+)
+
+// validate checks the flags before any work is done so that mistakes don't surface after a long collection.
+func (a uploadArgs) validate() error {
+	if !a.enabled() {
+		return nil
+	}
+	if _, err := upload.ParseDestination(a.destination); err != nil {
+		return err
+	}
+	if _, err := a.bytesPerSecond(); err != nil {
+		return err
+	}
+	if _, err := a.objectKey(placeholderVars(), "file"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// preflight verifies that the S3 endpoint is reachable and the AWS credentials are valid before any work is done so
+// that an upload doesn't fail only after a long collection.
+func (a uploadArgs) preflight(ctx context.Context) error {
+	if !a.enabled() {
+		return nil
+	}
+	dest, err := upload.ParseDestination(a.destination)
+	if err != nil {
+		return err
+	}
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return fmt.Errorf("cannot upload to %s: %w", a.destination, err)
+	}
+
+	endpoint, err := s3Endpoint(ctx, cfg, dest.Bucket)
+	if err != nil {
+		return fmt.Errorf("cannot upload to %s: %w", a.destination, err)
+	}
+	if err := aws.CheckConfigConnectivity(ctx, cfg, endpoint); err != nil {
+		return fmt.Errorf("cannot upload to %s: %w", a.destination, err)
+	}
+
+	arn, err := aws.Preflight(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("cannot upload to %s: %w", a.destination, err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"arn":      arn,
+		"endpoint": endpoint,
+	}).Debug("Verified connectivity and credentials for upload")
+
+	return nil
+}
+
+// upload uploads the objects under the destination prefix. Files that don't exist are skipped so that optional
+// companions (e.g. manifests) can be passed unconditionally.
+func (a uploadArgs) upload(ctx context.Context, objects ...uploadObject) error {
+	if !a.enabled() {
+		return nil
+	}
+	uploader, dest, err := a.uploader(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if _, err := os.Stat(object.path); os.IsNotExist(err) {
+			continue
+		}
+		if err := uploader.UploadFile(ctx, object.path, dest.Bucket, dest.Key(object.key)); err != nil {
+			return fmt.Errorf("upload %s to %s: %w", object.path, dest, err)
+		}
+	}
+
+	return nil
+}
+
+// uploadStream uploads what's read from r until EOF under the key below the destination prefix, while it's produced.
+func (a uploadArgs) uploadStream(ctx context.Context, r io.Reader, key string) error {
+	uploader, dest, err := a.uploader(ctx)
+	if err != nil {
+		return err
+	}
+	if err := uploader.UploadStream(ctx, r, dest.Bucket, dest.Key(key)); err != nil {
+		return fmt.Errorf("upload to %s: %w", dest, err)
+	}
+
+	return nil
+}
+
+// objectURI returns the s3:// URI of the object uploaded under the key below the destination prefix.
+func (a uploadArgs) objectURI(key string) (string, error) {
+	dest, err := upload.ParseDestination(a.destination)
+	if err != nil {
+		return "", err
+	}
+
+	return dest.URI(key), nil
+}
+
+// uploader returns the uploader configured by the flags and the destination it uploads to.
+func (a uploadArgs) uploader(ctx context.Context) (*upload.Uploader, upload.Destination, error) {
+	dest, err := upload.ParseDestination(a.destination)
+	if err != nil {
+		return nil, upload.Destination{}, err
+	}
+	rate, err := a.bytesPerSecond()
+	if err != nil {
+		return nil, upload.Destination{}, err
+	}
+
+	cfg, err := aws.LoadConfig(ctx, contextual.AWSOptions(ctx))
+	if err != nil {
+		return nil, upload.Destination{}, err
+	}
+	uploader := &upload.Uploader{
+		Client: s3.NewFromConfig(cfg),
+		Options: upload.Options{
+			StorageClass:   types.StorageClass(a.storageClass),
+			Tags:           a.tags,
+			Metadata:       a.metadata,
+			KMSKeyID:       a.kmsKeyID,
+			BytesPerSecond: rate,
+			StateDir:       uploadDefaultStateDir,
+		},
+	}
+
+	return uploader, dest, nil
+}
@@ -0,0 +1,19 @@
+//go:build !minimal
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSysdiagnoseLocation(t *testing.T) {
+	location, err := sysdiagnoseLocation(uploadArgs{}, "/tmp/sysdiagnose_20261016_120000.tar.gz", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/sysdiagnose_20261016_120000.tar.gz", location)
+
+	location, err = sysdiagnoseLocation(uploadArgs{destination: "s3://bucket/diag"}, "/tmp/sysdiagnose_20261016_120000.tar.gz", "host/sysdiagnose_20261016_120000.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://bucket/diag/host/sysdiagnose_20261016_120000.tar.gz", location, "uploaded archives should be printed as their S3 URI")
+}
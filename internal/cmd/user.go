@@ -0,0 +1,444 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/localuser"
+	"github.com/aws/ec2-macos-utils/internal/redact"
+)
+
+// userCommand creates a new command which groups local user management utilities.
+func userCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "local user management",
+		Long:  "utilities for creating, modifying, and deleting local users",
+	}
+
+	cmd.AddCommand(
+		userCreateCommand(),
+		userDeleteCommand(),
+		userModifyCommand(),
+		userSetPasswordCommand(),
+		userGrantSudoCommand(),
+		userRevokeSudoCommand(),
+	)
+
+	return cmd
+}
+
+// userCreateCommand creates a new command which creates a local user.
+func userCreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "create a local user",
+		Long: strings.TrimSpace(`
+create creates a local user and their home directory with sysadminctl.
+Password login is disabled, so the user can only log in with SSH keys
+until a password is set with set-password.
+
+Service accounts are usually created with --hidden, which hides them
+from the login window and System Settings, and a UID below 500.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var u localuser.User
+	cmd.Flags().StringVar(&u.FullName, "full-name", "", "full name of the user (default the short name)")
+	cmd.Flags().IntVar(&u.UID, "uid", 0, "user ID (default the next available ID)")
+	cmd.Flags().StringVar(&u.Shell, "shell", "", "path of the login shell (default /bin/zsh)")
+	cmd.Flags().StringVar(&u.Home, "home", "", "path of the home directory (default /Users/<name>)")
+	cmd.Flags().BoolVar(&u.Admin, "admin", false, "make the user an administrator")
+	cmd.Flags().BoolVar(&u.Hidden, "hidden", false, "hide the user from the login window and System Settings")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		u.Name = args[0]
+		if err := localuser.Create(cmd.Context(), u); err != nil {
+			return err
+		}
+		logrus.WithField("user", u.Name).Info("Created user")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// userDeleteCommand creates a new command which deletes a local user.
+func userDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete a local user",
+		Long: strings.TrimSpace(`
+delete deletes a local user with sysadminctl, including their home
+directory unless --keep-home is set.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var keepHome bool
+	cmd.Flags().BoolVar(&keepHome, "keep-home", false, "keep the user's home directory")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := localuser.Delete(cmd.Context(), args[0], keepHome); err != nil {
+			return err
+		}
+		logrus.WithField("user", args[0]).Info("Deleted user")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// userModifyCommand creates a new command which modifies a local user.
+func userModifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "modify <name>",
+		Short: "modify a local user",
+		Long: strings.TrimSpace(`
+modify changes the settings of a local user. Only the settings given
+as flags are changed.
+
+--home only changes the recorded home directory; the contents of the
+current home directory aren't moved.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var (
+		fullName, shell, home string
+		admin, hidden         bool
+	)
+	cmd.Flags().StringVar(&fullName, "full-name", "", "full name of the user")
+	cmd.Flags().StringVar(&shell, "shell", "", "path of the login shell")
+	cmd.Flags().StringVar(&home, "home", "", "path of the home directory")
+	cmd.Flags().BoolVar(&admin, "admin", false, "make the user an administrator, or not with --admin=false")
+	cmd.Flags().BoolVar(&hidden, "hidden", false, "hide the user from the login window and System Settings, or show them with --hidden=false")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		var m localuser.Modification
+		flags := cmd.Flags()
+		if flags.Changed("full-name") {
+			m.FullName = &fullName
+		}
+		if flags.Changed("shell") {
+			m.Shell = &shell
+		}
+		if flags.Changed("home") {
+			m.Home = &home
+		}
+		if flags.Changed("admin") {
+			m.Admin = &admin
+		}
+		if flags.Changed("hidden") {
+			m.Hidden = &hidden
+		}
+		if m == (localuser.Modification{}) {
+			return errors.New("no changes given, see --help for the available flags")
+		}
+
+		if err := localuser.Modify(cmd.Context(), args[0], m); err != nil {
+			return err
+		}
+		logrus.WithField("user", args[0]).Info("Modified user")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// userSetPasswordArgs is a struct for holding the arguments for the user set-password command.
+type userSetPasswordArgs struct {
+	stdin       bool
+	parameter   string
+	generate    bool
+	length      int
+	store       []string
+	storeKMSKey string
+}
+
+// source returns the name of the selected password source, or an error unless exactly one is selected.
+func (a userSetPasswordArgs) source() (string, error) {
+	var sources []string
+	if a.stdin {
+		sources = append(sources, "--password-stdin")
+	}
+	if a.parameter != "" {
+		sources = append(sources, "--ssm-parameter")
+	}
+	if a.generate {
+		sources = append(sources, "--generate")
+	}
+	if len(sources) != 1 {
+		return "", errors.New("exactly one of --password-stdin, --ssm-parameter, or --generate is required")
+	}
+
+	return sources[0], nil
+}
+
+// validate checks the arguments before the password is changed.
+func (a userSetPasswordArgs) validate() error {
+	if _, err := a.source(); err != nil {
+		return err
+	}
+	if len(a.store) > 0 && !a.generate {
+		return errors.New("--store can only be used with --generate")
+	}
+	for _, dest := range a.store {
+		if _, ok := config.ParameterName(dest); !ok && dest != "stdout" {
+			return fmt.Errorf("invalid --store %q, expected stdout or ssm:///parameter-name", dest)
+		}
+	}
+	if a.generate && a.length < localuser.MinPasswordLength {
+		return fmt.Errorf("--length must be at least %d", localuser.MinPasswordLength)
+	}
+
+	return nil
+}
+
+// userSetPasswordCommand creates a new command which sets a local user's password.
+func userSetPasswordCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-password <name>",
+		Short: "set or rotate a local user's password",
+		Long: strings.TrimSpace(`
+set-password sets a local user's password, which is required to log in
+with Screen Sharing or on the serial console. The password is read from
+exactly one source:
+
+  --password-stdin      the first line of standard input
+  --ssm-parameter NAME  an SSM Parameter Store (SecureString) parameter,
+                        which requires ssm:GetParameter (and kms:Decrypt
+                        for customer managed keys)
+  --generate            a random password of --length characters
+
+A generated password is stored where --store says, which can be
+repeated: "stdout" prints it and ssm:///parameter-name saves it as a
+SecureString parameter, overwriting the previous value so that the
+parameter always holds the current password. Saving requires
+ssm:PutParameter. The password is stored before it's set so that a
+failure to store it doesn't leave a password nobody knows, and only
+once the user is known to exist. Without --store, a generated password
+is printed.
+
+The user's login keychain isn't updated and keeps the previous password.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var args userSetPasswordArgs
+	cmd.Flags().BoolVar(&args.stdin, "password-stdin", false, "read the password from standard input")
+	cmd.Flags().StringVar(&args.parameter, "ssm-parameter", "", "read the password from this SSM Parameter Store parameter")
+	cmd.Flags().BoolVar(&args.generate, "generate", false, "generate a random password")
+	cmd.Flags().IntVar(&args.length, "length", 24, "length of generated passwords")
+	cmd.Flags().StringArrayVar(&args.store, "store", nil, "where to store a generated password: stdout or ssm:///parameter-name (repeatable, default stdout)")
+	cmd.Flags().StringVar(&args.storeKMSKey, "store-kms-key-id", "", "KMS key to encrypt stored SSM parameters with (default the AWS managed key)")
+
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, cmdArgs []string) error {
+		if err := args.validate(); err != nil {
+			return err
+		}
+		name := cmdArgs[0]
+		if err := localuser.ValidateName(name); err != nil {
+			return err
+		}
+
+		password, err := readPassword(cmd, args)
+		if err != nil {
+			return err
+		}
+		if args.generate {
+			// The generated password is stored before dscl sets it, so that a failure to store it can't leave the
+			// user with a password nobody knows. The checks of SetPassword are made first so that the stored value
+			// isn't replaced by a password that's never set.
+			if err := localuser.ValidatePassword(password); err != nil {
+				return err
+			}
+			exists, err := localuser.Exists(cmd.Context(), name)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("%w: %s", localuser.ErrNotFound, name)
+			}
+			if err := storePassword(cmd, args, name, password); err != nil {
+				return err
+			}
+		}
+
+		if err := localuser.SetPassword(cmd.Context(), name, password); err != nil {
+			if args.generate {
+				logrus.WithField("user", name).Warn("The stored password wasn't set on the user and isn't their password")
+			}
+			return err
+		}
+		logrus.WithField("user", name).Info("Set password")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// readPassword reads or generates the password from the selected source.
+func readPassword(cmd *cobra.Command, args userSetPasswordArgs) (string, error) {
+	if args.generate {
+		password, err := localuser.GeneratePassword(args.length)
+		redact.Secret(password)
+		return password, err
+	}
+
+	return readSecret(cmd, args.stdin, args.parameter)
+}
+
+// readSecret reads a secret from the first line of stdin when fromStdin is set, and from the SSM parameter
+// otherwise.
+func readSecret(cmd *cobra.Command, fromStdin bool, parameter string) (string, error) {
+	if fromStdin {
+		line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("cannot read from stdin: %w", err)
+		}
+		secret := strings.TrimRight(line, "\r\n")
+		redact.Secret(secret)
+		return secret, nil
+	}
+
+	secret, err := readSecureParameter(cmd.Context(), parameter)
+	if err != nil {
+		return "", err
+	}
+	redact.Secret(secret)
+
+	return secret, nil
+}
+
+// storePassword stores the generated password of the user in every --store destination.
+func storePassword(cmd *cobra.Command, args userSetPasswordArgs, name, password string) error {
+	stores := args.store
+	if len(stores) == 0 {
+		stores = []string{"stdout"}
+	}
+
+	for _, dest := range stores {
+		if dest == "stdout" {
+			fmt.Fprintln(cmd.OutOrStdout(), password)
+			continue
+		}
+
+		parameter, _ := config.ParameterName(dest)
+		if err := storeSecureParameter(cmd.Context(), parameter, password, "Password of "+name, args.storeKMSKey); err != nil {
+			return fmt.Errorf("cannot store password in parameter %s: %w", parameter, err)
+		}
+		logrus.WithField("parameter", parameter).Info("Stored password")
+	}
+
+	return nil
+}
+
+// userGrantSudoCommand creates a new command which grants a local user sudo rights.
+func userGrantSudoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "grant-sudo",
+		Short: "grant a local user sudo rights",
+		Long: strings.TrimSpace(`
+grant-sudo lets the --user user run commands as root with sudo by
+writing a drop-in file in ` + localuser.SudoersDir + `, replacing the one
+written before for the user. With --commands, only those commands,
+given as absolute paths with optional arguments, are allowed; otherwise
+every command is. With --nopasswd, the user isn't asked for their
+password, e.g. for service accounts that don't have one.
+
+The user must already exist, and names that sudoers reads as ALL or an
+alias, such as ADMINS, are rejected since they'd grant more than one
+user.
+
+The file is checked with visudo before it's installed, and the complete
+sudoers configuration after, so that a malformed rule can't lock
+administrators out of sudo.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var rule localuser.SudoRule
+	cmd.Flags().StringVar(&rule.User, "user", "", "local user to grant sudo rights")
+	cmd.Flags().BoolVar(&rule.NoPasswd, "nopasswd", false, "don't ask for the user's password")
+	cmd.Flags().StringArrayVar(&rule.Commands, "commands", nil, "absolute path of a command the user may run, with optional arguments (repeatable)")
+	_ = cmd.MarkFlagRequired("user")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if err := localuser.GrantSudo(cmd.Context(), rule); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"user":     rule.User,
+			"nopasswd": rule.NoPasswd,
+			"commands": rule.Commands,
+			"path":     localuser.SudoersPath(rule.User),
+		}).Info("Granted sudo rights")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// userRevokeSudoCommand creates a new command which revokes the sudo rights granted with grant-sudo.
+func userRevokeSudoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revoke-sudo",
+		Short: "revoke the sudo rights granted with grant-sudo",
+		Long: strings.TrimSpace(`
+revoke-sudo removes the drop-in file written by grant-sudo for the
+--user user. Rights granted by other sudoers files aren't changed.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var user string
+	cmd.Flags().StringVar(&user, "user", "", "local user to revoke sudo rights from")
+	_ = cmd.MarkFlagRequired("user")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if err := localuser.RevokeSudo(user); err != nil {
+			return err
+		}
+		logrus.WithField("user", user).Info("Revoked sudo rights")
+
+		return nil
+	}
+
+	return cmd
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserSetPasswordArgs_Validate(t *testing.T) {
+	assert.NoError(t, userSetPasswordArgs{stdin: true}.validate())
+	assert.NoError(t, userSetPasswordArgs{parameter: "/fleet/password"}.validate())
+	assert.NoError(t, userSetPasswordArgs{generate: true, length: 24, store: []string{"stdout", "ssm:///fleet/password"}}.validate())
+
+	assert.Error(t, userSetPasswordArgs{}.validate(), "a source is required")
+	assert.Error(t, userSetPasswordArgs{stdin: true, generate: true, length: 24}.validate(), "only one source is allowed")
+	assert.Error(t, userSetPasswordArgs{stdin: true, store: []string{"stdout"}}.validate(), "only generated passwords are stored")
+	assert.Error(t, userSetPasswordArgs{generate: true, length: 24, store: []string{"/tmp/password"}}.validate())
+	assert.Error(t, userSetPasswordArgs{generate: true, length: 8}.validate())
+}
+
+func TestUserSetPassword_GeneratedNotStoredForUnknownUser(t *testing.T) {
+	// The user can't be found, either because dscl isn't available or because there's no such user, so the generated
+	// password mustn't be stored.
+	cmd := userSetPasswordCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetContext(context.Background())
+	require.NoError(t, cmd.ParseFlags([]string{"--generate", "--store", "stdout"}))
+
+	assert.Error(t, cmd.RunE(cmd, []string{"ec2-macos-utils-no-such-user"}))
+	assert.Empty(t, out.String(), "the password shouldn't be stored before the user is found")
+}
@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/state"
 )
 
 func watchdogCommand() *cobra.Command {
@@ -16,6 +25,148 @@ Contains subcommands for monitoring various aspects of system health.
         `),
 	}
 
-	cmd.AddCommand(newNetworkHealthMonitorCommand())
+	cmd.AddCommand(
+		newNetworkHealthMonitorCommand(),
+		newScheduledEventsMonitorCommand(),
+		watchdogStatusCommand(),
+		watchdogPauseCommand(),
+		watchdogResumeCommand(),
+	)
 	return cmd
 }
+
+// watchdogStatusCommand creates a new command which reports the state of each watchdog.
+func watchdogStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show watchdog status",
+		Long: strings.TrimSpace(`
+show the state of each watchdog and the diagnostic data it has captured,
+as recorded in the state database. Data captured by earlier versions,
+which didn't record their captures, is found in the output base
+directories and recorded.
+The network health monitor stops on its next start once it has captured data.
+Watchdogs paused with "watchdog pause" are shown as paused until they resume.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		PreRunE: assertRootPrivileges,
+	}
+
+	var outputBaseDir, eventsOutputBaseDir string
+	cmd.Flags().StringVar(&outputBaseDir, "output-base-dir", networkMonitorDefaultOutputBaseDir, "base directory for sysdiagnose output")
+	cmd.Flags().StringVar(&eventsOutputBaseDir, "scheduled-events-output-base-dir", scheduledEventsDefaultOutputBaseDir, "base directory for scheduled events sysdiagnose output")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		prefix, err := getCollectionPrefix()
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to get prefix, using 'unknown'")
+			prefix = "unknown"
+		}
+		if err := importLegacyCaptures(prefix, outputBaseDir, eventsOutputBaseDir); err != nil {
+			return err
+		}
+
+		var statuses []watchdogStatus
+		err = withState(func(s *state.Store) error {
+			var err error
+			statuses, err = watchdogStatuses(s)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		styler := contextual.Styler(cmd.Context())
+		table := output.NewTable(styler, "watchdog", "state", "captures", "last capture")
+		for _, status := range statuses {
+			label, last := styler.Good(status.State), "-"
+			switch status.State {
+			case watchdogCaptured:
+				label = styler.Caution(status.State)
+			case watchdogPaused:
+				label = styler.Caution(fmt.Sprintf("%s until %s", status.State, status.PausedUntil.Local().Format(time.RFC3339)))
+			}
+			if status.LastCapture != nil {
+				last = status.LastCapture.Path
+			}
+			table.AddRow(status.Watchdog, label, strconv.Itoa(status.Captures), last)
+		}
+
+		return table.Render(cmd.OutOrStdout())
+	}
+
+	return cmd
+}
+
+// Watchdog states.
+const (
+	// watchdogArmed is the state of a watchdog that will capture diagnostic data when it detects a problem.
+	watchdogArmed = "armed"
+	// watchdogCaptured is the state of a watchdog that captured diagnostic data and won't capture any more.
+	watchdogCaptured = "captured"
+	// watchdogPaused is the state of an armed watchdog that was paused by "watchdog pause", which doesn't check for
+	// problems until it resumes.
+	watchdogPaused = "paused"
+)
+
+// watchdogStatus is the state of a watchdog and the diagnostic data it captured.
+type watchdogStatus struct {
+	Watchdog    string         `json:"watchdog"`
+	State       string         `json:"state"`
+	Captures    int            `json:"captures"`
+	LastCapture *state.Capture `json:"last_capture,omitempty"`
+	PausedUntil *time.Time     `json:"paused_until,omitempty"`
+}
+
+// watchdogStatuses returns the state of each watchdog recorded in the store.
+func watchdogStatuses(s *state.Store) ([]watchdogStatus, error) {
+	pause, paused, err := s.WatchdogsPaused()
+	if err != nil {
+		return nil, err
+	}
+	paused = paused && pause.Active(time.Now())
+
+	var statuses []watchdogStatus
+	for _, watchdog := range []string{networkMonitorWatchdog, scheduledEventsWatchdog} {
+		captures, err := s.Captures(watchdog)
+		if err != nil {
+			return nil, err
+		}
+		status := watchdogStatus{Watchdog: watchdog, State: watchdogArmed, Captures: len(captures)}
+		if len(captures) > 0 {
+			status.LastCapture = &captures[len(captures)-1]
+			// The scheduled events monitor captures once per event, so it stays armed after capturing.
+			if watchdog == networkMonitorWatchdog {
+				status.State = watchdogCaptured
+			}
+		}
+		if paused && status.State == watchdogArmed {
+			status.State, status.PausedUntil = watchdogPaused, &pause.Until
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// importLegacyCaptures records the captures of the network health monitor for the host with the prefix and of the
+// scheduled events monitor that were saved in their output base directories before captures were recorded in the
+// state.
+func importLegacyCaptures(prefix, outputBaseDir, eventsOutputBaseDir string) error {
+	if _, err := captured(networkMonitorWatchdog, prefix, filepath.Join(outputBaseDir, prefix)); err != nil {
+		return err
+	}
+
+	eventDirs, err := filepath.Glob(filepath.Join(eventsOutputBaseDir, "*"))
+	if err != nil {
+		return fmt.Errorf("invalid glob pattern: %w", err)
+	}
+	for _, dir := range eventDirs {
+		if _, err := captured(scheduledEventsWatchdog, filepath.Base(dir), dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+// watchdogMaxPause is the longest the watchdogs can be paused, so that a forgotten pause doesn't leave the host
+// unmonitored.
+const watchdogMaxPause = 7 * 24 * time.Hour
+
+// watchdogPauseCommand creates a new command which pauses the watchdogs for a while.
+func watchdogPauseCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pause",
+		Short: "pause the watchdogs for a while",
+		Long: strings.TrimSpace(`
+pause the watchdogs for a while, e.g. during planned maintenance, without
+unloading their launchd jobs. Paused watchdogs, whether they run on their
+own or as tasks of the daemon, skip their checks, and so don't collect a
+sysdiagnose, report to Auto Scaling, or notify, until --for has passed or
+"watchdog resume" is run. Pausing again replaces the previous pause.
+
+  sudo ec2-macos-utils watchdog pause --for 2h --reason "OS update"
+
+The pause is recorded in the state database, so it holds across restarts,
+and can last up to 7 days.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	var (
+		duration time.Duration
+		reason   string
+	)
+	cmd.Flags().DurationVar(&duration, "for", 0, "how long to pause the watchdogs (e.g. 30m, 2h)")
+	cmd.Flags().StringVar(&reason, "reason", "", "why the watchdogs are paused, shown in their logs")
+	_ = cmd.MarkFlagRequired("for")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if duration <= 0 || duration > watchdogMaxPause {
+			return fmt.Errorf("pause must be longer than 0 and at most %v", watchdogMaxPause)
+		}
+
+		now := time.Now()
+		pause := state.WatchdogPause{Until: now.Add(duration), Reason: reason, Time: now}
+		if err := withState(func(s *state.Store) error { return s.PauseWatchdogs(pause) }); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Watchdogs paused until %s\n", pause.Until.Local().Format(time.RFC3339))
+
+		return nil
+	}
+
+	return cmd
+}
+
+// watchdogResumeCommand creates a new command which resumes paused watchdogs.
+func watchdogResumeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resume",
+		Short: "resume paused watchdogs",
+		Long: strings.TrimSpace(`
+resume the watchdogs paused by "watchdog pause" before the pause ends.
+Running watchdogs resume at their next check.
+
+This command requires root privileges. Run with sudo if not running as root.
+        `),
+		Args:    cobra.NoArgs,
+		PreRunE: assertRootPrivileges,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		var paused bool
+		err := withState(func(s *state.Store) error {
+			pause, ok, err := s.WatchdogsPaused()
+			if err != nil {
+				return err
+			}
+			paused = ok && pause.Active(time.Now())
+			return s.ResumeWatchdogs()
+		})
+		if err != nil {
+			return err
+		}
+		if !paused {
+			fmt.Fprintln(cmd.OutOrStdout(), "Watchdogs weren't paused")
+			return nil
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Watchdogs resumed")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// activeWatchdogPause returns the watchdogs' pause if they're paused at now, or nil.
+func activeWatchdogPause(now time.Time) (*state.WatchdogPause, error) {
+	var pause *state.WatchdogPause
+	err := withState(func(s *state.Store) error {
+		p, ok, err := s.WatchdogsPaused()
+		if ok && p.Active(now) {
+			pause = &p
+		}
+		return err
+	})
+
+	return pause, err
+}
+
+// pausable makes the watchdog task skip its runs while the watchdogs are paused. A pause that can't be read doesn't
+// skip runs, so that the watchdog isn't silenced by a broken state database.
+func pausable(task scheduler.Task) scheduler.Task {
+	run, paused := task.Run, false
+	task.Run = func(ctx context.Context) error {
+		pause, err := activeWatchdogPause(time.Now())
+		if err != nil {
+			logrus.WithError(err).WithField("task", task.Name).Warn("Failed to read watchdog pause")
+		}
+		if pause != nil {
+			if !paused {
+				logrus.WithFields(logrus.Fields{
+					"task":   task.Name,
+					"until":  pause.Until,
+					"reason": pause.Reason,
+				}).Info("Watchdogs paused, skipping checks")
+			}
+			paused = true
+			return nil
+		}
+		if paused {
+			logrus.WithField("task", task.Name).Info("Watchdogs resumed")
+			paused = false
+		}
+
+		return run(ctx)
+	}
+
+	return task
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/ec2-macos-utils/internal/scheduler"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+func TestPausable(t *testing.T) {
+	withTestState(t)
+
+	runs := 0
+	task := pausable(scheduler.Task{Name: networkMonitorWatchdog, Run: func(context.Context) error {
+		runs++
+		return nil
+	}})
+	require.NoError(t, task.Run(context.Background()))
+	assert.Equal(t, 1, runs)
+
+	now := time.Now()
+	pause := state.WatchdogPause{Until: now.Add(time.Hour), Reason: "maintenance", Time: now}
+	require.NoError(t, withState(func(s *state.Store) error { return s.PauseWatchdogs(pause) }))
+	require.NoError(t, task.Run(context.Background()))
+	assert.Equal(t, 1, runs, "runs should be skipped while paused")
+
+	var statuses []watchdogStatus
+	require.NoError(t, withState(func(s *state.Store) (err error) {
+		statuses, err = watchdogStatuses(s)
+		return err
+	}))
+	for _, status := range statuses {
+		assert.Equal(t, watchdogPaused, status.State, status.Watchdog)
+		if assert.NotNil(t, status.PausedUntil) {
+			assert.True(t, pause.Until.Equal(*status.PausedUntil))
+		}
+	}
+
+	require.NoError(t, withState(func(s *state.Store) error { return s.ResumeWatchdogs() }))
+	require.NoError(t, task.Run(context.Background()))
+	assert.Equal(t, 2, runs)
+
+	// An expired pause doesn't skip runs.
+	pause.Until = now.Add(-time.Minute)
+	require.NoError(t, withState(func(s *state.Store) error { return s.PauseWatchdogs(pause) }))
+	require.NoError(t, task.Run(context.Background()))
+	assert.Equal(t, 3, runs)
+}
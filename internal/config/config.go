@@ -0,0 +1,390 @@
+// Package config provides the functionality necessary for loading EC2 macOS Utils configuration from local files and
+// AWS Systems Manager Parameter Store so flag defaults can be managed centrally.
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+
+	"github.com/aws/ec2-macos-utils/internal/redact"
+)
+
+// DefaultPath is the location of the local configuration file.
+const DefaultPath = "/etc/ec2-macos-utils/config.json"
+
+// ssmScheme is the URL scheme of configuration sources stored in Parameter Store.
+const ssmScheme = "ssm"
+
+// kmsPrefix marks a string value as base64 encoded KMS ciphertext that is decrypted when the configuration is loaded.
+const kmsPrefix = "kms:"
+
+// Config holds flag values that are applied when a flag isn't set on the command line. Values may be JSON strings,
+// numbers, booleans, or arrays of those for repeatable flags:
+//
+//	{
+//	  "global": {"verbose": true},
+//	  "commands": {
+//	    "watchdog network-health-monitor": {"interval": "30s"}
+//	  }
+//	}
+//
+// Commands that take a --profile flag of their own, e.g. devtools bootstrap, can also be given named sets of flag
+// values, selected on the command line:
+//
+//	{
+//	  "profiles": {
+//	    "devtools bootstrap": {
+//	      "ios-ci": {"clt": true, "simulator-runtime": ["iOS 17.5"]}
+//	    }
+//	  }
+//	}
+//
+// Sensitive string values can be stored encrypted as "kms:<base64 ciphertext>", as produced by 'aws kms encrypt'.
+type Config struct {
+	// Global holds flag values applied to every command that has the flag.
+	Global map[string]Value `json:"global"`
+	// Commands holds flag values keyed by command path (e.g. "logs ship"), taking precedence over Global.
+	Commands map[string]map[string]Value `json:"commands"`
+	// Profiles holds named sets of flag values keyed by command path and profile name, taking precedence over
+	// Commands when selected.
+	Profiles map[string]map[string]map[string]Value `json:"profiles"`
+}
+
+// ErrUnknownProfile is returned when a selected profile isn't configured.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Value is a configured flag value.
+type Value json.RawMessage
+
+// UnmarshalJSON keeps the raw value so that it can be validated when applied to its flag.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	*v = append((*v)[:0], data...)
+	return nil
+}
+
+// strings returns the value as one or more flag arguments.
+func (v Value) strings() ([]string, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(v, &list); err != nil {
+		s, err := scalarString(json.RawMessage(v))
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+
+	values := make([]string, 0, len(list))
+	for _, raw := range list {
+		s, err := scalarString(raw)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+
+	return values, nil
+}
+
+// scalarString converts a JSON string, number, or boolean to its flag argument form.
+func scalarString(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+
+	var scalar interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&scalar); err != nil {
+		return "", err
+	}
+	switch scalar := scalar.(type) {
+	case json.Number:
+		return scalar.String(), nil
+	case bool:
+		return fmt.Sprint(scalar), nil
+	default:
+		return "", fmt.Errorf("unsupported value %s", string(raw))
+	}
+}
+
+// Parse decodes a JSON configuration document.
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&c); err != nil {
+		return nil, fmt.Errorf("decode configuration: %w", err)
+	}
+
+	return &c, nil
+}
+
+// Apply sets the flags that weren't set on the command line to their configured values for the command at
+// commandPath. Configured flags the command doesn't have are ignored so that one configuration can be shared by
+// different versions of EC2 macOS Utils.
+func (c *Config) Apply(commandPath string, flags *pflag.FlagSet) error {
+	values := map[string]Value{}
+	for name, v := range c.Global {
+		values[name] = v
+	}
+	for name, v := range c.Commands[commandPath] {
+		values[name] = v
+	}
+
+	return apply(commandPath, values, c.Commands[commandPath], flags)
+}
+
+// ApplyProfile sets the flags that weren't set on the command line to the values of the named profile for the
+// command at commandPath. It's applied before Apply so that profile values take precedence.
+func (c *Config) ApplyProfile(commandPath, profile string, flags *pflag.FlagSet) error {
+	values, ok := c.Profiles[commandPath][profile]
+	if !ok {
+		return fmt.Errorf("%w %q for %s", ErrUnknownProfile, profile, commandPath)
+	}
+
+	return apply(commandPath, values, values, flags)
+}
+
+// apply sets the flags that weren't set yet to the values. Values of flags the command doesn't have are ignored, with
+// a warning when they're among the command-specific values.
+func apply(commandPath string, values, specific map[string]Value, flags *pflag.FlagSet) error {
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			if _, ok := specific[name]; ok {
+				logrus.WithFields(logrus.Fields{
+					"command": commandPath,
+					"flag":    name,
+				}).Warn("Ignoring configuration for unknown flag")
+			}
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+
+		args, err := values[name].strings()
+		if err != nil {
+			return fmt.Errorf("invalid configuration for --%s: %w", name, err)
+		}
+		for _, arg := range args {
+			if err := flags.Set(name, arg); err != nil {
+				return fmt.Errorf("invalid configuration for --%s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParameterStore reads configuration sources from Parameter Store. It's implemented outside of this package so that
+// builds without the SSM client, such as the minimal build, can still load local configuration files.
+type ParameterStore interface {
+	// Parameter returns the value of the named parameter, decrypting SecureString parameters.
+	Parameter(ctx context.Context, name string) (string, error)
+}
+
+// Decrypter decrypts encrypted configuration values with KMS. Like ParameterStore, it's implemented outside of this
+// package so that builds without the KMS client can still load configuration.
+type Decrypter interface {
+	// Decrypt returns the plaintext of the KMS ciphertext.
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// Loader reads configuration from a source, which is either a local file path or a Parameter Store parameter named
+// by an ssm:// URL (e.g. ssm:///ec2-macos-utils/fleet-config).
+type Loader struct {
+	// SSM creates the Parameter Store client. It's only called for ssm:// sources.
+	SSM func(ctx context.Context) (ParameterStore, error)
+	// KMS creates the KMS client. It's only called when the configuration has encrypted values.
+	KMS func(ctx context.Context) (Decrypter, error)
+}
+
+// Load reads and parses the configuration from source. A missing local file yields an error wrapping
+// os.ErrNotExist.
+func (l *Loader) Load(ctx context.Context, source string) (*Config, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if name, ok := ParameterName(source); ok {
+		data, err = l.loadParameter(ctx, name)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if err := l.decrypt(ctx, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	logrus.WithField("source", source).Debug("Loaded configuration")
+
+	return c, nil
+}
+
+// loadParameter fetches the value of the named parameter, decrypting SecureString parameters.
+func (l *Loader) loadParameter(ctx context.Context, name string) ([]byte, error) {
+	if l.SSM == nil {
+		return nil, errors.New("parameter store configuration sources are not supported")
+	}
+	client, err := l.SSM(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := client.Parameter(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("get parameter %s: %w", name, err)
+	}
+
+	return []byte(value), nil
+}
+
+// decrypt replaces every encrypted value in c with its plaintext.
+func (l *Loader) decrypt(ctx context.Context, c *Config) error {
+	var client Decrypter
+	decryptValues := func(values map[string]Value) error {
+		for name, v := range values {
+			if !encrypted(v) {
+				continue
+			}
+			if client == nil {
+				if l.KMS == nil {
+					return errors.New("encrypted configuration values are not supported")
+				}
+				var err error
+				if client, err = l.KMS(ctx); err != nil {
+					return err
+				}
+			}
+
+			plain, err := decryptValue(ctx, client, v)
+			if err != nil {
+				return fmt.Errorf("decrypt %s: %w", name, err)
+			}
+			values[name] = plain
+		}
+		return nil
+	}
+
+	if err := decryptValues(c.Global); err != nil {
+		return err
+	}
+	for _, values := range c.Commands {
+		if err := decryptValues(values); err != nil {
+			return err
+		}
+	}
+	for _, profiles := range c.Profiles {
+		for _, values := range profiles {
+			if err := decryptValues(values); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// encrypted returns whether the value is an encrypted string, or an array holding one, as decryptValue tells them
+// apart, so that KMS is only needed for values it decrypts.
+func encrypted(v Value) bool {
+	var list []json.RawMessage
+	if err := json.Unmarshal(v, &list); err != nil {
+		list = []json.RawMessage{json.RawMessage(v)}
+	}
+	for _, raw := range list {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil && strings.HasPrefix(s, kmsPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decryptValue decrypts the value if it's an encrypted string, or each encrypted string if it's an array.
+func decryptValue(ctx context.Context, client Decrypter, v Value) (Value, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(v, &list); err != nil {
+		return decryptScalar(ctx, client, json.RawMessage(v))
+	}
+
+	for i, raw := range list {
+		plain, err := decryptScalar(ctx, client, raw)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = json.RawMessage(plain)
+	}
+	data, err := json.Marshal(list)
+
+	return Value(data), err
+}
+
+// decryptScalar decrypts a JSON string holding KMS ciphertext and returns the plaintext as a JSON string. Other
+// values are returned as is.
+func decryptScalar(ctx context.Context, client Decrypter, raw json.RawMessage) (Value, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil || !strings.HasPrefix(s, kmsPrefix) {
+		return Value(raw), nil
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, kmsPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	plain, err := client.Decrypt(ctx, blob)
+	if err != nil {
+		return nil, err
+	}
+	// Decrypted values are secrets, which must stay out of logs wherever they're used.
+	redact.Secret(string(plain))
+	data, err := json.Marshal(string(plain))
+
+	return Value(data), err
+}
+
+// ParameterName returns the Parameter Store parameter name of an ssm:// source, e.g. /ec2-macos-utils/fleet-config for
+// ssm:///ec2-macos-utils/fleet-config.
+func ParameterName(source string) (string, bool) {
+	if !strings.HasPrefix(source, ssmScheme+"://") {
+		return "", false
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", false
+	}
+	// Both ssm:///path/name and ssm://name are accepted, the former naming a hierarchical parameter.
+	name := u.Host + u.Path
+	if u.Host != "" && u.Path != "" {
+		name = "/" + name
+	}
+
+	return name, name != ""
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `{
+  "global": {"verbose": true, "region": "us-east-1"},
+  "commands": {
+    "logs ship": {
+      "region": "us-west-2",
+      "flush-interval": "30s",
+      "file": ["/var/log/a.log", "/var/log/b.log"],
+      "retries": 5,
+      "future-flag": "ignored"
+    }
+  }
+}`
+
+func testFlags() (*pflag.FlagSet, *bool, *string, *time.Duration, *[]string, *int) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	verbose := flags.Bool("verbose", false, "")
+	region := flags.String("region", "", "")
+	interval := flags.Duration("flush-interval", 5*time.Second, "")
+	files := flags.StringArray("file", []string{"/var/log/system.log"}, "")
+	retries := flags.Int("retries", 1, "")
+
+	return flags, verbose, region, interval, files, retries
+}
+
+func TestConfig_Apply(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	assert.NoError(t, err)
+
+	flags, verbose, region, interval, files, retries := testFlags()
+	assert.NoError(t, c.Apply("logs ship", flags))
+
+	assert.True(t, *verbose)
+	assert.Equal(t, "us-west-2", *region, "command values should take precedence over global values")
+	assert.Equal(t, 30*time.Second, *interval)
+	assert.Equal(t, []string{"/var/log/a.log", "/var/log/b.log"}, *files, "arrays should replace the default")
+	assert.Equal(t, 5, *retries)
+}
+
+func TestConfig_ApplyKeepsCommandLine(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	assert.NoError(t, err)
+
+	flags, _, region, _, _, _ := testFlags()
+	assert.NoError(t, flags.Parse([]string{"--region", "eu-west-1"}))
+	assert.NoError(t, c.Apply("logs ship", flags))
+
+	assert.Equal(t, "eu-west-1", *region)
+}
+
+func TestConfig_ApplyInvalidValue(t *testing.T) {
+	c, err := Parse([]byte(`{"commands": {"x": {"retries": "many"}}}`))
+	assert.NoError(t, err)
+
+	flags, _, _, _, _, _ := testFlags()
+	assert.Error(t, c.Apply("x", flags))
+}
+
+func TestConfig_ApplyProfile(t *testing.T) {
+	c, err := Parse([]byte(`{
+  "commands": {"x": {"region": "us-east-1", "retries": 2}},
+  "profiles": {"x": {"fast": {"retries": 0, "flush-interval": "1s"}}}
+}`))
+	assert.NoError(t, err)
+
+	flags, _, region, interval, _, retries := testFlags()
+	assert.NoError(t, flags.Parse([]string{"--flush-interval", "2s"}))
+	assert.NoError(t, c.ApplyProfile("x", "fast", flags))
+	assert.NoError(t, c.Apply("x", flags))
+
+	assert.Equal(t, 0, *retries, "profile values should take precedence over command values")
+	assert.Equal(t, "us-east-1", *region)
+	assert.Equal(t, 2*time.Second, *interval, "command line values should take precedence over profile values")
+
+	assert.ErrorIs(t, c.ApplyProfile("x", "slow", flags), ErrUnknownProfile)
+	assert.ErrorIs(t, c.ApplyProfile("y", "fast", flags), ErrUnknownProfile)
+}
+
+func TestParse_UnknownField(t *testing.T) {
+	_, err := Parse([]byte(`{"flags": {}}`))
+	assert.Error(t, err)
+}
+
+type fakeSSM struct {
+	params map[string]string
+}
+
+var errParameterNotFound = errors.New("parameter not found")
+
+func (f *fakeSSM) Parameter(_ context.Context, name string) (string, error) {
+	v, ok := f.params[name]
+	if !ok {
+		return "", errParameterNotFound
+	}
+
+	return v, nil
+}
+
+func TestLoader_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(testConfig), 0600))
+
+	api := &fakeSSM{params: map[string]string{"/ec2-macos-utils/fleet-config": testConfig}}
+	loader := &Loader{SSM: func(context.Context) (ParameterStore, error) { return api, nil }}
+
+	for _, source := range []string{path, "ssm:///ec2-macos-utils/fleet-config", "ssm://ec2-macos-utils/fleet-config"} {
+		c, err := loader.Load(context.Background(), source)
+		assert.NoError(t, err, source)
+		assert.Contains(t, c.Commands, "logs ship", source)
+	}
+
+	_, err := loader.Load(context.Background(), "ssm:///missing")
+	assert.ErrorIs(t, err, errParameterNotFound)
+
+	_, err = loader.Load(context.Background(), filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+type fakeKMS struct{}
+
+// Decrypt "decrypts" by reversing the ciphertext.
+func (fakeKMS) Decrypt(_ context.Context, ciphertext []byte) ([]byte, error) {
+	plain := make([]byte, len(ciphertext))
+	for i, b := range ciphertext {
+		plain[len(plain)-1-i] = b
+	}
+
+	return plain, nil
+}
+
+func TestLoader_LoadEncrypted(t *testing.T) {
+	encrypted := "kms:" + base64.StdEncoding.EncodeToString([]byte("terces"))
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"global": {"token": "` + encrypted + `"}, "commands": {"x": {"tokens": ["plain", "` + encrypted + `"], "n": 1}}}`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0600))
+
+	c, err := (&Loader{}).Load(context.Background(), path)
+	assert.Error(t, err, "encrypted values require a KMS client")
+	assert.Nil(t, c)
+
+	loader := &Loader{KMS: func(context.Context) (Decrypter, error) { return fakeKMS{}, nil }}
+	c, err = loader.Load(context.Background(), path)
+	assert.NoError(t, err)
+
+	token, err := c.Global["token"].strings()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"secret"}, token)
+
+	tokens, err := c.Commands["x"]["tokens"].strings()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"plain", "secret"}, tokens)
+}
+
+func TestLoader_LoadMentioningKMS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"global": {"note": "keys are kms:aliases", "aliases": ["see kms:"]}}`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0600))
+
+	c, err := (&Loader{}).Load(context.Background(), path)
+	assert.NoError(t, err, "values that only mention the prefix aren't encrypted")
+	note, err := c.Global["note"].strings()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"keys are kms:aliases"}, note)
+}
@@ -3,7 +3,10 @@ package contextual
 import (
 	"context"
 
-	"github.com/aws/ec2-macos-utils/internal/system"
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/output"
+	"github.com/aws/ec2-macos-utils/internal/progress"
+	"github.com/aws/ec2-macos-utils/pkg/system"
 )
 
 type contextKey uint
@@ -11,6 +14,14 @@ type contextKey uint
 const (
 	// productKey is used to access current Product from context.
 	productKey contextKey = iota + 1
+	// progressKey is used to access the progress Reporter from context.
+	progressKey
+	// stylerKey is used to access the output Styler from context.
+	stylerKey
+	// runIDKey is used to access the invocation's run ID from context.
+	runIDKey
+	// awsOptionsKey is used to access the AWS configuration Options from context.
+	awsOptionsKey
 )
 
 // WithProduct extends the context to provide a Product.
@@ -29,3 +40,72 @@ func Product(ctx context.Context) *system.Product {
 
 	return nil
 }
+
+// WithProgress extends the context to provide a progress Reporter.
+func WithProgress(ctx context.Context, reporter progress.Reporter) context.Context {
+	return context.WithValue(ctx, progressKey, reporter)
+}
+
+// Progress fetches the progress Reporter provided in ctx. If none was provided, progress.Discard is returned.
+func Progress(ctx context.Context) progress.Reporter {
+	if val := ctx.Value(progressKey); val != nil {
+		if v, ok := val.(progress.Reporter); ok {
+			return v
+		}
+		panic("incoherent context")
+	}
+
+	return progress.Discard
+}
+
+// WithStyler extends the context to provide the output Styler for command results.
+func WithStyler(ctx context.Context, styler *output.Styler) context.Context {
+	return context.WithValue(ctx, stylerKey, styler)
+}
+
+// Styler fetches the output Styler provided in ctx. If none was provided, output.Plain is returned.
+func Styler(ctx context.Context) *output.Styler {
+	if val := ctx.Value(stylerKey); val != nil {
+		if v, ok := val.(*output.Styler); ok {
+			return v
+		}
+		panic("incoherent context")
+	}
+
+	return output.Plain
+}
+
+// WithRunID extends the context to provide the invocation's run ID.
+func WithRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey, id)
+}
+
+// RunID fetches the invocation's run ID provided in ctx. An empty string is returned if none was provided.
+func RunID(ctx context.Context) string {
+	if val := ctx.Value(runIDKey); val != nil {
+		if v, ok := val.(string); ok {
+			return v
+		}
+		panic("incoherent context")
+	}
+
+	return ""
+}
+
+// WithAWSOptions extends the context to provide the Options used to resolve the AWS configuration.
+func WithAWSOptions(ctx context.Context, opts aws.Options) context.Context {
+	return context.WithValue(ctx, awsOptionsKey, opts)
+}
+
+// AWSOptions fetches the AWS configuration Options provided in ctx. If none were provided, the zero Options are
+// returned.
+func AWSOptions(ctx context.Context) aws.Options {
+	if val := ctx.Value(awsOptionsKey); val != nil {
+		if v, ok := val.(aws.Options); ok {
+			return v
+		}
+		panic("incoherent context")
+	}
+
+	return aws.Options{}
+}
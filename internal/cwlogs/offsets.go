@@ -0,0 +1,79 @@
+package cwlogs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Offsets tracks the read position of tailed files and persists it to disk.
+type Offsets struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]offsetEntry
+}
+
+// offsetEntry is the persisted read position of a single file.
+type offsetEntry struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// LoadOffsets reads previously saved offsets from path. A missing file yields empty offsets.
+func LoadOffsets(path string) (*Offsets, error) {
+	o := &Offsets{path: path, entries: map[string]offsetEntry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read offsets: %w", err)
+	}
+	if err := json.Unmarshal(data, &o.entries); err != nil {
+		return nil, fmt.Errorf("decode offsets: %w", err)
+	}
+
+	return o, nil
+}
+
+// Get returns the inode and offset recorded for the file.
+func (o *Offsets) Get(file string) (uint64, int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e := o.entries[file]
+	return e.Inode, e.Offset
+}
+
+// Set records the inode and offset for the file. Call Save to persist it.
+func (o *Offsets) Set(file string, inode uint64, offset int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries[file] = offsetEntry{Inode: inode, Offset: offset}
+}
+
+// Save atomically writes the offsets to disk.
+func (o *Offsets) Save() error {
+	o.mu.Lock()
+	data, err := json.Marshal(o.entries)
+	o.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encode offsets: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path), 0700); err != nil {
+		return fmt.Errorf("create offsets directory: %w", err)
+	}
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write offsets: %w", err)
+	}
+
+	return os.Rename(tmp, o.path)
+}
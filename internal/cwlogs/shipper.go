@@ -0,0 +1,291 @@
+// Package cwlogs provides the functionality necessary for shipping macOS logs to Amazon CloudWatch Logs.
+package cwlogs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/sirupsen/logrus"
+)
+
+// PutLogEvents limits, see https://docs.aws.amazon.com/AmazonCloudWatchLogs/latest/APIReference/API_PutLogEvents.html
+const (
+	// maxBatchEvents is the maximum number of events in a single request.
+	maxBatchEvents = 10_000
+	// maxBatchBytes is the maximum size of a single request, including per-event overhead.
+	maxBatchBytes = 1_048_576
+	// eventOverhead is the number of bytes added to each event's message size when computing the request size.
+	eventOverhead = 26
+	// maxEventSize is the maximum size of a single event's message.
+	maxEventSize = 256*1024 - eventOverhead
+	// maxBatchSpan is the maximum time between the first and last event of a request.
+	maxBatchSpan = 24 * time.Hour
+)
+
+const (
+	// maxPendingEvents bounds the events buffered per stream while CloudWatch Logs is unreachable. The oldest
+	// events are dropped beyond this limit.
+	maxPendingEvents = 100_000
+
+	// maxPutAttempts is the number of times a batch is sent while recovering from sequence token and missing
+	// stream errors. Transient errors are retried by the SDK itself.
+	maxPutAttempts = 3
+)
+
+// API is the subset of the CloudWatch Logs client used by the Shipper.
+type API interface {
+	PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error)
+	CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error)
+	CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error)
+}
+
+// Shipper batches events by stream and delivers them to a CloudWatch Logs log group.
+type Shipper struct {
+	// Client is the CloudWatch Logs API client.
+	Client API
+	// LogGroup is the destination log group, created if it doesn't exist.
+	LogGroup string
+	// StreamPrefix is prepended to each event's stream name (e.g. the instance ID).
+	StreamPrefix string
+	// FlushInterval is the longest time an event is buffered before being sent.
+	FlushInterval time.Duration
+	// OnFlush is called after a flush delivered events with positions, with the furthest position delivered in each
+	// file, e.g. to save them so that a restart resumes after them. It may be nil.
+	OnFlush func(delivered []Position)
+
+	pending map[string][]pendingEvent
+	tokens  map[string]*string
+	// delivered are the furthest positions delivered in each file since the last OnFlush.
+	delivered map[string]Position
+}
+
+// pendingEvent is a buffered event along with its position.
+type pendingEvent struct {
+	types.InputLogEvent
+	position *Position
+}
+
+// Run buffers events from in and flushes them at every FlushInterval, when a batch is full, and when in is closed
+// or ctx is done. Delivery failures are logged and the events are retried on the next flush.
+func (s *Shipper) Run(ctx context.Context, in <-chan Event) error {
+	s.pending = map[string][]pendingEvent{}
+	s.tokens = map[string]*string{}
+	s.delivered = map[string]Position{}
+
+	interval := s.FlushInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-in:
+			if !ok {
+				return s.flushAll(context.WithoutCancel(ctx))
+			}
+			if s.add(ev) {
+				_ = s.flush(ctx, s.streamName(ev.Stream))
+			}
+		case <-ticker.C:
+			_ = s.flushAll(ctx)
+		case <-ctx.Done():
+			// Deliver what has been buffered before stopping, bounded so shutdown can't hang.
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), interval)
+			defer cancel()
+			_ = s.flushAll(flushCtx)
+			return ctx.Err()
+		}
+	}
+}
+
+// streamName returns the full log stream name for a source's stream suffix.
+func (s *Shipper) streamName(suffix string) string {
+	if s.StreamPrefix == "" {
+		return suffix
+	}
+
+	return s.StreamPrefix + "/" + suffix
+}
+
+// add buffers the event and reports whether its stream has a full batch ready to send.
+func (s *Shipper) add(ev Event) bool {
+	msg := ev.Message
+	if len(msg) > maxEventSize {
+		msg = strings.ToValidUTF8(msg[:maxEventSize], "")
+	}
+
+	stream := s.streamName(ev.Stream)
+	events := append(s.pending[stream], pendingEvent{
+		InputLogEvent: types.InputLogEvent{
+			Message:   aws.String(msg),
+			Timestamp: aws.Int64(ev.Timestamp.UnixMilli()),
+		},
+		position: ev.Position,
+	})
+	if dropped := len(events) - maxPendingEvents; dropped > 0 {
+		logrus.WithFields(logrus.Fields{
+			"stream":  stream,
+			"dropped": dropped,
+		}).Warn("Log buffer full, dropping oldest events")
+		events = events[dropped:]
+	}
+	s.pending[stream] = events
+
+	return len(events) >= maxBatchEvents
+}
+
+// flushAll sends the buffered events of every stream, reports the positions delivered, and returns the last delivery
+// error. Only the positions of events that were delivered are reported, so a stream that failed doesn't advance.
+func (s *Shipper) flushAll(ctx context.Context) error {
+	var lastErr error
+	for stream := range s.pending {
+		if err := s.flush(ctx, stream); err != nil {
+			lastErr = err
+		}
+	}
+	if len(s.delivered) > 0 && s.OnFlush != nil {
+		delivered := make([]Position, 0, len(s.delivered))
+		for _, pos := range s.delivered {
+			delivered = append(delivered, pos)
+		}
+		sort.Slice(delivered, func(i, j int) bool { return delivered[i].File < delivered[j].File })
+		s.OnFlush(delivered)
+	}
+	clear(s.delivered)
+
+	return lastErr
+}
+
+// flush sends the stream's buffered events in as many batches as needed, recording the positions of the events of each
+// batch once it's delivered. Events that could not be delivered stay buffered.
+func (s *Shipper) flush(ctx context.Context, stream string) error {
+	events := s.pending[stream]
+	if len(events) == 0 {
+		return nil
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return *events[i].Timestamp < *events[j].Timestamp
+	})
+
+	for len(events) > 0 {
+		batch := nextBatch(events)
+		if err := s.put(ctx, stream, batch); err != nil {
+			s.pending[stream] = events
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"log_group": s.LogGroup,
+				"stream":    stream,
+				"events":    len(events),
+			}).Error("Failed to deliver log events, will retry")
+			return err
+		}
+		// Events of a file are read in order, and sorting keeps that order, so its last delivered event is the
+		// furthest.
+		for _, ev := range batch {
+			if ev.position != nil {
+				s.delivered[ev.position.File] = *ev.position
+			}
+		}
+		events = events[len(batch):]
+	}
+	delete(s.pending, stream)
+
+	return nil
+}
+
+// nextBatch returns the longest prefix of the chronologically sorted events that fits in a single request.
+func nextBatch(events []pendingEvent) []pendingEvent {
+	var size int
+	first := *events[0].Timestamp
+	for i, ev := range events {
+		size += len(*ev.Message) + eventOverhead
+		if i == maxBatchEvents || size > maxBatchBytes || *ev.Timestamp-first > maxBatchSpan.Milliseconds() {
+			return events[:i]
+		}
+	}
+
+	return events
+}
+
+// put delivers a single batch, creating the log group and stream when they're missing and resynchronizing the
+// stream's sequence token when it's rejected.
+func (s *Shipper) put(ctx context.Context, stream string, pending []pendingEvent) error {
+	batch := make([]types.InputLogEvent, len(pending))
+	for i, ev := range pending {
+		batch[i] = ev.InputLogEvent
+	}
+	for attempt := 1; ; attempt++ {
+		out, err := s.Client.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+			LogGroupName:  aws.String(s.LogGroup),
+			LogStreamName: aws.String(stream),
+			LogEvents:     batch,
+			SequenceToken: s.tokens[stream],
+		})
+		if err == nil {
+			s.tokens[stream] = out.NextSequenceToken
+			if info := out.RejectedLogEventsInfo; info != nil {
+				logrus.WithFields(logrus.Fields{
+					"stream":      stream,
+					"too_old":     aws.ToInt32(info.TooOldLogEventEndIndex),
+					"too_new":     aws.ToInt32(info.TooNewLogEventStartIndex),
+					"expired_end": aws.ToInt32(info.ExpiredLogEventEndIndex),
+				}).Warn("CloudWatch Logs rejected some log events")
+			}
+			return nil
+		}
+
+		var (
+			notFound     *types.ResourceNotFoundException
+			badToken     *types.InvalidSequenceTokenException
+			alreadyTaken *types.DataAlreadyAcceptedException
+		)
+		switch {
+		case errors.As(err, &alreadyTaken):
+			// A previous attempt was delivered but its response was lost.
+			s.tokens[stream] = alreadyTaken.ExpectedSequenceToken
+			return nil
+		case attempt >= maxPutAttempts:
+			return fmt.Errorf("put log events: %w", err)
+		case errors.As(err, &badToken):
+			s.tokens[stream] = badToken.ExpectedSequenceToken
+		case errors.As(err, &notFound):
+			if err := s.ensureStream(ctx, stream); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("put log events: %w", err)
+		}
+	}
+}
+
+// ensureStream creates the log group and stream, tolerating either already existing.
+func (s *Shipper) ensureStream(ctx context.Context, stream string) error {
+	var exists *types.ResourceAlreadyExistsException
+
+	_, err := s.Client.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: aws.String(s.LogGroup),
+	})
+	if err != nil && !errors.As(err, &exists) {
+		return fmt.Errorf("create log group %s: %w", s.LogGroup, err)
+	}
+
+	_, err = s.Client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(s.LogGroup),
+		LogStreamName: aws.String(stream),
+	})
+	if err != nil && !errors.As(err, &exists) {
+		return fmt.Errorf("create log stream %s: %w", stream, err)
+	}
+
+	s.tokens[stream] = nil
+
+	return nil
+}
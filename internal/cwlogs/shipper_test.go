@@ -0,0 +1,142 @@
+package cwlogs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAPI records requests and emulates a single log group's sequence tokens.
+type fakeAPI struct {
+	streams       map[string]bool
+	token         map[string]int
+	puts          [][]types.InputLogEvent
+	createdGroups int
+	// failing are the streams whose events are refused.
+	failing map[string]bool
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{streams: map[string]bool{}, token: map[string]int{}, failing: map[string]bool{}}
+}
+
+func (f *fakeAPI) PutLogEvents(_ context.Context, in *cloudwatchlogs.PutLogEventsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	stream := aws.ToString(in.LogStreamName)
+	if f.failing[stream] {
+		return nil, errors.New("service unavailable")
+	}
+	if !f.streams[stream] {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("stream not found")}
+	}
+	expected := tokenString(f.token[stream])
+	if f.token[stream] > 0 && aws.ToString(in.SequenceToken) != expected {
+		return nil, &types.InvalidSequenceTokenException{ExpectedSequenceToken: aws.String(expected)}
+	}
+
+	f.puts = append(f.puts, in.LogEvents)
+	f.token[stream]++
+
+	return &cloudwatchlogs.PutLogEventsOutput{NextSequenceToken: aws.String(tokenString(f.token[stream]))}, nil
+}
+
+func (f *fakeAPI) CreateLogGroup(context.Context, *cloudwatchlogs.CreateLogGroupInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	f.createdGroups++
+	if f.createdGroups > 1 {
+		return nil, &types.ResourceAlreadyExistsException{Message: aws.String("exists")}
+	}
+
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (f *fakeAPI) CreateLogStream(_ context.Context, in *cloudwatchlogs.CreateLogStreamInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	f.streams[aws.ToString(in.LogStreamName)] = true
+
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+func tokenString(n int) string {
+	return strings.Repeat("t", n)
+}
+
+func TestShipper_CreatesMissingStream(t *testing.T) {
+	api := newFakeAPI()
+	flushes := 0
+	s := &Shipper{Client: api, LogGroup: "group", StreamPrefix: "i-123", OnFlush: func([]Position) { flushes++ }}
+
+	in := make(chan Event, 2)
+	in <- Event{Stream: "system.log", Timestamp: time.Unix(2, 0), Message: "second", Position: &Position{File: "system.log", Offset: 7}}
+	in <- Event{Stream: "system.log", Timestamp: time.Unix(1, 0), Message: "first"}
+	close(in)
+
+	assert.NoError(t, s.Run(context.Background(), in))
+	assert.True(t, api.streams["i-123/system.log"])
+	assert.Len(t, api.puts, 1)
+	assert.Equal(t, "first", aws.ToString(api.puts[0][0].Message), "events should be sent in chronological order")
+	assert.Equal(t, 1, flushes)
+}
+
+func TestShipper_ReportsDeliveredPositions(t *testing.T) {
+	api := newFakeAPI()
+	api.failing["wifi.log"] = true
+	var delivered []Position
+	s := &Shipper{Client: api, LogGroup: "group", OnFlush: func(p []Position) { delivered = append(delivered, p...) }}
+
+	in := make(chan Event, 3)
+	in <- Event{Stream: "system.log", Timestamp: time.Unix(1, 0), Message: "one", Position: &Position{File: "/var/log/system.log", Inode: 1, Offset: 4}}
+	in <- Event{Stream: "system.log", Timestamp: time.Unix(1, 0), Message: "two", Position: &Position{File: "/var/log/system.log", Inode: 1, Offset: 8}}
+	in <- Event{Stream: "wifi.log", Timestamp: time.Unix(1, 0), Message: "lost", Position: &Position{File: "/var/log/wifi.log", Inode: 2, Offset: 5}}
+	close(in)
+
+	assert.Error(t, s.Run(context.Background(), in))
+	assert.Equal(t, []Position{{File: "/var/log/system.log", Inode: 1, Offset: 8}}, delivered,
+		"only the positions of delivered events should be reported")
+}
+
+func TestShipper_RecoversSequenceToken(t *testing.T) {
+	api := newFakeAPI()
+	api.streams["stream"] = true
+	api.token["stream"] = 3
+
+	s := &Shipper{Client: api, LogGroup: "group"}
+	s.pending = map[string][]pendingEvent{}
+	s.tokens = map[string]*string{}
+	s.delivered = map[string]Position{}
+
+	s.add(Event{Stream: "stream", Timestamp: time.Now(), Message: "hello"})
+	assert.NoError(t, s.flush(context.Background(), "stream"))
+	assert.Len(t, api.puts, 1)
+	assert.Equal(t, tokenString(4), aws.ToString(s.tokens["stream"]))
+	assert.Empty(t, s.pending)
+}
+
+func TestNextBatch(t *testing.T) {
+	msg := strings.Repeat("x", 1000)
+	now := time.Now()
+
+	var events []pendingEvent
+	for i := 0; i < 2000; i++ {
+		events = append(events, pendingEvent{InputLogEvent: types.InputLogEvent{Message: aws.String(msg), Timestamp: aws.Int64(now.UnixMilli())}})
+	}
+	assert.Len(t, nextBatch(events), maxBatchBytes/(len(msg)+eventOverhead), "batch should be limited by size")
+
+	spread := []pendingEvent{
+		{InputLogEvent: types.InputLogEvent{Message: aws.String("a"), Timestamp: aws.Int64(now.UnixMilli())}},
+		{InputLogEvent: types.InputLogEvent{Message: aws.String("b"), Timestamp: aws.Int64(now.Add(time.Hour).UnixMilli())}},
+		{InputLogEvent: types.InputLogEvent{Message: aws.String("c"), Timestamp: aws.Int64(now.Add(25 * time.Hour).UnixMilli())}},
+	}
+	assert.Len(t, nextBatch(spread), 2, "batch should not span more than 24 hours")
+}
+
+func TestShipper_AddTruncatesLargeMessages(t *testing.T) {
+	s := &Shipper{pending: map[string][]pendingEvent{}}
+	s.add(Event{Stream: "s", Timestamp: time.Now(), Message: strings.Repeat("x", maxEventSize+10)})
+
+	assert.Len(t, aws.ToString(s.pending["s"][0].Message), maxEventSize)
+}
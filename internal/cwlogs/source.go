@@ -0,0 +1,264 @@
+package cwlogs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Event is a single log line read from a Source.
+type Event struct {
+	// Stream is the name of the log stream suffix the event belongs to.
+	Stream string
+	// Timestamp is when the event occurred.
+	Timestamp time.Time
+	// Message is the log line without its trailing newline.
+	Message string
+	// Position is where the source resumes reading after the event, to be saved once the event is delivered. It's
+	// nil for sources that can't resume, e.g. the unified log.
+	Position *Position
+}
+
+// Position is a read position in a log file.
+type Position struct {
+	File   string
+	Inode  uint64
+	Offset int64
+}
+
+// Source produces log events until its context is canceled.
+type Source interface {
+	// Name identifies the source and is used as its log stream suffix.
+	Name() string
+	// Tail sends events to out until ctx is done or an unrecoverable error occurs.
+	Tail(ctx context.Context, out chan<- Event) error
+}
+
+// FileSource tails a plain text log file, following it across rotation and truncation.
+type FileSource struct {
+	// Path is the location of the log file.
+	Path string
+	// Offsets is where reading starts, so restarts resume where they left off. It may be nil. The source doesn't
+	// update it, since lines that were read may not be delivered yet: the positions of the events are saved in it
+	// once they're delivered.
+	Offsets *Offsets
+	// PollInterval is how often the file is checked for new data.
+	PollInterval time.Duration
+}
+
+// Name returns the base name of the file.
+func (s *FileSource) Name() string {
+	return filepath.Base(s.Path)
+}
+
+// Tail reads new lines appended to the file. Partial lines are held until their newline is written.
+func (s *FileSource) Tail(ctx context.Context, out chan<- Event) error {
+	interval := s.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	var (
+		f       *os.File
+		inode   uint64
+		offset  int64
+		partial string
+	)
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+
+	if s.Offsets != nil {
+		inode, offset = s.Offsets.Get(s.Path)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fi, err := os.Stat(s.Path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// The file may be mid-rotation, try again on the next tick.
+		case err != nil:
+			return fmt.Errorf("stat %s: %w", s.Path, err)
+		default:
+			ino := fileInode(fi)
+			if f == nil || ino != inode || fi.Size() < offset {
+				// First open, rotated, or truncated: start over with the current file.
+				if f != nil {
+					_ = f.Close()
+					f = nil
+				}
+				if ino != inode || fi.Size() < offset {
+					offset, partial = 0, ""
+				}
+				inode = ino
+				if f, err = os.Open(s.Path); err != nil {
+					return fmt.Errorf("open %s: %w", s.Path, err)
+				}
+				if _, err := f.Seek(offset, io.SeekStart); err != nil {
+					return fmt.Errorf("seek %s: %w", s.Path, err)
+				}
+			}
+
+			n, rest, err := s.readLines(ctx, f, Position{File: s.Path, Inode: inode, Offset: offset}, partial, out)
+			offset += n
+			partial = rest
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// readLines sends each complete line available from r, which is read from pos, and returns the bytes consumed and any
+// trailing partial line. Each event's position is after its line, so a restart re-reads a partial line in full.
+func (s *FileSource) readLines(ctx context.Context, r io.Reader, pos Position, partial string, out chan<- Event) (int64, string, error) {
+	reader := bufio.NewReader(r)
+	var consumed int64
+	for {
+		line, err := reader.ReadString('\n')
+		consumed += int64(len(line))
+		if errors.Is(err, io.EOF) {
+			return consumed, partial + line, nil
+		}
+		if err != nil {
+			return consumed, "", fmt.Errorf("read %s: %w", s.Path, err)
+		}
+
+		msg := strings.TrimRight(partial+line, "\r\n")
+		partial = ""
+		if msg == "" {
+			continue
+		}
+		select {
+		case out <- Event{Stream: s.Name(), Timestamp: time.Now(), Message: msg, Position: &Position{
+			File:   pos.File,
+			Inode:  pos.Inode,
+			Offset: pos.Offset + consumed,
+		}}:
+		case <-ctx.Done():
+			return consumed, "", ctx.Err()
+		}
+	}
+}
+
+// fileInode returns the inode number of the file, used to detect rotation.
+func fileInode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+
+	return 0
+}
+
+// logExecutable is the path to the macOS unified logging tool.
+const logExecutable = "/usr/bin/log"
+
+// UnifiedLogSource streams entries matching a predicate from the macOS unified log.
+type UnifiedLogSource struct {
+	// Label names the source and is used as its log stream suffix.
+	Label string
+	// Predicate is the log(1) predicate that selects entries (e.g. 'subsystem == "com.apple.xpc"').
+	Predicate string
+}
+
+// Name returns the source's label.
+func (s *UnifiedLogSource) Name() string {
+	return s.Label
+}
+
+// unifiedLogEntry is the subset of fields emitted by 'log stream --style ndjson'.
+type unifiedLogEntry struct {
+	Timestamp    string `json:"timestamp"`
+	EventMessage string `json:"eventMessage"`
+	Process      string `json:"processImagePath"`
+	MessageType  string `json:"messageType"`
+}
+
+// unifiedLogTimestampFormat is the timestamp layout used by log(1) in ndjson output.
+const unifiedLogTimestampFormat = "2006-01-02 15:04:05.000000-0700"
+
+// Tail runs 'log stream' for the predicate and sends each entry as it arrives.
+func (s *UnifiedLogSource) Tail(ctx context.Context, out chan<- Event) error {
+	cmd := exec.CommandContext(ctx, logExecutable, "stream", "--style", "ndjson", "--predicate", s.Predicate)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("log stream pipe: %w", err)
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		util.LogExec(cmd.Args, time.Since(start), err, "", "")
+		return fmt.Errorf("start log stream: %w", err)
+	}
+	defer func() {
+		err := cmd.Wait()
+		util.LogExec(cmd.Args, time.Since(start), err, "", "")
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	for scanner.Scan() {
+		ev, ok := parseUnifiedLogLine(scanner.Bytes())
+		if !ok {
+			continue
+		}
+		ev.Stream = s.Name()
+		select {
+		case out <- ev:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read log stream: %w", err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return errors.New("log stream exited")
+}
+
+// parseUnifiedLogLine decodes a single ndjson line from log(1). The header line and other non-entry output are
+// reported as not ok.
+func parseUnifiedLogLine(line []byte) (Event, bool) {
+	var entry unifiedLogEntry
+	if err := json.Unmarshal(line, &entry); err != nil || entry.EventMessage == "" {
+		return Event{}, false
+	}
+
+	ts, err := time.Parse(unifiedLogTimestampFormat, entry.Timestamp)
+	if err != nil {
+		logrus.WithField("timestamp", entry.Timestamp).Trace("Unparseable unified log timestamp, using current time")
+		ts = time.Now()
+	}
+
+	msg := entry.EventMessage
+	if entry.Process != "" {
+		msg = fmt.Sprintf("%s[%s] %s", entry.Process, entry.MessageType, msg)
+	}
+
+	return Event{Timestamp: ts, Message: msg}, true
+}
@@ -0,0 +1,98 @@
+package cwlogs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSource_Tail(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+	assert.NoError(t, os.WriteFile(path, []byte("one\ntw"), 0600))
+
+	offsets, err := LoadOffsets(filepath.Join(dir, "offsets.json"))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := &FileSource{Path: path, Offsets: offsets, PollInterval: 10 * time.Millisecond}
+	out := make(chan Event, 10)
+	done := make(chan error)
+	go func() { done <- src.Tail(ctx, out) }()
+
+	assert.Equal(t, "one", receive(t, out).Message)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	_, err = f.WriteString("o\nthree\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	ev := receive(t, out)
+	assert.Equal(t, "two", ev.Message, "partial lines should be joined")
+	assert.Equal(t, "test.log", ev.Stream)
+	assert.Equal(t, "three", receive(t, out).Message)
+
+	// Rotate the file and expect reading to restart from the beginning of the new one.
+	assert.NoError(t, os.Rename(path, path+".1"))
+	assert.NoError(t, os.WriteFile(path, []byte("four\n"), 0600))
+
+	ev = receive(t, out)
+	assert.Equal(t, "four", ev.Message)
+	if assert.NotNil(t, ev.Position) {
+		assert.Equal(t, path, ev.Position.File)
+		assert.Equal(t, int64(len("four\n")), ev.Position.Offset, "the position should be after the line")
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	_, offset := offsets.Get(path)
+	assert.Zero(t, offset, "offsets should only be saved once events are delivered")
+}
+
+func TestOffsets_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "offsets.json")
+
+	offsets, err := LoadOffsets(path)
+	assert.NoError(t, err)
+	offsets.Set("/var/log/system.log", 42, 1024)
+	assert.NoError(t, offsets.Save())
+
+	loaded, err := LoadOffsets(path)
+	assert.NoError(t, err)
+	inode, offset := loaded.Get("/var/log/system.log")
+	assert.Equal(t, uint64(42), inode)
+	assert.Equal(t, int64(1024), offset)
+}
+
+func TestParseUnifiedLogLine(t *testing.T) {
+	line := `{"timestamp":"2024-01-02 03:04:05.678901-0800","eventMessage":"hello","processImagePath":"/usr/libexec/foo","messageType":"Default"}`
+
+	ev, ok := parseUnifiedLogLine([]byte(line))
+	assert.True(t, ok)
+	assert.Equal(t, "/usr/libexec/foo[Default] hello", ev.Message)
+	assert.Equal(t, time.Date(2024, 1, 2, 11, 4, 5, 678901000, time.UTC), ev.Timestamp.UTC())
+
+	_, ok = parseUnifiedLogLine([]byte("Filtering the log data using \"subsystem == foo\""))
+	assert.False(t, ok, "non-JSON output should be skipped")
+}
+
+// receive waits for the next event from out or fails the test.
+func receive(t *testing.T, out <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case ev := <-out:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
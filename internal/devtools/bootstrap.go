@@ -0,0 +1,210 @@
+package devtools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/rosetta"
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// StepStatus is the outcome of a bootstrap step.
+type StepStatus string
+
+const (
+	// StepUnchanged means the step's state was already in place.
+	StepUnchanged StepStatus = "unchanged"
+	// StepChanged means the step changed the system to put its state in place.
+	StepChanged StepStatus = "changed"
+	// StepSkipped means the step doesn't apply to the system.
+	StepSkipped StepStatus = "skipped"
+	// StepFailed means the step couldn't put its state in place.
+	StepFailed StepStatus = "failed"
+)
+
+// Bootstrap is the developer tools state to put in place. Every step is idempotent, so bootstrapping again only
+// changes what's missing.
+type Bootstrap struct {
+	// CLT installs the Command Line Tools.
+	CLT bool
+	// Rosetta installs Rosetta on Apple silicon, accepting its license.
+	Rosetta bool
+	// AcceptXcodeLicense accepts the license of the selected Xcode.
+	AcceptXcodeLicense bool
+	// SimulatorRuntimes are the simulator runtimes to install.
+	SimulatorRuntimes []SimulatorRuntime
+}
+
+// StepResult is the outcome of a bootstrap step.
+type StepResult struct {
+	Step   string     `json:"step"`
+	Status StepStatus `json:"status"`
+	Detail string     `json:"detail,omitempty"`
+	Error  string     `json:"error,omitempty"`
+	// ToolError details the external tool the step failed on, if any.
+	ToolError *util.ExternalToolError `json:"tool_error,omitempty"`
+}
+
+// BootstrapReport lists the outcome of every bootstrap step, in the order they ran.
+type BootstrapReport struct {
+	Steps []StepResult `json:"steps"`
+	// Changed reports whether any step changed the system.
+	Changed bool `json:"changed"`
+}
+
+// Failed returns the number of failed steps.
+func (r BootstrapReport) Failed() int {
+	n := 0
+	for _, step := range r.Steps {
+		if step.Status == StepFailed {
+			n++
+		}
+	}
+
+	return n
+}
+
+// bootstrapStep puts a piece of state in place and returns its status and a detail describing it.
+type bootstrapStep struct {
+	name string
+	run  func(ctx context.Context) (StepStatus, string, error)
+}
+
+// Run runs every step of the bootstrap, even after one fails so that the report is complete, and reports their
+// outcome.
+func (b Bootstrap) Run(ctx context.Context) BootstrapReport {
+	return runSteps(ctx, b.steps())
+}
+
+// steps returns the bootstrap's steps in the order they run.
+func (b Bootstrap) steps() []bootstrapStep {
+	var steps []bootstrapStep
+	if b.CLT {
+		steps = append(steps, bootstrapStep{name: "clt", run: bootstrapCLT})
+	}
+	if b.Rosetta {
+		steps = append(steps, bootstrapStep{name: "rosetta", run: bootstrapRosetta})
+	}
+	if b.AcceptXcodeLicense {
+		steps = append(steps, bootstrapStep{name: "xcode-license", run: bootstrapXcodeLicense})
+	}
+	for _, runtime := range b.SimulatorRuntimes {
+		steps = append(steps, bootstrapStep{
+			name: "simulator-runtime " + runtime.String(),
+			run: func(ctx context.Context) (StepStatus, string, error) {
+				return bootstrapSimulatorRuntime(ctx, runtime)
+			},
+		})
+	}
+
+	return steps
+}
+
+// runSteps runs every step and reports their outcome.
+func runSteps(ctx context.Context, steps []bootstrapStep) BootstrapReport {
+	report := BootstrapReport{Steps: []StepResult{}}
+	for _, step := range steps {
+		result := StepResult{Step: step.name}
+		status, detail, err := step.run(ctx)
+		if err != nil {
+			status = StepFailed
+			result.Error = err.Error()
+			result.ToolError = util.AsExternalToolError(err)
+		}
+		result.Status = status
+		result.Detail = detail
+		report.Steps = append(report.Steps, result)
+		report.Changed = report.Changed || status == StepChanged
+	}
+
+	return report
+}
+
+func bootstrapCLT(ctx context.Context) (StepStatus, string, error) {
+	if version, ok := CLTVersion(ctx); ok {
+		return StepUnchanged, "version " + version, nil
+	}
+	update, err := InstallCLT(ctx)
+	if err != nil {
+		return StepFailed, "", err
+	}
+
+	return StepChanged, "installed " + update.Label, nil
+}
+
+func bootstrapRosetta(ctx context.Context) (StepStatus, string, error) {
+	appleSilicon, err := rosetta.AppleSilicon(ctx)
+	if err != nil {
+		return StepFailed, "", err
+	}
+	if !appleSilicon {
+		return StepSkipped, "not Apple silicon", nil
+	}
+	if rosetta.Installed(ctx) {
+		return StepUnchanged, "", nil
+	}
+	if err := rosetta.Install(ctx, true); err != nil {
+		return StepFailed, "", err
+	}
+
+	return StepChanged, "installed", nil
+}
+
+func bootstrapXcodeLicense(ctx context.Context) (StepStatus, string, error) {
+	accepted, err := XcodeLicenseAccepted(ctx)
+	if err != nil {
+		return StepFailed, "", err
+	}
+	if accepted {
+		return StepUnchanged, "", nil
+	}
+	if err := AcceptXcodeLicense(ctx); err != nil {
+		return StepFailed, "", err
+	}
+
+	return StepChanged, "accepted", nil
+}
+
+func bootstrapSimulatorRuntime(ctx context.Context, runtime SimulatorRuntime) (StepStatus, string, error) {
+	runtimes, err := SimulatorRuntimes(ctx)
+	if err != nil {
+		return StepFailed, "", err
+	}
+	if HasSimulatorRuntime(runtimes, runtime) {
+		return StepUnchanged, "", nil
+	}
+	if err := InstallSimulatorRuntime(ctx, runtime); err != nil {
+		return StepFailed, "", err
+	}
+
+	runtimes, err = SimulatorRuntimes(ctx)
+	if err != nil {
+		return StepFailed, "", err
+	}
+	if !HasSimulatorRuntime(runtimes, runtime) {
+		return StepFailed, "", fmt.Errorf("%s simulator runtime isn't available after installing it", runtime)
+	}
+
+	return StepChanged, "installed", nil
+}
+
+// ParseSimulatorRuntimes parses runtimes such as "iOS 17.5", ignoring duplicates.
+func ParseSimulatorRuntimes(specs []string) ([]SimulatorRuntime, error) {
+	var runtimes []SimulatorRuntime
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		runtime, err := ParseSimulatorRuntime(spec)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(runtime.String())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		runtimes = append(runtimes, runtime)
+	}
+
+	return runtimes, nil
+}
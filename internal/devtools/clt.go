@@ -0,0 +1,213 @@
+// Package devtools provides the functionality necessary for installing developer tools, such as the Xcode Command
+// Line Tools, headlessly.
+package devtools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+const (
+	// CLTPath is where the Command Line Tools are installed.
+	CLTPath = "/Library/Developer/CommandLineTools"
+	// cltPackageID is the receipt of the Command Line Tools package.
+	cltPackageID = "com.apple.pkg.CLTools_Executables"
+	// cltLabelPrefix prefixes the software update labels of the Command Line Tools.
+	cltLabelPrefix = "Command Line Tools for Xcode"
+	// cltTriggerFile makes softwareupdate list the Command Line Tools, which it otherwise only offers through the
+	// interactive installer that xcode-select --install opens.
+	cltTriggerFile = "/tmp/.com.apple.dt.CommandLineTools.installondemand.in-progress"
+)
+
+// ErrCLTNotFound is returned when no Command Line Tools update is available to install.
+var ErrCLTNotFound = errors.New("no Command Line Tools update is available")
+
+// CLTVersion returns the version of the installed Command Line Tools, and false when they aren't installed.
+func CLTVersion(ctx context.Context) (string, bool) {
+	if _, err := os.Stat(CLTPath); err != nil {
+		return "", false
+	}
+	out, err := util.ExecuteCommand(ctx, []string{"pkgutil", "--pkg-info=" + cltPackageID}, "", nil, nil)
+	if err != nil {
+		return "", false
+	}
+	for _, line := range strings.Split(out.Stdout, "\n") {
+		if version, ok := strings.CutPrefix(line, "version:"); ok {
+			return strings.TrimSpace(version), true
+		}
+	}
+
+	return "", true
+}
+
+// InstallCLT installs the latest Command Line Tools and returns the update that was installed. Progress of the
+// download and installation is reported to the context's progress reporter.
+func InstallCLT(ctx context.Context) (softwareupdate.Update, error) {
+	// The trigger file is in /tmp, where anyone could have created it first, e.g. as a symlink to a file root would
+	// then truncate, so whatever is there is removed and the file is created anew without following symlinks.
+	if err := os.Remove(cltTriggerFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return softwareupdate.Update{}, fmt.Errorf("remove existing trigger file: %w", err)
+	}
+	f, err := os.OpenFile(cltTriggerFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0644)
+	if err != nil {
+		return softwareupdate.Update{}, fmt.Errorf("create trigger file: %w", err)
+	}
+	_ = f.Close()
+	// The trigger file changes what softwareupdate lists, so a list cached without it, or with it after it's
+	// removed, is stale.
+	softwareupdate.InvalidateList()
+	defer func() {
+		_ = os.Remove(cltTriggerFile)
+		softwareupdate.InvalidateList()
+	}()
+
+	reporter := contextual.Progress(ctx)
+	reporter.Report("list", 0, "listing software updates")
+	updates, err := softwareupdate.List(ctx)
+	if err != nil {
+		return softwareupdate.Update{}, err
+	}
+	update, ok := latestCLT(updates)
+	if !ok {
+		return softwareupdate.Update{}, ErrCLTNotFound
+	}
+	reporter.Report("list", 100, update.Label)
+
+	logrus.WithField("label", update.Label).Info("Installing Command Line Tools")
+	if err := runInstall(ctx, update.Label); err != nil {
+		return softwareupdate.Update{}, err
+	}
+	if _, ok := CLTVersion(ctx); !ok {
+		return softwareupdate.Update{}, fmt.Errorf("%s was installed but the Command Line Tools are missing", update.Label)
+	}
+
+	return update, nil
+}
+
+// runInstall installs the update, reporting the progress softwareupdate prints.
+func runInstall(ctx context.Context, label string) error {
+	cmd := exec.CommandContext(ctx, "softwareupdate", "--install", "--agree-to-license", "--verbose", label)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	release, err := util.AcquireExec(ctx, cmd.Args)
+	if err != nil {
+		return fmt.Errorf("install %s: %w", label, err)
+	}
+	defer release()
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		util.LogExec(cmd.Args, time.Since(start), err, "", "")
+		return fmt.Errorf("install %s: %w", label, err)
+	}
+
+	reporter := contextual.Progress(ctx)
+	scanner := bufio.NewScanner(stdout)
+	// softwareupdate rewrites its progress in place with carriage returns.
+	scanner.Split(scanLinesOrReturns)
+	for scanner.Scan() {
+		if phase, percent, ok := parseProgress(scanner.Text()); ok {
+			reporter.Report(phase, percent, label)
+		}
+	}
+
+	err = cmd.Wait()
+	util.LogExec(cmd.Args, time.Since(start), err, "", stderr.String())
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("install %s: %w", label, ctx.Err())
+		}
+		return fmt.Errorf("install %s: %s: %w", label, strings.TrimSpace(stderr.String()), util.NewExternalToolError(cmd.Args, err, stderr.String()))
+	}
+
+	return nil
+}
+
+// parseProgress parses a progress line of softwareupdate --verbose, e.g. "Downloading: 45.00%".
+func parseProgress(line string) (phase string, percent float64, ok bool) {
+	key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+	if !found {
+		return "", 0, false
+	}
+	switch key {
+	case "Downloading":
+		phase = "download"
+	case "Installing":
+		phase = "install"
+	default:
+		return "", 0, false
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return phase, percent, true
+}
+
+// scanLinesOrReturns is a bufio.SplitFunc that splits at newlines and carriage returns.
+func scanLinesOrReturns(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, b := range data {
+		if b == '\n' || b == '\r' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// latestCLT returns the Command Line Tools update with the highest version, ignoring betas.
+func latestCLT(updates []softwareupdate.Update) (softwareupdate.Update, bool) {
+	var latest softwareupdate.Update
+	var found bool
+	for _, u := range updates {
+		if !strings.HasPrefix(u.Label, cltLabelPrefix) || strings.Contains(strings.ToLower(u.Label), "beta") {
+			continue
+		}
+		if !found || compareVersions(u.Version, latest.Version) > 0 {
+			latest, found = u, true
+		}
+	}
+
+	return latest, found
+}
+
+// compareVersions compares dotted numeric versions, returning a negative number when a is older than b, zero when
+// they're equal, and a positive number when a is newer.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,61 @@
+package devtools
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+)
+
+func TestLatestCLT(t *testing.T) {
+	updates := []softwareupdate.Update{
+		{Label: "macOS Sonoma 14.4-23E214", Version: "14.4"},
+		{Label: "Command Line Tools for Xcode-15.1", Version: "15.1"},
+		{Label: "Command Line Tools for Xcode-15.3", Version: "15.3"},
+		{Label: "Command Line Tools for Xcode-15.10", Version: "15.10"},
+		{Label: "Command Line Tools beta 2 for Xcode-16.0", Version: "16.0"},
+		{Label: "Command Line Tools for Xcode beta-16.0", Version: "16.0"},
+	}
+	latest, ok := latestCLT(updates)
+	assert.True(t, ok)
+	assert.Equal(t, "Command Line Tools for Xcode-15.10", latest.Label)
+
+	_, ok = latestCLT(updates[:1])
+	assert.False(t, ok)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Positive(t, compareVersions("15.10", "15.3"))
+	assert.Negative(t, compareVersions("14.3.1", "15"))
+	assert.Zero(t, compareVersions("15.0", "15"))
+}
+
+func TestParseProgress(t *testing.T) {
+	phase, percent, ok := parseProgress("Downloading: 45.50%")
+	assert.True(t, ok)
+	assert.Equal(t, "download", phase)
+	assert.Equal(t, 45.5, percent)
+
+	phase, percent, ok = parseProgress("Installing: 100.00%")
+	assert.True(t, ok)
+	assert.Equal(t, "install", phase)
+	assert.Equal(t, 100.0, percent)
+
+	_, _, ok = parseProgress("Software Update Tool")
+	assert.False(t, ok)
+	_, _, ok = parseProgress("Done with Command Line Tools for Xcode-15.3")
+	assert.False(t, ok)
+}
+
+func TestScanLinesOrReturns(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("Downloading: 10%\rDownloading: 20%\nDone"))
+	scanner.Split(scanLinesOrReturns)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Equal(t, []string{"Downloading: 10%", "Downloading: 20%", "Done"}, lines)
+}
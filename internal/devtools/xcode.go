@@ -0,0 +1,138 @@
+package devtools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// ErrNoXcode is returned when Xcode isn't installed or selected, which its license and simulators require.
+var ErrNoXcode = errors.New("Xcode is not installed or selected with xcode-select")
+
+// XcodeLicenseAccepted reports whether the license of the selected Xcode has been accepted.
+func XcodeLicenseAccepted(ctx context.Context) (bool, error) {
+	if err := checkXcode(ctx); err != nil {
+		return false, err
+	}
+	// xcodebuild -license check fails when the license hasn't been accepted.
+	_, err := util.ExecuteCommand(ctx, []string{"xcodebuild", "-license", "check"}, "", nil, nil)
+
+	return err == nil, nil
+}
+
+// AcceptXcodeLicense accepts the license of the selected Xcode and installs the packages it needs at first launch,
+// which would otherwise prompt for them.
+func AcceptXcodeLicense(ctx context.Context) error {
+	for _, args := range [][]string{
+		{"xcodebuild", "-license", "accept"},
+		{"xcodebuild", "-runFirstLaunch"},
+	} {
+		if out, err := util.ExecuteCommand(ctx, args, "", nil, nil); err != nil {
+			return fmt.Errorf("%s: %s: %w", strings.Join(args, " "), strings.TrimSpace(out.Stderr), err)
+		}
+	}
+
+	return nil
+}
+
+// checkXcode returns ErrNoXcode unless the selected developer directory is an Xcode rather than the Command Line
+// Tools, whose xcodebuild can't accept licenses or manage simulators.
+func checkXcode(ctx context.Context) error {
+	out, err := util.ExecuteCommand(ctx, []string{"xcode-select", "-p"}, "", nil, nil)
+	if err != nil || !strings.Contains(out.Stdout, ".app/") {
+		return ErrNoXcode
+	}
+
+	return nil
+}
+
+// SimulatorRuntime is a simulator runtime, e.g. iOS 17.5.
+type SimulatorRuntime struct {
+	Platform string `json:"platform"`
+	Version  string `json:"version"`
+}
+
+func (r SimulatorRuntime) String() string {
+	return r.Platform + " " + r.Version
+}
+
+// simulatorPlatforms are the platforms xcodebuild -downloadPlatform accepts.
+var simulatorPlatforms = []string{"iOS", "watchOS", "tvOS", "visionOS"}
+
+// ParseSimulatorRuntime parses a runtime such as "iOS 17.5".
+func ParseSimulatorRuntime(s string) (SimulatorRuntime, error) {
+	platform, version, ok := strings.Cut(strings.TrimSpace(s), " ")
+	version = strings.TrimSpace(version)
+	if !ok || version == "" {
+		return SimulatorRuntime{}, fmt.Errorf("invalid simulator runtime %q, expected <platform> <version> such as \"iOS 17.5\"", s)
+	}
+	for _, p := range simulatorPlatforms {
+		if strings.EqualFold(platform, p) {
+			return SimulatorRuntime{Platform: p, Version: version}, nil
+		}
+	}
+
+	return SimulatorRuntime{}, fmt.Errorf("unknown simulator platform %q, expected one of %s", platform, strings.Join(simulatorPlatforms, ", "))
+}
+
+// SimulatorRuntimes returns the available simulator runtimes.
+func SimulatorRuntimes(ctx context.Context) ([]SimulatorRuntime, error) {
+	if err := checkXcode(ctx); err != nil {
+		return nil, err
+	}
+	out, err := util.ExecuteCommand(ctx, []string{"xcrun", "simctl", "list", "runtimes", "--json"}, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list simulator runtimes: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseSimulatorRuntimes([]byte(out.Stdout))
+}
+
+// parseSimulatorRuntimes parses the available runtimes from xcrun simctl list runtimes --json.
+func parseSimulatorRuntimes(data []byte) ([]SimulatorRuntime, error) {
+	var list struct {
+		Runtimes []struct {
+			Platform    string `json:"platform"`
+			Version     string `json:"version"`
+			IsAvailable bool   `json:"isAvailable"`
+		} `json:"runtimes"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode simulator runtimes: %w", err)
+	}
+
+	var runtimes []SimulatorRuntime
+	for _, r := range list.Runtimes {
+		if r.IsAvailable {
+			runtimes = append(runtimes, SimulatorRuntime{Platform: r.Platform, Version: r.Version})
+		}
+	}
+
+	return runtimes, nil
+}
+
+// HasSimulatorRuntime reports whether the runtime is among the runtimes. A runtime given as a major and minor
+// version, e.g. 17.5, matches its patch releases, e.g. 17.5.1.
+func HasSimulatorRuntime(runtimes []SimulatorRuntime, want SimulatorRuntime) bool {
+	for _, r := range runtimes {
+		if r.Platform == want.Platform && (r.Version == want.Version || strings.HasPrefix(r.Version, want.Version+".")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InstallSimulatorRuntime downloads and installs the simulator runtime.
+func InstallSimulatorRuntime(ctx context.Context, runtime SimulatorRuntime) error {
+	args := []string{"xcodebuild", "-downloadPlatform", runtime.Platform, "-buildVersion", runtime.Version}
+	if out, err := util.ExecuteCommand(ctx, args, "", nil, nil); err != nil {
+		return fmt.Errorf("install %s simulator runtime: %s: %w", runtime, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
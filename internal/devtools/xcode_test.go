@@ -0,0 +1,77 @@
+package devtools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSimulatorRuntime(t *testing.T) {
+	runtime, err := ParseSimulatorRuntime("ios 17.5")
+	assert.NoError(t, err)
+	assert.Equal(t, SimulatorRuntime{Platform: "iOS", Version: "17.5"}, runtime)
+
+	for _, invalid := range []string{"", "iOS", "17.5", "Android 14"} {
+		_, err := ParseSimulatorRuntime(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseSimulatorRuntimes(t *testing.T) {
+	runtimes, err := ParseSimulatorRuntimes([]string{"iOS 17.5", "watchOS 10.5", "ios 17.5"})
+	assert.NoError(t, err)
+	assert.Equal(t, []SimulatorRuntime{
+		{Platform: "iOS", Version: "17.5"},
+		{Platform: "watchOS", Version: "10.5"},
+	}, runtimes)
+}
+
+func TestParseSimulatorRuntimesList(t *testing.T) {
+	runtimes, err := parseSimulatorRuntimes([]byte(`{
+  "runtimes" : [
+    {
+      "platform" : "iOS",
+      "version" : "17.5",
+      "isAvailable" : true,
+      "name" : "iOS 17.5"
+    },
+    {
+      "platform" : "tvOS",
+      "version" : "17.2",
+      "isAvailable" : false,
+      "name" : "tvOS 17.2"
+    }
+  ]
+}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []SimulatorRuntime{{Platform: "iOS", Version: "17.5"}}, runtimes)
+
+	_, err = parseSimulatorRuntimes([]byte("xcrun: error: unable to find utility \"simctl\""))
+	assert.Error(t, err)
+}
+
+func TestHasSimulatorRuntime(t *testing.T) {
+	runtimes := []SimulatorRuntime{{Platform: "iOS", Version: "17.5.1"}}
+	assert.True(t, HasSimulatorRuntime(runtimes, SimulatorRuntime{Platform: "iOS", Version: "17.5"}))
+	assert.True(t, HasSimulatorRuntime(runtimes, SimulatorRuntime{Platform: "iOS", Version: "17.5.1"}))
+	assert.False(t, HasSimulatorRuntime(runtimes, SimulatorRuntime{Platform: "iOS", Version: "17.50"}))
+	assert.False(t, HasSimulatorRuntime(runtimes, SimulatorRuntime{Platform: "tvOS", Version: "17.5"}))
+}
+
+func TestRunSteps(t *testing.T) {
+	report := runSteps(context.Background(), []bootstrapStep{
+		{name: "present", run: func(context.Context) (StepStatus, string, error) { return StepUnchanged, "", nil }},
+		{name: "broken", run: func(context.Context) (StepStatus, string, error) { return StepFailed, "", errors.New("boom") }},
+		{name: "missing", run: func(context.Context) (StepStatus, string, error) { return StepChanged, "installed", nil }},
+	})
+
+	assert.Equal(t, []StepResult{
+		{Step: "present", Status: StepUnchanged},
+		{Step: "broken", Status: StepFailed, Error: "boom"},
+		{Step: "missing", Status: StepChanged, Detail: "installed"},
+	}, report.Steps)
+	assert.True(t, report.Changed)
+	assert.Equal(t, 1, report.Failed())
+}
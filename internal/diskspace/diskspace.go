@@ -0,0 +1,155 @@
+// Package diskspace provides the functionality necessary for checking the free space of volumes against thresholds.
+package diskspace
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/docker/go-units"
+)
+
+// Threshold is the least free space a volume should have, either a percentage of its capacity or a number of bytes.
+type Threshold struct {
+	Percent float64
+	Bytes   int64
+}
+
+// ParseThreshold parses a threshold given as a percentage, e.g. 10%, or a size, e.g. 20GB.
+func ParseThreshold(s string) (Threshold, error) {
+	s = strings.TrimSpace(s)
+	if percent, ok := strings.CutSuffix(s, "%"); ok {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || p < 0 || p > 100 {
+			return Threshold{}, fmt.Errorf("invalid percentage %q", s)
+		}
+		return Threshold{Percent: p}, nil
+	}
+	bytes, err := units.FromHumanSize(s)
+	if err != nil || bytes < 0 {
+		return Threshold{}, fmt.Errorf("invalid threshold %q, use a percentage (e.g. 10%%) or a size (e.g. 20GB)", s)
+	}
+
+	return Threshold{Bytes: bytes}, nil
+}
+
+// String formats the threshold as it's parsed.
+func (t Threshold) String() string {
+	if t.Bytes > 0 {
+		return units.HumanSize(float64(t.Bytes))
+	}
+
+	return strconv.FormatFloat(t.Percent, 'f', -1, 64) + "%"
+}
+
+// Met reports whether free bytes of a volume with the capacity meet the threshold.
+func (t Threshold) Met(free, capacity uint64) bool {
+	if t.Bytes > 0 {
+		return free >= uint64(t.Bytes)
+	}
+
+	return float64(free)/float64(capacity)*100 >= t.Percent
+}
+
+// Spec is a volume to check, given by the path of any file on it, and its threshold.
+type Spec struct {
+	Path    string
+	MinFree Threshold
+}
+
+// ParseSpec parses a volume to check given as a path with an optional threshold after an equal sign, e.g.
+// /Volumes/Data=20GB. The default threshold applies when there's none.
+func ParseSpec(s string, defaultMinFree Threshold) (Spec, error) {
+	path, threshold, ok := strings.Cut(s, "=")
+	if path == "" {
+		return Spec{}, fmt.Errorf("invalid volume %q, no path", s)
+	}
+	spec := Spec{Path: path, MinFree: defaultMinFree}
+	if ok {
+		var err error
+		if spec.MinFree, err = ParseThreshold(threshold); err != nil {
+			return Spec{}, fmt.Errorf("volume %s: %w", path, err)
+		}
+	}
+
+	return spec, nil
+}
+
+// Usage returns the capacity of the volume containing path and its space available to unprivileged users, which is
+// what's left for most processes writing to it.
+func Usage(path string) (capacity, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, fmt.Errorf("statfs %s: %w", path, err)
+	}
+	blockSize := uint64(st.Bsize)
+	capacity = uint64(st.Blocks) * blockSize
+	if capacity == 0 {
+		return 0, 0, fmt.Errorf("volume at %s reports no capacity", path)
+	}
+
+	return capacity, uint64(st.Bavail) * blockSize, nil
+}
+
+// Volume is the result of checking a volume.
+type Volume struct {
+	Path          string  `json:"path"`
+	CapacityBytes uint64  `json:"capacity_bytes"`
+	FreeBytes     uint64  `json:"free_bytes"`
+	FreePercent   float64 `json:"free_percent"`
+	MinFree       string  `json:"min_free"`
+	OK            bool    `json:"ok"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// Result is the result of checking volumes.
+type Result struct {
+	OK      bool     `json:"ok"`
+	Volumes []Volume `json:"volumes"`
+}
+
+// Check checks the free space of each volume against its threshold. Volumes whose usage can't be read fail.
+func Check(specs []Spec) Result {
+	return check(specs, Usage)
+}
+
+// check checks the volumes with their usage read by usage.
+func check(specs []Spec, usage func(path string) (capacity, free uint64, err error)) Result {
+	result := Result{OK: true}
+	for _, spec := range specs {
+		v := Volume{Path: spec.Path, MinFree: spec.MinFree.String()}
+		capacity, free, err := usage(spec.Path)
+		if err != nil {
+			v.Error = err.Error()
+		} else {
+			v.CapacityBytes, v.FreeBytes = capacity, free
+			v.FreePercent = float64(free) / float64(capacity) * 100
+			v.OK = spec.MinFree.Met(free, capacity)
+		}
+		result.OK = result.OK && v.OK
+		result.Volumes = append(result.Volumes, v)
+	}
+
+	return result
+}
+
+// Err returns an error describing the volumes that failed, or nil if every volume passed.
+func (r Result) Err() error {
+	var failed []string
+	for _, v := range r.Volumes {
+		switch {
+		case v.Error != "":
+			failed = append(failed, v.Error)
+		case !v.OK:
+			failed = append(failed, fmt.Sprintf("%s has %s (%.1f%%) free, less than %s",
+				v.Path, units.HumanSize(float64(v.FreeBytes)), v.FreePercent, v.MinFree))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(failed, "; "))
+}
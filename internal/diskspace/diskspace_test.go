@@ -0,0 +1,82 @@
+package diskspace
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseThreshold(t *testing.T) {
+	for s, expected := range map[string]Threshold{
+		"10%":   {Percent: 10},
+		"2.5%":  {Percent: 2.5},
+		"20GB":  {Bytes: 20_000_000_000},
+		"500MB": {Bytes: 500_000_000},
+	} {
+		threshold, err := ParseThreshold(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, threshold, s)
+	}
+	for _, s := range []string{"", "110%", "-1%", "lots"} {
+		_, err := ParseThreshold(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	defaultMinFree := Threshold{Percent: 10}
+
+	spec, err := ParseSpec("/", defaultMinFree)
+	require.NoError(t, err)
+	assert.Equal(t, Spec{Path: "/", MinFree: defaultMinFree}, spec)
+
+	spec, err = ParseSpec("/Volumes/Data=20GB", defaultMinFree)
+	require.NoError(t, err)
+	assert.Equal(t, Spec{Path: "/Volumes/Data", MinFree: Threshold{Bytes: 20_000_000_000}}, spec)
+
+	_, err = ParseSpec("=10%", defaultMinFree)
+	assert.Error(t, err)
+	_, err = ParseSpec("/=ten", defaultMinFree)
+	assert.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	usage := func(path string) (uint64, uint64, error) {
+		switch path {
+		case "/":
+			return 1000, 200, nil
+		case "/Volumes/Data":
+			return 1000, 50, nil
+		default:
+			return 0, 0, errors.New("statfs " + path + ": no such file or directory")
+		}
+	}
+
+	result := check([]Spec{
+		{Path: "/", MinFree: Threshold{Percent: 10}},
+		{Path: "/Volumes/Data", MinFree: Threshold{Bytes: 100}},
+	}, usage)
+	assert.False(t, result.OK)
+	require.Len(t, result.Volumes, 2)
+	assert.Equal(t, Volume{Path: "/", CapacityBytes: 1000, FreeBytes: 200, FreePercent: 20, MinFree: "10%", OK: true}, result.Volumes[0])
+	assert.False(t, result.Volumes[1].OK, "each volume should be checked against its own threshold")
+	assert.EqualError(t, result.Err(), "/Volumes/Data has 50B (5.0%) free, less than 100B")
+
+	result = check([]Spec{{Path: "/missing", MinFree: Threshold{Percent: 10}}}, usage)
+	assert.False(t, result.OK, "volumes whose usage can't be read should fail")
+	assert.EqualError(t, result.Err(), "statfs /missing: no such file or directory")
+
+	result = check([]Spec{{Path: "/", MinFree: Threshold{Percent: 20}}}, usage)
+	assert.True(t, result.OK)
+	assert.NoError(t, result.Err())
+}
+
+func TestUsage(t *testing.T) {
+	capacity, free, err := Usage(os.TempDir())
+	require.NoError(t, err)
+	assert.Positive(t, capacity)
+	assert.LessOrEqual(t, free, capacity)
+}
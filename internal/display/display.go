@@ -0,0 +1,286 @@
+// Package display reads and sets the resolution of displays, including the virtual displays of headless instances,
+// with displayplacer, which makes the CoreGraphics display configuration calls.
+package display
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// displayplacerPaths are where displayplacer is looked for when it's not on the PATH, e.g. when running from launchd.
+var displayplacerPaths = []string{
+	"/opt/homebrew/bin/displayplacer",
+	"/usr/local/bin/displayplacer",
+}
+
+// ErrNoDisplayplacer is returned when displayplacer isn't installed.
+var ErrNoDisplayplacer = errors.New("displayplacer not found")
+
+// ErrNoSession is returned when nobody is logged in on the console, so there's no window server session whose
+// displays can be configured.
+var ErrNoSession = errors.New("nobody is logged in on the console")
+
+// Resolution is a display resolution in pixels.
+type Resolution struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// resolutionExp matches resolutions such as 1920x1080.
+var resolutionExp = regexp.MustCompile(`^(\d+)x(\d+)$`)
+
+// ParseResolution parses a resolution such as 1920x1080.
+func ParseResolution(s string) (Resolution, error) {
+	m := resolutionExp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Resolution{}, fmt.Errorf("invalid resolution %q, expected <width>x<height>", s)
+	}
+	width, _ := strconv.Atoi(m[1])
+	height, _ := strconv.Atoi(m[2])
+	if width == 0 || height == 0 {
+		return Resolution{}, fmt.Errorf("invalid resolution %q, width and height must be positive", s)
+	}
+
+	return Resolution{Width: width, Height: height}, nil
+}
+
+func (r Resolution) String() string {
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+// Mode is a display mode supported by a screen.
+type Mode struct {
+	// Number identifies the mode for the screen.
+	Number     int
+	Resolution Resolution
+	Hz         int
+	// Scaling reports whether the mode is a HiDPI mode, which renders at twice the resolution.
+	Scaling bool
+	// Current reports whether the screen is in this mode.
+	Current bool
+}
+
+// Screen is a display.
+type Screen struct {
+	// ID is the persistent screen ID, which is stable across reboots.
+	ID         string
+	Type       string
+	Resolution Resolution
+	// Main reports whether the screen is the main display, which has the menu bar.
+	Main  bool
+	Modes []Mode
+}
+
+// FindMode returns the screen's mode with the resolution, preferring modes without scaling so that screenshots have
+// the resolution's size, then the highest refresh rate.
+func (s Screen) FindMode(res Resolution) (Mode, bool) {
+	var found Mode
+	ok := false
+	for _, mode := range s.Modes {
+		if mode.Resolution != res {
+			continue
+		}
+		if !ok || (found.Scaling && !mode.Scaling) || (found.Scaling == mode.Scaling && mode.Hz > found.Hz) {
+			found, ok = mode, true
+		}
+	}
+
+	return found, ok
+}
+
+// Resolutions returns the distinct resolutions of the screen's modes, in the order listed.
+func (s Screen) Resolutions() []Resolution {
+	var resolutions []Resolution
+	seen := map[Resolution]bool{}
+	for _, mode := range s.Modes {
+		if !seen[mode.Resolution] {
+			seen[mode.Resolution] = true
+			resolutions = append(resolutions, mode.Resolution)
+		}
+	}
+
+	return resolutions
+}
+
+// Screens returns the screens of the console user's window server session.
+func Screens(ctx context.Context) ([]Screen, error) {
+	out, err := displayplacer(ctx, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseList(out), nil
+}
+
+// SetMode switches the screen to the mode. The configuration is saved by the window server and kept across reboots.
+func SetMode(ctx context.Context, screen Screen, mode Mode) error {
+	_, err := displayplacer(ctx, setModeArg(screen, mode))
+
+	return err
+}
+
+// setModeArg returns the displayplacer screen configuration which switches the screen to the mode.
+func setModeArg(screen Screen, mode Mode) string {
+	return fmt.Sprintf("id:%s mode:%d", screen.ID, mode.Number)
+}
+
+// modeExp matches the mode lines of displayplacer list, e.g. "mode 12: res:1920x1080 hz:60 scaling:on <-- current mode".
+var modeExp = regexp.MustCompile(`^mode (\d+): res:(\d+)x(\d+)(?: hz:(\d+))?`)
+
+// parseList parses the screens from the output of displayplacer list.
+func parseList(output string) []Screen {
+	var screens []Screen
+	var screen *Screen
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if key, value, ok := strings.Cut(line, ": "); ok && !strings.HasPrefix(line, "mode ") {
+			switch key {
+			case "Persistent screen id":
+				screens = append(screens, Screen{ID: value})
+				screen = &screens[len(screens)-1]
+			case "Type":
+				if screen != nil {
+					screen.Type = value
+				}
+			case "Resolution":
+				if screen != nil {
+					screen.Resolution, _ = ParseResolution(value)
+				}
+			case "Origin":
+				if screen != nil {
+					screen.Main = strings.Contains(value, "main display")
+				}
+			}
+			continue
+		}
+
+		m := modeExp.FindStringSubmatch(line)
+		if m == nil || screen == nil {
+			continue
+		}
+		mode := Mode{
+			Scaling: strings.Contains(line, "scaling:on"),
+			Current: strings.Contains(line, "current mode"),
+		}
+		mode.Number, _ = strconv.Atoi(m[1])
+		mode.Resolution.Width, _ = strconv.Atoi(m[2])
+		mode.Resolution.Height, _ = strconv.Atoi(m[3])
+		mode.Hz, _ = strconv.Atoi(m[4])
+		screen.Modes = append(screen.Modes, mode)
+	}
+
+	return screens
+}
+
+// Main returns the main screen, or the first when none is marked as main.
+func Main(screens []Screen) (Screen, bool) {
+	for _, s := range screens {
+		if s.Main {
+			return s, true
+		}
+	}
+	if len(screens) > 0 {
+		return screens[0], true
+	}
+
+	return Screen{}, false
+}
+
+// displayplacer runs displayplacer with the arguments in the console user's session and returns its output. When
+// running as root, e.g. over SSH or from a daemon, it's run as the console user in their bootstrap namespace since the
+// window server only accepts display configuration from the session's processes. displayplacer is commonly installed
+// by Homebrew, owned by the user who installed it, so it's never run as root.
+func displayplacer(ctx context.Context, args ...string) (string, error) {
+	root := os.Geteuid() == 0
+	path, err := findDisplayplacer(!root)
+	if err != nil {
+		return "", err
+	}
+
+	argv := append([]string{path}, args...)
+	if root {
+		uid, err := consoleUID(ctx)
+		if err != nil {
+			return "", err
+		}
+		if err := checkExecutable(path, uid); err != nil {
+			return "", err
+		}
+		argv = append([]string{"launchctl", "asuser", uid, "sudo", "-u", "#" + uid}, argv...)
+	}
+	out, err := util.ExecuteCommand(ctx, argv, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("displayplacer %s: %s: %w", args[0], strings.TrimSpace(out.Stderr+" "+out.Stdout), err)
+	}
+
+	return out.Stdout, nil
+}
+
+// findDisplayplacer returns the path of displayplacer, looking for it on the PATH first with usePath. Root doesn't use
+// the PATH, which may include directories that other users can write to.
+func findDisplayplacer(usePath bool) (string, error) {
+	if usePath {
+		if path, err := exec.LookPath("displayplacer"); err == nil {
+			return path, nil
+		}
+	}
+	for _, path := range displayplacerPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", ErrNoDisplayplacer
+}
+
+// checkExecutable checks that the executable at path, and the file it links to, are owned by root or by the user with
+// the ID it's run as, and that nobody else can write to them, so that running it doesn't let another user run code as
+// that user.
+func checkExecutable(path, uid string) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{path, resolved} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("cannot read the owner of %s", p)
+		}
+		if owner := strconv.FormatUint(uint64(st.Uid), 10); owner != "0" && owner != uid {
+			return fmt.Errorf("%s is owned by uid %s, neither root nor the console user", p, owner)
+		}
+		if fi.Mode().Perm()&0022 != 0 {
+			return fmt.Errorf("%s can be written by other users", p)
+		}
+	}
+
+	return nil
+}
+
+// consoleUID returns the user ID of the user logged in on the console.
+func consoleUID(ctx context.Context) (string, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"stat", "-f", "%u", "/dev/console"}, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("find console user: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	uid := strings.TrimSpace(out.Stdout)
+	if uid == "0" || uid == "" {
+		return "", ErrNoSession
+	}
+
+	return uid, nil
+}
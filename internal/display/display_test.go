@@ -0,0 +1,116 @@
+package display
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testList = `Persistent screen id: 37D8832A-2D66-02CA-B9F7-8F30A301B230
+Contextual screen id: 1
+Serial screen id: s4251086178
+Type: 24 inch external screen
+Resolution: 1024x768
+Hertz: 60
+Color Depth: 8
+Scaling: off
+Origin: (0,0) - main display
+Rotation: 0
+Enabled: true
+Resolutions for rotation 0:
+  mode 0: res:1024x768 hz:60 color_depth:8 <-- current mode
+  mode 1: res:1920x1080 hz:30 color_depth:8
+  mode 2: res:1920x1080 hz:60 color_depth:8 scaling:on
+  mode 3: res:1920x1080 hz:60 color_depth:8
+  mode 4: res:2560x1440 hz:60 color_depth:8
+
+Persistent screen id: 4A8E5F3B-1C2D-4E5F-8A9B-0C1D2E3F4A5B
+Type: virtual screen
+Resolution: 1280x720
+Origin: (1024,0)
+Resolutions for rotation 0:
+  mode 0: res:1280x720 hz:60 color_depth:8 <-- current mode
+
+Execute the command below to set your screens to the current arrangement. If screen ids are switching, please run ` + "`displayplacer --help`" + ` for info on using contextual or serial ids instead of persistent ids.
+
+displayplacer "id:37D8832A-2D66-02CA-B9F7-8F30A301B230 res:1024x768 hz:60 color_depth:8 enabled:true scaling:off origin:(0,0) degree:0"
+`
+
+func TestParseResolution(t *testing.T) {
+	res, err := ParseResolution("1920x1080")
+	require.NoError(t, err)
+	assert.Equal(t, Resolution{Width: 1920, Height: 1080}, res)
+	assert.Equal(t, "1920x1080", res.String())
+
+	for _, invalid := range []string{"", "1920", "1920x", "x1080", "1920*1080", "0x1080", "-1x1080"} {
+		_, err := ParseResolution(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	screens := parseList(testList)
+	require.Len(t, screens, 2)
+
+	main := screens[0]
+	assert.Equal(t, "37D8832A-2D66-02CA-B9F7-8F30A301B230", main.ID)
+	assert.Equal(t, "24 inch external screen", main.Type)
+	assert.Equal(t, Resolution{1024, 768}, main.Resolution)
+	assert.True(t, main.Main)
+	require.Len(t, main.Modes, 5)
+	assert.Equal(t, Mode{Number: 0, Resolution: Resolution{1024, 768}, Hz: 60, Current: true}, main.Modes[0])
+	assert.Equal(t, Mode{Number: 2, Resolution: Resolution{1920, 1080}, Hz: 60, Scaling: true}, main.Modes[2])
+
+	virtual := screens[1]
+	assert.Equal(t, "virtual screen", virtual.Type)
+	assert.False(t, virtual.Main)
+	assert.Len(t, virtual.Modes, 1)
+
+	found, ok := Main(screens)
+	assert.True(t, ok)
+	assert.Equal(t, main.ID, found.ID)
+}
+
+func TestScreen_FindMode(t *testing.T) {
+	screen := parseList(testList)[0]
+
+	mode, ok := screen.FindMode(Resolution{1920, 1080})
+	assert.True(t, ok)
+	assert.Equal(t, 3, mode.Number, "unscaled modes with the highest refresh rate should be preferred")
+
+	_, ok = screen.FindMode(Resolution{3840, 2160})
+	assert.False(t, ok)
+
+	assert.Equal(t, []Resolution{{1024, 768}, {1920, 1080}, {2560, 1440}}, screen.Resolutions())
+}
+
+func TestSetModeArg(t *testing.T) {
+	assert.Equal(t, "id:ABC mode:3", setModeArg(Screen{ID: "ABC"}, Mode{Number: 3}))
+}
+
+func TestMainScreen_None(t *testing.T) {
+	_, ok := Main(nil)
+	assert.False(t, ok)
+}
+
+func TestCheckExecutable(t *testing.T) {
+	uid := strconv.Itoa(os.Getuid())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "displayplacer")
+	require.NoError(t, os.WriteFile(path, nil, 0755))
+	assert.NoError(t, checkExecutable(path, uid))
+
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(path, link))
+	assert.NoError(t, checkExecutable(link, uid))
+
+	if uid != "0" {
+		assert.Error(t, checkExecutable(path, uid+"1"), "executables of other users shouldn't be run")
+	}
+	require.NoError(t, os.Chmod(path, 0775))
+	assert.Error(t, checkExecutable(link, uid), "executables writable by others shouldn't be run")
+}
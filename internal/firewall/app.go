@@ -0,0 +1,95 @@
+// Package firewall provides the functionality necessary for configuring the macOS application firewall and packet
+// filter (pf) rules.
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// socketfilterfwPath is the application firewall's configuration tool.
+const socketfilterfwPath = "/usr/libexec/ApplicationFirewall/socketfilterfw"
+
+// AppOptions configures the application firewall.
+type AppOptions struct {
+	// Stealth ignores probes such as ICMP pings instead of responding to them.
+	Stealth bool
+	// BlockAll blocks every incoming connection except those required by basic services.
+	BlockAll bool
+}
+
+// AppState is the state of the application firewall.
+type AppState struct {
+	Enabled  bool `json:"enabled"`
+	Stealth  bool `json:"stealth"`
+	BlockAll bool `json:"block_all"`
+}
+
+// EnableApp enables the application firewall with the options.
+func EnableApp(ctx context.Context, opts AppOptions) error {
+	return socketfilterfw(ctx, enableAppArgs(opts)...)
+}
+
+// enableAppArgs returns the socketfilterfw arguments that enable the firewall with the options.
+func enableAppArgs(opts AppOptions) []string {
+	return []string{
+		"--setglobalstate", "on",
+		"--setstealthmode", onOff(opts.Stealth),
+		"--setblockall", onOff(opts.BlockAll),
+	}
+}
+
+// DisableApp disables the application firewall.
+func DisableApp(ctx context.Context) error {
+	return socketfilterfw(ctx, "--setglobalstate", "off")
+}
+
+// GetAppState returns the state of the application firewall.
+func GetAppState(ctx context.Context) (AppState, error) {
+	var state AppState
+	for _, get := range []struct {
+		flag  string
+		value *bool
+	}{
+		{"--getglobalstate", &state.Enabled},
+		{"--getstealthmode", &state.Stealth},
+		{"--getblockall", &state.BlockAll},
+	} {
+		out, err := util.ExecuteCommand(ctx, []string{socketfilterfwPath, get.flag}, "", nil, nil)
+		if err != nil {
+			return AppState{}, fmt.Errorf("socketfilterfw %s: %s: %w", get.flag, strings.TrimSpace(out.Stderr), err)
+		}
+		*get.value = parseAppState(out.Stdout)
+	}
+
+	return state, nil
+}
+
+// parseAppState reports whether socketfilterfw's output, e.g. "Firewall is enabled. (State = 1)" or "Firewall
+// stealth mode is on", describes an enabled setting.
+func parseAppState(output string) bool {
+	output = strings.ToLower(output)
+	return strings.Contains(output, "enabled") || strings.Contains(output, " is on")
+}
+
+// socketfilterfw runs socketfilterfw with the arguments.
+func socketfilterfw(ctx context.Context, args ...string) error {
+	out, err := util.ExecuteCommand(ctx, append([]string{socketfilterfwPath}, args...), "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("socketfilterfw %s: %s: %w", strings.Join(args, " "), strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// onOff formats the setting as socketfilterfw expects.
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+
+	return "off"
+}
@@ -0,0 +1,56 @@
+package firewall
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnableAppArgs(t *testing.T) {
+	assert.Equal(t, []string{"--setglobalstate", "on", "--setstealthmode", "on", "--setblockall", "off"}, enableAppArgs(AppOptions{Stealth: true}))
+}
+
+func TestParseAppState(t *testing.T) {
+	assert.True(t, parseAppState("Firewall is enabled. (State = 1)\n"))
+	assert.False(t, parseAppState("Firewall is disabled. (State = 0)\n"))
+	assert.True(t, parseAppState("Firewall stealth mode is on\n"))
+	assert.False(t, parseAppState("Firewall stealth mode is off\n"))
+}
+
+func TestConfig_Render(t *testing.T) {
+	c := &Config{Rules: []Rule{
+		{Service: "ssh", Allow: []string{"10.0.0.0/8", "192.0.2.10"}},
+		{Port: 8080, Allow: []string{"2001:db8::/32"}},
+	}}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, `# Managed by ec2-macos-utils, changes are overwritten.
+pass in quick proto tcp from { 10.0.0.0/8, 192.0.2.10 } to any port 22
+block return in quick on ! lo0 proto tcp from any to any port 22
+pass in quick proto tcp from { 2001:db8::/32 } to any port 8080
+block return in quick on ! lo0 proto tcp from any to any port 8080
+`, c.Render())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for name, r := range map[string]Rule{
+		"no sources":       {Service: "ssh"},
+		"invalid source":   {Service: "ssh", Allow: []string{"10.0.0.0/33"}},
+		"unknown service":  {Service: "rdp", Allow: []string{"10.0.0.0/8"}},
+		"service and port": {Service: "ssh", Port: 2222, Allow: []string{"10.0.0.0/8"}},
+		"invalid port":     {Port: 70000, Allow: []string{"10.0.0.0/8"}},
+		"invalid protocol": {Port: 53, Protocol: "icmp", Allow: []string{"10.0.0.0/8"}},
+	} {
+		assert.Error(t, (&Config{Rules: []Rule{r}}).Validate(), name)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "firewall.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"service": "ard", "allow": ["10.0.0.0/8"]}]}`), 0600))
+
+	c, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Contains(t, c.Render(), "pass in quick proto udp from { 10.0.0.0/8 } to any port 3283\n")
+}
@@ -0,0 +1,200 @@
+package firewall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Anchor is the pf anchor the rules are loaded in. The default /etc/pf.conf evaluates every anchor under com.apple,
+// so rules loaded in it take effect without changing pf.conf, which macOS updates may overwrite.
+const Anchor = "com.apple/ec2-macos-utils"
+
+// services are the ports of the services that rules may name.
+var services = map[string]struct {
+	protocols []string
+	port      int
+}{
+	"ssh": {[]string{"tcp"}, 22},
+	"vnc": {[]string{"tcp"}, 5900},
+	"ard": {[]string{"tcp", "udp"}, 3283},
+}
+
+// Config is the declarative pf configuration: for each rule's port, only the allowed sources may connect.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule restricts incoming connections to a port.
+type Rule struct {
+	// Service names a well-known service: ssh, vnc (Screen Sharing), or ard (Apple Remote Desktop). It's an
+	// alternative to Port and Protocol.
+	Service string `json:"service,omitempty"`
+	// Port is the destination port.
+	Port int `json:"port,omitempty"`
+	// Protocol is tcp or udp. Empty is tcp.
+	Protocol string `json:"protocol,omitempty"`
+	// Allow are the addresses or CIDR blocks allowed to connect.
+	Allow []string `json:"allow"`
+}
+
+// LoadConfig reads the configuration at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read firewall configuration: %w", err)
+	}
+
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("decode firewall configuration %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// Validate checks the configuration before any rule is loaded.
+func (c *Config) Validate() error {
+	for i, r := range c.Rules {
+		if _, _, err := r.target(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		// A rule that allows nobody would block the port entirely, which for SSH means being locked out.
+		if len(r.Allow) == 0 {
+			return fmt.Errorf("rule %d: at least one allowed source is required", i+1)
+		}
+		for _, source := range r.Allow {
+			if !validSource(source) {
+				return fmt.Errorf("rule %d: invalid source %q, expected an address or CIDR block", i+1, source)
+			}
+		}
+	}
+
+	return nil
+}
+
+// target returns the protocols and port that the rule applies to.
+func (r Rule) target() ([]string, int, error) {
+	if r.Service != "" {
+		if r.Port != 0 || r.Protocol != "" {
+			return nil, 0, errors.New("service can't be combined with port or protocol")
+		}
+		s, ok := services[r.Service]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown service %q", r.Service)
+		}
+		return s.protocols, s.port, nil
+	}
+
+	if r.Port < 1 || r.Port > 65535 {
+		return nil, 0, fmt.Errorf("invalid port %d", r.Port)
+	}
+	switch r.Protocol {
+	case "", "tcp":
+		return []string{"tcp"}, r.Port, nil
+	case "udp":
+		return []string{"udp"}, r.Port, nil
+	default:
+		return nil, 0, fmt.Errorf("invalid protocol %q", r.Protocol)
+	}
+}
+
+// validSource reports whether s is an IP address or CIDR block.
+func validSource(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// Render returns the pf rules of the configuration. For each port, connections from the allowed sources pass and
+// any others are blocked, except on the loopback interface, so that local services such as an SSH tunnel's endpoint
+// keep working. The rules are loaded in an anchor, where "set skip" isn't allowed, so each block rule excludes lo0.
+func (c *Config) Render() string {
+	var b strings.Builder
+	b.WriteString("# Managed by ec2-macos-utils, changes are overwritten.\n")
+	for _, r := range c.Rules {
+		protocols, port, _ := r.target()
+		for _, proto := range protocols {
+			fmt.Fprintf(&b, "pass in quick proto %s from { %s } to any port %d\n", proto, strings.Join(r.Allow, ", "), port)
+			fmt.Fprintf(&b, "block return in quick on ! lo0 proto %s from any to any port %d\n", proto, port)
+		}
+	}
+
+	return b.String()
+}
+
+// Apply replaces the rules of the anchor with the configuration's and enables pf.
+func Apply(ctx context.Context, c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	stdin := io.NopCloser(strings.NewReader(c.Render()))
+	out, err := util.ExecuteCommand(ctx, []string{"pfctl", "-a", Anchor, "-f", "-"}, "", nil, stdin)
+	if err != nil {
+		return fmt.Errorf("load pf rules: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return enablePF(ctx)
+}
+
+// Clear removes the rules of the anchor.
+func Clear(ctx context.Context) error {
+	out, err := util.ExecuteCommand(ctx, []string{"pfctl", "-a", Anchor, "-F", "rules"}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("flush pf rules: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// enablePF enables pf, which is a no-op when it's already enabled.
+func enablePF(ctx context.Context) error {
+	out, err := util.ExecuteCommand(ctx, []string{"pfctl", "-e"}, "", nil, nil)
+	// pfctl -e fails when pf is already enabled.
+	if err != nil && !strings.Contains(out.Stderr, "already enabled") {
+		return fmt.Errorf("enable pf: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// PFState is the state of the packet filter.
+type PFState struct {
+	Enabled bool `json:"enabled"`
+	// Rules are the rules loaded in the anchor.
+	Rules []string `json:"rules"`
+}
+
+// GetPFState returns the state of the packet filter.
+func GetPFState(ctx context.Context) (PFState, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"pfctl", "-s", "info"}, "", nil, nil)
+	if err != nil {
+		return PFState{}, fmt.Errorf("read pf status: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	state := PFState{Enabled: strings.Contains(out.Stdout, "Status: Enabled")}
+
+	out, err = util.ExecuteCommand(ctx, []string{"pfctl", "-a", Anchor, "-s", "rules"}, "", nil, nil)
+	if err != nil {
+		return PFState{}, fmt.Errorf("read pf rules: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	for _, line := range strings.Split(out.Stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			state.Rules = append(state.Rules, line)
+		}
+	}
+
+	return state, nil
+}
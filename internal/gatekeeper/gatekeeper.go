@@ -0,0 +1,191 @@
+// Package gatekeeper provides the functionality necessary for configuring the Gatekeeper execution policy with spctl.
+package gatekeeper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Policies are the execution policies that can be set.
+const (
+	// PolicyAppStore only allows apps from the App Store, the strictest policy.
+	PolicyAppStore = "app-store"
+	// PolicyAllowIdentified allows apps from the App Store and from identified (Developer ID) developers, the macOS
+	// default.
+	PolicyAllowIdentified = "allow-identified"
+	// PolicyDisable turns assessments off, allowing apps from anywhere.
+	PolicyDisable = "disable"
+)
+
+// ExemptionLabel labels the rules that exempt apps from assessment, so that they're managed together.
+const ExemptionLabel = "ec2-macos-utils"
+
+// developerIDLabel labels the built-in rules that allow Developer ID signed apps.
+const developerIDLabel = "Developer ID"
+
+// Policies lists the policies, from strictest to most permissive.
+var Policies = []string{PolicyAppStore, PolicyAllowIdentified, PolicyDisable}
+
+// Status is the Gatekeeper assessment policy.
+type Status struct {
+	// Assessments is whether apps are assessed before they run.
+	Assessments bool `json:"assessments"`
+	// DeveloperID is whether apps from identified developers are allowed.
+	DeveloperID bool `json:"developer_id"`
+	// Policy is the policy matching the status.
+	Policy string `json:"policy"`
+	// Exemptions is the number of exemption rules.
+	Exemptions int `json:"exemptions"`
+}
+
+// Assessment is the verdict of an assessment of an app.
+type Assessment struct {
+	Path     string `json:"path"`
+	Accepted bool   `json:"accepted"`
+	// Source is the kind of signature the verdict is based on, e.g. "Notarized Developer ID".
+	Source string `json:"source"`
+}
+
+// SetPolicy sets the execution policy.
+func SetPolicy(ctx context.Context, policy string) error {
+	commands, err := policyCommands(policy)
+	if err != nil {
+		return err
+	}
+	for _, args := range commands {
+		if err := spctl(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// policyCommands returns the spctl arguments that set the policy.
+func policyCommands(policy string) ([][]string, error) {
+	switch policy {
+	case PolicyAppStore:
+		return [][]string{{"--global-enable"}, {"--disable", "--label", developerIDLabel}}, nil
+	case PolicyAllowIdentified:
+		return [][]string{{"--global-enable"}, {"--enable", "--label", developerIDLabel}}, nil
+	case PolicyDisable:
+		return [][]string{{"--global-disable"}}, nil
+	default:
+		return nil, fmt.Errorf("unknown policy %q, expected one of %s", policy, strings.Join(Policies, ", "))
+	}
+}
+
+// GetStatus returns the assessment policy.
+func GetStatus(ctx context.Context) (Status, error) {
+	// spctl --status exits 1 when assessments are disabled.
+	out, _ := util.ExecuteCommand(ctx, []string{"spctl", "--status", "-v"}, "", nil, nil)
+	status, err := parseStatus(out.Stdout + out.Stderr)
+	if err != nil {
+		return Status{}, err
+	}
+
+	rules, err := util.ExecuteCommand(ctx, []string{"spctl", "--list", "--label", ExemptionLabel}, "", nil, nil)
+	if err == nil {
+		status.Exemptions = countRules(rules.Stdout)
+	}
+
+	return status, nil
+}
+
+// parseStatus parses spctl --status -v, e.g. "assessments enabled\ndeveloper id enabled".
+func parseStatus(output string) (Status, error) {
+	var status Status
+	switch {
+	case strings.Contains(output, "assessments enabled"):
+		status.Assessments = true
+	case strings.Contains(output, "assessments disabled"):
+	default:
+		return Status{}, fmt.Errorf("unknown assessment status %q", strings.TrimSpace(output))
+	}
+	status.DeveloperID = strings.Contains(output, "developer id enabled")
+
+	switch {
+	case !status.Assessments:
+		status.Policy = PolicyDisable
+	case status.DeveloperID:
+		status.Policy = PolicyAllowIdentified
+	default:
+		status.Policy = PolicyAppStore
+	}
+
+	return status, nil
+}
+
+// countRules counts the rules listed by spctl --list, e.g. "12[ec2-macos-utils] P0 allow execute" followed by an
+// indented requirement.
+func countRules(output string) int {
+	n := 0
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "["+ExemptionLabel+"]") {
+			n++
+		}
+	}
+
+	return n
+}
+
+// SetExemptions replaces the exemption rules with ones allowing the apps at paths to run whatever the policy.
+func SetExemptions(ctx context.Context, paths []string) error {
+	// Removing fails when there are no rules with the label, which is fine.
+	_, _ = util.ExecuteCommand(ctx, []string{"spctl", "--remove", "--label", ExemptionLabel}, "", nil, nil)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	for _, path := range paths {
+		if err := spctl(ctx, "--add", "--label", ExemptionLabel, path); err != nil {
+			return err
+		}
+	}
+
+	return spctl(ctx, "--enable", "--label", ExemptionLabel)
+}
+
+// Assess assesses whether the app at path is allowed to run.
+func Assess(ctx context.Context, path string) (Assessment, error) {
+	// spctl exits 3 when the app is rejected, so the verdict is read from its output rather than its status.
+	out, err := util.ExecuteCommand(ctx, []string{"spctl", "--assess", "--type", "execute", "-vv", path}, "", nil, nil)
+	assessment, ok := parseAssessment(path, out.Stdout+out.Stderr)
+	if !ok {
+		return Assessment{}, fmt.Errorf("assess %s: %s: %v", path, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return assessment, nil
+}
+
+// parseAssessment parses spctl --assess -vv, e.g. "/Applications/Xcode.app: accepted\nsource=Mac App Store".
+func parseAssessment(path, output string) (Assessment, bool) {
+	assessment := Assessment{Path: path}
+	var found bool
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == path+": accepted":
+			assessment.Accepted, found = true, true
+		case line == path+": rejected" || strings.HasPrefix(line, path+": rejected "):
+			found = true
+		case strings.HasPrefix(line, "source="):
+			assessment.Source = strings.TrimPrefix(line, "source=")
+		}
+	}
+
+	return assessment, found
+}
+
+// spctl runs spctl with the arguments.
+func spctl(ctx context.Context, args ...string) error {
+	out, err := util.ExecuteCommand(ctx, append([]string{"spctl"}, args...), "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("spctl %s: %s: %w", strings.Join(args, " "), strings.TrimSpace(out.Stderr+out.Stdout), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package gatekeeper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyCommands(t *testing.T) {
+	commands, err := policyCommands(PolicyAppStore)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"--global-enable"}, {"--disable", "--label", "Developer ID"}}, commands)
+
+	commands, err = policyCommands(PolicyAllowIdentified)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"--global-enable"}, {"--enable", "--label", "Developer ID"}}, commands)
+
+	commands, err = policyCommands(PolicyDisable)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"--global-disable"}}, commands)
+
+	_, err = policyCommands("anywhere")
+	assert.Error(t, err)
+}
+
+func TestParseStatus(t *testing.T) {
+	for output, want := range map[string]Status{
+		"assessments enabled\ndeveloper id enabled\n":  {Assessments: true, DeveloperID: true, Policy: PolicyAllowIdentified},
+		"assessments enabled\ndeveloper id disabled\n": {Assessments: true, Policy: PolicyAppStore},
+		"assessments disabled\ndeveloper id enabled\n": {DeveloperID: true, Policy: PolicyDisable},
+	} {
+		status, err := parseStatus(output)
+		assert.NoError(t, err)
+		assert.Equal(t, want, status, output)
+	}
+
+	_, err := parseStatus("spctl: command not found")
+	assert.Error(t, err)
+}
+
+func TestCountRules(t *testing.T) {
+	output := "12[ec2-macos-utils] P0 allow execute\n\tcdhash H\"ab\"\n13[ec2-macos-utils] P0 allow execute\n\tcdhash H\"cd\"\n"
+	assert.Equal(t, 2, countRules(output))
+	assert.Equal(t, 0, countRules(""))
+}
+
+func TestParseAssessment(t *testing.T) {
+	a, ok := parseAssessment("/Applications/Xcode.app", "/Applications/Xcode.app: accepted\nsource=Mac App Store\norigin=Apple Mac OS Application Signing\n")
+	assert.True(t, ok)
+	assert.Equal(t, Assessment{Path: "/Applications/Xcode.app", Accepted: true, Source: "Mac App Store"}, a)
+
+	a, ok = parseAssessment("/tmp/tool.app", "/tmp/tool.app: rejected\nsource=no usable signature\n")
+	assert.True(t, ok)
+	assert.Equal(t, Assessment{Path: "/tmp/tool.app", Source: "no usable signature"}, a)
+
+	_, ok = parseAssessment("/tmp/missing.app", "/tmp/missing.app: No such file or directory\n")
+	assert.False(t, ok)
+}
@@ -0,0 +1,389 @@
+// Package hardening provides curated baselines of macOS hardening settings that can be checked for drift and applied
+// idempotently.
+package hardening
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/audit"
+	"github.com/aws/ec2-macos-utils/internal/firewall"
+	"github.com/aws/ec2-macos-utils/internal/gatekeeper"
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Control is a hardening setting of a profile.
+type Control struct {
+	// ID identifies the control, e.g. "firewall".
+	ID string
+	// Description describes the desired setting.
+	Description string
+	// check reports whether the setting is in the desired state.
+	check func(ctx context.Context) (bool, error)
+	// apply puts the setting in the desired state.
+	apply func(ctx context.Context) error
+}
+
+// Result is the outcome of checking, and possibly applying, a control.
+type Result struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	// Compliant reports whether the setting is in the desired state, after it was applied if it was.
+	Compliant bool `json:"compliant"`
+	// Applied reports whether the setting was changed.
+	Applied bool `json:"applied"`
+	// Error is why the control couldn't be checked or applied.
+	Error string `json:"error,omitempty"`
+	// ToolError details the external tool the control failed on, if any.
+	ToolError *util.ExternalToolError `json:"tool_error,omitempty"`
+	// Resumed reports whether the control was made compliant by an interrupted run that this run resumed, and
+	// wasn't checked again.
+	Resumed bool `json:"resumed,omitempty"`
+}
+
+// Report is the outcome of running a profile.
+type Report struct {
+	Profile string   `json:"profile"`
+	Results []Result `json:"results"`
+	// Compliant is the number of compliant controls.
+	Compliant int `json:"compliant"`
+	// Score is the percentage of compliant controls.
+	Score int `json:"score"`
+}
+
+// Drifted returns the IDs of the controls that aren't compliant.
+func (r Report) Drifted() []string {
+	var ids []string
+	for _, result := range r.Results {
+		if !result.Compliant {
+			ids = append(ids, result.ID)
+		}
+	}
+
+	return ids
+}
+
+// profiles are the curated baselines by name.
+var profiles = map[string][]Control{
+	"cis-level1": cisLevel1,
+}
+
+// Profiles returns the names of the profiles.
+func Profiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Controls returns the controls of the profile.
+func Controls(profile string) ([]Control, error) {
+	controls, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q, expected one of %s", profile, strings.Join(Profiles(), ", "))
+	}
+
+	return controls, nil
+}
+
+// Journal records the controls that a run made compliant as it goes, so that an interrupted run can be resumed from
+// where it stopped.
+type Journal interface {
+	// Completed reports whether the control was made compliant.
+	Completed(id string) bool
+	// Value returns the named value recorded with Set.
+	Value(name string) string
+	// Set sets the named value, recorded when the next control completes.
+	Set(name, value string)
+	// Complete records that the control was made compliant.
+	Complete(id string)
+}
+
+// journalApplied is the journal value recording that a control was applied.
+const journalApplied = "applied"
+
+// Run checks every control of the profile and, with apply, applies those that aren't compliant. Controls that fail
+// are reported in their result rather than stopping the run, so that a report covers the whole profile. With a
+// journal, controls made compliant by the interrupted run it resumes are reported as they were then without being
+// checked again.
+func Run(ctx context.Context, profile string, apply bool, journal Journal) (Report, error) {
+	controls, err := Controls(profile)
+	if err != nil {
+		return Report{}, err
+	}
+	report := run(ctx, controls, apply, journal)
+	report.Profile = profile
+
+	return report, nil
+}
+
+// run checks, and with apply applies, the controls, recording those made compliant in the journal if there's one.
+func run(ctx context.Context, controls []Control, apply bool, journal Journal) Report {
+	var report Report
+	for _, control := range controls {
+		result := Result{ID: control.ID, Description: control.Description}
+		if journal != nil && journal.Completed(control.ID) {
+			result.Compliant, result.Resumed = true, true
+			result.Applied = journal.Value(control.ID) == journalApplied
+			report.Compliant++
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		compliant, err := control.check(ctx)
+		if err == nil && !compliant && apply {
+			if err = control.apply(ctx); err == nil {
+				result.Applied = true
+				compliant, err = control.check(ctx)
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+			result.ToolError = util.AsExternalToolError(err)
+		}
+		result.Compliant = err == nil && compliant
+		if result.Compliant {
+			report.Compliant++
+			if journal != nil {
+				if result.Applied {
+					journal.Set(control.ID, journalApplied)
+				}
+				journal.Complete(control.ID)
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+	if len(controls) > 0 {
+		report.Score = report.Compliant * 100 / len(controls)
+	}
+
+	return report
+}
+
+// Preference domains of the controls.
+const (
+	// screensaverDomain is the domain of the screen saver settings of each user, which are in effect unless a
+	// configuration profile enforces them, in which case they're in managedScreensaverDomain.
+	screensaverDomain        = "com.apple.screensaver"
+	managedScreensaverDomain = "/Library/Managed Preferences/com.apple.screensaver"
+	loginwindowDomain        = "/Library/Preferences/com.apple.loginwindow"
+)
+
+// geteuid is replaced in tests.
+var geteuid = os.Geteuid
+
+// Desired values of the screen lock settings, in seconds.
+const (
+	screenLockIdleTime      = 1200
+	screenLockPasswordDelay = 5
+)
+
+// cisLevel1 follows the CIS Apple macOS Benchmark Level 1 recommendations that can be set without a configuration
+// profile and don't interfere with access to EC2 instances. SSH, Screen Sharing, and automatic login are left as
+// they are since instances are managed through them.
+var cisLevel1 = []Control{
+	{
+		ID:          "screen-lock-idle",
+		Description: fmt.Sprintf("screen saver starts after %d minutes of inactivity or less", screenLockIdleTime/60),
+		check: func(ctx context.Context) (bool, error) {
+			// The idle time is a setting of the host, in the user's ByHost preferences.
+			idle, _, err := readScreensaverSetting(ctx, "idleTime", "-currentHost")
+			return idle > 0 && idle <= screenLockIdleTime, err
+		},
+		apply: func(ctx context.Context) error {
+			uid, err := consoleUser(ctx)
+			if err != nil {
+				return err
+			}
+			return execute(ctx, asUser(uid, "defaults", "-currentHost", "write", screensaverDomain, "idleTime", "-int",
+				strconv.Itoa(screenLockIdleTime))...)
+		},
+	},
+	{
+		ID:          "screen-lock-password",
+		Description: fmt.Sprintf("password is required %d seconds or less after the screen saver starts", screenLockPasswordDelay),
+		check: func(ctx context.Context) (bool, error) {
+			ask, _, err := readScreensaverSetting(ctx, "askForPassword")
+			if err != nil {
+				return false, err
+			}
+			delay, ok, err := readScreensaverSetting(ctx, "askForPasswordDelay")
+			return ask == 1 && ok && delay <= screenLockPasswordDelay, err
+		},
+		apply: func(context.Context) error {
+			// macOS ignores these preferences unless a configuration profile sets them, and changing the user's own
+			// setting with sysadminctl -screenLock needs their password.
+			return errors.New("the screen lock password can only be required by a configuration profile setting " +
+				"askForPassword and askForPasswordDelay in the com.apple.screensaver domain")
+		},
+	},
+	{
+		ID:          "firewall",
+		Description: "application firewall is enabled",
+		check: func(ctx context.Context) (bool, error) {
+			state, err := firewall.GetAppState(ctx)
+			return state.Enabled, err
+		},
+		apply: func(ctx context.Context) error {
+			state, err := firewall.GetAppState(ctx)
+			if err != nil {
+				return err
+			}
+			return firewall.EnableApp(ctx, firewall.AppOptions{Stealth: state.Stealth, BlockAll: state.BlockAll})
+		},
+	},
+	{
+		ID:          "firewall-stealth",
+		Description: "application firewall stealth mode is on",
+		check: func(ctx context.Context) (bool, error) {
+			state, err := firewall.GetAppState(ctx)
+			return state.Enabled && state.Stealth, err
+		},
+		apply: func(ctx context.Context) error {
+			state, err := firewall.GetAppState(ctx)
+			if err != nil {
+				return err
+			}
+			return firewall.EnableApp(ctx, firewall.AppOptions{Stealth: true, BlockAll: state.BlockAll})
+		},
+	},
+	{
+		ID:          "auditing",
+		Description: "security auditing (auditd) is enabled",
+		check: func(ctx context.Context) (bool, error) {
+			return audit.Enabled(ctx), nil
+		},
+		apply: audit.Enable,
+	},
+	{
+		ID:          "guest-account",
+		Description: "guest account is disabled",
+		check: func(ctx context.Context) (bool, error) {
+			enabled, _ := readIntDefault(ctx, loginwindowDomain, "GuestEnabled")
+			return enabled == 0, nil
+		},
+		apply: func(ctx context.Context) error {
+			return execute(ctx, "sysadminctl", "-guestAccount", "off")
+		},
+	},
+	{
+		ID:          "gatekeeper",
+		Description: "Gatekeeper assesses apps before they run",
+		check: func(ctx context.Context) (bool, error) {
+			status, err := gatekeeper.GetStatus(ctx)
+			return status.Assessments, err
+		},
+		apply: func(ctx context.Context) error {
+			// Apps from identified developers stay allowed since instances commonly run them.
+			return gatekeeper.SetPolicy(ctx, gatekeeper.PolicyAllowIdentified)
+		},
+	},
+	{
+		ID:          "remote-apple-events",
+		Description: "remote Apple events are disabled",
+		check: func(ctx context.Context) (bool, error) {
+			// systemsetup needs root privileges even to read the setting, e.g. with --report-only.
+			if geteuid() != 0 {
+				return false, errors.New("checking remote Apple events requires root privileges")
+			}
+			out, err := util.ExecuteCommand(ctx, []string{"systemsetup", "-getremoteappleevents"}, "", nil, nil)
+			if err != nil {
+				return false, fmt.Errorf("systemsetup -getremoteappleevents: %s: %w", strings.TrimSpace(out.Stderr), err)
+			}
+			return parseOnOff(out.Stdout) == "off", nil
+		},
+		apply: func(ctx context.Context) error {
+			return execute(ctx, "systemsetup", "-setremoteappleevents", "off")
+		},
+	},
+}
+
+// readIntDefault reads an integer preference, reporting whether it's set.
+func readIntDefault(ctx context.Context, domain, key string) (int, bool) {
+	out, err := util.ExecuteCommand(ctx, []string{"defaults", "read", domain, key}, "", nil, nil)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(out.Stdout))
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// readScreensaverSetting reads the screen saver setting in effect, reporting whether it's set: the one enforced by a
+// configuration profile if there's one, and the console user's own otherwise, read as them with the defaults options.
+// Screen savers only run in a user's session, so the setting can't be checked when nobody is logged in on the console
+// unless a profile enforces it.
+func readScreensaverSetting(ctx context.Context, key string, options ...string) (int, bool, error) {
+	if value, ok := readIntDefault(ctx, managedScreensaverDomain, key); ok {
+		return value, true, nil
+	}
+	uid, err := consoleUser(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	if euid := geteuid(); euid != 0 && strconv.Itoa(euid) != uid {
+		return 0, false, errors.New("checking the console user's screen saver requires root privileges")
+	}
+
+	argv := asUser(uid, append(append([]string{"defaults"}, options...), "read", screensaverDomain, key)...)
+	out, err := util.ExecuteCommand(ctx, argv, "", nil, nil)
+	if err != nil {
+		// defaults read fails when the setting isn't set.
+		return 0, false, nil
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(out.Stdout))
+
+	return value, err == nil, nil
+}
+
+// consoleUser returns the ID of the user logged in on the console.
+func consoleUser(ctx context.Context) (string, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"stat", "-f", "%u", "/dev/console"}, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("find console user: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	uid := strings.TrimSpace(out.Stdout)
+	if uid == "0" || uid == "" {
+		return "", errors.New("nobody is logged in on the console and no configuration profile sets the screen saver")
+	}
+
+	return uid, nil
+}
+
+// asUser returns the command line that runs argv as the user with the ID, in their session, or argv itself when it
+// already runs as them.
+func asUser(uid string, argv ...string) []string {
+	if strconv.Itoa(geteuid()) == uid {
+		return argv
+	}
+
+	return append([]string{"launchctl", "asuser", uid, "sudo", "-u", "#" + uid}, argv...)
+}
+
+// parseOnOff returns the lowercased setting of systemsetup output such as "Remote Apple Events: Off".
+func parseOnOff(output string) string {
+	_, value, _ := strings.Cut(output, ":")
+
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// execute runs the command and wraps its error with its output.
+func execute(ctx context.Context, args ...string) error {
+	out, err := util.ExecuteCommand(ctx, args, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", strings.Join(args, " "), strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package hardening
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeControl returns a control whose setting starts in the state and that counts how often it's applied.
+func fakeControl(id string, compliant *bool, applyErr error, applied *int) Control {
+	return Control{
+		ID: id,
+		check: func(context.Context) (bool, error) {
+			return *compliant, nil
+		},
+		apply: func(context.Context) error {
+			*applied++
+			if applyErr != nil {
+				return applyErr
+			}
+			*compliant = true
+			return nil
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	ok, drifted, broken := true, false, false
+	var okApplied, driftedApplied, brokenApplied int
+	controls := []Control{
+		fakeControl("ok", &ok, nil, &okApplied),
+		fakeControl("drifted", &drifted, nil, &driftedApplied),
+		fakeControl("broken", &broken, errors.New("denied"), &brokenApplied),
+		{
+			ID:    "unreadable",
+			check: func(context.Context) (bool, error) { return false, errors.New("unreadable") },
+		},
+	}
+
+	report := run(context.Background(), controls, false, nil)
+	assert.Equal(t, 1, report.Compliant)
+	assert.Equal(t, 25, report.Score)
+	assert.Equal(t, []string{"drifted", "broken", "unreadable"}, report.Drifted())
+	assert.Zero(t, driftedApplied+brokenApplied, "nothing should be applied when reporting")
+	assert.Equal(t, "unreadable", report.Results[3].Error)
+
+	report = run(context.Background(), controls, true, nil)
+	assert.Equal(t, 2, report.Compliant)
+	assert.Equal(t, 50, report.Score)
+	assert.Equal(t, []string{"broken", "unreadable"}, report.Drifted())
+	assert.Zero(t, okApplied, "compliant controls shouldn't be applied")
+	assert.Equal(t, 1, driftedApplied)
+	assert.True(t, report.Results[1].Applied)
+	assert.False(t, report.Results[2].Applied)
+	assert.Equal(t, "denied", report.Results[2].Error)
+
+	run(context.Background(), controls, true, nil)
+	assert.Equal(t, 1, driftedApplied, "applying should be idempotent")
+}
+
+// fakeJournal is a Journal kept in memory.
+type fakeJournal struct {
+	completed []string
+	values    map[string]string
+}
+
+func (j *fakeJournal) Completed(id string) bool {
+	for _, c := range j.completed {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *fakeJournal) Value(name string) string { return j.values[name] }
+
+func (j *fakeJournal) Set(name, value string) { j.values[name] = value }
+
+func (j *fakeJournal) Complete(id string) { j.completed = append(j.completed, id) }
+
+func TestRun_Journal(t *testing.T) {
+	ok, drifted, broken := true, false, false
+	var okApplied, driftedApplied, brokenApplied int
+	controls := []Control{
+		fakeControl("ok", &ok, nil, &okApplied),
+		fakeControl("drifted", &drifted, nil, &driftedApplied),
+		fakeControl("broken", &broken, errors.New("denied"), &brokenApplied),
+	}
+
+	journal := &fakeJournal{values: map[string]string{}}
+	run(context.Background(), controls, true, journal)
+	assert.Equal(t, []string{"ok", "drifted"}, journal.completed, "only compliant controls should be completed")
+
+	// The resumed run skips the completed controls, even if they drifted since.
+	drifted = false
+	report := run(context.Background(), controls, true, journal)
+	assert.Equal(t, 1, driftedApplied)
+	assert.Equal(t, 2, brokenApplied, "controls that failed should be retried")
+	assert.True(t, report.Results[0].Resumed)
+	assert.False(t, report.Results[0].Applied)
+	assert.True(t, report.Results[1].Resumed)
+	assert.True(t, report.Results[1].Applied, "controls applied by the interrupted run should be reported as applied")
+	assert.False(t, report.Results[2].Resumed)
+}
+
+func TestControls(t *testing.T) {
+	assert.Equal(t, []string{"cis-level1"}, Profiles())
+
+	controls, err := Controls("cis-level1")
+	require.NoError(t, err)
+	ids := map[string]bool{}
+	for _, control := range controls {
+		assert.False(t, ids[control.ID], "duplicate control %s", control.ID)
+		ids[control.ID] = true
+		assert.NotEmpty(t, control.Description, control.ID)
+		assert.NotNil(t, control.check, control.ID)
+		assert.NotNil(t, control.apply, control.ID)
+	}
+
+	_, err = Controls("cis-level3")
+	assert.Error(t, err)
+}
+
+func TestParseOnOff(t *testing.T) {
+	assert.Equal(t, "off", parseOnOff("Remote Apple Events: Off\n"))
+	assert.Equal(t, "on", parseOnOff("Remote Apple Events: On"))
+}
+
+func TestAsUser(t *testing.T) {
+	geteuid = func() int { return 0 }
+	t.Cleanup(func() { geteuid = os.Geteuid })
+
+	assert.Equal(t, []string{"launchctl", "asuser", "501", "sudo", "-u", "#501", "defaults", "read", "com.apple.screensaver", "idleTime"},
+		asUser("501", "defaults", "read", "com.apple.screensaver", "idleTime"))
+
+	geteuid = func() int { return 501 }
+	assert.Equal(t, []string{"defaults", "read"}, asUser("501", "defaults", "read"), "commands of the console user run as is")
+}
@@ -0,0 +1,128 @@
+// Package hostname provides the functionality necessary for reading and setting the macOS computer name, host name,
+// and local (Bonjour) host name consistently.
+package hostname
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// maxLabelLength is the longest DNS label, which limits the local host name.
+const maxLabelLength = 63
+
+// Names are the names macOS knows the computer by.
+type Names struct {
+	// ComputerName is the user-friendly name shown in Sharing settings and to AirDrop or Screen Sharing clients.
+	ComputerName string `json:"computer_name"`
+	// HostName is the name of the host on the network, as returned by hostname(1).
+	HostName string `json:"host_name"`
+	// LocalHostName is the Bonjour name, advertised as <name>.local.
+	LocalHostName string `json:"local_host_name"`
+}
+
+// keys are the scutil preference keys of the names, in the order they're set.
+var keys = []string{"ComputerName", "HostName", "LocalHostName"}
+
+// get returns the pointer to the name with the scutil key.
+func (n *Names) get(key string) *string {
+	switch key {
+	case "ComputerName":
+		return &n.ComputerName
+	case "HostName":
+		return &n.HostName
+	default:
+		return &n.LocalHostName
+	}
+}
+
+// FromName derives consistent names from name, e.g. "i-0123456789abcdef0" or "Build Mac 7". The computer name is
+// name itself, the host name is a lowercase DNS name, and the local host name is a single DNS label.
+func FromName(name string) (Names, error) {
+	name = strings.TrimSpace(name)
+	hostName := sanitize(name, true)
+	localHostName := sanitize(name, false)
+	if hostName == "" || localHostName == "" {
+		return Names{}, fmt.Errorf("no valid host name can be derived from %q", name)
+	}
+
+	return Names{ComputerName: name, HostName: hostName, LocalHostName: localHostName}, nil
+}
+
+// sanitize lowercases s and replaces characters that aren't valid in DNS labels with hyphens. Dots separate labels
+// when allowDots is set and are replaced otherwise. Each label is limited to 63 characters.
+func sanitize(s string, allowDots bool) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '.' && allowDots:
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+
+	var labels []string
+	for _, label := range strings.Split(b.String(), ".") {
+		if len(label) > maxLabelLength {
+			label = label[:maxLabelLength]
+		}
+		// Repeated hyphens from runs of invalid characters are collapsed, and labels can't start or end with one.
+		for strings.Contains(label, "--") {
+			label = strings.ReplaceAll(label, "--", "-")
+		}
+		if label = strings.Trim(label, "-"); label != "" {
+			labels = append(labels, label)
+		}
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// Get returns the current names. Names that aren't set are empty.
+func Get(ctx context.Context) (Names, error) {
+	var names Names
+	for _, key := range keys {
+		out, err := util.ExecuteCommand(ctx, []string{"scutil", "--get", key}, "", nil, nil)
+		if err != nil {
+			if strings.Contains(out.Stdout+out.Stderr, "not set") {
+				continue
+			}
+			return Names{}, fmt.Errorf("get %s: %s: %w", key, strings.TrimSpace(out.Stderr), err)
+		}
+		*names.get(key) = strings.TrimSpace(out.Stdout)
+	}
+
+	return names, nil
+}
+
+// Set sets the names that differ from the current ones and returns the keys of those that changed.
+func Set(ctx context.Context, names Names) ([]string, error) {
+	if names.ComputerName == "" || names.HostName == "" || names.LocalHostName == "" {
+		return nil, errors.New("every name is required")
+	}
+	current, err := Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, key := range keys {
+		value := *names.get(key)
+		if *current.get(key) == value {
+			continue
+		}
+		out, err := util.ExecuteCommand(ctx, []string{"scutil", "--set", key, value}, "", nil, nil)
+		if err != nil {
+			return changed, fmt.Errorf("set %s: %s: %w", key, strings.TrimSpace(out.Stderr), err)
+		}
+		changed = append(changed, key)
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,26 @@
+package hostname
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromName(t *testing.T) {
+	names, err := FromName("i-0123456789abcdef0")
+	assert.NoError(t, err)
+	assert.Equal(t, Names{ComputerName: "i-0123456789abcdef0", HostName: "i-0123456789abcdef0", LocalHostName: "i-0123456789abcdef0"}, names)
+
+	names, err = FromName(" Build Mac #7.ci.example.com ")
+	assert.NoError(t, err)
+	assert.Equal(t, Names{ComputerName: "Build Mac #7.ci.example.com", HostName: "build-mac-7.ci.example.com", LocalHostName: "build-mac-7-ci-example-com"}, names)
+
+	_, err = FromName("***")
+	assert.Error(t, err)
+}
+
+func TestSanitize(t *testing.T) {
+	assert.Equal(t, "a.b", sanitize("-a-..b-", true))
+	assert.Equal(t, strings.Repeat("x", maxLabelLength), sanitize(strings.Repeat("x", 100), false))
+}
@@ -0,0 +1,237 @@
+// Package imdslatency provides the functionality necessary for sampling the round-trip latency of the EC2 Instance
+// Metadata Service over long periods, persisting the samples, and summarizing them as percentiles.
+package imdslatency
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultEndpoint is the address of IMDS.
+	DefaultEndpoint = "http://169.254.169.254"
+	// tokenPath is the path of the IMDSv2 session token.
+	tokenPath = "/latest/api/token"
+	// metadataPath is the path of the metadata read with the token, chosen since it's small and always present.
+	metadataPath = "/latest/meta-data/instance-id"
+	// tokenTTL is the lifetime requested for the tokens, which are only used once.
+	tokenTTL = "60"
+)
+
+// Sample is a measurement of the round trips to IMDS.
+type Sample struct {
+	// Time is when the sample was taken.
+	Time time.Time `json:"time"`
+	// Token is the round-trip time of the token request.
+	Token time.Duration `json:"token_ns"`
+	// Metadata is the round-trip time of the metadata request made with the token, zero if the token request failed.
+	Metadata time.Duration `json:"metadata_ns,omitempty"`
+	// Error is why the sample failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Total is the round-trip time of both requests.
+func (s Sample) Total() time.Duration {
+	return s.Token + s.Metadata
+}
+
+// Prober takes samples of the round trips to IMDS.
+type Prober struct {
+	// Endpoint is the address of IMDS, DefaultEndpoint if empty.
+	Endpoint string
+	// Client makes the requests. Each request is limited by its timeout.
+	Client *http.Client
+}
+
+// Probe requests a token, then reads metadata with it, and measures the round trip of each. A failed request is
+// recorded in the sample's error rather than returned, since failures are part of what's sampled.
+func (p *Prober) Probe(ctx context.Context) Sample {
+	sample := Sample{Time: time.Now()}
+
+	var token string
+	var err error
+	sample.Token, err = p.timeRequest(ctx, http.MethodPut, tokenPath, map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": tokenTTL}, &token)
+	if err != nil {
+		sample.Error = fmt.Sprintf("token: %v", err)
+		return sample
+	}
+	sample.Metadata, err = p.timeRequest(ctx, http.MethodGet, metadataPath, map[string]string{"X-aws-ec2-metadata-token": token}, nil)
+	if err != nil {
+		sample.Error = fmt.Sprintf("metadata: %v", err)
+	}
+
+	return sample
+}
+
+// timeRequest makes the request, reads its body into body when it's set, and returns how long it took until the body
+// was read.
+func (p *Prober) timeRequest(ctx context.Context, method, path string, headers map[string]string, body *string) (time.Duration, error) {
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return time.Since(start), err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	elapsed := time.Since(start)
+	if err != nil {
+		return elapsed, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return elapsed, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if body != nil {
+		*body = string(data)
+	}
+
+	return elapsed, nil
+}
+
+// AppendSample appends the sample to the file at path as a line of JSON, creating it if needed, so that samples taken
+// over a long period survive the process.
+func AppendSample(path string, s Sample) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ReadSamples reads the samples appended to the file at path. Lines that can't be decoded, e.g. the last line of a
+// process that was killed while writing it, are skipped.
+func ReadSamples(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s Sample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		samples = append(samples, s)
+	}
+
+	return samples, scanner.Err()
+}
+
+// Percentiles summarizes the distribution of round-trip times, in milliseconds.
+type Percentiles struct {
+	P50 float64 `json:"p50_ms"`
+	P90 float64 `json:"p90_ms"`
+	P99 float64 `json:"p99_ms"`
+	Max float64 `json:"max_ms"`
+}
+
+// Summary summarizes samples.
+type Summary struct {
+	// From and To are the times of the first and last samples.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Samples is the number of samples, and Failures the number of them that failed.
+	Samples  int `json:"samples"`
+	Failures int `json:"failures"`
+	// Token, Metadata, and Total are the percentiles of the round trips of the samples that succeeded.
+	Token    Percentiles `json:"token"`
+	Metadata Percentiles `json:"metadata"`
+	Total    Percentiles `json:"total"`
+	// Slowest is the successful sample with the longest total round trip.
+	Slowest *Sample `json:"slowest,omitempty"`
+}
+
+// ErrNoSamples is returned when there are no samples to summarize.
+var ErrNoSamples = errors.New("no samples")
+
+// Summarize summarizes the samples, which may be in any order.
+func Summarize(samples []Sample) (Summary, error) {
+	if len(samples) == 0 {
+		return Summary{}, ErrNoSamples
+	}
+
+	summary := Summary{From: samples[0].Time, To: samples[0].Time, Samples: len(samples)}
+	var token, metadata, total []time.Duration
+	for i, s := range samples {
+		if s.Time.Before(summary.From) {
+			summary.From = s.Time
+		}
+		if s.Time.After(summary.To) {
+			summary.To = s.Time
+		}
+		if s.Error != "" {
+			summary.Failures++
+			continue
+		}
+		token = append(token, s.Token)
+		metadata = append(metadata, s.Metadata)
+		total = append(total, s.Total())
+		if summary.Slowest == nil || s.Total() > summary.Slowest.Total() {
+			summary.Slowest = &samples[i]
+		}
+	}
+	summary.Token = percentiles(token)
+	summary.Metadata = percentiles(metadata)
+	summary.Total = percentiles(total)
+
+	return summary, nil
+}
+
+// percentiles returns the percentiles of the durations with the nearest-rank method, or zeros if there are none.
+func percentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return milliseconds(sorted[i])
+	}
+
+	return Percentiles{P50: rank(50), P90: rank(90), P99: rank(99), Max: milliseconds(sorted[len(sorted)-1])}
+}
+
+// milliseconds returns d in milliseconds, rounded to microseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Round(time.Microsecond)) / float64(time.Millisecond)
+}
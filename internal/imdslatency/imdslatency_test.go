@@ -0,0 +1,102 @@
+package imdslatency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProber_Probe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == tokenPath:
+			assert.Equal(t, tokenTTL, r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+			_, _ = w.Write([]byte("token"))
+		case r.Method == http.MethodGet && r.URL.Path == metadataPath:
+			if r.Header.Get("X-aws-ec2-metadata-token") != "token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte("i-0123456789abcdef0"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &Prober{Endpoint: server.URL, Client: server.Client()}
+	sample := p.Probe(context.Background())
+	assert.Empty(t, sample.Error)
+	assert.Positive(t, sample.Token)
+	assert.Positive(t, sample.Metadata)
+	assert.Equal(t, sample.Token+sample.Metadata, sample.Total())
+}
+
+func TestProber_Probe_TokenFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	p := &Prober{Endpoint: server.URL, Client: server.Client()}
+	sample := p.Probe(context.Background())
+	assert.Equal(t, "token: status 403", sample.Error)
+	assert.Zero(t, sample.Metadata, "metadata shouldn't be read without a token")
+}
+
+func TestAppendSample_ReadSamples(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "imds-latency", "samples.jsonl")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{Time: start, Token: time.Millisecond, Metadata: 2 * time.Millisecond},
+		{Time: start.Add(time.Minute), Token: time.Second, Error: "metadata: status 500"},
+	}
+	for _, s := range samples {
+		require.NoError(t, AppendSample(path, s))
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"time": "2024-01-01T00:02:00Z", "tok`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	read, err := ReadSamples(path)
+	assert.NoError(t, err)
+	assert.Equal(t, samples, read, "a partially written sample should be skipped")
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var samples []Sample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, Sample{
+			Time:     start.Add(time.Duration(i) * time.Second),
+			Token:    time.Duration(i) * time.Millisecond,
+			Metadata: time.Millisecond,
+		})
+	}
+	samples = append(samples, Sample{Time: start, Token: time.Hour, Error: "token: timeout"})
+
+	summary, err := Summarize(samples)
+	require.NoError(t, err)
+	assert.Equal(t, start, summary.From)
+	assert.Equal(t, start.Add(100*time.Second), summary.To)
+	assert.Equal(t, 101, summary.Samples)
+	assert.Equal(t, 1, summary.Failures)
+	assert.Equal(t, Percentiles{P50: 50, P90: 90, P99: 99, Max: 100}, summary.Token, "failures shouldn't count towards percentiles")
+	assert.Equal(t, Percentiles{P50: 1, P90: 1, P99: 1, Max: 1}, summary.Metadata)
+	assert.Equal(t, Percentiles{P50: 51, P90: 91, P99: 100, Max: 101}, summary.Total)
+	if assert.NotNil(t, summary.Slowest) {
+		assert.Equal(t, 100*time.Millisecond, summary.Slowest.Token)
+	}
+
+	_, err = Summarize(nil)
+	assert.ErrorIs(t, err, ErrNoSamples)
+}
@@ -0,0 +1,103 @@
+package imdstoken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// clientRetryAfter is how long a client doesn't ask the socket again after failing to get a token from it, e.g. when
+// the daemon isn't running, so that each IMDS request doesn't wait for it.
+const clientRetryAfter = time.Minute
+
+// Client gets the token shared on a socket, keeping it until it's about to expire. It's safe for concurrent use.
+type Client struct {
+	socketPath string
+	http       *http.Client
+	now        func() time.Time
+
+	mu          sync.Mutex
+	token       Token
+	failedUntil time.Time
+	err         error
+}
+
+// NewClient creates a client of the token shared on the socket at socketPath.
+func NewClient(socketPath string) *Client {
+	dialer := &net.Dialer{Timeout: time.Second}
+
+	return &Client{
+		socketPath: socketPath,
+		http: &http.Client{
+			Timeout: 2 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Token returns the shared token. After failing to get one, it fails without asking the socket again for a while.
+func (c *Client) Token(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.token.validFor(now, minRemaining) {
+		return c.token, nil
+	}
+	if now.Before(c.failedUntil) {
+		return Token{}, c.err
+	}
+
+	var token Token
+	err := c.get(ctx, "/token", &token)
+	if err == nil && !token.validFor(now, minRemaining) {
+		err = errors.New("shared IMDS token is about to expire")
+	}
+	if err != nil {
+		c.failedUntil, c.err = now.Add(clientRetryAfter), err
+		return Token{}, err
+	}
+	c.token = token
+
+	return token, nil
+}
+
+// Health returns the health of the cache serving the token.
+func (c *Client) Health(ctx context.Context) (Health, error) {
+	var health Health
+	err := c.get(ctx, "/health", &health)
+
+	return health, err
+}
+
+// get decodes the JSON response to a GET request of path into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	if _, err := os.Stat(c.socketPath); err != nil {
+		return fmt.Errorf("no shared IMDS token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://imdstoken"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("get shared IMDS token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get shared IMDS token: status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,251 @@
+// Package imdstoken provides the functionality necessary for keeping an IMDSv2 session token fresh in a long-running
+// process, the daemon, and sharing it with the utility's other invocations over a root-only Unix socket, so that they
+// don't each request a token and token failures can be told apart from failures to read metadata.
+package imdstoken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSocketPath is where the daemon serves the token, in a directory of its own that only root can access.
+	DefaultSocketPath = "/private/var/run/ec2-macos-utils/imds-token/imds-token.sock"
+	// DefaultEndpoint is the address of IMDS.
+	DefaultEndpoint = "http://169.254.169.254"
+	// DefaultTTL is the lifetime requested for tokens, the longest IMDS allows.
+	DefaultTTL = 6 * time.Hour
+
+	// tokenPath is the path of the IMDSv2 session token.
+	tokenPath = "/latest/api/token"
+	// requestTimeout limits each token request to IMDS.
+	requestTimeout = 5 * time.Second
+	// refreshBefore is how long before it expires a token is replaced, so that the token handed out is always good for
+	// at least minRemaining.
+	refreshBefore = 30 * time.Minute
+	// minRemaining is the least lifetime left of a token that's handed out, so that it doesn't expire while it's used.
+	minRemaining = 5 * time.Minute
+)
+
+// Token is an IMDSv2 session token.
+type Token struct {
+	Value   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// validFor reports whether the token is still valid for d at now.
+func (t Token) validFor(now time.Time, d time.Duration) bool {
+	return t.Value != "" && now.Add(d).Before(t.Expires)
+}
+
+// Health is the outcome of the cache's token requests, a health signal of its own since IMDS can hand out tokens
+// while failing to serve metadata, and the other way around.
+type Health struct {
+	// OK is whether the cache holds a token that can be handed out.
+	OK bool `json:"ok"`
+	// LastAttempt and LastSuccess are when a token was last requested, and last acquired.
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	// Expires is when the cached token expires.
+	Expires time.Time `json:"expires,omitempty"`
+	// Failures is the number of token requests that failed since the last one that succeeded.
+	Failures int    `json:"failures"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Err returns an error describing why the cache has no token, or nil if it has one.
+func (h Health) Err() error {
+	switch {
+	case h.OK:
+		return nil
+	case h.Error != "":
+		return fmt.Errorf("no IMDS token after %d failed requests: %s", h.Failures, h.Error)
+	default:
+		return errors.New("no IMDS token requested yet")
+	}
+}
+
+// Cache keeps an IMDSv2 session token fresh. It's safe for concurrent use.
+type Cache struct {
+	// Endpoint is the address of IMDS, DefaultEndpoint if empty.
+	Endpoint string
+	// TTL is the lifetime requested for tokens, DefaultTTL if zero.
+	TTL time.Duration
+	// Client makes the requests, with a timeout of its own if it's nil.
+	Client *http.Client
+
+	now func() time.Time
+
+	mu     sync.Mutex
+	token  Token
+	health Health
+}
+
+// clock returns the current time.
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+
+	return time.Now()
+}
+
+// Refresh requests a new token unless the cached one is good for a while longer, and reports whether it requested
+// one. A failed request keeps the cached token, which may still be good for a while.
+func (c *Cache) Refresh(ctx context.Context) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock()
+	if c.token.validFor(now, refreshBefore) {
+		return false, nil
+	}
+
+	token, err := c.request(ctx, now)
+	c.health.LastAttempt = now
+	if err != nil {
+		c.health.Failures++
+		c.health.Error = err.Error()
+	} else {
+		c.token = token
+		c.health.LastSuccess, c.health.Failures, c.health.Error = now, 0, ""
+	}
+	c.health.Expires = c.token.Expires
+	c.health.OK = c.token.validFor(now, minRemaining)
+
+	return true, err
+}
+
+// Token returns the cached token if it's good for long enough to be used.
+func (c *Cache) Token() (Token, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.token, c.token.validFor(c.clock(), minRemaining)
+}
+
+// Health returns the outcome of the cache's token requests.
+func (c *Cache) Health() Health {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	health := c.health
+	health.OK = c.token.validFor(c.clock(), minRemaining)
+
+	return health
+}
+
+// request requests a token from IMDS.
+func (c *Cache) request(ctx context.Context, now time.Time) (Token, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimSuffix(endpoint, "/")+tokenPath, nil)
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(int(ttl.Seconds())))
+	resp, err := client.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("request IMDS token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return Token{}, fmt.Errorf("read IMDS token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Token{}, fmt.Errorf("request IMDS token: status %d", resp.StatusCode)
+	}
+	if len(body) == 0 {
+		return Token{}, errors.New("request IMDS token: empty token")
+	}
+	// IMDS reports the lifetime it granted, which is the one requested unless it was capped.
+	if granted, err := strconv.Atoi(resp.Header.Get("X-aws-ec2-metadata-token-ttl-seconds")); err == nil && granted > 0 {
+		ttl = time.Duration(granted) * time.Second
+	}
+
+	return Token{Value: string(body), Expires: now.Add(ttl)}, nil
+}
+
+// Handler returns the handler serving the cached token at /token and the health of the cache at /health. /token
+// answers 503 Service Unavailable when there's no token to hand out.
+func (c *Cache) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token, ok := c.Token()
+		if !ok {
+			http.Error(w, "no IMDS token", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, token)
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, c.Health())
+	})
+
+	return mux
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Listen listens on the Unix socket at path, replacing a stale socket left by a process that didn't exit cleanly. The
+// socket and its directory are only accessible by the current user, root, since the token grants access to the
+// instance's metadata, including its role credentials. The directory must be dedicated to the socket, since its
+// permissions are restricted; the directories above it are left alone, as other files in them may be shared.
+func Listen(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, fmt.Errorf("create socket directory: %w", err)
+	}
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("create socket directory: %w", err)
+	}
+	if fi, err := os.Lstat(dir); err != nil {
+		return nil, fmt.Errorf("create socket directory: %w", err)
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("socket directory %s isn't a directory", dir)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("restrict socket directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("restrict socket: %w", err)
+	}
+
+	return l, nil
+}
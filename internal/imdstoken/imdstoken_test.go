@@ -0,0 +1,121 @@
+package imdstoken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIMDS serves tokens, or fails when fail is set, and counts the requests.
+type fakeIMDS struct {
+	requests int
+	fail     bool
+}
+
+func (f *fakeIMDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests++
+	if f.fail || r.Method != http.MethodPut || r.URL.Path != tokenPath {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+	_, _ = w.Write([]byte("token"))
+}
+
+func TestCache_Refresh(t *testing.T) {
+	imds := &fakeIMDS{}
+	server := httptest.NewServer(imds)
+	defer server.Close()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	cache := &Cache{Endpoint: server.URL, TTL: time.Hour, now: func() time.Time { return now }}
+	_, ok := cache.Token()
+	assert.False(t, ok)
+	assert.Error(t, cache.Health().Err())
+
+	requested, err := cache.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, requested)
+	token, ok := cache.Token()
+	require.True(t, ok)
+	assert.Equal(t, "token", token.Value)
+	assert.Equal(t, now.Add(time.Hour), token.Expires)
+
+	requested, err = cache.Refresh(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, requested, "a fresh token shouldn't be replaced")
+	assert.Equal(t, 1, imds.requests)
+
+	// A failed request keeps the token while it's still good.
+	now = now.Add(45 * time.Minute)
+	imds.fail = true
+	requested, err = cache.Refresh(context.Background())
+	assert.Error(t, err)
+	assert.True(t, requested)
+	health := cache.Health()
+	assert.True(t, health.OK)
+	assert.Equal(t, 1, health.Failures)
+	assert.NotEmpty(t, health.Error)
+
+	now = now.Add(15 * time.Minute)
+	_, ok = cache.Token()
+	assert.False(t, ok, "an expired token shouldn't be handed out")
+	assert.Error(t, cache.Health().Err())
+
+	imds.fail = false
+	_, err = cache.Refresh(context.Background())
+	require.NoError(t, err)
+	health = cache.Health()
+	assert.True(t, health.OK)
+	assert.Zero(t, health.Failures)
+	assert.Equal(t, now, health.LastSuccess)
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(&fakeIMDS{})
+	defer server.Close()
+
+	run := filepath.Join(t.TempDir(), "run")
+	require.NoError(t, os.Mkdir(run, 0755))
+	socket := filepath.Join(run, "imds-token", "imds-token.sock")
+	client := NewClient(socket)
+	_, err := client.Token(context.Background())
+	assert.Error(t, err, "nothing serves the token yet")
+
+	cache := &Cache{Endpoint: server.URL}
+	_, err = cache.Refresh(context.Background())
+	require.NoError(t, err)
+	l, err := Listen(socket)
+	require.NoError(t, err)
+	shared := &http.Server{Handler: cache.Handler()}
+	go func() { _ = shared.Serve(l) }()
+	defer shared.Close()
+
+	fi, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	fi, err = os.Stat(filepath.Dir(socket))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+	fi, err = os.Stat(run)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), fi.Mode().Perm(), "the shared parent directory should be left alone")
+
+	_, err = client.Token(context.Background())
+	assert.Error(t, err, "failures should be remembered for a while")
+
+	client = NewClient(socket)
+	token, err := client.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token", token.Value)
+	health, err := client.Health(context.Background())
+	require.NoError(t, err)
+	assert.True(t, health.OK)
+}
@@ -0,0 +1,139 @@
+// Package journal provides the functionality necessary for making multi-step operations crash-safe. The steps of an
+// operation are recorded in the state as they complete, so that an operation interrupted by a crash or timeout can be
+// resumed after its last completed step rather than started over or left half done.
+package journal
+
+import (
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+// Record is the journal of an operation.
+type Record struct {
+	// Operation is the operation, e.g. "security harden".
+	Operation string `json:"operation"`
+	// Key identifies what the operation is applied to, e.g. a hardening profile, so that runs applied to different
+	// targets have their own journals.
+	Key string `json:"key"`
+	// RunID is the run ID of the invocation that last updated the journal.
+	RunID string `json:"run_id,omitempty"`
+	// Started is when the operation started.
+	Started time.Time `json:"started"`
+	// Updated is when a step last completed.
+	Updated time.Time `json:"updated"`
+	// Completed are the steps that completed, in order.
+	Completed []string `json:"completed"`
+	// Values are what completed steps produced that later steps need, e.g. the path of a collected archive.
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// Journal records the steps of an operation as they complete.
+type Journal struct {
+	path    string
+	record  Record
+	resumed bool
+}
+
+func recordKey(operation, key string) string {
+	return operation + "/" + key
+}
+
+// Begin starts the journal of the operation on key, in the state at path. With resume, the journal of an interrupted
+// run of the operation on key is continued, so that its completed steps are skipped. Otherwise, it's discarded and the
+// operation starts over, and it's returned as interrupted so that it can be reported.
+func Begin(path, operation, key, runID string, resume bool) (j *Journal, interrupted *Record, err error) {
+	j = &Journal{path: path, record: Record{Operation: operation, Key: key, Started: time.Now()}}
+
+	var previous Record
+	var found bool
+	err = withStore(path, func(s *state.Store) error {
+		var err error
+		found, err = s.Get(state.BucketJournals, recordKey(operation, key), &previous)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case found && resume:
+		j.record, j.resumed = previous, true
+	case found:
+		interrupted = &previous
+	}
+	// The journal is recorded before any step completes so that a run interrupted before then is resumed too.
+	j.record.RunID = runID
+	if err := j.save(); err != nil {
+		return nil, nil, err
+	}
+
+	return j, interrupted, nil
+}
+
+// Record returns the journal's record.
+func (j *Journal) Record() Record {
+	return j.record
+}
+
+// Resumed reports whether the journal continues an interrupted run.
+func (j *Journal) Resumed() bool {
+	return j.resumed
+}
+
+// Completed reports whether the step completed.
+func (j *Journal) Completed(step string) bool {
+	for _, s := range j.record.Completed {
+		if s == step {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Value returns the named value produced by a completed step.
+func (j *Journal) Value(name string) string {
+	return j.record.Values[name]
+}
+
+// Set sets the named value, which is recorded when the step producing it completes.
+func (j *Journal) Set(name, value string) {
+	if j.record.Values == nil {
+		j.record.Values = map[string]string{}
+	}
+	j.record.Values[name] = value
+}
+
+// Complete records that the step completed, with the values set so far.
+func (j *Journal) Complete(step string) error {
+	if !j.Completed(step) {
+		j.record.Completed = append(j.record.Completed, step)
+	}
+	j.record.Updated = time.Now()
+
+	return j.save()
+}
+
+// Finish removes the journal once the operation completed, so that a later run starts over.
+func (j *Journal) Finish() error {
+	return withStore(j.path, func(s *state.Store) error {
+		return s.Delete(state.BucketJournals, recordKey(j.record.Operation, j.record.Key))
+	})
+}
+
+func (j *Journal) save() error {
+	return withStore(j.path, func(s *state.Store) error {
+		return s.Put(state.BucketJournals, recordKey(j.record.Operation, j.record.Key), j.record)
+	})
+}
+
+// withStore opens the state at path for the duration of fn, since it's locked while it's open.
+func withStore(path string, fn func(s *state.Store) error) error {
+	s, err := state.Open(path)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return fn(s)
+}
@@ -0,0 +1,64 @@
+package journal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	j, interrupted, err := Begin(path, "debug create-sysdiagnose", "/tmp", "run-1", false)
+	require.NoError(t, err)
+	assert.Nil(t, interrupted)
+	assert.False(t, j.Resumed())
+	j.Set("archive", "/tmp/sysdiagnose.tar.gz")
+	require.NoError(t, j.Complete("collect"))
+
+	// Interrupted before the upload completed.
+	j, interrupted, err = Begin(path, "debug create-sysdiagnose", "/tmp", "run-2", true)
+	require.NoError(t, err)
+	assert.Nil(t, interrupted)
+	assert.True(t, j.Resumed())
+	assert.True(t, j.Completed("collect"))
+	assert.False(t, j.Completed("upload"))
+	assert.Equal(t, "/tmp/sysdiagnose.tar.gz", j.Value("archive"))
+	assert.Equal(t, "run-2", j.Record().RunID)
+
+	// Journals of other keys are separate.
+	other, _, err := Begin(path, "debug create-sysdiagnose", "/var/tmp", "run-3", true)
+	require.NoError(t, err)
+	assert.False(t, other.Resumed())
+
+	require.NoError(t, j.Complete("upload"))
+	require.NoError(t, j.Finish())
+	j, interrupted, err = Begin(path, "debug create-sysdiagnose", "/tmp", "run-4", true)
+	require.NoError(t, err)
+	assert.Nil(t, interrupted)
+	assert.False(t, j.Resumed(), "finished operations shouldn't be resumed")
+}
+
+func TestJournal_StartOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	j, _, err := Begin(path, "security harden", "cis-level1", "run-1", false)
+	require.NoError(t, err)
+	require.NoError(t, j.Complete("firewall"))
+
+	j, interrupted, err := Begin(path, "security harden", "cis-level1", "run-2", false)
+	require.NoError(t, err)
+	if assert.NotNil(t, interrupted, "the interrupted run should be reported") {
+		assert.Equal(t, []string{"firewall"}, interrupted.Completed)
+		assert.Equal(t, "run-1", interrupted.RunID)
+	}
+	assert.False(t, j.Completed("firewall"), "starting over should discard the completed steps")
+
+	// The discarded journal is replaced, so it can't be resumed later.
+	j, _, err = Begin(path, "security harden", "cis-level1", "run-3", true)
+	require.NoError(t, err)
+	assert.True(t, j.Resumed())
+	assert.Empty(t, j.Record().Completed)
+}
@@ -0,0 +1,224 @@
+// Package jsonschema provides the functionality necessary for describing the JSON encoding of Go values as JSON Schema
+// documents, and for checking that a newer document remains compatible with an older one.
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect of the documents.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema, limited to the keywords needed to describe the encoding of Go values.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is the JSON types the value may have, e.g. "object", or "array" and "null" for a nil slice. No types
+	// allow any value.
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+// Types are the JSON types of a schema, encoded as a single string when there's one.
+type Types []string
+
+// MarshalJSON encodes a single type as a string and several as an array.
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON decodes a type given as a string or an array.
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// For returns the schema of the JSON encoding of values of v's type by encoding/json.
+func For(v any) *Schema {
+	return reflectType(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+// reflectType returns the schema of the encoding of t. Types that contain themselves are described as any value
+// where they recur.
+func reflectType(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(jsonMarshalerType), t.Implements(jsonMarshalerType):
+		// Custom encodings can't be described.
+		return &Schema{}
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(textMarshalerType), t.Implements(textMarshalerType):
+		return &Schema{Type: Types{"string"}}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Pointer:
+		return nullable(reflectType(t.Elem(), visiting))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: Types{"string", "null"}, Format: "byte"}
+		}
+		return &Schema{Type: Types{"array", "null"}, Items: reflectType(t.Elem(), visiting)}
+	case reflect.Array:
+		return &Schema{Type: Types{"array"}, Items: reflectType(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: Types{"object", "null"}, AdditionalProperties: reflectType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return &Schema{}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		s := &Schema{Type: Types{"object"}, Properties: map[string]*Schema{}}
+		addFields(s, t, visiting)
+		return s
+	default:
+		// Interfaces may hold any value.
+		return &Schema{}
+	}
+}
+
+// addFields adds the properties of the exported fields of the struct type t to s, including those of embedded
+// structs, as encoding/json does.
+func addFields(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(s, ft, visiting)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		property := reflectType(ft, visiting)
+		if slices.Contains(strings.Split(opts, ","), "string") && len(property.Type) == 1 {
+			property = &Schema{Type: Types{"string"}}
+		}
+		s.Properties[name] = property
+		if !slices.Contains(strings.Split(opts, ","), "omitempty") && !slices.Contains(strings.Split(opts, ","), "omitzero") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	slices.Sort(s.Required)
+}
+
+// nullable returns s allowing null as well, which is how nil pointers are encoded.
+func nullable(s *Schema) *Schema {
+	if len(s.Type) == 0 || slices.Contains(s.Type, "null") {
+		return s
+	}
+	s.Type = append(slices.Clone(s.Type), "null")
+
+	return s
+}
+
+// Compatible checks that documents valid against newer are valid against older and that values of older still
+// meet newer, as far as consumers of older rely on: every property of older remains with a compatible schema,
+// properties older requires remain required, and types are only narrowed. Properties may be added. The problems are
+// reported by their JSON path.
+func Compatible(older, newer *Schema) error {
+	var problems []string
+	compatible("$", older, newer, &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("incompatible schema: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func compatible(path string, older, newer *Schema, problems *[]string) {
+	if older == nil || len(older.Type) == 0 {
+		// Consumers can't rely on anything of a value that may be anything.
+		return
+	}
+	if newer == nil {
+		*problems = append(*problems, path+" was removed")
+		return
+	}
+	if len(newer.Type) == 0 {
+		*problems = append(*problems, fmt.Sprintf("%s may no longer be %s", path, strings.Join(older.Type, " or ")))
+		return
+	}
+	for _, t := range newer.Type {
+		if !slices.Contains(older.Type, t) {
+			*problems = append(*problems, fmt.Sprintf("%s may now be %s", path, t))
+		}
+	}
+	if older.Format != "" && newer.Format != older.Format {
+		*problems = append(*problems, fmt.Sprintf("%s changed format from %s to %q", path, older.Format, newer.Format))
+	}
+
+	names := make([]string, 0, len(older.Properties))
+	for name := range older.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		compatible(path+"."+name, older.Properties[name], newer.Properties[name], problems)
+	}
+	for _, name := range older.Required {
+		if !slices.Contains(newer.Required, name) {
+			*problems = append(*problems, fmt.Sprintf("%s.%s is no longer required", path, name))
+		}
+	}
+	compatible(path+"[]", older.Items, newer.Items, problems)
+	compatible(path+"{}", older.AdditionalProperties, newer.AdditionalProperties, problems)
+}
@@ -0,0 +1,100 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type embedded struct {
+	ID string `json:"id"`
+}
+
+type sample struct {
+	embedded
+	Name     string            `json:"name"`
+	Count    int               `json:"count,omitempty"`
+	Ratio    float64           `json:"ratio"`
+	OK       bool              `json:"ok"`
+	Time     time.Time         `json:"time"`
+	Duration time.Duration     `json:"duration_ns"`
+	Address  net.IP            `json:"address"`
+	Tags     map[string]string `json:"tags"`
+	Items    []embedded        `json:"items"`
+	Parent   *sample           `json:"parent,omitempty"`
+	Raw      json.RawMessage   `json:"raw"`
+	Ignored  string            `json:"-"`
+	hidden   string
+}
+
+func TestFor(t *testing.T) {
+	s := For(sample{})
+
+	assert.Equal(t, Types{"object"}, s.Type)
+	assert.NotContains(t, s.Properties, "Ignored")
+	assert.NotContains(t, s.Properties, "hidden")
+	assert.Equal(t, Types{"string"}, s.Properties["id"].Type, "embedded fields should be flattened")
+	assert.Equal(t, Types{"integer"}, s.Properties["count"].Type)
+	assert.Equal(t, Types{"number"}, s.Properties["ratio"].Type)
+	assert.Equal(t, Types{"boolean"}, s.Properties["ok"].Type)
+	assert.Equal(t, &Schema{Type: Types{"string"}, Format: "date-time"}, s.Properties["time"])
+	assert.Equal(t, Types{"integer"}, s.Properties["duration_ns"].Type)
+	assert.Equal(t, Types{"string"}, s.Properties["address"].Type, "text marshalers should be strings")
+	assert.Equal(t, Types{"object", "null"}, s.Properties["tags"].Type)
+	assert.Equal(t, Types{"string"}, s.Properties["tags"].AdditionalProperties.Type)
+	assert.Equal(t, Types{"array", "null"}, s.Properties["items"].Type)
+	assert.Equal(t, Types{"object"}, s.Properties["items"].Items.Type)
+	assert.Empty(t, s.Properties["parent"].Type, "recursive types should be described as any value")
+	assert.Empty(t, s.Properties["raw"].Type)
+	assert.Equal(t, []string{"address", "duration_ns", "id", "items", "name", "ok", "ratio", "raw", "tags", "time"}, s.Required)
+}
+
+func TestTypes_JSON(t *testing.T) {
+	data, err := json.Marshal(&Schema{Type: Types{"string"}, Items: &Schema{Type: Types{"array", "null"}}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "string", "items": {"type": ["array", "null"]}}`, string(data))
+
+	var s Schema
+	require.NoError(t, json.Unmarshal(data, &s))
+	assert.Equal(t, Types{"string"}, s.Type)
+	assert.Equal(t, Types{"array", "null"}, s.Items.Type)
+}
+
+func TestCompatible(t *testing.T) {
+	type v1 struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	type added struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+		Extra string `json:"extra,omitempty"`
+	}
+	type removed struct {
+		Name string `json:"name"`
+	}
+	type retyped struct {
+		Name  string `json:"name"`
+		Count string `json:"count"`
+	}
+	type optional struct {
+		Name  string `json:"name"`
+		Count int    `json:"count,omitempty"`
+	}
+	type nullable struct {
+		Name  string `json:"name"`
+		Count *int   `json:"count"`
+	}
+
+	assert.NoError(t, Compatible(For(v1{}), For(v1{})))
+	assert.NoError(t, Compatible(For(v1{}), For(added{})), "properties should be addable")
+	assert.ErrorContains(t, Compatible(For(v1{}), For(removed{})), "$.count was removed")
+	assert.ErrorContains(t, Compatible(For(v1{}), For(retyped{})), "$.count may now be string")
+	assert.ErrorContains(t, Compatible(For(v1{}), For(optional{})), "$.count is no longer required")
+	assert.ErrorContains(t, Compatible(For(v1{}), For(nullable{})), "$.count may now be null")
+	assert.ErrorContains(t, Compatible(For([]v1{}), For([]removed{})), "$[].count was removed")
+}
@@ -0,0 +1,429 @@
+// Package launchd provides the functionality necessary for registering launchd daemons and agents: rendering their
+// property lists, loading and unloading them, and reading their status.
+package launchd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Kinds of launchd jobs.
+const (
+	// Daemon runs in the system domain, as root unless UserName is set.
+	Daemon = "daemon"
+	// Agent runs in the GUI domain of each user that logs in.
+	Agent = "agent"
+)
+
+// Directories where property lists of each kind are installed.
+const (
+	DaemonDir = "/Library/LaunchDaemons"
+	AgentDir  = "/Library/LaunchAgents"
+)
+
+// Session types that agents can be limited to.
+const (
+	// AquaSession is the GUI login session of a user.
+	AquaSession = "Aqua"
+	// BackgroundSession is the non-GUI session of a user, e.g. over SSH.
+	BackgroundSession = "Background"
+	// LoginWindowSession is the login window, before any user logs in.
+	LoginWindowSession = "LoginWindow"
+)
+
+// validLabel matches the labels accepted for jobs, which are also used as file names.
+var validLabel = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Spec describes a launchd job. The plist tags are the launchd.plist(5) keys.
+type Spec struct {
+	// Kind is Daemon or Agent.
+	Kind string `json:"kind" plist:"-"`
+	// Label uniquely identifies the job, e.g. com.example.ci-runner.
+	Label string `json:"label" plist:"Label"`
+	// ProgramArguments is the command to run, the first being an absolute path to the program.
+	ProgramArguments []string `json:"program_arguments" plist:"ProgramArguments"`
+	// RunAtLoad starts the job when it's loaded.
+	RunAtLoad bool `json:"run_at_load,omitempty" plist:"RunAtLoad,omitempty"`
+	// KeepAlive restarts the job whenever it exits.
+	KeepAlive bool `json:"keep_alive,omitempty" plist:"KeepAlive,omitempty"`
+	// StartInterval starts the job every this many seconds.
+	StartInterval int `json:"start_interval,omitempty" plist:"StartInterval,omitempty"`
+	// ThrottleInterval is the least number of seconds between starts.
+	ThrottleInterval int `json:"throttle_interval,omitempty" plist:"ThrottleInterval,omitempty"`
+	// WorkingDirectory is the directory the job runs in.
+	WorkingDirectory string `json:"working_directory,omitempty" plist:"WorkingDirectory,omitempty"`
+	// Environment sets environment variables of the job.
+	Environment map[string]string `json:"environment,omitempty" plist:"EnvironmentVariables,omitempty"`
+	// StdoutPath and StderrPath are files the job's output is appended to.
+	StdoutPath string `json:"stdout_path,omitempty" plist:"StandardOutPath,omitempty"`
+	StderrPath string `json:"stderr_path,omitempty" plist:"StandardErrorPath,omitempty"`
+	// UserName runs a daemon as this user instead of root.
+	UserName string `json:"user_name,omitempty" plist:"UserName,omitempty"`
+	// User installs an agent for this user only, in their ~/Library/LaunchAgents, instead of for every user.
+	User string `json:"user,omitempty" plist:"-"`
+	// SessionType limits an agent to a session type, e.g. AquaSession for agents that interact with the GUI.
+	SessionType string `json:"session_type,omitempty" plist:"LimitLoadToSessionType,omitempty"`
+}
+
+// ValidateLabel checks that label can identify a job.
+func ValidateLabel(label string) error {
+	if !validLabel.MatchString(label) {
+		return fmt.Errorf("invalid label %q", label)
+	}
+	if strings.HasPrefix(label, "com.apple.") {
+		return fmt.Errorf("label %q is reserved for Apple", label)
+	}
+
+	return nil
+}
+
+// Validate checks the spec before anything is installed.
+func (s Spec) Validate() error {
+	if err := ValidateLabel(s.Label); err != nil {
+		return err
+	}
+	if s.Kind != Daemon && s.Kind != Agent {
+		return fmt.Errorf("kind must be %s or %s, got %q", Daemon, Agent, s.Kind)
+	}
+	if len(s.ProgramArguments) == 0 || !filepath.IsAbs(s.ProgramArguments[0]) {
+		return errors.New("program arguments must start with an absolute path to the program")
+	}
+	if s.UserName != "" && s.Kind != Daemon {
+		return errors.New("user name can only be set for daemons")
+	}
+	if s.User != "" && s.Kind != Agent {
+		return errors.New("user can only be set for agents, set user name to run a daemon as a user")
+	}
+	switch s.SessionType {
+	case "":
+	case AquaSession, BackgroundSession, LoginWindowSession:
+		if s.Kind != Agent {
+			return errors.New("session type can only be set for agents")
+		}
+	default:
+		return fmt.Errorf("session type must be %s, %s, or %s, got %q", AquaSession, BackgroundSession, LoginWindowSession, s.SessionType)
+	}
+	if s.StartInterval < 0 || s.ThrottleInterval < 0 {
+		return errors.New("intervals cannot be negative")
+	}
+
+	return nil
+}
+
+// Render returns the XML property list of the spec.
+func Render(s Spec) ([]byte, error) {
+	data, err := plist.MarshalIndent(s, plist.XMLFormat, "\t")
+	if err != nil {
+		return nil, fmt.Errorf("render property list of %s: %w", s.Label, err)
+	}
+
+	return data, nil
+}
+
+// Path returns where the property list of the job with the kind and label is installed.
+func Path(kind, label string) string {
+	dir := DaemonDir
+	if kind == Agent {
+		dir = AgentDir
+	}
+
+	return filepath.Join(dir, label+".plist")
+}
+
+// UserAgentPath returns where the property list of the agent with the label is installed for the user with the home
+// directory.
+func UserAgentPath(home, label string) string {
+	return filepath.Join(home, "Library", "LaunchAgents", label+".plist")
+}
+
+// location is where a job is installed and loaded.
+type location struct {
+	// path is the job's property list.
+	path string
+	// home is the home directory of the user the agent is installed for, if any.
+	home string
+	// uid and gid own the property list.
+	uid, gid int
+	// domain is the launchd domain the job is loaded in, empty when there's none to load it in yet.
+	domain string
+}
+
+// locate returns where the job with the kind and label is installed and loaded. Agents of a single user are installed
+// in their home directory and loaded in their GUI domain if they're logged in; other agents are loaded in the GUI
+// domain of the console user.
+func locate(ctx context.Context, kind, username, label string) (location, error) {
+	if username == "" {
+		domain, _, err := domainFor(ctx, kind)
+		return location{path: Path(kind, label), domain: domain}, err
+	}
+
+	u, err := user.Lookup(username)
+	if err != nil {
+		return location{}, fmt.Errorf("cannot find user %q: %w", username, err)
+	}
+	loc := location{path: UserAgentPath(u.HomeDir, label), home: u.HomeDir}
+	if loc.uid, err = strconv.Atoi(u.Uid); err != nil {
+		return location{}, fmt.Errorf("parse %q uid: %w", username, err)
+	}
+	if loc.gid, err = strconv.Atoi(u.Gid); err != nil {
+		return location{}, fmt.Errorf("parse %q gid: %w", username, err)
+	}
+	// launchctl print fails for users without a GUI domain, i.e. who aren't logged in.
+	domain := "gui/" + u.Uid
+	if _, err := util.ExecuteCommand(ctx, []string{"launchctl", "print", domain}, "", nil, nil); err == nil {
+		loc.domain = domain
+	} else {
+		logrus.WithField("user", username).Debug("User isn't logged in, the agent will load at their next login")
+	}
+
+	return loc, nil
+}
+
+// Install writes the spec's property list and loads the job, replacing any job with the same label. Agents for every
+// user are only loaded for the user logged in on the console, if any; other users get them when they log in. Agents
+// of a single user are loaded if the user is logged in.
+func Install(ctx context.Context, s Spec) (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+	data, err := Render(s)
+	if err != nil {
+		return "", err
+	}
+
+	loc, err := locate(ctx, s.Kind, s.User, s.Label)
+	if err != nil {
+		return "", err
+	}
+	path := loc.path
+	if s.User != "" {
+		if err := writeUserAgent(loc, s.Label, data); err != nil {
+			return "", err
+		}
+	} else {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", fmt.Errorf("write %s: %w", path, err)
+		}
+		// launchd refuses property lists that are writable by anyone other than their owner.
+		if err := os.Chmod(path, 0644); err != nil {
+			return "", fmt.Errorf("set permissions of %s: %w", path, err)
+		}
+		if err := os.Chown(path, loc.uid, loc.gid); err != nil {
+			return "", fmt.Errorf("set owner of %s: %w", path, err)
+		}
+	}
+
+	if loc.domain == "" {
+		return path, nil
+	}
+	// The job is unloaded first so that changes to an installed job take effect.
+	_ = launchctl(ctx, "bootout", loc.domain+"/"+s.Label)
+	if err := launchctl(ctx, "bootstrap", loc.domain, path); err != nil {
+		return path, err
+	}
+
+	return path, nil
+}
+
+// writeUserAgent writes the property list of an agent installed for a single user, in their LaunchAgents directory.
+// The user controls their home directory and could replace the directories or the property list with symlinks or
+// links to other files, so nothing is followed: the directories are opened relative to the home directory without
+// following symlinks, and the property list is written to a new file that replaces the existing one.
+func writeUserAgent(loc location, label string, data []byte) error {
+	dirFd, err := unix.Open(loc.home, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", loc.home, err)
+	}
+	defer func() { _ = unix.Close(dirFd) }()
+
+	dir := loc.home
+	for _, name := range []string{"Library", "LaunchAgents"} {
+		dir = filepath.Join(dir, name)
+		err := unix.Mkdirat(dirFd, name, 0755)
+		if err != nil && !errors.Is(err, unix.EEXIST) {
+			return fmt.Errorf("create %s: %w", dir, err)
+		}
+		created := err == nil
+		fd, err := unix.Openat(dirFd, name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("open %s, which must be a directory and not a symlink: %w", dir, err)
+		}
+		_ = unix.Close(dirFd)
+		dirFd = fd
+		if created {
+			if err := unix.Fchown(dirFd, loc.uid, loc.gid); err != nil {
+				return fmt.Errorf("set owner of %s: %w", dir, err)
+			}
+		}
+	}
+
+	// The property list is written to a new file and renamed over the existing one rather than truncating it, which
+	// may be a hard link to another file.
+	name := label + ".plist"
+	tmp := fmt.Sprintf(".%s-%016x", name, rand.Uint64())
+	fd, err := unix.Openat(dirFd, tmp, unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0644)
+	if err != nil {
+		return fmt.Errorf("create temporary file in %s: %w", dir, err)
+	}
+	defer func() { _ = unix.Unlinkat(dirFd, tmp, 0) }()
+
+	f := os.NewFile(uintptr(fd), filepath.Join(dir, tmp))
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s: %w", f.Name(), err)
+	}
+	// launchd refuses property lists that are writable by anyone other than their owner, the agent's user.
+	if err := f.Chmod(0644); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("set permissions of %s: %w", f.Name(), err)
+	}
+	if err := f.Chown(loc.uid, loc.gid); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("set owner of %s: %w", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", f.Name(), err)
+	}
+	if err := unix.Renameat(dirFd, tmp, dirFd, name); err != nil {
+		return fmt.Errorf("replace %s: %w", loc.path, err)
+	}
+
+	return nil
+}
+
+// Remove unloads the job and deletes its property list. With a username, the agent installed for that user only is
+// removed.
+func Remove(ctx context.Context, kind, username, label string) error {
+	if err := ValidateLabel(label); err != nil {
+		return err
+	}
+	loc, err := locate(ctx, kind, username, label)
+	if err != nil {
+		return err
+	}
+	path := loc.path
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("no %s %s installed: %w", kind, label, err)
+	}
+
+	if loc.domain != "" {
+		if err := launchctl(ctx, "bootout", loc.domain+"/"+label); err != nil {
+			logrus.WithError(err).WithField("label", label).Debug("Job wasn't loaded")
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Status is the state of a job.
+type Status struct {
+	Label string `json:"label"`
+	// Installed reports whether the job's property list is installed.
+	Installed bool `json:"installed"`
+	// Loaded reports whether launchd has loaded the job.
+	Loaded bool `json:"loaded"`
+	// State is launchd's state of the job, e.g. "running" or "not running".
+	State string `json:"state,omitempty"`
+	// PID is the process ID of the running job.
+	PID int `json:"pid,omitempty"`
+	// LastExitCode is the exit code of the job's last run, as reported by launchd.
+	LastExitCode string `json:"last_exit_code,omitempty"`
+}
+
+// GetStatus returns the state of the job. Agents are looked up for the user logged in on the console or, with a
+// username, among the agents installed for that user only.
+func GetStatus(ctx context.Context, kind, username, label string) (Status, error) {
+	if err := ValidateLabel(label); err != nil {
+		return Status{}, err
+	}
+	loc, err := locate(ctx, kind, username, label)
+	if err != nil {
+		return Status{}, err
+	}
+	status := Status{Label: label}
+	if _, err := os.Stat(loc.path); err == nil {
+		status.Installed = true
+	}
+
+	if loc.domain == "" {
+		return status, nil
+	}
+	out, err := util.ExecuteCommand(ctx, []string{"launchctl", "print", loc.domain + "/" + label}, "", nil, nil)
+	if err != nil {
+		// launchctl print fails when the job isn't loaded.
+		return status, nil
+	}
+	status.Loaded = true
+	parsePrint(out.Stdout, &status)
+
+	return status, nil
+}
+
+// parsePrint fills the status from the top-level "key = value" lines of launchctl print.
+func parsePrint(output string, status *Status) {
+	for _, line := range strings.Split(output, "\n") {
+		// Top-level properties are indented by a single tab; nested ones by more.
+		if !strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "\t\t") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "state":
+			status.State = value
+		case "pid":
+			status.PID, _ = strconv.Atoi(value)
+		case "last exit code":
+			status.LastExitCode = value
+		}
+	}
+}
+
+// domainFor returns the launchd domain that jobs of the kind are loaded in, and whether there is one: agents are
+// loaded in the GUI domain of the console user, of which there's none when nobody is logged in.
+func domainFor(ctx context.Context, kind string) (string, bool, error) {
+	if kind != Agent {
+		return "system", true, nil
+	}
+
+	out, err := util.ExecuteCommand(ctx, []string{"stat", "-f", "%u", "/dev/console"}, "", nil, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("find console user: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	uid := strings.TrimSpace(out.Stdout)
+	if uid == "0" || uid == "" {
+		logrus.Debug("Nobody is logged in on the console, agents will load at the next login")
+		return "", false, nil
+	}
+
+	return "gui/" + uid, true, nil
+}
+
+// launchctl runs launchctl with the arguments.
+func launchctl(ctx context.Context, args ...string) error {
+	out, err := util.ExecuteCommand(ctx, append([]string{"launchctl"}, args...), "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %s: %w", strings.Join(args, " "), strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
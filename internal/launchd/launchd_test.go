@@ -0,0 +1,136 @@
+package launchd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"howett.net/plist"
+)
+
+func TestRender(t *testing.T) {
+	data, err := Render(Spec{
+		Kind:             Daemon,
+		Label:            "com.example.runner",
+		ProgramArguments: []string{"/usr/local/bin/runner", "--once"},
+		RunAtLoad:        true,
+		Environment:      map[string]string{"HOME": "/var/runner"},
+	})
+	assert.NoError(t, err)
+
+	var decoded map[string]interface{}
+	_, err = plist.Unmarshal(data, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Label":                "com.example.runner",
+		"ProgramArguments":     []interface{}{"/usr/local/bin/runner", "--once"},
+		"RunAtLoad":            true,
+		"EnvironmentVariables": map[string]interface{}{"HOME": "/var/runner"},
+	}, decoded, "unset keys and the kind shouldn't be rendered")
+}
+
+func TestSpec_Validate(t *testing.T) {
+	valid := Spec{Kind: Agent, Label: "com.example.agent", ProgramArguments: []string{"/bin/echo"}}
+	assert.NoError(t, valid.Validate())
+
+	userAgent := valid
+	userAgent.User = "ec2-user"
+	userAgent.SessionType = AquaSession
+	assert.NoError(t, userAgent.Validate())
+
+	for name, modify := range map[string]func(*Spec){
+		"kind":           func(s *Spec) { s.Kind = "service" },
+		"label":          func(s *Spec) { s.Label = "../evil" },
+		"apple label":    func(s *Spec) { s.Label = "com.apple.screensharing" },
+		"no program":     func(s *Spec) { s.ProgramArguments = nil },
+		"relative path":  func(s *Spec) { s.ProgramArguments = []string{"echo"} },
+		"agent user":     func(s *Spec) { s.UserName = "ci" },
+		"daemon user":    func(s *Spec) { s.Kind = Daemon; s.User = "ci" },
+		"session type":   func(s *Spec) { s.SessionType = "Desktop" },
+		"daemon session": func(s *Spec) { s.Kind = Daemon; s.SessionType = AquaSession },
+		"interval":       func(s *Spec) { s.StartInterval = -1 },
+	} {
+		s := valid
+		modify(&s)
+		assert.Error(t, s.Validate(), name)
+	}
+}
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, "/Library/LaunchDaemons/com.example.d.plist", Path(Daemon, "com.example.d"))
+	assert.Equal(t, "/Library/LaunchAgents/com.example.a.plist", Path(Agent, "com.example.a"))
+	assert.Equal(t, "/Users/ec2-user/Library/LaunchAgents/com.example.a.plist", UserAgentPath("/Users/ec2-user", "com.example.a"))
+}
+
+func TestRender_UserAgent(t *testing.T) {
+	data, err := Render(Spec{
+		Kind:             Agent,
+		Label:            "com.example.agent",
+		ProgramArguments: []string{"/usr/local/bin/agent"},
+		User:             "ec2-user",
+		SessionType:      AquaSession,
+	})
+	assert.NoError(t, err)
+
+	var decoded map[string]interface{}
+	_, err = plist.Unmarshal(data, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Label":                  "com.example.agent",
+		"ProgramArguments":       []interface{}{"/usr/local/bin/agent"},
+		"LimitLoadToSessionType": "Aqua",
+	}, decoded, "the user shouldn't be rendered")
+}
+
+func TestParsePrint(t *testing.T) {
+	var status Status
+	parsePrint(`system/com.example.runner = {
+	active count = 1
+	path = /Library/LaunchDaemons/com.example.runner.plist
+	state = running
+
+	program = /usr/local/bin/runner
+	pid = 4321
+	last exit code = (never exited)
+	environment = {
+		state = nested
+	}
+}
+`, &status)
+	assert.Equal(t, Status{State: "running", PID: 4321, LastExitCode: "(never exited)"}, status)
+}
+
+func TestWriteUserAgent(t *testing.T) {
+	home := t.TempDir()
+	loc := location{path: UserAgentPath(home, "com.example.agent"), home: home, uid: os.Getuid(), gid: os.Getgid()}
+
+	require.NoError(t, writeUserAgent(loc, "com.example.agent", []byte("v1")))
+	require.NoError(t, writeUserAgent(loc, "com.example.agent", []byte("v2")))
+	data, err := os.ReadFile(loc.path)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+	fi, err := os.Stat(loc.path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+
+	// A property list linked to another file replaces the link rather than writing through it.
+	other := filepath.Join(t.TempDir(), "other")
+	require.NoError(t, os.WriteFile(other, []byte("other"), 0600))
+	require.NoError(t, os.Remove(loc.path))
+	require.NoError(t, os.Link(other, loc.path))
+	require.NoError(t, writeUserAgent(loc, "com.example.agent", []byte("v3")))
+	data, err = os.ReadFile(other)
+	require.NoError(t, err)
+	assert.Equal(t, "other", string(data))
+
+	// A symlinked LaunchAgents directory isn't followed.
+	home = t.TempDir()
+	target := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(home, "Library"), 0755))
+	require.NoError(t, os.Symlink(target, filepath.Join(home, "Library", "LaunchAgents")))
+	loc = location{path: UserAgentPath(home, "com.example.agent"), home: home, uid: os.Getuid(), gid: os.Getgid()}
+	assert.Error(t, writeUserAgent(loc, "com.example.agent", []byte("v1")))
+	assert.NoFileExists(t, filepath.Join(target, "com.example.agent.plist"))
+}
@@ -0,0 +1,96 @@
+package locale
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"regexp"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// globalPreferences is the preferences domain whose settings apply to every user that doesn't set them.
+const globalPreferences = "/Library/Preferences/.GlobalPreferences"
+
+// localeExp matches locale identifiers such as en_US, fr_CA, or zh_Hans_CN.
+var localeExp = regexp.MustCompile(`^[a-z]{2,3}(?:_[A-Z][a-z]{3})?(?:_(?:[A-Z]{2}|\d{3}))?$`)
+
+// ValidateLocale checks that locale is a locale identifier such as en_US.
+func ValidateLocale(locale string) error {
+	if !localeExp.MatchString(locale) {
+		return fmt.Errorf("invalid locale %q, expected an identifier such as en_US", locale)
+	}
+
+	return nil
+}
+
+// language returns the preferred language of the locale, e.g. en-US for en_US.
+func language(locale string) string {
+	return strings.ReplaceAll(locale, "_", "-")
+}
+
+// localeArgs returns the arguments to defaults that set the locale and preferred language in the domain.
+func localeArgs(domain, locale string) [][]string {
+	return [][]string{
+		{"write", domain, "AppleLocale", "-string", locale},
+		{"write", domain, "AppleLanguages", "-array", language(locale)},
+	}
+}
+
+// Locale returns the system locale or, with a username, the user's.
+func Locale(ctx context.Context, username string) (string, error) {
+	domain := globalPreferences
+	if username != "" {
+		domain = "-g"
+	}
+	out, err := defaults(ctx, username, "read", domain, "AppleLocale")
+	if err != nil {
+		return "", fmt.Errorf("read locale: %s: %w", strings.TrimSpace(out), err)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// SetLocale sets the system locale, which applies to users that don't set their own, or with a username, the user's,
+// and verifies it. Users get the new locale at their next login.
+func SetLocale(ctx context.Context, username, locale string) error {
+	if err := ValidateLocale(locale); err != nil {
+		return err
+	}
+	domain := globalPreferences
+	if username != "" {
+		domain = "-g"
+	}
+	for _, args := range localeArgs(domain, locale) {
+		if out, err := defaults(ctx, username, args...); err != nil {
+			return fmt.Errorf("set locale: %s: %w", strings.TrimSpace(out), err)
+		}
+	}
+
+	current, err := Locale(ctx, username)
+	if err != nil {
+		return err
+	}
+	if current != locale {
+		return fmt.Errorf("locale is %s after setting it to %s", current, locale)
+	}
+
+	return nil
+}
+
+// defaults runs defaults, as the user if there's one so that their preferences are changed, and returns its combined
+// output.
+func defaults(ctx context.Context, username string, args ...string) (string, error) {
+	var env []string
+	if username != "" {
+		u, err := user.Lookup(username)
+		if err != nil {
+			return "", fmt.Errorf("cannot find user %q: %w", username, err)
+		}
+		env = []string{"HOME=" + u.HomeDir, "USER=" + username}
+	}
+	out, err := util.ExecuteCommand(ctx, append([]string{"defaults"}, args...), username, env, nil)
+
+	return out.Stdout + out.Stderr, err
+}
@@ -0,0 +1,49 @@
+package locale
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLocale(t *testing.T) {
+	for _, valid := range []string{"en_US", "fr_CA", "de", "zh_Hans_CN", "es_419"} {
+		assert.NoError(t, ValidateLocale(valid), valid)
+	}
+	for _, invalid := range []string{"", "en-US", "EN_us", "en_US.UTF-8", "english"} {
+		assert.Error(t, ValidateLocale(invalid), invalid)
+	}
+}
+
+func TestLocaleArgs(t *testing.T) {
+	assert.Equal(t, [][]string{
+		{"write", "-g", "AppleLocale", "-string", "fr_CA"},
+		{"write", "-g", "AppleLanguages", "-array", "fr-CA"},
+	}, localeArgs("-g", "fr_CA"))
+}
+
+func TestRegionTimeZone(t *testing.T) {
+	tz, err := RegionTimeZone("eu-west-3")
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Paris", tz)
+
+	_, err = RegionTimeZone("xx-nowhere-1")
+	assert.Error(t, err)
+}
+
+func TestValidateTimeZone(t *testing.T) {
+	assert.NoError(t, ValidateTimeZone("UTC"))
+	assert.NoError(t, ValidateTimeZone("America/New_York"))
+	for _, invalid := range []string{"", "Local", "Mars/Olympus_Mons", "../etc/passwd"} {
+		assert.Error(t, ValidateTimeZone(invalid), invalid)
+	}
+}
+
+func TestParseTimeZone(t *testing.T) {
+	tz, err := parseTimeZone("Time Zone: Europe/Paris\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Paris", tz)
+
+	_, err = parseTimeZone("You need administrator access to run this tool... exiting!")
+	assert.Error(t, err)
+}
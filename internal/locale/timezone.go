@@ -0,0 +1,114 @@
+// Package locale provides the functionality necessary for setting the system time zone and locale.
+package locale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// regionTimeZones maps AWS regions to the time zone of the region's location.
+var regionTimeZones = map[string]string{
+	"af-south-1":     "Africa/Johannesburg",
+	"ap-east-1":      "Asia/Hong_Kong",
+	"ap-northeast-1": "Asia/Tokyo",
+	"ap-northeast-2": "Asia/Seoul",
+	"ap-northeast-3": "Asia/Tokyo",
+	"ap-south-1":     "Asia/Kolkata",
+	"ap-south-2":     "Asia/Kolkata",
+	"ap-southeast-1": "Asia/Singapore",
+	"ap-southeast-2": "Australia/Sydney",
+	"ap-southeast-3": "Asia/Jakarta",
+	"ap-southeast-4": "Australia/Melbourne",
+	"ap-southeast-5": "Asia/Kuala_Lumpur",
+	"ap-southeast-7": "Asia/Bangkok",
+	"ca-central-1":   "America/Toronto",
+	"ca-west-1":      "America/Edmonton",
+	"cn-north-1":     "Asia/Shanghai",
+	"cn-northwest-1": "Asia/Shanghai",
+	"eu-central-1":   "Europe/Berlin",
+	"eu-central-2":   "Europe/Zurich",
+	"eu-north-1":     "Europe/Stockholm",
+	"eu-south-1":     "Europe/Rome",
+	"eu-south-2":     "Europe/Madrid",
+	"eu-west-1":      "Europe/Dublin",
+	"eu-west-2":      "Europe/London",
+	"eu-west-3":      "Europe/Paris",
+	"il-central-1":   "Asia/Jerusalem",
+	"me-central-1":   "Asia/Dubai",
+	"me-south-1":     "Asia/Bahrain",
+	"mx-central-1":   "America/Mexico_City",
+	"sa-east-1":      "America/Sao_Paulo",
+	"us-east-1":      "America/New_York",
+	"us-east-2":      "America/New_York",
+	"us-gov-east-1":  "America/New_York",
+	"us-gov-west-1":  "America/Los_Angeles",
+	"us-west-1":      "America/Los_Angeles",
+	"us-west-2":      "America/Los_Angeles",
+}
+
+// RegionTimeZone returns the time zone of the region's location.
+func RegionTimeZone(region string) (string, error) {
+	tz, ok := regionTimeZones[region]
+	if !ok {
+		return "", fmt.Errorf("no known time zone for region %q, set one explicitly", region)
+	}
+
+	return tz, nil
+}
+
+// ValidateTimeZone checks that tz names a time zone of the tz database, e.g. Europe/Paris or UTC.
+func ValidateTimeZone(tz string) error {
+	if tz == "" || tz == "Local" {
+		return fmt.Errorf("invalid time zone %q", tz)
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("unknown time zone %q", tz)
+	}
+
+	return nil
+}
+
+// TimeZone returns the system time zone.
+func TimeZone(ctx context.Context) (string, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"systemsetup", "-gettimezone"}, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("get time zone: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseTimeZone(out.Stdout)
+}
+
+// parseTimeZone parses the output of systemsetup -gettimezone, e.g. "Time Zone: Europe/Paris".
+func parseTimeZone(output string) (string, error) {
+	_, tz, ok := strings.Cut(strings.TrimSpace(output), "Time Zone: ")
+	if !ok || tz == "" {
+		return "", fmt.Errorf("unexpected time zone output %q", strings.TrimSpace(output))
+	}
+
+	return tz, nil
+}
+
+// SetTimeZone sets the system time zone and verifies that it's in effect.
+func SetTimeZone(ctx context.Context, tz string) error {
+	if err := ValidateTimeZone(tz); err != nil {
+		return err
+	}
+	out, err := util.ExecuteCommand(ctx, []string{"systemsetup", "-settimezone", tz}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("set time zone: %s: %w", strings.TrimSpace(out.Stderr+" "+out.Stdout), err)
+	}
+
+	current, err := TimeZone(ctx)
+	if err != nil {
+		return err
+	}
+	if current != tz {
+		return fmt.Errorf("time zone is %s after setting it to %s", current, tz)
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+// Package localuser provides the functionality necessary for managing local user accounts with dscl, sysadminctl,
+// and dseditgroup.
+package localuser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// adminGroup is the group whose members are administrators.
+const adminGroup = "admin"
+
+// ErrNotFound is returned when the user doesn't exist.
+var ErrNotFound = errors.New("user not found")
+
+// validName matches the short names accepted for local users.
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// User describes a local user to create.
+type User struct {
+	// Name is the user's short name, e.g. "ci-runner".
+	Name string
+	// FullName is the user's full name. Empty uses the short name.
+	FullName string
+	// UID is the user's ID. Zero lets sysadminctl pick the next available ID.
+	UID int
+	// Shell is the path of the user's login shell. Empty uses the system default.
+	Shell string
+	// Home is the path of the user's home directory. Empty uses /Users/<name>.
+	Home string
+	// Admin adds the user to the admin group.
+	Admin bool
+	// Hidden hides the user from the login window and System Settings, e.g. for service accounts.
+	Hidden bool
+}
+
+// Modification describes changes to an existing user. Nil fields are left unchanged.
+type Modification struct {
+	FullName *string
+	Shell    *string
+	// Home changes the recorded home directory without moving its contents.
+	Home   *string
+	Admin  *bool
+	Hidden *bool
+}
+
+// ValidateName checks that name can be used as a short name.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid user name %q", name)
+	}
+
+	return nil
+}
+
+// validate checks the user's settings before anything is changed.
+func (u User) validate() error {
+	if err := ValidateName(u.Name); err != nil {
+		return err
+	}
+	if u.UID < 0 {
+		return fmt.Errorf("invalid UID %d", u.UID)
+	}
+
+	return validatePaths(u.Shell, u.Home)
+}
+
+// validatePaths checks that the shell and home, when set, are absolute paths.
+func validatePaths(shell, home string) error {
+	if shell != "" && !filepath.IsAbs(shell) {
+		return fmt.Errorf("shell must be an absolute path, got %q", shell)
+	}
+	if home != "" && !filepath.IsAbs(home) {
+		return fmt.Errorf("home directory must be an absolute path, got %q", home)
+	}
+
+	return nil
+}
+
+// Exists reports whether the user exists.
+func Exists(ctx context.Context, name string) (bool, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"dscl", ".", "-read", "/Users/" + name, "RecordName"}, "", nil, nil)
+	if err != nil {
+		if strings.Contains(out.Stdout+out.Stderr, "eDSRecordNotFound") {
+			return false, nil
+		}
+		return false, fmt.Errorf("read user %s: %s: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return true, nil
+}
+
+// Create creates the user and their home directory. Password login is disabled, so they can only log in with SSH keys
+// until a password is set.
+func Create(ctx context.Context, u User) error {
+	if err := u.validate(); err != nil {
+		return err
+	}
+	exists, err := Exists(ctx, u.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("user %s already exists", u.Name)
+	}
+
+	return run(ctx, createCommands(u))
+}
+
+// createCommands returns the commands that create the user.
+func createCommands(u User) [][]string {
+	add := []string{"sysadminctl", "-addUser", u.Name}
+	if u.FullName != "" {
+		add = append(add, "-fullName", u.FullName)
+	}
+	if u.UID != 0 {
+		add = append(add, "-UID", strconv.Itoa(u.UID))
+	}
+	if u.Shell != "" {
+		add = append(add, "-shell", u.Shell)
+	}
+	if u.Home != "" {
+		add = append(add, "-home", u.Home)
+	}
+	if u.Admin {
+		add = append(add, "-admin")
+	}
+
+	// sysadminctl gives users created without a password an empty one, which is replaced by the "*" of accounts without
+	// a password, such as the system's service accounts, so that nobody can log in as the user with a password.
+	cmds := [][]string{
+		add,
+		{"dscl", ".", "-delete", "/Users/" + u.Name, "AuthenticationAuthority"},
+		{"dscl", ".", "-create", "/Users/" + u.Name, "Password", "*"},
+	}
+	if u.Hidden {
+		cmds = append(cmds, hiddenCommand(u.Name, true))
+	}
+
+	return append(cmds, []string{"createhomedir", "-c", "-u", u.Name})
+}
+
+// Delete deletes the user, and their home directory unless keepHome is set.
+func Delete(ctx context.Context, name string, keepHome bool) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if name == "root" {
+		return errors.New("refusing to delete root")
+	}
+	if err := assertExists(ctx, name); err != nil {
+		return err
+	}
+
+	return run(ctx, [][]string{deleteCommand(name, keepHome)})
+}
+
+// deleteCommand returns the command that deletes the user.
+func deleteCommand(name string, keepHome bool) []string {
+	cmd := []string{"sysadminctl", "-deleteUser", name}
+	if keepHome {
+		cmd = append(cmd, "-keepHome")
+	}
+
+	return cmd
+}
+
+// Modify applies the modification to the user.
+func Modify(ctx context.Context, name string, m Modification) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	var shell, home string
+	if m.Shell != nil {
+		if shell = *m.Shell; shell == "" {
+			return errors.New("shell cannot be empty")
+		}
+	}
+	if m.Home != nil {
+		if home = *m.Home; home == "" {
+			return errors.New("home directory cannot be empty")
+		}
+	}
+	if err := validatePaths(shell, home); err != nil {
+		return err
+	}
+	if err := assertExists(ctx, name); err != nil {
+		return err
+	}
+
+	return run(ctx, modifyCommands(name, m))
+}
+
+// modifyCommands returns the commands that apply the modification.
+func modifyCommands(name string, m Modification) [][]string {
+	var cmds [][]string
+	set := func(attribute, value string) {
+		cmds = append(cmds, []string{"dscl", ".", "-create", "/Users/" + name, attribute, value})
+	}
+	if m.FullName != nil {
+		set("RealName", *m.FullName)
+	}
+	if m.Shell != nil {
+		set("UserShell", *m.Shell)
+	}
+	if m.Home != nil {
+		set("NFSHomeDirectory", *m.Home)
+	}
+	if m.Hidden != nil {
+		cmds = append(cmds, hiddenCommand(name, *m.Hidden))
+	}
+	if m.Admin != nil {
+		op := "-d"
+		if *m.Admin {
+			op = "-a"
+		}
+		cmds = append(cmds, []string{"dseditgroup", "-o", "edit", op, name, "-t", "user", adminGroup})
+	}
+
+	return cmds
+}
+
+// hiddenCommand returns the command that hides or shows the user.
+func hiddenCommand(name string, hidden bool) []string {
+	value := "0"
+	if hidden {
+		value = "1"
+	}
+
+	return []string{"dscl", ".", "-create", "/Users/" + name, "IsHidden", value}
+}
+
+// userExists reports whether the user exists, replaced in tests.
+var userExists = Exists
+
+// assertExists returns an error wrapping ErrNotFound when the user doesn't exist.
+func assertExists(ctx context.Context, name string) error {
+	exists, err := userExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	return nil
+}
+
+// run runs the commands in order, stopping at the first failure.
+func run(ctx context.Context, cmds [][]string) error {
+	for _, c := range cmds {
+		logrus.WithField("command", c).Debug("Running user management command")
+		out, err := util.ExecuteCommand(ctx, c, "", nil, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", c[0], strings.TrimSpace(out.Stderr), err)
+		}
+	}
+
+	return nil
+}
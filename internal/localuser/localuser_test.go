@@ -0,0 +1,81 @@
+package localuser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateName(t *testing.T) {
+	assert.NoError(t, ValidateName("ci-runner"))
+	assert.NoError(t, ValidateName("_buildsvc"))
+	assert.Error(t, ValidateName(""))
+	assert.Error(t, ValidateName("-rf"))
+	assert.Error(t, ValidateName("ci runner"))
+	assert.Error(t, ValidateName("../root"))
+}
+
+func TestCreateCommands(t *testing.T) {
+	assert.Equal(t, [][]string{
+		{"sysadminctl", "-addUser", "ci", "-fullName", "CI Runner", "-UID", "450", "-shell", "/bin/zsh", "-home", "/var/ci", "-admin"},
+		{"dscl", ".", "-delete", "/Users/ci", "AuthenticationAuthority"},
+		{"dscl", ".", "-create", "/Users/ci", "Password", "*"},
+		{"dscl", ".", "-create", "/Users/ci", "IsHidden", "1"},
+		{"createhomedir", "-c", "-u", "ci"},
+	}, createCommands(User{Name: "ci", FullName: "CI Runner", UID: 450, Shell: "/bin/zsh", Home: "/var/ci", Admin: true, Hidden: true}))
+
+	assert.Equal(t, [][]string{
+		{"sysadminctl", "-addUser", "ci"},
+		{"dscl", ".", "-delete", "/Users/ci", "AuthenticationAuthority"},
+		{"dscl", ".", "-create", "/Users/ci", "Password", "*"},
+		{"createhomedir", "-c", "-u", "ci"},
+	}, createCommands(User{Name: "ci"}))
+}
+
+func TestUser_Validate(t *testing.T) {
+	assert.NoError(t, User{Name: "ci", Shell: "/bin/zsh"}.validate())
+	assert.Error(t, User{Name: "ci", Shell: "zsh"}.validate())
+	assert.Error(t, User{Name: "ci", Home: "ci"}.validate())
+	assert.Error(t, User{Name: "ci", UID: -1}.validate())
+}
+
+func TestDeleteCommand(t *testing.T) {
+	assert.Equal(t, []string{"sysadminctl", "-deleteUser", "ci"}, deleteCommand("ci", false))
+	assert.Equal(t, []string{"sysadminctl", "-deleteUser", "ci", "-keepHome"}, deleteCommand("ci", true))
+}
+
+func TestModifyCommands(t *testing.T) {
+	shell, admin, hidden := "/bin/bash", false, false
+	assert.Equal(t, [][]string{
+		{"dscl", ".", "-create", "/Users/ci", "UserShell", "/bin/bash"},
+		{"dscl", ".", "-create", "/Users/ci", "IsHidden", "0"},
+		{"dseditgroup", "-o", "edit", "-d", "ci", "-t", "user", "admin"},
+	}, modifyCommands("ci", Modification{Shell: &shell, Admin: &admin, Hidden: &hidden}))
+
+	assert.Empty(t, modifyCommands("ci", Modification{}))
+}
+
+func TestGeneratePassword(t *testing.T) {
+	password, err := GeneratePassword(24)
+	assert.NoError(t, err)
+	assert.Len(t, password, 24)
+	assert.NoError(t, ValidatePassword(password))
+
+	other, err := GeneratePassword(24)
+	assert.NoError(t, err)
+	assert.NotEqual(t, password, other)
+
+	_, err = GeneratePassword(MinPasswordLength - 1)
+	assert.Error(t, err)
+}
+
+func TestValidatePassword(t *testing.T) {
+	assert.NoError(t, ValidatePassword("correct horse battery staple"))
+	assert.Error(t, ValidatePassword(""))
+	assert.Error(t, ValidatePassword("two\nlines"))
+}
+
+func TestPasswdScript(t *testing.T) {
+	assert.Equal(t, "passwd /Users/ci s3cret\nquit\n", passwdScript("ci", "s3cret"))
+	assert.Equal(t, `passwd /Users/ci a\ b\"c\\d`+"\nquit\n", passwdScript("ci", `a b"c\d`))
+}
@@ -0,0 +1,96 @@
+package localuser
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// MinPasswordLength is the shortest password that Generate creates.
+const MinPasswordLength = 12
+
+// passwordAlphabet is the characters of generated passwords. Characters that are hard to type on the serial console
+// or in a VNC client, or that shells treat specially, are left out.
+const passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789-_.+=%@"
+
+// GeneratePassword returns a random password of the length.
+func GeneratePassword(length int) (string, error) {
+	if length < MinPasswordLength {
+		return "", fmt.Errorf("password length must be at least %d", MinPasswordLength)
+	}
+
+	var b strings.Builder
+	limit := big.NewInt(int64(len(passwordAlphabet)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", fmt.Errorf("generate password: %w", err)
+		}
+		b.WriteByte(passwordAlphabet[n.Int64()])
+	}
+
+	return b.String(), nil
+}
+
+// ValidatePassword checks that password can be set.
+func ValidatePassword(password string) error {
+	if password == "" {
+		return errors.New("password cannot be empty")
+	}
+	if strings.ContainsAny(password, "\n\r\x00") {
+		return errors.New("password cannot contain line breaks or NUL characters")
+	}
+
+	return nil
+}
+
+// SetPassword sets the user's password. The password is passed to dscl on its standard input rather than as an
+// argument so that it doesn't appear in the process list. The user's login keychain isn't updated, so it keeps the
+// previous password.
+func SetPassword(ctx context.Context, name, password string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	if err := assertExists(ctx, name); err != nil {
+		return err
+	}
+
+	stdin := io.NopCloser(strings.NewReader(passwdScript(name, password)))
+	out, err := util.ExecuteCommand(ctx, []string{"dscl", "."}, "", nil, stdin)
+	if err != nil {
+		return fmt.Errorf("set password of %s: %s: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+	// dscl's interactive mode exits successfully even when a command fails, reporting the failure in its output.
+	if output := out.Stdout + out.Stderr; strings.Contains(output, "DS Error") {
+		return fmt.Errorf("set password of %s: %s", name, strings.TrimSpace(output))
+	}
+
+	return nil
+}
+
+// passwdScript returns the dscl interactive commands that set the user's password.
+func passwdScript(name, password string) string {
+	return fmt.Sprintf("passwd /Users/%s %s\nquit\n", name, dsclQuote(password))
+}
+
+// dsclQuote escapes s as a single argument of a dscl interactive command.
+func dsclQuote(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(` \"'`, r) || r == '\t' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package localuser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+const (
+	// SudoersDir is the directory of sudoers drop-in files, which /etc/sudoers includes.
+	SudoersDir = "/etc/sudoers.d"
+	// sudoersPrefix prefixes the names of drop-in files written by ec2-macos-utils.
+	sudoersPrefix = "ec2-macos-utils-"
+	// sudoersHeader marks drop-in files written by ec2-macos-utils.
+	sudoersHeader = "# Managed by ec2-macos-utils, changes will be overwritten."
+)
+
+// sudoersAlias matches the names sudoers reads as the ALL reserved word or an alias (e.g. ADMINS) rather than a user,
+// so that a rule for them would grant more than one user.
+var sudoersAlias = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// SudoRule grants a user sudo rights.
+type SudoRule struct {
+	// User is the short name of the user.
+	User string
+	// NoPasswd lets the user run the commands without entering their password, e.g. for service accounts without
+	// one.
+	NoPasswd bool
+	// Commands are the absolute paths of the commands, with optional arguments, the user may run. Empty allows
+	// every command.
+	Commands []string
+}
+
+// Validate checks that the rule can be written safely.
+func (r SudoRule) Validate() error {
+	if err := ValidateName(r.User); err != nil {
+		return err
+	}
+	if sudoersAlias.MatchString(r.User) {
+		return fmt.Errorf("user name %q is reserved in sudoers for ALL or an alias", r.User)
+	}
+	for _, c := range r.Commands {
+		if !filepath.IsAbs(c) {
+			return fmt.Errorf("command must be an absolute path, got %q", c)
+		}
+		if strings.ContainsAny(c, "\n\r") {
+			return fmt.Errorf("command cannot span lines: %q", c)
+		}
+	}
+
+	return nil
+}
+
+// Render returns the drop-in file contents, see sudoers(5).
+func (r SudoRule) Render() string {
+	tag := ""
+	if r.NoPasswd {
+		tag = "NOPASSWD: "
+	}
+	commands := "ALL"
+	if len(r.Commands) > 0 {
+		escaped := make([]string, len(r.Commands))
+		for i, c := range r.Commands {
+			escaped[i] = escapeSudoers(c)
+		}
+		commands = strings.Join(escaped, ", ")
+	}
+
+	return fmt.Sprintf("%s\n%s ALL=(ALL) %s%s\n", sudoersHeader, r.User, tag, commands)
+}
+
+// escapeSudoers escapes the characters that are special in sudoers commands.
+func escapeSudoers(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`, `:`, `\:`, `=`, `\=`).Replace(s)
+}
+
+// SudoersPath returns the path of the user's drop-in file. sudo ignores files whose name contains a dot, which
+// user names may, so dots are replaced.
+func SudoersPath(user string) string {
+	return filepath.Join(SudoersDir, sudoersPrefix+strings.ReplaceAll(user, ".", "_"))
+}
+
+// GrantSudo writes the rule to the user's drop-in file, replacing any previous rule. The user must exist, so that a
+// mistyped name doesn't grant rights to whoever later takes it. The file is checked with visudo before it's installed,
+// and the complete configuration after, so that a malformed rule can't lock administrators out of sudo.
+func GrantSudo(ctx context.Context, rule SudoRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if err := assertExists(ctx, rule.User); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(SudoersDir, 0755); err != nil {
+		return err
+	}
+
+	// The temporary file's name has a dot so that sudo ignores it.
+	tmp, err := os.CreateTemp(SudoersDir, ".ec2-macos-utils-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.WriteString(rule.Render()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0440); err != nil {
+		return err
+	}
+	if err := visudo(ctx, "-c", "-f", tmp.Name()); err != nil {
+		return err
+	}
+
+	path := SudoersPath(rule.User)
+	previous, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if err := visudo(ctx, "-c"); err != nil {
+		// Restore the previous state so that sudo keeps working.
+		if previous != nil {
+			_ = os.WriteFile(path, previous, 0440)
+		} else {
+			_ = os.Remove(path)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RevokeSudo removes the user's drop-in file. It's not an error if there is none.
+func RevokeSudo(user string) error {
+	if err := ValidateName(user); err != nil {
+		return err
+	}
+	path := SudoersPath(user)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(string(data), sudoersHeader) {
+		return fmt.Errorf("%s is not managed by ec2-macos-utils", path)
+	}
+
+	return os.Remove(path)
+}
+
+// visudo runs visudo with the arguments to check sudoers syntax.
+func visudo(ctx context.Context, args ...string) error {
+	out, err := util.ExecuteCommand(ctx, append([]string{"visudo"}, args...), "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("sudoers syntax check failed: %s: %w", strings.TrimSpace(out.Stderr+out.Stdout), err)
+	}
+
+	return nil
+}
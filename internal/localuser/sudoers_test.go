@@ -0,0 +1,38 @@
+package localuser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSudoRule_Render(t *testing.T) {
+	assert.Equal(t, sudoersHeader+"\nci-runner ALL=(ALL) ALL\n", SudoRule{User: "ci-runner"}.Render())
+	assert.Equal(t,
+		sudoersHeader+"\nci-runner ALL=(ALL) NOPASSWD: /usr/bin/xcodebuild, /sbin/shutdown -r now, /usr/bin/env FOO\\=a\\,b\n",
+		SudoRule{User: "ci-runner", NoPasswd: true, Commands: []string{"/usr/bin/xcodebuild", "/sbin/shutdown -r now", "/usr/bin/env FOO=a,b"}}.Render())
+}
+
+func TestSudoRule_Validate(t *testing.T) {
+	assert.NoError(t, SudoRule{User: "ci-runner", Commands: []string{"/usr/bin/true"}}.Validate())
+	assert.Error(t, SudoRule{User: "ci runner"}.Validate())
+	assert.Error(t, SudoRule{User: "ci-runner", Commands: []string{"xcodebuild"}}.Validate())
+	assert.Error(t, SudoRule{User: "ci-runner", Commands: []string{"/usr/bin/true\nALL ALL=(ALL) ALL"}}.Validate())
+
+	for _, reserved := range []string{"ALL", "ADMINS", "CI_RUNNERS2"} {
+		assert.Error(t, SudoRule{User: reserved}.Validate(), "%s would grant more than one user", reserved)
+	}
+	assert.NoError(t, SudoRule{User: "Admin"}.Validate())
+}
+
+func TestGrantSudo_MissingUser(t *testing.T) {
+	userExists = func(context.Context, string) (bool, error) { return false, nil }
+	t.Cleanup(func() { userExists = Exists })
+
+	assert.ErrorIs(t, GrantSudo(context.Background(), SudoRule{User: "ci-runer"}), ErrNotFound)
+}
+
+func TestSudoersPath(t *testing.T) {
+	assert.Equal(t, "/etc/sudoers.d/ec2-macos-utils-jane_doe", SudoersPath("jane.doe"))
+}
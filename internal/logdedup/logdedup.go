@@ -0,0 +1,171 @@
+// Package logdedup provides the functionality necessary for keeping the logs of long-running commands, such as
+// watchdogs, readable and small by collapsing identical repeated entries into periodic summaries with counts.
+package logdedup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RepeatedField is the name of the log field of a summary that carries the number of entries it collapsed.
+const RepeatedField = "repeated"
+
+// Formatter is a logrus.Formatter that writes the first of identical entries and collapses the ones repeated within
+// Window of it into a summary, written once the window ends, with the number of entries it collapsed.
+//
+// Entries are identical when they have the same level, message, and string or error fields, e.g. the check a
+// message is about. Other fields, such as durations and counts, usually differ between repeats and are disregarded,
+// so a summary has the fields of the first entry. Summaries are written before the next entry formatted after their
+// window ends, or by Flush.
+type Formatter struct {
+	// Formatter formats the entries that are written and the summaries.
+	logrus.Formatter
+	// Window is how long identical entries are collapsed for after the first one.
+	Window time.Duration
+
+	// now returns the current time, and is replaced in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	repeats map[string]*repeat
+}
+
+// repeat tracks the entries identical to first within the window.
+type repeat struct {
+	first      logrus.Entry
+	since      time.Time
+	suppressed int
+}
+
+// New returns a Formatter that collapses the entries repeated within window, formatting the others with formatter.
+func New(formatter logrus.Formatter, window time.Duration) *Formatter {
+	return &Formatter{Formatter: formatter, Window: window}
+}
+
+// Format formats the entry unless it repeats an entry written within the window, preceded by the summaries of
+// windows that ended. Nothing is written for a collapsed entry when no window ended.
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.clock()
+	out, err := f.summarize(func(r *repeat) bool { return now.Sub(r.since) >= f.Window })
+	if err != nil {
+		return nil, err
+	}
+
+	k := key(entry)
+	if r, ok := f.repeats[k]; ok {
+		r.suppressed++
+		return out, nil
+	}
+	if f.repeats == nil {
+		f.repeats = map[string]*repeat{}
+	}
+	// The entry's buffer is reused by the logger once it's written.
+	first := *entry
+	first.Buffer = nil
+	first.Data = make(logrus.Fields, len(entry.Data))
+	for name, value := range entry.Data {
+		first.Data[name] = value
+	}
+	f.repeats[k] = &repeat{first: first, since: now}
+
+	formatted, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, formatted...), nil
+}
+
+// Flush writes the summaries of every window to w, e.g. before the program exits, and forgets the entries written so
+// far.
+func (f *Formatter) Flush(w io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out, err := f.summarize(func(*repeat) bool { return true })
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+
+	return err
+}
+
+// summarize formats the summaries of the windows selected by ended, oldest first, and forgets them. Windows without
+// repeated entries are forgotten without a summary.
+func (f *Formatter) summarize(ended func(r *repeat) bool) ([]byte, error) {
+	var done []*repeat
+	for k, r := range f.repeats {
+		if ended(r) {
+			delete(f.repeats, k)
+			if r.suppressed > 0 {
+				done = append(done, r)
+			}
+		}
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i].since.Before(done[j].since) })
+
+	var out bytes.Buffer
+	for _, r := range done {
+		summary := r.first
+		summary.Time = f.clock()
+		summary.Data[RepeatedField] = r.suppressed
+		summary.Message = fmt.Sprintf("%s (repeated %d times since %s)", r.first.Message, r.suppressed, r.since.Format(time.RFC3339))
+		formatted, err := f.Formatter.Format(&summary)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(formatted)
+	}
+
+	return out.Bytes(), nil
+}
+
+func (f *Formatter) clock() time.Time {
+	if f.now != nil {
+		return f.now()
+	}
+
+	return time.Now()
+}
+
+// key identifies the entries identical to entry.
+func key(entry *logrus.Entry) string {
+	var b strings.Builder
+	b.WriteString(entry.Level.String())
+	b.WriteByte(0)
+	b.WriteString(entry.Message)
+
+	names := make([]string, 0, len(entry.Data))
+	for name := range entry.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var value string
+		switch v := entry.Data[name].(type) {
+		case string:
+			value = v
+		case error:
+			value = v.Error()
+		default:
+			continue
+		}
+		b.WriteByte(0)
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(value)
+	}
+
+	return b.String()
+}
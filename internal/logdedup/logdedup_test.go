@@ -0,0 +1,81 @@
+package logdedup
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLogger returns a logger collapsing entries repeated within window, whose clock is advanced with the returned
+// function.
+func testLogger(window time.Duration) (*logrus.Logger, *Formatter, *bytes.Buffer, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := New(&logrus.TextFormatter{DisableTimestamp: true}, window)
+	f.now = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buf
+	logger.Formatter = f
+
+	return logger, f, &buf, func(d time.Duration) { now = now.Add(d) }
+}
+
+func lines(buf *bytes.Buffer) []string {
+	return strings.Split(strings.TrimSpace(buf.String()), "\n")
+}
+
+func TestFormatter(t *testing.T) {
+	logger, _, buf, advance := testLogger(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		logger.WithField("check", "imds").Info("Check passed")
+		advance(5 * time.Minute)
+	}
+	logger.WithField("check", "time").Info("Check passed")
+	assert.Len(t, lines(buf), 2, "only the first of identical entries should be written")
+
+	advance(time.Hour)
+	logger.Info("Next")
+	out := lines(buf)
+	if assert.Len(t, out, 4) {
+		assert.Contains(t, out[2], "repeated 2 times")
+		assert.Contains(t, out[2], "check=imds")
+		assert.Contains(t, out[2], "repeated=2")
+		assert.Contains(t, out[3], "Next", "summaries should be written before the entry")
+	}
+}
+
+func TestFormatter_Distinct(t *testing.T) {
+	logger, _, buf, _ := testLogger(time.Hour)
+
+	logger.WithError(errors.New("timeout")).Warn("Check failed")
+	logger.WithError(errors.New("refused")).Warn("Check failed")
+	logger.WithError(errors.New("refused")).Error("Check failed")
+	logger.WithField("duration", time.Second).Warn("Slow")
+	logger.WithField("duration", 2*time.Second).Warn("Slow")
+
+	assert.Len(t, lines(buf), 4, "entries with different levels, messages, or errors should be written")
+}
+
+func TestFormatter_Flush(t *testing.T) {
+	logger, f, buf, _ := testLogger(time.Hour)
+
+	logger.Info("Check passed")
+	logger.Info("Check passed")
+	logger.Info("Once")
+
+	var flushed bytes.Buffer
+	assert.NoError(t, f.Flush(&flushed))
+	assert.Contains(t, flushed.String(), "repeated 1 times")
+	assert.NotContains(t, flushed.String(), "Once", "entries that weren't repeated shouldn't be summarized")
+
+	buf.Reset()
+	logger.Info("Check passed")
+	assert.Contains(t, buf.String(), "Check passed", "entries should be written again after a flush")
+}
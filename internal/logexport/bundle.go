@@ -0,0 +1,228 @@
+package logexport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/pkg/command"
+)
+
+const (
+	// DefaultCollectorTimeout bounds how long each collector of a bundle may run.
+	DefaultCollectorTimeout = 2 * time.Minute
+	// bundleConcurrency is the number of collectors that run at the same time.
+	bundleConcurrency = 4
+	// bundleSummaryName is the name of the file that summarizes the collectors' outcomes at the end of a bundle.
+	bundleSummaryName = "summary.json"
+)
+
+// Collector gathers one file of a bundle.
+type Collector struct {
+	// Name is the file's name in the bundle, e.g. "disks.txt".
+	Name string
+	// Timeout bounds how long the collector may run, after which it's abandoned. Zero uses DefaultCollectorTimeout.
+	Timeout time.Duration
+	// Collect writes the file to w. What was written is kept when it fails.
+	Collect func(ctx context.Context, w io.Writer) error
+}
+
+// CommandCollector returns a collector of the output of the command, run by command.Default.
+func CommandCollector(name string, argv ...string) Collector {
+	return Collector{
+		Name: name,
+		Collect: func(ctx context.Context, w io.Writer) error {
+			out, err := command.Default.Output(ctx, command.New(argv...))
+			if _, writeErr := io.WriteString(w, out.Stdout); writeErr != nil {
+				return writeErr
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", strings.Join(argv, " "), strings.TrimSpace(out.Stderr), err)
+			}
+			return nil
+		},
+	}
+}
+
+// BundleCollectors returns the collectors of a bundle: the unified log entries from the last period that match the
+// predicate, and the output of diagnostic tools that complete within seconds.
+func BundleCollectors(last time.Duration, predicate string) []Collector {
+	return []Collector{
+		{
+			Name: "unified.log",
+			Collect: func(ctx context.Context, w io.Writer) error {
+				return show(ctx, w, last, predicate)
+			},
+		},
+		CommandCollector("sw_vers.txt", "sw_vers"),
+		CommandCollector("hardware.txt", "system_profiler", "SPHardwareDataType", "SPSoftwareDataType"),
+		CommandCollector("disks.txt", "diskutil", "list"),
+		CommandCollector("df.txt", "df", "-h"),
+		CommandCollector("ifconfig.txt", "ifconfig", "-a"),
+		CommandCollector("routes.txt", "netstat", "-rn"),
+		CommandCollector("dns.txt", "scutil", "--dns"),
+		CommandCollector("launchctl.txt", "launchctl", "list"),
+		CommandCollector("processes.txt", "ps", "axo", "pid,ppid,user,%cpu,%mem,etime,command"),
+		CommandCollector("vm_stat.txt", "vm_stat"),
+	}
+}
+
+// CollectorResult is the outcome of a collector of a bundle.
+type CollectorResult struct {
+	Name     string        `json:"name"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration_ns"`
+	Error    string        `json:"error,omitempty"`
+	// TimedOut reports whether the collector was abandoned when its timeout elapsed. It's only in the bundle when
+	// it wrote something before.
+	TimedOut bool `json:"timed_out,omitempty"`
+}
+
+// collected is a file collected for a bundle, saved in a temporary file until it's written to the bundle.
+type collected struct {
+	CollectorResult
+	path string
+}
+
+// WriteBundle runs the collectors concurrently and writes the files they collect to w as a gzip-compressed tarball,
+// in the order they complete, followed by a summary.json of the outcome of each. Collectors are isolated from each
+// other: one that fails, or hangs until its timeout, is recorded in the summary without holding up or failing the
+// others, and what it wrote before is kept. It fails only when the bundle itself can't be written.
+func WriteBundle(ctx context.Context, w io.Writer, collectors []Collector) ([]CollectorResult, error) {
+	workDir, err := os.MkdirTemp("", "logexport-bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(workDir) }()
+
+	results := make(chan collected)
+	go func() {
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, bundleConcurrency)
+		for i, c := range collectors {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				results <- runCollector(ctx, c, filepath.Join(workDir, fmt.Sprintf("%d", i)))
+			}()
+		}
+		wg.Wait()
+		close(results)
+	}()
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	reporter := contextual.Progress(ctx)
+	var (
+		summary  []CollectorResult
+		writeErr error
+	)
+	// Results are drained even after a write fails so that the collectors can finish.
+	for r := range results {
+		summary = append(summary, r.CollectorResult)
+		reporter.Report("collect", float64(len(summary))/float64(len(collectors))*100, fmt.Sprintf("%d of %d collected", len(summary), len(collectors)))
+		if writeErr == nil && r.Bytes > 0 {
+			writeErr = writeBundleFile(tw, r.Name, r.path, r.Bytes)
+		}
+	}
+	if writeErr != nil {
+		return summary, fmt.Errorf("write bundle: %w", writeErr)
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return summary, err
+	}
+	if err := tw.WriteHeader(bundleHeader(bundleSummaryName, int64(len(data)))); err != nil {
+		return summary, fmt.Errorf("write bundle: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return summary, fmt.Errorf("write bundle: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return summary, err
+	}
+
+	return summary, zw.Close()
+}
+
+// runCollector runs the collector into a file at path and returns its outcome. A collector that doesn't return
+// within its timeout, e.g. a tool that doesn't exit when it's killed, is abandoned rather than waited for.
+func runCollector(ctx context.Context, c Collector, path string) collected {
+	result := collected{CollectorResult: CollectorResult{Name: c.Name}, path: path}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCollectorTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Collect(ctx, f)
+	}()
+	select {
+	case err = <-done:
+		_ = f.Close()
+	case <-ctx.Done():
+		// The abandoned collector keeps its file open, and whatever it writes from now on isn't read.
+		err, result.TimedOut = fmt.Errorf("abandoned after %s: %w", timeout, ctx.Err()), true
+	}
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		logrus.WithError(err).WithField("collector", c.Name).Warn("Diagnostic collector failed")
+	}
+	if fi, statErr := os.Stat(path); statErr == nil {
+		result.Bytes = fi.Size()
+	}
+
+	return result
+}
+
+// writeBundleFile writes size bytes of the file at path to the bundle under name.
+func writeBundleFile(tw *tar.Writer, name, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := tw.WriteHeader(bundleHeader(name, size)); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, size)
+
+	return err
+}
+
+// bundleHeader returns the header of a file of the bundle.
+func bundleHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0600,
+		ModTime:  time.Now(),
+	}
+}
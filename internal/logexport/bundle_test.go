@@ -0,0 +1,81 @@
+package logexport
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBundle(t *testing.T) {
+	hung := make(chan struct{})
+	t.Cleanup(func() { close(hung) })
+
+	collectors := []Collector{
+		{Name: "ok.txt", Collect: func(_ context.Context, w io.Writer) error {
+			_, err := io.WriteString(w, "fine")
+			return err
+		}},
+		{Name: "failed.txt", Collect: func(_ context.Context, w io.Writer) error {
+			_, _ = io.WriteString(w, "partial")
+			return errors.New("exit status 1")
+		}},
+		{Name: "hung.txt", Timeout: 50 * time.Millisecond, Collect: func(_ context.Context, w io.Writer) error {
+			_, _ = io.WriteString(w, "started")
+			// The collector ignores its context, like a tool that doesn't exit.
+			<-hung
+			return nil
+		}},
+		{Name: "empty.txt", Collect: func(context.Context, io.Writer) error { return nil }},
+	}
+
+	var buf bytes.Buffer
+	start := time.Now()
+	results, err := WriteBundle(context.Background(), &buf, collectors)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second, "a hung collector shouldn't hold up the bundle")
+	assert.Len(t, results, 4)
+
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(zr)
+	contents := map[string]string{}
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		contents[hdr.Name] = string(data)
+		names = append(names, hdr.Name)
+	}
+
+	assert.Equal(t, "fine", contents["ok.txt"])
+	assert.Equal(t, "partial", contents["failed.txt"], "what a failed collector wrote should be kept")
+	assert.Equal(t, "started", contents["hung.txt"])
+	assert.NotContains(t, contents, "empty.txt")
+	assert.Equal(t, bundleSummaryName, names[len(names)-1], "the summary should be last")
+
+	var summary []CollectorResult
+	require.NoError(t, json.Unmarshal([]byte(contents[bundleSummaryName]), &summary))
+	outcomes := map[string]CollectorResult{}
+	for _, r := range summary {
+		outcomes[r.Name] = r
+	}
+	assert.Empty(t, outcomes["ok.txt"].Error)
+	assert.Equal(t, int64(4), outcomes["ok.txt"].Bytes)
+	assert.Equal(t, "exit status 1", outcomes["failed.txt"].Error)
+	assert.True(t, outcomes["hung.txt"].TimedOut)
+	assert.Contains(t, outcomes["hung.txt"].Error, "abandoned after 50ms")
+}
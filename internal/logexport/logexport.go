@@ -0,0 +1,218 @@
+// Package logexport exports entries from the macOS unified log, alone or in a bundle with the output of quick
+// diagnostic tools, as a lighter alternative to sysdiagnose.
+package logexport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// logExecutable is the path to the macOS unified logging tool.
+const logExecutable = "/usr/bin/log"
+
+// Format is the format of an export.
+type Format string
+
+const (
+	// FormatText exports matching entries as gzip-compressed syslog-style text.
+	FormatText Format = "text"
+	// FormatArchive exports the whole unified log as a gzip-compressed tarball of a .logarchive bundle, which can be
+	// opened with Console or 'log show --archive'.
+	FormatArchive Format = "logarchive"
+	// FormatBundle exports matching entries as text along with the output of quick diagnostic tools, such as the
+	// disks, network configuration, and processes, as a gzip-compressed tarball.
+	FormatBundle Format = "bundle"
+)
+
+// Formats are the supported export formats.
+var Formats = []Format{FormatText, FormatArchive, FormatBundle}
+
+// Extension returns the file name extension of exports in the format.
+func (f Format) Extension() string {
+	switch f {
+	case FormatArchive:
+		return ".logarchive.tar.gz"
+	case FormatBundle:
+		return ".bundle.tar.gz"
+	default:
+		return ".log.gz"
+	}
+}
+
+// DefaultPredicate selects the entries most relevant to EC2 instances: the EC2 agents and tools, SSH, launchd
+// services, disk management, and software updates.
+const DefaultPredicate = `process IN {"ec2-macos-init", "ec2-macos-utils", "amazon-ssm-agent", "ssm-session-worker", "sshd", "diskmanagementd", "softwareupdated"} OR subsystem BEGINSWITH "com.amazon" OR (process == "launchd" AND messageType IN {error, fault})`
+
+// Show writes the entries from the last period that match the predicate to w as gzip-compressed text.
+func Show(ctx context.Context, w io.Writer, last time.Duration, predicate string) error {
+	zw := gzip.NewWriter(w)
+	if err := show(ctx, zw, last, predicate); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// show writes the entries from the last period that match the predicate to w as text.
+func show(ctx context.Context, w io.Writer, last time.Duration, predicate string) error {
+	args, err := showArgs(last, predicate)
+	if err != nil {
+		return err
+	}
+
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, logExecutable, args...)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	release, err := util.AcquireExec(ctx, cmd.Args)
+	if err != nil {
+		return fmt.Errorf("log show: %w", err)
+	}
+	defer release()
+	logrus.WithContext(ctx).WithField("command", cmd.String()).Debug("Running log show")
+	start := time.Now()
+	err = cmd.Run()
+	util.LogExec(cmd.Args, time.Since(start), err, "", stderr.String())
+	if err != nil {
+		return fmt.Errorf("log show: %s: %w", strings.TrimSpace(stderr.String()), util.NewExternalToolError(cmd.Args, err, stderr.String()))
+	}
+
+	return nil
+}
+
+// Collect writes a .logarchive bundle of the unified log from the last period to w as a gzip-compressed tarball.
+// Collection requires root privileges.
+func Collect(ctx context.Context, w io.Writer, last time.Duration, name string) error {
+	if filepath.Base(name) != filepath.Clean(name) || name == "" {
+		return errors.New("archive name must be a valid path basename without directory parts")
+	}
+
+	workDir, err := os.MkdirTemp("", "logexport-*")
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(workDir) }()
+
+	bundle := filepath.Join(workDir, name+".logarchive")
+	args, err := collectArgs(bundle, last)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, logExecutable, args...)
+	release, err := util.AcquireExec(ctx, cmd.Args)
+	if err != nil {
+		return fmt.Errorf("log collect: %w", err)
+	}
+	defer release()
+	logrus.WithContext(ctx).WithField("command", cmd.String()).Debug("Running log collect")
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	util.LogExec(cmd.Args, time.Since(start), err, string(out), "")
+	if err != nil {
+		return fmt.Errorf("log collect: %s: %w", strings.TrimSpace(string(out)), util.NewExternalToolError(cmd.Args, err, string(out)))
+	}
+
+	return writeTarball(w, workDir, filepath.Base(bundle))
+}
+
+// showArgs returns the arguments to log(1) to show the entries from the last period that match the predicate.
+func showArgs(last time.Duration, predicate string) ([]string, error) {
+	lastArg, err := lastArg(last)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"show", "--last", lastArg, "--style", "syslog", "--info"}
+	if predicate != "" {
+		args = append(args, "--predicate", predicate)
+	}
+
+	return args, nil
+}
+
+// collectArgs returns the arguments to log(1) to collect the unified log from the last period into the bundle.
+func collectArgs(bundle string, last time.Duration) ([]string, error) {
+	lastArg, err := lastArg(last)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{"collect", "--output", bundle, "--last", lastArg}, nil
+}
+
+// lastArg formats the period for log(1)'s --last option, which takes whole minutes, rounding up.
+func lastArg(last time.Duration) (string, error) {
+	if last <= 0 {
+		return "", fmt.Errorf("invalid period %v: must be positive", last)
+	}
+	minutes := (last + time.Minute - 1) / time.Minute
+
+	return fmt.Sprintf("%dm", minutes), nil
+}
+
+// writeTarball writes the tree rooted at dir/name to w as a gzip-compressed tarball with paths relative to dir.
+func writeTarball(w io.Writer, dir, name string) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	err := filepath.WalkDir(filepath.Join(dir, name), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", name, err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,92 @@
+package logexport
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastArg(t *testing.T) {
+	cases := []struct {
+		last     time.Duration
+		expected string
+	}{
+		{2 * time.Hour, "120m"},
+		{time.Minute, "1m"},
+		{90 * time.Second, "2m"},
+		{time.Second, "1m"},
+	}
+	for _, c := range cases {
+		actual, err := lastArg(c.last)
+		assert.NoError(t, err, c.last)
+		assert.Equal(t, c.expected, actual, c.last)
+	}
+
+	_, err := lastArg(0)
+	assert.Error(t, err)
+	_, err = lastArg(-time.Hour)
+	assert.Error(t, err)
+}
+
+func TestShowArgs(t *testing.T) {
+	args, err := showArgs(2*time.Hour, `process == "sshd"`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"show", "--last", "120m", "--style", "syslog", "--info", "--predicate", `process == "sshd"`}, args)
+
+	args, err = showArgs(time.Hour, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"show", "--last", "60m", "--style", "syslog", "--info"}, args)
+}
+
+func TestCollectArgs(t *testing.T) {
+	args, err := collectArgs("/tmp/x/logs.logarchive", 30*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"collect", "--output", "/tmp/x/logs.logarchive", "--last", "30m"}, args)
+}
+
+func TestWriteTarball(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "logs.logarchive")
+	require.NoError(t, os.MkdirAll(filepath.Join(bundle, "Persist"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "Info.plist"), []byte("plist"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "Persist", "0001.tracev3"), []byte("trace"), 0644))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeTarball(&buf, dir, "logs.logarchive"))
+
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(zr)
+	contents := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		contents[hdr.Name] = string(data)
+	}
+
+	assert.Equal(t, map[string]string{
+		"logs.logarchive/":                     "",
+		"logs.logarchive/Info.plist":           "plist",
+		"logs.logarchive/Persist/":             "",
+		"logs.logarchive/Persist/0001.tracev3": "trace",
+	}, contents)
+}
+
+func TestFormatExtension(t *testing.T) {
+	assert.Equal(t, ".log.gz", FormatText.Extension())
+	assert.Equal(t, ".logarchive.tar.gz", FormatArchive.Extension())
+	assert.Equal(t, ".bundle.tar.gz", FormatBundle.Extension())
+}
@@ -0,0 +1,130 @@
+// Package memory provides the functionality necessary for inspecting memory usage and purging the disk cache.
+package memory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// pageSizeExp matches the page size in the header of vm_stat output, e.g. "(page size of 16384 bytes)".
+var pageSizeExp = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// Stats is a snapshot of the host's memory usage.
+type Stats struct {
+	// Pressure is the percentage of memory that isn't available for use.
+	Pressure float64 `json:"pressure"`
+	// Free is the number of bytes of unused memory.
+	Free uint64 `json:"free"`
+	// Inactive is the number of bytes of memory that hasn't been used recently, such as the disk cache.
+	Inactive uint64 `json:"inactive"`
+	// Speculative is the number of bytes of memory read ahead from disk.
+	Speculative uint64 `json:"speculative"`
+	// Purgeable is the number of bytes of memory that applications marked as discardable.
+	Purgeable uint64 `json:"purgeable"`
+}
+
+// Pressure returns the host's memory pressure. The kernel reports the percentage of memory that is free for use, so
+// the pressure is its complement.
+func Pressure(_ context.Context) (float64, error) {
+	level, err := system.SysctlUint32("kern.memorystatus_level")
+	if err != nil {
+		return 0, fmt.Errorf("read memory status: %w", err)
+	}
+
+	return 100 - float64(level), nil
+}
+
+// GetStats takes a snapshot of the host's memory usage.
+func GetStats(ctx context.Context) (Stats, error) {
+	pressure, err := Pressure(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	out, err := util.ExecuteCommand(ctx, []string{"/usr/bin/vm_stat"}, "", nil, nil)
+	if err != nil {
+		return Stats{}, fmt.Errorf("read memory statistics: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	stats, err := parseVMStat(out.Stdout)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.Pressure = pressure
+
+	return stats, nil
+}
+
+// parseVMStat parses the page counts of vm_stat output into bytes.
+func parseVMStat(out string) (Stats, error) {
+	m := pageSizeExp.FindStringSubmatch(out)
+	if m == nil {
+		return Stats{}, fmt.Errorf("page size not found in vm_stat output")
+	}
+	pageSize, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return Stats{}, fmt.Errorf("parse page size %q: %w", m[1], err)
+	}
+
+	var stats Stats
+	fields := map[string]*uint64{
+		"Pages free":        &stats.Free,
+		"Pages inactive":    &stats.Inactive,
+		"Pages speculative": &stats.Speculative,
+		"Pages purgeable":   &stats.Purgeable,
+	}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		field, ok := fields[strings.TrimSpace(name)]
+		if !ok {
+			continue
+		}
+		pages, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), "."), 10, 64)
+		if err != nil {
+			return Stats{}, fmt.Errorf("parse %s %q: %w", strings.TrimSpace(name), strings.TrimSpace(value), err)
+		}
+		*field = pages * pageSize
+	}
+
+	return stats, nil
+}
+
+// PurgeResult reports the memory usage before and after purging.
+type PurgeResult struct {
+	Before Stats `json:"before"`
+	After  Stats `json:"after"`
+}
+
+// Purge flushes file system buffers to disk with sync and then empties the disk cache with purge, which frees the
+// memory it held. It's meant as a remediation for memory pressure, e.g. before collecting diagnostics, and doesn't
+// free memory that processes are using.
+func Purge(ctx context.Context) (PurgeResult, error) {
+	var result PurgeResult
+	var err error
+	if result.Before, err = GetStats(ctx); err != nil {
+		return result, err
+	}
+
+	// purge syncs too, but syncing first keeps dirty buffers from being written while the cache is emptied.
+	for _, args := range [][]string{{"/bin/sync"}, {"/usr/sbin/purge"}} {
+		if out, err := util.ExecuteCommand(ctx, args, "", nil, nil); err != nil {
+			return result, fmt.Errorf("%s: %s: %w", args[0], strings.TrimSpace(out.Stderr), err)
+		}
+	}
+
+	if result.After, err = GetStats(ctx); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVMStat(t *testing.T) {
+	stats, err := parseVMStat(`Mach Virtual Memory Statistics: (page size of 16384 bytes)
+Pages free:                                3000.
+Pages active:                            200000.
+Pages inactive:                          150000.
+Pages speculative:                         2000.
+Pages throttled:                              0.
+Pages wired down:                         90000.
+Pages purgeable:                            500.
+"Translation faults":                 123456789.
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{
+		Free:        3000 * 16384,
+		Inactive:    150000 * 16384,
+		Speculative: 2000 * 16384,
+		Purgeable:   500 * 16384,
+	}, stats)
+
+	_, err = parseVMStat("vm_stat: command not found")
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+// Package metrics provides the functionality necessary for publishing host metrics to Amazon CloudWatch.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/sirupsen/logrus"
+)
+
+// maxDatumsPerRequest is the maximum number of metric datums accepted by a single PutMetricData request.
+const maxDatumsPerRequest = 1000
+
+// Sample is a single metric measurement.
+type Sample struct {
+	// Name is the CloudWatch metric name.
+	Name string
+	// Unit is the CloudWatch unit of Value.
+	Unit types.StandardUnit
+	// Value is the measurement.
+	Value float64
+	// Dimensions are added to the publisher's dimensions for this sample only. It may be nil.
+	Dimensions map[string]string
+}
+
+// Collector gathers one or more samples.
+type Collector func(ctx context.Context) ([]Sample, error)
+
+// API is the subset of the CloudWatch client used by the Publisher.
+type API interface {
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// Publisher collects samples and sends them to CloudWatch.
+type Publisher struct {
+	// Client is the CloudWatch API client.
+	Client API
+	// Namespace is the CloudWatch namespace metrics are published under.
+	Namespace string
+	// Dimensions are attached to every published sample (e.g. the instance ID).
+	Dimensions map[string]string
+	// Collectors gather the samples to publish.
+	Collectors map[string]Collector
+}
+
+// Collect runs every collector and returns their samples. Collectors that fail are logged and skipped so that one
+// unavailable measurement doesn't prevent the others from being published.
+func (p *Publisher) Collect(ctx context.Context) []Sample {
+	var samples []Sample
+	for name, collect := range p.Collectors {
+		s, err := collect(ctx)
+		if err != nil {
+			logrus.WithError(err).WithField("collector", name).Warn("Failed to collect metrics")
+			continue
+		}
+		samples = append(samples, s...)
+	}
+
+	return samples
+}
+
+// Publish collects samples and sends them to CloudWatch, returning the number of samples published.
+func (p *Publisher) Publish(ctx context.Context) (int, error) {
+	samples := p.Collect(ctx)
+	if len(samples) == 0 {
+		return 0, fmt.Errorf("no metrics were collected")
+	}
+
+	datums := p.datums(samples, time.Now())
+	for len(datums) > 0 {
+		n := min(len(datums), maxDatumsPerRequest)
+		_, err := p.Client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(p.Namespace),
+			MetricData: datums[:n],
+		})
+		if err != nil {
+			return 0, fmt.Errorf("put metric data: %w", err)
+		}
+		datums = datums[n:]
+	}
+
+	return len(samples), nil
+}
+
+// datums converts samples into CloudWatch metric datums timestamped at ts.
+func (p *Publisher) datums(samples []Sample, ts time.Time) []types.MetricDatum {
+	datums := make([]types.MetricDatum, 0, len(samples))
+	for _, s := range samples {
+		datums = append(datums, types.MetricDatum{
+			MetricName: aws.String(s.Name),
+			Unit:       s.Unit,
+			Value:      aws.Float64(s.Value),
+			Timestamp:  aws.Time(ts),
+			Dimensions: dimensions(p.Dimensions, s.Dimensions),
+		})
+	}
+
+	return datums
+}
+
+// dimensions merges the dimension sets into CloudWatch dimensions, later sets taking precedence.
+func dimensions(sets ...map[string]string) []types.Dimension {
+	merged := map[string]string{}
+	for _, set := range sets {
+		for k, v := range set {
+			merged[k] = v
+		}
+	}
+
+	names := make([]string, 0, len(merged))
+	for k := range merged {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	dims := make([]types.Dimension, 0, len(merged))
+	for _, k := range names {
+		dims = append(dims, types.Dimension{Name: aws.String(k), Value: aws.String(merged[k])})
+	}
+
+	return dims
+}
+
+// Check returns a collector that runs the check and reports a CheckPassed sample of 1 when it succeeds and 0 when it
+// fails, dimensioned by the check's name.
+func Check(name string, check func(ctx context.Context) error) Collector {
+	return func(ctx context.Context) ([]Sample, error) {
+		value := 1.0
+		if err := check(ctx); err != nil {
+			logrus.WithError(err).WithField("check", name).Debug("Check failed")
+			value = 0
+		}
+
+		return []Sample{{
+			Name:       "CheckPassed",
+			Unit:       types.StandardUnitCount,
+			Value:      value,
+			Dimensions: map[string]string{"Check": name},
+		}}, nil
+	}
+}
+
+// Heartbeat is a collector that reports a Heartbeat sample of 1, so that an alarm on missing data detects a host that
+// stopped publishing.
+func Heartbeat(context.Context) ([]Sample, error) {
+	return []Sample{{
+		Name:  "Heartbeat",
+		Unit:  types.StandardUnitCount,
+		Value: 1,
+	}}, nil
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAPI struct {
+	requests []*cloudwatch.PutMetricDataInput
+}
+
+func (f *fakeAPI) PutMetricData(_ context.Context, in *cloudwatch.PutMetricDataInput, _ ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	f.requests = append(f.requests, in)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	api := &fakeAPI{}
+	p := &Publisher{
+		Client:     api,
+		Namespace:  "Test",
+		Dimensions: map[string]string{"InstanceId": "i-123"},
+		Collectors: map[string]Collector{
+			"many": func(context.Context) ([]Sample, error) {
+				samples := make([]Sample, maxDatumsPerRequest+1)
+				for i := range samples {
+					samples[i] = Sample{Name: "Many", Unit: types.StandardUnitCount, Value: float64(i)}
+				}
+				return samples, nil
+			},
+			"broken": func(context.Context) ([]Sample, error) {
+				return nil, errors.New("unavailable")
+			},
+		},
+	}
+
+	n, err := p.Publish(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, maxDatumsPerRequest+1, n)
+	assert.Len(t, api.requests, 2, "datums should be split into multiple requests")
+	assert.Equal(t, "Test", aws.ToString(api.requests[0].Namespace))
+	assert.Len(t, api.requests[1].MetricData, 1)
+}
+
+func TestPublisher_PublishNothingCollected(t *testing.T) {
+	p := &Publisher{Client: &fakeAPI{}, Namespace: "Test"}
+
+	_, err := p.Publish(context.Background())
+	assert.Error(t, err)
+}
+
+func TestDimensions(t *testing.T) {
+	dims := dimensions(map[string]string{"InstanceId": "i-123", "Check": "default"}, map[string]string{"Check": "imds"})
+
+	assert.Equal(t, []types.Dimension{
+		{Name: aws.String("Check"), Value: aws.String("imds")},
+		{Name: aws.String("InstanceId"), Value: aws.String("i-123")},
+	}, dims)
+}
+
+func TestCheck(t *testing.T) {
+	passing, err := Check("ok", func(context.Context) error { return nil })(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, passing[0].Value)
+	assert.Equal(t, "ok", passing[0].Dimensions["Check"])
+
+	failing, err := Check("bad", func(context.Context) error { return errors.New("failed") })(context.Background())
+	assert.NoError(t, err, "a failing check is still a sample")
+	assert.Equal(t, 0.0, failing[0].Value)
+}
+
+func TestHeartbeat(t *testing.T) {
+	samples, err := Heartbeat(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Sample{{Name: "Heartbeat", Unit: types.StandardUnitCount, Value: 1}}, samples)
+}
+
+func TestParseCPUIdle(t *testing.T) {
+	const out = `Processes: 512 total, 2 running, 510 sleeping, 2048 threads
+CPU usage: 10.00% user, 20.00% sys, 70.00% idle
+Processes: 512 total, 2 running, 510 sleeping, 2048 threads
+CPU usage: 3.5% user, 5.0% sys, 91.5% idle
+`
+	idle, err := parseCPUIdle(out)
+	assert.NoError(t, err)
+	assert.Equal(t, 91.5, idle, "the last sample should be used")
+
+	_, err = parseCPUIdle("no usage here")
+	assert.Error(t, err)
+}
+
+func TestDiskFree(t *testing.T) {
+	samples, err := DiskFree(os.TempDir())(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+	for _, s := range samples {
+		assert.Equal(t, os.TempDir(), s.Dimensions["Path"])
+	}
+}
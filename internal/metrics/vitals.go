@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	"github.com/aws/ec2-macos-utils/internal/diskspace"
+	"github.com/aws/ec2-macos-utils/internal/memory"
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// cpuUsageExp matches the CPU usage summary printed by top(1), e.g. "CPU usage: 3.5% user, 5.0% sys, 91.5% idle".
+var cpuUsageExp = regexp.MustCompile(`CPU usage:\s*([\d.]+)% user,\s*([\d.]+)% sys,\s*([\d.]+)% idle`)
+
+// CPU samples the host's CPU utilization. top(1) is asked for two samples since its first one reports the average
+// since boot rather than current usage.
+func CPU(ctx context.Context) ([]Sample, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"/usr/bin/top", "-l", "2", "-n", "0", "-s", "1"}, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sample CPU usage: %w", err)
+	}
+
+	idle, err := parseCPUIdle(out.Stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Sample{{Name: "CPUUtilization", Unit: types.StandardUnitPercent, Value: 100 - idle}}, nil
+}
+
+// parseCPUIdle returns the idle percentage of the last CPU usage summary in top(1) output.
+func parseCPUIdle(out string) (float64, error) {
+	var last []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if m := cpuUsageExp.FindStringSubmatch(scanner.Text()); m != nil {
+			last = m
+		}
+	}
+	if last == nil {
+		return 0, fmt.Errorf("CPU usage not found in top output")
+	}
+
+	return strconv.ParseFloat(last[3], 64)
+}
+
+// Memory samples the host's memory pressure.
+func Memory(ctx context.Context) ([]Sample, error) {
+	pressure, err := memory.Pressure(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Sample{{Name: "MemoryPressure", Unit: types.StandardUnitPercent, Value: pressure}}, nil
+}
+
+// DiskFree returns a collector that samples the free space of the volume containing path. Samples are dimensioned
+// by the path.
+func DiskFree(path string) Collector {
+	return func(context.Context) ([]Sample, error) {
+		total, available, err := diskspace.Usage(path)
+		if err != nil {
+			return nil, err
+		}
+
+		dims := map[string]string{"Path": path}
+		return []Sample{
+			{Name: "DiskFreeBytes", Unit: types.StandardUnitBytes, Value: float64(available), Dimensions: dims},
+			{Name: "DiskFreePercent", Unit: types.StandardUnitPercent, Value: float64(available) / float64(total) * 100, Dimensions: dims},
+		}, nil
+	}
+}
@@ -0,0 +1,176 @@
+// Package mtuprobe provides the functionality necessary for discovering the path MTU to a host with probes that can't
+// be fragmented, and for finding where larger packets are rejected or silently dropped (black-holed) on the way.
+package mtuprobe
+
+import (
+	"context"
+	"fmt"
+)
+
+// MinIPv4MTU is the smallest MTU every IPv4 host must accept.
+const MinIPv4MTU = 576
+
+// Outcomes of a probe.
+const (
+	// OutcomeReply is a probe that reached the target and was answered.
+	OutcomeReply = "reply"
+	// OutcomeFragNeeded is a probe rejected by a router whose next hop has a smaller MTU, which reported it.
+	OutcomeFragNeeded = "frag-needed"
+	// OutcomeLocal is a probe larger than the MTU of the local network device, which wasn't sent.
+	OutcomeLocal = "local"
+	// OutcomeTimeout is a probe that wasn't answered.
+	OutcomeTimeout = "timeout"
+)
+
+// Probe is the outcome of sending a packet of a size, with the don't-fragment bit set, to the target.
+type Probe struct {
+	// MTU is the size of the packet, headers included.
+	MTU     int    `json:"mtu"`
+	Outcome string `json:"outcome"`
+	// Router and RouterMTU are the router that rejected the packet and the MTU it reported, for OutcomeFragNeeded.
+	Router    string `json:"router,omitempty"`
+	RouterMTU int    `json:"router_mtu,omitempty"`
+}
+
+// Pinger sends a packet of the size to the target with the don't-fragment bit set, and waits for its answer. Probes
+// that aren't answered aren't errors; errors are failures to send them at all.
+type Pinger func(ctx context.Context, target string, mtu int) (Probe, error)
+
+// Tracer lists the routers on the path to the target answering packets of the size with the don't-fragment bit set,
+// hop by hop, with an empty address for the hops that didn't answer.
+type Tracer func(ctx context.Context, target string, mtu int) ([]string, error)
+
+// What limits the path MTU.
+const (
+	// LimitNone is a path that carries the largest size probed.
+	LimitNone = "none"
+	// LimitLocal is a path limited by the MTU of the local network device.
+	LimitLocal = "local"
+	// LimitFragNeeded is a path limited by a router that reports the MTU of its next hop, as path MTU discovery needs.
+	LimitFragNeeded = "frag-needed"
+	// LimitBlackhole is a path that silently drops larger packets, which breaks path MTU discovery and makes
+	// connections hang once they send full-sized packets.
+	LimitBlackhole = "blackhole"
+)
+
+// Result is the outcome of path MTU discovery.
+type Result struct {
+	Target string `json:"target"`
+	// MinMTU and MaxMTU are the range of sizes probed.
+	MinMTU int `json:"min_mtu"`
+	MaxMTU int `json:"max_mtu"`
+	// PathMTU is the largest size that reached the target.
+	PathMTU int `json:"path_mtu"`
+	// Limit is what rejected or dropped the smallest size that didn't, e.g. LimitBlackhole.
+	Limit string `json:"limit"`
+	// Router and RouterMTU are the router rejecting larger packets and the MTU it reported, for LimitFragNeeded.
+	Router    string `json:"router,omitempty"`
+	RouterMTU int    `json:"router_mtu,omitempty"`
+	// LastHop is the farthest router that answered larger packets, for LimitBlackhole, so the black hole is after it.
+	LastHop string `json:"last_hop,omitempty"`
+	// Probes are the probes sent, in order, after retries.
+	Probes []Probe `json:"probes"`
+}
+
+// Options customizes path MTU discovery.
+type Options struct {
+	// MinMTU and MaxMTU are the range of sizes to probe, MinIPv4MTU and the MTU of the local network device usually.
+	MinMTU, MaxMTU int
+	// Attempts is how many times an unanswered size is sent before it's considered dropped, at least once.
+	Attempts int
+	// Trace, when set, locates black holes by tracing the path with larger packets.
+	Trace Tracer
+}
+
+// Discover finds the path MTU to the target by binary search over the sizes of the options, and what limits it.
+func Discover(ctx context.Context, target string, opts Options, ping Pinger) (Result, error) {
+	if opts.MinMTU < MinIPv4MTU || opts.MaxMTU < opts.MinMTU {
+		return Result{}, fmt.Errorf("invalid MTU range %d-%d, the smallest MTU is %d", opts.MinMTU, opts.MaxMTU, MinIPv4MTU)
+	}
+	result := Result{Target: target, MinMTU: opts.MinMTU, MaxMTU: opts.MaxMTU}
+	send := func(mtu int) (Probe, error) {
+		var p Probe
+		for attempt := 0; attempt < max(opts.Attempts, 1); attempt++ {
+			var err error
+			if p, err = ping(ctx, target, mtu); err != nil {
+				return p, err
+			}
+			if p.Outcome != OutcomeTimeout {
+				break
+			}
+		}
+		result.Probes = append(result.Probes, p)
+		return p, nil
+	}
+
+	smallest, err := send(opts.MinMTU)
+	if err != nil {
+		return result, err
+	}
+	if smallest.Outcome != OutcomeReply {
+		return result, fmt.Errorf("no reply to %d-byte probes (%s), %s is unreachable or doesn't answer ping", opts.MinMTU, smallest.Outcome, target)
+	}
+	failed, err := send(opts.MaxMTU)
+	if err != nil {
+		return result, err
+	}
+	if failed.Outcome == OutcomeReply {
+		result.PathMTU, result.Limit = opts.MaxMTU, LimitNone
+		return result, nil
+	}
+
+	// The MTU a router reports is usually the path MTU, so it and the size above it are tried first to save probes.
+	lo, hi := opts.MinMTU, opts.MaxMTU
+	var hints []int
+	if failed.Outcome == OutcomeFragNeeded && failed.RouterMTU > lo && failed.RouterMTU < hi {
+		hints = []int{failed.RouterMTU, failed.RouterMTU + 1}
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if len(hints) > 0 {
+			mid, hints = hints[0], hints[1:]
+			if mid <= lo || mid >= hi {
+				continue
+			}
+		}
+		p, err := send(mid)
+		if err != nil {
+			return result, err
+		}
+		if p.Outcome == OutcomeReply {
+			lo = mid
+		} else {
+			hi, failed = mid, p
+		}
+	}
+	result.PathMTU = lo
+
+	switch failed.Outcome {
+	case OutcomeLocal:
+		result.Limit = LimitLocal
+	case OutcomeFragNeeded:
+		result.Limit, result.Router, result.RouterMTU = LimitFragNeeded, failed.Router, failed.RouterMTU
+	default:
+		result.Limit = LimitBlackhole
+		if opts.Trace != nil {
+			hops, err := opts.Trace(ctx, target, hi)
+			if err != nil {
+				return result, fmt.Errorf("trace black hole: %w", err)
+			}
+			result.LastHop = lastHop(hops)
+		}
+	}
+
+	return result, nil
+}
+
+// lastHop returns the farthest hop that answered.
+func lastHop(hops []string) string {
+	for i := len(hops) - 1; i >= 0; i-- {
+		if hops[i] != "" {
+			return hops[i]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,118 @@
+package mtuprobe
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// fakePath is a path to a target through a local device and a router, either of which can limit the packet size.
+type fakePath struct {
+	deviceMTU int
+	routerMTU int
+	// blackhole drops packets larger than routerMTU instead of rejecting them.
+	blackhole bool
+	// unreachable drops every packet.
+	unreachable bool
+}
+
+func (f fakePath) ping(_ context.Context, _ string, mtu int) (Probe, error) {
+	switch {
+	case mtu > f.deviceMTU:
+		return Probe{MTU: mtu, Outcome: OutcomeLocal}, nil
+	case f.unreachable:
+		return Probe{MTU: mtu, Outcome: OutcomeTimeout}, nil
+	case mtu > f.routerMTU && f.blackhole:
+		return Probe{MTU: mtu, Outcome: OutcomeTimeout}, nil
+	case mtu > f.routerMTU:
+		return Probe{MTU: mtu, Outcome: OutcomeFragNeeded, Router: "10.0.1.1", RouterMTU: f.routerMTU}, nil
+	default:
+		return Probe{MTU: mtu, Outcome: OutcomeReply}, nil
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	ctx := context.Background()
+	opts := Options{MinMTU: MinIPv4MTU, MaxMTU: 9001}
+
+	result, err := Discover(ctx, "10.0.2.10", opts, fakePath{deviceMTU: 9001, routerMTU: 9001}.ping)
+	require.NoError(t, err)
+	assert.Equal(t, 9001, result.PathMTU)
+	assert.Equal(t, LimitNone, result.Limit)
+	assert.Len(t, result.Probes, 2)
+
+	result, err = Discover(ctx, "10.0.2.10", opts, fakePath{deviceMTU: 9001, routerMTU: 1500}.ping)
+	require.NoError(t, err)
+	assert.Equal(t, 1500, result.PathMTU)
+	assert.Equal(t, LimitFragNeeded, result.Limit)
+	assert.Equal(t, "10.0.1.1", result.Router)
+	assert.Equal(t, 1500, result.RouterMTU)
+	assert.Less(t, len(result.Probes), 6, "the MTU reported by the router should be tried first")
+
+	result, err = Discover(ctx, "10.0.2.10", opts, fakePath{deviceMTU: 1500, routerMTU: 9001}.ping)
+	require.NoError(t, err)
+	assert.Equal(t, 1500, result.PathMTU)
+	assert.Equal(t, LimitLocal, result.Limit)
+
+	opts.Attempts = 2
+	opts.Trace = func(_ context.Context, _ string, mtu int) ([]string, error) {
+		assert.Equal(t, 1437, mtu, "the path should be traced with the smallest dropped size")
+		return []string{"10.0.0.1", "10.0.1.1", "", ""}, nil
+	}
+	result, err = Discover(ctx, "10.0.2.10", opts, fakePath{deviceMTU: 9001, routerMTU: 1436, blackhole: true}.ping)
+	require.NoError(t, err)
+	assert.Equal(t, 1436, result.PathMTU)
+	assert.Equal(t, LimitBlackhole, result.Limit)
+	assert.Equal(t, "10.0.1.1", result.LastHop)
+
+	_, err = Discover(ctx, "10.0.2.10", opts, fakePath{deviceMTU: 9001, routerMTU: 9001, unreachable: true}.ping)
+	assert.ErrorContains(t, err, "unreachable")
+
+	_, err = Discover(ctx, "10.0.2.10", Options{MinMTU: 1500, MaxMTU: 1280}, fakePath{}.ping)
+	assert.Error(t, err)
+}
+
+func TestParsePing(t *testing.T) {
+	for name, tc := range map[string]struct {
+		out     util.CommandOutput
+		err     error
+		outcome string
+	}{
+		"reply": {
+			out:     util.CommandOutput{Stdout: "PING 10.0.2.10 (10.0.2.10): 1472 data bytes\n1480 bytes from 10.0.2.10: icmp_seq=0 ttl=64 time=0.512 ms\n"},
+			outcome: OutcomeReply,
+		},
+		"timeout": {
+			out:     util.CommandOutput{Stdout: "PING 10.0.2.10 (10.0.2.10): 8973 data bytes\n\n--- 10.0.2.10 ping statistics ---\n1 packets transmitted, 0 packets received, 100.0% packet loss\n"},
+			err:     errors.New("exit status 2"),
+			outcome: OutcomeTimeout,
+		},
+		"local": {
+			out:     util.CommandOutput{Stderr: "ping: sendto: Message too long\n", Stdout: "1 packets transmitted, 0 packets received, 100.0% packet loss\n"},
+			err:     errors.New("exit status 2"),
+			outcome: OutcomeLocal,
+		},
+	} {
+		p, err := parsePing(1500, tc.out, tc.err)
+		require.NoError(t, err, name)
+		assert.Equal(t, tc.outcome, p.Outcome, name)
+	}
+
+	p, err := parsePing(9001, util.CommandOutput{Stdout: "92 bytes from 10.0.1.1: frag needed and DF set (MTU 1500)\n"}, errors.New("exit status 2"))
+	require.NoError(t, err)
+	assert.Equal(t, Probe{MTU: 9001, Outcome: OutcomeFragNeeded, Router: "10.0.1.1", RouterMTU: 1500}, p)
+
+	_, err = parsePing(1500, util.CommandOutput{Stderr: "ping: cannot resolve nowhere: Unknown host\n"}, errors.New("exit status 68"))
+	assert.Error(t, err)
+}
+
+func TestParseTraceroute(t *testing.T) {
+	output := " 1  10.0.0.1  0.412 ms\n 2  10.0.1.1  0.512 ms\n 3  *\n 4  *\n"
+	assert.Equal(t, []string{"10.0.0.1", "10.0.1.1", "", ""}, parseTraceroute(output))
+	assert.Equal(t, "10.0.1.1", lastHop(parseTraceroute(output)))
+}
@@ -0,0 +1,93 @@
+package mtuprobe
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// headerSize is the size of the IPv4 and ICMP headers of a ping, which aren't part of its payload.
+const headerSize = 28
+
+// fragNeeded matches ping's report of a router rejecting a probe, e.g.
+// "92 bytes from 10.0.1.1: frag needed and DF set (MTU 1400)".
+var fragNeeded = regexp.MustCompile(`(?i)bytes from ([0-9a-f.:]+): frag needed and DF set \(MTU (\d+)\)`)
+
+// Ping returns a pinger that sends probes with ping(8), waiting up to timeout for each answer.
+func Ping(timeout time.Duration) Pinger {
+	seconds := strconv.Itoa(max(1, int(math.Ceil(timeout.Seconds()))))
+
+	return func(ctx context.Context, target string, mtu int) (Probe, error) {
+		out, err := util.ExecuteCommand(ctx, []string{
+			"ping", "-n", "-c", "1", "-D", "-t", seconds, "-s", strconv.Itoa(mtu - headerSize), target,
+		}, "", nil, nil)
+
+		return parsePing(mtu, out, err)
+	}
+}
+
+// parsePing parses the outcome of a probe of the size from ping's output.
+func parsePing(mtu int, out util.CommandOutput, err error) (Probe, error) {
+	p := Probe{MTU: mtu}
+	switch match := fragNeeded.FindStringSubmatch(out.Stdout); {
+	case match != nil:
+		p.Outcome, p.Router = OutcomeFragNeeded, match[1]
+		p.RouterMTU, _ = strconv.Atoi(match[2])
+	case strings.Contains(out.Stdout, "icmp_seq="):
+		p.Outcome = OutcomeReply
+	case strings.Contains(out.Stderr, "Message too long"):
+		p.Outcome = OutcomeLocal
+	case strings.Contains(out.Stdout, "packets received"):
+		p.Outcome = OutcomeTimeout
+	case err != nil:
+		return p, fmt.Errorf("ping: %s: %w", strings.TrimSpace(out.Stderr), err)
+	default:
+		return p, fmt.Errorf("unexpected ping output %q", strings.TrimSpace(out.Stdout))
+	}
+
+	return p, nil
+}
+
+// Traceroute returns a tracer that traces the path with traceroute(8), waiting up to timeout for each hop's answer.
+func Traceroute(timeout time.Duration) Tracer {
+	seconds := strconv.Itoa(max(1, int(math.Ceil(timeout.Seconds()))))
+
+	return func(ctx context.Context, target string, mtu int) ([]string, error) {
+		out, err := util.ExecuteCommand(ctx, []string{
+			"traceroute", "-n", "-F", "-q", "1", "-w", seconds, "-m", "30", target, strconv.Itoa(mtu),
+		}, "", nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("traceroute: %s: %w", strings.TrimSpace(out.Stderr), err)
+		}
+
+		return parseTraceroute(out.Stdout), nil
+	}
+}
+
+// parseTraceroute parses the address of each hop from traceroute's output, e.g. " 2  10.0.1.1  0.512 ms !F-1400" or
+// " 3  *", with an empty address for the hops that didn't answer.
+func parseTraceroute(output string) []string {
+	var hops []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue
+		}
+		if fields[1] == "*" {
+			hops = append(hops, "")
+			continue
+		}
+		hops = append(hops, fields[1])
+	}
+
+	return hops
+}
@@ -0,0 +1,140 @@
+// Package naming provides the functionality necessary for expanding naming templates for artifacts, such as
+// "{instance-id}/{az}/{timestamp}-sysdiagnose.tar.gz", so that fleets get consistent layouts for their files.
+package naming
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+)
+
+// TimestampFormat is the format of the {timestamp} placeholder.
+const TimestampFormat = "20060102_150405" // YYYYMMDD_HHMMSS
+
+// Placeholders filled from the instance identity document.
+const (
+	InstanceID   = "instance-id"
+	AZ           = "az"
+	Region       = "region"
+	AccountID    = "account-id"
+	InstanceType = "instance-type"
+	ImageID      = "image-id"
+)
+
+// Placeholders filled from the time and the host.
+const (
+	Timestamp = "timestamp"
+	Date      = "date"
+	Hostname  = "hostname"
+	RunID     = "run-id"
+	Filename  = "filename"
+)
+
+// identityPlaceholders are the placeholders that require the instance identity document.
+var identityPlaceholders = []string{InstanceID, AZ, Region, AccountID, InstanceType, ImageID}
+
+// Vars holds the values of placeholders.
+type Vars map[string]string
+
+// Placeholders returns the names of the placeholders in the template, failing on unbalanced braces.
+func Placeholders(template string) ([]string, error) {
+	var names []string
+	for rest := template; ; {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			return names, nil
+		}
+		if rest[start] == '}' {
+			return nil, fmt.Errorf("invalid template %q: unexpected }", template)
+		}
+		end := strings.IndexAny(rest[start+1:], "{}")
+		if end < 0 || rest[start+1+end] != '}' {
+			return nil, fmt.Errorf("invalid template %q: unclosed {", template)
+		}
+		names = append(names, rest[start+1:start+1+end])
+		rest = rest[start+1+end+1:]
+	}
+}
+
+// NeedsIdentity reports whether any of the templates use placeholders from the instance identity document.
+func NeedsIdentity(templates ...string) bool {
+	for _, template := range templates {
+		names, _ := Placeholders(template)
+		for _, name := range names {
+			for _, p := range identityPlaceholders {
+				if name == p {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Expand replaces the placeholders in the template with their values. Slashes in values are replaced so that a value
+// can't add path components. Unknown placeholders are an error so that typos don't go unnoticed.
+func Expand(template string, vars Vars) (string, error) {
+	names, err := Placeholders(template)
+	if err != nil {
+		return "", err
+	}
+
+	replacements := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		v, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("invalid template %q: unknown placeholder {%s}, expected one of %s", template, name, vars.names())
+		}
+		replacements = append(replacements, "{"+name+"}", strings.ReplaceAll(v, "/", "-"))
+	}
+
+	return strings.NewReplacer(replacements...).Replace(template), nil
+}
+
+// names returns the placeholder names of the vars, sorted and formatted for error messages.
+func (v Vars) names() string {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// TimeVars returns the values of the time placeholders for t, in UTC.
+func TimeVars(t time.Time) Vars {
+	t = t.UTC()
+	return Vars{
+		Timestamp: t.Format(TimestampFormat),
+		Date:      t.Format("2006-01-02"),
+	}
+}
+
+// IdentityVars returns the values of the instance identity placeholders from the instance identity document.
+func IdentityVars(doc imds.InstanceIdentityDocument) Vars {
+	return Vars{
+		InstanceID:   doc.InstanceID,
+		AZ:           doc.AvailabilityZone,
+		Region:       doc.Region,
+		AccountID:    doc.AccountID,
+		InstanceType: doc.InstanceType,
+		ImageID:      doc.ImageID,
+	}
+}
+
+// Merge returns the union of the vars, later values taking precedence.
+func Merge(vars ...Vars) Vars {
+	merged := Vars{}
+	for _, v := range vars {
+		for name, value := range v {
+			merged[name] = value
+		}
+	}
+
+	return merged
+}
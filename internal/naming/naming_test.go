@@ -0,0 +1,62 @@
+package naming
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholders(t *testing.T) {
+	names, err := Placeholders("{instance-id}/{az}/{timestamp}-sysdiagnose.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"instance-id", "az", "timestamp"}, names)
+
+	names, err = Placeholders("/var/tmp/sysdiagnose")
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	for _, template := range []string{"{instance-id", "instance-id}", "{a{b}}"} {
+		_, err := Placeholders(template)
+		assert.Error(t, err, template)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := Merge(
+		Vars{InstanceID: "i-0123", AZ: "us-west-2a"},
+		TimeVars(time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("PDT", -7*3600))),
+		Vars{Filename: "a/b"},
+	)
+
+	s, err := Expand("{instance-id}/{az}/{timestamp}-sysdiagnose.tar.gz", vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "i-0123/us-west-2a/20240501_193000-sysdiagnose.tar.gz", s)
+
+	s, err = Expand("{date}/{filename}", vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05-01/a-b", s, "values shouldn't add path components")
+
+	_, err = Expand("{instance}/{filename}", vars)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "{instance}")
+}
+
+func TestNeedsIdentity(t *testing.T) {
+	assert.True(t, NeedsIdentity("/tmp", "{az}/{filename}"))
+	assert.False(t, NeedsIdentity("/tmp/{timestamp}", "{filename}"))
+}
+
+func TestIdentityVars(t *testing.T) {
+	vars := IdentityVars(imds.InstanceIdentityDocument{
+		InstanceID:       "i-0123",
+		AvailabilityZone: "us-west-2a",
+		Region:           "us-west-2",
+		AccountID:        "123456789012",
+		InstanceType:     "mac2.metal",
+		ImageID:          "ami-0123",
+	})
+	assert.Equal(t, "mac2.metal", vars[InstanceType])
+	assert.Equal(t, "us-west-2a", vars[AZ])
+}
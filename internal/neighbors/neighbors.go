@@ -0,0 +1,291 @@
+// Package neighbors provides the functionality necessary for checking the neighbor entries of the default gateways,
+// i.e. their ARP (IPv4) and NDP (IPv6) entries, which must resolve to a single link-layer address for the gateway, and
+// so IMDS, to be reachable.
+package neighbors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Families of gateways.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// States of a neighbor entry.
+const (
+	// StateResolved is an entry with a link-layer address.
+	StateResolved = "resolved"
+	// StateIncomplete is an entry whose resolution hasn't been answered.
+	StateIncomplete = "incomplete"
+	// StateMissing is a gateway without an entry.
+	StateMissing = "missing"
+)
+
+// touchTimeout bounds how long a packet sent to resolve a gateway's entry waits for its answer.
+const touchTimeout = 2 * time.Second
+
+// Gateway is a default gateway.
+type Gateway struct {
+	Family  string
+	Address string
+	Device  string
+}
+
+// Entry is the neighbor entry of a gateway.
+type Entry struct {
+	MAC   string
+	State string
+}
+
+// Sample is the neighbor entry of a gateway at a point in time.
+type Sample struct {
+	Time  time.Time `json:"time"`
+	MAC   string    `json:"mac,omitempty"`
+	State string    `json:"state"`
+}
+
+// Neighbor is the outcome of sampling a gateway's neighbor entry.
+type Neighbor struct {
+	Family  string `json:"family"`
+	Gateway string `json:"gateway"`
+	Device  string `json:"device"`
+	// MACs are the distinct link-layer addresses the entry resolved to, in the order they were seen. More than one
+	// means the entry flapped.
+	MACs    []string `json:"macs"`
+	Samples []Sample `json:"samples"`
+	OK      bool     `json:"ok"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Result is the outcome of checking the neighbor entries of the default gateways.
+type Result struct {
+	OK        bool       `json:"ok"`
+	Neighbors []Neighbor `json:"neighbors"`
+}
+
+// Options customizes the check.
+type Options struct {
+	// Samples is how many times each entry is read, at least once.
+	Samples int
+	// Interval is the time between samples.
+	Interval time.Duration
+}
+
+// Check samples the neighbor entry of each default gateway, sending it a packet before each sample so that the entry
+// is resolved if it can be. An entry passes when every sample resolved to the same link-layer address. It fails only
+// when the default gateways can't be found.
+func Check(ctx context.Context, opts Options) (Result, error) {
+	gateways, err := DefaultGateways(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return check(ctx, gateways, opts, Lookup, Touch), nil
+}
+
+// check samples the gateways' entries, read by lookup after resolving them with touch.
+func check(ctx context.Context, gateways []Gateway, opts Options, lookup func(context.Context, Gateway) (Entry, error),
+	touch func(context.Context, Gateway)) Result {
+	neighbors := make([]Neighbor, len(gateways))
+	for i, gw := range gateways {
+		neighbors[i] = Neighbor{Family: gw.Family, Gateway: gw.Address, Device: gw.Device}
+	}
+
+	for n := 0; n < max(opts.Samples, 1); n++ {
+		if n > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(opts.Interval):
+			}
+		}
+		for i, gw := range gateways {
+			nb := &neighbors[i]
+			if nb.Error != "" {
+				continue
+			}
+			touch(ctx, gw)
+			entry, err := lookup(ctx, gw)
+			if err != nil {
+				nb.Error = err.Error()
+				continue
+			}
+			nb.Samples = append(nb.Samples, Sample{Time: time.Now().UTC(), MAC: entry.MAC, State: entry.State})
+			if entry.MAC != "" && !slices.Contains(nb.MACs, entry.MAC) {
+				nb.MACs = append(nb.MACs, entry.MAC)
+			}
+		}
+	}
+
+	result := Result{OK: true}
+	for _, nb := range neighbors {
+		nb.OK = nb.Error == "" && len(nb.MACs) == 1 && unresolved(nb.Samples) == 0
+		result.OK = result.OK && nb.OK
+		result.Neighbors = append(result.Neighbors, nb)
+	}
+
+	return result
+}
+
+// Err returns an error describing the entries that failed, or nil if every entry passed.
+func (r Result) Err() error {
+	var failed []string
+	for _, nb := range r.Neighbors {
+		gateway := fmt.Sprintf("%s gateway %s on %s", nb.Family, nb.Gateway, nb.Device)
+		switch {
+		case nb.Error != "":
+			failed = append(failed, fmt.Sprintf("%s: %s", gateway, nb.Error))
+		case len(nb.MACs) > 1:
+			failed = append(failed, fmt.Sprintf("%s flapped between %s", gateway, strings.Join(nb.MACs, ", ")))
+		case len(nb.MACs) == 0:
+			failed = append(failed, fmt.Sprintf("%s isn't resolved (%s)", gateway, nb.Samples[len(nb.Samples)-1].State))
+		case !nb.OK:
+			failed = append(failed, fmt.Sprintf("%s was unresolved in %d of %d samples", gateway,
+				unresolved(nb.Samples), len(nb.Samples)))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// unresolved counts the samples without a link-layer address.
+func unresolved(samples []Sample) int {
+	var n int
+	for _, s := range samples {
+		if s.State != StateResolved {
+			n++
+		}
+	}
+
+	return n
+}
+
+// DefaultGateways returns the IPv4 default gateway, followed by the IPv6 one when there's an IPv6 default route.
+func DefaultGateways(ctx context.Context) ([]Gateway, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"route", "-n", "get", "default"}, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get default route: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	gw, err := parseRoute(FamilyIPv4, out.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	gateways := []Gateway{gw}
+
+	// Most instances don't have an IPv6 default route, which isn't an error.
+	out, err = util.ExecuteCommand(ctx, []string{"route", "-n", "get", "-inet6", "default"}, "", nil, nil)
+	if err == nil {
+		if gw, err := parseRoute(FamilyIPv6, out.Stdout); err == nil {
+			gateways = append(gateways, gw)
+		}
+	}
+
+	return gateways, nil
+}
+
+// parseRoute parses the gateway of a route from route get, whose lines include "gateway: 10.0.0.1" and
+// "interface: en0".
+func parseRoute(family, output string) (Gateway, error) {
+	gw := Gateway{Family: family}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "gateway":
+			gw.Address = strings.TrimSpace(value)
+		case "interface":
+			gw.Device = strings.TrimSpace(value)
+		}
+	}
+	if gw.Address == "" || gw.Device == "" {
+		return gw, fmt.Errorf("no %s default gateway in route output", family)
+	}
+
+	return gw, nil
+}
+
+// Lookup reads the neighbor entry of the gateway from the ARP or NDP table.
+func Lookup(ctx context.Context, gw Gateway) (Entry, error) {
+	if gw.Family == FamilyIPv6 {
+		out, err := util.ExecuteCommand(ctx, []string{"ndp", "-an"}, "", nil, nil)
+		if err != nil {
+			return Entry{}, fmt.Errorf("read NDP table: %s: %w", strings.TrimSpace(out.Stderr), err)
+		}
+		return parseNDP(gw, out.Stdout), nil
+	}
+
+	// arp fails when there's no entry, which it reports on stdout.
+	out, err := util.ExecuteCommand(ctx, []string{"arp", "-n", gw.Address}, "", nil, nil)
+	if err != nil && !strings.Contains(out.Stdout, "no entry") {
+		return Entry{}, fmt.Errorf("read ARP entry: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseARP(out.Stdout), nil
+}
+
+// parseARP parses the entry from arp -n, e.g. "? (10.0.0.1) at 2:7d:1c:2e:3f:40 on en0 ifscope [ethernet]",
+// "? (10.0.0.1) at (incomplete) on en0 ifscope [ethernet]", or "10.0.0.1 (10.0.0.1) -- no entry".
+func parseARP(output string) Entry {
+	fields := strings.Fields(output)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] != "at" {
+			continue
+		}
+		if fields[i+1] == "(incomplete)" {
+			return Entry{State: StateIncomplete}
+		}
+		return Entry{MAC: fields[i+1], State: StateResolved}
+	}
+
+	return Entry{State: StateMissing}
+}
+
+// parseNDP parses the gateway's entry from ndp -an, whose lines are e.g.
+// "fe80::1%en0  2:7d:1c:2e:3f:40  en0  23h59m58s  S  R" or "fe80::1%en0  (incomplete)  en0  expired  I  R".
+func parseNDP(gw Gateway, output string) Entry {
+	address, _, _ := strings.Cut(gw.Address, "%")
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[2] != gw.Device {
+			continue
+		}
+		if neighbor, _, _ := strings.Cut(fields[0], "%"); neighbor != address {
+			continue
+		}
+		if fields[1] == "(incomplete)" || (len(fields) >= 5 && fields[4] == "I") {
+			return Entry{State: StateIncomplete}
+		}
+		return Entry{MAC: fields[1], State: StateResolved}
+	}
+
+	return Entry{State: StateMissing}
+}
+
+// Touch sends a ping to the gateway, which resolves its neighbor entry whether or not the gateway answers it.
+func Touch(ctx context.Context, gw Gateway) {
+	ctx, cancel := context.WithTimeout(ctx, touchTimeout)
+	defer cancel()
+
+	argv := []string{"ping", "-n", "-c", "1", "-t", "1", gw.Address}
+	if gw.Family == FamilyIPv6 {
+		address := gw.Address
+		if !strings.Contains(address, "%") {
+			address += "%" + gw.Device
+		}
+		argv = []string{"ping6", "-n", "-c", "1", address}
+	}
+	_, _ = util.ExecuteCommand(ctx, argv, "", nil, nil)
+}
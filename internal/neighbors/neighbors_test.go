@@ -0,0 +1,91 @@
+package neighbors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoute(t *testing.T) {
+	gw, err := parseRoute(FamilyIPv4, `   route to: default
+destination: default
+       mask: default
+    gateway: 10.0.0.1
+  interface: en0
+      flags: <UP,GATEWAY,DONE,STATIC,PRCLONING,GLOBAL>
+`)
+	require.NoError(t, err)
+	assert.Equal(t, Gateway{Family: FamilyIPv4, Address: "10.0.0.1", Device: "en0"}, gw)
+
+	_, err = parseRoute(FamilyIPv6, "route: writing to routing socket: not in table\n")
+	assert.Error(t, err)
+}
+
+func TestParseARP(t *testing.T) {
+	assert.Equal(t, Entry{MAC: "2:7d:1c:2e:3f:40", State: StateResolved},
+		parseARP("? (10.0.0.1) at 2:7d:1c:2e:3f:40 on en0 ifscope [ethernet]\n"))
+	assert.Equal(t, Entry{State: StateIncomplete},
+		parseARP("? (10.0.0.1) at (incomplete) on en0 ifscope [ethernet]\n"))
+	assert.Equal(t, Entry{State: StateMissing}, parseARP("10.0.0.1 (10.0.0.1) -- no entry\n"))
+}
+
+func TestParseNDP(t *testing.T) {
+	const output = `Neighbor                        Linklayer Address  Netif Expire    St Flgs Prbs
+fe80::1%lo0                     (incomplete)         lo0 permanent R
+fe80::1%en0                     2:7d:1c:2e:3f:40     en0 23h59m58s S  R
+fe80::2%en0                     (incomplete)         en0 expired   I  R
+`
+	gw := Gateway{Family: FamilyIPv6, Address: "fe80::1%en0", Device: "en0"}
+	assert.Equal(t, Entry{MAC: "2:7d:1c:2e:3f:40", State: StateResolved}, parseNDP(gw, output))
+	gw.Address = "fe80::2"
+	assert.Equal(t, Entry{State: StateIncomplete}, parseNDP(gw, output))
+	gw.Address = "fe80::3"
+	assert.Equal(t, Entry{State: StateMissing}, parseNDP(gw, output))
+}
+
+func TestCheck(t *testing.T) {
+	gateways := []Gateway{
+		{Family: FamilyIPv4, Address: "10.0.0.1", Device: "en0"},
+		{Family: FamilyIPv6, Address: "fe80::1%en0", Device: "en0"},
+	}
+	run := func(entries map[string][]Entry) Result {
+		var touched int
+		result := check(context.Background(), gateways, Options{Samples: 3},
+			func(_ context.Context, gw Gateway) (Entry, error) {
+				e := entries[gw.Address][0]
+				if len(entries[gw.Address]) > 1 {
+					entries[gw.Address] = entries[gw.Address][1:]
+				}
+				return e, nil
+			},
+			func(context.Context, Gateway) { touched++ })
+		assert.Equal(t, 6, touched, "each gateway should be touched before each sample")
+		return result
+	}
+	resolved := Entry{MAC: "2:7d:1c:2e:3f:40", State: StateResolved}
+
+	result := run(map[string][]Entry{"10.0.0.1": {resolved}, "fe80::1%en0": {resolved}})
+	assert.True(t, result.OK)
+	assert.NoError(t, result.Err())
+	require.Len(t, result.Neighbors, 2)
+	assert.Len(t, result.Neighbors[0].Samples, 3)
+	assert.Equal(t, []string{"2:7d:1c:2e:3f:40"}, result.Neighbors[0].MACs)
+
+	result = run(map[string][]Entry{
+		"10.0.0.1":    {resolved, {MAC: "2:7d:1c:2e:3f:41", State: StateResolved}},
+		"fe80::1%en0": {resolved},
+	})
+	assert.False(t, result.OK)
+	assert.True(t, result.Neighbors[1].OK)
+	assert.EqualError(t, result.Err(), "ipv4 gateway 10.0.0.1 on en0 flapped between 2:7d:1c:2e:3f:40, 2:7d:1c:2e:3f:41")
+
+	result = run(map[string][]Entry{
+		"10.0.0.1":    {{State: StateIncomplete}, resolved},
+		"fe80::1%en0": {{State: StateMissing}},
+	})
+	assert.False(t, result.OK)
+	assert.EqualError(t, result.Err(), "ipv4 gateway 10.0.0.1 on en0 was unresolved in 1 of 3 samples; "+
+		"ipv6 gateway fe80::1%en0 on en0 isn't resolved (missing)")
+}
@@ -0,0 +1,128 @@
+package netconfig
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// hostNetmask is the netmask of addresses in a subnet the device already has an address in, so that they don't add
+// a second route to the subnet.
+const hostNetmask = "255.255.255.255"
+
+// Alias is an IPv4 address added to a network device.
+type Alias struct {
+	Device  string `json:"device"`
+	Address string `json:"address"`
+	Netmask string `json:"netmask"`
+}
+
+// Args returns the ifconfig arguments that add the alias.
+func (a Alias) Args() []string {
+	return []string{"ifconfig", a.Device, "inet", a.Address, "netmask", a.Netmask, "alias"}
+}
+
+// HardwareDevices returns the network devices by their lowercase MAC address.
+func HardwareDevices(ctx context.Context) (map[string]string, error) {
+	out, err := networksetup(ctx, "-listallhardwareports")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseHardwarePorts(out), nil
+}
+
+// parseHardwarePorts parses networksetup -listallhardwareports, whose ports are "Device: en0" lines followed by
+// "Ethernet Address: 06:aa:bb:cc:dd:ee" lines.
+func parseHardwarePorts(output string) map[string]string {
+	devices := map[string]string{}
+	var device string
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Device":
+			device = strings.TrimSpace(value)
+		case "Ethernet Address":
+			if mac := strings.ToLower(strings.TrimSpace(value)); device != "" && mac != "n/a" {
+				devices[mac] = device
+			}
+			device = ""
+		}
+	}
+
+	return devices
+}
+
+// Addresses returns the IPv4 addresses of the network device.
+func Addresses(ctx context.Context, device string) ([]string, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"ifconfig", device}, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ifconfig %s: %s: %w", device, strings.TrimSpace(out.Stderr), err)
+	}
+
+	var addresses []string
+	for _, line := range strings.Split(out.Stdout, "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "inet" {
+			addresses = append(addresses, fields[1])
+		}
+	}
+
+	return addresses, nil
+}
+
+// PlanAliases returns the aliases that add the addresses missing from the device. The first address in the subnet
+// gets the subnet's netmask, which also routes the subnet through the device, and the others a host netmask.
+func PlanAliases(device, subnetCIDR string, addresses, existing []string) ([]Alias, error) {
+	_, subnet, err := net.ParseCIDR(subnetCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %q: %w", subnetCIDR, err)
+	}
+	if subnet.IP.To4() == nil {
+		return nil, fmt.Errorf("subnet %s is not IPv4", subnetCIDR)
+	}
+
+	present := map[string]bool{}
+	inSubnet := false
+	for _, address := range existing {
+		present[address] = true
+		if ip := net.ParseIP(address); ip != nil && subnet.Contains(ip) {
+			inSubnet = true
+		}
+	}
+
+	var aliases []Alias
+	for _, address := range addresses {
+		if present[address] {
+			continue
+		}
+		ip := net.ParseIP(address)
+		if ip == nil || !subnet.Contains(ip) {
+			return nil, fmt.Errorf("address %q is not in subnet %s", address, subnetCIDR)
+		}
+		netmask := hostNetmask
+		if !inSubnet {
+			netmask = net.IP(subnet.Mask).String()
+			inSubnet = true
+		}
+		aliases = append(aliases, Alias{Device: device, Address: address, Netmask: netmask})
+		present[address] = true
+	}
+
+	return aliases, nil
+}
+
+// AddAlias adds the alias to its device. Aliases are removed at restart.
+func AddAlias(ctx context.Context, alias Alias) error {
+	out, err := util.ExecuteCommand(ctx, alias.Args(), "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("add %s to %s: %s: %w", alias.Address, alias.Device, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHardwarePorts(t *testing.T) {
+	output := `
+Hardware Port: Ethernet
+Device: en0
+Ethernet Address: 06:AA:BB:CC:DD:01
+
+Hardware Port: Ethernet Adapter (en1)
+Device: en1
+Ethernet Address: 06:aa:bb:cc:dd:02
+
+Hardware Port: Thunderbolt Bridge
+Device: bridge0
+Ethernet Address: N/A
+
+VLAN Configurations
+===================
+`
+	assert.Equal(t, map[string]string{"06:aa:bb:cc:dd:01": "en0", "06:aa:bb:cc:dd:02": "en1"}, parseHardwarePorts(output))
+}
+
+func TestPlanAliases(t *testing.T) {
+	aliases, err := PlanAliases("en0", "10.0.1.0/24", []string{"10.0.1.10", "10.0.1.11", "10.0.1.12"}, []string{"10.0.1.10", "10.0.1.12"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Alias{{Device: "en0", Address: "10.0.1.11", Netmask: hostNetmask}}, aliases)
+
+	aliases, err = PlanAliases("en1", "10.0.2.0/24", []string{"10.0.2.20", "10.0.2.21"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Alias{
+		{Device: "en1", Address: "10.0.2.20", Netmask: "255.255.255.0"},
+		{Device: "en1", Address: "10.0.2.21", Netmask: hostNetmask},
+	}, aliases)
+	assert.Equal(t, []string{"ifconfig", "en1", "inet", "10.0.2.20", "netmask", "255.255.255.0", "alias"}, aliases[0].Args())
+
+	_, err = PlanAliases("en1", "10.0.2.0/24", []string{"10.0.3.1"}, nil)
+	assert.Error(t, err)
+	_, err = PlanAliases("en1", "10.0.2.0", nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package netconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/plistutil"
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// infiniteLease is the lease time of a lease that never expires.
+const infiniteLease = 0xffffffff
+
+// dhcpLeasesDir is where the DHCP client saves the leases it was granted, one plist per device and network.
+var dhcpLeasesDir = "/private/var/db/dhcpclient/leases"
+
+// dhcpOptionExp matches an option of ipconfig getpacket, e.g. "lease_time (uint32): 0xe10".
+var dhcpOptionExp = regexp.MustCompile(`^(\S+) \(([^)]+)\):\s*(.*)$`)
+
+// DHCPOption is an option of a DHCP lease.
+type DHCPOption struct {
+	Name string `json:"name"`
+	// Type is how the value is encoded in the packet, e.g. "ip_mult" for a list of addresses.
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// DHCPLease is the DHCP lease of a network device.
+type DHCPLease struct {
+	Device  string `json:"device"`
+	Address string `json:"address"`
+	// Server is the DHCP server that granted the lease.
+	Server string `json:"server"`
+	// TransactionID is the ID of the DHCP exchange that granted the lease, which changes whenever it's renewed.
+	TransactionID string `json:"-"`
+	// LeaseSeconds is the length of the lease, which is zero for leases that never expire.
+	LeaseSeconds int64 `json:"lease_seconds"`
+	// Start and Expires are when the lease was granted and when it expires, when the DHCP client saved the lease.
+	Start   *time.Time   `json:"start,omitempty"`
+	Expires *time.Time   `json:"expires,omitempty"`
+	Options []DHCPOption `json:"options"`
+}
+
+// GetDHCPLease returns the device's current DHCP lease.
+func GetDHCPLease(ctx context.Context, device string) (DHCPLease, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"ipconfig", "getpacket", device}, "", nil, nil)
+	if err != nil || strings.TrimSpace(out.Stdout) == "" {
+		return DHCPLease{}, fmt.Errorf("no DHCP lease on %s", device)
+	}
+	lease, err := parseDHCPPacket(out.Stdout)
+	if err != nil {
+		return lease, err
+	}
+	lease.Device = device
+
+	// The packet doesn't say when the lease was granted, which the client saves separately.
+	if start, ok := leaseStart(device, lease.Address); ok {
+		lease.Start = &start
+		if lease.LeaseSeconds > 0 {
+			expires := start.Add(time.Duration(lease.LeaseSeconds) * time.Second)
+			lease.Expires = &expires
+		}
+	}
+
+	return lease, nil
+}
+
+// RenewDHCPLease restarts DHCP on the device, which requests the lease again from the server. The device's address
+// may be briefly unavailable while it's renewed.
+func RenewDHCPLease(ctx context.Context, device string) error {
+	out, err := util.ExecuteCommand(ctx, []string{"ipconfig", "set", device, "DHCP"}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("renew DHCP lease on %s: %s: %w", device, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// parseDHCPPacket parses the lease from ipconfig getpacket, which prints the fields of the last DHCP packet received
+// as "yiaddr = 10.0.0.5", followed by "options:" and its options, e.g. "server_identifier (ip): 10.0.0.1".
+func parseDHCPPacket(output string) (DHCPLease, error) {
+	var (
+		lease   DHCPLease
+		options bool
+	)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "options:" {
+			options = true
+			continue
+		}
+		if !options {
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "yiaddr":
+				lease.Address = strings.TrimSpace(value)
+			case "xid":
+				lease.TransactionID = strings.TrimSpace(value)
+			}
+			continue
+		}
+
+		match := dhcpOptionExp.FindStringSubmatch(line)
+		if match == nil || match[1] == "end" {
+			continue
+		}
+		option := DHCPOption{Name: match[1], Type: match[2], Value: dhcpOptionValue(match[2], match[3])}
+		switch option.Name {
+		case "server_identifier":
+			lease.Server = option.Value
+		case "lease_time":
+			if seconds, err := strconv.ParseInt(option.Value, 10, 64); err == nil && seconds != infiniteLease {
+				lease.LeaseSeconds = seconds
+			}
+		}
+		lease.Options = append(lease.Options, option)
+	}
+	if lease.Address == "" {
+		return lease, errors.New("no address in DHCP packet")
+	}
+
+	return lease, nil
+}
+
+// dhcpOptionValue normalizes the value of an option as ipconfig prints it: integers are printed in hex, lists in
+// braces, and the message type by name followed by its code, e.g. "ACK 0x5".
+func dhcpOptionValue(typ, value string) string {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.HasSuffix(typ, "_mult"):
+		return strings.TrimSuffix(strings.TrimPrefix(value, "{"), "}")
+	case !strings.HasPrefix(typ, "uint"):
+		return value
+	}
+	if name, code, ok := strings.Cut(value, " "); ok && strings.HasPrefix(code, "0x") {
+		return name
+	}
+	if n, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 64); err == nil && strings.HasPrefix(value, "0x") {
+		return strconv.FormatUint(n, 10)
+	}
+
+	return value
+}
+
+// leaseStart returns when the device's lease of the address was granted, from the leases saved by the DHCP client.
+func leaseStart(device, address string) (time.Time, bool) {
+	entries, err := os.ReadDir(dhcpLeasesDir)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var start time.Time
+	for _, e := range entries {
+		if e.Name() != device && !strings.HasPrefix(e.Name(), device+"-") {
+			continue
+		}
+		var saved struct {
+			IPAddress      string    `plist:"IPAddress"`
+			LeaseStartDate time.Time `plist:"LeaseStartDate"`
+		}
+		if err := plistutil.DecodeFile(filepath.Join(dhcpLeasesDir, e.Name()), &saved); err != nil {
+			continue
+		}
+		if saved.IPAddress == address && saved.LeaseStartDate.After(start) {
+			start = saved.LeaseStartDate
+		}
+	}
+
+	return start, !start.IsZero()
+}
@@ -0,0 +1,95 @@
+package netconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dhcpPacket = `op = BOOTREPLY
+htype = 1
+flags = 0
+hlen = 6
+hops = 0
+xid = 0x2a3b4c5d
+secs = 0
+ciaddr = 0.0.0.0
+yiaddr = 10.0.0.5
+siaddr = 0.0.0.0
+giaddr = 0.0.0.0
+chaddr = 2:7d:1c:2e:3f:40
+sname =
+file =
+options:
+Options count is 8
+dhcp_message_type (uint8): ACK 0x5
+server_identifier (ip): 10.0.0.1
+lease_time (uint32): 0xe10
+subnet_mask (ip): 255.255.255.0
+router (ip_mult): {10.0.0.1}
+domain_name_server (ip_mult): {10.0.0.2, 10.0.0.3}
+domain_name (string): ec2.internal
+interface_mtu (uint16): 0x2329
+end (none):
+`
+
+func TestParseDHCPPacket(t *testing.T) {
+	lease, err := parseDHCPPacket(dhcpPacket)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", lease.Address)
+	assert.Equal(t, "10.0.0.1", lease.Server)
+	assert.Equal(t, "0x2a3b4c5d", lease.TransactionID)
+	assert.EqualValues(t, 3600, lease.LeaseSeconds)
+	assert.Equal(t, []DHCPOption{
+		{Name: "dhcp_message_type", Type: "uint8", Value: "ACK"},
+		{Name: "server_identifier", Type: "ip", Value: "10.0.0.1"},
+		{Name: "lease_time", Type: "uint32", Value: "3600"},
+		{Name: "subnet_mask", Type: "ip", Value: "255.255.255.0"},
+		{Name: "router", Type: "ip_mult", Value: "10.0.0.1"},
+		{Name: "domain_name_server", Type: "ip_mult", Value: "10.0.0.2, 10.0.0.3"},
+		{Name: "domain_name", Type: "string", Value: "ec2.internal"},
+		{Name: "interface_mtu", Type: "uint16", Value: "9001"},
+	}, lease.Options)
+
+	lease, err = parseDHCPPacket("yiaddr = 10.0.0.5\noptions:\nlease_time (uint32): 0xffffffff\n")
+	require.NoError(t, err)
+	assert.Zero(t, lease.LeaseSeconds, "an infinite lease shouldn't have a length")
+
+	_, err = parseDHCPPacket("op = BOOTREPLY\noptions:\n")
+	assert.Error(t, err)
+}
+
+func TestLeaseStart(t *testing.T) {
+	dhcpLeasesDir = t.TempDir()
+	defer func() { dhcpLeasesDir = "/private/var/db/dhcpclient/leases" }()
+
+	saved := func(name, address, start string) {
+		plist := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>IPAddress</key>
+	<string>` + address + `</string>
+	<key>LeaseLength</key>
+	<integer>3600</integer>
+	<key>LeaseStartDate</key>
+	<date>` + start + `</date>
+</dict>
+</plist>
+`
+		require.NoError(t, os.WriteFile(filepath.Join(dhcpLeasesDir, name), []byte(plist), 0644))
+	}
+	saved("en0-1,2:7d:1c:2e:3f:40", "10.0.0.5", "2026-10-16T12:00:00Z")
+	saved("en0-1,2:7d:1c:2e:3f:41", "10.1.0.5", "2026-10-16T13:00:00Z")
+	saved("en1-1,2:7d:1c:2e:3f:42", "10.0.0.5", "2026-10-16T14:00:00Z")
+
+	start, ok := leaseStart("en0", "10.0.0.5")
+	require.True(t, ok)
+	assert.True(t, start.Equal(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)))
+
+	_, ok = leaseStart("en0", "10.2.0.5")
+	assert.False(t, ok)
+}
@@ -0,0 +1,231 @@
+package netconfig
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ResolverDir is where per-domain resolver configurations are read from by the system resolver.
+const ResolverDir = "/etc/resolver"
+
+// resolverHeader marks resolver files written by ec2-macos-utils so that others aren't overwritten or removed.
+const resolverHeader = "# Managed by ec2-macos-utils"
+
+// DNS is the DNS configuration of a network service. Empty fields use the servers and domains from DHCP, which are
+// the VPC's by default.
+type DNS struct {
+	Servers       []string `json:"servers"`
+	SearchDomains []string `json:"search_domains"`
+}
+
+// Equal returns whether the configurations have the same servers and search domains in the same order.
+func (d DNS) Equal(other DNS) bool {
+	return equalStrings(d.Servers, other.Servers) && equalStrings(d.SearchDomains, other.SearchDomains)
+}
+
+// Validate checks that the servers are IP addresses and the search domains are domain names.
+func (d DNS) Validate() error {
+	for _, server := range d.Servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid DNS server %q", server)
+		}
+	}
+	for _, domain := range d.SearchDomains {
+		if err := validateDomain(domain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDNS returns the DNS configuration of the network service.
+func GetDNS(ctx context.Context, service string) (DNS, error) {
+	servers, err := networksetup(ctx, "-getdnsservers", service)
+	if err != nil {
+		return DNS{}, err
+	}
+	domains, err := networksetup(ctx, "-getsearchdomains", service)
+	if err != nil {
+		return DNS{}, err
+	}
+
+	return DNS{Servers: parseList(servers), SearchDomains: parseList(domains)}, nil
+}
+
+// SetDNS sets the DNS configuration of the network service.
+func SetDNS(ctx context.Context, service string, dns DNS) error {
+	for _, args := range setDNSArgs(service, dns) {
+		if _, err := networksetup(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setDNSArgs returns the networksetup arguments that set the configuration. "Empty" clears a setting.
+func setDNSArgs(service string, dns DNS) [][]string {
+	servers, domains := dns.Servers, dns.SearchDomains
+	if len(servers) == 0 {
+		servers = []string{"Empty"}
+	}
+	if len(domains) == 0 {
+		domains = []string{"Empty"}
+	}
+
+	return [][]string{
+		append([]string{"-setdnsservers", service}, servers...),
+		append([]string{"-setsearchdomains", service}, domains...),
+	}
+}
+
+// parseList parses the values networksetup lists one per line, which is a sentence when there are none, e.g.
+// "There aren't any DNS Servers set on Ethernet.".
+func parseList(output string) []string {
+	if strings.Contains(output, "There aren't any") {
+		return nil
+	}
+
+	return strings.Fields(output)
+}
+
+// Resolver forwards the queries for a domain, and its subdomains, to specific servers, e.g. to resolve an
+// on-premises domain with conditional forwarders instead of the VPC resolver.
+type Resolver struct {
+	Domain  string   `json:"domain"`
+	Servers []string `json:"servers"`
+}
+
+// ParseResolver parses a resolver from "domain=server[,server...]".
+func ParseResolver(s string) (Resolver, error) {
+	domain, servers, ok := strings.Cut(s, "=")
+	if !ok || servers == "" {
+		return Resolver{}, fmt.Errorf("invalid resolver %q, expected domain=server[,server...]", s)
+	}
+	r := Resolver{Domain: strings.ToLower(strings.TrimSuffix(domain, ".")), Servers: strings.Split(servers, ",")}
+
+	return r, r.Validate()
+}
+
+// Validate checks that the domain is a domain name and the servers are IP addresses.
+func (r Resolver) Validate() error {
+	if err := validateDomain(r.Domain); err != nil {
+		return err
+	}
+	if len(r.Servers) == 0 {
+		return fmt.Errorf("resolver for %s has no servers", r.Domain)
+	}
+	for _, server := range r.Servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid DNS server %q for %s", server, r.Domain)
+		}
+	}
+
+	return nil
+}
+
+// Render returns the resolver file contents, see resolver(5).
+func (r Resolver) Render() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, resolverHeader)
+	for _, server := range r.Servers {
+		fmt.Fprintf(&b, "nameserver %s\n", server)
+	}
+
+	return b.String()
+}
+
+// Resolvers returns the resolvers in dir written by ec2-macos-utils, sorted by domain.
+func Resolvers(dir string) ([]Resolver, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resolvers []Resolver
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil || !strings.HasPrefix(string(data), resolverHeader) {
+			continue
+		}
+		r := Resolver{Domain: entry.Name()}
+		for _, line := range strings.Split(string(data), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "nameserver" {
+				r.Servers = append(r.Servers, fields[1])
+			}
+		}
+		resolvers = append(resolvers, r)
+	}
+	sort.Slice(resolvers, func(i, j int) bool { return resolvers[i].Domain < resolvers[j].Domain })
+
+	return resolvers, nil
+}
+
+// SetResolvers replaces the resolvers in dir written by ec2-macos-utils with resolvers. Resolver files written by
+// others are left alone, and an error is returned if one is for the same domain.
+func SetResolvers(dir string, resolvers []Resolver) error {
+	existing, err := Resolvers(dir)
+	if err != nil {
+		return err
+	}
+	for _, r := range existing {
+		if err := os.Remove(filepath.Join(dir, r.Domain)); err != nil {
+			return fmt.Errorf("cannot remove resolver for %s: %w", r.Domain, err)
+		}
+	}
+	if len(resolvers) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, r := range resolvers {
+		path := filepath.Join(dir, r.Domain)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("resolver for %s is not managed by ec2-macos-utils: %s", r.Domain, path)
+		}
+		if err := os.WriteFile(path, []byte(r.Render()), 0644); err != nil {
+			return fmt.Errorf("cannot write resolver for %s: %w", r.Domain, err)
+		}
+	}
+
+	return nil
+}
+
+// validateDomain checks that the domain is a domain name that can be used as a file name.
+func validateDomain(domain string) error {
+	if domain == "" || len(domain) > 253 || strings.HasPrefix(domain, ".") || strings.Contains(domain, "..") {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+	for _, r := range domain {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return fmt.Errorf("invalid domain %q", domain)
+		}
+	}
+
+	return nil
+}
+
+// equalStrings returns whether the slices have the same elements in the same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package netconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseServices(t *testing.T) {
+	output := "An asterisk (*) denotes that a network service is disabled.\nEthernet\n*Wi-Fi\nThunderbolt Bridge\n"
+	assert.Equal(t, []string{"Ethernet", "Thunderbolt Bridge"}, parseServices(output))
+}
+
+func TestParseList(t *testing.T) {
+	assert.Nil(t, parseList("There aren't any DNS Servers set on Ethernet.\n"))
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, parseList("10.0.0.2\n10.0.0.3\n"))
+}
+
+func TestSetDNSArgs(t *testing.T) {
+	assert.Equal(t, [][]string{
+		{"-setdnsservers", "Ethernet", "10.0.0.2"},
+		{"-setsearchdomains", "Ethernet", "Empty"},
+	}, setDNSArgs("Ethernet", DNS{Servers: []string{"10.0.0.2"}}))
+}
+
+func TestDNS_Validate(t *testing.T) {
+	assert.NoError(t, DNS{Servers: []string{"10.0.0.2", "fd00::2"}, SearchDomains: []string{"corp.example.com"}}.Validate())
+	assert.Error(t, DNS{Servers: []string{"dns.example.com"}}.Validate())
+	assert.Error(t, DNS{SearchDomains: []string{"../etc"}}.Validate())
+}
+
+func TestParseResolver(t *testing.T) {
+	r, err := ParseResolver("Corp.Example.com.=10.1.0.2,10.1.0.3")
+	assert.NoError(t, err)
+	assert.Equal(t, Resolver{Domain: "corp.example.com", Servers: []string{"10.1.0.2", "10.1.0.3"}}, r)
+
+	for _, s := range []string{"corp.example.com", "corp.example.com=", "=10.1.0.2", "corp/x=10.1.0.2", "corp.example.com=host"} {
+		_, err := ParseResolver(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSetResolvers(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.example.com"), []byte("nameserver 10.9.0.2\n"), 0644))
+
+	corp := Resolver{Domain: "corp.example.com", Servers: []string{"10.1.0.2"}}
+	lab := Resolver{Domain: "lab.example.com", Servers: []string{"10.2.0.2", "10.2.0.3"}}
+	assert.NoError(t, SetResolvers(dir, []Resolver{lab, corp}))
+
+	resolvers, err := Resolvers(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []Resolver{corp, lab}, resolvers)
+
+	assert.NoError(t, SetResolvers(dir, []Resolver{lab}))
+	resolvers, err = Resolvers(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []Resolver{lab}, resolvers)
+	assert.FileExists(t, filepath.Join(dir, "other.example.com"))
+
+	assert.Error(t, SetResolvers(dir, []Resolver{{Domain: "other.example.com", Servers: []string{"10.3.0.2"}}}))
+}
@@ -0,0 +1,175 @@
+package netconfig
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+const (
+	// MinMTU is the smallest MTU that carries IPv6.
+	MinMTU = 1280
+	// InternetMTU is the largest MTU of traffic leaving the VPC through an internet gateway, VPN connection, or
+	// inter-region peering connection.
+	InternetMTU = 1500
+	// VPCMaxMTU is the largest MTU supported within a VPC (jumbo frames).
+	VPCMaxMTU = 9001
+)
+
+// MTU is the MTU of a network device.
+type MTU struct {
+	// Active is the MTU in use.
+	Active int `json:"active"`
+	// Min and Max are the range of MTUs the device supports.
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Validate checks that mtu is supported by both the VPC and the device.
+func (m MTU) Validate(mtu int) error {
+	if mtu < MinMTU || mtu > VPCMaxMTU {
+		return fmt.Errorf("MTU %d is outside the range supported by the VPC (%d-%d)", mtu, MinMTU, VPCMaxMTU)
+	}
+	if m.Max > 0 && (mtu < m.Min || mtu > m.Max) {
+		return fmt.Errorf("MTU %d is outside the range supported by the device (%d-%d)", mtu, m.Min, m.Max)
+	}
+
+	return nil
+}
+
+// DefaultDevice returns the network device of the default route, i.e. the primary interface.
+func DefaultDevice(ctx context.Context) (string, error) {
+	return RouteDevice(ctx, "default")
+}
+
+// RouteDevice returns the network device traffic to the destination, an address or "default", is routed through.
+func RouteDevice(ctx context.Context, destination string) (string, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"route", "-n", "get", destination}, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("get route to %s: %s: %w", destination, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseRouteInterface(out.Stdout)
+}
+
+// parseRouteInterface parses the interface from route get's output, e.g. "  interface: en0".
+func parseRouteInterface(output string) (string, error) {
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && key == "interface" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+
+	return "", fmt.Errorf("no interface in route output %q", strings.TrimSpace(output))
+}
+
+// GetMTU returns the MTU of the network device, e.g. en0.
+func GetMTU(ctx context.Context, device string) (MTU, error) {
+	active, err := networksetup(ctx, "-getMTU", device)
+	if err != nil {
+		return MTU{}, err
+	}
+	valid, err := networksetup(ctx, "-listValidMTURange", device)
+	if err != nil {
+		return MTU{}, err
+	}
+
+	return parseMTU(active, valid)
+}
+
+// parseMTU parses the outputs of networksetup -getMTU, e.g. "Active MTU: 1500 (Current Setting: 1500)", and
+// -listValidMTURange, e.g. "Valid MTU Range: 1280-9001".
+func parseMTU(active, valid string) (MTU, error) {
+	var m MTU
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(active), "Active MTU:"))
+	if len(fields) == 0 {
+		return MTU{}, fmt.Errorf("invalid MTU output %q", strings.TrimSpace(active))
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return MTU{}, fmt.Errorf("invalid MTU output %q", strings.TrimSpace(active))
+	}
+	m.Active = n
+
+	min, max, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(valid), "Valid MTU Range:"), "-")
+	if !ok {
+		return MTU{}, fmt.Errorf("invalid MTU range output %q", strings.TrimSpace(valid))
+	}
+	if m.Min, err = strconv.Atoi(strings.TrimSpace(min)); err != nil {
+		return MTU{}, fmt.Errorf("invalid MTU range output %q", strings.TrimSpace(valid))
+	}
+	if m.Max, err = strconv.Atoi(strings.TrimSpace(max)); err != nil {
+		return MTU{}, fmt.Errorf("invalid MTU range output %q", strings.TrimSpace(valid))
+	}
+
+	return m, nil
+}
+
+// SetMTU sets the MTU of the network device. networksetup saves it in the network preferences so that it's kept
+// across restarts.
+func SetMTU(ctx context.Context, device string, mtu int) error {
+	_, err := networksetup(ctx, "-setMTU", device, strconv.Itoa(mtu))
+	return err
+}
+
+// Tunable is a boolean kernel network setting.
+type Tunable struct {
+	// Name identifies the tunable, e.g. "pmtud-blackhole-detection".
+	Name string
+	// Description describes the behavior the tunable controls.
+	Description string
+	// Sysctl is the kernel variable, set to 1 or 0.
+	Sysctl string
+}
+
+// Tunables are the network tunables related to the MTU.
+var Tunables = []Tunable{
+	{
+		Name:        "pmtud-blackhole-detection",
+		Description: "lower the TCP segment size when large segments are silently dropped, e.g. because ICMP is blocked",
+		Sysctl:      "net.inet.tcp.pmtud_blackhole_detection",
+	},
+	{
+		Name:        "tso",
+		Description: "offload TCP segmentation to the network device",
+		Sysctl:      "net.inet.tcp.tso",
+	},
+}
+
+// LookupTunable returns the tunable with the name.
+func LookupTunable(name string) (Tunable, bool) {
+	for _, t := range Tunables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+
+	return Tunable{}, false
+}
+
+// Read returns whether the tunable is enabled.
+func (t Tunable) Read(ctx context.Context) (bool, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"sysctl", "-n", t.Sysctl}, "", nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("read %s: %s: %w", t.Sysctl, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return strings.TrimSpace(out.Stdout) != "0", nil
+}
+
+// Write enables or disables the tunable. Kernel variables are reset at restart.
+func (t Tunable) Write(ctx context.Context, enabled bool) error {
+	value := "0"
+	if enabled {
+		value = "1"
+	}
+	out, err := util.ExecuteCommand(ctx, []string{"sysctl", "-w", t.Sysctl + "=" + value}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("write %s: %s: %w", t.Sysctl, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRouteInterface(t *testing.T) {
+	device, err := parseRouteInterface("   route to: default\ndestination: default\n    gateway: 10.0.0.1\n  interface: en0\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "en0", device)
+
+	_, err = parseRouteInterface("route: writing to routing socket: not in table")
+	assert.Error(t, err)
+}
+
+func TestParseMTU(t *testing.T) {
+	m, err := parseMTU("Active MTU: 9001 (Current Setting: 9001)\n", "Valid MTU Range: 1280-9001\n")
+	assert.NoError(t, err)
+	assert.Equal(t, MTU{Active: 9001, Min: 1280, Max: 9001}, m)
+
+	_, err = parseMTU("Active MTU: unknown", "Valid MTU Range: 1280-9001")
+	assert.Error(t, err)
+	_, err = parseMTU("Active MTU: 1500", "Valid MTU Range: 1280")
+	assert.Error(t, err)
+}
+
+func TestMTU_Validate(t *testing.T) {
+	m := MTU{Active: 1500, Min: 1280, Max: 1500}
+	assert.NoError(t, m.Validate(1500))
+	assert.Error(t, m.Validate(9001))
+	assert.Error(t, MTU{}.Validate(9216))
+	assert.Error(t, MTU{}.Validate(576))
+	assert.NoError(t, MTU{}.Validate(VPCMaxMTU))
+}
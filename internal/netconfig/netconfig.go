@@ -0,0 +1,49 @@
+// Package netconfig provides the functionality necessary for configuring macOS network services with networksetup.
+package netconfig
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Services returns the names of the enabled network services, in service order.
+func Services(ctx context.Context) ([]string, error) {
+	out, err := networksetup(ctx, "-listallnetworkservices")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseServices(out), nil
+}
+
+// parseServices parses the enabled services from networksetup -listallnetworkservices. The first line is a note
+// and disabled services are marked with an asterisk.
+func parseServices(output string) []string {
+	var services []string
+	for i, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if i == 0 || line == "" || strings.HasPrefix(line, "*") {
+			continue
+		}
+		services = append(services, line)
+	}
+
+	return services
+}
+
+// networksetup runs networksetup with the arguments and returns its output.
+func networksetup(ctx context.Context, args ...string) (string, error) {
+	out, err := util.ExecuteCommand(ctx, append([]string{"networksetup"}, args...), "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("networksetup %s: %s: %w", args[0], strings.TrimSpace(out.Stderr+out.Stdout), err)
+	}
+	// networksetup reports some failures, e.g. an unknown service, on stdout with a zero exit status.
+	if strings.Contains(out.Stdout, "** Error") {
+		return "", fmt.Errorf("networksetup %s: %s", args[0], strings.TrimSpace(out.Stdout))
+	}
+
+	return out.Stdout, nil
+}
@@ -0,0 +1,148 @@
+package netconfig
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// imdsAddress is the address of IMDS, which is connected to directly when the bypass list covers it.
+const imdsAddress = "169.254.169.254"
+
+// Proxy is the address of a proxy server.
+type Proxy struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// String returns the proxy's host:port address.
+func (p Proxy) String() string {
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+}
+
+// ParseProxy parses a proxy from "host:port". A leading scheme, e.g. "http://", is ignored.
+func ParseProxy(s string) (Proxy, error) {
+	if _, rest, ok := strings.Cut(s, "://"); ok {
+		s = rest
+	}
+	host, port, err := net.SplitHostPort(strings.TrimSuffix(s, "/"))
+	if err != nil {
+		return Proxy{}, fmt.Errorf("invalid proxy %q, expected host:port", s)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 || host == "" || strings.ContainsAny(host, " /@") {
+		return Proxy{}, fmt.Errorf("invalid proxy %q, expected host:port", s)
+	}
+
+	return Proxy{Host: host, Port: n}, nil
+}
+
+// Proxies is the proxy configuration of a network service. Nil proxies are turned off.
+type Proxies struct {
+	HTTP  *Proxy `json:"http,omitempty"`
+	HTTPS *Proxy `json:"https,omitempty"`
+	SOCKS *Proxy `json:"socks,omitempty"`
+	// Bypass are the hosts and domains connected to directly, e.g. "169.254.169.254" or "*.internal".
+	Bypass []string `json:"bypass,omitempty"`
+}
+
+// proxyKinds are the networksetup names of the proxies, which prefix the -set<kind>proxy and -set<kind>proxystate
+// options.
+var proxyKinds = []struct {
+	name  string
+	proxy func(Proxies) *Proxy
+}{
+	{"web", func(p Proxies) *Proxy { return p.HTTP }},
+	{"secureweb", func(p Proxies) *Proxy { return p.HTTPS }},
+	{"socksfirewall", func(p Proxies) *Proxy { return p.SOCKS }},
+}
+
+// SetProxies sets the proxy configuration of the network service.
+func SetProxies(ctx context.Context, service string, proxies Proxies) error {
+	for _, args := range setProxiesArgs(service, proxies) {
+		if _, err := networksetup(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setProxiesArgs returns the networksetup arguments that set the configuration. "Empty" clears the bypass list.
+func setProxiesArgs(service string, proxies Proxies) [][]string {
+	var args [][]string
+	for _, kind := range proxyKinds {
+		if p := kind.proxy(proxies); p != nil {
+			args = append(args, []string{"-set" + kind.name + "proxy", service, p.Host, strconv.Itoa(p.Port)})
+		}
+		args = append(args, []string{"-set" + kind.name + "proxystate", service, onOff(kind.proxy(proxies) != nil)})
+	}
+
+	bypass := proxies.Bypass
+	if len(bypass) == 0 {
+		bypass = []string{"Empty"}
+	}
+	args = append(args, append([]string{"-setproxybypassdomains", service}, bypass...))
+
+	return args
+}
+
+// EnvSnippet returns a shell snippet that exports the proxy configuration to command line tools, which don't read
+// the system proxy settings, e.g. to be sourced from a shell profile.
+func EnvSnippet(proxies Proxies) string {
+	var b strings.Builder
+	export := func(name, value string) {
+		fmt.Fprintf(&b, "export %s=%q\n", name, value)
+		fmt.Fprintf(&b, "export %s=%q\n", strings.ToUpper(name), value)
+	}
+	if proxies.HTTP != nil {
+		export("http_proxy", "http://"+proxies.HTTP.String())
+	}
+	if proxies.HTTPS != nil {
+		export("https_proxy", "http://"+proxies.HTTPS.String())
+	}
+	if proxies.SOCKS != nil {
+		export("all_proxy", "socks5://"+proxies.SOCKS.String())
+	}
+	if len(proxies.Bypass) > 0 {
+		var noProxy []string
+		for _, host := range proxies.Bypass {
+			noProxy = append(noProxy, noProxyEntries(host)...)
+		}
+		export("no_proxy", strings.Join(noProxy, ","))
+	}
+
+	return b.String()
+}
+
+// noProxyEntries returns the no_proxy entries of a bypass list entry. Tools match no_proxy entries as domain suffixes
+// rather than globs, and only match full CIDR notation, if any, rather than the abbreviated networks macOS accepts,
+// e.g. "169.254/16". IMDS is listed by address when the network includes it, for tools that don't match networks.
+func noProxyEntries(host string) []string {
+	prefix, bits, ok := strings.Cut(host, "/")
+	if !ok {
+		return []string{strings.TrimPrefix(host, "*")}
+	}
+	if octets := strings.Count(prefix, ".") + 1; octets < 4 && net.ParseIP(prefix) == nil {
+		prefix += strings.Repeat(".0", 4-octets)
+	}
+	_, network, err := net.ParseCIDR(prefix + "/" + bits)
+	if err != nil {
+		return []string{host}
+	}
+	if network.Contains(net.ParseIP(imdsAddress)) {
+		return []string{imdsAddress, network.String()}
+	}
+
+	return []string{network.String()}
+}
+
+// onOff returns "on" for true and "off" for false.
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,54 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProxy(t *testing.T) {
+	p, err := ParseProxy("http://proxy.example.com:3128/")
+	assert.NoError(t, err)
+	assert.Equal(t, Proxy{Host: "proxy.example.com", Port: 3128}, p)
+
+	p, err = ParseProxy("[fd00::1]:1080")
+	assert.NoError(t, err)
+	assert.Equal(t, "[fd00::1]:1080", p.String())
+
+	for _, s := range []string{"proxy.example.com", ":3128", "proxy:0", "proxy:http", "user@proxy:3128"} {
+		_, err := ParseProxy(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSetProxiesArgs(t *testing.T) {
+	proxy := &Proxy{Host: "proxy", Port: 3128}
+	assert.Equal(t, [][]string{
+		{"-setwebproxy", "Ethernet", "proxy", "3128"},
+		{"-setwebproxystate", "Ethernet", "on"},
+		{"-setsecurewebproxy", "Ethernet", "proxy", "3128"},
+		{"-setsecurewebproxystate", "Ethernet", "on"},
+		{"-setsocksfirewallproxystate", "Ethernet", "off"},
+		{"-setproxybypassdomains", "Ethernet", "169.254.169.254", "*.internal"},
+	}, setProxiesArgs("Ethernet", Proxies{HTTP: proxy, HTTPS: proxy, Bypass: []string{"169.254.169.254", "*.internal"}}))
+
+	assert.Equal(t, []string{"-setproxybypassdomains", "Ethernet", "Empty"}, setProxiesArgs("Ethernet", Proxies{})[3])
+}
+
+func TestEnvSnippet(t *testing.T) {
+	assert.Equal(t, `export http_proxy="http://proxy:3128"
+export HTTP_PROXY="http://proxy:3128"
+export all_proxy="socks5://proxy:1080"
+export ALL_PROXY="socks5://proxy:1080"
+export no_proxy="169.254.169.254,.internal"
+export NO_PROXY="169.254.169.254,.internal"
+`, EnvSnippet(Proxies{
+		HTTP:   &Proxy{Host: "proxy", Port: 3128},
+		SOCKS:  &Proxy{Host: "proxy", Port: 1080},
+		Bypass: []string{"169.254.169.254", "*.internal"},
+	}))
+
+	assert.Equal(t, `export no_proxy=".local,169.254.169.254,169.254.0.0/16,10.0.0.0/8"
+export NO_PROXY=".local,169.254.169.254,169.254.0.0/16,10.0.0.0/8"
+`, EnvSnippet(Proxies{Bypass: []string{"*.local", "169.254/16", "10.0.0.0/8"}}))
+}
@@ -0,0 +1,211 @@
+// Package netsnapshot provides the functionality necessary for capturing the transient state of the host's network
+// stack, i.e. its TCP connections, their socket buffers, and the error counters of its network devices, when a network
+// check fails and before heavier diagnostics are collected, by which time that state has often changed.
+package netsnapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// halfOpenStates are the states of connections whose handshake hasn't completed.
+var halfOpenStates = map[string]bool{"SYN_SENT": true, "SYN_RCVD": true}
+
+// Connection is a TCP connection and the state of its socket buffers.
+type Connection struct {
+	Proto  string `json:"proto"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	State  string `json:"state"`
+	// RecvQ and SendQ are the bytes waiting in the socket buffers, to be read by the process and to be acknowledged
+	// by the peer.
+	RecvQ int `json:"recv_q"`
+	SendQ int `json:"send_q"`
+	// RecvBuffer and SendBuffer are the sizes (high-water marks) of the socket buffers.
+	RecvBuffer int `json:"recv_buffer"`
+	SendBuffer int `json:"send_buffer"`
+}
+
+// Interface is the packet and error counters of a network device since boot.
+type Interface struct {
+	Name       string `json:"name"`
+	MTU        int    `json:"mtu"`
+	InPackets  int64  `json:"in_packets"`
+	InErrors   int64  `json:"in_errors"`
+	OutPackets int64  `json:"out_packets"`
+	OutErrors  int64  `json:"out_errors"`
+	Collisions int64  `json:"collisions"`
+	Drops      int64  `json:"drops"`
+}
+
+// Snapshot is the state of the host's network stack at a point in time.
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	// Connections are the TCP connections that aren't listening, e.g. established and half-open ones.
+	Connections []Connection `json:"connections"`
+	Interfaces  []Interface  `json:"interfaces"`
+	// Errors are why parts of the snapshot couldn't be captured.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Summary counts what stands out in a snapshot, for logging.
+type Summary struct {
+	// States is the number of connections in each state.
+	States map[string]int
+	// HalfOpen is the number of connections whose handshake hasn't completed.
+	HalfOpen int
+	// Queued is the number of connections with bytes waiting in their socket buffers.
+	Queued int
+	// InterfaceErrors is the number of errors and drops of all the network devices.
+	InterfaceErrors int64
+}
+
+// Capture takes a snapshot of the network stack. It captures what it can: the parts that fail are recorded in the
+// snapshot's errors rather than failing it.
+func Capture(ctx context.Context) Snapshot {
+	s := Snapshot{Time: time.Now().UTC()}
+
+	out, err := util.ExecuteCommand(ctx, []string{"netstat", "-anv", "-p", "tcp"}, "", nil, nil)
+	if err != nil {
+		s.Errors = append(s.Errors, fmt.Sprintf("list connections: %s: %v", strings.TrimSpace(out.Stderr), err))
+	} else {
+		s.Connections = parseConnections(out.Stdout)
+	}
+
+	out, err = util.ExecuteCommand(ctx, []string{"netstat", "-ibdn"}, "", nil, nil)
+	if err != nil {
+		s.Errors = append(s.Errors, fmt.Sprintf("list interface counters: %s: %v", strings.TrimSpace(out.Stderr), err))
+	} else {
+		s.Interfaces = parseInterfaces(out.Stdout)
+	}
+
+	return s
+}
+
+// Summarize counts what stands out in the snapshot.
+func (s Snapshot) Summarize() Summary {
+	summary := Summary{States: map[string]int{}}
+	for _, c := range s.Connections {
+		summary.States[c.State]++
+		if halfOpenStates[c.State] {
+			summary.HalfOpen++
+		}
+		if c.RecvQ > 0 || c.SendQ > 0 {
+			summary.Queued++
+		}
+	}
+	for _, i := range s.Interfaces {
+		summary.InterfaceErrors += i.InErrors + i.OutErrors + i.Drops
+	}
+
+	return summary
+}
+
+// Encode writes the snapshot as JSON to w.
+func Encode(w io.Writer, s Snapshot) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode network snapshot: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write network snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// parseConnections parses the TCP connections that aren't listening from netstat -anv -p tcp, e.g.
+// "tcp4  0  0  10.0.0.5.22  10.0.1.9.51234  ESTABLISHED  131072  131768 ...". The columns vary between macOS
+// releases, e.g. some list the bytes sent and received before the receive and send buffer sizes (rhiwat and shiwat),
+// so they're located from the header line, and rows before it are skipped.
+func parseConnections(output string) []Connection {
+	var (
+		conns   []Connection
+		columns map[string]int
+	)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "Proto" {
+			columns = connectionColumns(fields)
+			continue
+		}
+		if columns == nil || len(fields) <= columns["(state)"] || !strings.HasPrefix(fields[0], "tcp") {
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return fields[i]
+			}
+			return ""
+		}
+		if field("(state)") == "LISTEN" {
+			continue
+		}
+		c := Connection{Proto: fields[0], Local: field("Local"), Remote: field("Foreign"), State: field("(state)")}
+		c.RecvQ, _ = strconv.Atoi(field("Recv-Q"))
+		c.SendQ, _ = strconv.Atoi(field("Send-Q"))
+		c.RecvBuffer, _ = strconv.Atoi(field("rhiwat"))
+		c.SendBuffer, _ = strconv.Atoi(field("shiwat"))
+		conns = append(conns, c)
+	}
+
+	return conns
+}
+
+// connectionColumns returns the index of each column of the rows from the header of netstat -anv -p tcp, e.g.
+// "Proto Recv-Q Send-Q  Local Address  Foreign Address  (state) ...", where "Address" belongs to the column before
+// it. Only the first of columns with the same name is kept, e.g. "(state)" rather than the later "state" flags.
+func connectionColumns(header []string) map[string]int {
+	columns := map[string]int{}
+	i := 0
+	for _, name := range header {
+		if name == "Address" {
+			continue
+		}
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+		i++
+	}
+
+	return columns
+}
+
+// parseInterfaces parses the counters of each network device from netstat -ibdn, which lists a device once per
+// address. Only the link-layer rows are kept, e.g. "en0  9001  <Link#4>  06:7d:1c:2e:3f:40  1000  0 ...", whose
+// address is missing for devices without one, such as lo0. The last columns are Ipkts, Ierrs, Ibytes, Opkts, Oerrs,
+// Obytes, Coll, and Drop.
+func parseInterfaces(output string) []Interface {
+	var ifaces []Interface
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		n := len(fields)
+		if n < 11 || !strings.HasPrefix(fields[2], "<Link#") {
+			continue
+		}
+		counter := func(i int) int64 {
+			v, _ := strconv.ParseInt(fields[i], 10, 64)
+			return v
+		}
+		i := Interface{
+			Name:       fields[0],
+			InPackets:  counter(n - 8),
+			InErrors:   counter(n - 7),
+			OutPackets: counter(n - 5),
+			OutErrors:  counter(n - 4),
+			Collisions: counter(n - 2),
+			Drops:      counter(n - 1),
+		}
+		i.MTU, _ = strconv.Atoi(fields[1])
+		ifaces = append(ifaces, i)
+	}
+
+	return ifaces
+}
@@ -0,0 +1,87 @@
+package netsnapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const netstatConnections = `Active Internet connections (including servers)
+Proto Recv-Q Send-Q  Local Address          Foreign Address        (state)      rhiwat  shiwat    pid   epid state  options
+tcp4       0      0  10.0.0.5.22            10.0.1.9.51234         ESTABLISHED  131072  131768   1234      0 00102 00000000
+tcp4       0  48212  10.0.0.5.50112         169.254.169.254.80     ESTABLISHED  131072  131768   2345      0 00102 00000000
+tcp4       0      0  10.0.0.5.50113         169.254.169.254.80     SYN_SENT     131072  131768   2345      0 00102 00000000
+tcp46      0      0  *.22                   *.*                    LISTEN       131072  131072      1      0 00100 00000006
+`
+
+const netstatInterfaces = `Name       Mtu   Network       Address            Ipkts Ierrs     Ibytes    Opkts Oerrs     Obytes  Coll Drop
+lo0        16384 <Link#1>                         1200     0     104000     1200     0     104000     0   0
+lo0        16384 127           127.0.0.1          1200     -     104000     1200     -     104000     -   -
+en0        9001  <Link#6>    06:7d:1c:2e:3f:40   98000     3   81000000    76000     0   12000000     0  12
+en0        9001  10.0.0/24     10.0.0.5          98000     -   81000000    76000     -   12000000     -   -
+`
+
+func TestParseConnections(t *testing.T) {
+	conns := parseConnections(netstatConnections)
+	require.Len(t, conns, 3, "listening sockets shouldn't be kept")
+	assert.Equal(t, Connection{
+		Proto:      "tcp4",
+		Local:      "10.0.0.5.50112",
+		Remote:     "169.254.169.254.80",
+		State:      "ESTABLISHED",
+		SendQ:      48212,
+		RecvBuffer: 131072,
+		SendBuffer: 131768,
+	}, conns[1])
+	assert.Equal(t, "SYN_SENT", conns[2].State)
+
+	// Releases that list the bytes received and sent before the buffer sizes.
+	conns = parseConnections(`Proto Recv-Q Send-Q  Local Address  Foreign Address  (state)  rxbytes  txbytes  rhiwat  shiwat  pid  epid  state  options
+tcp4  0  0  10.0.0.5.22  10.0.1.9.51234  ESTABLISHED  5120  9216  131072  131768  1234  0  00102  00000000
+`)
+	require.Len(t, conns, 1)
+	assert.Equal(t, 131072, conns[0].RecvBuffer)
+	assert.Equal(t, 131768, conns[0].SendBuffer)
+
+	assert.Empty(t, parseConnections("tcp4  0  0  10.0.0.5.22  10.0.1.9.51234  ESTABLISHED\n"),
+		"rows can't be parsed without the header")
+}
+
+func TestParseInterfaces(t *testing.T) {
+	ifaces := parseInterfaces(netstatInterfaces)
+	assert.Equal(t, []Interface{
+		{Name: "lo0", MTU: 16384, InPackets: 1200, OutPackets: 1200},
+		{Name: "en0", MTU: 9001, InPackets: 98000, InErrors: 3, OutPackets: 76000, Drops: 12},
+	}, ifaces)
+}
+
+func TestSnapshot_Summarize(t *testing.T) {
+	s := Snapshot{
+		Connections: parseConnections(netstatConnections),
+		Interfaces:  parseInterfaces(netstatInterfaces),
+	}
+	assert.Equal(t, Summary{
+		States:          map[string]int{"ESTABLISHED": 2, "SYN_SENT": 1},
+		HalfOpen:        1,
+		Queued:          1,
+		InterfaceErrors: 15,
+	}, s.Summarize())
+}
+
+func TestEncode(t *testing.T) {
+	s := Snapshot{
+		Time:        time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Connections: parseConnections(netstatConnections),
+		Errors:      []string{"list interface counters: exit status 1"},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, s))
+
+	var saved Snapshot
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &saved))
+	assert.Equal(t, s, saved)
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+const (
+	// DefaultEventBus is the name of the account's default event bus.
+	DefaultEventBus = "default"
+
+	// maxEntries is the maximum number of entries in a PutEvents request.
+	maxEntries = 10
+)
+
+// EventBridgeAPI is the subset of the EventBridge client used by the EventBridge notifier.
+type EventBridgeAPI interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// EventBridge publishes events to an EventBridge event bus with the source "ec2-macos-utils" and the detail-type
+// "<check> check" (e.g. "imds check"), so rules can match the results of individual checks:
+//
+//	{"source": ["ec2-macos-utils"], "detail-type": ["imds check"], "detail": {"passed": [false]}}
+type EventBridge struct {
+	// Client is the EventBridge API client.
+	Client EventBridgeAPI
+	// EventBus is the name or ARN of the event bus. Empty uses the default event bus.
+	EventBus string
+}
+
+// DetailType returns the detail-type of the event for a check.
+func DetailType(check string) string {
+	return check + " check"
+}
+
+// Notify publishes the events, failing if any of them was rejected.
+func (b *EventBridge) Notify(ctx context.Context, events ...Event) error {
+	bus := b.EventBus
+	if bus == "" {
+		bus = DefaultEventBus
+	}
+
+	for len(events) > 0 {
+		n := min(len(events), maxEntries)
+		entries := make([]types.PutEventsRequestEntry, 0, n)
+		for _, e := range events[:n] {
+			detail, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("encode %s event: %w", e.Check, err)
+			}
+			entries = append(entries, types.PutEventsRequestEntry{
+				EventBusName: aws.String(bus),
+				Source:       aws.String(Source),
+				DetailType:   aws.String(DetailType(e.Check)),
+				Detail:       aws.String(string(detail)),
+				Time:         aws.Time(e.Time),
+			})
+		}
+
+		out, err := b.Client.PutEvents(ctx, &eventbridge.PutEventsInput{Entries: entries})
+		if err != nil {
+			return fmt.Errorf("put events: %w", err)
+		}
+		if out.FailedEntryCount > 0 {
+			var errs []error
+			for i, entry := range out.Entries {
+				if entry.ErrorCode != nil {
+					errs = append(errs, fmt.Errorf("%s event: %s: %s", events[i].Check, aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage)))
+				}
+			}
+			return fmt.Errorf("put events: %d rejected: %w", out.FailedEntryCount, errors.Join(errs...))
+		}
+
+		events = events[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEventBridge struct {
+	inputs []*eventbridge.PutEventsInput
+	// reject rejects entries with the detail-type.
+	reject string
+}
+
+func (f *fakeEventBridge) PutEvents(_ context.Context, in *eventbridge.PutEventsInput, _ ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	f.inputs = append(f.inputs, in)
+
+	out := &eventbridge.PutEventsOutput{}
+	for _, entry := range in.Entries {
+		if aws.ToString(entry.DetailType) == f.reject {
+			out.FailedEntryCount++
+			out.Entries = append(out.Entries, types.PutEventsResultEntry{ErrorCode: aws.String("AccessDenied"), ErrorMessage: aws.String("denied")})
+			continue
+		}
+		out.Entries = append(out.Entries, types.PutEventsResultEntry{EventId: aws.String("id")})
+	}
+
+	return out, nil
+}
+
+func TestEventBridge_Notify(t *testing.T) {
+	api := &fakeEventBridge{}
+	n := &EventBridge{Client: api}
+
+	e := NewEvent("imds", errors.New("timeout"))
+	e.InstanceID = "i-123"
+	assert.NoError(t, n.Notify(context.Background(), e, NewEvent("disk", nil)))
+
+	assert.Len(t, api.inputs, 1)
+	entries := api.inputs[0].Entries
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "default", aws.ToString(entries[0].EventBusName))
+	assert.Equal(t, "ec2-macos-utils", aws.ToString(entries[0].Source))
+	assert.Equal(t, "imds check", aws.ToString(entries[0].DetailType))
+	assert.Equal(t, "disk check", aws.ToString(entries[1].DetailType))
+
+	var detail map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(aws.ToString(entries[0].Detail)), &detail))
+	assert.Equal(t, false, detail["passed"])
+	assert.Equal(t, "timeout", detail["error"])
+	assert.Equal(t, "i-123", detail["instance_id"])
+}
+
+func TestEventBridge_NotifyBatches(t *testing.T) {
+	api := &fakeEventBridge{}
+	n := &EventBridge{Client: api, EventBus: "fleet"}
+
+	var events []Event
+	for i := 0; i < 25; i++ {
+		events = append(events, NewEvent(fmt.Sprint(i), nil))
+	}
+	assert.NoError(t, n.Notify(context.Background(), events...))
+
+	assert.Len(t, api.inputs, 3)
+	assert.Len(t, api.inputs[2].Entries, 5)
+	assert.Equal(t, "fleet", aws.ToString(api.inputs[2].Entries[0].EventBusName))
+}
+
+func TestEventBridge_NotifyRejected(t *testing.T) {
+	api := &fakeEventBridge{reject: "imds check"}
+	n := &EventBridge{Client: api}
+
+	err := n.Notify(context.Background(), NewEvent("disk", nil), NewEvent("imds", nil))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "imds event: AccessDenied")
+}
+
+type fakeNotifier struct {
+	events []Event
+	err    error
+}
+
+func (f *fakeNotifier) Notify(_ context.Context, events ...Event) error {
+	f.events = append(f.events, events...)
+	return f.err
+}
+
+func TestMulti_Notify(t *testing.T) {
+	failing := &fakeNotifier{err: errors.New("unavailable")}
+	working := &fakeNotifier{}
+
+	err := Multi{failing, working}.Notify(context.Background(), NewEvent("imds", nil))
+	assert.Error(t, err)
+	assert.Len(t, working.events, 1, "a failing notifier shouldn't suppress the others")
+}
@@ -0,0 +1,71 @@
+// Package notify provides the functionality necessary for publishing check results to notification backends.
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Source identifies EC2 macOS Utils as the origin of events.
+const Source = "ec2-macos-utils"
+
+// Event is the result of a check.
+type Event struct {
+	// Check is the name of the check (e.g. imds).
+	Check string `json:"check"`
+	// Passed reports whether the check passed.
+	Passed bool `json:"passed"`
+	// Error describes why the check failed.
+	Error string `json:"error,omitempty"`
+	// InstanceID is the ID of the instance the check ran on.
+	InstanceID string `json:"instance_id,omitempty"`
+	// RunID correlates the event with the logs and artifacts of the invocation that produced it.
+	RunID string `json:"run_id,omitempty"`
+	// Version is the version of EC2 macOS Utils that ran the check.
+	Version string `json:"version,omitempty"`
+	// Time is when the check completed.
+	Time time.Time `json:"time"`
+	// Capture is the diagnostic data collected because of the failure, if any.
+	Capture *Capture `json:"capture,omitempty"`
+}
+
+// Capture describes diagnostic data collected because of a failed check, such as a sysdiagnose archive.
+type Capture struct {
+	// Path is where the data was saved on the instance.
+	Path string `json:"path"`
+	// Reason is why the data was collected, and Trigger what triggered the collection, as recorded in its manifest.
+	Reason  string            `json:"reason,omitempty"`
+	Trigger map[string]string `json:"trigger,omitempty"`
+}
+
+// NewEvent creates an event for the result of the named check.
+func NewEvent(check string, err error) Event {
+	e := Event{Check: check, Passed: err == nil, Time: time.Now().UTC()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return e
+}
+
+// Notifier publishes events to a backend.
+type Notifier interface {
+	Notify(ctx context.Context, events ...Event) error
+}
+
+// Multi publishes events to every notifier.
+type Multi []Notifier
+
+// Notify publishes the events to every notifier, continuing past failures so that one unavailable backend doesn't
+// suppress the others.
+func (m Multi) Notify(ctx context.Context, events ...Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, events...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
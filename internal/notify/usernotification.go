@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/redact"
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// userNotificationTitle is the title of the notifications posted by UserNotification.
+const userNotificationTitle = "EC2 macOS Utils"
+
+// UserNotification posts the failed checks as macOS user notifications, which appear in Notification Center of the
+// user logged in on the console, e.g. a developer using the instance over Screen Sharing. Passed checks aren't posted
+// since nobody needs to act on them, and nothing is posted when nobody is logged in.
+type UserNotification struct {
+	// execute runs a command, and is replaced in tests.
+	execute func(ctx context.Context, argv []string) (util.CommandOutput, error)
+}
+
+// Notify posts a notification for each failed check.
+func (n *UserNotification) Notify(ctx context.Context, events ...Event) error {
+	var failed []Event
+	for _, e := range events {
+		if !e.Passed {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	var prefix []string
+	// Notifications are only shown when they're posted from the console user's session, so root, e.g. a watchdog
+	// daemon, posts them in its bootstrap namespace.
+	if os.Geteuid() == 0 {
+		uid, err := n.consoleUID(ctx)
+		if err != nil {
+			return err
+		}
+		if uid == "" {
+			logrus.Debug("Nobody is logged in on the console, not posting notifications")
+			return nil
+		}
+		prefix = []string{"launchctl", "asuser", uid}
+	}
+
+	var errs []error
+	for _, e := range failed {
+		argv := append(append([]string(nil), prefix...), "osascript", "-e", notificationScript(e))
+		if out, err := n.run(ctx, argv); err != nil {
+			errs = append(errs, fmt.Errorf("post %s notification: %s: %w", e.Check, strings.TrimSpace(out.Stderr), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// consoleUID returns the user ID of the user logged in on the console, or an empty string when nobody is.
+func (n *UserNotification) consoleUID(ctx context.Context) (string, error) {
+	out, err := n.run(ctx, []string{"stat", "-f", "%u", "/dev/console"})
+	if err != nil {
+		return "", fmt.Errorf("find console user: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	uid := strings.TrimSpace(out.Stdout)
+	if uid == "0" {
+		return "", nil
+	}
+
+	return uid, nil
+}
+
+func (n *UserNotification) run(ctx context.Context, argv []string) (util.CommandOutput, error) {
+	if n.execute != nil {
+		return n.execute(ctx, argv)
+	}
+
+	return util.ExecuteCommand(ctx, argv, "", nil, nil)
+}
+
+// notificationScript returns the AppleScript that posts the notification of the failed check.
+func notificationScript(e Event) string {
+	message := "The check failed."
+	if e.Error != "" {
+		message = redact.String(e.Error)
+	}
+	if e.Capture != nil {
+		message += " (sysdiagnose collected)"
+	}
+
+	return fmt.Sprintf("display notification %s with title %s subtitle %s",
+		appleScriptString(message), appleScriptString(userNotificationTitle), appleScriptString(e.Check+" check failed"))
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
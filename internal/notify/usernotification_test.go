@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// fakeExecutor records the commands run and answers stat with the console user ID.
+type fakeExecutor struct {
+	consoleUID string
+	commands   [][]string
+}
+
+func (f *fakeExecutor) execute(_ context.Context, argv []string) (util.CommandOutput, error) {
+	f.commands = append(f.commands, argv)
+	if argv[0] == "stat" {
+		return util.CommandOutput{Stdout: f.consoleUID + "\n"}, nil
+	}
+
+	return util.CommandOutput{}, nil
+}
+
+func TestUserNotification_Notify(t *testing.T) {
+	exec := &fakeExecutor{consoleUID: "501"}
+	n := &UserNotification{execute: exec.execute}
+
+	assert.NoError(t, n.Notify(context.Background(), NewEvent("disk", nil), NewEvent("imds", errors.New(`connect "169.254.169.254": timeout`))))
+
+	posted := exec.commands[len(exec.commands)-1]
+	if os.Geteuid() == 0 {
+		assert.Len(t, exec.commands, 2, "only failed checks should be posted")
+		assert.Equal(t, []string{"launchctl", "asuser", "501"}, posted[:3], "root should post in the console user's session")
+		posted = posted[3:]
+	} else {
+		assert.Len(t, exec.commands, 1, "only failed checks should be posted")
+	}
+	assert.Equal(t, []string{"osascript", "-e", `display notification "connect \"169.254.169.254\": timeout" with title "EC2 macOS Utils" subtitle "imds check failed"`}, posted)
+}
+
+func TestUserNotification_NoConsoleUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("the console user is only looked up as root")
+	}
+	exec := &fakeExecutor{consoleUID: "0"}
+	n := &UserNotification{execute: exec.execute}
+
+	assert.NoError(t, n.Notify(context.Background(), NewEvent("imds", errors.New("timeout"))))
+	assert.Len(t, exec.commands, 1, "nothing should be posted when nobody is logged in")
+}
+
+func TestAppleScriptString(t *testing.T) {
+	assert.Equal(t, `"a \"quoted\" \\ path"`, appleScriptString(`a "quoted" \ path`))
+}
+
+func TestNotificationScript_Capture(t *testing.T) {
+	e := NewEvent("imds", errors.New("timeout"))
+	e.Capture = &Capture{Path: "/tmp/sysdiagnose.tar.gz", Reason: "IMDS connectivity check failed"}
+	assert.Equal(t, `display notification "timeout (sysdiagnose collected)" with title "EC2 macOS Utils" subtitle "imds check failed"`, notificationScript(e))
+}
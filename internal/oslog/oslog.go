@@ -0,0 +1,95 @@
+// Package oslog provides the functionality necessary for mirroring log entries into the macOS unified logging system,
+// so that they appear in log show and Console alongside system events.
+package oslog
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Subsystem is the unified logging subsystem that entries are logged under, e.g. for
+// log show --predicate 'subsystem == "com.amazon.ec2-macos-utils"'.
+const Subsystem = "com.amazon.ec2-macos-utils"
+
+// ErrUnsupported is returned when the unified logging system isn't available, i.e. not on macOS or in builds without
+// cgo.
+var ErrUnsupported = errors.New("unified logging is not supported by this build")
+
+// Type is the type of a unified log entry, which determines whether it's persisted and how it's shown.
+type Type int
+
+const (
+	// TypeDebug entries are only kept in memory, and only when debug logging is enabled for the subsystem.
+	TypeDebug Type = iota
+	// TypeInfo entries are kept in memory and only persisted around errors.
+	TypeInfo
+	// TypeDefault entries are persisted.
+	TypeDefault
+	// TypeError entries are persisted and shown as errors.
+	TypeError
+	// TypeFault entries are persisted and shown as faults.
+	TypeFault
+)
+
+// levelTypes maps logrus levels to unified log entry types.
+var levelTypes = map[logrus.Level]Type{
+	logrus.TraceLevel: TypeDebug,
+	logrus.DebugLevel: TypeDebug,
+	logrus.InfoLevel:  TypeInfo,
+	logrus.WarnLevel:  TypeDefault,
+	logrus.ErrorLevel: TypeError,
+	logrus.FatalLevel: TypeFault,
+	logrus.PanicLevel: TypeFault,
+}
+
+// Hook is a logrus.Hook that mirrors log entries into the unified logging system under Subsystem.
+type Hook struct {
+	write func(t Type, message string)
+}
+
+// NewHook returns a hook that logs under Subsystem with the category, e.g. the command being run.
+func NewHook(category string) (*Hook, error) {
+	write, err := newWriter(Subsystem, category)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Hook{write: write}, nil
+}
+
+// Levels returns all log levels, leaving it to the logger's level to select the entries that are mirrored.
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire logs the entry.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	h.write(levelTypes[entry.Level], format(entry))
+	return nil
+}
+
+// format formats the entry's message followed by its fields in name order. The time and level aren't included since
+// the unified log records them itself.
+func format(entry *logrus.Entry) string {
+	var b strings.Builder
+	b.WriteString(entry.Message)
+
+	names := make([]string, 0, len(entry.Data))
+	for name := range entry.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := entry.Data[name]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		fmt.Fprintf(&b, " %s=%v", name, value)
+	}
+
+	return b.String()
+}
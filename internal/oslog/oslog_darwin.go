@@ -0,0 +1,41 @@
+//go:build darwin && cgo
+
+package oslog
+
+/*
+#include <os/log.h>
+#include <stdlib.h>
+
+// oslog_write logs msg as a public string so that it isn't redacted as <private>.
+static void oslog_write(os_log_t log, os_log_type_t type, const char *msg) {
+	os_log_with_type(log, type, "%{public}s", msg);
+}
+*/
+import "C"
+
+import "unsafe"
+
+// osLogTypes maps entry types to the os_log_type_t values.
+var osLogTypes = map[Type]C.os_log_type_t{
+	TypeDebug:   C.OS_LOG_TYPE_DEBUG,
+	TypeInfo:    C.OS_LOG_TYPE_INFO,
+	TypeDefault: C.OS_LOG_TYPE_DEFAULT,
+	TypeError:   C.OS_LOG_TYPE_ERROR,
+	TypeFault:   C.OS_LOG_TYPE_FAULT,
+}
+
+// newWriter returns a function that logs messages with os_log under the subsystem and category. The log object lives
+// for the rest of the process, as os_log objects are meant to.
+func newWriter(subsystem, category string) (func(t Type, message string), error) {
+	cSubsystem := C.CString(subsystem)
+	defer C.free(unsafe.Pointer(cSubsystem))
+	cCategory := C.CString(category)
+	defer C.free(unsafe.Pointer(cCategory))
+	log := C.os_log_create(cSubsystem, cCategory)
+
+	return func(t Type, message string) {
+		cMessage := C.CString(message)
+		defer C.free(unsafe.Pointer(cMessage))
+		C.oslog_write(log, osLogTypes[t], cMessage)
+	}, nil
+}
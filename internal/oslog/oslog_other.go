@@ -0,0 +1,8 @@
+//go:build !darwin || !cgo
+
+package oslog
+
+// newWriter returns ErrUnsupported, as os_log can only be called through cgo on macOS.
+func newWriter(_, _ string) (func(t Type, message string), error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,41 @@
+package oslog
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHook_Fire(t *testing.T) {
+	type written struct {
+		t       Type
+		message string
+	}
+	var entries []written
+	h := &Hook{write: func(t Type, message string) { entries = append(entries, written{t, message}) }}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	logger.ReplaceHooks(logrus.LevelHooks{})
+	logger.AddHook(h)
+	logger.Out = io.Discard
+
+	logger.Debug("not mirrored")
+	logger.WithFields(logrus.Fields{"size": 42, "disk": "disk1"}).Info("Resized container")
+	logger.WithError(errors.New("no space")).Error("Resize failed")
+
+	assert.Equal(t, []written{
+		{TypeInfo, "Resized container disk=disk1 size=42"},
+		{TypeError, "Resize failed error=no space"},
+	}, entries)
+}
+
+func TestLevelTypes(t *testing.T) {
+	for _, level := range logrus.AllLevels {
+		_, ok := levelTypes[level]
+		assert.True(t, ok, level.String())
+	}
+}
@@ -0,0 +1,158 @@
+// Package output provides the functionality necessary for formatting command results for terminals and scripts.
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences used for styling.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// Styler applies terminal styling to text when the destination supports it.
+type Styler struct {
+	color bool
+}
+
+// Plain is a Styler that never applies styling.
+var Plain = &Styler{}
+
+// NewStyler creates a Styler for w. Color is enabled only when w is a terminal, noColor is unset, the NO_COLOR
+// environment variable is unset, and TERM is not "dumb".
+func NewStyler(w io.Writer, noColor bool) *Styler {
+	return &Styler{color: !noColor && colorEnvironment() && IsTerminal(w)}
+}
+
+// IsTerminal reports whether w is a character device such as a TTY.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEnvironment checks the environment's conventional opt-outs for colored output.
+func colorEnvironment() bool {
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return false
+	}
+
+	return os.Getenv("TERM") != "dumb"
+}
+
+// Color reports whether the Styler applies styling.
+func (s *Styler) Color() bool {
+	return s != nil && s.color
+}
+
+func (s *Styler) wrap(code, text string) string {
+	if !s.Color() {
+		return text
+	}
+
+	return code + text + ansiReset
+}
+
+// Bold styles text in bold.
+func (s *Styler) Bold(text string) string { return s.wrap(ansiBold, text) }
+
+// Good styles text to indicate success.
+func (s *Styler) Good(text string) string { return s.wrap(ansiGreen, text) }
+
+// Bad styles text to indicate failure.
+func (s *Styler) Bad(text string) string { return s.wrap(ansiRed, text) }
+
+// Caution styles text to indicate a warning.
+func (s *Styler) Caution(text string) string { return s.wrap(ansiYellow, text) }
+
+// Status renders a PASS or FAIL verdict for a check result.
+func (s *Styler) Status(pass bool) string {
+	if pass {
+		return s.Good("PASS")
+	}
+
+	return s.Bad("FAIL")
+}
+
+// ansiSequence matches the escape sequences applied by Styler.
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// visibleWidth returns the number of runes of text that occupy space on a terminal.
+func visibleWidth(text string) int {
+	return utf8.RuneCountInString(ansiSequence.ReplaceAllString(text, ""))
+}
+
+// Table writes aligned columns of text. Cells may be styled; alignment is computed on their visible width so
+// styling doesn't skew the layout.
+type Table struct {
+	styler  *Styler
+	headers []string
+	rows    [][]string
+}
+
+// NewTable creates a Table with the given column headers.
+func NewTable(styler *Styler, headers ...string) *Table {
+	return &Table{styler: styler, headers: headers}
+}
+
+// AddRow appends a row of cells to the table. Missing trailing cells are rendered empty and extra cells are
+// dropped.
+func (t *Table) AddRow(cells ...string) {
+	row := make([]string, len(t.headers))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+}
+
+// Render writes the table to w.
+func (t *Table) Render(w io.Writer) error {
+	const columnGap = 2
+
+	header := make([]string, len(t.headers))
+	widths := make([]int, len(t.headers))
+	for i, h := range t.headers {
+		header[i] = strings.ToUpper(h)
+		widths[i] = visibleWidth(header[i])
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if n := visibleWidth(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	for i := range header {
+		header[i] = t.styler.Bold(header[i])
+	}
+
+	for _, row := range append([][]string{header}, t.rows...) {
+		var line strings.Builder
+		for i, cell := range row {
+			line.WriteString(cell)
+			if i < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-visibleWidth(cell)+columnGap))
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line.String(), " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
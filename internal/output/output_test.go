@@ -0,0 +1,43 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStyler_Plain(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewStyler(&buf, false)
+
+	assert.False(t, s.Color(), "should not color output that isn't a terminal")
+	assert.Equal(t, "PASS", s.Status(true))
+	assert.Equal(t, "FAIL", s.Status(false))
+}
+
+func TestStyler_Color(t *testing.T) {
+	s := &Styler{color: true}
+
+	assert.Equal(t, "\x1b[32mPASS\x1b[0m", s.Status(true))
+	assert.Equal(t, "\x1b[31mFAIL\x1b[0m", s.Status(false))
+	assert.Equal(t, 4, visibleWidth(s.Status(true)), "should not count escape sequences as visible")
+}
+
+func TestTable_Render(t *testing.T) {
+	for _, s := range []*Styler{Plain, {color: true}} {
+		var buf bytes.Buffer
+		table := NewTable(s, "name", "state", "note")
+		table.AddRow("network-health-monitor", s.Good("armed"))
+		table.AddRow("x", s.Bad("captured"), "extra", "dropped")
+
+		assert.NoError(t, table.Render(&buf))
+
+		plain := ansiSequence.ReplaceAllString(buf.String(), "")
+		expected := "" +
+			"NAME                    STATE     NOTE\n" +
+			"network-health-monitor  armed\n" +
+			"x                       captured  extra\n"
+		assert.Equal(t, expected, plain, "should align on visible width (color=%v)", s.Color())
+	}
+}
@@ -0,0 +1,183 @@
+// Package plistutil provides the functionality necessary for decoding property lists, in either the XML or binary
+// format, from files and the output of commands like diskutil, and for reading the values of loosely typed plists
+// with defaults.
+package plistutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"howett.net/plist"
+)
+
+// ErrNoPlist is returned when the output of a command doesn't contain a plist.
+var ErrNoPlist = errors.New("no plist found in output")
+
+// plistStarts are the prefixes that start a plist in the output of a command, in the order they're looked for.
+var plistStarts = [][]byte{
+	[]byte("bplist00"),
+	[]byte("<?xml"),
+	[]byte("<!DOCTYPE plist"),
+	[]byte("<plist"),
+}
+
+// Decode decodes the plist data, in any format the plist package supports, into v. Empty data decodes into v without
+// changing it.
+func Decode(data []byte, v any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	_, err := plist.Unmarshal(data, v)
+
+	return err
+}
+
+// DecodeOutput decodes the plist in the output of a command into v. Commands sometimes print warnings before the plist,
+// so anything before its start is skipped.
+func DecodeOutput(output string, v any) error {
+	data := []byte(output)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	start := -1
+	for _, prefix := range plistStarts {
+		if i := bytes.Index(data, prefix); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+	if start < 0 {
+		return ErrNoPlist
+	}
+
+	return Decode(data[start:], v)
+}
+
+// DecodeFile reads the plist file at path and decodes it into v.
+func DecodeFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := Decode(data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Format returns the name of the format of the plist data, e.g. "XML" or "Binary".
+func Format(data []byte) (string, error) {
+	var v any
+	format, err := plist.Unmarshal(data, &v)
+	if err != nil {
+		return "", err
+	}
+
+	return plist.FormatNames[format], nil
+}
+
+// Dict is a plist dictionary whose values are read by key with defaults for when they're missing or of another type.
+type Dict map[string]any
+
+// ParseDict decodes the plist data, whose root must be a dictionary, into a Dict.
+func ParseDict(data []byte) (Dict, error) {
+	var d map[string]any
+	if _, err := plist.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	return Dict(d), nil
+}
+
+// ParseOutputDict decodes the plist in the output of a command, whose root must be a dictionary, into a Dict.
+func ParseOutputDict(output string) (Dict, error) {
+	var d map[string]any
+	if err := DecodeOutput(output, &d); err != nil {
+		return nil, err
+	}
+
+	return Dict(d), nil
+}
+
+// String returns the string value of key, or def.
+func (d Dict) String(key, def string) string {
+	if s, ok := d[key].(string); ok {
+		return s
+	}
+
+	return def
+}
+
+// Bool returns the boolean value of key, or def.
+func (d Dict) Bool(key string, def bool) bool {
+	if b, ok := d[key].(bool); ok {
+		return b
+	}
+
+	return def
+}
+
+// Int returns the integer value of key, or def. Reals are truncated.
+func (d Dict) Int(key string, def int64) int64 {
+	switch n := d[key].(type) {
+	case int64:
+		return n
+	case uint64:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+
+	return def
+}
+
+// Float returns the numeric value of key, or def.
+func (d Dict) Float(key string, def float64) float64 {
+	switch n := d[key].(type) {
+	case float64:
+		return n
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	}
+
+	return def
+}
+
+// Dict returns the dictionary value of key, or an empty Dict.
+func (d Dict) Dict(key string) Dict {
+	if m, ok := d[key].(map[string]any); ok {
+		return Dict(m)
+	}
+
+	return Dict{}
+}
+
+// Strings returns the strings in the array value of key, skipping values of other types.
+func (d Dict) Strings(key string) []string {
+	values, _ := d[key].([]any)
+	var strs []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+
+	return strs
+}
+
+// Dicts returns the dictionaries in the array value of key, skipping values of other types.
+func (d Dict) Dicts(key string) []Dict {
+	values, _ := d[key].([]any)
+	var dicts []Dict
+	for _, v := range values {
+		if m, ok := v.(map[string]any); ok {
+			dicts = append(dicts, Dict(m))
+		}
+	}
+
+	return dicts
+}
@@ -0,0 +1,137 @@
+package plistutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"howett.net/plist"
+)
+
+const xmlPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Name</key>
+	<string>disk0</string>
+	<key>Size</key>
+	<integer>500277790720</integer>
+	<key>Internal</key>
+	<true/>
+	<key>Ratio</key>
+	<real>0.5</real>
+	<key>Children</key>
+	<array>
+		<string>disk0s1</string>
+		<integer>2</integer>
+		<string>disk0s2</string>
+	</array>
+	<key>Volumes</key>
+	<array>
+		<dict>
+			<key>Name</key>
+			<string>Macintosh HD</string>
+		</dict>
+	</array>
+	<key>Parent</key>
+	<dict>
+		<key>Name</key>
+		<string>disk1</string>
+	</dict>
+</dict>
+</plist>
+`
+
+type disk struct {
+	Name     string `plist:"Name"`
+	Size     uint64 `plist:"Size"`
+	Internal bool   `plist:"Internal"`
+}
+
+func TestDecode_Formats(t *testing.T) {
+	binary, err := plist.Marshal(disk{Name: "disk0", Size: 500277790720, Internal: true}, plist.BinaryFormat)
+	require.NoError(t, err)
+
+	for name, data := range map[string][]byte{
+		"XML":    []byte(xmlPlist),
+		"Binary": binary,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var d disk
+			assert.NoError(t, Decode(data, &d))
+			assert.Equal(t, disk{Name: "disk0", Size: 500277790720, Internal: true}, d)
+
+			format, err := Format(data)
+			assert.NoError(t, err)
+			assert.Equal(t, name, format)
+		})
+	}
+}
+
+func TestDecode_Empty(t *testing.T) {
+	d := disk{Name: "unchanged"}
+	assert.NoError(t, Decode([]byte(" \n"), &d))
+	assert.Equal(t, "unchanged", d.Name)
+}
+
+func TestDecodeOutput(t *testing.T) {
+	var d disk
+	output := "diskutil: warning: something happened\n" + xmlPlist
+	assert.NoError(t, DecodeOutput(output, &d))
+	assert.Equal(t, "disk0", d.Name)
+
+	assert.ErrorIs(t, DecodeOutput("Could not find disk: disk9", &d), ErrNoPlist)
+	assert.NoError(t, DecodeOutput("", &d), "empty output should decode to nothing")
+}
+
+func TestDecodeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.plist")
+	require.NoError(t, os.WriteFile(path, []byte(xmlPlist), 0644))
+
+	var d disk
+	assert.NoError(t, DecodeFile(path, &d))
+	assert.Equal(t, "disk0", d.Name)
+
+	assert.ErrorIs(t, DecodeFile(filepath.Join(t.TempDir(), "missing.plist"), &d), os.ErrNotExist)
+}
+
+func TestDict(t *testing.T) {
+	d, err := ParseDict([]byte(xmlPlist))
+	require.NoError(t, err)
+
+	assert.Equal(t, "disk0", d.String("Name", ""))
+	assert.Equal(t, "default", d.String("Missing", "default"))
+	assert.Equal(t, "default", d.String("Size", "default"), "values of another type should get the default")
+
+	assert.True(t, d.Bool("Internal", false))
+	assert.True(t, d.Bool("Missing", true))
+
+	assert.Equal(t, int64(500277790720), d.Int("Size", 0))
+	assert.Equal(t, int64(0), d.Int("Ratio", -1), "reals should be truncated")
+	assert.Equal(t, int64(-1), d.Int("Name", -1))
+
+	assert.Equal(t, 0.5, d.Float("Ratio", 0))
+	assert.Equal(t, float64(500277790720), d.Float("Size", 0))
+	assert.Equal(t, 1.5, d.Float("Missing", 1.5))
+
+	assert.Equal(t, "disk1", d.Dict("Parent").String("Name", ""))
+	assert.Equal(t, "", d.Dict("Missing").String("Name", ""), "missing dictionaries should be empty")
+
+	assert.Equal(t, []string{"disk0s1", "disk0s2"}, d.Strings("Children"))
+	assert.Empty(t, d.Strings("Missing"))
+
+	volumes := d.Dicts("Volumes")
+	require.Len(t, volumes, 1)
+	assert.Equal(t, "Macintosh HD", volumes[0].String("Name", ""))
+}
+
+func TestParseOutputDict(t *testing.T) {
+	d, err := ParseOutputDict("warning\n" + xmlPlist)
+	require.NoError(t, err)
+	assert.Equal(t, "disk0", d.String("Name", ""))
+
+	_, err = ParseDict([]byte("not a plist"))
+	assert.Error(t, err)
+}
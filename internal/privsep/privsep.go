@@ -0,0 +1,143 @@
+// Package privsep drops the root privileges of long-running commands once their privileged setup is done, so that
+// their steady-state work runs as an unprivileged service user and regains root only for the actions that need it.
+//
+// Only the effective IDs of the process are switched, and root remains its real user so that privileges can be
+// regained. This keeps the steady-state work from accidentally acting as root, e.g. writing files that only root can
+// remove, but it isn't a security boundary: code running in the process can regain root at any time.
+package privsep
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// credentials are the effective user, group, and supplementary groups of the process.
+type credentials struct {
+	uid    int
+	gid    int
+	groups []int
+}
+
+var (
+	// lookup, geteuid, and setCredentials are replaced in tests.
+	lookup         = user.Lookup
+	geteuid        = os.Geteuid
+	setCredentials = setEffectiveCredentials
+
+	mu sync.Mutex
+	// root and service are the credentials switched between once privileges are dropped, and nil before.
+	root, service *credentials
+	// depth is the number of Privileged calls running, which share root privileges.
+	depth int
+)
+
+// Drop switches the effective user and groups of the process to those of the named user. Only the effective IDs are
+// switched, and root remains the real user, so that root privileges can be regained with Privileged. Whatever was
+// opened before, e.g. listening sockets and log files, stays usable.
+func Drop(username string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if service != nil {
+		return errors.New("privileges already dropped")
+	}
+	if geteuid() != 0 {
+		return errors.New("cannot drop privileges: not running as root")
+	}
+	u, err := lookup(username)
+	if err != nil {
+		return fmt.Errorf("cannot drop privileges: %w", err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("cannot drop privileges: invalid user ID %q", u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("cannot drop privileges: invalid group ID %q", u.Gid)
+	}
+	if uid == 0 {
+		return fmt.Errorf("cannot drop privileges to %s: it's root", username)
+	}
+
+	groups, err := os.Getgroups()
+	if err != nil {
+		return fmt.Errorf("cannot drop privileges: %w", err)
+	}
+	current := credentials{uid: 0, gid: os.Getegid(), groups: groups}
+	target := &credentials{uid: uid, gid: gid, groups: []int{gid}}
+	if err := setCredentials(*target); err != nil {
+		return fmt.Errorf("cannot drop privileges to %s: %w", username, err)
+	}
+	root, service = &current, target
+	logrus.WithFields(logrus.Fields{
+		"user": username,
+		"uid":  uid,
+	}).Info("Dropped root privileges")
+
+	return nil
+}
+
+// Dropped reports whether privileges were dropped with Drop.
+func Dropped() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return service != nil
+}
+
+// Privileged runs fn with root privileges, regaining them for its duration when they were dropped, and just runs it
+// otherwise. Privileges are those of the process rather than of a goroutine, so other goroutines also run as root
+// while fn does; calls may be nested or run concurrently, and privileges are dropped again once the last returns. The
+// process exits when they can't be, rather than carry on as root.
+func Privileged(fn func() error) error {
+	if err := acquire(); err != nil {
+		return err
+	}
+	defer release()
+
+	return fn()
+}
+
+// acquire regains root privileges for the first of the running Privileged calls.
+func acquire() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if service == nil {
+		return nil
+	}
+	if depth == 0 {
+		if err := setCredentials(*root); err != nil {
+			return fmt.Errorf("cannot regain root privileges: %w", err)
+		}
+		logrus.Debug("Regained root privileges")
+	}
+	depth++
+
+	return nil
+}
+
+// release drops root privileges again after the last of the running Privileged calls.
+func release() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if service == nil {
+		return
+	}
+	depth--
+	if depth > 0 {
+		return
+	}
+	if err := setCredentials(*service); err != nil {
+		logrus.WithError(err).Fatal("Failed to drop root privileges again")
+	}
+	logrus.Debug("Dropped root privileges again")
+}
@@ -0,0 +1,23 @@
+//go:build darwin
+
+package privsep
+
+import "golang.org/x/sys/unix"
+
+// setEffectiveCredentials switches the effective IDs of the process to c. Root's effective user ID is regained first,
+// since only root can set the groups, and an unprivileged one is set last for the same reason.
+func setEffectiveCredentials(c credentials) error {
+	if c.uid == 0 {
+		if err := unix.Seteuid(0); err != nil {
+			return err
+		}
+	}
+	if err := unix.Setgroups(c.groups); err != nil {
+		return err
+	}
+	if err := unix.Setegid(c.gid); err != nil {
+		return err
+	}
+
+	return unix.Seteuid(c.uid)
+}
@@ -0,0 +1,11 @@
+//go:build !darwin
+
+package privsep
+
+import "errors"
+
+// setEffectiveCredentials returns errors.ErrUnsupported, as privileges are only dropped on macOS, where switching the
+// effective IDs applies to every thread of the process.
+func setEffectiveCredentials(credentials) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,103 @@
+package privsep
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fake replaces the process's credentials with a recording of the switches between them.
+func fake(t *testing.T, euid int) *[]int {
+	var switches []int
+	lookup = func(name string) (*user.User, error) {
+		if name != "_ec2macosutils" {
+			return nil, user.UnknownUserError(name)
+		}
+		return &user.User{Username: name, Uid: "250", Gid: "250"}, nil
+	}
+	geteuid = func() int { return euid }
+	setCredentials = func(c credentials) error {
+		switches = append(switches, c.uid)
+		return nil
+	}
+	t.Cleanup(func() {
+		lookup, geteuid, setCredentials = user.Lookup, os.Geteuid, setEffectiveCredentials
+		root, service, depth = nil, nil, 0
+	})
+
+	return &switches
+}
+
+func TestDrop(t *testing.T) {
+	switches := fake(t, 0)
+
+	assert.Error(t, Drop("nobody-here"), "unknown users should fail")
+	assert.False(t, Dropped())
+
+	require.NoError(t, Drop("_ec2macosutils"))
+	assert.True(t, Dropped())
+	assert.Equal(t, []int{250}, *switches)
+	assert.Error(t, Drop("_ec2macosutils"), "privileges should only be dropped once")
+}
+
+func TestDrop_NotRoot(t *testing.T) {
+	switches := fake(t, 501)
+
+	assert.Error(t, Drop("_ec2macosutils"))
+	assert.False(t, Dropped())
+	assert.Empty(t, *switches)
+}
+
+func TestPrivileged(t *testing.T) {
+	switches := fake(t, 0)
+
+	ran := false
+	require.NoError(t, Privileged(func() error { ran = true; return nil }))
+	assert.True(t, ran)
+	assert.Empty(t, *switches, "nothing should be switched before privileges are dropped")
+
+	require.NoError(t, Drop("_ec2macosutils"))
+	errFailed := errors.New("failed")
+	err := Privileged(func() error {
+		return Privileged(func() error { return errFailed })
+	})
+	assert.ErrorIs(t, err, errFailed)
+	assert.Equal(t, []int{250, 0, 250}, *switches, "nested calls should regain and drop privileges once")
+	assert.Zero(t, depth)
+}
+
+func TestPrivileged_Concurrent(t *testing.T) {
+	switches := fake(t, 0)
+	require.NoError(t, Drop("_ec2macosutils"))
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = Privileged(func() error { return nil })
+		}()
+	}
+	wg.Wait()
+
+	assert.Zero(t, depth)
+	require.NotEmpty(t, *switches)
+	assert.Equal(t, 250, (*switches)[len(*switches)-1], "privileges should be dropped after the last call")
+}
+
+func TestPrivileged_RegainFails(t *testing.T) {
+	fake(t, 0)
+	require.NoError(t, Drop("_ec2macosutils"))
+	setCredentials = func(credentials) error { return errors.New("operation not permitted") }
+
+	ran := false
+	err := Privileged(func() error { ran = true; return nil })
+	assert.Error(t, err)
+	assert.False(t, ran, "nothing should run without the privileges it needs")
+	assert.Zero(t, depth)
+}
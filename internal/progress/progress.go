@@ -0,0 +1,118 @@
+// Package progress provides the functionality necessary for reporting the progress of long-running operations to
+// users and wrapping tools.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// Unknown may be given as the percent of an update when the operation cannot estimate its completion.
+const Unknown = -1
+
+// Event is a single progress update emitted by a Reporter.
+type Event struct {
+	// Time is when the event was reported.
+	Time time.Time `json:"time"`
+	// Phase names the step of the operation that is in progress (e.g. "collect", "write").
+	Phase string `json:"phase"`
+	// Percent is the completion of the phase from 0 to 100. It is omitted when unknown.
+	Percent *float64 `json:"percent,omitempty"`
+	// Message is a human-readable description of the update.
+	Message string `json:"message,omitempty"`
+}
+
+// Reporter receives progress updates from long-running operations.
+type Reporter interface {
+	// Report records progress for the given phase. A negative percent (e.g. Unknown) indicates that completion
+	// cannot be estimated.
+	Report(phase string, percent float64, message string)
+}
+
+// Discard is a Reporter that drops all updates.
+var Discard Reporter = discard{}
+
+type discard struct{}
+
+func (discard) Report(string, float64, string) {}
+
+// jsonReporter writes each update as a single line of JSON.
+type jsonReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// NewJSON creates a Reporter that writes newline-delimited JSON events to w.
+func NewJSON(w io.Writer) Reporter {
+	return &jsonReporter{enc: json.NewEncoder(w), now: time.Now}
+}
+
+func (r *jsonReporter) Report(phase string, percent float64, message string) {
+	ev := Event{
+		Time:    r.now().UTC(),
+		Phase:   phase,
+		Message: message,
+	}
+	if percent >= 0 {
+		if percent > 100 {
+			percent = 100
+		}
+		ev.Percent = &percent
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Progress is best-effort and must never fail the operation being reported on.
+	_ = r.enc.Encode(ev)
+}
+
+// Writer counts bytes written through it and reports the phase's completion against an expected total, with the bytes
+// counted so far as the message. Updates are
+// only emitted when the completed percentage advances by at least one step to keep the event stream small.
+type Writer struct {
+	reporter Reporter
+	phase    string
+	total    int64
+	step     float64
+
+	written  int64
+	reported float64
+}
+
+// NewWriter creates a Writer for the phase that reports every step percent of total bytes. When total is not
+// positive, bytes are counted without emitting updates.
+func NewWriter(r Reporter, phase string, total int64, step float64) *Writer {
+	return &Writer{
+		reporter: r,
+		phase:    phase,
+		total:    total,
+		step:     step,
+		reported: -step,
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	if w.total <= 0 {
+		return len(p), nil
+	}
+
+	pct := float64(w.written) / float64(w.total) * 100
+	if pct-w.reported >= w.step || (pct >= 100 && w.reported < 100) {
+		w.reported = pct
+		w.reporter.Report(w.phase, pct, fmt.Sprintf("%s of %s", units.HumanSize(float64(w.written)), units.HumanSize(float64(w.total))))
+	}
+
+	return len(p), nil
+}
+
+// Written returns the number of bytes counted so far.
+func (w *Writer) Written() int64 {
+	return w.written
+}
@@ -0,0 +1,71 @@
+package progress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeEvents(t *testing.T, buf *bytes.Buffer) []Event {
+	var events []Event
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("line is not a JSON event: %q", scanner.Text())
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestJSONReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewJSON(&buf).(*jsonReporter)
+	r.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	r.Report("collect", Unknown, "running")
+	r.Report("write", 150, "done")
+
+	events := decodeEvents(t, &buf)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "collect", events[0].Phase)
+		assert.Nil(t, events[0].Percent, "should omit unknown percent")
+		assert.Equal(t, "running", events[0].Message)
+
+		if assert.NotNil(t, events[1].Percent) {
+			assert.Equal(t, 100.0, *events[1].Percent, "should clamp percent")
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(NewJSON(&buf), "write", 100, 25)
+
+	for i := 0; i < 10; i++ {
+		_, _ = w.Write(make([]byte, 10))
+	}
+
+	assert.Equal(t, int64(100), w.Written())
+	events := decodeEvents(t, &buf)
+	var percents []float64
+	for _, ev := range events {
+		percents = append(percents, *ev.Percent)
+	}
+	assert.Equal(t, []float64{10, 40, 70, 100}, percents, "should only report at step boundaries and completion")
+}
+
+func TestWriter_UnknownTotal(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(NewJSON(&buf), "write", 0, 5)
+
+	_, _ = w.Write(make([]byte, 10))
+
+	assert.Equal(t, int64(10), w.Written())
+	assert.Empty(t, buf.String(), "should not report without a total")
+}
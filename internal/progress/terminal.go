@@ -0,0 +1,168 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// barWidth is the number of characters of the bar drawn by Bar.
+const barWidth = 30
+
+// clearLine returns the cursor to the start of the line and clears it.
+const clearLine = "\r\x1b[K"
+
+// Bar is a Reporter that draws the progress of the current phase as a bar with an estimate of the time remaining on a
+// single line of a terminal, which is redrawn with each update and ended when the phase completes.
+type Bar struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+
+	phase   string
+	started time.Time
+	// line is the line currently drawn, empty when there's none.
+	line string
+}
+
+// NewBar creates a Bar that draws on w, which should be a terminal.
+func NewBar(w io.Writer) *Bar {
+	return &Bar{w: w, now: time.Now}
+}
+
+// Report draws the update. Phases that can't estimate their completion are drawn with their message only.
+func (b *Bar) Report(phase string, percent float64, message string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if phase != b.phase {
+		b.end()
+		b.phase, b.started = phase, now
+	}
+
+	var line strings.Builder
+	line.WriteString(phase)
+	if percent >= 0 {
+		percent = min(percent, 100)
+		filled := int(percent / 100 * barWidth)
+		fmt.Fprintf(&line, " [%s%s] %3.0f%%", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), percent)
+		if eta, ok := remaining(now.Sub(b.started), percent); ok {
+			fmt.Fprintf(&line, " ETA %s", eta)
+		}
+	}
+	if message != "" {
+		line.WriteString("  " + message)
+	}
+	b.line = line.String()
+	// Progress is best-effort and must never fail the operation being reported on.
+	_, _ = io.WriteString(b.w, clearLine+b.line)
+
+	if percent >= 100 {
+		b.end()
+		b.phase = ""
+	}
+}
+
+// end ends the line drawn, if any, so that it's kept.
+func (b *Bar) end() {
+	if b.line != "" {
+		_, _ = io.WriteString(b.w, "\n")
+		b.line = ""
+	}
+}
+
+// Writer returns a writer to w, e.g. for logs, that clears the bar before each write and draws it again after, so
+// that what's written isn't mixed with the bar when w is the terminal the bar is drawn on.
+func (b *Bar) Writer(w io.Writer) io.Writer {
+	return barWriter{bar: b, w: w}
+}
+
+type barWriter struct {
+	bar *Bar
+	w   io.Writer
+}
+
+func (w barWriter) Write(p []byte) (int, error) {
+	w.bar.mu.Lock()
+	defer w.bar.mu.Unlock()
+
+	if w.bar.line == "" {
+		return w.w.Write(p)
+	}
+	_, _ = io.WriteString(w.bar.w, clearLine)
+	n, err := w.w.Write(p)
+	_, _ = io.WriteString(w.bar.w, w.bar.line)
+
+	return n, err
+}
+
+// logReporter logs updates, at most one per interval for each phase besides their start and completion.
+type logReporter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	now      func() time.Time
+
+	started map[string]time.Time
+	logged  map[string]time.Time
+}
+
+// NewLog creates a Reporter that logs the progress of each phase every interval, for when there's no terminal to draw
+// a Bar on, e.g. when the output is redirected to a file.
+func NewLog(interval time.Duration) Reporter {
+	return &logReporter{
+		interval: interval,
+		now:      time.Now,
+		started:  map[string]time.Time{},
+		logged:   map[string]time.Time{},
+	}
+}
+
+func (r *logReporter) Report(phase string, percent float64, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	started, ok := r.started[phase]
+	if !ok {
+		started = now
+		r.started[phase] = now
+	}
+	done := percent >= 100
+	if ok && !done && now.Sub(r.logged[phase]) < r.interval {
+		return
+	}
+	r.logged[phase] = now
+	if done {
+		delete(r.started, phase)
+		delete(r.logged, phase)
+	}
+
+	entry := logrus.WithField("phase", phase)
+	if percent >= 0 {
+		percent = min(percent, 100)
+		entry = entry.WithField("percent", fmt.Sprintf("%.0f", percent))
+		if eta, ok := remaining(now.Sub(started), percent); ok {
+			entry = entry.WithField("eta", eta.String())
+		}
+	}
+	if message == "" {
+		message = "In progress"
+	}
+	entry.Info(message)
+}
+
+// remaining estimates the time remaining from the time elapsed and the percent completed, assuming a steady rate. It
+// reports false when there's no estimate, at the start and end of a phase.
+func remaining(elapsed time.Duration, percent float64) (time.Duration, bool) {
+	if percent <= 0 || percent >= 100 || elapsed <= 0 {
+		return 0, false
+	}
+	eta := time.Duration(float64(elapsed) * (100 - percent) / percent)
+
+	return eta.Round(time.Second), true
+}
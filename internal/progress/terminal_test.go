@@ -0,0 +1,87 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBar(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := NewBar(&buf)
+	b.now = func() time.Time { return now }
+
+	b.Report("write", 0, "")
+	now = now.Add(time.Minute)
+	b.Report("write", 25, "25MB of 100MB")
+	assert.True(t, strings.HasSuffix(buf.String(), clearLine+"write [=======                       ]  25% ETA 3m0s  25MB of 100MB"), buf.String())
+
+	buf.Reset()
+	b.Report("write", 100, "done")
+	assert.True(t, strings.HasSuffix(buf.String(), "100%  done\n"), "the bar should be ended when the phase completes")
+
+	buf.Reset()
+	b.Report("collect", Unknown, "running for 10s")
+	assert.Equal(t, clearLine+"collect  running for 10s", buf.String())
+}
+
+func TestBar_Writer(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBar(&buf)
+	w := b.Writer(&buf)
+
+	_, _ = w.Write([]byte("before\n"))
+	assert.Equal(t, "before\n", buf.String(), "nothing should be cleared without a bar")
+
+	b.Report("collect", Unknown, "running")
+	buf.Reset()
+	_, _ = w.Write([]byte("log\n"))
+	assert.Equal(t, clearLine+"log\ncollect  running", buf.String(), "the bar should be drawn again after the write")
+}
+
+func TestLogReporter(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	previous := logrus.StandardLogger().Out
+	logrus.SetOutput(logger.Out)
+	logrus.AddHook(hook)
+	t.Cleanup(func() {
+		logrus.SetOutput(previous)
+		logrus.StandardLogger().ReplaceHooks(logrus.LevelHooks{})
+	})
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := NewLog(30 * time.Second).(*logReporter)
+	r.now = func() time.Time { return now }
+
+	r.Report("upload", 0, "")
+	now = now.Add(10 * time.Second)
+	r.Report("upload", 10, "")
+	now = now.Add(30 * time.Second)
+	r.Report("upload", 50, "50MB of 100MB")
+	r.Report("upload", 100, "")
+
+	entries := hook.AllEntries()
+	if assert.Len(t, entries, 3, "updates within the interval should be skipped") {
+		assert.Equal(t, "50MB of 100MB", entries[1].Message)
+		assert.Equal(t, "50", entries[1].Data["percent"])
+		assert.Equal(t, "40s", entries[1].Data["eta"])
+		assert.Equal(t, "100", entries[2].Data["percent"], "completion should always be logged")
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	eta, ok := remaining(time.Minute, 25)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Minute, eta)
+
+	for _, percent := range []float64{0, 100, Unknown} {
+		_, ok := remaining(time.Minute, percent)
+		assert.False(t, ok, "percent %v", percent)
+	}
+}
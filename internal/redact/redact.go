@@ -0,0 +1,126 @@
+// Package redact provides the functionality necessary for keeping secrets, such as passwords, tokens, and presigned
+// URLs, out of logs at every level.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Placeholder replaces redacted values.
+const Placeholder = "REDACTED"
+
+// minSecretLength is the length of the shortest value that Secret marks. Redacting shorter values would garble
+// unrelated text wherever they appear.
+const minSecretLength = 4
+
+// secrets are the values marked sensitive with Secret.
+var secrets struct {
+	sync.RWMutex
+	values map[string]struct{}
+}
+
+// Secret marks the value, e.g. a password read from stdin, as sensitive so that it's redacted wherever it appears in
+// log entries from then on.
+func Secret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	if secrets.values == nil {
+		secrets.values = map[string]struct{}{}
+	}
+	secrets.values[value] = struct{}{}
+}
+
+// patterns match sensitive values that appear in text without having been marked, keeping what identifies them in
+// the first group.
+var patterns = []*regexp.Regexp{
+	// Signatures, credentials, and session tokens of presigned URLs, e.g. ?X-Amz-Signature=...
+	regexp.MustCompile(`(?i)([?&](?:X-Amz-Signature|X-Amz-Credential|X-Amz-Security-Token|Signature|AWSAccessKeyId|sig)=)[^&\s"']+`),
+	// Authorization headers.
+	regexp.MustCompile(`(?i)(Authorization:\s*(?:Bearer\s+|Basic\s+)?)[^\s"']+`),
+	// Assignments of passwords, secrets, and tokens, e.g. password=... or "token": "...".
+	regexp.MustCompile(`(?i)((?:password|passwd|secret|token|secret_access_key)["']?\s*[:=]\s*["']?)[^\s"'&,}]+`),
+}
+
+// sensitiveKeys are substrings of the names of log fields whose values are redacted entirely.
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "authorization", "credential"}
+
+// String returns s with marked secrets and values matching known sensitive patterns replaced by Placeholder.
+func String(s string) string {
+	secrets.RLock()
+	for secret := range secrets.values {
+		s = strings.ReplaceAll(s, secret, Placeholder)
+	}
+	secrets.RUnlock()
+	for _, p := range patterns {
+		s = p.ReplaceAllString(s, "${1}"+Placeholder)
+	}
+
+	return s
+}
+
+// Strings returns a copy of values with each redacted, see String.
+func Strings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	redacted := make([]string, len(values))
+	for i, v := range values {
+		redacted[i] = String(v)
+	}
+
+	return redacted
+}
+
+// SensitiveKey reports whether a field with the name holds a sensitive value, e.g. vnc_password.
+func SensitiveKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, key := range sensitiveKeys {
+		if strings.Contains(name, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Hook is a logrus.Hook that redacts sensitive values from the message and fields of log entries. It must be added
+// before other hooks so that they only see redacted entries.
+type Hook struct{}
+
+// Levels returns all log levels so that debug entries are redacted too.
+func (Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts the entry's message and fields. Fields that aren't strings are redacted in their formatted form, and
+// only replaced by it when something was redacted.
+func (Hook) Fire(entry *logrus.Entry) error {
+	entry.Message = String(entry.Message)
+	for key, value := range entry.Data {
+		if SensitiveKey(key) {
+			entry.Data[key] = Placeholder
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = String(v)
+		case []string:
+			entry.Data[key] = Strings(v)
+		default:
+			formatted := fmt.Sprint(v)
+			if redacted := String(formatted); redacted != formatted {
+				entry.Data[key] = redacted
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package redact
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString(t *testing.T) {
+	Secret("hunter2-stdin")
+	Secret("abc")
+
+	cases := map[string]struct {
+		in, want string
+	}{
+		"secret": {
+			in:   "dscl: passwd /Users/ec2-user hunter2-stdin",
+			want: "dscl: passwd /Users/ec2-user REDACTED",
+		},
+		"short values aren't marked": {
+			in:   "abc def",
+			want: "abc def",
+		},
+		"presigned URL": {
+			in:   "GET https://bucket.s3.amazonaws.com/key?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIA%2F20240101&X-Amz-Signature=abcdef0123 failed",
+			want: "GET https://bucket.s3.amazonaws.com/key?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=REDACTED&X-Amz-Signature=REDACTED failed",
+		},
+		"authorization header": {
+			in:   "Authorization: Bearer eyJhbGciOi.payload",
+			want: "Authorization: Bearer REDACTED",
+		},
+		"assignments": {
+			in:   `token=s3cr3t {"password": "pa55", "user": "ec2-user"}`,
+			want: `token=REDACTED {"password": "REDACTED", "user": "ec2-user"}`,
+		},
+		"prose": {
+			in:   "password cannot be empty",
+			want: "password cannot be empty",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, String(tc.in))
+		})
+	}
+}
+
+func TestSensitiveKey(t *testing.T) {
+	assert.True(t, SensitiveKey("vnc_password"))
+	assert.True(t, SensitiveKey("SessionToken"))
+	assert.False(t, SensitiveKey("user"))
+}
+
+func TestHook(t *testing.T) {
+	Secret("correct-horse")
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "Setting password correct-horse"
+	entry.Data = logrus.Fields{
+		"argv":             []string{"kickstart", "-vncpw", "correct-horse"},
+		"session_token":    "anything",
+		logrus.ErrorKey:    errors.New("failed with correct-horse"),
+		"bytes":            42,
+		"manifest_url":     "https://example.com/m.json?Signature=xyz",
+		"unrelated_string": "ok",
+	}
+	assert.NoError(t, Hook{}.Fire(entry))
+
+	assert.Equal(t, "Setting password REDACTED", entry.Message)
+	assert.Equal(t, []string{"kickstart", "-vncpw", "REDACTED"}, entry.Data["argv"])
+	assert.Equal(t, Placeholder, entry.Data["session_token"])
+	assert.Equal(t, "failed with REDACTED", entry.Data[logrus.ErrorKey])
+	assert.Equal(t, 42, entry.Data["bytes"])
+	assert.Equal(t, "https://example.com/m.json?Signature=REDACTED", entry.Data["manifest_url"])
+	assert.Equal(t, "ok", entry.Data["unrelated_string"])
+}
@@ -0,0 +1,137 @@
+// Package remotedesktop provides the functionality necessary for enabling and disabling macOS Screen Sharing and
+// Apple Remote Desktop (ARD) access.
+package remotedesktop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+const (
+	// kickstartPath is the ARD configuration tool.
+	kickstartPath = "/System/Library/CoreServices/RemoteManagement/ARDAgent.app/Contents/Resources/kickstart"
+
+	// screenSharingService and screenSharingPlist identify the Screen Sharing launch daemon.
+	screenSharingService = "system/com.apple.screensharing"
+	screenSharingPlist   = "/System/Library/LaunchDaemons/com.apple.screensharing.plist"
+
+	// vncAddress is where Screen Sharing accepts connections.
+	vncAddress = "127.0.0.1:5900"
+
+	// MaxVNCPasswordLength is the longest password VNC clients use; longer ones are silently truncated by them.
+	MaxVNCPasswordLength = 8
+)
+
+// Options configures the access that's enabled.
+type Options struct {
+	// Users are the local users allowed to connect, with every privilege.
+	Users []string
+	// VNCPassword enables legacy VNC clients, which don't authenticate as a user, with this password. Empty leaves
+	// VNC clients disabled.
+	VNCPassword string
+}
+
+// Status is the state of remote desktop access.
+type Status struct {
+	// ScreenSharing reports whether the Screen Sharing launch daemon is loaded.
+	ScreenSharing bool `json:"screen_sharing"`
+	// Agent reports whether the ARD agent is running.
+	Agent bool `json:"ard_agent"`
+	// Listening reports whether connections are accepted on the VNC port.
+	Listening bool `json:"listening"`
+}
+
+// Enable enables Screen Sharing and ARD access for the users.
+func Enable(ctx context.Context, opts Options) error {
+	if len(opts.Users) == 0 {
+		return errors.New("at least one user is required")
+	}
+	if len(opts.VNCPassword) > MaxVNCPasswordLength {
+		return fmt.Errorf("VNC passwords can't be longer than %d characters", MaxVNCPasswordLength)
+	}
+
+	return run(ctx, enableCommands(opts))
+}
+
+// enableCommands returns the commands that enable access.
+func enableCommands(opts Options) [][]string {
+	cmds := [][]string{
+		{kickstartPath, "-configure", "-allowAccessFor", "-specifiedUsers"},
+		{kickstartPath, "-configure", "-access", "-on", "-users", strings.Join(opts.Users, ","), "-privs", "-all"},
+	}
+	if opts.VNCPassword != "" {
+		cmds = append(cmds, []string{kickstartPath, "-configure", "-clientopts", "-setvnclegacy", "-vnclegacy", "yes", "-setvncpw", "-vncpw", opts.VNCPassword})
+	}
+
+	return append(cmds,
+		[]string{kickstartPath, "-activate", "-restart", "-agent", "-menu"},
+		[]string{"launchctl", "enable", screenSharingService},
+		[]string{"launchctl", "load", "-w", screenSharingPlist},
+	)
+}
+
+// Disable disables Screen Sharing and ARD access.
+func Disable(ctx context.Context) error {
+	return run(ctx, disableCommands())
+}
+
+// disableCommands returns the commands that disable access.
+func disableCommands() [][]string {
+	return [][]string{
+		{kickstartPath, "-deactivate", "-configure", "-access", "-off", "-clientopts", "-setvnclegacy", "-vnclegacy", "no"},
+		{"launchctl", "unload", "-w", screenSharingPlist},
+		{"launchctl", "disable", screenSharingService},
+	}
+}
+
+// GetStatus returns the state of remote desktop access.
+func GetStatus(ctx context.Context) Status {
+	var s Status
+	_, err := util.ExecuteCommand(ctx, []string{"launchctl", "print", screenSharingService}, "", nil, nil)
+	s.ScreenSharing = err == nil
+	_, err = util.ExecuteCommand(ctx, []string{"pgrep", "-x", "ARDAgent"}, "", nil, nil)
+	s.Agent = err == nil
+
+	conn, err := (&net.Dialer{Timeout: time.Second}).DialContext(ctx, "tcp", vncAddress)
+	if err == nil {
+		_ = conn.Close()
+		s.Listening = true
+	}
+
+	return s
+}
+
+// run runs the commands in order, stopping at the first failure. Arguments following -vncpw are redacted from logs
+// and errors.
+func run(ctx context.Context, cmds [][]string) error {
+	for _, c := range cmds {
+		logrus.WithField("command", redact(c)).Debug("Running remote desktop command")
+		out, err := util.ExecuteCommand(ctx, c, "", nil, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", strings.Join(redact(c), " "), strings.TrimSpace(out.Stderr), err)
+		}
+	}
+
+	return nil
+}
+
+// redact returns the command with the VNC password replaced.
+func redact(c []string) []string {
+	redacted := make([]string, len(c))
+	copy(redacted, c)
+	for i := 1; i < len(redacted); i++ {
+		if redacted[i-1] == "-vncpw" {
+			redacted[i] = "REDACTED"
+		}
+	}
+
+	return redacted
+}
@@ -0,0 +1,31 @@
+package remotedesktop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnableCommands(t *testing.T) {
+	cmds := enableCommands(Options{Users: []string{"ec2-user", "ci"}})
+	assert.Equal(t, []string{kickstartPath, "-configure", "-access", "-on", "-users", "ec2-user,ci", "-privs", "-all"}, cmds[1])
+	for _, c := range cmds {
+		assert.NotContains(t, c, "-setvncpw", "VNC clients should stay disabled without a password")
+	}
+
+	cmds = enableCommands(Options{Users: []string{"ec2-user"}, VNCPassword: "s3cret"})
+	assert.Contains(t, cmds, []string{kickstartPath, "-configure", "-clientopts", "-setvnclegacy", "-vnclegacy", "yes", "-setvncpw", "-vncpw", "s3cret"})
+	assert.Equal(t, []string{"launchctl", "load", "-w", screenSharingPlist}, cmds[len(cmds)-1])
+}
+
+func TestEnable_Invalid(t *testing.T) {
+	assert.Error(t, Enable(context.Background(), Options{}))
+	assert.Error(t, Enable(context.Background(), Options{Users: []string{"ec2-user"}, VNCPassword: "too-long-password"}))
+}
+
+func TestRedact(t *testing.T) {
+	c := []string{kickstartPath, "-setvncpw", "-vncpw", "s3cret"}
+	assert.Equal(t, []string{kickstartPath, "-setvncpw", "-vncpw", "REDACTED"}, redact(c))
+	assert.Equal(t, "s3cret", c[3], "the command itself shouldn't change")
+}
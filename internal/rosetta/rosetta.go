@@ -0,0 +1,56 @@
+// Package rosetta provides the functionality necessary for installing Rosetta 2, which runs x86_64 code on Apple
+// silicon.
+package rosetta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// ErrLicenseNotAccepted is returned when installing without accepting the Rosetta license.
+var ErrLicenseNotAccepted = errors.New("the Rosetta license must be accepted to install it")
+
+// AppleSilicon returns whether the host has an Apple silicon processor. It's also true when this program runs
+// translated by Rosetta.
+func AppleSilicon(_ context.Context) (bool, error) {
+	arm64, err := system.SysctlUint32("hw.optional.arm64")
+	if err != nil {
+		// Intel processors don't have the variable at all.
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("read hw.optional.arm64: %w", err)
+	}
+
+	return arm64 == 1, nil
+}
+
+// Installed returns whether x86_64 code can run, i.e. Rosetta is installed.
+func Installed(ctx context.Context) bool {
+	_, err := util.ExecuteCommand(ctx, []string{"arch", "-x86_64", "/usr/bin/true"}, "", nil, nil)
+	return err == nil
+}
+
+// Install installs Rosetta non-interactively, which requires accepting its license, and verifies that x86_64 code
+// can run afterwards.
+func Install(ctx context.Context, agreeToLicense bool) error {
+	if !agreeToLicense {
+		return ErrLicenseNotAccepted
+	}
+
+	out, err := util.ExecuteCommand(ctx, []string{"softwareupdate", "--install-rosetta", "--agree-to-license"}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("install Rosetta: %s: %w", strings.TrimSpace(out.Stderr+out.Stdout), err)
+	}
+	if !Installed(ctx) {
+		return errors.New("Rosetta was installed but x86_64 code still can't run")
+	}
+
+	return nil
+}
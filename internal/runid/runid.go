@@ -0,0 +1,58 @@
+// Package runid provides the functionality necessary for correlating a single invocation's logs and artifacts.
+package runid
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// EnvVar is the environment variable that may provide the run ID, allowing a caller (e.g. an orchestrator or
+	// a parent invocation) to correlate its own records with this invocation.
+	EnvVar = "EC2_MACOS_UTILS_RUN_ID"
+
+	// LogField is the name of the log field that carries the run ID.
+	LogField = "run_id"
+)
+
+// validID restricts externally provided run IDs to values that are safe in file names, object metadata, and logs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// New generates a random run ID formatted as a version 4 UUID.
+func New() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Errorf("generate run id: %w", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// FromEnvOrNew returns the run ID provided by EnvVar when it is valid, otherwise a new run ID is generated.
+func FromEnvOrNew() string {
+	if id := os.Getenv(EnvVar); validID.MatchString(id) {
+		return id
+	}
+
+	return New()
+}
+
+// Hook is a logrus.Hook that adds the run ID to every log entry.
+type Hook string
+
+// Levels returns all log levels so that every entry is annotated.
+func (h Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the run ID field to the entry.
+func (h Hook) Fire(entry *logrus.Entry) error {
+	entry.Data[LogField] = string(h)
+	return nil
+}
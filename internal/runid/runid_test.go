@@ -0,0 +1,31 @@
+package runid
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	a, b := New(), New()
+	assert.Regexp(t, uuidV4, a)
+	assert.NotEqual(t, a, b, "should generate unique IDs")
+}
+
+func TestFromEnvOrNew(t *testing.T) {
+	t.Setenv(EnvVar, "orchestrator-1234")
+	assert.Equal(t, "orchestrator-1234", FromEnvOrNew(), "should use valid ID from environment")
+
+	t.Setenv(EnvVar, "bad id/../x")
+	assert.NotEqual(t, "bad id/../x", FromEnvOrNew(), "should ignore unsafe ID from environment")
+}
+
+func TestHook(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New())
+	assert.NoError(t, Hook("abc").Fire(entry))
+	assert.Equal(t, "abc", entry.Data[LogField])
+}
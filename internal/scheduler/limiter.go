@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of an action shared by tasks, e.g. collecting a sysdiagnose, so that together they don't
+// take it more often than the host can afford. It allows a burst of actions, and one more every interval. A nil
+// Limiter doesn't limit.
+type Limiter struct {
+	every time.Duration
+	burst int
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a limiter of one action every interval after a burst, or nil if every isn't positive.
+func NewLimiter(every time.Duration, burst int) *Limiter {
+	if every <= 0 {
+		return nil
+	}
+	burst = max(burst, 1)
+
+	return &Limiter{every: every, burst: burst, now: time.Now, tokens: float64(burst)}
+}
+
+// Allow reports whether the action may be taken now, and counts it if so.
+func (l *Limiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = min(float64(l.burst), l.tokens+float64(now.Sub(l.last))/float64(l.every))
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+
+	return true
+}
@@ -0,0 +1,120 @@
+// Package scheduler provides the functionality necessary for running many periodic tasks, such as watchdog checks,
+// metrics, and log shipping, in one long-running process.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/selfmetrics"
+)
+
+// ErrStop is returned by a task's run to stop the task, e.g. a watchdog once it captured diagnostic data.
+var ErrStop = errors.New("task stopped")
+
+// Task is a periodic task.
+type Task struct {
+	// Name identifies the task in logs and metrics.
+	Name string
+	// Delay is the time before the first run.
+	Delay time.Duration
+	// Interval is the time between the starts of runs. A run that takes longer delays the next one rather than
+	// overlapping it, and zero runs it back to back. For continuous tasks, it's the time between a run returning and
+	// the next starting.
+	Interval time.Duration
+	// Continuous tasks run until ctx is done, e.g. log shipping, and are restarted when they return.
+	Continuous bool
+	// Run runs the task once. Returning ErrStop stops the task, and other errors are logged and retried at the next
+	// run.
+	Run func(ctx context.Context) error
+}
+
+// Run runs each task in its own goroutine at its interval until ctx is done or every task has stopped. Tasks are
+// isolated from each other: a run that fails or panics is logged and counted without affecting the other tasks, and
+// the task runs again at its next interval. It returns ctx.Err() when ctx is done.
+func Run(ctx context.Context, tasks ...Task) error {
+	names := map[string]bool{}
+	for _, t := range tasks {
+		switch {
+		case t.Name == "" || t.Run == nil:
+			return errors.New("tasks must have a name and a run function")
+		case names[t.Name]:
+			return fmt.Errorf("duplicate task %q", t.Name)
+		case t.Interval < 0 || t.Delay < 0:
+			return fmt.Errorf("task %s: interval and delay cannot be negative", t.Name)
+		}
+		names[t.Name] = true
+	}
+
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runTask(ctx, t)
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// runTask runs the task at its interval until ctx is done or the task stops.
+func runTask(ctx context.Context, t Task) {
+	log := logrus.WithField("task", t.Name)
+	log.WithFields(logrus.Fields{
+		"delay":    t.Delay,
+		"interval": t.Interval,
+	}).Debug("Scheduled task")
+
+	timer := time.NewTimer(t.Delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		start := time.Now()
+		err := runOnce(ctx, t)
+		switch {
+		case errors.Is(err, ErrStop):
+			log.Info("Task stopped")
+			return
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			selfmetrics.TaskFailures.Inc(t.Name)
+			log.WithError(err).Warn("Task failed, will retry")
+		}
+
+		next := t.Interval
+		if !t.Continuous {
+			next -= time.Since(start)
+		}
+		timer.Reset(max(next, 0))
+	}
+}
+
+// runOnce runs the task once, recovering from a panic as an error so that it doesn't take down the other tasks.
+func runOnce(ctx context.Context, t Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithFields(logrus.Fields{
+				"task":  t.Name,
+				"stack": string(debug.Stack()),
+			}).Error("Task panicked")
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	selfmetrics.TaskRuns.Inc(t.Name)
+
+	return t.Run(ctx)
+}
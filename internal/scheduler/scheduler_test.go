@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var fast, panicking, stopping atomic.Int32
+	err := Run(ctx,
+		Task{Name: "fast", Interval: 10 * time.Millisecond, Run: func(context.Context) error {
+			fast.Add(1)
+			return errors.New("failed")
+		}},
+		Task{Name: "panicking", Interval: 10 * time.Millisecond, Run: func(context.Context) error {
+			panicking.Add(1)
+			panic("boom")
+		}},
+		Task{Name: "stopping", Interval: time.Millisecond, Run: func(context.Context) error {
+			if stopping.Add(1) == 3 {
+				return ErrStop
+			}
+			return nil
+		}},
+		Task{Name: "delayed", Delay: time.Hour, Interval: time.Millisecond, Run: func(context.Context) error {
+			t.Error("the task should not run before its delay")
+			return nil
+		}},
+	)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Greater(t, fast.Load(), int32(2), "failed runs should be retried")
+	assert.Greater(t, panicking.Load(), int32(2), "a panic should neither stop the task nor the others")
+	assert.Equal(t, int32(3), stopping.Load(), "the task should stop when it returns ErrStop")
+}
+
+func TestRun_AllStopped(t *testing.T) {
+	err := Run(context.Background(),
+		Task{Name: "a", Interval: time.Millisecond, Run: func(context.Context) error { return ErrStop }},
+		Task{Name: "b", Interval: time.Millisecond, Run: func(context.Context) error { return ErrStop }},
+	)
+
+	assert.NoError(t, err, "Run should return once every task stopped")
+}
+
+func TestRun_Continuous(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var starts atomic.Int32
+	err := Run(ctx, Task{Name: "ship", Continuous: true, Interval: time.Millisecond, Run: func(ctx context.Context) error {
+		if starts.Add(1) == 2 {
+			cancel()
+			<-ctx.Done()
+			return nil
+		}
+		return errors.New("stream closed")
+	}})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(2), starts.Load(), "the task should be restarted after it returned")
+}
+
+func TestRun_Invalid(t *testing.T) {
+	run := func(context.Context) error { return nil }
+	for name, tasks := range map[string][]Task{
+		"no name":   {{Interval: time.Second, Run: run}},
+		"no run":    {{Name: "a", Interval: time.Second}},
+		"negative":  {{Name: "a", Interval: -time.Second, Run: run}},
+		"duplicate": {{Name: "a", Interval: time.Second, Run: run}, {Name: "a", Interval: time.Second, Run: run}},
+	} {
+		assert.Error(t, Run(context.Background(), tasks...), name)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := NewLimiter(time.Hour, 2)
+	require.NotNil(t, l)
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Allow())
+	assert.True(t, l.Allow(), "the burst should be allowed")
+	assert.False(t, l.Allow())
+
+	now = now.Add(30 * time.Minute)
+	assert.False(t, l.Allow())
+	now = now.Add(30 * time.Minute)
+	assert.True(t, l.Allow(), "an action should be allowed again after the interval")
+	assert.False(t, l.Allow())
+
+	var unlimited *Limiter
+	assert.Nil(t, NewLimiter(0, 1))
+	assert.True(t, unlimited.Allow())
+}
@@ -0,0 +1,209 @@
+// Package selfmetrics provides the functionality necessary for counting what the utility itself does, such as the
+// checks it runs and the external commands it executes, and exposing the counts in the Prometheus text format.
+package selfmetrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// namespace prefixes the name of every metric.
+const namespace = "ec2_macos_utils_"
+
+var (
+	// ChecksRun counts the system checks run, by check.
+	ChecksRun = newCounter("checks_total", "System checks run.", "check")
+	// CheckFailures counts the system checks that failed, by check.
+	CheckFailures = newCounter("check_failures_total", "System checks that failed.", "check")
+	// SysdiagnosesCollected counts the sysdiagnose archives collected.
+	SysdiagnosesCollected = newCounter("sysdiagnoses_collected_total", "Sysdiagnose archives collected.", "")
+	// UploadBytes counts the bytes of the objects and parts uploaded to S3.
+	UploadBytes = newCounter("upload_bytes_total", "Bytes uploaded to S3.", "")
+	// CommandDuration sums the duration of the external commands executed, by command.
+	CommandDuration = newSummary("external_command_duration_seconds", "Duration of the external commands executed.", "command")
+	// CommandWait sums the time external commands waited for the concurrency and rate limits, by command.
+	CommandWait = newSummary("external_command_wait_seconds", "Time external commands waited for the limits before starting.", "command")
+	// CommandFailures counts the external commands that failed to start or exited with an error, by command.
+	CommandFailures = newCounter("external_command_failures_total", "External commands that failed.", "command")
+	// TaskRuns counts the runs of scheduled tasks, by task.
+	TaskRuns = newCounter("task_runs_total", "Runs of scheduled tasks.", "task")
+	// TaskFailures counts the runs of scheduled tasks that failed or panicked, by task.
+	TaskFailures = newCounter("task_failures_total", "Runs of scheduled tasks that failed.", "task")
+)
+
+// labelEscaper escapes label values as the Prometheus text format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metrics are every metric, in the order they're written.
+var metrics = []metric{ChecksRun, CheckFailures, SysdiagnosesCollected, UploadBytes, CommandDuration, CommandWait, CommandFailures, TaskRuns, TaskFailures}
+
+// metric is a family of series, one for each value of its label.
+type metric interface {
+	// write writes the metric in the Prometheus text format.
+	write(w io.Writer) error
+	// snapshot adds the metric's series and their values to values.
+	snapshot(values map[string]float64)
+}
+
+// Counter is a monotonically increasing count, partitioned by the value of its label if it has one.
+type Counter struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounter(name, help, label string) *Counter {
+	return &Counter{name: namespace + name, help: help, label: label, values: map[string]float64{}}
+}
+
+// Inc increments the count of the label value, which is ignored if the counter has no label.
+func (c *Counter) Inc(value string) {
+	c.Add(value, 1)
+}
+
+// Add adds n to the count of the label value, which is ignored if the counter has no label.
+func (c *Counter) Add(value string, n float64) {
+	if c.label == "" {
+		value = ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[value] += n
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+		return err
+	}
+	if c.label == "" {
+		// Unlabelled counters are exposed before they're incremented so that they can be told from missing ones.
+		_, err := fmt.Fprintf(w, "%s %s\n", c.name, formatValue(c.values[""]))
+		return err
+	}
+	for _, value := range sortedKeys(c.values) {
+		if _, err := fmt.Fprintf(w, "%s %s\n", series(c.name, c.label, value), formatValue(c.values[value])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Counter) snapshot(values map[string]float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for value, n := range c.values {
+		values[series(c.name, c.label, value)] = n
+	}
+}
+
+// Summary sums and counts observed durations, partitioned by the value of its label, without quantiles.
+type Summary struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	sums   map[string]float64
+	counts map[string]float64
+}
+
+func newSummary(name, help, label string) *Summary {
+	return &Summary{name: namespace + name, help: help, label: label, sums: map[string]float64{}, counts: map[string]float64{}}
+}
+
+// Observe records a duration for the label value.
+func (s *Summary) Observe(value string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sums[value] += d.Seconds()
+	s.counts[value]++
+}
+
+func (s *Summary) write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n", s.name, s.help, s.name); err != nil {
+		return err
+	}
+	for _, value := range sortedKeys(s.counts) {
+		if _, err := fmt.Fprintf(w, "%s %s\n%s %s\n",
+			series(s.name+"_sum", s.label, value), formatValue(s.sums[value]),
+			series(s.name+"_count", s.label, value), formatValue(s.counts[value])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Summary) snapshot(values map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for value := range s.counts {
+		values[series(s.name+"_sum", s.label, value)] = s.sums[value]
+		values[series(s.name+"_count", s.label, value)] = s.counts[value]
+	}
+}
+
+// WriteText writes every metric in the Prometheus text exposition format.
+func WriteText(w io.Writer) error {
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Snapshot returns the current value of every series, keyed by the series as it's written in the Prometheus text
+// format, e.g. ec2_macos_utils_checks_total{check="imds"}.
+func Snapshot() map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range metrics {
+		m.snapshot(values)
+	}
+
+	return values
+}
+
+// series returns the name of the series of the metric with the label value.
+func series(name, label, value string) string {
+	if label == "" {
+		return name
+	}
+
+	return name + "{" + label + "=\"" + labelEscaper.Replace(value) + "\"}"
+}
+
+// formatValue formats a sample value as the Prometheus text format expects.
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of m in order, so that series are written in a stable order.
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Handler serves every metric in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteText(w)
+	})
+}
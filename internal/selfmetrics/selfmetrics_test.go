@@ -0,0 +1,66 @@
+package selfmetrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	c := newCounter("checks_total", "System checks run.", "check")
+	c.Inc("imds")
+	c.Inc("time")
+	c.Add("imds", 2)
+
+	var b strings.Builder
+	assert.NoError(t, c.write(&b))
+	assert.Equal(t, `# HELP ec2_macos_utils_checks_total System checks run.
+# TYPE ec2_macos_utils_checks_total counter
+ec2_macos_utils_checks_total{check="imds"} 3
+ec2_macos_utils_checks_total{check="time"} 1
+`, b.String())
+
+	values := map[string]float64{}
+	c.snapshot(values)
+	assert.Equal(t, map[string]float64{
+		`ec2_macos_utils_checks_total{check="imds"}`: 3,
+		`ec2_macos_utils_checks_total{check="time"}`: 1,
+	}, values)
+}
+
+func TestCounter_Unlabelled(t *testing.T) {
+	c := newCounter("upload_bytes_total", "Bytes uploaded.", "")
+
+	var b strings.Builder
+	assert.NoError(t, c.write(&b))
+	assert.Contains(t, b.String(), "\nec2_macos_utils_upload_bytes_total 0\n")
+
+	c.Add("ignored", 1024)
+	b.Reset()
+	assert.NoError(t, c.write(&b))
+	assert.Contains(t, b.String(), "\nec2_macos_utils_upload_bytes_total 1024\n")
+}
+
+func TestSummary(t *testing.T) {
+	s := newSummary("external_command_duration_seconds", "Duration.", "command")
+	s.Observe(`we"ird`, 1500*time.Millisecond)
+	s.Observe(`we"ird`, 500*time.Millisecond)
+
+	var b strings.Builder
+	assert.NoError(t, s.write(&b))
+	assert.Contains(t, b.String(), `ec2_macos_utils_external_command_duration_seconds_sum{command="we\"ird"} 2`+"\n")
+	assert.Contains(t, b.String(), `ec2_macos_utils_external_command_duration_seconds_count{command="we\"ird"} 2`+"\n")
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Equal(t, len(metrics), strings.Count(rec.Body.String(), "# TYPE "))
+	assert.Contains(t, rec.Body.String(), "ec2_macos_utils_sysdiagnoses_collected_total ")
+}
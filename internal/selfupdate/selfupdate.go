@@ -0,0 +1,245 @@
+// Package selfupdate provides the functionality necessary for checking, verifying, and installing new releases of
+// EC2 macOS Utils.
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// signatureSuffix is appended to the manifest URL to locate its detached signature.
+	signatureSuffix = ".sig"
+
+	// maxManifestSize limits the amount of data read for a manifest or its signature.
+	maxManifestSize = 1 << 20
+
+	// maxBinarySize limits the amount of data read when downloading a release binary.
+	maxBinarySize = 256 << 20
+)
+
+// ErrVerification identifies errors due to a manifest or binary failing its integrity checks.
+var ErrVerification = errors.New("verification failed")
+
+// Manifest describes a published release and the binaries available for it.
+type Manifest struct {
+	// Version is the semantic version of the release.
+	Version string `json:"version"`
+	// Artifacts maps a platform key (e.g. "darwin_arm64") to its release binary.
+	Artifacts map[string]Artifact `json:"artifacts"`
+}
+
+// Artifact describes a single release binary.
+type Artifact struct {
+	// URL is the HTTPS location of the binary, either an S3 object URL or a GitHub release asset.
+	URL string `json:"url"`
+	// SHA256 is the hex encoded SHA-256 checksum of the binary.
+	SHA256 string `json:"sha256"`
+}
+
+// Platform returns the manifest artifact key for the running platform.
+func Platform() string {
+	return runtime.GOOS + "_" + runtime.GOARCH
+}
+
+// Artifact returns the artifact for the given platform key.
+func (m *Manifest) Artifact(platform string) (Artifact, error) {
+	a, ok := m.Artifacts[platform]
+	if !ok {
+		return Artifact{}, fmt.Errorf("no artifact for platform %q in release %s", platform, m.Version)
+	}
+	if a.URL == "" || a.SHA256 == "" {
+		return Artifact{}, fmt.Errorf("incomplete artifact for platform %q in release %s", platform, m.Version)
+	}
+
+	return a, nil
+}
+
+// NewerThan reports whether the manifest's release is newer than the current version.
+func (m *Manifest) NewerThan(current string) (bool, error) {
+	next, err := semver.NewVersion(m.Version)
+	if err != nil {
+		return false, fmt.Errorf("invalid release version %q: %w", m.Version, err)
+	}
+	cur, err := semver.NewVersion(current)
+	if err != nil {
+		return false, fmt.Errorf("invalid current version %q: %w", current, err)
+	}
+
+	return next.GreaterThan(cur), nil
+}
+
+// Updater fetches and verifies release manifests and installs their binaries.
+type Updater struct {
+	// Client is the HTTP client used for all requests.
+	Client *http.Client
+	// PublicKey is the ed25519 key that release manifests must be signed with.
+	PublicKey ed25519.PublicKey
+}
+
+// ParsePublicKey decodes a base64 encoded ed25519 public key.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	if strings.TrimSpace(encoded) == "" {
+		return nil, errors.New("no release signing key configured")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decode public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+
+	return raw, nil
+}
+
+// FetchManifest downloads the manifest and its detached signature (the manifest URL suffixed with ".sig") and
+// returns the manifest only once the signature has been verified.
+func (u *Updater) FetchManifest(ctx context.Context, manifestURL string) (*Manifest, error) {
+	data, err := u.get(ctx, manifestURL, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest: %w", err)
+	}
+	sig, err := u.get(ctx, manifestURL+signatureSuffix, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest signature: %w", err)
+	}
+
+	return VerifyManifest(u.PublicKey, data, sig)
+}
+
+// VerifyManifest checks the base64 encoded ed25519 signature of the raw manifest data and decodes it.
+func VerifyManifest(key ed25519.PublicKey, data, signature []byte) (*Manifest, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return nil, fmt.Errorf("manifest signature: %w", ErrVerification)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+
+	return &m, nil
+}
+
+// Install downloads the artifact, verifies its checksum, and atomically replaces the executable at target with it.
+// The new binary is staged next to the target so the final rename never crosses filesystems.
+func (u *Updater) Install(ctx context.Context, a Artifact, target string) error {
+	want, err := hex.DecodeString(a.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("invalid artifact checksum %q", a.SHA256)
+	}
+
+	resp, err := u.open(ctx, a.URL)
+	if err != nil {
+		return fmt.Errorf("download artifact: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	staged, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".update*")
+	if err != nil {
+		return fmt.Errorf("stage update: %w", err)
+	}
+	stagedPath := staged.Name()
+	// Remove the staged file on any failure; after a successful rename this is a no-op.
+	defer func() { _ = os.Remove(stagedPath) }()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(staged, hash), io.LimitReader(resp.Body, maxBinarySize+1))
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write staged update: %w", err)
+	}
+	if written > maxBinarySize {
+		return fmt.Errorf("artifact exceeds %d bytes", maxBinarySize)
+	}
+
+	if got := hash.Sum(nil); !strings.EqualFold(hex.EncodeToString(got), hex.EncodeToString(want)) {
+		return fmt.Errorf("artifact checksum %x: %w", got, ErrVerification)
+	}
+
+	if err := os.Chmod(stagedPath, 0755); err != nil {
+		return fmt.Errorf("set staged update permissions: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"target": target,
+		"bytes":  written,
+	}).Debug("Replacing executable with verified update")
+	if err := os.Rename(stagedPath, target); err != nil {
+		return fmt.Errorf("replace executable: %w", err)
+	}
+
+	return nil
+}
+
+// get reads the full body at url, up to limit bytes.
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	resp, err := u.open(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+
+	return data, nil
+}
+
+// open issues a GET request for url and returns the response when successful. Only HTTPS URLs are accepted since
+// the integrity of the update relies on the transport as well as the signature.
+func (u *Updater) open(ctx context.Context, url string) (*http.Response, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("refusing non-HTTPS URL %q", url)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%s returned status code %d", url, resp.StatusCode)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,111 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signed(t *testing.T, data []byte) (ed25519.PublicKey, []byte) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)))
+}
+
+func TestVerifyManifest(t *testing.T) {
+	data := []byte(`{"version":"1.2.0","artifacts":{"darwin_arm64":{"url":"https://example.com/bin","sha256":"00"}}}`)
+	pub, sig := signed(t, data)
+
+	m, err := VerifyManifest(pub, data, sig)
+	assert.NoError(t, err, "should verify correctly signed manifest")
+	assert.Equal(t, "1.2.0", m.Version)
+
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)-3] = '1'
+	_, err = VerifyManifest(pub, tampered, sig)
+	assert.True(t, errors.Is(err, ErrVerification), "should reject tampered manifest")
+
+	otherPub, _ := signed(t, data)
+	_, err = VerifyManifest(otherPub, data, sig)
+	assert.True(t, errors.Is(err, ErrVerification), "should reject manifest signed by another key")
+}
+
+func TestManifest_NewerThan(t *testing.T) {
+	m := Manifest{Version: "1.2.0"}
+
+	newer, err := m.NewerThan("1.1.9")
+	assert.NoError(t, err)
+	assert.True(t, newer)
+
+	newer, err = m.NewerThan("v1.2.0")
+	assert.NoError(t, err)
+	assert.False(t, newer)
+
+	_, err = m.NewerThan("")
+	assert.Error(t, err, "should fail to compare against unset version")
+}
+
+func TestManifest_Artifact(t *testing.T) {
+	m := Manifest{Version: "1.0.0", Artifacts: map[string]Artifact{
+		"darwin_arm64": {URL: "https://example.com/arm64", SHA256: "ab"},
+		"darwin_amd64": {URL: "https://example.com/amd64"},
+	}}
+
+	a, err := m.Artifact("darwin_arm64")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/arm64", a.URL)
+
+	_, err = m.Artifact("darwin_amd64")
+	assert.Error(t, err, "should reject artifact without checksum")
+
+	_, err = m.Artifact("linux_amd64")
+	assert.Error(t, err, "should fail for missing platform")
+}
+
+func TestUpdater_Install(t *testing.T) {
+	payload := []byte("#!/bin/sh\necho updated\n")
+	sum := sha256.Sum256(payload)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer srv.Close()
+
+	target := filepath.Join(t.TempDir(), "ec2-macos-utils")
+	if err := os.WriteFile(target, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	u := &Updater{Client: srv.Client()}
+
+	err := u.Install(context.Background(), Artifact{URL: srv.URL, SHA256: hex.EncodeToString(make([]byte, sha256.Size))}, target)
+	assert.True(t, errors.Is(err, ErrVerification), "should reject checksum mismatch")
+	old, _ := os.ReadFile(target)
+	assert.Equal(t, []byte("old"), old, "should leave target intact on failure")
+
+	err = u.Install(context.Background(), Artifact{URL: srv.URL, SHA256: hex.EncodeToString(sum[:])}, target)
+	assert.NoError(t, err)
+	updated, _ := os.ReadFile(target)
+	assert.Equal(t, payload, updated, "should replace target with verified artifact")
+
+	entries, _ := os.ReadDir(filepath.Dir(target))
+	assert.Len(t, entries, 1, "should not leave staged files behind")
+}
+
+func TestUpdater_RejectsPlainHTTP(t *testing.T) {
+	u := &Updater{}
+	_, err := u.FetchManifest(context.Background(), "http://example.com/manifest.json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+// Package serialconsole provides the functionality necessary for preparing an EC2 Mac instance for access through the
+// EC2 serial console.
+package serialconsole
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// serialBootArg directs console input and output to the serial port.
+const serialBootArg = "serial=3"
+
+// EC2API is the subset of the EC2 client used to read and change the account's serial console access.
+type EC2API interface {
+	GetSerialConsoleAccessStatus(ctx context.Context, params *ec2.GetSerialConsoleAccessStatusInput, optFns ...func(*ec2.Options)) (*ec2.GetSerialConsoleAccessStatusOutput, error)
+	EnableSerialConsoleAccess(ctx context.Context, params *ec2.EnableSerialConsoleAccessInput, optFns ...func(*ec2.Options)) (*ec2.EnableSerialConsoleAccessOutput, error)
+}
+
+// AccountAccessEnabled reports whether serial console access is enabled for the account in the client's region.
+func AccountAccessEnabled(ctx context.Context, client EC2API) (bool, error) {
+	out, err := client.GetSerialConsoleAccessStatus(ctx, &ec2.GetSerialConsoleAccessStatusInput{})
+	if err != nil {
+		return false, fmt.Errorf("get serial console access status: %w", err)
+	}
+
+	return aws.ToBool(out.SerialConsoleAccessEnabled), nil
+}
+
+// EnableAccountAccess enables serial console access for the account in the client's region.
+func EnableAccountAccess(ctx context.Context, client EC2API) error {
+	if _, err := client.EnableSerialConsoleAccess(ctx, &ec2.EnableSerialConsoleAccessInput{}); err != nil {
+		return fmt.Errorf("enable serial console access: %w", err)
+	}
+
+	return nil
+}
+
+// BootArgs reads the boot-args NVRAM variable, which is empty when the variable isn't set.
+func BootArgs(ctx context.Context) (string, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"nvram", "boot-args"}, "", nil, nil)
+	if err != nil {
+		if strings.Contains(out.Stderr, "not found") {
+			return "", nil
+		}
+		return "", fmt.Errorf("read boot-args: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseBootArgs(out.Stdout), nil
+}
+
+// parseBootArgs extracts the value from nvram's "boot-args<tab>value" output.
+func parseBootArgs(output string) string {
+	_, value, _ := strings.Cut(strings.TrimRight(output, "\n"), "\t")
+	return strings.TrimSpace(value)
+}
+
+// SerialEnabled reports whether the boot arguments direct the console to the serial port.
+func SerialEnabled(bootArgs string) bool {
+	for _, arg := range strings.Fields(bootArgs) {
+		if arg == serialBootArg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithSerial returns the boot arguments with the console directed to the serial port, replacing any other serial
+// setting and keeping the remaining arguments.
+func WithSerial(bootArgs string) string {
+	args := []string{}
+	for _, arg := range strings.Fields(bootArgs) {
+		if !strings.HasPrefix(arg, "serial=") {
+			args = append(args, arg)
+		}
+	}
+
+	return strings.Join(append(args, serialBootArg), " ")
+}
+
+// EnableSerial directs the console to the serial port from the next boot.
+func EnableSerial(ctx context.Context) error {
+	current, err := BootArgs(ctx)
+	if err != nil {
+		return err
+	}
+	if SerialEnabled(current) {
+		return nil
+	}
+
+	out, err := util.ExecuteCommand(ctx, []string{"nvram", "boot-args=" + WithSerial(current)}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("set boot-args: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// HasPassword reports whether the local user has a password, which is required to log in on the serial console.
+func HasPassword(ctx context.Context, user string) (bool, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"dscl", ".", "-read", "/Users/" + user, "ShadowHashData"}, "", nil, nil)
+	if err != nil {
+		if strings.Contains(out.Stdout+out.Stderr, "No such key") {
+			return false, nil
+		}
+		return false, fmt.Errorf("read password of %s: %s: %w", user, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return hasShadowHash(out.Stdout), nil
+}
+
+// hasShadowHash reports whether dscl's ShadowHashData output includes a hash.
+func hasShadowHash(output string) bool {
+	_, value, found := strings.Cut(output, "ShadowHashData:")
+	return found && strings.TrimSpace(value) != ""
+}
+
+// Instructions returns how to connect to the instance's serial console with an SSH client.
+func Instructions(instanceID, region string) string {
+	return strings.TrimSpace(fmt.Sprintf(`
+Connect to the serial console from a machine with the AWS CLI and an SSH key pair:
+
+  aws ec2-instance-connect send-serial-console-ssh-public-key \
+    --region %[2]s --instance-id %[1]s --serial-port 0 \
+    --ssh-public-key file://~/.ssh/id_ed25519.pub
+  ssh %[1]s.port0@serial-console.ec2-instance-connect.%[2]s.aws
+
+The public key is valid for 60 seconds. Log in with the local user's password.
+Alternatively, connect from the EC2 console with "Connect", then "EC2 serial console".
+`, instanceID, region))
+}
@@ -0,0 +1,63 @@
+package serialconsole
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBootArgs(t *testing.T) {
+	assert.Equal(t, "-v serial=3", parseBootArgs("boot-args\t-v serial=3\n"))
+	assert.Equal(t, "", parseBootArgs("boot-args\t\n"))
+}
+
+func TestSerialEnabled(t *testing.T) {
+	assert.True(t, SerialEnabled("-v serial=3"))
+	assert.False(t, SerialEnabled("serial=1"))
+	assert.False(t, SerialEnabled(""))
+}
+
+func TestWithSerial(t *testing.T) {
+	assert.Equal(t, "serial=3", WithSerial(""))
+	assert.Equal(t, "-v debug=0x144 serial=3", WithSerial("-v serial=1 debug=0x144"))
+}
+
+func TestHasShadowHash(t *testing.T) {
+	assert.True(t, hasShadowHash("dsAttrTypeNative:ShadowHashData:\n 62706c6973743030d101\n"))
+	assert.False(t, hasShadowHash("dsAttrTypeNative:ShadowHashData:\n"))
+	assert.False(t, hasShadowHash(""))
+}
+
+func TestInstructions(t *testing.T) {
+	s := Instructions("i-0123", "us-west-2")
+	assert.Contains(t, s, "--instance-id i-0123")
+	assert.Contains(t, s, "ssh i-0123.port0@serial-console.ec2-instance-connect.us-west-2.aws")
+}
+
+type fakeEC2 struct {
+	enabled bool
+}
+
+func (f *fakeEC2) GetSerialConsoleAccessStatus(context.Context, *ec2.GetSerialConsoleAccessStatusInput, ...func(*ec2.Options)) (*ec2.GetSerialConsoleAccessStatusOutput, error) {
+	return &ec2.GetSerialConsoleAccessStatusOutput{SerialConsoleAccessEnabled: aws.Bool(f.enabled)}, nil
+}
+
+func (f *fakeEC2) EnableSerialConsoleAccess(context.Context, *ec2.EnableSerialConsoleAccessInput, ...func(*ec2.Options)) (*ec2.EnableSerialConsoleAccessOutput, error) {
+	f.enabled = true
+	return &ec2.EnableSerialConsoleAccessOutput{SerialConsoleAccessEnabled: aws.Bool(true)}, nil
+}
+
+func TestAccountAccess(t *testing.T) {
+	api := &fakeEC2{}
+	enabled, err := AccountAccessEnabled(context.Background(), api)
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	assert.NoError(t, EnableAccountAccess(context.Background(), api))
+	enabled, err = AccountAccessEnabled(context.Background(), api)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+}
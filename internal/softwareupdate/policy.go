@@ -0,0 +1,90 @@
+package softwareupdate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultPolicyPath is where the deferral policy is saved.
+const DefaultPolicyPath = "/private/var/db/ec2-macos-utils/updates-policy.json"
+
+// Policy defers updates that the fleet isn't ready to install. macOS only honors deferrals from MDM profiles, so the
+// policy is enforced by the updates commands rather than by softwareupdate itself.
+type Policy struct {
+	// PinMajor holds back macOS updates to a later major version than this one. Zero doesn't pin.
+	PinMajor int `json:"pin_major,omitempty"`
+	// DeferDays holds back updates until they've been available for this many days. Zero doesn't defer.
+	DeferDays int `json:"defer_days,omitempty"`
+	// FirstSeen records when each update was first listed, by label, to measure DeferDays.
+	FirstSeen map[string]time.Time `json:"first_seen,omitempty"`
+}
+
+// Deferred is an update held back by the policy.
+type Deferred struct {
+	Update
+	// Reason explains why the update is held back.
+	Reason string `json:"reason"`
+}
+
+// LoadPolicy reads the policy at path. A missing file is an empty policy.
+func LoadPolicy(path string) (*Policy, error) {
+	p := &Policy{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read update policy: %w", err)
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("decode update policy %s: %w", path, err)
+	}
+
+	return p, nil
+}
+
+// Save writes the policy to path.
+func (p *Policy) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode update policy: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create update policy directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write update policy: %w", err)
+	}
+
+	return nil
+}
+
+// Apply splits the updates into those allowed by the policy at now and those it defers. Updates that weren't seen
+// before are recorded as first seen at now, and updates no longer available are forgotten.
+func (p *Policy) Apply(updates []Update, now time.Time) (allowed []Update, deferred []Deferred) {
+	firstSeen := map[string]time.Time{}
+	for _, u := range updates {
+		seen, ok := p.FirstSeen[u.Label]
+		if !ok {
+			seen = now
+		}
+		firstSeen[u.Label] = seen
+
+		switch {
+		case p.PinMajor > 0 && u.IsMacOS() && u.MajorVersion() > p.PinMajor:
+			deferred = append(deferred, Deferred{Update: u, Reason: fmt.Sprintf("pinned to macOS %d", p.PinMajor)})
+		case p.DeferDays > 0 && now.Before(seen.AddDate(0, 0, p.DeferDays)):
+			until := seen.AddDate(0, 0, p.DeferDays).UTC().Format("2006-01-02")
+			deferred = append(deferred, Deferred{Update: u, Reason: fmt.Sprintf("deferred until %s", until)})
+		default:
+			allowed = append(allowed, u)
+		}
+	}
+	p.FirstSeen = firstSeen
+
+	return allowed, deferred
+}
@@ -0,0 +1,108 @@
+package softwareupdate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Preference domains of the automatic update settings.
+const (
+	softwareUpdateDomain = "/Library/Preferences/com.apple.SoftwareUpdate"
+	commerceDomain       = "/Library/Preferences/com.apple.commerce"
+)
+
+// Setting is an automatic update behavior, stored as one or more boolean preferences.
+type Setting struct {
+	// Name identifies the setting, e.g. "auto-check".
+	Name string
+	// Description describes the behavior the setting controls.
+	Description string
+	// Domain is the preference domain the keys are in.
+	Domain string
+	// Keys are the preference keys, which are all set to the same value.
+	Keys []string
+}
+
+// Settings are the automatic update settings, in the order System Settings shows them.
+var Settings = []Setting{
+	{
+		Name:        "auto-check",
+		Description: "check for updates in the background",
+		Domain:      softwareUpdateDomain,
+		Keys:        []string{"AutomaticCheckEnabled"},
+	},
+	{
+		Name:        "auto-download",
+		Description: "download new updates when available",
+		Domain:      softwareUpdateDomain,
+		Keys:        []string{"AutomaticDownload"},
+	},
+	{
+		Name:        "auto-install-macos",
+		Description: "install macOS updates",
+		Domain:      softwareUpdateDomain,
+		Keys:        []string{"AutomaticallyInstallMacOSUpdates"},
+	},
+	{
+		Name:        "auto-install-security",
+		Description: "install security responses and system files",
+		Domain:      softwareUpdateDomain,
+		Keys:        []string{"CriticalUpdateInstall", "ConfigDataInstall"},
+	},
+	{
+		Name:        "auto-install-apps",
+		Description: "install application updates from the App Store",
+		Domain:      commerceDomain,
+		Keys:        []string{"AutoUpdate"},
+	},
+}
+
+// LookupSetting returns the setting with the name.
+func LookupSetting(name string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Name == name {
+			return s, true
+		}
+	}
+
+	return Setting{}, false
+}
+
+// Read returns whether the setting is enabled. A setting whose first key isn't set reports ok as false, meaning
+// macOS uses its default.
+func (s Setting) Read(ctx context.Context) (enabled, ok bool, err error) {
+	out, err := util.ExecuteCommand(ctx, []string{"defaults", "read", s.Domain, s.Keys[0]}, "", nil, nil)
+	if err != nil {
+		if strings.Contains(out.Stderr, "does not exist") {
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("read %s %s: %s: %w", s.Domain, s.Keys[0], strings.TrimSpace(out.Stderr), err)
+	}
+
+	return strings.TrimSpace(out.Stdout) == "1", true, nil
+}
+
+// Write enables or disables the setting.
+func (s Setting) Write(ctx context.Context, enabled bool) error {
+	for _, c := range s.writeCommands(enabled) {
+		out, err := util.ExecuteCommand(ctx, c, "", nil, nil)
+		if err != nil {
+			return fmt.Errorf("write %s %s: %s: %w", s.Domain, c[3], strings.TrimSpace(out.Stderr), err)
+		}
+	}
+
+	return nil
+}
+
+// writeCommands returns the commands that write every key of the setting.
+func (s Setting) writeCommands(enabled bool) [][]string {
+	cmds := make([][]string, 0, len(s.Keys))
+	for _, key := range s.Keys {
+		cmds = append(cmds, []string{"defaults", "write", s.Domain, key, "-bool", fmt.Sprint(enabled)})
+	}
+
+	return cmds
+}
@@ -0,0 +1,140 @@
+// Package softwareupdate provides the functionality necessary for listing and installing macOS software updates with
+// softwareupdate(8), subject to a local deferral policy.
+package softwareupdate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+	"github.com/aws/ec2-macos-utils/pkg/command"
+)
+
+// Update is an available software update.
+type Update struct {
+	// Label identifies the update to softwareupdate, e.g. "macOS Sonoma 14.2.1-23C71".
+	Label string `json:"label"`
+	// Title is the update's name, e.g. "macOS Sonoma 14.2.1".
+	Title string `json:"title"`
+	// Version is the version the update installs.
+	Version string `json:"version"`
+	// SizeKiB is the download size.
+	SizeKiB int64 `json:"size_kib"`
+	// Recommended reports whether Apple recommends the update.
+	Recommended bool `json:"recommended"`
+	// Restart reports whether installing the update requires a restart.
+	Restart bool `json:"restart"`
+}
+
+// IsMacOS reports whether the update is a macOS update, as opposed to e.g. Safari or command line tools.
+func (u Update) IsMacOS() bool {
+	return strings.HasPrefix(u.Title, "macOS") || strings.HasPrefix(u.Label, "macOS")
+}
+
+// MajorVersion returns the major version the update installs, or 0 when the version is unknown.
+func (u Update) MajorVersion() int {
+	major, _, _ := strings.Cut(u.Version, ".")
+	n, _ := strconv.Atoi(major)
+	return n
+}
+
+// listCommand lists the available software updates. Its output may be cached by command.Default since it takes a
+// while to contact the catalog.
+var listCommand = []string{"softwareupdate", "--list"}
+
+// List returns the available software updates.
+func List(ctx context.Context) ([]Update, error) {
+	out, err := command.Default.Output(ctx, command.New(listCommand...))
+	if err != nil {
+		return nil, fmt.Errorf("list software updates: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseList(out.Stdout), nil
+}
+
+// InvalidateList discards the cached list of software updates, e.g. after changing what softwareupdate lists.
+func InvalidateList() {
+	command.Invalidate(command.Default, listCommand...)
+}
+
+// parseList parses the output of softwareupdate --list, where each update is described by a "* Label: ..." line
+// followed by a line of comma-separated "Key: value" attributes.
+func parseList(output string) []Update {
+	var updates []Update
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if label, ok := strings.CutPrefix(line, "* Label:"); ok {
+			updates = append(updates, Update{Label: strings.TrimSpace(label)})
+			continue
+		}
+		if len(updates) == 0 || !strings.HasPrefix(line, "Title:") {
+			continue
+		}
+
+		u := &updates[len(updates)-1]
+		for _, attr := range strings.Split(line, ",") {
+			key, value, ok := strings.Cut(attr, ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "Title":
+				u.Title = value
+			case "Version":
+				u.Version = value
+			case "Size":
+				u.SizeKiB, _ = strconv.ParseInt(strings.TrimSuffix(strings.TrimSuffix(value, "KiB"), "K"), 10, 64)
+			case "Recommended":
+				u.Recommended = value == "YES"
+			case "Action":
+				u.Restart = value == "restart"
+			}
+		}
+	}
+
+	return updates
+}
+
+// InstallOptions configures an installation.
+type InstallOptions struct {
+	// Restart restarts the instance after installing updates that require it.
+	Restart bool
+}
+
+// Install installs the updates with the labels, accepting their licenses so that nothing waits for input.
+func Install(ctx context.Context, labels []string, opts InstallOptions) error {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	cmd := installCommand(labels, opts)
+	logrus.WithField("labels", labels).Info("Installing software updates")
+	out, err := util.ExecuteCommand(ctx, cmd, "", nil, nil)
+	InvalidateList()
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("install software updates: %w", ctx.Err())
+		}
+		return fmt.Errorf("install software updates: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	logrus.WithField("output", strings.TrimSpace(out.Stdout)).Debug("Installed software updates")
+
+	return nil
+}
+
+// installCommand returns the softwareupdate command that installs the updates.
+func installCommand(labels []string, opts InstallOptions) []string {
+	cmd := []string{"softwareupdate", "--install", "--agree-to-license"}
+	if opts.Restart {
+		cmd = append(cmd, "--restart")
+	}
+
+	return append(cmd, labels...)
+}
@@ -0,0 +1,105 @@
+package softwareupdate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testList = `Software Update Tool
+
+Finding available software
+Software Update found the following new or updated software:
+* Label: macOS Sonoma 14.2.1-23C71
+	Title: macOS Sonoma 14.2.1, Version: 14.2.1, Size: 1433004KiB, Recommended: YES, Action: restart,
+* Label: Safari17.2.1VenturaAuto-17.2.1
+	Title: Safari, Version: 17.2.1, Size: 161040KiB, Recommended: YES,
+* Label: Command Line Tools for Xcode-15.1
+	Title: Command Line Tools for Xcode, Version: 15.1, Size: 735560K, Recommended: NO,
+`
+
+func TestParseList(t *testing.T) {
+	updates := parseList(testList)
+	assert.Len(t, updates, 3)
+
+	assert.Equal(t, Update{
+		Label:       "macOS Sonoma 14.2.1-23C71",
+		Title:       "macOS Sonoma 14.2.1",
+		Version:     "14.2.1",
+		SizeKiB:     1433004,
+		Recommended: true,
+		Restart:     true,
+	}, updates[0])
+	assert.True(t, updates[0].IsMacOS())
+	assert.Equal(t, 14, updates[0].MajorVersion())
+
+	assert.False(t, updates[1].Restart)
+	assert.False(t, updates[1].IsMacOS())
+	assert.False(t, updates[2].Recommended)
+	assert.Equal(t, int64(735560), updates[2].SizeKiB)
+}
+
+func TestParseList_None(t *testing.T) {
+	assert.Empty(t, parseList("Software Update Tool\n\nFinding available software\nNo new software available.\n"))
+}
+
+func TestInstallCommand(t *testing.T) {
+	assert.Equal(t, []string{"softwareupdate", "--install", "--agree-to-license", "Safari"}, installCommand([]string{"Safari"}, InstallOptions{}))
+	assert.Equal(t, []string{"softwareupdate", "--install", "--agree-to-license", "--restart", "a", "b"}, installCommand([]string{"a", "b"}, InstallOptions{Restart: true}))
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	updates := []Update{
+		{Label: "macOS Sonoma 14.2.1-23C71", Title: "macOS Sonoma 14.2.1", Version: "14.2.1"},
+		{Label: "macOS Ventura 13.6.3-22G436", Title: "macOS Ventura 13.6.3", Version: "13.6.3"},
+		{Label: "Safari-17.2.1", Title: "Safari", Version: "17.2.1"},
+	}
+	p := &Policy{
+		PinMajor:  13,
+		DeferDays: 7,
+		FirstSeen: map[string]time.Time{
+			"Safari-17.2.1": now.AddDate(0, 0, -8),
+			"removed":       now.AddDate(0, 0, -30),
+		},
+	}
+
+	allowed, deferred := p.Apply(updates, now)
+	assert.Equal(t, []Update{updates[2]}, allowed)
+	assert.Len(t, deferred, 2)
+	assert.Equal(t, "pinned to macOS 13", deferred[0].Reason)
+	assert.Equal(t, "deferred until 2024-01-17", deferred[1].Reason)
+	assert.Equal(t, now, p.FirstSeen["macOS Ventura 13.6.3-22G436"])
+	assert.NotContains(t, p.FirstSeen, "removed", "updates no longer available should be forgotten")
+
+	allowed, _ = p.Apply(updates, now.AddDate(0, 0, 7))
+	assert.Len(t, allowed, 2, "the deferral should end after the configured days")
+}
+
+func TestPolicy_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+
+	p, err := LoadPolicy(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &Policy{}, p, "a missing policy should be empty")
+
+	p.PinMajor = 14
+	assert.NoError(t, p.Save(path))
+	loaded, err := LoadPolicy(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 14, loaded.PinMajor)
+}
+
+func TestSetting_WriteCommands(t *testing.T) {
+	s, ok := LookupSetting("auto-install-security")
+	assert.True(t, ok)
+	assert.Equal(t, [][]string{
+		{"defaults", "write", softwareUpdateDomain, "CriticalUpdateInstall", "-bool", "false"},
+		{"defaults", "write", softwareUpdateDomain, "ConfigDataInstall", "-bool", "false"},
+	}, s.writeCommands(false))
+
+	_, ok = LookupSetting("auto-reboot")
+	assert.False(t, ok)
+}
@@ -0,0 +1,131 @@
+// Package spotlight provides the functionality necessary for controlling Spotlight indexing of volumes with mdutil.
+package spotlight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// DefaultStatePath is where the desired indexing state of volumes is saved.
+const DefaultStatePath = "/private/var/db/ec2-macos-utils/spotlight.json"
+
+// Indexing returns whether Spotlight indexing is enabled for the volume mounted at volume.
+func Indexing(ctx context.Context, volume string) (bool, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"mdutil", "-s", volume}, "", nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("mdutil -s %s: %s: %w", volume, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseStatus(out.Stdout)
+}
+
+// parseStatus parses mdutil -s, e.g. "/:\n\tIndexing enabled.".
+func parseStatus(output string) (bool, error) {
+	switch {
+	case strings.Contains(output, "Indexing enabled"):
+		return true, nil
+	case strings.Contains(output, "disabled"):
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown indexing status %q", strings.TrimSpace(output))
+	}
+}
+
+// SetIndexing turns Spotlight indexing of the volume mounted at volume on or off. Turning it off also erases the
+// volume's index.
+func SetIndexing(ctx context.Context, volume string, enabled bool) error {
+	for _, args := range setIndexingCommands(volume, enabled) {
+		out, err := util.ExecuteCommand(ctx, args, "", nil, nil)
+		if err != nil {
+			return fmt.Errorf("%s %s: %s: %w", args[0], args[1], strings.TrimSpace(out.Stderr), err)
+		}
+	}
+
+	return nil
+}
+
+// setIndexingCommands returns the mdutil commands that turn indexing on or off.
+func setIndexingCommands(volume string, enabled bool) [][]string {
+	if enabled {
+		return [][]string{{"mdutil", "-i", "on", volume}}
+	}
+
+	return [][]string{
+		{"mdutil", "-i", "off", volume},
+		{"mdutil", "-E", volume},
+	}
+}
+
+// State is the desired indexing state, by volume mount point, which drift is detected against.
+type State map[string]bool
+
+// LoadState reads the state at path. A missing file is an empty state.
+func LoadState(path string) (State, error) {
+	state := State{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read spotlight state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decode spotlight state %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// Save writes the state to path.
+func (s State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode spotlight state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create spotlight state directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write spotlight state: %w", err)
+	}
+
+	return nil
+}
+
+// Volumes returns the volumes of the state, sorted.
+func (s State) Volumes() []string {
+	volumes := make([]string, 0, len(s))
+	for volume := range s {
+		volumes = append(volumes, volume)
+	}
+	sort.Strings(volumes)
+
+	return volumes
+}
+
+// Drift returns the volumes whose indexing differs from the state. Volumes that aren't mounted are skipped.
+func (s State) Drift(ctx context.Context) ([]string, error) {
+	var drifted []string
+	for _, volume := range s.Volumes() {
+		if _, err := os.Stat(volume); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		enabled, err := Indexing(ctx, volume)
+		if err != nil {
+			return nil, err
+		}
+		if enabled != s[volume] {
+			drifted = append(drifted, volume)
+		}
+	}
+
+	return drifted, nil
+}
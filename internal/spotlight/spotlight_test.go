@@ -0,0 +1,45 @@
+package spotlight
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatus(t *testing.T) {
+	enabled, err := parseStatus("/:\n\tIndexing enabled. \n")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	for _, output := range []string{"/:\n\tIndexing disabled.\n", "/Volumes/Build:\n\tIndexing and searching disabled.\n"} {
+		enabled, err := parseStatus(output)
+		assert.NoError(t, err)
+		assert.False(t, enabled, output)
+	}
+
+	_, err = parseStatus("/:\n\tError: unknown indexing state.\n")
+	assert.Error(t, err)
+}
+
+func TestSetIndexingCommands(t *testing.T) {
+	assert.Equal(t, [][]string{{"mdutil", "-i", "on", "/"}}, setIndexingCommands("/", true))
+	assert.Equal(t, [][]string{{"mdutil", "-i", "off", "/"}, {"mdutil", "-E", "/"}}, setIndexingCommands("/", false))
+}
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db", "spotlight.json")
+
+	state, err := LoadState(path)
+	assert.NoError(t, err)
+	assert.Empty(t, state)
+
+	state["/Volumes/Build"] = false
+	state["/"] = true
+	assert.NoError(t, state.Save(path))
+
+	loaded, err := LoadState(path)
+	assert.NoError(t, err)
+	assert.Equal(t, state, loaded)
+	assert.Equal(t, []string{"/", "/Volumes/Build"}, loaded.Volumes())
+}
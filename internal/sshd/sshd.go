@@ -0,0 +1,196 @@
+// Package sshd provides the functionality necessary for configuring the SSH server with an sshd_config drop-in file.
+package sshd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+const (
+	// DropInDir is the directory of sshd_config drop-in files, which /etc/ssh/sshd_config includes.
+	DropInDir = "/etc/ssh/sshd_config.d"
+	// DropInPath is the drop-in file written by ec2-macos-utils. sshd uses the first value it reads for most
+	// settings, and drop-ins are read in name order, so it's named to be read before the system's 100-macos.conf.
+	DropInPath = DropInDir + "/050-ec2-macos-utils.conf"
+	// dropInHeader marks the drop-in file written by ec2-macos-utils.
+	dropInHeader = "# Managed by ec2-macos-utils, changes will be overwritten."
+)
+
+// Ciphers are the ciphers that can be allowed, strongest first.
+var Ciphers = []string{
+	"chacha20-poly1305@openssh.com",
+	"aes256-gcm@openssh.com",
+	"aes128-gcm@openssh.com",
+	"aes256-ctr",
+	"aes192-ctr",
+	"aes128-ctr",
+}
+
+// userPatternExp matches AllowUsers patterns: user names, optionally with wildcards and a @host part.
+var userPatternExp = regexp.MustCompile(`^[A-Za-z0-9_.*?-]+(@[A-Za-z0-9_.*?:/-]+)?$`)
+
+// Config is the SSH server configuration to apply. Unset fields leave the server's defaults in place.
+type Config struct {
+	// DisablePasswordAuth only allows public key authentication.
+	DisablePasswordAuth bool
+	// AllowUsers restricts logins to the users matching the patterns.
+	AllowUsers []string
+	// Ciphers restricts the ciphers to these.
+	Ciphers []string
+	// KeepAliveInterval is how often an idle client is probed, keeping NAT and load balancer mappings alive.
+	KeepAliveInterval time.Duration
+	// KeepAliveCountMax is the number of unanswered probes after which the client is disconnected.
+	KeepAliveCountMax int
+}
+
+// Validate checks the configuration before it's written.
+func (c Config) Validate() error {
+	for _, pattern := range c.AllowUsers {
+		if !userPatternExp.MatchString(pattern) {
+			return fmt.Errorf("invalid user pattern %q", pattern)
+		}
+	}
+	for _, cipher := range c.Ciphers {
+		if !containsString(Ciphers, cipher) {
+			return fmt.Errorf("unsupported cipher %q, expected one of %s", cipher, strings.Join(Ciphers, ", "))
+		}
+	}
+	if c.KeepAliveInterval < 0 || c.KeepAliveInterval%time.Second != 0 {
+		return fmt.Errorf("invalid keep-alive interval %v, must be whole seconds", c.KeepAliveInterval)
+	}
+	if c.KeepAliveCountMax < 0 {
+		return errors.New("keep-alive count cannot be negative")
+	}
+
+	return nil
+}
+
+// Render returns the drop-in file contents, see sshd_config(5).
+func (c Config) Render() string {
+	lines := []string{dropInHeader}
+	if c.DisablePasswordAuth {
+		// macOS authenticates passwords through PAM with keyboard-interactive authentication too.
+		lines = append(lines,
+			"PasswordAuthentication no",
+			"KbdInteractiveAuthentication no",
+		)
+	}
+	if len(c.AllowUsers) > 0 {
+		lines = append(lines, "AllowUsers "+strings.Join(c.AllowUsers, " "))
+	}
+	if len(c.Ciphers) > 0 {
+		lines = append(lines, "Ciphers "+strings.Join(c.Ciphers, ","))
+	}
+	if c.KeepAliveInterval > 0 {
+		lines = append(lines, "ClientAliveInterval "+strconv.Itoa(int(c.KeepAliveInterval/time.Second)))
+		if c.KeepAliveCountMax > 0 {
+			lines = append(lines, "ClientAliveCountMax "+strconv.Itoa(c.KeepAliveCountMax))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Apply installs the configuration's drop-in file, replacing the one installed before, and verifies the complete
+// server configuration with sshd -t. The previous drop-in is restored if verification fails so that the server keeps
+// accepting connections. sshd is started by launchd for each connection, so new connections use the configuration
+// without restarting it and established sessions are left alone.
+func Apply(ctx context.Context, c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	previous, err := readDropIn()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(DropInDir, 0755); err != nil {
+		return err
+	}
+
+	// The temporary file's name starts with a dot so that sshd's Include glob ignores it.
+	tmp, err := os.CreateTemp(DropInDir, ".ec2-macos-utils-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.WriteString(c.Render()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), DropInPath); err != nil {
+		return err
+	}
+	if err := Test(ctx); err != nil {
+		if previous != nil {
+			_ = os.WriteFile(DropInPath, previous, 0644)
+		} else {
+			_ = os.Remove(DropInPath)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Reset removes the drop-in file. It's not an error if there is none.
+func Reset() error {
+	data, err := readDropIn()
+	if err != nil || data == nil {
+		return err
+	}
+
+	return os.Remove(DropInPath)
+}
+
+// readDropIn reads the drop-in file, returning nil if there is none. It fails if the file wasn't written by
+// ec2-macos-utils, so that a file of the same name written by someone else isn't replaced or removed.
+func readDropIn() ([]byte, error) {
+	data, err := os.ReadFile(DropInPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(string(data), dropInHeader) {
+		return nil, fmt.Errorf("%s isn't managed by ec2-macos-utils", DropInPath)
+	}
+
+	return data, nil
+}
+
+// Test verifies the complete server configuration, including drop-in files.
+func Test(ctx context.Context) error {
+	out, err := util.ExecuteCommand(ctx, []string{"/usr/sbin/sshd", "-t"}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("invalid sshd configuration: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// containsString reports whether the list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
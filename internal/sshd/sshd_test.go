@@ -0,0 +1,51 @@
+package sshd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Render(t *testing.T) {
+	c := Config{
+		DisablePasswordAuth: true,
+		AllowUsers:          []string{"ec2-user", "ci@10.0.0.0/8"},
+		Ciphers:             []string{"chacha20-poly1305@openssh.com", "aes256-gcm@openssh.com"},
+		KeepAliveInterval:   time.Minute,
+		KeepAliveCountMax:   3,
+	}
+	assert.Equal(t, `# Managed by ec2-macos-utils, changes will be overwritten.
+PasswordAuthentication no
+KbdInteractiveAuthentication no
+AllowUsers ec2-user ci@10.0.0.0/8
+Ciphers chacha20-poly1305@openssh.com,aes256-gcm@openssh.com
+ClientAliveInterval 60
+ClientAliveCountMax 3
+`, c.Render())
+
+	assert.Equal(t, "# Managed by ec2-macos-utils, changes will be overwritten.\n", Config{}.Render())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{
+		AllowUsers:        []string{"ec2-user", "ci-*", "admin@10.0.0.*"},
+		Ciphers:           []string{"aes128-ctr"},
+		KeepAliveInterval: 30 * time.Second,
+		KeepAliveCountMax: 4,
+	}
+	assert.NoError(t, valid.Validate())
+
+	for name, modify := range map[string]func(*Config){
+		"user newline": func(c *Config) { c.AllowUsers = []string{"ec2-user\nPermitRootLogin yes"} },
+		"user space":   func(c *Config) { c.AllowUsers = []string{"ec2 user"} },
+		"cipher":       func(c *Config) { c.Ciphers = []string{"3des-cbc"} },
+		"interval":     func(c *Config) { c.KeepAliveInterval = 1500 * time.Millisecond },
+		"negative":     func(c *Config) { c.KeepAliveInterval = -time.Second },
+		"count":        func(c *Config) { c.KeepAliveCountMax = -1 },
+	} {
+		c := valid
+		modify(&c)
+		assert.Error(t, c.Validate(), name)
+	}
+}
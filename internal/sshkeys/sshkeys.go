@@ -0,0 +1,166 @@
+// Package sshkeys provides the functionality necessary for maintaining a user's SSH authorized_keys file with the
+// public keys provided to the instance.
+package sshkeys
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Markers delimit the keys managed in authorized_keys so that keys added by other means are left alone.
+const (
+	beginMarker = "# BEGIN ec2-macos-utils managed keys"
+	endMarker   = "# END ec2-macos-utils managed keys"
+)
+
+// Owner is the user that owns the authorized_keys file and its directory.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// Render returns the contents of authorized_keys with the managed section replaced by keys, keeping every other
+// line. The section is appended when it doesn't exist yet, and removed when there are no keys.
+func Render(existing []byte, keys []string) []byte {
+	var out bytes.Buffer
+	section := func() {
+		if len(keys) == 0 {
+			return
+		}
+		out.WriteString(beginMarker + "\n")
+		for _, key := range keys {
+			out.WriteString(strings.TrimSpace(key) + "\n")
+		}
+		out.WriteString(endMarker + "\n")
+	}
+
+	written, inSection := false, false
+	for _, line := range strings.SplitAfter(string(existing), "\n") {
+		if line == "" {
+			continue
+		}
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == beginMarker:
+			inSection = true
+		case trimmed == endMarker && inSection:
+			inSection = false
+			if !written {
+				section()
+				written = true
+			}
+		case !inSection:
+			out.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				out.WriteString("\n")
+			}
+		}
+	}
+	if !written {
+		section()
+	}
+
+	return out.Bytes()
+}
+
+// Sync writes keys to the managed section of the authorized_keys file at path, creating it and its directory as
+// needed, and reports whether the file changed. Keys in the section that aren't in keys are removed. The file and
+// its directory are owned by owner and only accessible to them, as sshd requires.
+//
+// The directory is controlled by the user, who could replace the directory or the file with symlinks to redirect
+// writes elsewhere, so neither is followed if it's a symlink, and the file is only accessed relative to the directory
+// that was opened.
+func Sync(path string, keys []string, owner Owner) (bool, error) {
+	dir, name := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false, fmt.Errorf("create %s: %w", dir, err)
+	}
+	dirFd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return false, fmt.Errorf("open %s, which must be a directory and not a symlink: %w", dir, err)
+	}
+	defer func() { _ = unix.Close(dirFd) }()
+	if err := secure(dirFd, dir, 0700, owner); err != nil {
+		return false, err
+	}
+
+	existing, err := readFileAt(dirFd, name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("read %s: %w", path, err)
+	}
+	updated := Render(existing, keys)
+	if err == nil && bytes.Equal(existing, updated) {
+		fd, err := unix.Openat(dirFd, name, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return false, fmt.Errorf("open %s: %w", path, err)
+		}
+		defer func() { _ = unix.Close(fd) }()
+		return false, secure(fd, path, 0600, owner)
+	}
+
+	// The file is replaced atomically so that sshd never reads a partially written file.
+	tmp := fmt.Sprintf(".authorized_keys-%016x", rand.Uint64())
+	fd, err := unix.Openat(dirFd, tmp, unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0600)
+	if err != nil {
+		return false, fmt.Errorf("create temporary file: %w", err)
+	}
+	defer func() { _ = unix.Unlinkat(dirFd, tmp, 0) }()
+
+	f := os.NewFile(uintptr(fd), filepath.Join(dir, tmp))
+	if _, err := f.Write(updated); err != nil {
+		_ = f.Close()
+		return false, fmt.Errorf("write %s: %w", f.Name(), err)
+	}
+	if err := secure(fd, f.Name(), 0600, owner); err != nil {
+		_ = f.Close()
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("write %s: %w", f.Name(), err)
+	}
+	if err := unix.Renameat(dirFd, tmp, dirFd, name); err != nil {
+		return false, fmt.Errorf("replace %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// readFileAt reads the regular file name in the directory dirFd, refusing to follow a symlink.
+func readFileAt(dirFd int, name string) ([]byte, error) {
+	fd, err := unix.Openat(dirFd, name, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), name)
+	defer func() { _ = f.Close() }()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s isn't a regular file", name)
+	}
+
+	return io.ReadAll(f)
+}
+
+// secure sets the permissions and owner of the open file fd, which is at path.
+func secure(fd int, path string, mode os.FileMode, owner Owner) error {
+	if err := unix.Fchmod(fd, uint32(mode)); err != nil {
+		return fmt.Errorf("set permissions of %s: %w", path, err)
+	}
+	if err := unix.Fchown(fd, owner.UID, owner.GID); err != nil {
+		return fmt.Errorf("set owner of %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package sshkeys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	existing := "ssh-rsa USER user-key\n" +
+		beginMarker + "\n" +
+		"ssh-rsa OLD old-key\n" +
+		endMarker + "\n" +
+		"ssh-rsa OTHER other-key"
+
+	assert.Equal(t, "ssh-rsa USER user-key\n"+
+		beginMarker+"\n"+
+		"ssh-ed25519 NEW new-key\n"+
+		endMarker+"\n"+
+		"ssh-rsa OTHER other-key\n",
+		string(Render([]byte(existing), []string{"ssh-ed25519 NEW new-key\n"})), "only the managed keys should be replaced")
+
+	assert.Equal(t, "ssh-rsa USER user-key\nssh-rsa OTHER other-key\n", string(Render([]byte(existing), nil)))
+}
+
+func TestRender_Append(t *testing.T) {
+	assert.Equal(t, "ssh-rsa USER user-key\n"+beginMarker+"\nssh-rsa KEY key\n"+endMarker+"\n",
+		string(Render([]byte("ssh-rsa USER user-key\n"), []string{"ssh-rsa KEY key"})))
+}
+
+func TestSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ssh", "authorized_keys")
+	owner := Owner{UID: os.Getuid(), GID: os.Getgid()}
+
+	changed, err := Sync(path, []string{"ssh-rsa KEY key"}, owner)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	changed, err = Sync(path, []string{"ssh-rsa KEY key"}, owner)
+	assert.NoError(t, err)
+	assert.False(t, changed, "unchanged keys shouldn't rewrite the file")
+
+	changed, err = Sync(path, nil, owner)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, data, "keys no longer provided should be removed")
+}
+
+func TestSync_Symlinks(t *testing.T) {
+	home := t.TempDir()
+	owner := Owner{UID: os.Getuid(), GID: os.Getgid()}
+	target := filepath.Join(t.TempDir(), "target")
+	require.NoError(t, os.Mkdir(target, 0755))
+
+	// A symlinked .ssh directory isn't followed.
+	require.NoError(t, os.Symlink(target, filepath.Join(home, ".ssh")))
+	_, err := Sync(filepath.Join(home, ".ssh", "authorized_keys"), []string{"ssh-rsa KEY key"}, owner)
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(target, "authorized_keys"))
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// Nor is a symlinked authorized_keys file.
+	dir := filepath.Join(home, ".ssh2")
+	require.NoError(t, os.Mkdir(dir, 0700))
+	file := filepath.Join(target, "file")
+	require.NoError(t, os.WriteFile(file, []byte("secret\n"), 0644))
+	require.NoError(t, os.Symlink(file, filepath.Join(dir, "authorized_keys")))
+	_, err = Sync(filepath.Join(dir, "authorized_keys"), []string{"ssh-rsa KEY key"}, owner)
+	assert.Error(t, err)
+	info, err = os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
@@ -0,0 +1,209 @@
+package state
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// MaxCheckHistory is the number of results kept for each check. Older results are removed as new ones are recorded.
+const MaxCheckHistory = 100
+
+// keyTimeFormat formats times in keys so that they sort in time order.
+const keyTimeFormat = "20060102T150405.000000000Z"
+
+// CheckResult is the result of a run of a check.
+type CheckResult struct {
+	Check string    `json:"check"`
+	Time  time.Time `json:"time"`
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+}
+
+// RecordCheck records the result of a run of a check, keeping the most recent MaxCheckHistory results of the check.
+func (s *Store) RecordCheck(r CheckResult) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	prefix := r.Check + "/"
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(BucketChecks))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(prefix+r.Time.UTC().Format(keyTimeFormat)), data); err != nil {
+			return err
+		}
+
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && hasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for len(keys) > MaxCheckHistory {
+			if err := b.Delete(keys[0]); err != nil {
+				return err
+			}
+			keys = keys[1:]
+		}
+		return nil
+	})
+}
+
+// CheckHistory returns the recorded results of the check, or of every check if it's empty, oldest first. Only the
+// most recent limit results are returned, unless limit is zero.
+func (s *Store) CheckHistory(check string, limit int) ([]CheckResult, error) {
+	prefix := ""
+	if check != "" {
+		prefix = check + "/"
+	}
+
+	var results []CheckResult
+	err := s.ForEach(BucketChecks, prefix, func(_ string, value json.RawMessage) (bool, error) {
+		var r CheckResult
+		if err := json.Unmarshal(value, &r); err != nil {
+			return false, err
+		}
+		results = append(results, r)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Keys are ordered by check first, so results of different checks are ordered by time here.
+	if check == "" {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Time.Before(results[j].Time) })
+	}
+	if limit > 0 && len(results) > limit {
+		results = results[len(results)-limit:]
+	}
+
+	return results, nil
+}
+
+// Capture is diagnostic data captured by a watchdog.
+type Capture struct {
+	// Watchdog is the name of the watchdog, e.g. network-health-monitor.
+	Watchdog string `json:"watchdog"`
+	// ID identifies what the data was captured for, e.g. a scheduled event's ID, so it's only captured once.
+	ID string `json:"id"`
+	// Path is where the data was saved.
+	Path string `json:"path"`
+	// Time is when the data was captured.
+	Time time.Time `json:"time"`
+}
+
+// captureKey returns the key of the capture of the watchdog with the ID.
+func captureKey(watchdog, id string) string {
+	return watchdog + "/" + id
+}
+
+// RecordCapture records the capture, replacing a previous capture of the watchdog with the same ID.
+func (s *Store) RecordCapture(c Capture) error {
+	return s.Put(BucketCaptures, captureKey(c.Watchdog, c.ID), c)
+}
+
+// Captured returns the capture of the watchdog with the ID, and whether there was one.
+func (s *Store) Captured(watchdog, id string) (Capture, bool, error) {
+	var c Capture
+	ok, err := s.Get(BucketCaptures, captureKey(watchdog, id), &c)
+
+	return c, ok, err
+}
+
+// Captures returns the captures of the watchdog, oldest first.
+func (s *Store) Captures(watchdog string) ([]Capture, error) {
+	var captures []Capture
+	err := s.ForEach(BucketCaptures, watchdog+"/", func(_ string, value json.RawMessage) (bool, error) {
+		var c Capture
+		if err := json.Unmarshal(value, &c); err != nil {
+			return false, err
+		}
+		captures = append(captures, c)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(captures, func(i, j int) bool { return captures[i].Time.Before(captures[j].Time) })
+
+	return captures, nil
+}
+
+// FirstbootModule is the status of a first-boot module on an instance.
+type FirstbootModule struct {
+	// Instance identifies the instance the module ran on, so that it runs again on instances launched from an image of
+	// the host.
+	Instance string `json:"instance"`
+	Module   string `json:"module"`
+	OK       bool   `json:"ok"`
+	// Time is when the module last ran, and Attempts how many times it ran on the instance.
+	Time       time.Time `json:"time"`
+	DurationMS int64     `json:"duration_ms"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// firstbootKey returns the key of the status of the module on the instance.
+func firstbootKey(instance, module string) string {
+	return instance + "/" + module
+}
+
+// RecordFirstbootModule records the status of the module on the instance, replacing its previous status.
+func (s *Store) RecordFirstbootModule(m FirstbootModule) error {
+	return s.Put(BucketFirstboot, firstbootKey(m.Instance, m.Module), m)
+}
+
+// FirstbootModules returns the status of the modules that ran on the instance, ordered by module name.
+func (s *Store) FirstbootModules(instance string) ([]FirstbootModule, error) {
+	var modules []FirstbootModule
+	err := s.ForEach(BucketFirstboot, instance+"/", func(_ string, value json.RawMessage) (bool, error) {
+		var m FirstbootModule
+		if err := json.Unmarshal(value, &m); err != nil {
+			return false, err
+		}
+		modules = append(modules, m)
+		return true, nil
+	})
+
+	return modules, err
+}
+
+// watchdogPauseKey is the key of the watchdogs' pause.
+const watchdogPauseKey = "pause"
+
+// WatchdogPause silences the watchdogs until a time, e.g. during planned maintenance.
+type WatchdogPause struct {
+	// Until is when the watchdogs resume on their own.
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+	// Time is when the watchdogs were paused.
+	Time time.Time `json:"time"`
+}
+
+// Active reports whether the pause silences the watchdogs at now.
+func (p WatchdogPause) Active(now time.Time) bool {
+	return now.Before(p.Until)
+}
+
+// PauseWatchdogs pauses the watchdogs, replacing a previous pause.
+func (s *Store) PauseWatchdogs(p WatchdogPause) error {
+	return s.Put(BucketWatchdogs, watchdogPauseKey, p)
+}
+
+// ResumeWatchdogs removes the watchdogs' pause, if any.
+func (s *Store) ResumeWatchdogs() error {
+	return s.Delete(BucketWatchdogs, watchdogPauseKey)
+}
+
+// WatchdogsPaused returns the watchdogs' pause, and whether there's one. The pause may have expired.
+func (s *Store) WatchdogsPaused() (WatchdogPause, bool, error) {
+	var p WatchdogPause
+	ok, err := s.Get(BucketWatchdogs, watchdogPauseKey, &p)
+
+	return p, ok, err
+}
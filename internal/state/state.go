@@ -0,0 +1,150 @@
+// Package state provides the functionality necessary for persisting the utility's own state, such as check history
+// and the diagnostic data captured by watchdogs, in a single embedded database.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultPath is where the state is stored.
+const DefaultPath = "/private/var/db/ec2-macos-utils/state.db"
+
+// lockTimeout is how long Open waits for another invocation, e.g. a watchdog, to close the store.
+const lockTimeout = 10 * time.Second
+
+// Buckets group related records, like tables.
+const (
+	// BucketChecks holds the history of check results, see RecordCheck.
+	BucketChecks = "checks"
+	// BucketCaptures holds the diagnostic data captured by watchdogs, see RecordCapture.
+	BucketCaptures = "captures"
+	// BucketJournals holds the journals of multi-step operations, see the journal package.
+	BucketJournals = "journals"
+	// BucketFirstboot holds the status of the first-boot modules run on each instance, see RecordFirstbootModule.
+	BucketFirstboot = "firstboot"
+	// BucketWatchdogs holds the settings operators give the watchdogs at runtime, see PauseWatchdogs.
+	BucketWatchdogs = "watchdogs"
+)
+
+// Store is the state database. It's locked while it's open, so it should only be kept open while it's used rather
+// than for an invocation's lifetime, which would block other invocations.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store at path, creating it if needed. It waits for other invocations holding the store open to close
+// it.
+func Open(path string) (*Store, error) {
+	// The state is only readable by root since it may describe the instance's problems.
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open state %s: %w", path, err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// OpenReadOnly opens the existing store at path for reading. Unlike Open, it shares the lock with other readers, but
+// still waits for an invocation writing the store to close it, and blocks writers while it's open.
+func OpenReadOnly(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("open state %s: %w", path, err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the store, releasing its lock.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Put stores v as JSON under the key of the bucket, replacing its previous value.
+func (s *Store) Put(bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+// Get decodes the value stored under the key of the bucket into v, and reports whether there was one.
+func (s *Store) Get(bucket, key string, v interface{}) (bool, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			data = b.Get([]byte(key))
+		}
+		if data == nil {
+			return nil
+		}
+		// Values are only valid during the transaction.
+		return json.Unmarshal(data, v)
+	})
+
+	return data != nil, err
+}
+
+// Delete removes the key of the bucket. Removing a missing key isn't an error.
+func (s *Store) Delete(bucket, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// errStop stops ForEach early.
+var errStop = errors.New("stop")
+
+// ForEach calls fn with each key of the bucket starting with prefix and its value, in key order, until it returns
+// false.
+func (s *Store) ForEach(bucket, prefix string, fn func(key string, value json.RawMessage) (bool, error)) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && hasPrefix(k, prefix); k, v = c.Next() {
+			// Values are only valid during the transaction, so they're copied.
+			more, err := fn(string(k), append(json.RawMessage(nil), v...))
+			if err != nil {
+				return err
+			}
+			if !more {
+				return errStop
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errStop) {
+		return nil
+	}
+
+	return err
+}
+
+func hasPrefix(key []byte, prefix string) bool {
+	return len(key) >= len(prefix) && string(key[:len(prefix)]) == prefix
+}
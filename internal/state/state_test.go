@@ -0,0 +1,160 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestStore(t *testing.T) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "state", "state.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestStore_PutGetDelete(t *testing.T) {
+	s := openTestStore(t)
+
+	var v map[string]int
+	ok, err := s.Get("bucket", "key", &v)
+	assert.NoError(t, err)
+	assert.False(t, ok, "missing bucket should have no value")
+
+	require.NoError(t, s.Put("bucket", "key", map[string]int{"n": 1}))
+	ok, err = s.Get("bucket", "key", &v)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]int{"n": 1}, v)
+
+	require.NoError(t, s.Delete("bucket", "key"))
+	require.NoError(t, s.Delete("bucket", "key"), "deleting a missing key should succeed")
+	ok, err = s.Get("bucket", "key", &v)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	_, err := OpenReadOnly(path)
+	assert.Error(t, err, "a missing store shouldn't be created")
+
+	s, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Put("bucket", "key", 1))
+	require.NoError(t, s.Close())
+
+	first, err := OpenReadOnly(path)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := OpenReadOnly(path)
+	require.NoError(t, err, "readers should share the store")
+	defer second.Close()
+
+	var v int
+	ok, err := second.Get("bucket", "key", &v)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Error(t, second.Put("bucket", "key", 2))
+}
+
+func TestStore_CheckHistory(t *testing.T) {
+	s := openTestStore(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < MaxCheckHistory+5; i++ {
+		require.NoError(t, s.RecordCheck(CheckResult{Check: "imds", Time: start.Add(time.Duration(i) * time.Minute), OK: i%2 == 0}))
+	}
+	require.NoError(t, s.RecordCheck(CheckResult{Check: "time", Time: start.Add(30 * time.Second), Error: "drift"}))
+	// A check whose name starts with another's must not be mixed up with it.
+	require.NoError(t, s.RecordCheck(CheckResult{Check: "imds-v2", Time: start, OK: true}))
+
+	results, err := s.CheckHistory("imds", 0)
+	require.NoError(t, err)
+	require.Len(t, results, MaxCheckHistory, "older results should be removed")
+	assert.Equal(t, start.Add(5*time.Minute), results[0].Time.UTC())
+	assert.Equal(t, start.Add((MaxCheckHistory+4)*time.Minute), results[len(results)-1].Time.UTC())
+
+	results, err = s.CheckHistory("imds", 2)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	results, err = s.CheckHistory("", 0)
+	require.NoError(t, err)
+	require.Len(t, results, MaxCheckHistory+2)
+	assert.Equal(t, "imds-v2", results[0].Check, "results of every check should be ordered by time")
+	assert.Equal(t, "time", results[1].Check)
+}
+
+func TestStore_Captures(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	_, ok, err := s.Captured("scheduled-events", "instance-event-1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.RecordCapture(Capture{Watchdog: "scheduled-events", ID: "instance-event-2", Path: "/b", Time: now}))
+	require.NoError(t, s.RecordCapture(Capture{Watchdog: "scheduled-events", ID: "instance-event-1", Path: "/a", Time: now.Add(time.Minute)}))
+	require.NoError(t, s.RecordCapture(Capture{Watchdog: "network-health-monitor", ID: "uuid", Path: "/c", Time: now}))
+
+	c, ok, err := s.Captured("scheduled-events", "instance-event-1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "/a", c.Path)
+
+	captures, err := s.Captures("scheduled-events")
+	require.NoError(t, err)
+	require.Len(t, captures, 2)
+	assert.Equal(t, "/b", captures[0].Path, "captures should be ordered by time")
+	assert.Equal(t, "/a", captures[1].Path)
+}
+
+func TestStore_FirstbootModules(t *testing.T) {
+	s := openTestStore(t)
+
+	modules, err := s.FirstbootModules("i-1/uuid")
+	assert.NoError(t, err)
+	assert.Empty(t, modules)
+
+	require.NoError(t, s.RecordFirstbootModule(FirstbootModule{Instance: "i-1/uuid", Module: "time", Attempts: 1, Error: "timeout"}))
+	require.NoError(t, s.RecordFirstbootModule(FirstbootModule{Instance: "i-1/uuid", Module: "time", OK: true, Attempts: 2}))
+	require.NoError(t, s.RecordFirstbootModule(FirstbootModule{Instance: "i-1/uuid", Module: "hostname", OK: true, Attempts: 1}))
+	require.NoError(t, s.RecordFirstbootModule(FirstbootModule{Instance: "i-2/uuid", Module: "time", OK: true, Attempts: 1}))
+
+	modules, err = s.FirstbootModules("i-1/uuid")
+	require.NoError(t, err)
+	require.Len(t, modules, 2, "only the instance's modules should be returned, once each")
+	assert.Equal(t, "hostname", modules[0].Module, "modules should be ordered by name")
+	assert.Equal(t, "time", modules[1].Module)
+	assert.True(t, modules[1].OK)
+	assert.Equal(t, 2, modules[1].Attempts)
+}
+
+func TestStore_WatchdogsPaused(t *testing.T) {
+	s := openTestStore(t)
+
+	_, ok, err := s.WatchdogsPaused()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, s.PauseWatchdogs(WatchdogPause{Until: now.Add(2 * time.Hour), Reason: "maintenance", Time: now}))
+	pause, ok, err := s.WatchdogsPaused()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "maintenance", pause.Reason)
+	assert.True(t, pause.Active(now.Add(time.Hour)))
+	assert.False(t, pause.Active(now.Add(2*time.Hour)), "the pause should expire")
+
+	require.NoError(t, s.ResumeWatchdogs())
+	_, ok, err = s.WatchdogsPaused()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, s.ResumeWatchdogs(), "resuming twice isn't an error")
+}
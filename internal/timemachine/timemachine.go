@@ -0,0 +1,126 @@
+// Package timemachine provides the functionality necessary for configuring Time Machine backups and local snapshots.
+package timemachine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+const (
+	// preferencesDomain is the Time Machine preference domain.
+	preferencesDomain = "/Library/Preferences/com.apple.TimeMachine"
+	// snapshotPrefix prefixes the names of Time Machine's local snapshots.
+	snapshotPrefix = "com.apple.TimeMachine."
+	// purgeAmount is an amount of bytes larger than any volume, which makes thinning delete every local snapshot.
+	purgeAmount = "999999999999999"
+	// purgeUrgency is the highest thinning urgency.
+	purgeUrgency = "4"
+)
+
+// Status is the state of Time Machine.
+type Status struct {
+	// AutoBackup is whether Time Machine backs up automatically.
+	AutoBackup bool `json:"auto_backup"`
+	// LocalSnapshots is whether local snapshots are kept, false when they've been disabled by this package.
+	LocalSnapshots bool `json:"local_snapshots"`
+	// Snapshots are the names of the local snapshots of the volume.
+	Snapshots []string `json:"snapshots"`
+}
+
+// GetStatus returns the state of Time Machine and the local snapshots of the volume mounted at mountPoint.
+func GetStatus(ctx context.Context, mountPoint string) (Status, error) {
+	var status Status
+	var err error
+	if status.AutoBackup, err = readBool(ctx, "AutoBackup", false); err != nil {
+		return Status{}, err
+	}
+	if status.LocalSnapshots, err = readBool(ctx, "MobileBackups", true); err != nil {
+		return Status{}, err
+	}
+	if status.Snapshots, err = Snapshots(ctx, mountPoint); err != nil {
+		return Status{}, err
+	}
+
+	return status, nil
+}
+
+// SetAutoBackup turns automatic backups on or off.
+func SetAutoBackup(ctx context.Context, enabled bool) error {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+
+	return tmutil(ctx, action)
+}
+
+// DisableLocalSnapshots stops Time Machine from keeping local snapshots and deletes those of the volume mounted at
+// mountPoint. Current macOS releases only take local snapshots for automatic backups, so automatic backups must
+// also be disabled to stop them entirely.
+func DisableLocalSnapshots(ctx context.Context, mountPoint string) error {
+	out, err := util.ExecuteCommand(ctx, []string{"defaults", "write", preferencesDomain, "MobileBackups", "-bool", "false"}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("write MobileBackups: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return tmutil(ctx, "thinlocalsnapshots", mountPoint, purgeAmount, purgeUrgency)
+}
+
+// EnableLocalSnapshots lets Time Machine keep local snapshots again.
+func EnableLocalSnapshots(ctx context.Context) error {
+	out, err := util.ExecuteCommand(ctx, []string{"defaults", "delete", preferencesDomain, "MobileBackups"}, "", nil, nil)
+	if err != nil && !strings.Contains(out.Stderr, "does not exist") {
+		return fmt.Errorf("delete MobileBackups: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Snapshots returns the names of Time Machine's local snapshots of the volume mounted at mountPoint.
+func Snapshots(ctx context.Context, mountPoint string) ([]string, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"tmutil", "listlocalsnapshots", mountPoint}, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("tmutil listlocalsnapshots: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseSnapshots(out.Stdout), nil
+}
+
+// parseSnapshots parses the snapshot names from tmutil listlocalsnapshots, which starts with a header on some
+// releases, e.g. "Snapshots for disk /:".
+func parseSnapshots(output string) []string {
+	var snapshots []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, snapshotPrefix) {
+			snapshots = append(snapshots, line)
+		}
+	}
+
+	return snapshots
+}
+
+// readBool reads the boolean preference, which is def when it isn't set.
+func readBool(ctx context.Context, key string, def bool) (bool, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"defaults", "read", preferencesDomain, key}, "", nil, nil)
+	if err != nil {
+		if strings.Contains(out.Stderr, "does not exist") {
+			return def, nil
+		}
+		return false, fmt.Errorf("read %s: %s: %w", key, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return strings.TrimSpace(out.Stdout) == "1", nil
+}
+
+// tmutil runs tmutil with the arguments.
+func tmutil(ctx context.Context, args ...string) error {
+	out, err := util.ExecuteCommand(ctx, append([]string{"tmutil"}, args...), "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("tmutil %s: %s: %w", args[0], strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
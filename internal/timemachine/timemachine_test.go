@@ -0,0 +1,19 @@
+package timemachine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSnapshots(t *testing.T) {
+	output := `Snapshots for disk /:
+com.apple.TimeMachine.2024-05-01-101500.local
+com.apple.TimeMachine.2024-05-01-111500.local
+`
+	assert.Equal(t, []string{
+		"com.apple.TimeMachine.2024-05-01-101500.local",
+		"com.apple.TimeMachine.2024-05-01-111500.local",
+	}, parseSnapshots(output))
+	assert.Empty(t, parseSnapshots("Snapshots for disk /:\n"))
+}
@@ -0,0 +1,120 @@
+// Package timesync provides the functionality necessary for configuring network time on macOS and measuring the
+// system clock's offset from a time server.
+package timesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+const (
+	// AmazonTimeSyncServer is the link-local address of the Amazon Time Sync Service.
+	AmazonTimeSyncServer = "169.254.169.123"
+	// ntpConfPath is where timed reads the time servers from.
+	ntpConfPath = "/etc/ntp.conf"
+	// timedService is the launchd service of the time daemon.
+	timedService = "system/com.apple.timed"
+)
+
+// Configure sets the time servers, in order of preference, and enables network time. The first server is set with
+// systemsetup, which also restarts the time daemon; the rest are added to its configuration as fallbacks.
+func Configure(ctx context.Context, servers []string) error {
+	if len(servers) == 0 {
+		return errors.New("at least one time server is required")
+	}
+	for _, server := range servers {
+		if server == "" || strings.ContainsAny(server, " \t\n") {
+			return fmt.Errorf("invalid time server %q", server)
+		}
+	}
+
+	if err := systemsetup(ctx, "-setnetworktimeserver", servers[0]); err != nil {
+		return err
+	}
+	if err := os.WriteFile(ntpConfPath, []byte(Render(servers)), 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", ntpConfPath, err)
+	}
+	if err := systemsetup(ctx, "-setusingnetworktime", "on"); err != nil {
+		return err
+	}
+
+	// The time daemon only reads its configuration at start.
+	out, err := util.ExecuteCommand(ctx, []string{"launchctl", "kickstart", "-k", timedService}, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("restart time daemon: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Render returns the ntp.conf contents for the servers.
+func Render(servers []string) string {
+	var b strings.Builder
+	for _, server := range servers {
+		fmt.Fprintf(&b, "server %s\n", server)
+	}
+
+	return b.String()
+}
+
+// ErrNetworkTimeUnknown is returned by NetworkTimeEnabled when it isn't running as root, which systemsetup requires.
+var ErrNetworkTimeUnknown = errors.New("whether network time is enabled can only be read as root")
+
+// NetworkTimeEnabled returns whether the system time is set from the network.
+func NetworkTimeEnabled(ctx context.Context) (bool, error) {
+	if os.Geteuid() != 0 {
+		return false, ErrNetworkTimeUnknown
+	}
+	out, err := util.ExecuteCommand(ctx, []string{"systemsetup", "-getusingnetworktime"}, "", nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("get network time: %s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return strings.Contains(out.Stdout, ": On"), nil
+}
+
+// Offset queries the server and returns the offset of the system clock from it. A positive offset means that the
+// system clock is behind the server.
+func Offset(ctx context.Context, server string) (time.Duration, error) {
+	out, err := util.ExecuteCommand(ctx, []string{"sntp", "-t", "5", server}, "", nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("query %s: %s: %w", server, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseOffset(out.Stdout)
+}
+
+// parseOffset parses the offset in seconds from sntp's output, e.g. "+0.000162 +/- 0.000046 169.254.169.123 ...".
+func parseOffset(output string) (time.Duration, error) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "+/-" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return 0, fmt.Errorf("invalid offset %q", fields[0])
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	return 0, fmt.Errorf("no offset in sntp output %q", strings.TrimSpace(output))
+}
+
+// systemsetup runs systemsetup with the arguments.
+func systemsetup(ctx context.Context, args ...string) error {
+	out, err := util.ExecuteCommand(ctx, append([]string{"systemsetup"}, args...), "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("systemsetup %s: %s: %w", args[0], strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
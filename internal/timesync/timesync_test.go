@@ -0,0 +1,25 @@
+package timesync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	assert.Equal(t, "server 169.254.169.123\nserver time.aws.com\n", Render([]string{AmazonTimeSyncServer, "time.aws.com"}))
+}
+
+func TestParseOffset(t *testing.T) {
+	offset, err := parseOffset("sntp 4.2.8p10@1.3728-o Tue Jan 10 00:00:00 UTC 2023 (1)\n+0.250000 +/- 0.000046 169.254.169.123 s3 no-leap\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, offset)
+
+	offset, err = parseOffset("-1.5 +/- 0.1 169.254.169.123 s3 no-leap")
+	assert.NoError(t, err)
+	assert.Equal(t, -1500*time.Millisecond, offset)
+
+	_, err = parseOffset("sntp: no reply from 169.254.169.123")
+	assert.Error(t, err)
+}
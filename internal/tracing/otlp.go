@@ -0,0 +1,182 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EndpointEnvVar is the standard OpenTelemetry environment variable with the base URL of the OTLP collector.
+	EndpointEnvVar = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	// TracesEndpointEnvVar is the standard OpenTelemetry environment variable with the URL of the OTLP collector's
+	// traces endpoint.
+	TracesEndpointEnvVar = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	// tracesPath is appended to base URLs to get the traces endpoint.
+	tracesPath = "/v1/traces"
+	// scopeName is the instrumentation scope of the spans.
+	scopeName = "github.com/aws/ec2-macos-utils"
+)
+
+// OTLP span kinds and status codes, see opentelemetry/proto/trace/v1/trace.proto.
+const (
+	spanKindInternal = 1
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+// EndpointFromEnv returns the traces endpoint configured by the standard OpenTelemetry environment variables, if any.
+func EndpointFromEnv() string {
+	if endpoint := os.Getenv(TracesEndpointEnvVar); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv(EndpointEnvVar); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + tracesPath
+	}
+
+	return ""
+}
+
+// OTLPExporter exports spans to an OpenTelemetry collector with OTLP over HTTP, encoded as JSON.
+type OTLPExporter struct {
+	// Endpoint is the URL of the collector's traces endpoint, e.g. http://localhost:4318/v1/traces.
+	Endpoint string
+	// Resource are the attributes of the entity producing the spans, e.g. service.name.
+	Resource map[string]string
+	// Client sends the requests. It defaults to a client with a 10 second timeout.
+	Client *http.Client
+}
+
+// Export sends the spans to the collector.
+func (e *OTLPExporter) Export(ctx context.Context, spans []*Span) error {
+	data, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export spans: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("export spans: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// The types below are the JSON encoding of the OTLP ExportTraceServiceRequest message, in which IDs are hex encoded
+// and 64-bit integers are strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// request encodes the spans as an OTLP export request.
+func (e *OTLPExporter) request(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        attributes(s.Attributes),
+			Status:            otlpStatus{Code: statusCodeOK},
+		}
+		if s.ParentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+		}
+		if s.Err != nil {
+			span.Status = otlpStatus{Code: statusCodeError, Message: s.Err.Error()}
+		}
+		encoded = append(encoded, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes(e.Resource)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: encoded}},
+	}}}
+}
+
+// attributes encodes the attributes in key order.
+func attributes(m map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, otlpAttribute{Key: k, Value: otlpValue{StringValue: m[k]}})
+	}
+
+	return attrs
+}
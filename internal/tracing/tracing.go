@@ -0,0 +1,127 @@
+// Package tracing provides the functionality necessary for tracing where time goes during an invocation and exporting
+// the spans to an OpenTelemetry collector with OTLP over HTTP.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Tracer collects the spans of an invocation until they're flushed to its exporter.
+type Tracer struct {
+	exporter Exporter
+
+	mu    sync.Mutex
+	ended []*Span
+}
+
+// Exporter sends ended spans to a collector.
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+}
+
+// NewTracer returns a tracer that exports its spans with the exporter.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Span is a timed operation of a trace. A nil span, returned when there's no tracer, ignores every call.
+type Span struct {
+	tracer *Tracer
+
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	// Err is the error that the operation failed with.
+	Err error
+}
+
+type contextKey struct{}
+
+// WithTracer extends the context so that spans started from it are collected by the tracer.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, contextKey{}, &Span{tracer: t})
+}
+
+// Start starts a span named name, as a child of the context's span if there's one or as the root of a new trace
+// otherwise, and returns a context carrying it. Without a tracer in the context, the span is nil.
+func Start(ctx context.Context, name string, attributes map[string]string) (context.Context, *Span) {
+	parent, _ := ctx.Value(contextKey{}).(*Span)
+	if parent == nil || parent.tracer == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		tracer:     parent.tracer,
+		TraceID:    parent.TraceID,
+		ParentID:   parent.SpanID,
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: attributes,
+	}
+	if s.ParentID == [8]byte{} {
+		_, _ = rand.Read(s.TraceID[:])
+	}
+	_, _ = rand.Read(s.SpanID[:])
+
+	return context.WithValue(ctx, contextKey{}, s), s
+}
+
+// SetAttribute records an attribute of the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = map[string]string{}
+	}
+	s.Attributes[key] = value
+}
+
+// Finish ends the span with the error the operation failed with, if any, and queues it for export.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.End, s.Err = time.Now(), err
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.ended = append(s.tracer.ended, s)
+}
+
+// TraceIDString returns the hex encoded trace ID, as shown by tracing backends.
+func (s *Span) TraceIDString() string {
+	if s == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(s.TraceID[:])
+}
+
+// Flush exports the spans that ended since the last flush by the context's tracer, e.g. at the end of an invocation
+// or of each cycle of a long-running one. It does nothing without a tracer.
+func Flush(ctx context.Context) error {
+	parent, _ := ctx.Value(contextKey{}).(*Span)
+	if parent == nil || parent.tracer == nil {
+		return nil
+	}
+	t := parent.tracer
+
+	t.mu.Lock()
+	spans := t.ended
+	t.ended = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	return t.exporter.Export(ctx, spans)
+}
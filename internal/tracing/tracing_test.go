@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeExporter struct {
+	spans []*Span
+}
+
+func (e *fakeExporter) Export(_ context.Context, spans []*Span) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestStart_NoTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), "grow", nil)
+	assert.Nil(t, span)
+	span.SetAttribute("disk", "disk1")
+	span.Finish(nil)
+	assert.NoError(t, Flush(ctx))
+}
+
+func TestStart(t *testing.T) {
+	exporter := &fakeExporter{}
+	ctx := WithTracer(context.Background(), NewTracer(exporter))
+
+	rootCtx, root := Start(ctx, "grow", nil)
+	_, child := Start(rootCtx, "exec diskutil", map[string]string{"process.executable.name": "diskutil"})
+	child.Finish(errors.New("exit status 1"))
+	assert.NoError(t, Flush(rootCtx))
+	assert.Len(t, exporter.spans, 1, "spans are exported as they end")
+
+	root.SetAttribute("run.id", "run-1")
+	root.Finish(nil)
+	assert.NoError(t, Flush(ctx))
+	assert.NoError(t, Flush(ctx), "flushed spans aren't exported again")
+
+	if assert.Len(t, exporter.spans, 2) {
+		assert.Equal(t, root.TraceID, child.TraceID)
+		assert.Equal(t, root.SpanID, child.ParentID)
+		assert.Equal(t, [8]byte{}, root.ParentID)
+		assert.NotEqual(t, [16]byte{}, root.TraceID)
+		assert.Equal(t, "run-1", exporter.spans[1].Attributes["run.id"])
+	}
+
+	// Another root span starts another trace.
+	_, other := Start(ctx, "grow", nil)
+	assert.NotEqual(t, root.TraceID, other.TraceID)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		data, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(data, &body))
+	}))
+	defer server.Close()
+
+	exporter := &OTLPExporter{Endpoint: server.URL + "/v1/traces", Resource: map[string]string{"service.name": "ec2-macos-utils"}}
+	ctx := WithTracer(context.Background(), NewTracer(exporter))
+	_, span := Start(ctx, "debug create-sysdiagnose", nil)
+	span.Finish(errors.New("timeout"))
+	assert.NoError(t, Flush(ctx))
+
+	resourceSpans := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "ec2-macos-utils"}}},
+		resourceSpans["resource"].(map[string]interface{})["attributes"])
+	encoded := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, span.TraceIDString(), encoded["traceId"])
+	assert.Equal(t, "debug create-sysdiagnose", encoded["name"])
+	assert.NotContains(t, encoded, "parentSpanId")
+	assert.Equal(t, map[string]interface{}{"code": float64(statusCodeError), "message": "timeout"}, encoded["status"])
+}
+
+func TestOTLPExporter_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unsupported", http.StatusUnsupportedMediaType)
+	}))
+	defer server.Close()
+
+	exporter := &OTLPExporter{Endpoint: server.URL}
+	assert.Error(t, exporter.Export(context.Background(), []*Span{{Name: "grow"}}))
+}
+
+func TestEndpointFromEnv(t *testing.T) {
+	t.Setenv(TracesEndpointEnvVar, "")
+	t.Setenv(EndpointEnvVar, "")
+	assert.Empty(t, EndpointFromEnv())
+
+	t.Setenv(EndpointEnvVar, "http://localhost:4318/")
+	assert.Equal(t, "http://localhost:4318/v1/traces", EndpointFromEnv())
+
+	t.Setenv(TracesEndpointEnvVar, "http://collector:4318/custom")
+	assert.Equal(t, "http://collector:4318/custom", EndpointFromEnv())
+}
@@ -0,0 +1,205 @@
+// Package uiprefs provides the functionality necessary for setting the user preferences that keep modal dialogs
+// from blocking unattended UI sessions.
+package uiprefs
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Pref is a user preference.
+type Pref struct {
+	// Domain is the preference domain, e.g. com.apple.CrashReporter.
+	Domain string
+	// Key is the preference key.
+	Key string
+	// CurrentHost stores the preference for the current host only, which some preferences are read from.
+	CurrentHost bool
+	// Type is the defaults type flag of the value, e.g. -bool.
+	Type string
+	// Value is the value that suppresses the dialogs.
+	Value string
+	// SystemValue is the sw_vers flag, e.g. -buildVersion, of the system value that suppresses the dialogs, for
+	// preferences that record the last version a dialog was seen on. It overrides Value.
+	SystemValue string
+}
+
+// Setting is a group of preferences that suppresses a kind of dialog when set. When unset, the preferences are
+// deleted so that macOS uses its defaults.
+type Setting struct {
+	// Name identifies the setting, e.g. "suppress-crash-dialogs".
+	Name string
+	// Description describes the dialogs the setting suppresses.
+	Description string
+	// Prefs are the preferences of the setting.
+	Prefs []Pref
+}
+
+// Settings are the dialog suppression settings.
+var Settings = []Setting{
+	{
+		Name:        "suppress-crash-dialogs",
+		Description: "report crashes with a notification instead of the modal crash reporter dialog",
+		Prefs: []Pref{
+			{Domain: "com.apple.CrashReporter", Key: "DialogType", Type: "-string", Value: "none"},
+			{Domain: "com.apple.CrashReporter", Key: "UseUNC", Type: "-bool", Value: "true"},
+		},
+	},
+	{
+		Name:        "suppress-setup-assistant",
+		Description: "skip the Setup Assistant panes (Siri, analytics, Screen Time, appearance, ...) shown at first login",
+		Prefs: append(seenPrefs(
+			"DidSeeSiriSetup",
+			"DidSeePrivacy",
+			"DidSeeScreenTime",
+			"DidSeeAppearanceSetup",
+			"DidSeeTouchIDSetup",
+			"DidSeeAccessibility",
+			"DidSeeTrueTonePrivacy",
+			"DidSeeApplePaySetup",
+			"DidSeeActivationLock",
+			"DidSeeSyncSetup",
+			"DidSeeSyncSetup2",
+		), Pref{Domain: setupAssistantDomain, Key: "LastSeenBuddyBuildVersion", Type: "-string", SystemValue: "-buildVersion"}),
+	},
+	{
+		Name:        "skip-icloud",
+		Description: "skip the iCloud and Apple Account sign-in prompts shown at first login and after updates",
+		Prefs: append(seenPrefs(
+			"DidSeeCloudSetup",
+			"DidSeeiCloudLoginForStorageServices",
+		), Pref{Domain: setupAssistantDomain, Key: "LastSeenCloudProductVersion", Type: "-string", SystemValue: "-productVersion"}),
+	},
+}
+
+// setupAssistantDomain is the preference domain where Setup Assistant records the panes a user has seen.
+const setupAssistantDomain = "com.apple.SetupAssistant"
+
+// seenPrefs returns the preferences that record that the user has seen the Setup Assistant panes with the keys.
+func seenPrefs(keys ...string) []Pref {
+	prefs := make([]Pref, len(keys))
+	for i, key := range keys {
+		prefs[i] = Pref{Domain: setupAssistantDomain, Key: key, Type: "-bool", Value: "true"}
+	}
+
+	return prefs
+}
+
+// lookupSetting returns the setting with the name.
+func lookupSetting(name string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Name == name {
+			return s, true
+		}
+	}
+
+	return Setting{}, false
+}
+
+// resolve returns the setting with the system values of its preferences filled in.
+func (s Setting) resolve(ctx context.Context) (Setting, error) {
+	prefs := make([]Pref, len(s.Prefs))
+	for i, p := range s.Prefs {
+		if p.SystemValue != "" {
+			out, err := util.ExecuteCommand(ctx, []string{"sw_vers", p.SystemValue}, "", nil, nil)
+			if err != nil {
+				return Setting{}, fmt.Errorf("sw_vers %s: %s: %w", p.SystemValue, strings.TrimSpace(out.Stderr), err)
+			}
+			p.Value = strings.TrimSpace(out.Stdout)
+		}
+		prefs[i] = p
+	}
+	s.Prefs = prefs
+
+	return s, nil
+}
+
+// Read returns whether every preference of the setting is set for the user.
+func (s Setting) Read(ctx context.Context, username string) (bool, error) {
+	s, err := s.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range s.Prefs {
+		out, err := defaults(ctx, username, p.args("read"))
+		if err != nil {
+			if strings.Contains(out, "does not exist") {
+				return false, nil
+			}
+			return false, fmt.Errorf("read %s %s: %s: %w", p.Domain, p.Key, strings.TrimSpace(out), err)
+		}
+		if strings.TrimSpace(out) != p.readValue() {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Write sets the preferences of the setting for the user when suppress is true, and deletes them otherwise.
+func (s Setting) Write(ctx context.Context, username string, suppress bool) error {
+	if suppress {
+		var err error
+		if s, err = s.resolve(ctx); err != nil {
+			return err
+		}
+	}
+	for _, args := range s.writeArgs(suppress) {
+		out, err := defaults(ctx, username, args)
+		if err != nil && !(!suppress && strings.Contains(out, "does not exist")) {
+			return fmt.Errorf("defaults %s: %s: %w", strings.Join(args, " "), strings.TrimSpace(out), err)
+		}
+	}
+
+	return nil
+}
+
+// writeArgs returns the defaults arguments that set or delete the preferences.
+func (s Setting) writeArgs(suppress bool) [][]string {
+	var args [][]string
+	for _, p := range s.Prefs {
+		if suppress {
+			args = append(args, append(p.args("write"), p.Type, p.Value))
+		} else {
+			args = append(args, p.args("delete"))
+		}
+	}
+
+	return args
+}
+
+// readValue returns the value as defaults reads it back, which is 1 or 0 for booleans.
+func (p Pref) readValue() string {
+	if p.Type != "-bool" {
+		return p.Value
+	}
+	if p.Value == "true" {
+		return "1"
+	}
+	return "0"
+}
+
+// args returns the defaults arguments that run the action on the preference.
+func (p Pref) args(action string) []string {
+	var args []string
+	if p.CurrentHost {
+		args = append(args, "-currentHost")
+	}
+
+	return append(args, action, p.Domain, p.Key)
+}
+
+// defaults runs defaults as the user, so that the user's preferences are changed, and returns its combined output.
+func defaults(ctx context.Context, username string, args []string) (string, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return "", fmt.Errorf("cannot find user %q: %w", username, err)
+	}
+	out, err := util.ExecuteCommand(ctx, append([]string{"defaults"}, args...), username, []string{"HOME=" + u.HomeDir, "USER=" + username}, nil)
+
+	return out.Stdout + out.Stderr, err
+}
@@ -0,0 +1,33 @@
+package uiprefs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetting_WriteArgs(t *testing.T) {
+	crash, ok := lookupSetting("suppress-crash-dialogs")
+	assert.True(t, ok)
+	assert.Equal(t, [][]string{
+		{"write", "com.apple.CrashReporter", "DialogType", "-string", "none"},
+		{"write", "com.apple.CrashReporter", "UseUNC", "-bool", "true"},
+	}, crash.writeArgs(true))
+	assert.Equal(t, [][]string{
+		{"delete", "com.apple.CrashReporter", "DialogType"},
+		{"delete", "com.apple.CrashReporter", "UseUNC"},
+	}, crash.writeArgs(false))
+
+	icloud, ok := lookupSetting("skip-icloud")
+	assert.True(t, ok)
+	icloud.Prefs = append([]Pref(nil), icloud.Prefs...)
+	icloud.Prefs[2].Value = "15.1"
+	assert.Equal(t, [][]string{
+		{"write", "com.apple.SetupAssistant", "DidSeeCloudSetup", "-bool", "true"},
+		{"write", "com.apple.SetupAssistant", "DidSeeiCloudLoginForStorageServices", "-bool", "true"},
+		{"write", "com.apple.SetupAssistant", "LastSeenCloudProductVersion", "-string", "15.1"},
+	}, icloud.writeArgs(true))
+
+	_, ok = lookupSetting("suppress-everything")
+	assert.False(t, ok)
+}
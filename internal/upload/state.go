@@ -0,0 +1,78 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// state is the saved progress of a multipart upload.
+type state struct {
+	// path is where the state is saved. It's empty when resumption is disabled.
+	path string
+
+	// UploadID identifies the multipart upload.
+	UploadID string `json:"upload_id"`
+	// Parts maps the numbers of the uploaded parts to their ETags.
+	Parts map[int32]string `json:"parts"`
+}
+
+// loadState returns the saved progress of uploading the file to the bucket and key. The state is keyed by the file's
+// identity and size so that a changed file starts a new upload.
+func (u *Uploader) loadState(file string, fi os.FileInfo, bucket, key string, partSize int64) *state {
+	st := &state{}
+	if u.Options.StateDir == "" {
+		return st
+	}
+
+	id := fmt.Sprintf("%s\x00%d\x00%d\x00%s\x00%s\x00%d", file, fi.Size(), fi.ModTime().UnixNano(), bucket, key, partSize)
+	sum := sha256.Sum256([]byte(id))
+	st.path = filepath.Join(u.Options.StateDir, hex.EncodeToString(sum[:16])+".json")
+
+	data, err := os.ReadFile(st.path)
+	if err != nil {
+		return st
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		logrus.WithError(err).WithField("path", st.path).Warn("Ignoring unreadable upload state")
+		return &state{path: st.path}
+	}
+
+	return st
+}
+
+// saveState persists the upload's progress. Failing to save only prevents resumption, so errors are logged.
+func (u *Uploader) saveState(st *state) {
+	if st.path == "" {
+		return
+	}
+
+	data, err := json.Marshal(st)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(st.path), 0700)
+	}
+	if err == nil {
+		tmp := st.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, st.path)
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to save upload state")
+	}
+}
+
+// removeState deletes the state of a completed upload.
+func (u *Uploader) removeState(st *state) {
+	if st.path == "" {
+		return
+	}
+	if err := os.Remove(st.path); err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).Warn("Failed to remove upload state")
+	}
+}
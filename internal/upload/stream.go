@@ -0,0 +1,144 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/progress"
+	"github.com/aws/ec2-macos-utils/internal/selfmetrics"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
+)
+
+// UploadStream uploads what's read from r until EOF to the bucket and key, uploading each part as soon as it's read,
+// so that an artifact can be uploaded while it's being written instead of being read again from disk afterwards.
+// Unlike UploadFile, a stream that fails can't be resumed, and the parts it uploaded are discarded.
+func (u *Uploader) UploadStream(ctx context.Context, r io.Reader, bucket, key string) (err error) {
+	ctx, span := tracing.Start(ctx, "s3 upload", map[string]string{"aws.s3.bucket": bucket, "aws.s3.key": key})
+	defer func() { span.Finish(err) }()
+
+	fields := logrus.Fields{
+		"bucket": bucket,
+		"key":    key,
+	}
+	logrus.WithFields(fields).Info("Streaming upload to S3")
+
+	limiter := newLimiter(u.Options.BytesPerSecond)
+	// The size of the stream isn't known up front, so parts aren't grown to fit it.
+	buf := make([]byte, u.partSize(0))
+	n, readErr := readPart(r, buf)
+	if readErr != nil && !errors.Is(readErr, io.EOF) {
+		return readErr
+	}
+	if readErr != nil {
+		// The stream fits in a single part.
+		if err := u.putObject(ctx, bytes.NewReader(buf[:n]), int64(n), bucket, key, limiter); err != nil {
+			return err
+		}
+		selfmetrics.UploadBytes.Add("", float64(n))
+		reportUploaded(ctx, int64(n), int64(n))
+		logrus.WithFields(fields).WithField("bytes", n).Info("Uploaded stream to S3")
+		return nil
+	}
+
+	id, err := u.createMultipartUpload(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			u.abortMultipartUpload(ctx, bucket, key, id)
+		}
+	}()
+
+	var (
+		completed []types.CompletedPart
+		uploaded  int64
+	)
+	for number := int32(1); n > 0; number++ {
+		if number > maxParts {
+			return fmt.Errorf("stream exceeds the %d parts of a multipart upload", maxParts)
+		}
+		var out *s3.UploadPartOutput
+		err := u.retry(ctx, fmt.Sprintf("upload part %d", number), func() error {
+			var err error
+			out, err = u.Client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:        aws.String(bucket),
+				Key:           aws.String(key),
+				UploadId:      aws.String(id),
+				PartNumber:    aws.Int32(number),
+				Body:          newThrottledReader(ctx, bytes.NewReader(buf[:n]), limiter),
+				ContentLength: aws.Int64(int64(n)),
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		selfmetrics.UploadBytes.Add("", float64(n))
+		uploaded += int64(n)
+		contextual.Progress(ctx).Report("upload", progress.Unknown, units.HumanSize(float64(uploaded))+" uploaded")
+		completed = append(completed, types.CompletedPart{PartNumber: aws.Int32(number), ETag: out.ETag})
+		logrus.WithField("part", number).Debug("Uploaded part")
+
+		// A short part is the last one.
+		if readErr != nil {
+			break
+		}
+		if n, readErr = readPart(r, buf); readErr != nil && !errors.Is(readErr, io.EOF) {
+			return readErr
+		}
+	}
+
+	err = u.retry(ctx, "complete multipart upload", func() error {
+		_, err := u.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(bucket),
+			Key:             aws.String(key),
+			UploadId:        aws.String(id),
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	reportUploaded(ctx, uploaded, uploaded)
+	logrus.WithFields(fields).WithField("bytes", uploaded).Info("Uploaded stream to S3")
+
+	return nil
+}
+
+// readPart fills buf from r. It returns io.EOF when r ended before buf was filled, with the number of bytes read.
+func readPart(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("read upload stream: %w", err)
+	}
+
+	return n, err
+}
+
+// abortMultipartUpload discards the parts of a failed upload so that they aren't billed. It's best-effort since the
+// upload already failed, and isn't canceled with ctx so that it's attempted after an interruption too.
+func (u *Uploader) abortMultipartUpload(ctx context.Context, bucket, key, id string) {
+	_, err := u.Client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(id),
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("upload_id", id).Warn("Failed to abort multipart upload")
+	}
+}
@@ -0,0 +1,55 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploader_UploadStream(t *testing.T) {
+	for _, size := range []int{0, 1024, MinPartSize, 2*MinPartSize + 100} {
+		_, data := writeTestFile(t, size)
+		api := newFakeS3()
+		u := &Uploader{Client: api, Options: Options{PartSize: MinPartSize}}
+
+		assert.NoError(t, u.UploadStream(context.Background(), bytes.NewReader(data), "bucket", "key"), "size %d", size)
+		assert.True(t, bytes.Equal(data, api.objects["key"]), "size %d", size)
+		switch {
+		case size > MinPartSize:
+			assert.Equal(t, []int32{1, 2, 3}, api.partCalls)
+			assert.Empty(t, api.puts)
+		case size < MinPartSize:
+			assert.Len(t, api.puts, 1, "streams that fit in a part should be put with a single request")
+		}
+	}
+}
+
+func TestUploader_UploadStreamRetry(t *testing.T) {
+	_, data := writeTestFile(t, 1024)
+	api := newFakeS3()
+	api.failPuts = 1
+	u := &Uploader{Client: api, Options: Options{PartSize: MinPartSize}}
+
+	assert.NoError(t, u.UploadStream(context.Background(), bytes.NewReader(data), "bucket", "key"))
+	assert.Equal(t, data, api.objects["key"], "the retried object should be complete")
+}
+
+func TestUploader_UploadStreamFailure(t *testing.T) {
+	_, data := writeTestFile(t, 2*MinPartSize)
+	api := newFakeS3()
+	u := &Uploader{Client: api, Options: Options{PartSize: MinPartSize}}
+
+	broken := io.MultiReader(bytes.NewReader(data), &failingReader{err: errors.New("disk on fire")})
+	err := u.UploadStream(context.Background(), broken, "bucket", "key")
+	assert.ErrorContains(t, err, "disk on fire")
+	assert.Equal(t, []string{"upload-1"}, api.aborted, "the parts of the failed stream should be discarded")
+	assert.NotContains(t, api.objects, "key")
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
@@ -0,0 +1,89 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// limiter paces reads to an average number of bytes per second. A nil limiter doesn't limit.
+type limiter struct {
+	rate int64
+
+	mu    sync.Mutex
+	start time.Time
+	total int64
+}
+
+// newLimiter creates a limiter for the rate, or nil if the rate is unlimited.
+func newLimiter(bytesPerSecond int64) *limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return &limiter{rate: bytesPerSecond}
+}
+
+// wait records that n bytes were read and sleeps until reading them is within the rate.
+func (l *limiter) wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.start.IsZero() {
+		l.start = time.Now()
+	}
+	l.total += int64(n)
+	due := l.start.Add(time.Duration(float64(l.total) / float64(l.rate) * float64(time.Second)))
+	l.mu.Unlock()
+
+	delay := time.Until(due)
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// throttledReader is a seekable reader whose reads are paced by a limiter. It remains seekable so that the SDK can
+// rewind the body when retrying a request.
+type throttledReader struct {
+	ctx     context.Context
+	r       io.ReadSeeker
+	limiter *limiter
+}
+
+// newThrottledReader wraps r so its reads are paced by the limiter.
+func newThrottledReader(ctx context.Context, r io.ReadSeeker, l *limiter) io.ReadSeeker {
+	if l == nil {
+		return r
+	}
+
+	return &throttledReader{ctx: ctx, r: r, limiter: l}
+}
+
+// Read reads from the underlying reader and then waits for the limiter.
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// Read in small chunks so the pace is smooth rather than bursting a whole buffer at a time.
+	if limit := max(t.limiter.rate/10, 4096); int64(len(p)) > limit {
+		p = p[:limit]
+	}
+
+	n, err := t.r.Read(p)
+	if werr := t.limiter.wait(t.ctx, n); werr != nil {
+		return n, werr
+	}
+
+	return n, err
+}
+
+// Seek seeks the underlying reader.
+func (t *throttledReader) Seek(offset int64, whence int) (int64, error) {
+	return t.r.Seek(offset, whence)
+}
@@ -0,0 +1,418 @@
+// Package upload provides the functionality necessary for uploading diagnostic artifacts to Amazon S3.
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/selfmetrics"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
+)
+
+const (
+	// DefaultPartSize is the size of each part of a multipart upload. Files smaller than the part size are uploaded
+	// with a single request.
+	DefaultPartSize = 16 * 1024 * 1024
+	// MinPartSize is the smallest part size accepted by S3.
+	MinPartSize = 5 * 1024 * 1024
+	// maxParts is the maximum number of parts in a multipart upload.
+	maxParts = 10_000
+
+	// DefaultAttempts is the number of times each request is attempted before the upload fails.
+	DefaultAttempts = 4
+
+	// runIDMetadata is the metadata key (x-amz-meta-run-id) of the run ID of the invocation that uploaded an object,
+	// so that it can be matched with the invocation's logs, manifests, and notifications.
+	runIDMetadata = "run-id"
+)
+
+// retryBaseDelay is the delay before the first retry, doubled for each subsequent one.
+var retryBaseDelay = time.Second
+
+// API is the subset of the S3 client used by the Uploader.
+type API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// Options customizes how objects are stored.
+type Options struct {
+	// StorageClass is the S3 storage class of the object (e.g. STANDARD_IA). Empty uses the bucket's default.
+	StorageClass types.StorageClass
+	// Tags are applied to the object.
+	Tags map[string]string
+	// Metadata is stored as the object's user-defined metadata (x-amz-meta-*), e.g. why the object was created,
+	// along with the run ID provided in the context as run-id. Characters that aren't printable ASCII are replaced,
+	// since S3 doesn't accept them in metadata.
+	Metadata map[string]string
+	// KMSKeyID enables SSE-KMS encryption with the key. "aws/s3" selects the AWS managed key. Empty uses the
+	// bucket's default encryption.
+	KMSKeyID string
+	// PartSize is the size of each part of a multipart upload. It's raised when needed to stay within S3's part
+	// limits. Zero uses DefaultPartSize.
+	PartSize int64
+	// BytesPerSecond limits the upload bandwidth. Zero is unlimited.
+	BytesPerSecond int64
+	// Attempts is the number of times each request is attempted. Zero uses DefaultAttempts.
+	Attempts int
+	// StateDir is where the progress of multipart uploads is saved so that an interrupted upload resumes with the
+	// parts that weren't uploaded yet. Empty disables resumption.
+	StateDir string
+}
+
+// Uploader uploads files to S3.
+type Uploader struct {
+	// Client is the S3 API client.
+	Client API
+	// Options customizes how objects are stored.
+	Options Options
+}
+
+// Destination is an S3 bucket and key prefix.
+type Destination struct {
+	Bucket string
+	Prefix string
+}
+
+// ParseDestination parses an s3://bucket/prefix URL.
+func ParseDestination(s string) (Destination, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return Destination{}, fmt.Errorf("invalid S3 URL %q, expected s3://bucket/prefix", s)
+	}
+
+	return Destination{Bucket: u.Host, Prefix: strings.TrimPrefix(u.Path, "/")}, nil
+}
+
+// Key returns the object key for a file name under the destination's prefix.
+func (d Destination) Key(name string) string {
+	return path.Join(d.Prefix, name)
+}
+
+// URI returns the s3:// URI of the object for a file name under the destination's prefix.
+func (d Destination) URI(name string) string {
+	return "s3://" + path.Join(d.Bucket, d.Key(name))
+}
+
+// String returns the destination as an s3:// URL.
+func (d Destination) String() string {
+	return "s3://" + path.Join(d.Bucket, d.Prefix)
+}
+
+// UploadFile uploads the file at filePath to the bucket and key.
+func (u *Uploader) UploadFile(ctx context.Context, filePath, bucket, key string) (err error) {
+	ctx, span := tracing.Start(ctx, "s3 upload", map[string]string{"aws.s3.bucket": bucket, "aws.s3.key": key})
+	defer func() { span.Finish(err) }()
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", filePath, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", filePath, err)
+	}
+	span.SetAttribute("file.size", strconv.FormatInt(fi.Size(), 10))
+
+	limiter := newLimiter(u.Options.BytesPerSecond)
+	partSize := u.partSize(fi.Size())
+
+	fields := logrus.Fields{
+		"file":   filePath,
+		"bucket": bucket,
+		"key":    key,
+		"bytes":  fi.Size(),
+	}
+	logrus.WithFields(fields).Info("Uploading file to S3")
+
+	if fi.Size() <= partSize {
+		err = u.putObject(ctx, f, fi.Size(), bucket, key, limiter)
+		if err == nil {
+			selfmetrics.UploadBytes.Add("", float64(fi.Size()))
+			reportUploaded(ctx, fi.Size(), fi.Size())
+		}
+	} else {
+		err = u.uploadMultipart(ctx, f, fi, bucket, key, partSize, limiter)
+	}
+	if err != nil {
+		return err
+	}
+	logrus.WithFields(fields).Info("Uploaded file to S3")
+
+	return nil
+}
+
+// putObject uploads the first size bytes of body with a single request. Each attempt reads the body from its start,
+// since a failed attempt may have read part of it.
+func (u *Uploader) putObject(ctx context.Context, body io.ReaderAt, size int64, bucket, key string, limiter *limiter) error {
+	return u.retry(ctx, "put object", func() error {
+		_, err := u.Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:               aws.String(bucket),
+			Key:                  aws.String(key),
+			Body:                 newThrottledReader(ctx, io.NewSectionReader(body, 0, size), limiter),
+			ContentLength:        aws.Int64(size),
+			StorageClass:         u.Options.StorageClass,
+			Tagging:              u.tagging(),
+			Metadata:             u.metadata(ctx),
+			ServerSideEncryption: u.sse(),
+			SSEKMSKeyId:          u.kmsKeyID(),
+		})
+		return err
+	})
+}
+
+// reportUploaded reports the progress of the upload after a part, or the whole file, was uploaded.
+func reportUploaded(ctx context.Context, uploaded, total int64) {
+	percent := 100.0
+	if total > 0 {
+		percent = float64(uploaded) / float64(total) * 100
+	}
+	contextual.Progress(ctx).Report("upload", percent, fmt.Sprintf("%s of %s", units.HumanSize(float64(uploaded)), units.HumanSize(float64(total))))
+}
+
+// uploadMultipart uploads the file in parts, resuming a previously interrupted upload of the same file if its state
+// was saved.
+func (u *Uploader) uploadMultipart(ctx context.Context, f *os.File, fi os.FileInfo, bucket, key string, partSize int64, limiter *limiter) error {
+	st := u.loadState(f.Name(), fi, bucket, key, partSize)
+	if st.UploadID != "" {
+		if err := u.syncParts(ctx, st, bucket, key); err != nil {
+			logrus.WithError(err).Warn("Cannot resume previous upload, starting over")
+			st.UploadID, st.Parts = "", nil
+		} else {
+			logrus.WithField("parts", len(st.Parts)).Info("Resuming previous upload")
+		}
+	}
+
+	if st.UploadID == "" {
+		id, err := u.createMultipartUpload(ctx, bucket, key)
+		if err != nil {
+			return err
+		}
+		st.UploadID = id
+		st.Parts = map[int32]string{}
+		u.saveState(st)
+	}
+
+	parts := int32((fi.Size() + partSize - 1) / partSize)
+	uploaded := int64(len(st.Parts)) * partSize
+	for number := int32(1); number <= parts; number++ {
+		if _, done := st.Parts[number]; done {
+			continue
+		}
+
+		offset := int64(number-1) * partSize
+		size := min(partSize, fi.Size()-offset)
+		var out *s3.UploadPartOutput
+		err := u.retry(ctx, fmt.Sprintf("upload part %d", number), func() error {
+			var err error
+			out, err = u.Client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:        aws.String(bucket),
+				Key:           aws.String(key),
+				UploadId:      aws.String(st.UploadID),
+				PartNumber:    aws.Int32(number),
+				Body:          newThrottledReader(ctx, io.NewSectionReader(f, offset, size), limiter),
+				ContentLength: aws.Int64(size),
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		selfmetrics.UploadBytes.Add("", float64(size))
+		uploaded += size
+		reportUploaded(ctx, min(uploaded, fi.Size()), fi.Size())
+		st.Parts[number] = aws.ToString(out.ETag)
+		u.saveState(st)
+		logrus.WithFields(logrus.Fields{"part": number, "parts": parts}).Debug("Uploaded part")
+	}
+
+	completed := make([]types.CompletedPart, 0, len(st.Parts))
+	for number, etag := range st.Parts {
+		completed = append(completed, types.CompletedPart{PartNumber: aws.Int32(number), ETag: aws.String(etag)})
+	}
+	sort.Slice(completed, func(i, j int) bool {
+		return *completed[i].PartNumber < *completed[j].PartNumber
+	})
+
+	err := u.retry(ctx, "complete multipart upload", func() error {
+		_, err := u.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(bucket),
+			Key:             aws.String(key),
+			UploadId:        aws.String(st.UploadID),
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	u.removeState(st)
+
+	return nil
+}
+
+// createMultipartUpload starts a multipart upload to the bucket and key and returns its ID.
+func (u *Uploader) createMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	var out *s3.CreateMultipartUploadOutput
+	err := u.retry(ctx, "create multipart upload", func() error {
+		var err error
+		out, err = u.Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket:               aws.String(bucket),
+			Key:                  aws.String(key),
+			StorageClass:         u.Options.StorageClass,
+			Tagging:              u.tagging(),
+			Metadata:             u.metadata(ctx),
+			ServerSideEncryption: u.sse(),
+			SSEKMSKeyId:          u.kmsKeyID(),
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(out.UploadId), nil
+}
+
+// syncParts replaces the saved parts with the parts S3 has for the upload, which is the source of truth.
+func (u *Uploader) syncParts(ctx context.Context, st *state, bucket, key string) error {
+	parts := map[int32]string{}
+	paginator := s3.NewListPartsPaginator(u.Client, &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(st.UploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("list parts: %w", err)
+		}
+		for _, p := range page.Parts {
+			parts[aws.ToInt32(p.PartNumber)] = aws.ToString(p.ETag)
+		}
+	}
+	st.Parts = parts
+
+	return nil
+}
+
+// partSize returns the part size for a file of the given size.
+func (u *Uploader) partSize(size int64) int64 {
+	partSize := u.Options.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	partSize = max(partSize, MinPartSize)
+	for size/partSize >= maxParts {
+		partSize *= 2
+	}
+
+	return partSize
+}
+
+// retry calls fn until it succeeds, the attempts are exhausted, or ctx is done, backing off exponentially.
+func (u *Uploader) retry(ctx context.Context, op string, fn func() error) error {
+	attempts := u.Options.Attempts
+	if attempts <= 0 {
+		attempts = DefaultAttempts
+	}
+
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts || ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"operation": op,
+			"attempt":   attempt,
+		}).Warn("S3 request failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", op, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// tagging returns the object tags encoded for the x-amz-tagging header.
+func (u *Uploader) tagging() *string {
+	if len(u.Options.Tags) == 0 {
+		return nil
+	}
+	values := url.Values{}
+	for k, v := range u.Options.Tags {
+		values.Set(k, v)
+	}
+
+	return aws.String(values.Encode())
+}
+
+// metadata returns the object's user-defined metadata, with the run ID provided in ctx unless the metadata has its
+// own, and with the characters S3 doesn't accept replaced.
+func (u *Uploader) metadata(ctx context.Context) map[string]string {
+	runID := contextual.RunID(ctx)
+	if len(u.Options.Metadata) == 0 && runID == "" {
+		return nil
+	}
+	printable := func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return '?'
+		}
+		return r
+	}
+	metadata := make(map[string]string, len(u.Options.Metadata)+1)
+	if runID != "" {
+		metadata[runIDMetadata] = strings.Map(printable, runID)
+	}
+	for k, v := range u.Options.Metadata {
+		metadata[strings.Map(printable, k)] = strings.Map(printable, v)
+	}
+
+	return metadata
+}
+
+// sse returns the server-side encryption setting of the object.
+func (u *Uploader) sse() types.ServerSideEncryption {
+	if u.Options.KMSKeyID == "" {
+		return ""
+	}
+
+	return types.ServerSideEncryptionAwsKms
+}
+
+// kmsKeyID returns the customer managed KMS key of the object, if any.
+func (u *Uploader) kmsKeyID() *string {
+	if u.Options.KMSKeyID == "" || u.Options.KMSKeyID == "aws/s3" {
+		return nil
+	}
+
+	return aws.String(u.Options.KMSKeyID)
+}
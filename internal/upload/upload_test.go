@@ -0,0 +1,314 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+)
+
+func init() {
+	retryBaseDelay = time.Millisecond
+}
+
+// fakeS3 stores objects and multipart uploads in memory.
+type fakeS3 struct {
+	mu sync.Mutex
+
+	objects map[string][]byte
+	puts    []*s3.PutObjectInput
+	creates []*s3.CreateMultipartUploadInput
+	uploads map[string]map[int32][]byte
+	// failParts fails uploads of the part numbers once each.
+	failParts map[int32]int
+	// failPuts fails that many PutObject calls after reading half of their body.
+	failPuts  int
+	partCalls []int32
+	aborted   []string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int32][]byte{}, failParts: map[int32]int{}}
+}
+
+func (f *fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.mu.Lock()
+	fail := f.failPuts > 0
+	if fail {
+		f.failPuts--
+	}
+	f.mu.Unlock()
+	if fail {
+		_, _ = io.CopyN(io.Discard, in.Body, aws.ToInt64(in.ContentLength)/2)
+		return nil, errors.New("connection reset")
+	}
+
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != aws.ToInt64(in.ContentLength) {
+		return nil, fmt.Errorf("read %d bytes of a %d byte body", len(data), aws.ToInt64(in.ContentLength))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.puts = append(f.puts, in)
+	f.objects[aws.ToString(in.Key)] = data
+
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) CreateMultipartUpload(_ context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.creates = append(f.creates, in)
+	id := fmt.Sprintf("upload-%d", len(f.creates))
+	f.uploads[id] = map[int32][]byte{}
+
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (f *fakeS3) UploadPart(_ context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	number := aws.ToInt32(in.PartNumber)
+
+	f.mu.Lock()
+	f.partCalls = append(f.partCalls, number)
+	if f.failParts[number] > 0 {
+		f.failParts[number]--
+		f.mu.Unlock()
+		return nil, errors.New("connection reset")
+	}
+	f.mu.Unlock()
+
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.uploads[aws.ToString(in.UploadId)][number] = data
+
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", number))}, nil
+}
+
+func (f *fakeS3) ListParts(_ context.Context, in *s3.ListPartsInput, _ ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts, ok := f.uploads[aws.ToString(in.UploadId)]
+	if !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+
+	out := &s3.ListPartsOutput{}
+	for number := range parts {
+		out.Parts = append(out.Parts, types.Part{PartNumber: aws.Int32(number), ETag: aws.String(fmt.Sprintf("etag-%d", number))})
+	}
+
+	return out, nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(_ context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := f.uploads[aws.ToString(in.UploadId)]
+
+	var data []byte
+	for i, p := range in.MultipartUpload.Parts {
+		if aws.ToInt32(p.PartNumber) != int32(i+1) {
+			return nil, errors.New("parts out of order")
+		}
+		data = append(data, parts[aws.ToInt32(p.PartNumber)]...)
+	}
+	f.objects[aws.ToString(in.Key)] = data
+	delete(f.uploads, aws.ToString(in.UploadId))
+
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(_ context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aborted = append(f.aborted, aws.ToString(in.UploadId))
+	delete(f.uploads, aws.ToString(in.UploadId))
+
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func writeTestFile(t *testing.T, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	path := filepath.Join(t.TempDir(), "archive.tar.gz")
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+
+	return path, data
+}
+
+func TestUploader_SmallFile(t *testing.T) {
+	path, data := writeTestFile(t, 1024)
+	api := newFakeS3()
+	u := &Uploader{Client: api, Options: Options{
+		StorageClass: types.StorageClassStandardIa,
+		Tags:         map[string]string{"Purpose": "debug", "RunId": "a b"},
+		Metadata:     map[string]string{"reason": "IMDS check failed – timeout"},
+		KMSKeyID:     "alias/diagnostics",
+	}}
+
+	assert.NoError(t, u.UploadFile(context.Background(), path, "bucket", "prefix/archive.tar.gz"))
+	assert.Equal(t, data, api.objects["prefix/archive.tar.gz"])
+	assert.Len(t, api.puts, 1)
+
+	put := api.puts[0]
+	assert.Equal(t, types.StorageClassStandardIa, put.StorageClass)
+	assert.Equal(t, types.ServerSideEncryptionAwsKms, put.ServerSideEncryption)
+	assert.Equal(t, "alias/diagnostics", aws.ToString(put.SSEKMSKeyId))
+	tags, err := url.ParseQuery(aws.ToString(put.Tagging))
+	assert.NoError(t, err)
+	assert.Equal(t, "a b", tags.Get("RunId"))
+	assert.Equal(t, "debug", tags.Get("Purpose"))
+	assert.Equal(t, map[string]string{"reason": "IMDS check failed ? timeout"}, put.Metadata, "metadata should be printable ASCII")
+}
+
+func TestUploader_SmallFileRetry(t *testing.T) {
+	path, data := writeTestFile(t, 1024)
+	api := newFakeS3()
+	api.failPuts = 1
+	u := &Uploader{Client: api}
+
+	assert.NoError(t, u.UploadFile(context.Background(), path, "bucket", "key"))
+	assert.Equal(t, data, api.objects["key"], "the retried object should be complete")
+}
+
+func TestUploader_AWSManagedKey(t *testing.T) {
+	path, _ := writeTestFile(t, 10)
+	api := newFakeS3()
+	u := &Uploader{Client: api, Options: Options{KMSKeyID: "aws/s3"}}
+
+	assert.NoError(t, u.UploadFile(context.Background(), path, "bucket", "key"))
+	assert.Equal(t, types.ServerSideEncryptionAwsKms, api.puts[0].ServerSideEncryption)
+	assert.Nil(t, api.puts[0].SSEKMSKeyId)
+}
+
+func TestUploader_Multipart(t *testing.T) {
+	path, data := writeTestFile(t, 2*MinPartSize+100)
+	api := newFakeS3()
+	api.failParts[2] = 1
+	u := &Uploader{Client: api, Options: Options{
+		PartSize:     MinPartSize,
+		StorageClass: types.StorageClassGlacierIr,
+		Metadata:     map[string]string{"reason": "scheduled event"},
+	}}
+
+	assert.NoError(t, u.UploadFile(context.Background(), path, "bucket", "key"))
+	assert.True(t, bytes.Equal(data, api.objects["key"]))
+	assert.Equal(t, []int32{1, 2, 2, 3}, api.partCalls, "the failed part should be retried")
+	assert.Len(t, api.creates, 1)
+	assert.Equal(t, types.StorageClassGlacierIr, api.creates[0].StorageClass)
+	assert.Equal(t, map[string]string{"reason": "scheduled event"}, api.creates[0].Metadata)
+	assert.Empty(t, api.puts)
+}
+
+func TestUploader_RunID(t *testing.T) {
+	ctx := contextual.WithRunID(context.Background(), "run-1")
+
+	path, _ := writeTestFile(t, 10)
+	api := newFakeS3()
+	u := &Uploader{Client: api, Options: Options{Metadata: map[string]string{"reason": "scheduled event"}}}
+	assert.NoError(t, u.UploadFile(ctx, path, "bucket", "key"))
+	assert.Equal(t, map[string]string{"run-id": "run-1", "reason": "scheduled event"}, api.puts[0].Metadata)
+
+	path, _ = writeTestFile(t, MinPartSize+1)
+	u = &Uploader{Client: api, Options: Options{PartSize: MinPartSize}}
+	assert.NoError(t, u.UploadFile(ctx, path, "bucket", "key"))
+	assert.Equal(t, map[string]string{"run-id": "run-1"}, api.creates[0].Metadata)
+}
+
+func TestUploader_Resume(t *testing.T) {
+	path, data := writeTestFile(t, 2*MinPartSize+100)
+	api := newFakeS3()
+	api.failParts[3] = 1
+	u := &Uploader{Client: api, Options: Options{PartSize: MinPartSize, Attempts: 1, StateDir: t.TempDir()}}
+
+	assert.Error(t, u.UploadFile(context.Background(), path, "bucket", "key"))
+	assert.Equal(t, []int32{1, 2, 3}, api.partCalls)
+
+	api.partCalls = nil
+	assert.NoError(t, u.UploadFile(context.Background(), path, "bucket", "key"))
+	assert.Equal(t, []int32{3}, api.partCalls, "only the missing part should be uploaded")
+	assert.Len(t, api.creates, 1, "the upload should be resumed")
+	assert.True(t, bytes.Equal(data, api.objects["key"]))
+
+	states, err := os.ReadDir(u.Options.StateDir)
+	assert.NoError(t, err)
+	assert.Empty(t, states, "the state of a completed upload should be removed")
+}
+
+func TestUploader_ResumeExpired(t *testing.T) {
+	path, data := writeTestFile(t, 2*MinPartSize+100)
+	api := newFakeS3()
+	api.failParts[3] = 1
+	u := &Uploader{Client: api, Options: Options{PartSize: MinPartSize, Attempts: 1, StateDir: t.TempDir()}}
+
+	assert.Error(t, u.UploadFile(context.Background(), path, "bucket", "key"))
+	// The upload was aborted (e.g. by a bucket lifecycle rule) so it must start over.
+	api.uploads = map[string]map[int32][]byte{}
+
+	assert.NoError(t, u.UploadFile(context.Background(), path, "bucket", "key"))
+	assert.Len(t, api.creates, 2)
+	assert.True(t, bytes.Equal(data, api.objects["key"]))
+}
+
+func TestUploader_PartSize(t *testing.T) {
+	u := &Uploader{}
+	assert.Equal(t, int64(DefaultPartSize), u.partSize(1))
+	assert.Equal(t, int64(2*DefaultPartSize), u.partSize(maxParts*DefaultPartSize))
+
+	u.Options.PartSize = 1
+	assert.Equal(t, int64(MinPartSize), u.partSize(1))
+}
+
+func TestThrottledReader(t *testing.T) {
+	const rate = 64 * 1024
+	r := newThrottledReader(context.Background(), bytes.NewReader(make([]byte, rate/2)), newLimiter(rate))
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(rate/2), n)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestParseDestination(t *testing.T) {
+	d, err := ParseDestination("s3://bucket/some/prefix/")
+	assert.NoError(t, err)
+	assert.Equal(t, Destination{Bucket: "bucket", Prefix: "some/prefix/"}, d)
+	assert.Equal(t, "some/prefix/archive.tar.gz", d.Key("archive.tar.gz"))
+	assert.Equal(t, "s3://bucket/some/prefix/archive.tar.gz", d.URI("archive.tar.gz"))
+
+	d, err = ParseDestination("s3://bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, "archive.tar.gz", d.Key("archive.tar.gz"))
+
+	for _, s := range []string{"bucket/prefix", "https://bucket/prefix", "s3:///prefix"} {
+		_, err := ParseDestination(s)
+		assert.Error(t, err, s)
+	}
+}
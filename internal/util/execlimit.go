@@ -0,0 +1,324 @@
+package util
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/aws/ec2-macos-utils/internal/selfmetrics"
+)
+
+const (
+	// execRateWindow is the period over which the starts of external commands are limited.
+	execRateWindow = time.Minute
+	// execSlotPollInterval is how often a command waiting for a slot held by another process checks for a free one.
+	execSlotPollInterval = 100 * time.Millisecond
+	// execStartsName is the name of the file in the limiter's directory holding the recent starts of commands.
+	execStartsName = "starts"
+)
+
+// execLimiter is the limiter of the external commands executed, see SetExecLimiter.
+var execLimiter atomic.Pointer[ExecLimiter]
+
+// SetExecLimiter sets the limiter that external commands wait for before they start. Nil removes the limits.
+func SetExecLimiter(l *ExecLimiter) {
+	execLimiter.Store(l)
+}
+
+// AcquireExec waits until the command with argv may start under the limits set with SetExecLimiter and returns the
+// function that releases its slot once it has exited. Commands that are run without ExecuteCommand, e.g. to stream
+// their output, acquire a slot with it. It fails only when ctx is done while waiting.
+func AcquireExec(ctx context.Context, argv []string) (release func(), err error) {
+	l := execLimiter.Load()
+	if l == nil {
+		return func() {}, nil
+	}
+	start := time.Now()
+	release, err = l.Acquire(ctx)
+	if len(argv) > 0 {
+		selfmetrics.CommandWait.Observe(filepath.Base(argv[0]), time.Since(start))
+	}
+
+	return release, err
+}
+
+// ExecLimiter bounds how many external commands run at the same time and how many start within a minute, so that
+// bursts of diagnostics, e.g. from several watchdogs at once, don't overload a busy host. The limits are shared with
+// every process of the utility using the same directory, through files locked with flock(2) whose locks are released
+// even when a process dies. The directory and its files are only accessible by root, so that other users can't hold
+// the slots to stall the utility; processes that don't run as root, or find the directory unusable, limit only the
+// commands of this process. Limiters are created with NewExecLimiter.
+type ExecLimiter struct {
+	// Concurrency is the most commands that may run at the same time. Zero is unbounded.
+	Concurrency int
+	// PerMinute is the most commands that may start within a minute. Zero is unbounded.
+	PerMinute int
+	// Dir holds the files shared by the processes. Empty limits only the commands of this process.
+	Dir string
+
+	now func() time.Time
+	// owner is the user the processes sharing the limits run as, root except in tests.
+	owner int
+
+	// slots are the slots of the commands of this process, used when Dir can't be.
+	slots  chan struct{}
+	mu     sync.Mutex
+	starts []time.Time
+	warned sync.Once
+}
+
+// NewExecLimiter creates a limiter of external commands to concurrency at the same time and perMinute starts within a
+// minute, shared by the processes using dir.
+func NewExecLimiter(concurrency, perMinute int, dir string) *ExecLimiter {
+	l := &ExecLimiter{
+		Concurrency: concurrency,
+		PerMinute:   perMinute,
+		Dir:         dir,
+		now:         time.Now,
+		owner:       0,
+	}
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+
+	return l
+}
+
+// Acquire waits until a command may start, first for the rate and then for a slot, and returns the function that
+// releases the slot. It fails only when ctx is done while waiting.
+func (l *ExecLimiter) Acquire(ctx context.Context) (release func(), err error) {
+	if err := l.waitRate(ctx); err != nil {
+		return nil, err
+	}
+
+	return l.acquireSlot(ctx)
+}
+
+// waitRate waits until fewer than PerMinute commands started within the last minute, and records the start.
+func (l *ExecLimiter) waitRate(ctx context.Context) error {
+	if l.PerMinute <= 0 {
+		return nil
+	}
+	for {
+		wait, err := l.reserveSharedStart()
+		if err != nil {
+			l.fallback(err)
+			wait = l.reserveLocalStart()
+		}
+		if wait <= 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// reserveLocalStart records a start in the starts of this process if the rate allows it, and returns how long to
+// wait before trying again otherwise.
+func (l *ExecLimiter) reserveLocalStart() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	l.starts, wait = reserveStart(l.starts, l.now(), l.PerMinute)
+
+	return wait
+}
+
+// reserveSharedStart records a start in the starts file shared by the processes if the rate allows it, and returns
+// how long to wait before trying again otherwise.
+func (l *ExecLimiter) reserveSharedStart() (time.Duration, error) {
+	if err := l.sharedDir(); err != nil {
+		return 0, err
+	}
+	f, err := l.openShared(execStartsName)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		return 0, fmt.Errorf("lock %s: %w", f.Name(), err)
+	}
+
+	starts, err := readStarts(f)
+	if err != nil {
+		return 0, err
+	}
+	kept, wait := reserveStart(starts, l.now(), l.PerMinute)
+	if err := writeStarts(f, kept); err != nil {
+		return 0, err
+	}
+
+	return wait, nil
+}
+
+// reserveStart drops the starts older than the rate window and adds a start at now if fewer than perMinute remain.
+// Otherwise, it returns how long until the oldest start leaves the window.
+func reserveStart(starts []time.Time, now time.Time, perMinute int) ([]time.Time, time.Duration) {
+	kept := starts[:0]
+	for _, start := range starts {
+		if now.Sub(start) < execRateWindow && !start.After(now) {
+			kept = append(kept, start)
+		}
+	}
+	if len(kept) < perMinute {
+		return append(kept, now), 0
+	}
+
+	return kept, kept[len(kept)-perMinute].Add(execRateWindow).Sub(now)
+}
+
+// readStarts reads the starts from the file, one Unix time in nanoseconds per line. Lines that can't be parsed are
+// dropped.
+func readStarts(r io.Reader) ([]time.Time, error) {
+	var starts []time.Time
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		ns, err := strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 64)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, time.Unix(0, ns))
+	}
+
+	return starts, scanner.Err()
+}
+
+// writeStarts replaces the contents of the file with the starts.
+func writeStarts(f *os.File, starts []time.Time) error {
+	var b strings.Builder
+	for _, start := range starts {
+		fmt.Fprintln(&b, start.UnixNano())
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(b.String()), 0)
+
+	return err
+}
+
+// acquireSlot waits for one of the Concurrency slots and returns the function that releases it.
+func (l *ExecLimiter) acquireSlot(ctx context.Context) (func(), error) {
+	if l.Concurrency <= 0 {
+		return func() {}, nil
+	}
+	for {
+		release, err := l.trySharedSlot()
+		if err != nil {
+			l.fallback(err)
+			return l.acquireLocalSlot(ctx)
+		}
+		if release != nil {
+			return release, nil
+		}
+		if err := sleepContext(ctx, execSlotPollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// acquireLocalSlot waits for one of the slots of this process and returns the function that releases it.
+func (l *ExecLimiter) acquireLocalSlot(ctx context.Context) (func(), error) {
+	select {
+	case l.slots <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-l.slots }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// trySharedSlot locks the first free slot file, which is held until the returned function closes it. It returns a
+// nil function when every slot is held.
+func (l *ExecLimiter) trySharedSlot() (func(), error) {
+	if err := l.sharedDir(); err != nil {
+		return nil, err
+	}
+	for i := range l.Concurrency {
+		f, err := l.openShared(fmt.Sprintf("slot-%d", i))
+		if err != nil {
+			return nil, err
+		}
+		err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			var once sync.Once
+			return func() { once.Do(func() { _ = f.Close() }) }, nil
+		}
+		_ = f.Close()
+		if !errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, fmt.Errorf("lock %s: %w", f.Name(), err)
+		}
+	}
+
+	return nil, nil
+}
+
+// sharedDir creates the directory shared by the processes if needed, and checks that only the owner, root, can access
+// it, so that the slots can't be held by other users.
+func (l *ExecLimiter) sharedDir() error {
+	if l.Dir == "" {
+		return errors.New("no directory to share limits in")
+	}
+	if uid := os.Geteuid(); uid != l.owner {
+		return fmt.Errorf("limits are only shared by processes running as uid %d, not %d", l.owner, uid)
+	}
+	if err := os.MkdirAll(l.Dir, 0700); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(l.Dir)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || !ok || int(st.Uid) != l.owner {
+		return fmt.Errorf("%s isn't a directory owned by uid %d", l.Dir, l.owner)
+	}
+	// Versions that let other users hold slots created the directory accessible to them.
+	if fi.Mode().Perm() != 0700 {
+		return os.Chmod(l.Dir, 0700)
+	}
+
+	return nil
+}
+
+// openShared opens the file with the name in the shared directory, creating it only accessible by its owner if
+// needed.
+func (l *ExecLimiter) openShared(name string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(l.Dir, name), os.O_RDWR|os.O_CREATE|syscall.O_NOFOLLOW, 0600)
+}
+
+// fallback logs, once, that the limits only apply to this process because the directory can't be used.
+func (l *ExecLimiter) fallback(err error) {
+	if l.Dir == "" {
+		return
+	}
+	l.warned.Do(func() {
+		logrus.WithError(err).WithField("dir", l.Dir).Debug("Limiting external commands of this process only")
+	})
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
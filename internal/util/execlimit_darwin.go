@@ -0,0 +1,7 @@
+//go:build darwin
+
+package util
+
+// DefaultExecLimitDir is where the processes of the utility share the limits of external commands. It's cleared at
+// boot, along with the slots of processes that were running.
+const DefaultExecLimitDir = "/private/var/run/ec2-macos-utils/exec"
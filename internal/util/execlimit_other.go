@@ -0,0 +1,7 @@
+//go:build !darwin
+
+package util
+
+// DefaultExecLimitDir is empty on platforms other than macOS, where the limits of external commands only apply to the
+// commands of each process.
+const DefaultExecLimitDir = ""
@@ -0,0 +1,139 @@
+package util
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveStart(t *testing.T) {
+	now := time.Unix(1000, 0)
+	starts := []time.Time{now.Add(-2 * time.Minute), now.Add(-50 * time.Second), now.Add(-10 * time.Second)}
+
+	kept, wait := reserveStart(starts, now, 3)
+	assert.Zero(t, wait)
+	assert.Equal(t, []time.Time{now.Add(-50 * time.Second), now.Add(-10 * time.Second), now}, kept, "old starts should be dropped")
+
+	kept, wait = reserveStart(kept, now, 3)
+	assert.Equal(t, 10*time.Second, wait, "should wait for the oldest start to leave the window")
+	assert.Len(t, kept, 3)
+
+	_, wait = reserveStart(kept, now, 1)
+	assert.Equal(t, time.Minute, wait, "a lowered limit should wait for the latest starts")
+}
+
+// newTestExecLimiter creates a limiter sharing its limits with the processes of the user running the tests.
+func newTestExecLimiter(concurrency, perMinute int, dir string) *ExecLimiter {
+	l := NewExecLimiter(concurrency, perMinute, dir)
+	l.owner = os.Geteuid()
+
+	return l
+}
+
+func TestExecLimiter_SharedSlots(t *testing.T) {
+	dir := t.TempDir()
+	// Limiters with the same directory stand in for separate processes.
+	first := newTestExecLimiter(1, 0, dir)
+	second := newTestExecLimiter(1, 0, dir)
+
+	release, err := first.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*execSlotPollInterval)
+	defer cancel()
+	_, err = second.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the slot should be held by the other limiter")
+
+	release()
+	release()
+	releaseSecond, err := second.Acquire(context.Background())
+	require.NoError(t, err)
+	releaseSecond()
+}
+
+func TestExecLimiter_SharedRate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Unix(1000, 0)
+	first := newTestExecLimiter(0, 2, dir)
+	first.now = func() time.Time { return now }
+	second := newTestExecLimiter(0, 2, dir)
+	second.now = first.now
+
+	for _, l := range []*ExecLimiter{first, second} {
+		release, err := l.Acquire(context.Background())
+		require.NoError(t, err)
+		release()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := first.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the starts of both limiters should count")
+
+	data, err := os.ReadFile(filepath.Join(dir, execStartsName))
+	require.NoError(t, err)
+	assert.Equal(t, "1000000000000\n1000000000000\n", string(data))
+	fi, err := os.Stat(filepath.Join(dir, execStartsName))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+}
+
+func TestExecLimiter_SharedDir(t *testing.T) {
+	// A directory accessible by others is restricted to its owner.
+	dir := filepath.Join(t.TempDir(), "exec")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	require.NoError(t, newTestExecLimiter(1, 0, dir).sharedDir())
+	fi, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+
+	// A symlink isn't used, nor is the directory by processes running as another user.
+	link := filepath.Join(t.TempDir(), "link")
+	require.NoError(t, os.Symlink(dir, link))
+	assert.Error(t, newTestExecLimiter(1, 0, link).sharedDir())
+	l := newTestExecLimiter(1, 0, dir)
+	l.owner++
+	assert.Error(t, l.sharedDir())
+}
+
+func TestExecLimiter_Local(t *testing.T) {
+	// A file where the directory should be can't be used, so the limits only apply to the limiter.
+	notDir := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(notDir, nil, 0600))
+	l := newTestExecLimiter(1, 0, notDir)
+
+	release, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release, err = l.Acquire(context.Background())
+	assert.NoError(t, err)
+	release()
+}
+
+func TestAcquireExec(t *testing.T) {
+	SetExecLimiter(NewExecLimiter(1, 0, ""))
+	t.Cleanup(func() { SetExecLimiter(nil) })
+
+	release, err := AcquireExec(context.Background(), []string{"diskutil", "list"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = ExecuteCommand(ctx, []string{"true"}, "", nil, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "commands should wait for the limits")
+
+	release()
+	_, err = ExecuteCommand(context.Background(), []string{"true"}, "", nil, nil)
+	assert.NoError(t, err)
+}
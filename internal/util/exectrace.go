@@ -0,0 +1,72 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxTracedOutput is the number of bytes of each of a command's stdout and stderr logged by exec tracing.
+const maxTracedOutput = 4096
+
+// execTracing is whether external commands are logged, see SetExecTracing.
+var execTracing atomic.Bool
+
+// SetExecTracing enables or disables logging every external command executed, with its arguments, duration, exit
+// code and output. This helps tell apart how commands such as diskutil behave across macOS versions, but the logged
+// arguments and output may contain secrets.
+func SetExecTracing(enabled bool) {
+	execTracing.Store(enabled)
+}
+
+// LogExec logs the external command that ran for the duration and exited with err when exec tracing is enabled. The
+// output is truncated, and output that wasn't captured, e.g. because it was streamed, is passed as "".
+func LogExec(argv []string, duration time.Duration, err error, stdout, stderr string) {
+	if !execTracing.Load() {
+		return
+	}
+
+	fields := logrus.Fields{
+		"argv":      argv,
+		"duration":  duration,
+		"exit_code": exitCode(err),
+	}
+	if stdout != "" {
+		fields["stdout"] = truncateOutput(stdout)
+	}
+	if stderr != "" {
+		fields["stderr"] = truncateOutput(stderr)
+	}
+	entry := logrus.WithFields(fields)
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	entry.Info("Executed external command")
+}
+
+// exitCode returns the exit code of the command that exited with err, or -1 if it didn't start or was killed by a
+// signal.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
+
+// truncateOutput truncates s to maxTracedOutput bytes, noting how many were left out.
+func truncateOutput(s string) string {
+	if len(s) <= maxTracedOutput {
+		return s
+	}
+
+	return fmt.Sprintf("%s... (%d more bytes)", s[:maxTracedOutput], len(s)-maxTracedOutput)
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, exitCode(nil))
+	assert.Equal(t, -1, exitCode(errors.New("executable file not found")))
+
+	_, err := ExecuteCommand(context.Background(), []string{"sh", "-c", "exit 3"}, "", nil, nil)
+	assert.Equal(t, 3, exitCode(err))
+}
+
+func TestTruncateOutput(t *testing.T) {
+	assert.Equal(t, "short", truncateOutput("short"))
+
+	long := strings.Repeat("a", maxTracedOutput+10)
+	assert.Equal(t, strings.Repeat("a", maxTracedOutput)+"... (10 more bytes)", truncateOutput(long))
+}
+
+func TestLogExec(t *testing.T) {
+	var buf bytes.Buffer
+	previous := logrus.StandardLogger().Out
+	logrus.SetOutput(&buf)
+	t.Cleanup(func() {
+		logrus.SetOutput(previous)
+		SetExecTracing(false)
+	})
+
+	_, _ = ExecuteCommand(context.Background(), []string{"sh", "-c", "echo out; echo err >&2; exit 2"}, "", nil, nil)
+	assert.Empty(t, buf.String(), "nothing should be logged when exec tracing is disabled")
+
+	SetExecTracing(true)
+	_, _ = ExecuteCommand(context.Background(), []string{"sh", "-c", "echo out; echo err >&2; exit 2"}, "", nil, nil)
+	logged := buf.String()
+	assert.Contains(t, logged, "Executed external command")
+	assert.Contains(t, logged, "exit_code=2")
+	assert.Contains(t, logged, `stdout="out\n"`)
+	assert.Contains(t, logged, `stderr="err\n"`)
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/redact"
+)
+
+// maxStderrTail is the number of bytes at the end of a failed tool's stderr kept by ExternalToolError, which is where
+// tools explain why they failed.
+const maxStderrTail = 2048
+
+// ExternalToolError is returned when an external tool, such as diskutil or sysdiagnose, fails. It carries what's
+// needed to act on the failure, which is logged by commands and included in their JSON output.
+type ExternalToolError struct {
+	// Tool is the file name of the tool, e.g. diskutil.
+	Tool string `json:"tool"`
+	// Args are the tool's arguments, with secrets redacted.
+	Args []string `json:"args,omitempty"`
+	// ExitCode is the tool's exit code, or -1 if it was killed by a signal.
+	ExitCode int `json:"exit_code"`
+	// Stderr is the end of what the tool wrote to stderr.
+	Stderr string `json:"stderr,omitempty"`
+	// Err is the error the tool failed with.
+	Err error `json:"-"`
+}
+
+// NewExternalToolError returns the error of the command c, which failed with err after writing stderr.
+func NewExternalToolError(c []string, err error, stderr string) *ExternalToolError {
+	e := &ExternalToolError{
+		ExitCode: exitCode(err),
+		Stderr:   stderrTail(stderr),
+		Err:      err,
+	}
+	if len(c) > 0 {
+		e.Tool = filepath.Base(c[0])
+		e.Args = redact.Strings(c[1:])
+	}
+
+	return e
+}
+
+// Error describes how the tool failed. The stderr isn't included since callers usually include it in their own
+// context, e.g. "unmount disk: <stderr>: <error>".
+func (e *ExternalToolError) Error() string {
+	if e.ExitCode < 0 {
+		return fmt.Sprintf("%s failed: %v", e.Tool, e.Err)
+	}
+
+	return fmt.Sprintf("%s exited with status %d", e.Tool, e.ExitCode)
+}
+
+// Unwrap returns the error the tool failed with, e.g. an *exec.ExitError.
+func (e *ExternalToolError) Unwrap() error {
+	return e.Err
+}
+
+// AsExternalToolError returns the ExternalToolError in err's chain, or nil if there's none.
+func AsExternalToolError(err error) *ExternalToolError {
+	var toolErr *ExternalToolError
+	if errors.As(err, &toolErr) {
+		return toolErr
+	}
+
+	return nil
+}
+
+// stderrTail returns the last maxStderrTail bytes of stderr, starting at a line when possible, without surrounding
+// whitespace.
+func stderrTail(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if len(stderr) <= maxStderrTail {
+		return stderr
+	}
+	tail := stderr[len(stderr)-maxStderrTail:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+
+	return "..." + tail
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommand_ExternalToolError(t *testing.T) {
+	_, err := ExecuteCommand(context.Background(), []string{"/bin/sh", "-c", "echo first >&2; echo disk busy >&2; exit 1"}, "", nil, nil)
+	wrapped := fmt.Errorf("unmount disk: %w", err)
+
+	toolErr := AsExternalToolError(wrapped)
+	if assert.NotNil(t, toolErr) {
+		assert.Equal(t, "sh", toolErr.Tool)
+		assert.Equal(t, []string{"-c", "echo first >&2; echo disk busy >&2; exit 1"}, toolErr.Args)
+		assert.Equal(t, 1, toolErr.ExitCode)
+		assert.Equal(t, "first\ndisk busy", toolErr.Stderr)
+	}
+	assert.Equal(t, "unmount disk: error waiting for specified command to exit: sh exited with status 1", wrapped.Error())
+
+	_, err = ExecuteCommand(context.Background(), []string{"true"}, "", nil, nil)
+	assert.Nil(t, AsExternalToolError(err))
+}
+
+func TestStderrTail(t *testing.T) {
+	assert.Equal(t, "disk busy", stderrTail("  disk busy\n"))
+
+	long := strings.Repeat("x", maxStderrTail) + "\nthe reason"
+	assert.Equal(t, "...the reason", stderrTail(long))
+}
@@ -8,8 +8,13 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"path/filepath"
 	"strconv"
 	"syscall"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/selfmetrics"
+	"github.com/aws/ec2-macos-utils/internal/tracing"
 )
 
 // CommandOutput wraps the output from an exec command as strings.
@@ -60,6 +65,27 @@ func ExecuteCommand(ctx context.Context, c []string, runAsUser string, envVars [
 	cmd.Env = os.Environ()
 	cmd.Env = append(cmd.Env, envVars...)
 
+	release, err := AcquireExec(ctx, c)
+	if err != nil {
+		return CommandOutput{}, fmt.Errorf("error waiting to start specified command: %w", err)
+	}
+	defer release()
+
+	// Count and trace the command's duration and failures, by its file name. Arguments aren't traced since they may
+	// contain secrets, they're only logged when exec tracing is enabled.
+	base := filepath.Base(name)
+	_, span := tracing.Start(ctx, "exec "+base, map[string]string{"process.executable.name": base})
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start)
+		selfmetrics.CommandDuration.Observe(base, duration)
+		if err != nil {
+			selfmetrics.CommandFailures.Inc(base)
+		}
+		span.Finish(err)
+		LogExec(c, duration, err, output.Stdout, output.Stderr)
+	}()
+
 	// Start the command's execution
 	if err = cmd.Start(); err != nil {
 		return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, fmt.Errorf("error starting specified command: %w", err)
@@ -67,7 +93,7 @@ func ExecuteCommand(ctx context.Context, c []string, runAsUser string, envVars [
 
 	// Wait for the command to exit
 	if err = cmd.Wait(); err != nil {
-		return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, fmt.Errorf("error waiting for specified command to exit: %w", err)
+		return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, fmt.Errorf("error waiting for specified command to exit: %w", NewExternalToolError(c, err, stderrb.String()))
 	}
 
 	return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, err
@@ -0,0 +1,147 @@
+package command
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Cache is an Executor that caches the output of queries, i.e. commands that only read the state of the host such as
+// "ioreg" or "diskutil list", for a time-to-live set for each query, so that repeated queries within a run or a
+// watchdog's cycle don't run the same slow tools again. Commands without a TTL, failures, and commands given an input
+// are always run. Caches are created with NewCache.
+type Cache struct {
+	// Executor runs the commands that aren't cached.
+	Executor Executor
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	ttls    []cacheTTL
+	entries map[string]cacheEntry
+}
+
+// cacheTTL is the time-to-live of the queries starting with the arguments.
+type cacheTTL struct {
+	argv []string
+	ttl  time.Duration
+}
+
+// cacheEntry is the cached output of a query.
+type cacheEntry struct {
+	argv    []string
+	output  Output
+	expires time.Time
+}
+
+// NewCache creates a Cache of the queries run by e, or the Default when e is nil, which caches nothing until TTLs are
+// set with SetTTL.
+func NewCache(e Executor) *Cache {
+	if e == nil {
+		e = Default
+	}
+
+	return &Cache{
+		Executor: e,
+		now:      time.Now,
+		entries:  map[string]cacheEntry{},
+	}
+}
+
+// SetTTL caches the output of the commands starting with argv, e.g. "diskutil", "list", for ttl. The longest matching
+// argv takes precedence, so that a TTL set for a tool can be overridden for one of its verbs. A zero ttl doesn't
+// cache the commands. It returns the Cache for chaining.
+func (c *Cache) SetTTL(ttl time.Duration, argv ...string) *Cache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttls = slices.DeleteFunc(c.ttls, func(t cacheTTL) bool { return slices.Equal(t.argv, argv) })
+	c.ttls = append(c.ttls, cacheTTL{argv: slices.Clone(argv), ttl: ttl})
+	// Longer arguments are sorted first so that the first match is the most specific.
+	slices.SortStableFunc(c.ttls, func(a, b cacheTTL) int { return len(b.argv) - len(a.argv) })
+
+	return c
+}
+
+// Invalidate discards the cached output of the commands starting with argv, e.g. after a command changed what they
+// query. No arguments discard everything.
+func (c *Cache) Invalidate(argv ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if hasPrefix(entry.argv, argv) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Run runs the command, discarding what it writes.
+func (c *Cache) Run(ctx context.Context, cmd Command) error {
+	return run(ctx, c, cmd)
+}
+
+// Output returns the cached output of the command when it's a query that was run within its TTL, or runs it
+// otherwise.
+func (c *Cache) Output(ctx context.Context, cmd Command) (Output, error) {
+	ttl := c.ttl(cmd)
+	if ttl <= 0 {
+		return c.Executor.Output(ctx, cmd)
+	}
+
+	key := strings.Join(cmd.Argv, "\x00")
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if now := c.now(); ok && now.Before(entry.expires) {
+		logrus.WithFields(logrus.Fields{
+			"command": strings.Join(cmd.Argv, " "),
+			"age":     (ttl - entry.expires.Sub(now)).Round(time.Millisecond).String(),
+		}).Debug("Using cached command output")
+		return entry.output, nil
+	}
+
+	out, err := c.Executor.Output(ctx, cmd)
+	if err != nil {
+		return out, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{argv: slices.Clone(cmd.Argv), output: out, expires: c.now().Add(ttl)}
+	c.mu.Unlock()
+
+	return out, nil
+}
+
+// ttl returns the time-to-live of the command's output, which is zero when it isn't cached.
+func (c *Cache) ttl(cmd Command) time.Duration {
+	if cmd.Stdin != nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.ttls {
+		if hasPrefix(cmd.Argv, t.argv) {
+			return t.ttl
+		}
+	}
+
+	return 0
+}
+
+// hasPrefix reports whether argv starts with the arguments of prefix.
+func hasPrefix(argv, prefix []string) bool {
+	return len(argv) >= len(prefix) && slices.Equal(argv[:len(prefix)], prefix)
+}
+
+// Invalidate discards the cached output of the commands starting with argv when e caches them, e.g. after a command
+// run by e changed what they query. It does nothing for executors that don't cache.
+func Invalidate(e Executor, argv ...string) {
+	if c, ok := e.(interface{ Invalidate(argv ...string) }); ok {
+		c.Invalidate(argv...)
+	}
+}
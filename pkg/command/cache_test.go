@@ -0,0 +1,77 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingExecutor counts the commands it runs, and fails those in fail.
+type countingExecutor struct {
+	runs map[string]int
+	fail map[string]bool
+}
+
+func (e *countingExecutor) Run(ctx context.Context, c Command) error {
+	return run(ctx, e, c)
+}
+
+func (e *countingExecutor) Output(_ context.Context, c Command) (Output, error) {
+	key := strings.Join(c.Argv, " ")
+	e.runs[key]++
+	if e.fail[key] {
+		return Output{}, errors.New("exit status 1")
+	}
+
+	return Output{Stdout: key}, nil
+}
+
+func TestCache(t *testing.T) {
+	e := &countingExecutor{runs: map[string]int{}, fail: map[string]bool{"diskutil list disk9": true}}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := NewCache(e).
+		SetTTL(time.Minute, "diskutil").
+		SetTTL(10*time.Second, "diskutil", "list")
+	c.now = func() time.Time { return now }
+
+	query := func(argv ...string) {
+		out, err := c.Output(context.Background(), New(argv...))
+		if !e.fail[strings.Join(argv, " ")] {
+			assert.NoError(t, err)
+			assert.Equal(t, strings.Join(argv, " "), out.Stdout)
+		}
+	}
+	for range 2 {
+		query("diskutil", "list", "-plist")
+		query("diskutil", "info", "-plist", "disk0")
+		query("diskutil", "list", "disk9")
+		query("ioreg", "-l")
+	}
+	assert.Equal(t, 1, e.runs["diskutil list -plist"], "queries should be cached")
+	assert.Equal(t, 1, e.runs["diskutil info -plist disk0"])
+	assert.Equal(t, 2, e.runs["diskutil list disk9"], "failures shouldn't be cached")
+	assert.Equal(t, 2, e.runs["ioreg -l"], "commands without a TTL shouldn't be cached")
+
+	now = now.Add(20 * time.Second)
+	query("diskutil", "list", "-plist")
+	query("diskutil", "info", "-plist", "disk0")
+	assert.Equal(t, 2, e.runs["diskutil list -plist"], "the most specific TTL should apply")
+	assert.Equal(t, 1, e.runs["diskutil info -plist disk0"])
+
+	Invalidate(c, "diskutil", "info")
+	query("diskutil", "list", "-plist")
+	query("diskutil", "info", "-plist", "disk0")
+	assert.Equal(t, 2, e.runs["diskutil list -plist"], "only the invalidated queries should be run again")
+	assert.Equal(t, 2, e.runs["diskutil info -plist disk0"])
+
+	c.Invalidate()
+	withInput := New("diskutil", "list", "-plist")
+	withInput.Stdin = strings.NewReader("y\n")
+	_, _ = c.Output(context.Background(), withInput)
+	_, _ = c.Output(context.Background(), withInput)
+	assert.Equal(t, 4, e.runs["diskutil list -plist"], "commands given an input shouldn't be cached")
+}
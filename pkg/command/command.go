@@ -0,0 +1,140 @@
+// Package command provides the functionality necessary for running external commands, such as diskutil, ioreg, and
+// sysdiagnose, behind an Executor, so that the code running them can be tested with a fake such as
+// commandtest.Recorder instead of running the real tools.
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/util"
+)
+
+// Command is an external command to run.
+type Command struct {
+	// Argv is the command's executable followed by its arguments.
+	Argv []string
+	// Stdin is the command's input. Nil gives it no input.
+	Stdin io.Reader
+	// Timeout bounds how long the command may run, after which it's killed. Zero only bounds it by the context.
+	Timeout time.Duration
+}
+
+// New returns the command with the executable and arguments of argv.
+func New(argv ...string) Command {
+	return Command{Argv: argv}
+}
+
+// Output is what a command wrote.
+type Output struct {
+	Stdout string
+	Stderr string
+}
+
+// Executor runs external commands.
+type Executor interface {
+	// Run runs the command, discarding what it writes. It fails when the command can't be started or exits
+	// unsuccessfully, with what the command wrote to stderr.
+	Run(ctx context.Context, c Command) error
+	// Output runs the command and returns what it wrote, which is also returned when it fails.
+	Output(ctx context.Context, c Command) (Output, error)
+}
+
+// ErrUnsupported is returned by Unsupported for every command.
+var ErrUnsupported = errors.New("external commands are not supported on this platform")
+
+// OS is an Executor that runs commands as processes of the operating system. Their runs are traced, counted in the
+// utility's own metrics, and logged with --trace-exec, and their failures are described by the tool that failed.
+type OS struct {
+	// Timeout bounds how long each command may run when it doesn't set its own timeout. Zero is no bound.
+	Timeout time.Duration
+}
+
+// Run runs the command, discarding what it writes.
+func (e OS) Run(ctx context.Context, c Command) error {
+	return run(ctx, e, c)
+}
+
+// Output runs the command and returns what it wrote.
+func (e OS) Output(ctx context.Context, c Command) (Output, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = e.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var stdin io.ReadCloser
+	if c.Stdin != nil {
+		stdin = io.NopCloser(c.Stdin)
+	}
+	out, err := util.ExecuteCommand(ctx, c.Argv, "", nil, stdin)
+
+	return Output{Stdout: out.Stdout, Stderr: out.Stderr}, err
+}
+
+// Unsupported is an Executor that runs no commands and fails each with ErrUnsupported. It's the Default on platforms
+// other than macOS, where the tools the utility runs don't exist or, like sysctl, do something else entirely, so that
+// the utility can be built, linted, and tested there without a Mac.
+type Unsupported struct{}
+
+// Run fails with ErrUnsupported.
+func (u Unsupported) Run(ctx context.Context, c Command) error {
+	_, err := u.Output(ctx, c)
+
+	return err
+}
+
+// Output fails with ErrUnsupported.
+func (Unsupported) Output(_ context.Context, c Command) (Output, error) {
+	name := "command"
+	if len(c.Argv) > 0 {
+		name = c.Argv[0]
+	}
+
+	return Output{}, fmt.Errorf("%s: %w", name, ErrUnsupported)
+}
+
+// run implements Executor.Run with the executor's Output.
+func run(ctx context.Context, e Executor, c Command) error {
+	out, err := e.Output(ctx, c)
+	if err == nil {
+		return nil
+	}
+	if stderr := strings.TrimSpace(out.Stderr); stderr != "" {
+		return fmt.Errorf("%s: %w", stderr, err)
+	}
+
+	return err
+}
+
+// Yes returns an input that answers "y" to every prompt, for commands that ask for confirmation.
+func Yes() io.Reader {
+	return &yes{}
+}
+
+// yes is an endless input of "y" lines.
+type yes struct {
+	// newline reports whether the next byte is the newline of a line.
+	newline bool
+}
+
+func (y *yes) Read(p []byte) (int, error) {
+	for i := range p {
+		if y.newline {
+			p[i] = '\n'
+		} else {
+			p[i] = 'y'
+		}
+		y.newline = !y.newline
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,6 @@
+//go:build darwin
+
+package command
+
+// Default is the Executor used when none is given, which runs the commands on macOS.
+var Default Executor = OS{}
@@ -0,0 +1,6 @@
+//go:build !darwin
+
+package command
+
+// Default is the Executor used when none is given, which runs no commands outside of macOS.
+var Default Executor = Unsupported{}
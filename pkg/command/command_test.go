@@ -0,0 +1,54 @@
+package command
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYes(t *testing.T) {
+	input, err := io.ReadAll(io.LimitReader(Yes(), 7))
+	require.NoError(t, err)
+	assert.Equal(t, "y\ny\ny\ny", string(input))
+}
+
+func TestOS_Output(t *testing.T) {
+	c := New("cat")
+	c.Stdin = io.LimitReader(Yes(), 4)
+	out, err := OS{}.Output(context.Background(), c)
+	require.NoError(t, err)
+	assert.Equal(t, "y\ny\n", out.Stdout, "the input should be given to the command")
+
+	err = OS{}.Run(context.Background(), New("sh", "-c", "echo broken >&2; exit 3"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "broken", "the error should include what the command wrote to stderr")
+	}
+}
+
+func TestOS_Timeout(t *testing.T) {
+	start := time.Now()
+	err := OS{Timeout: 50 * time.Millisecond}.Run(context.Background(), New("sleep", "10"))
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second, "the command should be killed after the timeout")
+
+	c := New("sleep", "10")
+	c.Timeout = 50 * time.Millisecond
+	start = time.Now()
+	assert.Error(t, OS{Timeout: time.Hour}.Run(context.Background(), c))
+	assert.Less(t, time.Since(start), 5*time.Second, "the command's timeout should take precedence")
+}
+
+func TestUnsupported(t *testing.T) {
+	err := Unsupported{}.Run(context.Background(), New("diskutil", "list"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+	assert.ErrorContains(t, err, "diskutil")
+
+	if runtime.GOOS != "darwin" {
+		assert.Equal(t, Unsupported{}, Default, "commands shouldn't be run outside of macOS")
+	}
+}
@@ -0,0 +1,103 @@
+// Package commandtest provides a fake command.Executor for testing code that runs external commands.
+package commandtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/ec2-macos-utils/pkg/command"
+)
+
+// maxRecordedStdin is the number of bytes of a command's input that are recorded, since inputs such as command.Yes
+// are endless.
+const maxRecordedStdin = 4096
+
+// Response is the result of a command run by a Recorder.
+type Response struct {
+	Output command.Output
+	Err    error
+}
+
+// Call is a command run by a Recorder.
+type Call struct {
+	command.Command
+	// Input is the start of what was given to the command as its input.
+	Input string
+}
+
+// Recorder is a command.Executor that records the commands it's given and answers them with predefined responses
+// instead of running them.
+type Recorder struct {
+	// Responses are the responses to commands, by their arguments joined with spaces, e.g. "diskutil list -plist".
+	// Commands without a response succeed without output.
+	Responses map[string]Response
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Respond sets the response to the command with the arguments, and returns the Recorder for chaining.
+func (r *Recorder) Respond(response Response, argv ...string) *Recorder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Responses == nil {
+		r.Responses = map[string]Response{}
+	}
+	r.Responses[strings.Join(argv, " ")] = response
+
+	return r
+}
+
+// Run records the command and returns the error of its response, with its stderr like command.OS.
+func (r *Recorder) Run(ctx context.Context, c command.Command) error {
+	out, err := r.Output(ctx, c)
+	if err != nil && strings.TrimSpace(out.Stderr) != "" {
+		return fmt.Errorf("%s: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return err
+}
+
+// Output records the command and returns its response.
+func (r *Recorder) Output(ctx context.Context, c command.Command) (command.Output, error) {
+	call := Call{Command: c}
+	if c.Stdin != nil {
+		input, _ := io.ReadAll(io.LimitReader(c.Stdin, maxRecordedStdin))
+		call.Input = string(input)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+	if err := ctx.Err(); err != nil {
+		return command.Output{}, err
+	}
+	response := r.Responses[strings.Join(c.Argv, " ")]
+
+	return response.Output, response.Err
+}
+
+// Calls returns the commands run so far, in order.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+// Commands returns the arguments of the commands run so far, joined with spaces, in order.
+func (r *Recorder) Commands() []string {
+	calls := r.Calls()
+	commands := make([]string, 0, len(calls))
+	for _, c := range calls {
+		commands = append(commands, strings.Join(c.Argv, " "))
+	}
+
+	return commands
+}
+
+// Type assertion to ensure Recorder implements the command.Executor interface.
+var _ command.Executor = (*Recorder)(nil)
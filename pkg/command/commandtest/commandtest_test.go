@@ -0,0 +1,33 @@
+package commandtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/command"
+)
+
+func TestRecorder(t *testing.T) {
+	failed := errors.New("exit status 1")
+	r := (&Recorder{}).
+		Respond(Response{Output: command.Output{Stdout: "listed"}}, "diskutil", "list").
+		Respond(Response{Output: command.Output{Stderr: "busy"}, Err: failed}, "diskutil", "repairDisk", "disk1")
+
+	out, err := r.Output(context.Background(), command.New("diskutil", "list"))
+	assert.NoError(t, err)
+	assert.Equal(t, "listed", out.Stdout)
+
+	c := command.New("diskutil", "repairDisk", "disk1")
+	c.Stdin = command.Yes()
+	err = r.Run(context.Background(), c)
+	assert.ErrorIs(t, err, failed)
+	assert.ErrorContains(t, err, "busy")
+
+	assert.NoError(t, r.Run(context.Background(), command.New("true")), "commands without a response should succeed")
+
+	assert.Equal(t, []string{"diskutil list", "diskutil repairDisk disk1", "true"}, r.Commands())
+	assert.Len(t, r.Calls()[1].Input, maxRecordedStdin, "endless inputs should be recorded up to the limit")
+}
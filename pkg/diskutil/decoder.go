@@ -4,9 +4,8 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
-
-	"howett.net/plist"
+	"github.com/aws/ec2-macos-utils/internal/plistutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 )
 
 // Decoder outlines the functionality necessary for decoding plist output from the macOS diskutil command.
@@ -25,13 +24,14 @@ type PlistDecoder struct{}
 
 // DecodeSystemPartitions assumes the io.ReadSeeker it's given contains raw plist data and attempts to decode that.
 func (d *PlistDecoder) DecodeSystemPartitions(reader io.ReadSeeker) (*types.SystemPartitions, error) {
-	// Set up a new SystemPartitions and create a decoder from the reader
-	partitions := &types.SystemPartitions{}
-	decoder := plist.NewDecoder(reader)
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading list: %w", err)
+	}
 
 	// Decode the plist output from diskutil into a SystemPartitions struct for easier access
-	err := decoder.Decode(partitions)
-	if err != nil {
+	partitions := &types.SystemPartitions{}
+	if err := plistutil.DecodeOutput(string(output), partitions); err != nil {
 		return nil, fmt.Errorf("error decoding list: %w", err)
 	}
 
@@ -40,13 +40,14 @@ func (d *PlistDecoder) DecodeSystemPartitions(reader io.ReadSeeker) (*types.Syst
 
 // DecodeDiskInfo assumes the io.ReadSeeker it's given contains raw plist data and attempts to decode that.
 func (d *PlistDecoder) DecodeDiskInfo(reader io.ReadSeeker) (*types.DiskInfo, error) {
-	// Set up a new DiskInfo and create a decoder from the reader
-	disk := &types.DiskInfo{}
-	decoder := plist.NewDecoder(reader)
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading disk info: %w", err)
+	}
 
 	// Decode the plist output from diskutil into a DiskInfo struct for easier access
-	err := decoder.Decode(disk)
-	if err != nil {
+	disk := &types.DiskInfo{}
+	if err := plistutil.DecodeOutput(string(output), disk); err != nil {
 		return nil, fmt.Errorf("error decoding disk info: %w", err)
 	}
 
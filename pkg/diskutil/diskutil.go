@@ -1,7 +1,7 @@
 // Package diskutil provides the functionality necessary for interacting with macOS's diskutil CLI.
 package diskutil
 
-//go:generate mockgen -destination mocks/mock_diskutil.go github.com/aws/ec2-macos-utils/internal/diskutil DiskUtil
+//go:generate mockgen -destination mocks/mock_diskutil.go github.com/aws/ec2-macos-utils/pkg/diskutil DiskUtil
 
 import (
 	"context"
@@ -9,8 +9,9 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
-	"github.com/aws/ec2-macos-utils/internal/system"
+	"github.com/aws/ec2-macos-utils/pkg/command"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/system"
 
 	"github.com/Masterminds/semver"
 )
@@ -88,32 +89,39 @@ func Dryrun(impl DiskUtil) *readonlyWrapper {
 
 // ForProduct creates a new diskutil controller for the given product.
 func ForProduct(p *system.Product) (DiskUtil, error) {
+	return ForProductWithExecutor(p, nil)
+}
+
+// ForProductWithExecutor creates a new diskutil controller for the given product, which runs diskutil with the
+// Executor e, e.g. a commandtest.Recorder in tests. Nil uses command.Default.
+func ForProductWithExecutor(p *system.Product, e command.Executor) (DiskUtil, error) {
+	cmd := &DiskUtilityCmd{Executor: e}
 	switch p.Release {
 	case system.Mojave:
-		return newMojave(p.Version)
+		return newMojave(p.Version, cmd)
 	case system.Catalina:
-		return newCatalina(p.Version)
+		return newCatalina(p.Version, cmd)
 	case system.BigSur:
-		return newBigSur(p.Version)
+		return newBigSur(p.Version, cmd)
 	case system.Monterey:
-		return newMonterey(p.Version)
+		return newMonterey(p.Version, cmd)
 	case system.Ventura:
-		return newVentura(p.Version)
+		return newVentura(p.Version, cmd)
 	case system.Sonoma:
-		return newSonoma(p.Version)
+		return newSonoma(p.Version, cmd)
 	case system.Sequoia:
-		return newSequoia(p.Version)
+		return newSequoia(p.Version, cmd)
 	case system.Tahoe:
-		return newTahoe(p.Version)
+		return newTahoe(p.Version, cmd)
 	default:
 		return nil, errors.New("unknown release")
 	}
 }
 
 // newMojave configures the DiskUtil for the specified Mojave version.
-func newMojave(version semver.Version) (*diskutilMojave, error) {
+func newMojave(version semver.Version, cmd *DiskUtilityCmd) (*diskutilMojave, error) {
 	du := &diskutilMojave{
-		embeddedDiskutil: &DiskUtilityCmd{},
+		embeddedDiskutil: cmd,
 		dec:              &PlistDecoder{},
 	}
 
@@ -121,9 +129,9 @@ func newMojave(version semver.Version) (*diskutilMojave, error) {
 }
 
 // newCatalina configures the DiskUtil for the specified Catalina version.
-func newCatalina(version semver.Version) (*diskutilCatalina, error) {
+func newCatalina(version semver.Version, cmd *DiskUtilityCmd) (*diskutilCatalina, error) {
 	du := &diskutilCatalina{
-		embeddedDiskutil: &DiskUtilityCmd{},
+		embeddedDiskutil: cmd,
 		dec:              &PlistDecoder{},
 	}
 
@@ -131,9 +139,9 @@ func newCatalina(version semver.Version) (*diskutilCatalina, error) {
 }
 
 // newBigSur configures the DiskUtil for the specified Big Sur version.
-func newBigSur(version semver.Version) (*diskutilBigSur, error) {
+func newBigSur(version semver.Version, cmd *DiskUtilityCmd) (*diskutilBigSur, error) {
 	du := &diskutilBigSur{
-		embeddedDiskutil: &DiskUtilityCmd{},
+		embeddedDiskutil: cmd,
 		dec:              &PlistDecoder{},
 	}
 
@@ -141,9 +149,9 @@ func newBigSur(version semver.Version) (*diskutilBigSur, error) {
 }
 
 // newMonterey configures the DiskUtil for the specified Monterey version.
-func newMonterey(version semver.Version) (*diskutilMonterey, error) {
+func newMonterey(version semver.Version, cmd *DiskUtilityCmd) (*diskutilMonterey, error) {
 	du := &diskutilMonterey{
-		embeddedDiskutil: &DiskUtilityCmd{},
+		embeddedDiskutil: cmd,
 		dec:              &PlistDecoder{},
 	}
 
@@ -151,9 +159,9 @@ func newMonterey(version semver.Version) (*diskutilMonterey, error) {
 }
 
 // newVentura configures the DiskUtil for the specified Ventura version.
-func newVentura(version semver.Version) (*diskutilVentura, error) {
+func newVentura(version semver.Version, cmd *DiskUtilityCmd) (*diskutilVentura, error) {
 	du := &diskutilVentura{
-		embeddedDiskutil: &DiskUtilityCmd{},
+		embeddedDiskutil: cmd,
 		dec:              &PlistDecoder{},
 	}
 
@@ -161,9 +169,9 @@ func newVentura(version semver.Version) (*diskutilVentura, error) {
 }
 
 // newSonoma configures the DiskUtil for the specified Sonoma version.
-func newSonoma(version semver.Version) (*diskutilSonoma, error) {
+func newSonoma(version semver.Version, cmd *DiskUtilityCmd) (*diskutilSonoma, error) {
 	du := &diskutilSonoma{
-		embeddedDiskutil: &DiskUtilityCmd{},
+		embeddedDiskutil: cmd,
 		dec:              &PlistDecoder{},
 	}
 
@@ -171,9 +179,9 @@ func newSonoma(version semver.Version) (*diskutilSonoma, error) {
 }
 
 // newSequoia configures the DiskUtil for the specified Sequoia version.
-func newSequoia(version semver.Version) (*diskutilSonoma, error) {
+func newSequoia(version semver.Version, cmd *DiskUtilityCmd) (*diskutilSonoma, error) {
 	du := &diskutilSonoma{
-		embeddedDiskutil: &DiskUtilityCmd{},
+		embeddedDiskutil: cmd,
 		dec:              &PlistDecoder{},
 	}
 
@@ -181,9 +189,9 @@ func newSequoia(version semver.Version) (*diskutilSonoma, error) {
 }
 
 // newTahoe configures the DiskUtil for the specified Tahoe version.
-func newTahoe(version semver.Version) (*diskutilSonoma, error) {
+func newTahoe(version semver.Version, cmd *DiskUtilityCmd) (*diskutilSonoma, error) {
 	du := &diskutilSonoma{
-		embeddedDiskutil: &DiskUtilityCmd{},
+		embeddedDiskutil: cmd,
 		dec:              &PlistDecoder{},
 	}
 
@@ -218,7 +226,7 @@ func (d *diskutilMojave) List(ctx context.Context, args []string) (*types.System
 		return nil, err
 	}
 
-	err = updatePhysicalStores(ctx, partitions)
+	err = updatePhysicalStores(ctx, executorOf(d.embeddedDiskutil), partitions)
 	if err != nil {
 		return partitions, err
 	}
@@ -238,7 +246,7 @@ func (d *diskutilMojave) Info(ctx context.Context, id string) (*types.DiskInfo,
 		return nil, err
 	}
 
-	err = updatePhysicalStore(ctx, disk)
+	err = updatePhysicalStore(ctx, executorOf(d.embeddedDiskutil), disk)
 	if err != nil {
 		return disk, err
 	}
@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 
 	"github.com/dustin/go-humanize"
 	"github.com/sirupsen/logrus"
 )
 
+// growPhase is the progress phase of GrowContainer, whose completion is reported by step.
+const growPhase = "grow"
+
 // GrowContainer grows a container to its maximum size by performing the following operations:
 //  1. Verify that the given types.DiskInfo is an APFS container that can be resized.
 //  2. Fetch the types.DiskInfo for the underlying physical disk (if the container isn't a physical device).
@@ -22,6 +26,8 @@ func GrowContainer(ctx context.Context, u DiskUtil, container *types.DiskInfo) e
 		return fmt.Errorf("unable to resize nil container")
 	}
 
+	reporter := contextual.Progress(ctx)
+	reporter.Report(growPhase, 0, "checking the container")
 	logrus.WithField("device_id", container.DeviceIdentifier).Info("Checking if device can be APFS resized...")
 	if err := canAPFSResize(container); err != nil {
 		return fmt.Errorf("unable to resize container: %w", err)
@@ -42,6 +48,7 @@ func GrowContainer(ctx context.Context, u DiskUtil, container *types.DiskInfo) e
 	}
 
 	// Capture any free space on a resized disk
+	reporter.Report(growPhase, 25, "repairing the parent disk")
 	logrus.Info("Repairing the parent disk...")
 	_, err := repairParentDisk(ctx, u, phy)
 	if err != nil {
@@ -50,6 +57,7 @@ func GrowContainer(ctx context.Context, u DiskUtil, container *types.DiskInfo) e
 	logrus.Info("Successfully repaired the parent disk")
 
 	// Minimum free space to resize required - bail if we don't have enough.
+	reporter.Report(growPhase, 50, "checking free space")
 	logrus.WithField("device_id", phy.DeviceIdentifier).Info("Fetching amount of free space on device...")
 	totalFree, err := getDiskFreeSpace(ctx, u, phy)
 	if err != nil {
@@ -68,6 +76,7 @@ func GrowContainer(ctx context.Context, u DiskUtil, container *types.DiskInfo) e
 		"device_id":  phy.DeviceIdentifier,
 		"free_space": humanize.Bytes(totalFree),
 	}).Info("Resizing container to maximum size...")
+	reporter.Report(growPhase, 75, "resizing the container")
 	out, err := u.ResizeContainer(ctx, phy.DeviceIdentifier, "0")
 	logrus.WithField("out", out).Debug("Resize output")
 	if errors.Is(err, ErrReadOnly) {
@@ -75,6 +84,7 @@ func GrowContainer(ctx context.Context, u DiskUtil, container *types.DiskInfo) e
 	} else if err != nil {
 		return err
 	}
+	reporter.Report(growPhase, 100, "container resized")
 
 	return nil
 }
@@ -1,3 +1,4 @@
+// Package identifier provides the functionality necessary for parsing the device identifiers of disks, e.g. disk0.
 package identifier
 
 import (
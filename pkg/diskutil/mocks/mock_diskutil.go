@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/aws/ec2-macos-utils/internal/diskutil (interfaces: DiskUtil)
+// Source: github.com/aws/ec2-macos-utils/pkg/diskutil (interfaces: DiskUtil)
 
 // Package mock_diskutil is a generated GoMock package.
 package mock_diskutil
@@ -8,7 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
-	types "github.com/aws/ec2-macos-utils/internal/diskutil/types"
+	types "github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -5,18 +5,27 @@ import (
 	"fmt"
 	"regexp"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
-	"github.com/aws/ec2-macos-utils/internal/util"
+	"github.com/aws/ec2-macos-utils/pkg/command"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 )
 
+// executorOf returns the Executor that runs the commands of the diskutil implementation.
+func executorOf(u UtilImpl) command.Executor {
+	if d, ok := u.(*DiskUtilityCmd); ok {
+		return d.executor()
+	}
+
+	return command.Default
+}
+
 // updatePhysicalStores provides separate functionality for fetching APFS physical stores for SystemPartitions.
-func updatePhysicalStores(ctx context.Context, partitions *types.SystemPartitions) error {
+func updatePhysicalStores(ctx context.Context, e command.Executor, partitions *types.SystemPartitions) error {
 	// Independently update all APFS disks' physical stores
 	for i, part := range partitions.AllDisksAndPartitions {
 		// Only do the update if the disk/partition is APFS
 		if isAPFSVolume(part) {
 			// Fetch the physical store for the disk/partition
-			physicalStoreDeviceID, err := fetchPhysicalStore(ctx, part.DeviceIdentifier)
+			physicalStoreDeviceID, err := fetchPhysicalStore(ctx, e, part.DeviceIdentifier)
 			if err != nil {
 				return err
 			}
@@ -42,13 +51,13 @@ func isAPFSVolume(part types.DiskPart) bool {
 // fetchPhysicalStore parses the human-readable output of the list verb for the given ID in order to fetch its
 // physical store. This function is limited to returning only one physical store so the behavior might cause problems
 // for fusion devices that have more than one APFS physical store.
-func fetchPhysicalStore(ctx context.Context, id string) (string, error) {
+func fetchPhysicalStore(ctx context.Context, e command.Executor, id string) (string, error) {
 	// Create the command for running diskutil and parsing the output to retrieve the desired info (physical store)
 	//   * list - specifies the diskutil 'list' verb for a specific device ID and returns the human-readable output
 	cmdPhysicalStore := []string{"diskutil", "list", id}
 
 	// Execute the command to parse output from diskutil list
-	out, err := util.ExecuteCommand(ctx, cmdPhysicalStore, "", nil, nil)
+	out, err := e.Output(ctx, command.New(cmdPhysicalStore...))
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", out.Stderr, err)
 	}
@@ -74,9 +83,9 @@ func parsePhysicalStoreId(raw string) (string, error) {
 }
 
 // updatePhysicalStore provides separate functionality for fetching APFS physical stores for DiskInfo.
-func updatePhysicalStore(ctx context.Context, disk *types.DiskInfo) error {
+func updatePhysicalStore(ctx context.Context, e command.Executor, disk *types.DiskInfo) error {
 	if isAPFSMedia(disk) {
-		physicalStoreDeviceID, err := fetchPhysicalStore(ctx, disk.DeviceIdentifier)
+		physicalStoreDeviceID, err := fetchPhysicalStore(ctx, e, disk.DeviceIdentifier)
 		if err != nil {
 			return err
 		}
@@ -1,10 +1,12 @@
+// Package types provides the types decoded from the property lists printed by diskutil, describing disks,
+// partitions, and APFS containers and volumes.
 package types
 
 import (
 	"fmt"
 	"strings"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
 )
 
 // DiskInfo mirrors the output format of the command "diskutil info -plist <disk>" to store information about a disk.
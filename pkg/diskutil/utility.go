@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/aws/ec2-macos-utils/internal/util"
+	"github.com/aws/ec2-macos-utils/pkg/command"
 )
 
 // UtilImpl outlines the functionality necessary for wrapping macOS's diskutil tool. The methods are intentionally
@@ -30,8 +30,26 @@ type APFSImpl interface {
 	ResizeContainer(ctx context.Context, id string, size string) (string, error)
 }
 
-// DiskUtilityCmd is an empty struct that provides the implementation for the DiskUtility interface.
-type DiskUtilityCmd struct{}
+// DiskUtilityCmd provides the implementation for the DiskUtility interface by running diskutil.
+type DiskUtilityCmd struct {
+	// Executor runs diskutil. Nil uses command.Default.
+	Executor command.Executor
+}
+
+// executor returns the Executor that runs diskutil.
+func (d *DiskUtilityCmd) executor() command.Executor {
+	if d.Executor == nil {
+		return command.Default
+	}
+
+	return d.Executor
+}
+
+// invalidate discards the cached disk information after a command that may have changed the disks, even when it
+// failed partway.
+func (d *DiskUtilityCmd) invalidate() {
+	command.Invalidate(d.executor(), "diskutil")
+}
 
 // List uses the macOS diskutil list command to list disks and partitions in a plist format by passing the -plist arg.
 // List also appends any given args to fully support the diskutil list verb.
@@ -46,7 +64,7 @@ func (d *DiskUtilityCmd) List(ctx context.Context, args []string) (string, error
 	}
 
 	// Execute the diskutil list command and store the output
-	cmdOut, err := util.ExecuteCommand(ctx, cmdListDisks, "", nil, nil)
+	cmdOut, err := d.executor().Output(ctx, command.New(cmdListDisks...))
 	if err != nil {
 		return cmdOut.Stdout, fmt.Errorf("diskutil: failed to run diskutil command to list all disks, stderr: [%s]: %w", cmdOut.Stderr, err)
 	}
@@ -63,7 +81,7 @@ func (d *DiskUtilityCmd) Info(ctx context.Context, id string) (string, error) {
 	cmdDiskInfo := []string{"diskutil", "info", "-plist", id}
 
 	// Execute the diskutil info command and store the output
-	cmdOut, err := util.ExecuteCommand(ctx, cmdDiskInfo, "", nil, nil)
+	cmdOut, err := d.executor().Output(ctx, command.New(cmdDiskInfo...))
 	if err != nil {
 		return cmdOut.Stdout, fmt.Errorf("diskutil: failed to run diskutil command to fetch disk information, stderr: [%s]: %w", cmdOut.Stderr, err)
 	}
@@ -75,13 +93,16 @@ func (d *DiskUtilityCmd) Info(ctx context.Context, id string) (string, error) {
 // (e.g. amount of free space).
 func (d *DiskUtilityCmd) RepairDisk(ctx context.Context, id string) (string, error) {
 	// cmdRepairDisk represents the command used for executing macOS's diskutil to repair a disk.
-	// The repairDisk command requires interactive-input ("yes"/"no") but is automated with util.ExecuteCommandYes.
+	// The repairDisk command requires interactive-input ("yes"/"no") but is automated with command.Yes.
 	//   * repairDisk - indicates that a disk is going to be repaired (used to fetch amount of free space)
 	//   * id - the device identifier for the disk to be repaired
 	cmdRepairDisk := []string{"diskutil", "repairDisk", id}
 
 	// Execute the diskutil repairDisk command and store the output
-	cmdOut, err := util.ExecuteCommandYes(ctx, cmdRepairDisk, "", []string{})
+	c := command.New(cmdRepairDisk...)
+	c.Stdin = command.Yes()
+	cmdOut, err := d.executor().Output(ctx, c)
+	d.invalidate()
 	if err != nil {
 		return cmdOut.Stdout, fmt.Errorf("diskutil: failed to run repairDisk command, stderr: [%s]: %w", cmdOut.Stderr, err)
 	}
@@ -99,7 +120,8 @@ func (d *DiskUtilityCmd) ResizeContainer(ctx context.Context, id string, size st
 	cmdResizeContainer := []string{"diskutil", "apfs", "resizeContainer", id, size}
 
 	// Execute the diskutil apfs resizeContainer command and store the output
-	cmdOut, err := util.ExecuteCommand(ctx, cmdResizeContainer, "", nil, nil)
+	cmdOut, err := d.executor().Output(ctx, command.New(cmdResizeContainer...))
+	d.invalidate()
 	if err != nil {
 		return cmdOut.Stdout, fmt.Errorf("diskutil: failed to run diskutil command to resize the container, stderr [%s]: %w", cmdOut.Stderr, err)
 	}
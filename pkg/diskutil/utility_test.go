@@ -0,0 +1,44 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/command"
+	"github.com/aws/ec2-macos-utils/pkg/command/commandtest"
+)
+
+func TestDiskUtilityCmd(t *testing.T) {
+	r := (&commandtest.Recorder{}).
+		Respond(commandtest.Response{Output: command.Output{Stdout: "<plist/>"}}, "diskutil", "list", "-plist", "physical").
+		Respond(commandtest.Response{Output: command.Output{Stderr: "Unable to repair"}, Err: errors.New("exit status 1")}, "diskutil", "repairDisk", "disk0")
+	d := &DiskUtilityCmd{Executor: r}
+
+	out, err := d.List(context.Background(), []string{"physical"})
+	assert.NoError(t, err)
+	assert.Equal(t, "<plist/>", out)
+
+	_, err = d.RepairDisk(context.Background(), "disk0")
+	assert.ErrorContains(t, err, "Unable to repair")
+
+	assert.Equal(t, []string{"diskutil list -plist physical", "diskutil repairDisk disk0"}, r.Commands())
+	assert.True(t, strings.HasPrefix(r.Calls()[1].Input, "y\ny\n"), "repairDisk should be confirmed")
+}
+
+func TestDiskUtilityCmd_Invalidate(t *testing.T) {
+	r := &commandtest.Recorder{}
+	d := &DiskUtilityCmd{Executor: command.NewCache(r).SetTTL(time.Minute, "diskutil", "list")}
+
+	_, _ = d.List(context.Background(), nil)
+	_, _ = d.List(context.Background(), nil)
+	_, _ = d.ResizeContainer(context.Background(), "disk1", "0")
+	_, _ = d.List(context.Background(), nil)
+
+	assert.Equal(t, []string{"diskutil list -plist", "diskutil apfs resizeContainer disk1 0", "diskutil list -plist"}, r.Commands(),
+		"the disks should be listed again after they're resized")
+}
@@ -0,0 +1,106 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// imdsScheduledEventsPath is the IMDS path that lists the instance's scheduled events.
+const imdsScheduledEventsPath = "events/maintenance/scheduled"
+
+// scheduledEventTimeFormat is the format of times in scheduled events, e.g. "21 Jan 2019 09:00:43 GMT".
+const scheduledEventTimeFormat = "2 Jan 2006 15:04:05 GMT"
+
+// Scheduled event codes.
+const (
+	EventInstanceReboot     = "instance-reboot"
+	EventSystemReboot       = "system-reboot"
+	EventSystemMaintenance  = "system-maintenance"
+	EventInstanceRetirement = "instance-retirement"
+	EventInstanceStop       = "instance-stop"
+)
+
+// Scheduled event states.
+const (
+	EventActive    = "active"
+	EventCompleted = "completed"
+	EventCanceled  = "canceled"
+)
+
+// ScheduledEvent is a maintenance event AWS has scheduled for the instance.
+type ScheduledEvent struct {
+	// ID identifies the event, e.g. instance-event-0d59937288b749b32.
+	ID string
+	// Code is the kind of event, e.g. system-reboot.
+	Code string
+	// Description describes the event.
+	Description string
+	// State is active, completed, or canceled.
+	State string
+	// NotBefore is the earliest start of the event window.
+	NotBefore time.Time
+	// NotAfter is the latest end of the event window, zero when the event has no end.
+	NotAfter time.Time
+}
+
+// Active reports whether the event is still going to happen.
+func (e ScheduledEvent) Active() bool {
+	return e.State == EventActive
+}
+
+// ScheduledEvents returns the instance's scheduled events, including completed and canceled ones.
+func ScheduledEvents(ctx context.Context, client IMDSAPI) ([]ScheduledEvent, error) {
+	data, err := readMetadata(ctx, client, imdsScheduledEventsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseScheduledEvents([]byte(data))
+}
+
+// parseScheduledEvents decodes the IMDS scheduled events document.
+func parseScheduledEvents(data []byte) ([]ScheduledEvent, error) {
+	var raw []struct {
+		EventID     string `json:"EventId"`
+		Code        string
+		Description string
+		State       string
+		NotBefore   string
+		NotAfter    string
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("decode scheduled events: %w", err)
+		}
+	}
+
+	events := make([]ScheduledEvent, 0, len(raw))
+	for _, r := range raw {
+		e := ScheduledEvent{ID: r.EventID, Code: r.Code, Description: r.Description, State: r.State}
+		var err error
+		if e.NotBefore, err = parseEventTime(r.NotBefore); err != nil {
+			return nil, err
+		}
+		if e.NotAfter, err = parseEventTime(r.NotAfter); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// parseEventTime parses a scheduled event time, which may be empty.
+func parseEventTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(scheduledEventTimeFormat, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid scheduled event time %q: %w", s, err)
+	}
+
+	return t.UTC(), nil
+}
@@ -0,0 +1,42 @@
+package instance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduledEvents(t *testing.T) {
+	client := fakeIMDS{
+		"events/maintenance/scheduled": `[
+  {"NotBefore": "21 Jan 2019 09:00:43 GMT", "Code": "system-reboot", "Description": "scheduled reboot", "EventId": "instance-event-0d59937288b749b32", "NotAfter": "21 Jan 2019 09:17:23 GMT", "State": "active"},
+  {"NotBefore": "2 Feb 2019 09:00:00 GMT", "Code": "instance-retirement", "Description": "retirement", "EventId": "instance-event-1", "State": "canceled"}
+]`,
+	}
+
+	events, err := ScheduledEvents(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	assert.Equal(t, "instance-event-0d59937288b749b32", events[0].ID)
+	assert.Equal(t, EventSystemReboot, events[0].Code)
+	assert.True(t, events[0].Active())
+	assert.Equal(t, time.Date(2019, 1, 21, 9, 0, 43, 0, time.UTC), events[0].NotBefore)
+	assert.Equal(t, time.Date(2019, 1, 21, 9, 17, 23, 0, time.UTC), events[0].NotAfter)
+
+	assert.False(t, events[1].Active())
+	assert.True(t, events[1].NotAfter.IsZero())
+}
+
+func TestScheduledEvents_None(t *testing.T) {
+	events, err := ScheduledEvents(context.Background(), fakeIMDS{"events/maintenance/scheduled": "[]"})
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestScheduledEvents_Invalid(t *testing.T) {
+	_, err := ScheduledEvents(context.Background(), fakeIMDS{"events/maintenance/scheduled": `[{"NotBefore": "tomorrow"}]`})
+	assert.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package instance
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultCertificateDir holds the AWS public certificates used to verify instance identity documents, named
+	// <region>.pem, with default.pem used for regions without their own certificate. The utility doesn't install
+	// them, they're downloaded from the EC2 documentation.
+	DefaultCertificateDir = "/etc/ec2-macos-utils/identity-certificates"
+
+	imdsIdentityDocumentPath  = "instance-identity/document"
+	imdsIdentitySignaturePath = "instance-identity/signature"
+
+	// identityCacheTTL is how long a fetched identity document is reused. The document only changes when the
+	// instance is stopped and started, which restarts every process, so this only bounds very long-running ones.
+	identityCacheTTL = time.Hour
+)
+
+var (
+	// ErrInvalidSignature is returned when the identity document doesn't match its signature.
+	ErrInvalidSignature = errors.New("instance identity document signature is invalid")
+	// ErrNoCertificate is returned when verification is required and no certificate is installed for the region.
+	ErrNoCertificate = errors.New("no certificate to verify the instance identity document")
+)
+
+// Identity is the instance identity document.
+type Identity struct {
+	imds.InstanceIdentityDocument
+
+	// Verified reports whether the document's signature was verified with an AWS public certificate. It's false
+	// when no certificate is installed for the region.
+	Verified bool `json:"verified"`
+}
+
+// IdentityReader reads and verifies the instance identity document.
+type IdentityReader struct {
+	// IMDS is the instance metadata client.
+	IMDS IMDSAPI
+	// CertificateDir holds the certificates used for verification. Empty uses DefaultCertificateDir.
+	CertificateDir string
+	// Require fails reading the document when no certificate is installed for the region, rather than returning it
+	// unverified.
+	Require bool
+}
+
+// Identity fetches the instance identity document and verifies its signature when a certificate for the region is
+// installed. A document that doesn't match its signature is an error wrapping ErrInvalidSignature, and one that can't
+// be verified with Require is an error wrapping ErrNoCertificate.
+func (r *IdentityReader) Identity(ctx context.Context) (*Identity, error) {
+	doc, err := readDynamicData(ctx, r.IMDS, imdsIdentityDocumentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{}
+	if err := json.Unmarshal(doc, &identity.InstanceIdentityDocument); err != nil {
+		return nil, fmt.Errorf("decode instance identity document: %w", err)
+	}
+
+	dir := r.CertificateDir
+	if dir == "" {
+		dir = DefaultCertificateDir
+	}
+	cert, err := loadCertificate(dir, identity.Region)
+	if errors.Is(err, os.ErrNotExist) {
+		if r.Require {
+			return nil, fmt.Errorf("%w in %s for %s", ErrNoCertificate, dir, identity.Region)
+		}
+		logrus.WithField("dir", dir).Debug("No certificate to verify the instance identity document")
+		return identity, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := readDynamicData(ctx, r.IMDS, imdsIdentitySignaturePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifySignature(doc, signature, cert); err != nil {
+		return nil, err
+	}
+	identity.Verified = true
+
+	return identity, nil
+}
+
+// VerifySignature verifies the base64 encoded RSA SHA-256 signature of the identity document with the certificate.
+func VerifySignature(doc, signature []byte, cert *x509.Certificate) error {
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported certificate key type %T", cert.PublicKey)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	digest := sha256.Sum256(doc)
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	return nil
+}
+
+// loadCertificate reads the certificate for the region from dir, falling back to default.pem. The error wraps
+// os.ErrNotExist when neither exists.
+func loadCertificate(dir, region string) (*x509.Certificate, error) {
+	var data []byte
+	var err error
+	for _, name := range []string{region + ".pem", "default.pem"} {
+		if data, err = os.ReadFile(filepath.Join(dir, name)); err == nil || !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM certificate for %s in %s", region, dir)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate for %s: %w", region, err)
+	}
+
+	return cert, nil
+}
+
+// readDynamicData reads the IMDS dynamic data path.
+func readDynamicData(ctx context.Context, client IMDSAPI, path string) ([]byte, error) {
+	out, err := client.GetDynamicData(ctx, &imds.GetDynamicDataInput{Path: path})
+	if err != nil {
+		return nil, fmt.Errorf("get dynamic data %s: %w", path, err)
+	}
+	defer func() { _ = out.Content.Close() }()
+
+	data, err := io.ReadAll(out.Content)
+	if err != nil {
+		return nil, fmt.Errorf("read dynamic data %s: %w", path, err)
+	}
+
+	return data, nil
+}
+
+// identityCache holds the identity document shared by every feature in the process.
+var identityCache struct {
+	mu        sync.Mutex
+	identity  *Identity
+	fetchedAt time.Time
+}
+
+// CachedIdentity returns the instance identity document, fetching and verifying it with the client only when it
+// hasn't been fetched recently, so features that need the instance's identity don't each query IMDS.
+func CachedIdentity(ctx context.Context, client IMDSAPI) (*Identity, error) {
+	identityCache.mu.Lock()
+	defer identityCache.mu.Unlock()
+
+	if identityCache.identity != nil && time.Since(identityCache.fetchedAt) < identityCacheTTL {
+		return identityCache.identity, nil
+	}
+
+	identity, err := (&IdentityReader{IMDS: client}).Identity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	identityCache.identity, identityCache.fetchedAt = identity, time.Now()
+
+	return identity, nil
+}
@@ -0,0 +1,124 @@
+package instance
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testIdentityDocument = `{
+  "accountId": "123456789012",
+  "availabilityZone": "us-east-1a",
+  "imageId": "ami-123",
+  "instanceId": "i-123",
+  "instanceType": "mac2.metal",
+  "region": "us-east-1"
+}`
+
+// resetIdentityCache clears the identity cache before and after the test.
+func resetIdentityCache(t *testing.T) {
+	reset := func() {
+		identityCache.mu.Lock()
+		defer identityCache.mu.Unlock()
+		identityCache.identity = nil
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// signDocument creates a self-signed certificate, saved as file in dir, and returns the base64 encoded signature of
+// doc made with its key.
+func signDocument(t *testing.T, dir, file, doc string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, file), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	digest := sha256.Sum256([]byte(doc))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestIdentityReader_Verified(t *testing.T) {
+	dir := t.TempDir()
+	sig := signDocument(t, dir, "us-east-1.pem", testIdentityDocument)
+
+	r := &IdentityReader{
+		IMDS: fakeIMDS{
+			"dynamic/instance-identity/document":  testIdentityDocument,
+			"dynamic/instance-identity/signature": sig,
+		},
+		CertificateDir: dir,
+	}
+	identity, err := r.Identity(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, identity.Verified)
+	assert.Equal(t, "i-123", identity.InstanceID)
+	assert.Equal(t, "us-east-1a", identity.AvailabilityZone)
+	assert.Equal(t, "123456789012", identity.AccountID)
+}
+
+func TestIdentityReader_InvalidSignature(t *testing.T) {
+	dir := t.TempDir()
+	sig := signDocument(t, dir, "default.pem", `{"instanceId": "i-456"}`)
+
+	r := &IdentityReader{
+		IMDS: fakeIMDS{
+			"dynamic/instance-identity/document":  testIdentityDocument,
+			"dynamic/instance-identity/signature": sig,
+		},
+		CertificateDir: dir,
+	}
+	_, err := r.Identity(context.Background())
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestIdentityReader_NoCertificate(t *testing.T) {
+	r := &IdentityReader{
+		IMDS:           fakeIMDS{"dynamic/instance-identity/document": testIdentityDocument},
+		CertificateDir: t.TempDir(),
+	}
+	identity, err := r.Identity(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, identity.Verified, "the document can't be verified without a certificate")
+	assert.Equal(t, "i-123", identity.InstanceID)
+
+	r.Require = true
+	_, err = r.Identity(context.Background())
+	assert.ErrorIs(t, err, ErrNoCertificate)
+}
+
+func TestCachedIdentity(t *testing.T) {
+	resetIdentityCache(t)
+
+	identity, err := CachedIdentity(context.Background(), fakeIMDS{"dynamic/instance-identity/document": testIdentityDocument})
+	assert.NoError(t, err)
+	assert.Equal(t, "i-123", identity.InstanceID)
+
+	cached, err := CachedIdentity(context.Background(), fakeIMDS{})
+	assert.NoError(t, err, "the cached document should be used without IMDS")
+	assert.Same(t, identity, cached)
+}
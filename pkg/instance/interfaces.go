@@ -0,0 +1,78 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// imdsInterfacesPath is the IMDS path that lists the MAC addresses of the attached network interfaces.
+const imdsInterfacesPath = "network/interfaces/macs"
+
+// NetworkInterface is an elastic network interface (ENI) attached to the instance.
+type NetworkInterface struct {
+	// ID is the interface's ID, e.g. "eni-0123456789abcdef0".
+	ID string `json:"id"`
+	// MAC is the interface's MAC address.
+	MAC string `json:"mac"`
+	// DeviceNumber is the attachment's device index, 0 for the primary interface.
+	DeviceNumber int `json:"device_number"`
+	// PrivateIPv4s are the interface's private IPv4 addresses, the primary address first.
+	PrivateIPv4s []string `json:"private_ipv4s"`
+	// SubnetCIDR is the IPv4 CIDR block of the interface's subnet.
+	SubnetCIDR string `json:"subnet_cidr"`
+}
+
+// NetworkInterfaces returns the network interfaces attached to the instance, ordered by device number.
+func NetworkInterfaces(ctx context.Context, client IMDSAPI) ([]NetworkInterface, error) {
+	list, err := readMetadata(ctx, client, imdsInterfacesPath+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []NetworkInterface
+	for _, line := range strings.Split(list, "\n") {
+		mac := strings.TrimSuffix(strings.TrimSpace(line), "/")
+		if mac == "" {
+			continue
+		}
+		iface, err := networkInterface(ctx, client, mac)
+		if err != nil {
+			return nil, err
+		}
+		interfaces = append(interfaces, iface)
+	}
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].DeviceNumber < interfaces[j].DeviceNumber })
+
+	return interfaces, nil
+}
+
+// networkInterface reads the network interface with the MAC address.
+func networkInterface(ctx context.Context, client IMDSAPI, mac string) (NetworkInterface, error) {
+	iface := NetworkInterface{MAC: mac}
+	path := imdsInterfacesPath + "/" + mac + "/"
+
+	var err error
+	if iface.ID, err = readMetadata(ctx, client, path+"interface-id"); err != nil {
+		return NetworkInterface{}, err
+	}
+	number, err := readMetadata(ctx, client, path+"device-number")
+	if err != nil {
+		return NetworkInterface{}, err
+	}
+	if iface.DeviceNumber, err = strconv.Atoi(number); err != nil {
+		return NetworkInterface{}, fmt.Errorf("invalid device number %q of %s: %w", number, iface.ID, err)
+	}
+	if iface.SubnetCIDR, err = readMetadata(ctx, client, path+"subnet-ipv4-cidr-block"); err != nil {
+		return NetworkInterface{}, err
+	}
+	ips, err := readMetadata(ctx, client, path+"local-ipv4s")
+	if err != nil {
+		return NetworkInterface{}, err
+	}
+	iface.PrivateIPv4s = strings.Fields(ips)
+
+	return iface, nil
+}
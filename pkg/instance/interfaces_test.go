@@ -0,0 +1,31 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkInterfaces(t *testing.T) {
+	const (
+		primary   = "network/interfaces/macs/06:00:00:00:00:01/"
+		secondary = "network/interfaces/macs/06:00:00:00:00:02/"
+	)
+	interfaces, err := NetworkInterfaces(context.Background(), fakeIMDS{
+		"network/interfaces/macs/":           "06:00:00:00:00:02/\n06:00:00:00:00:01/",
+		primary + "interface-id":             "eni-1",
+		primary + "device-number":            "0",
+		primary + "subnet-ipv4-cidr-block":   "10.0.1.0/24",
+		primary + "local-ipv4s":              "10.0.1.10\n10.0.1.11",
+		secondary + "interface-id":           "eni-2",
+		secondary + "device-number":          "1",
+		secondary + "subnet-ipv4-cidr-block": "10.0.2.0/24",
+		secondary + "local-ipv4s":            "10.0.2.20",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []NetworkInterface{
+		{ID: "eni-1", MAC: "06:00:00:00:00:01", DeviceNumber: 0, PrivateIPv4s: []string{"10.0.1.10", "10.0.1.11"}, SubnetCIDR: "10.0.1.0/24"},
+		{ID: "eni-2", MAC: "06:00:00:00:00:02", DeviceNumber: 1, PrivateIPv4s: []string{"10.0.2.20"}, SubnetCIDR: "10.0.2.0/24"},
+	}, interfaces)
+}
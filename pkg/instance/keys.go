@@ -0,0 +1,36 @@
+package instance
+
+import (
+	"context"
+	"strings"
+)
+
+// imdsPublicKeysPath is the IMDS path that lists the public keys provided at launch, as "<index>=<key name>" lines.
+const imdsPublicKeysPath = "public-keys"
+
+// PublicKeys returns the OpenSSH public keys provided to the instance at launch. An instance launched without a key
+// pair has none.
+func PublicKeys(ctx context.Context, client IMDSAPI) ([]string, error) {
+	list, err := readMetadata(ctx, client, imdsPublicKeysPath+"/")
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, line := range strings.Split(list, "\n") {
+		index, _, _ := strings.Cut(line, "=")
+		if index == "" {
+			continue
+		}
+		key, err := readMetadata(ctx, client, imdsPublicKeysPath+"/"+index+"/openssh-key")
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
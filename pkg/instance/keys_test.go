@@ -0,0 +1,24 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicKeys(t *testing.T) {
+	keys, err := PublicKeys(context.Background(), fakeIMDS{
+		"public-keys/":              "0=build-key\n1=admin-key",
+		"public-keys/0/openssh-key": "ssh-ed25519 AAAA build-key\n",
+		"public-keys/1/openssh-key": "ssh-rsa BBBB admin-key",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssh-ed25519 AAAA build-key", "ssh-rsa BBBB admin-key"}, keys)
+}
+
+func TestPublicKeys_None(t *testing.T) {
+	keys, err := PublicKeys(context.Background(), fakeIMDS{})
+	assert.NoError(t, err, "an instance launched without a key pair has no keys")
+	assert.Empty(t, keys)
+}
@@ -0,0 +1,150 @@
+// Package instance provides the functionality necessary for retrieving information about the EC2 instance from IMDS
+// and the EC2 API.
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/sirupsen/logrus"
+)
+
+// imdsTagsPath is the IMDS path that lists instance tag keys when tags in instance metadata are enabled.
+const imdsTagsPath = "tags/instance"
+
+// IMDSAPI is the subset of the IMDS client used to read instance metadata and dynamic data.
+type IMDSAPI interface {
+	GetMetadata(ctx context.Context, params *imds.GetMetadataInput, optFns ...func(*imds.Options)) (*imds.GetMetadataOutput, error)
+	GetDynamicData(ctx context.Context, params *imds.GetDynamicDataInput, optFns ...func(*imds.Options)) (*imds.GetDynamicDataOutput, error)
+}
+
+// EC2API is the subset of the EC2 client used to read instance tags.
+type EC2API interface {
+	DescribeTags(ctx context.Context, params *ec2.DescribeTagsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTagsOutput, error)
+}
+
+// TagReader reads the instance's tags from IMDS when tags in instance metadata are enabled, and from the EC2 API
+// otherwise.
+type TagReader struct {
+	// IMDS is the instance metadata client.
+	IMDS IMDSAPI
+	// EC2 is the EC2 API client, only used when tags aren't available from IMDS. It may be nil to only use IMDS.
+	EC2 EC2API
+}
+
+// NewTagReader creates a TagReader with clients for the configuration.
+func NewTagReader(cfg aws.Config) *TagReader {
+	return &TagReader{
+		IMDS: imds.NewFromConfig(cfg),
+		EC2:  ec2.NewFromConfig(cfg),
+	}
+}
+
+// Tags returns the instance's tags.
+func (r *TagReader) Tags(ctx context.Context) (map[string]string, error) {
+	tags, err := r.imdsTags(ctx)
+	if err == nil {
+		return tags, nil
+	}
+	if !isNotFound(err) || r.EC2 == nil {
+		return nil, err
+	}
+	logrus.Debug("Instance tags aren't available from IMDS, using the EC2 API")
+
+	identity, err := CachedIdentity(ctx, r.IMDS)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.ec2Tags(ctx, identity.InstanceID)
+}
+
+// Tag returns the value of the instance tag with key and whether the tag exists.
+func (r *TagReader) Tag(ctx context.Context, key string) (string, bool, error) {
+	tags, err := r.Tags(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	v, ok := tags[key]
+
+	return v, ok, nil
+}
+
+// imdsTags reads every tag from the instance metadata tags category.
+func (r *TagReader) imdsTags(ctx context.Context) (map[string]string, error) {
+	keys, err := r.metadata(ctx, imdsTagsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, key := range strings.Split(keys, "\n") {
+		if key == "" {
+			continue
+		}
+		value, err := r.metadata(ctx, imdsTagsPath+"/"+key)
+		if err != nil {
+			return nil, err
+		}
+		tags[key] = value
+	}
+
+	return tags, nil
+}
+
+// ec2Tags reads every tag of the instance from the EC2 API.
+func (r *TagReader) ec2Tags(ctx context.Context, instanceID string) (map[string]string, error) {
+	tags := map[string]string{}
+	paginator := ec2.NewDescribeTagsPaginator(r.EC2, &ec2.DescribeTagsInput{
+		Filters: []types.Filter{
+			{Name: aws.String("resource-id"), Values: []string{instanceID}},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("describe tags: %w", err)
+		}
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	return tags, nil
+}
+
+// metadata reads the IMDS path as a string.
+func (r *TagReader) metadata(ctx context.Context, path string) (string, error) {
+	return readMetadata(ctx, r.IMDS, path)
+}
+
+// readMetadata reads the IMDS path as a string.
+func readMetadata(ctx context.Context, client IMDSAPI, path string) (string, error) {
+	out, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: path})
+	if err != nil {
+		return "", fmt.Errorf("get metadata %s: %w", path, err)
+	}
+	defer func() { _ = out.Content.Close() }()
+
+	data, err := io.ReadAll(out.Content)
+	if err != nil {
+		return "", fmt.Errorf("read metadata %s: %w", path, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// isNotFound reports whether err is an IMDS "404 Not Found" response.
+func isNotFound(err error) bool {
+	var re *awshttp.ResponseError
+	return errors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotFound
+}
@@ -0,0 +1,94 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIMDS serves metadata paths from a map and responds "404 Not Found" for everything else.
+type fakeIMDS map[string]string
+
+func (f fakeIMDS) GetMetadata(_ context.Context, in *imds.GetMetadataInput, _ ...func(*imds.Options)) (*imds.GetMetadataOutput, error) {
+	v, ok := f[in.Path]
+	if !ok {
+		return nil, &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotFound}},
+			Err:      errors.New("not found"),
+		}}
+	}
+
+	return &imds.GetMetadataOutput{Content: io.NopCloser(strings.NewReader(v))}, nil
+}
+
+// GetDynamicData serves dynamic data paths from the map with a "dynamic/" prefix.
+func (f fakeIMDS) GetDynamicData(ctx context.Context, in *imds.GetDynamicDataInput, _ ...func(*imds.Options)) (*imds.GetDynamicDataOutput, error) {
+	out, err := f.GetMetadata(ctx, &imds.GetMetadataInput{Path: "dynamic/" + in.Path})
+	if err != nil {
+		return nil, err
+	}
+
+	return &imds.GetDynamicDataOutput{Content: out.Content}, nil
+}
+
+type fakeEC2 struct {
+	tags []types.TagDescription
+}
+
+func (f *fakeEC2) DescribeTags(_ context.Context, in *ec2.DescribeTagsInput, _ ...func(*ec2.Options)) (*ec2.DescribeTagsOutput, error) {
+	if aws.ToString(in.NextToken) == "" {
+		return &ec2.DescribeTagsOutput{Tags: f.tags[:1], NextToken: aws.String("page-2")}, nil
+	}
+
+	return &ec2.DescribeTagsOutput{Tags: f.tags[1:]}, nil
+}
+
+func TestTagReader_IMDS(t *testing.T) {
+	r := &TagReader{IMDS: fakeIMDS{
+		"tags/instance":      "Name\nteam\n",
+		"tags/instance/Name": "build-mac",
+		"tags/instance/team": "ci",
+	}}
+
+	tags, err := r.Tags(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Name": "build-mac", "team": "ci"}, tags)
+
+	v, ok, err := r.Tag(context.Background(), "team")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "ci", v)
+}
+
+func TestTagReader_EC2Fallback(t *testing.T) {
+	resetIdentityCache(t)
+	r := &TagReader{
+		IMDS: fakeIMDS{"dynamic/instance-identity/document": `{"instanceId": "i-123"}`},
+		EC2: &fakeEC2{tags: []types.TagDescription{
+			{Key: aws.String("Name"), Value: aws.String("build-mac")},
+			{Key: aws.String("team"), Value: aws.String("ci")},
+		}},
+	}
+
+	tags, err := r.Tags(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Name": "build-mac", "team": "ci"}, tags, "every page should be read")
+}
+
+func TestTagReader_IMDSOnly(t *testing.T) {
+	r := &TagReader{IMDS: fakeIMDS{}}
+
+	_, err := r.Tags(context.Background())
+	assert.Error(t, err)
+}
@@ -1,3 +1,5 @@
+// Package sysdiagnose provides the functionality necessary for collecting sysdiagnose archives, which bundle the logs,
+// system state, and configuration needed to diagnose problems on macOS, and for describing them with a manifest.
 package sysdiagnose
 
 import (
@@ -6,16 +8,24 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/progress"
+	"github.com/aws/ec2-macos-utils/pkg/command"
 )
 
 const (
 	// systemSysdiagnoseExecutable is the hardcoded path to the macOS sysdiagnose executable
 	systemSysdiagnoseExecutable = "/usr/bin/sysdiagnose"
+
+	// progressInterval is how often progress is reported while sysdiagnose runs since it gives no indication of
+	// its own completion.
+	progressInterval = 10 * time.Second
 )
 
 // Collect executes a full run of sysdiagnose and returns a handle to read the
@@ -23,6 +33,18 @@ const (
 // finished. Sysdiagnose requires root privileges to collect system data and an
 // error will be returned if called without root privileges.
 func Collect(ctx context.Context, archiveName string) (io.ReadCloser, error) {
+	return Collector{}.Collect(ctx, archiveName)
+}
+
+// Collector collects sysdiagnose archives.
+type Collector struct {
+	// Executor runs sysdiagnose. Nil uses command.Default.
+	Executor command.Executor
+}
+
+// Collect executes a full run of sysdiagnose with the Collector's Executor and returns a handle to read the resulting
+// archive, like the package's Collect.
+func (c Collector) Collect(ctx context.Context, archiveName string) (io.ReadCloser, error) {
 	// Validate archive name
 	if archiveName == "" {
 		return nil, errors.New("archive name required")
@@ -48,18 +70,24 @@ func Collect(ctx context.Context, archiveName string) (io.ReadCloser, error) {
 	}
 	logrus.WithContext(ctx).WithField("args", args).Debug("preparing sysdiagnose collection")
 
-	cmd := exec.CommandContext(ctx, systemSysdiagnoseExecutable, args...)
+	cmd := command.New(append([]string{systemSysdiagnoseExecutable}, args...)...)
 
 	logrus.WithContext(ctx).WithFields(logrus.Fields{
 		"archive_name": archiveName,
-		"command":      cmd.String(),
+		"command":      strings.Join(cmd.Argv, " "),
 	}).Info("running sysdiagnose - this produces large archive file in a few minutes, usually 100s of MB")
 
+	reporter := contextual.Progress(ctx)
+	reporter.Report("collect", 0, "running sysdiagnose")
+
 	tStart := time.Now()
-	err = cmd.Run()
+	stopReporting := reportElapsed(reporter, "collect", tStart)
+	err = c.executor().Run(ctx, cmd)
+	stopReporting()
 	if err != nil {
 		return nil, fmt.Errorf("error running sysdiagnose: %w", err)
 	}
+	reporter.Report("collect", 100, "sysdiagnose collected")
 
 	runtime := time.Since(tStart).Truncate(time.Second)
 	logrus.WithContext(ctx).WithField("runtime_seconds", runtime.Seconds()).Info("sysdiagnose collected")
@@ -82,6 +110,36 @@ func Collect(ctx context.Context, archiveName string) (io.ReadCloser, error) {
 	return handle, nil
 }
 
+// executor returns the Executor that runs sysdiagnose.
+func (c Collector) executor() command.Executor {
+	if c.Executor == nil {
+		return command.Default
+	}
+
+	return c.Executor
+}
+
+// reportElapsed periodically reports the time elapsed since start for the phase until the returned function is
+// called.
+func reportElapsed(reporter progress.Reporter, phase string, start time.Time) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				elapsed := time.Since(start).Truncate(time.Second)
+				reporter.Report(phase, progress.Unknown, fmt.Sprintf("running for %s", elapsed))
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
 func sysdiagnoseArgs(outputFileFullPath string) ([]string, error) {
 	if outputFileFullPath == "" {
 		return nil, errors.New("output file path required")
@@ -0,0 +1,91 @@
+package sysdiagnose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// archiveSuffix is the file extension of sysdiagnose archives.
+const archiveSuffix = ".tar.gz"
+
+// Manifest describes a saved sysdiagnose archive so that it can be correlated with the invocation that created it.
+type Manifest struct {
+	// RunID identifies the invocation that collected the archive.
+	RunID string `json:"run_id"`
+	// Archive is the file name of the archive.
+	Archive string `json:"archive"`
+	// CreatedAt is when the archive was saved.
+	CreatedAt time.Time `json:"created_at"`
+	// Bytes is the size of the archive.
+	Bytes int64 `json:"bytes"`
+	// SHA256 is the hex encoded checksum of the archive.
+	SHA256 string `json:"sha256"`
+	// ToolVersion is the version of EC2 macOS Utils that collected the archive.
+	ToolVersion string `json:"tool_version"`
+	// Reason is why the archive was collected, e.g. "IMDS connectivity check failed".
+	Reason string `json:"reason,omitempty"`
+	// Trigger describes what triggered the collection, e.g. the watchdog and the ID of the scheduled event, so that
+	// archives can be triaged by cause without opening them.
+	Trigger map[string]string `json:"trigger,omitempty"`
+	// Counters are the counts of what the invocation that collected the archive did until then, keyed by
+	// Prometheus series, e.g. ec2_macos_utils_checks_total{check="imds"}.
+	Counters map[string]float64 `json:"counters,omitempty"`
+	// Attachments are the file names of what was saved alongside the archive for the same collection, e.g. a snapshot
+	// of the network stack taken before the archive was collected.
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// ManifestPath returns the path of the manifest that accompanies the archive at archivePath.
+func ManifestPath(archivePath string) string {
+	return strings.TrimSuffix(archivePath, archiveSuffix) + ".manifest.json"
+}
+
+// WriteManifest saves the manifest alongside the archive at archivePath and returns the manifest's path. Like the
+// archive, the manifest is created read-only and is never overwritten, nor written through a symlink.
+func WriteManifest(archivePath string, m Manifest) (string, error) {
+	path := ManifestPath(archivePath)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0400)
+	if err != nil {
+		return "", fmt.Errorf("create manifest: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := EncodeManifest(f, m); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// ReadManifest reads the manifest that accompanies the archive at archivePath.
+func ReadManifest(archivePath string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(ManifestPath(archivePath))
+	if err != nil {
+		return m, fmt.Errorf("read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("decode manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// EncodeManifest writes the manifest as JSON to w, e.g. to a file created by the caller with its own ownership.
+func EncodeManifest(w io.Writer, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package sysdiagnose
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteManifest(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "sysdiagnose_20240102_030405.tar.gz")
+	m := Manifest{
+		RunID:    "run-1",
+		Archive:  filepath.Base(archive),
+		Bytes:    42,
+		Reason:   "IMDS connectivity check failed",
+		Trigger:  map[string]string{"watchdog": "network-health-monitor"},
+		Counters: map[string]float64{`ec2_macos_utils_checks_total{check="imds"}`: 2},
+	}
+
+	path, err := WriteManifest(archive, m)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(archive), "sysdiagnose_20240102_030405.manifest.json"), path)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var decoded Manifest
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, m, decoded)
+
+	_, err = WriteManifest(archive, m)
+	assert.Error(t, err, "should not overwrite an existing manifest")
+
+	// A symlink planted where the manifest goes isn't followed.
+	target := filepath.Join(t.TempDir(), "target")
+	link := ManifestPath(filepath.Join(filepath.Dir(archive), "sysdiagnose_20240102_030406.tar.gz"))
+	assert.NoError(t, os.Symlink(target, link))
+	_, err = WriteManifest(filepath.Join(filepath.Dir(archive), "sysdiagnose_20240102_030406.tar.gz"), m)
+	assert.Error(t, err)
+	assert.NoFileExists(t, target)
+}
+
+func TestReadManifest(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "sysdiagnose_20240102_030405.tar.gz")
+	_, err := ReadManifest(archive)
+	assert.Error(t, err)
+
+	m := Manifest{RunID: "run-1", Archive: filepath.Base(archive), Attachments: []string{"network-snapshot_20240102_030404.json"}}
+	_, err = WriteManifest(archive, m)
+	assert.NoError(t, err)
+	read, err := ReadManifest(archive)
+	assert.NoError(t, err)
+	assert.Equal(t, m, read)
+}
@@ -0,0 +1,61 @@
+//go:build darwin && cgo
+
+package system
+
+/*
+#cgo LDFLAGS: -framework IOKit -framework CoreFoundation
+#include <CoreFoundation/CoreFoundation.h>
+#include <IOKit/IOKitLib.h>
+#include <stdlib.h>
+
+// platform_property copies the string property of the platform expert device with the key into buf. It returns 0
+// on success, 1 when the device isn't found, and 2 when the property isn't found or isn't a string that fits in buf.
+static int platform_property(const char *key, char *buf, CFIndex size) {
+	io_service_t device = IOServiceGetMatchingService(MACH_PORT_NULL, IOServiceMatching("IOPlatformExpertDevice"));
+	if (device == IO_OBJECT_NULL) {
+		return 1;
+	}
+
+	CFStringRef cfKey = CFStringCreateWithCString(kCFAllocatorDefault, key, kCFStringEncodingUTF8);
+	CFTypeRef value = IORegistryEntryCreateCFProperty(device, cfKey, kCFAllocatorDefault, 0);
+	CFRelease(cfKey);
+	IOObjectRelease(device);
+	if (value == NULL) {
+		return 2;
+	}
+
+	Boolean ok = CFGetTypeID(value) == CFStringGetTypeID() &&
+		CFStringGetCString((CFStringRef)value, buf, size, kCFStringEncodingUTF8);
+	CFRelease(value);
+
+	return ok ? 0 : 2;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// platformPropertySize is the size of the buffer the platform expert device's properties are copied into, which fits
+// the UUID and serial number with room to spare.
+const platformPropertySize = 256
+
+// platformProperty reads the string property of the platform expert device with IOKit.
+func platformProperty(key string) (string, error) {
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+	buf := (*C.char)(C.malloc(platformPropertySize))
+	defer C.free(unsafe.Pointer(buf))
+
+	switch C.platform_property(cKey, buf, platformPropertySize) {
+	case 0:
+		return C.GoString(buf), nil
+	case 1:
+		return "", errors.New("IOPlatformExpertDevice not found in the I/O Registry")
+	default:
+		return "", fmt.Errorf("%s not found in the I/O Registry", key)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !darwin || !cgo
+
+package system
+
+import (
+	"errors"
+	"fmt"
+)
+
+// platformProperty returns errors.ErrUnsupported, as IOKit can only be called through cgo on macOS.
+func platformProperty(key string) (string, error) {
+	return "", fmt.Errorf("read %s with IOKit: %w", key, errors.ErrUnsupported)
+}
@@ -0,0 +1,11 @@
+//go:build darwin
+
+package system
+
+import "golang.org/x/sys/unix"
+
+// SysctlUint32 reads the kernel state variable with the name, e.g. "hw.optional.arm64", without running sysctl(8).
+// Variables that don't exist fail with an error matching fs.ErrNotExist.
+func SysctlUint32(name string) (uint32, error) {
+	return unix.SysctlUint32(name)
+}
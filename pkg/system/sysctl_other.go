@@ -0,0 +1,13 @@
+//go:build !darwin
+
+package system
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SysctlUint32 returns errors.ErrUnsupported, as the kernel state variables read by the utility only exist on macOS.
+func SysctlUint32(name string) (uint32, error) {
+	return 0, fmt.Errorf("sysctl %s: %w", name, errors.ErrUnsupported)
+}
@@ -3,14 +3,13 @@ package system
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
 	"strings"
 
-	"howett.net/plist"
+	"github.com/aws/ec2-macos-utils/internal/plistutil"
+	"github.com/aws/ec2-macos-utils/pkg/command"
 )
 
 const (
@@ -76,19 +75,6 @@ func (v *VersionInfo) Product() (*Product, error) {
 	return newProduct(v.ProductVersion)
 }
 
-// decodeVersionInfo attempts to decode the raw data from the reader
-// into a new VersionInfo struct.
-func decodeVersionInfo(reader io.ReadSeeker) (*VersionInfo, error) {
-	// Decode the system version plist into the VersionInfo struct
-	var version VersionInfo
-	decoder := plist.NewDecoder(reader)
-	if err := decoder.Decode(&version); err != nil {
-		return nil, fmt.Errorf("system failed to decode contents of reader: %w", err)
-	}
-
-	return &version, nil
-}
-
 // readVersion reads the SystemVersion plist data from disk
 // (versionPath). If "SYSTEM_VERSION_COMPAT" is enabled, it will
 // instead read from dotVersionPath to bypass macOS's compat mode.
@@ -108,65 +94,112 @@ func readVersion() (*VersionInfo, error) {
 	return version, nil
 }
 
-// readProductVersion opens the given file and attempts to decode it
-// as VersionInfo.
+// readProductVersionFile reads the given file and attempts to decode
+// it as VersionInfo.
 func readProductVersionFile(path string) (*VersionInfo, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	// Parse loaded plist version data
-	version, err := decodeVersionInfo(bytes.NewReader(data))
-	if err != nil {
-		return nil, err
+	var version VersionInfo
+	if err := plistutil.DecodeFile(path, &version); err != nil {
+		return nil, fmt.Errorf("system failed to read version info: %w", err)
 	}
-	return version, nil
+
+	return &version, nil
 }
 
+const (
+	// platformUUIDKey is the property of the platform expert device with the host's platform UUID.
+	platformUUIDKey = "IOPlatformUUID"
+	// serialNumberKey is the property of the platform expert device with the host's serial number.
+	serialNumberKey = "IOPlatformSerialNumber"
+)
+
 // GetHostIOPlatformUUID retrieves the host's platform UUID
 // which is unique for each Mac device
 func GetHostIOPlatformUUID() (string, error) {
-	out, err := queryIORegistryPlatformEntry()
+	return hostPlatformProperty(platformUUIDKey)
+}
+
+// GetHostSerialNumber retrieves the host's serial number.
+func GetHostSerialNumber() (string, error) {
+	return hostPlatformProperty(serialNumberKey)
+}
+
+// hostPlatformProperty reads the property of the platform expert device directly with IOKit, which doesn't spawn a
+// process or parse its output, and falls back to querying ioreg in builds without IOKit, i.e. without cgo.
+func hostPlatformProperty(key string) (string, error) {
+	value, err := platformProperty(key)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return IORegistry{}.platformProperty(context.Background(), key)
+	}
+
+	return value, err
+}
+
+// IORegistry queries the I/O Registry of the host with ioreg.
+type IORegistry struct {
+	// Executor runs ioreg. Nil uses command.Default.
+	Executor command.Executor
+}
+
+// PlatformUUID retrieves the host's platform UUID which is unique for each Mac device.
+func (r IORegistry) PlatformUUID(ctx context.Context) (string, error) {
+	return r.platformProperty(ctx, platformUUIDKey)
+}
+
+// SerialNumber retrieves the host's serial number.
+func (r IORegistry) SerialNumber(ctx context.Context) (string, error) {
+	return r.platformProperty(ctx, serialNumberKey)
+}
+
+// platformProperty queries the string property of the platform expert device.
+func (r IORegistry) platformProperty(ctx context.Context, key string) (string, error) {
+	out, err := r.queryPlatformEntry(ctx)
 	if err != nil {
 		return "", err
 	}
-	return parseIOPlatformUUID(out)
+	return parseIORegistryProperty(out, key)
 }
 
-// queryIORegistryPlatformEntry executes the ioreg command and returns its output
-func queryIORegistryPlatformEntry() ([]byte, error) {
-	cmd := exec.Command("ioreg", "-d1", "-c", "IOPlatformExpertDevice", "-r", "-w0")
-	out, err := cmd.Output()
+// queryPlatformEntry executes the ioreg command and returns its output
+func (r IORegistry) queryPlatformEntry(ctx context.Context) ([]byte, error) {
+	e := r.Executor
+	if e == nil {
+		e = command.Default
+	}
+	out, err := e.Output(ctx, command.New("ioreg", "-d1", "-c", "IOPlatformExpertDevice", "-r", "-w0"))
 	if err != nil {
 		return nil, fmt.Errorf("ioreg query: %w", err)
 	}
-	return out, nil
+	return []byte(out.Stdout), nil
 }
 
 // parseIOPlatformUUID extracts the platform UUID from ioreg output
 func parseIOPlatformUUID(data []byte) (string, error) {
-	// platformUUIDKeyToken is the key identifier used to locate the IOPlatformUUID
-	const platformUUIDKeyToken = `"IOPlatformUUID"`
+	return parseIORegistryProperty(data, platformUUIDKey)
+}
+
+// parseIORegistryProperty extracts the string property with the key from ioreg output
+func parseIORegistryProperty(data []byte, key string) (string, error) {
+	// keyToken is the key identifier used to locate the property
+	keyToken := `"` + key + `"`
 
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		line := scanner.Text()
-		if !strings.Contains(line, platformUUIDKeyToken) {
+		if !strings.Contains(line, keyToken) {
 			continue
 		}
 
-		// Parse the line containing UUID
+		// Parse the line containing the property
 		fields := strings.Fields(strings.ReplaceAll(line, `"`, ""))
 		if len(fields) != 3 {
 			continue
 		}
-		key, uuid := fields[0], fields[2]
-		if key != strings.Trim(platformUUIDKeyToken, `"`) {
+		if fields[0] != key {
 			continue
 		}
 
-		if uuid != "" {
-			return uuid, nil
+		if value := fields[2]; value != "" {
+			return value, nil
 		}
 	}
 
@@ -174,5 +207,5 @@ func parseIOPlatformUUID(data []byte) (string, error) {
 		return "", fmt.Errorf("error scanning ioreg output: %w", err)
 	}
 
-	return "", errors.New("UUID not found in ioreg output")
+	return "", fmt.Errorf("%s not found in ioreg output", key)
 }
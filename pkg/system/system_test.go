@@ -1,9 +1,13 @@
 package system
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/command"
+	"github.com/aws/ec2-macos-utils/pkg/command/commandtest"
 )
 
 func TestParseIOPlatformUUID(t *testing.T) {
@@ -114,3 +118,27 @@ func TestParseIOPlatformUUID(t *testing.T) {
 		})
 	}
 }
+
+func TestIORegistry_PlatformUUID(t *testing.T) {
+	r := (&commandtest.Recorder{}).Respond(commandtest.Response{
+		Output: command.Output{Stdout: `    "IOPlatformUUID" = "ABCD1234-5678-90EF-GHIJ-KLMNOPQRSTUV"`},
+	}, "ioreg", "-d1", "-c", "IOPlatformExpertDevice", "-r", "-w0")
+
+	uuid, err := IORegistry{Executor: r}.PlatformUUID(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCD1234-5678-90EF-GHIJ-KLMNOPQRSTUV", uuid)
+
+	_, err = IORegistry{Executor: &commandtest.Recorder{}}.PlatformUUID(context.Background())
+	assert.Error(t, err, "ioreg output without the UUID should fail")
+}
+
+func TestIORegistry_SerialNumber(t *testing.T) {
+	r := (&commandtest.Recorder{}).Respond(commandtest.Response{
+		Output: command.Output{Stdout: `    "IOPlatformSerialNumber" = "C02ABC123DEF"
+    "IOPlatformUUID" = "ABCD1234-5678-90EF-GHIJ-KLMNOPQRSTUV"`},
+	}, "ioreg", "-d1", "-c", "IOPlatformExpertDevice", "-r", "-w0")
+
+	serial, err := IORegistry{Executor: r}.SerialNumber(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "C02ABC123DEF", serial)
+}